@@ -0,0 +1,473 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package accessreview provides the periodic review of the accounts of each owner.
+// Version: 1.0.0
+package accessreview
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/accounts"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/compliance"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	cttypes "github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	ct "github.com/aws/aws-sdk-go-v2/service/controltower"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"go.uber.org/zap"
+)
+
+// Compliance status of a reviewed account
+const (
+	ComplianceCompliant    = "compliant"
+	ComplianceNonCompliant = "non-compliant"
+	ComplianceNotChecked   = "not-checked"
+)
+
+// Accounts looked up in CloudTrail at once
+const maxConcurrentLookups = 10
+
+// Events read per page while looking for the last activity of an account
+const activityPageSize = 50
+
+// Account is an account of an owner with its enabled controls, last activity and
+// compliance status. LastActivity is unset when CloudTrail could not be read or holds
+// no event.
+type Account struct {
+	ID              string          `json:"id"`
+	Name            string          `json:"name"`
+	Email           string          `json:"email"`
+	OU              string          `json:"ou"`
+	Status          string          `json:"status"`
+	Controls        []string        `json:"controls,omitempty"`
+	LastActivity    *time.Time      `json:"lastActivity,omitempty"`
+	Inactive        bool            `json:"inactive"`
+	Compliance      string          `json:"compliance"`
+	Findings        int             `json:"findings"`
+	HighestSeverity report.Severity `json:"highestSeverity,omitempty"`
+}
+
+// Owner groups the accounts of an owner. Accounts without an owner are grouped under
+// an empty owner.
+type Owner struct {
+	Owner    string    `json:"owner"`
+	Accounts []Account `json:"accounts"`
+}
+
+// Review lists the accounts of the organization grouped by owner
+type Review struct {
+	RunID          string    `json:"runId"`
+	GeneratedAt    time.Time `json:"generatedAt"`
+	OwnerAttribute string    `json:"ownerAttribute"`
+	InactiveDays   int       `json:"inactiveDays"`
+	Owners         []Owner   `json:"owners"`
+}
+
+// Reviewer builds the access review from the live organization
+type Reviewer struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	cfg       *config.LandingZoneConfig
+	review    *config.AccessReviewConfig
+	cache     *orgcache.Cache
+	base      aws.Config
+	orgClient *organizations.Client
+	ctClient  *ct.Client
+}
+
+// NewReviewer creates a reviewer using the credentials of the management account. The
+// configuration must declare the access review.
+func NewReviewer(ctx context.Context, cfg *config.LandingZoneConfig, cache *orgcache.Cache) (*Reviewer, error) {
+	if cfg.AccessReview == nil {
+		return nil, fmt.Errorf("no access review is configured, see accessReview")
+	}
+
+	logger, err := logging.NewLogger("access_review")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("access_review")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HomeRegion != "" {
+		base.Region = cfg.HomeRegion
+	}
+
+	return &Reviewer{
+		logger:    logger,
+		metrics:   metrics,
+		cfg:       cfg,
+		review:    cfg.AccessReview,
+		cache:     cache,
+		base:      base,
+		orgClient: organizations.NewFromConfig(base),
+		ctClient:  ct.NewFromConfig(base),
+	}, nil
+}
+
+// Review lists every account of the organization under the owner held by its owner
+// attribute, with the Control Tower controls enabled on its OU and the OUs above it,
+// its last CloudTrail management event in the home region and the findings of the
+// compliance checks
+func (r *Reviewer) Review(ctx context.Context) (*Review, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordDuration("access_review", time.Since(start))
+	}()
+
+	manager, err := accounts.NewAccountManager(ctx, accounts.WithOrganizationCache(r.cache),
+		accounts.WithParameterNames(r.cfg), accounts.WithAccountAttributes(r.cfg))
+	if err != nil {
+		return nil, err
+	}
+	listed, err := manager.SearchAccounts(ctx, accounts.AccountFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := r.orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe organization: %w", err)
+	}
+
+	controls, err := r.controls(ctx, org.Organization)
+	if err != nil {
+		return nil, err
+	}
+	findings, err := r.findings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	activity := r.activity(ctx, listed, aws.ToString(org.Organization.MasterAccountId))
+
+	review := &Review{
+		RunID:          runid.ID(),
+		GeneratedAt:    time.Now().UTC(),
+		OwnerAttribute: r.review.Owner(),
+		InactiveDays:   r.review.Inactivity(),
+	}
+	inactiveBefore := review.GeneratedAt.AddDate(0, 0, -review.InactiveDays)
+
+	owners := make(map[string]*Owner)
+	for _, info := range listed {
+		account := Account{
+			ID:         info.ID,
+			Name:       info.Name,
+			Email:      info.Email,
+			OU:         info.OU,
+			Status:     info.Status,
+			Controls:   controls[info.OUID],
+			Compliance: ComplianceNotChecked,
+		}
+		if last, ok := activity[info.ID]; ok {
+			account.LastActivity = &last
+			account.Inactive = last.Before(inactiveBefore)
+		}
+		if info.Status == string(orgtypes.AccountStatusActive) {
+			account.Compliance = ComplianceCompliant
+			for _, finding := range findings[info.ID] {
+				account.Findings++
+				if account.HighestSeverity == "" || severityRank(finding.Severity) < severityRank(account.HighestSeverity) {
+					account.HighestSeverity = finding.Severity
+				}
+			}
+			if account.Findings > 0 {
+				account.Compliance = ComplianceNonCompliant
+			}
+		}
+
+		name := info.Attributes[review.OwnerAttribute]
+		owner, ok := owners[name]
+		if !ok {
+			owner = &Owner{Owner: name}
+			owners[name] = owner
+		}
+		owner.Accounts = append(owner.Accounts, account)
+	}
+
+	for _, owner := range owners {
+		review.Owners = append(review.Owners, *owner)
+	}
+	// Accounts without an owner last
+	sort.Slice(review.Owners, func(i, j int) bool {
+		a, b := review.Owners[i].Owner, review.Owners[j].Owner
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+		return a < b
+	})
+
+	r.metrics.IncrementCounter("access_reviews")
+	r.logger.Info("access review built",
+		zap.Int("owners", len(review.Owners)),
+		zap.Int("accounts", len(listed)))
+	return review, nil
+}
+
+// controls returns the names of the Control Tower controls applying to the accounts
+// of each OU, enabled on the OU itself or an OU above it
+func (r *Reviewer) controls(ctx context.Context, org *orgtypes.Organization) (map[string][]string, error) {
+	ouArn := fmt.Sprintf("arn:%s:organizations::%s:ou/%s/", awsclient.Partition(),
+		aws.ToString(org.MasterAccountId), aws.ToString(org.Id))
+
+	ous, err := r.cache.OUs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// OUs are listed parents first, so the controls of a parent are known before its
+	// children
+	controls := make(map[string][]string)
+	for _, ou := range ous {
+		enabled := append([]string(nil), controls[ou.ParentID]...)
+		paginator := ct.NewListEnabledControlsPaginator(r.ctClient, &ct.ListEnabledControlsInput{
+			TargetIdentifier: aws.String(ouArn + ou.ID),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list enabled controls of %s: %w", ou.Name, err)
+			}
+			for _, control := range page.EnabledControls {
+				identifier := aws.ToString(control.ControlIdentifier)
+				enabled = append(enabled, identifier[strings.LastIndex(identifier, "/")+1:])
+			}
+		}
+		sort.Strings(enabled)
+		controls[ou.ID] = dedupe(enabled)
+	}
+	return controls, nil
+}
+
+// findings runs the compliance checks and returns their findings by account ID
+func (r *Reviewer) findings(ctx context.Context) (map[string][]report.Finding, error) {
+	auditor, err := compliance.NewAuditor(ctx, r.cfg, compliance.DefaultChecks(r.cfg)...)
+	if err != nil {
+		return nil, err
+	}
+	auditor.UseCache(r.cache)
+
+	audit := report.New()
+	if err := auditor.Run(ctx, audit); err != nil {
+		return nil, err
+	}
+	return audit.ByAccount(), nil
+}
+
+// activity returns the time of the last CloudTrail management event of each active
+// account. Accounts whose trail cannot be read are logged and left out.
+func (r *Reviewer) activity(ctx context.Context, listed []*accounts.AccountInfo, managementID string) map[string]time.Time {
+	roleName := awsclient.ReadOnlyRoleName(r.cfg)
+
+	var (
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, maxConcurrentLookups)
+		activity = make(map[string]time.Time)
+	)
+	for _, info := range listed {
+		if info.Status != string(orgtypes.AccountStatusActive) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(info *accounts.AccountInfo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			cfg := r.base
+			if info.ID != managementID {
+				cfg = awsclient.AssumeRole(r.base, info.ID, roleName)
+			}
+			last, err := latestEvent(ctx, cloudtrail.NewFromConfig(cfg), roleName)
+			if err != nil {
+				r.logger.Warn("failed to read the last activity of account",
+					zap.String("accountId", info.ID),
+					zap.Error(err))
+				r.metrics.IncrementCounter("activity_errors")
+				return
+			}
+			if last.IsZero() {
+				return
+			}
+
+			mutex.Lock()
+			activity[info.ID] = last
+			mutex.Unlock()
+		}(info)
+	}
+	wg.Wait()
+	return activity
+}
+
+// latestEvent returns the time of the last management event of an account not caused
+// by this tool, paging back through the trail past the sessions of the tool and the
+// assumptions of its role. It returns the zero time when there is none.
+func latestEvent(ctx context.Context, client *cloudtrail.Client, roleName string) (time.Time, error) {
+	paginator := cloudtrail.NewLookupEventsPaginator(client, &cloudtrail.LookupEventsInput{
+		MaxResults: aws.Int32(activityPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, event := range page.Events {
+			if event.EventTime == nil || toolEvent(event, roleName) {
+				continue
+			}
+			return aws.ToTime(event.EventTime), nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// toolEvent reports whether an event was caused by this tool: a call of one of its
+// sessions, or the assumption of the role it reads the account with
+func toolEvent(event cttypes.Event, roleName string) bool {
+	if aws.ToString(event.Username) == awsclient.SessionName {
+		return true
+	}
+	if aws.ToString(event.EventName) != "AssumeRole" {
+		return false
+	}
+	for _, resource := range event.Resources {
+		if strings.HasSuffix(aws.ToString(resource.ResourceName), ":role/"+roleName) {
+			return true
+		}
+	}
+	return false
+}
+
+// For returns the review of the accounts of a single owner
+func (review *Review) For(owner string) *Review {
+	scoped := *review
+	scoped.Owners = nil
+	for _, o := range review.Owners {
+		if o.Owner == owner {
+			scoped.Owners = []Owner{o}
+		}
+	}
+	return &scoped
+}
+
+// Write renders a review as text or JSON
+func Write(w io.Writer, format string, review *Review) error {
+	switch format {
+	case report.FormatText:
+		return writeText(w, review)
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(review); err != nil {
+			return fmt.Errorf("failed to encode access review: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// writeText writes a table of accounts per owner
+func writeText(w io.Writer, review *Review) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, owner := range review.Owners {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "Owner %s (%d accounts)\n", ownerName(owner.Owner), len(owner.Accounts))
+		fmt.Fprintln(tw, "  ID\tNAME\tOU\tSTATUS\tLAST ACTIVITY\tCOMPLIANCE\tCONTROLS")
+		for _, account := range owner.Accounts {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%d\n", account.ID, account.Name, account.OU, account.Status,
+				lastActivity(account), complianceSummary(account), len(account.Controls))
+		}
+	}
+	fmt.Fprintf(tw, "\n%d owners, accounts inactive for more than %d days marked (run %s)\n",
+		len(review.Owners), review.InactiveDays, review.RunID)
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write access review: %w", err)
+	}
+	return nil
+}
+
+// ownerName renders the owner of a group
+func ownerName(owner string) string {
+	if owner == "" {
+		return "(none)"
+	}
+	return owner
+}
+
+// lastActivity renders the last activity of an account
+func lastActivity(account Account) string {
+	if account.LastActivity == nil {
+		return "unknown"
+	}
+	day := account.LastActivity.Format("2006-01-02")
+	if account.Inactive {
+		return day + " (inactive)"
+	}
+	return day
+}
+
+// complianceSummary renders the compliance status of an account
+func complianceSummary(account Account) string {
+	if account.Findings == 0 {
+		return account.Compliance
+	}
+	return fmt.Sprintf("%s (%d, %s)", account.Compliance, account.Findings, account.HighestSeverity)
+}
+
+// severityRank orders severities from the most to the least severe
+func severityRank(severity report.Severity) int {
+	switch severity {
+	case report.SeverityCritical:
+		return 0
+	case report.SeverityHigh:
+		return 1
+	case report.SeverityMedium:
+		return 2
+	case report.SeverityLow:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// dedupe removes the repeated values of a sorted list
+func dedupe(sorted []string) []string {
+	unique := sorted[:0]
+	for i, value := range sorted {
+		if i == 0 || value != sorted[i-1] {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package accessreview
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/mail"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+// Timeout of the post to the Slack webhook
+const slackTimeout = 10 * time.Second
+
+// Deliver emails the review through SES and posts its summary to Slack, as
+// configured. With sendToOwners, each owner holding an email address receives the
+// review of their own accounts. Every delivery is attempted; the first failure is
+// returned.
+func (r *Reviewer) Deliver(ctx context.Context, review *Review) error {
+	var failed error
+	fail := func(err error) {
+		r.logger.Error("failed to deliver access review", zap.Error(err))
+		r.metrics.IncrementCounter("delivery_failures")
+		if failed == nil {
+			failed = err
+		}
+	}
+
+	if email := r.review.Email; email != nil {
+		if err := r.email(ctx, email, review); err != nil {
+			fail(err)
+		}
+	}
+	if r.review.SlackWebhookEnv != "" {
+		if err := r.postToSlack(ctx, review); err != nil {
+			fail(err)
+		}
+	}
+	return failed
+}
+
+// email sends the review to the recipients, then to the owners
+func (r *Reviewer) email(ctx context.Context, email *config.AccessReviewEmailConfig, review *Review) error {
+	mailer, err := mail.NewMailer(ctx, r.cfg)
+	if err != nil {
+		return err
+	}
+
+	if len(email.To) > 0 {
+		if err := send(ctx, mailer, email.To, review); err != nil {
+			return err
+		}
+	}
+	if !email.SendToOwners {
+		return nil
+	}
+
+	var failed error
+	for _, owner := range review.Owners {
+		if !strings.Contains(owner.Owner, "@") {
+			continue
+		}
+		if err := send(ctx, mailer, []string{owner.Owner}, review.For(owner.Owner)); err != nil && failed == nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+// send emails a review with its text rendering
+func send(ctx context.Context, mailer *mail.Mailer, to []string, review *Review) error {
+	var body bytes.Buffer
+	if err := Write(&body, report.FormatText, review); err != nil {
+		return err
+	}
+
+	accounts := 0
+	for _, owner := range review.Owners {
+		accounts += len(owner.Accounts)
+	}
+	title := fmt.Sprintf("Account access review %s", review.GeneratedAt.Format("2006-01-02"))
+	return mailer.Send(ctx, mail.Message{
+		To:       to,
+		Subject:  title,
+		Template: mail.TemplateReport,
+		Data: mail.Report{
+			Title:   title,
+			Summary: fmt.Sprintf("%d accounts of %d owners, grouped by %s.", accounts, len(review.Owners), review.OwnerAttribute),
+			Body:    body.String(),
+		},
+		Text: body.String(),
+	})
+}
+
+// postToSlack posts a summary of the review per owner to the Slack incoming webhook
+func (r *Reviewer) postToSlack(ctx context.Context, review *Review) error {
+	url := os.Getenv(r.review.SlackWebhookEnv)
+	if url == "" {
+		return fmt.Errorf("Slack webhook URL of the access review is not set in %s", r.review.SlackWebhookEnv)
+	}
+
+	body, err := json.Marshal(map[string]string{"text": slackSummary(review)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Slack message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := (&http.Client{Timeout: slackTimeout}).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post access review to Slack: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Slack rejected the access review with status %d", resp.StatusCode)
+	}
+
+	r.metrics.IncrementCounter("slack_posts")
+	r.logger.Info("access review posted to Slack")
+	return nil
+}
+
+// slackSummary renders the accounts, inactive accounts and non-compliant accounts of
+// each owner in Slack markup
+func slackSummary(review *Review) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Account access review %s*\n", review.GeneratedAt.Format("2006-01-02"))
+	for _, owner := range review.Owners {
+		inactive, nonCompliant := 0, 0
+		for _, account := range owner.Accounts {
+			if account.Inactive {
+				inactive++
+			}
+			if account.Compliance == ComplianceNonCompliant {
+				nonCompliant++
+			}
+		}
+		fmt.Fprintf(&b, "• *%s*: %d accounts, %d inactive for more than %d days, %d non-compliant\n",
+			ownerName(owner.Owner), len(owner.Accounts), inactive, review.InactiveDays, nonCompliant)
+	}
+	fmt.Fprintf(&b, "Run `%s`", review.RunID)
+	return b.String()
+}
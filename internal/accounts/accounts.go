@@ -14,8 +14,19 @@ import (
 	"sync"
 	"time"
 
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/hooks"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/paramstore"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	ct "github.com/aws/aws-sdk-go-v2/service/controltower"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	sdkssm "github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
 	awsOrg "github.com/pulumi/pulumi-aws/sdk/v6/go/aws/organizations"
 	awsssm "github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ssm"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
@@ -25,8 +36,8 @@ import (
 
 // Constants for account management
 const (
-	// SSM parameter path format for account information
-	ssmAccountPathFmt = "/organization/accounts/%s"
+	// OU holding the default accounts
+	securityOUName = "Security"
 
 	// Default role name for account access
 	defaultAccessRoleName = "OrganizationAccountAccessRole"
@@ -50,16 +61,22 @@ const (
 	maxRetryDelay    = time.Second * 30
 )
 
+// AccountReader defines the read-only account queries. They call the Organizations
+// API directly and need no Pulumi run, see WithOrganizationCache.
+type AccountReader interface {
+	GetAccountStatus(ctx context.Context, accountID string) (string, error)
+	ListAccounts(ctx context.Context) ([]*AccountInfo, error)
+}
+
 // AccountService defines the interface for account operations
 type AccountService interface {
+	AccountReader
 	CreateAccount(ctx *pulumi.Context, config *AccountConfig) (*awsOrg.Account, error)
 	SuspendAccount(ctx *pulumi.Context, accountID string) error
 	ResumeAccount(ctx *pulumi.Context, accountID string) error
 	MoveAccount(ctx *pulumi.Context, accountID string, targetOUID string) error
-	GetAccountStatus(ctx *pulumi.Context, accountID string) (string, error)
-	ListAccounts(ctx *pulumi.Context) ([]*AccountInfo, error)
-	Backup(ctx context.Context) error
-	Restore(ctx context.Context, backupID string) error
+	Backup(ctx *pulumi.Context) error
+	Restore(ctx *pulumi.Context, backupID string) error
 }
 
 // AccountConfig represents the configuration for account creation
@@ -68,6 +85,14 @@ type AccountConfig struct {
 	Email      string             `json:"email"`
 	ParentOUID pulumi.StringInput `json:"parentOuId"`
 	Tags       map[string]string  `json:"tags"`
+
+	// OUName and Hooks select the post-provision hooks run once the account exists
+	OUName string              `json:"ouName,omitempty"`
+	Hooks  []config.HookConfig `json:"hooks,omitempty"`
+
+	// Attributes are the values of the custom account attributes, recorded in the
+	// registry
+	Attributes map[string]string `json:"attributes,omitempty"`
 }
 
 // AccountInfo represents account information
@@ -78,6 +103,13 @@ type AccountInfo struct {
 	Email  string            `json:"email"`
 	Status string            `json:"status"`
 	Tags   map[string]string `json:"tags"`
+
+	// OU and OUID are set on accounts listed from the organization
+	OU   string `json:"ou,omitempty"`
+	OUID string `json:"ouId,omitempty"`
+
+	// Attributes are the values of the custom account attributes
+	Attributes map[string]string `json:"attributes,omitempty"`
 }
 
 // AccountManager handles AWS account operations
@@ -88,13 +120,27 @@ type AccountManager struct {
 	mutex    sync.RWMutex
 	accounts map[string]*AccountInfo
 	emailRE  *regexp.Regexp
+	opts     []pulumi.ResourceOption
+	hooks    *hooks.Runner
+	hookOpts []pulumi.ResourceOption
+	sharing  *parameterSharing
+	names    config.ParameterNames
+
+	// Declared account attributes, set by WithAccountAttributes
+	attributes *config.LandingZoneConfig
+
+	// Clients of the live organization, set by WithOrganizationCache
+	orgClient *organizations.Client
+	orgCache  *orgcache.Cache
+	ssmClient *sdkssm.Client
+	ctClient  *ct.Client
 }
 
-// NewAccountManager creates a new account manager instance
-func NewAccountManager(ctx context.Context) (*AccountManager, error) {
-	logger, err := zap.NewProduction()
+// NewAccountManager creates a new account manager instance with the provided options
+func NewAccountManager(ctx context.Context, opts ...func(*AccountManager) error) (*AccountManager, error) {
+	logger, err := logging.NewLogger("accounts")
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+		return nil, err
 	}
 
 	metrics, err := metrics.NewCollector("accounts")
@@ -107,13 +153,53 @@ func NewAccountManager(ctx context.Context) (*AccountManager, error) {
 		return nil, fmt.Errorf("failed to compile email regex: %w", err)
 	}
 
-	return &AccountManager{
+	am := &AccountManager{
 		logger:   logger,
 		metrics:  metrics,
 		limiter:  rate.NewLimiter(rate.Limit(rateLimit), rateBurst),
 		accounts: make(map[string]*AccountInfo),
 		emailRE:  emailRE,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		if err := opt(am); err != nil {
+			return nil, err
+		}
+	}
+
+	return am, nil
+}
+
+// WithHooks enables the post-provision hooks of created accounts
+func WithHooks(ctx context.Context, lz *config.LandingZoneConfig) func(*AccountManager) error {
+	return func(am *AccountManager) error {
+		runner, err := hooks.NewRunner(ctx, lz)
+		if err != nil {
+			return fmt.Errorf("failed to create hook runner: %w", err)
+		}
+		am.hooks = runner
+		return nil
+	}
+}
+
+// WithParameterNames names the SSM parameters of the manager after the configuration
+func WithParameterNames(lz *config.LandingZoneConfig) func(*AccountManager) error {
+	return func(am *AccountManager) error {
+		am.names = lz.ParameterNames()
+		return nil
+	}
+}
+
+// WithAccountAttributes checks the attributes of created accounts against the
+// attributes declared by the configuration, and adds the attributes of the configured
+// accounts to the accounts listed from the organization
+func WithAccountAttributes(lz *config.LandingZoneConfig) func(*AccountManager) error {
+	return func(am *AccountManager) error {
+		if len(lz.AccountAttributes) > 0 {
+			am.attributes = lz
+		}
+		return nil
+	}
 }
 
 // CreateAccount creates a new AWS account with retry logic
@@ -127,6 +213,10 @@ func (am *AccountManager) CreateAccount(ctx *pulumi.Context, accountConfig *Acco
 		zap.String("name", accountConfig.Name),
 		zap.String("email", accountConfig.Email))
 
+	if err := readonly.Guard(ctx, "create account"); err != nil {
+		return nil, err
+	}
+
 	if err := am.validateAccountConfig(accountConfig); err != nil {
 		return nil, err
 	}
@@ -144,7 +234,7 @@ func (am *AccountManager) CreateAccount(ctx *pulumi.Context, accountConfig *Acco
 			ParentId: accountConfig.ParentOUID,
 			RoleName: pulumi.String(defaultAccessRoleName),
 			Tags:     pulumi.ToStringMap(accountConfig.Tags),
-		})
+		}, am.opts...)
 		return err
 	}
 
@@ -160,6 +250,10 @@ func (am *AccountManager) CreateAccount(ctx *pulumi.Context, accountConfig *Acco
 		return nil, err
 	}
 
+	if err := am.runHooks(ctx, account, accountConfig); err != nil {
+		return nil, err
+	}
+
 	am.logger.Info("account created successfully",
 		zap.String("name", accountConfig.Name))
 	am.metrics.IncrementCounter("accounts_created")
@@ -181,21 +275,34 @@ func (am *AccountManager) validateAccountConfig(config *AccountConfig) error {
 		return fmt.Errorf("parent OU ID is required")
 	}
 
+	if am.attributes != nil {
+		if err := am.attributes.CheckAttributes(config.Attributes); err != nil {
+			return fmt.Errorf("account %s: %w", config.Name, err)
+		}
+	}
+
 	return nil
 }
 
 // storeAccountInfo stores account information in SSM Parameter Store
-func (am *AccountManager) storeAccountInfo(ctx *pulumi.Context, account *awsOrg.Account, config *AccountConfig) error {
-	_, err := awsssm.NewParameter(ctx, fmt.Sprintf(ssmAccountPathFmt, config.Name), &awsssm.ParameterArgs{
+func (am *AccountManager) storeAccountInfo(ctx *pulumi.Context, account *awsOrg.Account, accountConfig *AccountConfig) error {
+	name := am.names.Name(config.ParameterKindAccounts, accountConfig.Name)
+	attributes := accountConfig.Attributes
+	if am.attributes != nil {
+		attributes = am.attributes.ResolveAttributes(attributes)
+	}
+	args := &awsssm.ParameterArgs{
 		Type: pulumi.String("SecureString"),
 		Value: pulumi.All(account.ID(), account.Arn).ApplyT(func(args []interface{}) (string, error) {
 			info := AccountInfo{
 				ID:     args[0].(string),
 				ARN:    args[1].(string),
-				Name:   config.Name,
-				Email:  config.Email,
+				Name:   accountConfig.Name,
+				Email:  accountConfig.Email,
 				Status: statusActive,
-				Tags:   config.Tags,
+				Tags:   accountConfig.Tags,
+
+				Attributes: attributes,
 			}
 			value, err := json.Marshal(info)
 			if err != nil {
@@ -203,32 +310,102 @@ func (am *AccountManager) storeAccountInfo(ctx *pulumi.Context, account *awsOrg.
 			}
 			return string(value), nil
 		}).(pulumi.StringOutput),
-		Description: pulumi.Sprintf("Information for Account: %s", config.Name),
-		Tags:        pulumi.ToStringMap(config.Tags),
-	})
+		Description: pulumi.Sprintf("Information for Account: %s", accountConfig.Name),
+		Tags:        pulumi.ToStringMap(accountConfig.Tags),
+	}
+	if err := am.shareArgs(ctx, "accounts", args, am.opts); err != nil {
+		return err
+	}
+
+	parameter, err := awsssm.NewParameter(ctx, name, args, am.opts...)
+	if err != nil {
+		return err
+	}
+	return am.shareParameter(ctx, "accounts", fmt.Sprintf("account-%s", accountConfig.Name), parameter, am.opts)
+}
 
-	return err
+// runHooks starts the post-provision hooks of an account once it exists. Every execution
+// is recorded in an SSM parameter, so a hook runs once per account.
+func (am *AccountManager) runHooks(ctx *pulumi.Context, account *awsOrg.Account, accountConfig *AccountConfig) error {
+	if am.hooks == nil || len(accountConfig.Hooks) == 0 {
+		return nil
+	}
+
+	// Executions are recorded in the region the runner reads them from
+	if am.hookOpts == nil {
+		provider, err := aws.NewProvider(ctx, "post-provision-hooks", &aws.ProviderArgs{
+			Region: pulumi.String(am.hooks.Region()),
+		}, am.opts...)
+		if err != nil {
+			return fmt.Errorf("failed to create hook provider: %w", err)
+		}
+		am.hookOpts = component.Options(am.opts, pulumi.Provider(provider))
+	}
+
+	for _, hook := range accountConfig.Hooks {
+		hook := hook
+		execution := account.ID().ApplyT(func(id pulumi.ID) (string, error) {
+			return am.hooks.Start(ctx.Context(), hook, hooks.Input{
+				AccountId:   string(id),
+				AccountName: accountConfig.Name,
+				Email:       accountConfig.Email,
+				OUName:      accountConfig.OUName,
+			}, ctx.DryRun())
+		}).(pulumi.StringOutput)
+
+		name := fmt.Sprintf("hook-%s-%s", accountConfig.Name, hook.Name)
+		args := &awsssm.ParameterArgs{
+			Name: account.ID().ApplyT(func(id pulumi.ID) string {
+				return am.hooks.ExecutionPath(string(id), hook.Name)
+			}).(pulumi.StringOutput),
+			Type:        pulumi.String("String"),
+			Value:       execution,
+			Description: pulumi.Sprintf("Execution of post-provision hook %s for account %s", hook.Name, accountConfig.Name),
+			Tags:        pulumi.ToStringMap(accountConfig.Tags),
+		}
+		if err := am.shareArgs(ctx, "hooks", args, am.hookOpts); err != nil {
+			return err
+		}
+
+		parameter, err := awsssm.NewParameter(ctx, name, args, am.hookOpts...)
+		if err != nil {
+			return fmt.Errorf("failed to record hook %s of account %s: %w", hook.Name, accountConfig.Name, err)
+		}
+		if err := am.shareParameter(ctx, "hooks", name, parameter, am.hookOpts); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // CreateDefaultAccounts creates the default accounts required for AWS Control Tower
 func CreateDefaultAccounts(ctx *pulumi.Context, securityOUID pulumi.StringInput, cfg *config.OrganizationConfig) error {
-	am, err := NewAccountManager(ctx.Context())
+	am, err := NewAccountManager(ctx.Context(), WithHooks(ctx.Context(), cfg.LandingZoneConfig),
+		WithParameterSharing(cfg.LandingZoneConfig), WithParameterNames(cfg.LandingZoneConfig),
+		WithAccountAttributes(cfg.LandingZoneConfig))
 	if err != nil {
 		return err
 	}
 
+	securityHooks := hooks.ForOU(cfg.LandingZoneConfig, securityOUName)
+	emails := DefaultAccountEmails(cfg.LandingZoneConfig)
 	defaultAccounts := []AccountConfig{
 		{
 			Name:       "AFT-Management",
-			Email:      fmt.Sprintf("aft-management@%s", cfg.LandingZoneConfig.AccountEmailDomain),
+			Email:      emails["AFT-Management"],
 			ParentOUID: securityOUID,
 			Tags:       cfg.LandingZoneConfig.Tags,
+			OUName:     securityOUName,
+			Hooks:      securityHooks,
 		},
 		{
 			Name:       "AFT-Networking",
-			Email:      fmt.Sprintf("aft-networking@%s", cfg.LandingZoneConfig.AccountEmailDomain),
+			Email:      emails["AFT-Networking"],
 			ParentOUID: securityOUID,
 			Tags:       cfg.LandingZoneConfig.Tags,
+			OUName:     securityOUName,
+			Hooks:      securityHooks,
 		},
 	}
 
@@ -241,6 +418,14 @@ func CreateDefaultAccounts(ctx *pulumi.Context, securityOUID pulumi.StringInput,
 	return nil
 }
 
+// DefaultAccountEmails returns the email addresses of the default accounts, by name
+func DefaultAccountEmails(lz *config.LandingZoneConfig) map[string]string {
+	return map[string]string{
+		"AFT-Management": fmt.Sprintf("aft-management@%s", lz.AccountEmailDomain),
+		"AFT-Networking": fmt.Sprintf("aft-networking@%s", lz.AccountEmailDomain),
+	}
+}
+
 // retryWithBackoff implements exponential backoff retry logic
 func retryWithBackoff(operation func() error, maxAttempts int, baseDelay time.Duration) error {
 	var lastErr error
@@ -248,6 +433,10 @@ func retryWithBackoff(operation func() error, maxAttempts int, baseDelay time.Du
 		if err := operation(); err == nil {
 			return nil
 		} else {
+			// Retrying cannot fix missing permissions, existing resources or quotas
+			if awsclient.Permanent(err) {
+				return err
+			}
 			lastErr = err
 			if attempt < maxAttempts {
 				delay := time.Duration(float64(baseDelay) * float64(attempt))
@@ -269,7 +458,7 @@ type BackupInfo struct {
 }
 
 // Backup creates a backup of account configurations
-func (am *AccountManager) Backup(ctx context.Context) error {
+func (am *AccountManager) Backup(ctx *pulumi.Context) error {
 	am.mutex.RLock()
 	defer am.mutex.RUnlock()
 
@@ -284,24 +473,34 @@ func (am *AccountManager) Backup(ctx context.Context) error {
 		return fmt.Errorf("failed to marshal backup data: %w", err)
 	}
 
-	pulumiCtx := getPulumiContextFromContext(ctx)
-	if pulumiCtx == nil {
-		return fmt.Errorf("pulumi context not found in context")
+	if err := readonly.Guard(ctx, "backup accounts"); err != nil {
+		return err
 	}
 
-	_, err = awsssm.NewParameter(pulumiCtx,
-		fmt.Sprintf("backup-%s", backupInfo.ID),
-		&awsssm.ParameterArgs{
-			Name:        pulumi.String(fmt.Sprintf("/organization/backups/%s", backupInfo.ID)),
-			Type:        pulumi.String("SecureString"),
-			Value:       pulumi.String(string(backupData)),
-			Description: pulumi.String(fmt.Sprintf("Account configuration backup created at %s", backupInfo.Timestamp)),
-		},
-		pulumi.DeleteBeforeReplace(true),
-	)
+	// Backups of large organizations exceed a parameter and are split across chunks
+	name := fmt.Sprintf("backup-%s", backupInfo.ID)
+	args := &awsssm.ParameterArgs{
+		Type:        pulumi.String("SecureString"),
+		Description: pulumi.String(fmt.Sprintf("Account configuration backup created at %s", backupInfo.Timestamp)),
+	}
+	if err := am.shareArgs(ctx, "backups", args, nil); err != nil {
+		return err
+	}
+
+	parameters, err := paramstore.NewParameter(ctx, name, am.names.Name(config.ParameterKindBackups, backupInfo.ID),
+		string(backupData), args, pulumi.DeleteBeforeReplace(true))
 	if err != nil {
 		return fmt.Errorf("failed to store backup in SSM: %w", err)
 	}
+	for i, parameter := range parameters {
+		shareName := name
+		if i > 0 {
+			shareName = paramstore.ChunkName(name, i-1)
+		}
+		if err := am.shareParameter(ctx, "backups", shareName, parameter, nil); err != nil {
+			return err
+		}
+	}
 
 	am.logger.Info("backup created successfully",
 		zap.String("backupID", backupInfo.ID),
@@ -313,26 +512,17 @@ func (am *AccountManager) Backup(ctx context.Context) error {
 }
 
 // Restore restores account configurations from a backup
-func (am *AccountManager) Restore(ctx context.Context, backupID string) error {
+func (am *AccountManager) Restore(ctx *pulumi.Context, backupID string) error {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
 
-	pulumiCtx := getPulumiContextFromContext(ctx)
-	if pulumiCtx == nil {
-		return fmt.Errorf("pulumi context not found in context")
-	}
-
-	// Changed this line to use pulumiCtx instead of ctx
-	paramValue, err := awsssm.LookupParameter(pulumiCtx, &awsssm.LookupParameterArgs{
-		Name:           fmt.Sprintf("/organization/backups/%s", backupID),
-		WithDecryption: pulumi.BoolRef(true),
-	})
+	backupData, err := paramstore.Lookup(ctx, am.names.Name(config.ParameterKindBackups, backupID))
 	if err != nil {
 		return fmt.Errorf("failed to retrieve backup %s: %w", backupID, err)
 	}
 
 	var backupInfo BackupInfo
-	if err := json.Unmarshal([]byte(paramValue.Value), &backupInfo); err != nil {
+	if err := json.Unmarshal([]byte(backupData), &backupInfo); err != nil {
 		return fmt.Errorf("failed to unmarshal backup data: %w", err)
 	}
 
@@ -353,19 +543,3 @@ func (am *AccountManager) Restore(ctx context.Context, backupID string) error {
 	am.metrics.IncrementCounter("backups_restored")
 	return nil
 }
-
-// Helper function to get Pulumi context from context.Context
-func getPulumiContextFromContext(ctx context.Context) *pulumi.Context {
-	if ctx == nil {
-		return nil
-	}
-	if pulumiCtx, ok := ctx.Value("pulumi.Context").(*pulumi.Context); ok {
-		return pulumiCtx
-	}
-	return nil
-}
-
-// Add this to your AccountManager struct
-func (am *AccountManager) WithPulumiContext(ctx *pulumi.Context) context.Context {
-	return context.WithValue(context.Background(), "pulumi.Context", ctx)
-}
@@ -0,0 +1,119 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+)
+
+// attachAttributes sets the attributes of the listed accounts: the attributes recorded
+// in the registry, overridden by the attributes of the configured account of the same
+// email address
+func (am *AccountManager) attachAttributes(ctx context.Context, accounts []*AccountInfo) error {
+	registry, err := am.registryAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	recorded := make(map[string]map[string]string, len(registry))
+	for _, info := range registry {
+		recorded[info.ID] = info.Attributes
+	}
+	configured := am.attributes.ConfiguredAttributes()
+
+	for _, info := range accounts {
+		attributes := make(map[string]string)
+		for name, value := range recorded[info.ID] {
+			attributes[name] = value
+		}
+		for name, value := range configured[strings.ToLower(info.Email)] {
+			attributes[name] = value
+		}
+		if len(attributes) > 0 {
+			info.Attributes = attributes
+		}
+	}
+	return nil
+}
+
+// matchesAttributes reports whether the attributes of an account hold every attribute
+// of the filter. An empty value matches any value.
+func (f AccountFilter) matchesAttributes(attributes map[string]string) bool {
+	for name, value := range f.Attributes {
+		actual, ok := attributes[name]
+		if !ok || (value != "" && !strings.EqualFold(actual, value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatAttributes renders attributes as sorted name=value pairs
+func formatAttributes(attributes map[string]string, separator string) string {
+	pairs := make([]string, 0, len(attributes))
+	for name, value := range attributes {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, separator)
+}
+
+// hasAttributes reports whether one of the accounts carries attributes, so listings
+// only gain an attributes column when attributes are in use
+func hasAttributes(accounts []*AccountInfo) bool {
+	for _, info := range accounts {
+		if len(info.Attributes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesHaveAttributes reports whether one of the matches carries attributes
+func matchesHaveAttributes(matches []*AccountMatch) bool {
+	for _, match := range matches {
+		if len(match.Attributes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectAttributes records the custom attributes of every account of the organization
+// in the report, and reports the active accounts missing a required attribute. It
+// requires WithOrganizationCache and WithAccountAttributes.
+func (am *AccountManager) CollectAttributes(ctx context.Context, r *report.Report) error {
+	if am.attributes == nil {
+		return nil
+	}
+
+	listed, err := am.SearchAccounts(ctx, AccountFilter{})
+	if err != nil {
+		return err
+	}
+	for _, info := range listed {
+		if len(info.Attributes) > 0 {
+			r.SetAttributes(info.ID, info.Attributes)
+		}
+		if info.Status != string(orgtypes.AccountStatusActive) {
+			continue
+		}
+		for _, name := range am.attributes.MissingAttributes(info.Attributes) {
+			r.Add(report.Finding{
+				AccountID: info.ID,
+				Check:     "account-attributes",
+				Severity:  report.SeverityLow,
+				Resource:  name,
+				Message:   fmt.Sprintf("required attribute %s is not set on account %s", name, info.Name),
+			})
+		}
+	}
+	return nil
+}
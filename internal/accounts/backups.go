@@ -0,0 +1,145 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/paramstore"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	sdkaws "github.com/aws/aws-sdk-go-v2/aws"
+	sdkssm "github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"go.uber.org/zap"
+)
+
+// Largest number of parameters deleted by a DeleteParameters call
+const deleteParametersBatch = 10
+
+// StoredBackup is an account backup stored in SSM
+type StoredBackup struct {
+	ID         string    `json:"id"`
+	Created    time.Time `json:"created"`
+	Parameters []string  `json:"parameters"`
+}
+
+// Backups lists the account backups stored in SSM, oldest first, with the chunk
+// parameters of each
+func (am *AccountManager) Backups(ctx context.Context) ([]*StoredBackup, error) {
+	if am.ssmClient == nil {
+		return nil, fmt.Errorf("listing backups requires the organization cache, see WithOrganizationCache")
+	}
+
+	byName := make(map[string]*StoredBackup)
+	paginator := sdkssm.NewDescribeParametersPaginator(am.ssmClient, &sdkssm.DescribeParametersInput{
+		ParameterFilters: []ssmtypes.ParameterStringFilter{{
+			Key:    sdkaws.String("Path"),
+			Option: sdkaws.String("OneLevel"),
+			Values: []string{am.names.Path(config.ParameterKindBackups)},
+		}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list account backups: %w", err)
+		}
+		for _, param := range page.Parameters {
+			name := sdkaws.ToString(param.Name)
+			base := paramstore.BaseName(name)
+			backup, ok := byName[base]
+			if !ok {
+				backup = &StoredBackup{ID: path.Base(base)}
+				byName[base] = backup
+			}
+			backup.Parameters = append(backup.Parameters, name)
+			if name == base && param.LastModifiedDate != nil {
+				backup.Created = *param.LastModifiedDate
+			}
+		}
+	}
+
+	backups := make([]*StoredBackup, 0, len(byName))
+	for _, backup := range byName {
+		// Backup IDs carry their creation time, which survives the parameter being
+		// overwritten
+		stamp := strings.TrimPrefix(backup.ID, "backup-")
+		if created, err := time.ParseInLocation("20060102-150405", stamp, time.Local); err == nil {
+			backup.Created = created
+		}
+		sort.Strings(backup.Parameters)
+		backups = append(backups, backup)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Created.Before(backups[j].Created)
+	})
+	return backups, nil
+}
+
+// PruneBackups deletes the account backups older than retentionDays with their chunk
+// parameters and returns them, oldest first. Backups with a parameter in managed are
+// kept, as the stack deletes them itself. With dryRun, the expired backups are only
+// listed.
+func (am *AccountManager) PruneBackups(ctx context.Context, retentionDays int, managed map[string]bool, dryRun bool) ([]*StoredBackup, error) {
+	if !dryRun {
+		if err := readonly.Check("prune account backups"); err != nil {
+			return nil, err
+		}
+	}
+
+	backups, err := am.Backups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	var expired []*StoredBackup
+	for _, backup := range backups {
+		if backup.Created.IsZero() || !backup.Created.Before(cutoff) {
+			continue
+		}
+		if stackManaged(backup, managed) {
+			am.logger.Info("account backup managed by the stack, not pruned",
+				zap.String("backupID", backup.ID))
+			continue
+		}
+		expired = append(expired, backup)
+	}
+	if dryRun {
+		return expired, nil
+	}
+
+	for i, backup := range expired {
+		for start := 0; start < len(backup.Parameters); start += deleteParametersBatch {
+			end := min(start+deleteParametersBatch, len(backup.Parameters))
+			if _, err := am.ssmClient.DeleteParameters(ctx, &sdkssm.DeleteParametersInput{
+				Names: backup.Parameters[start:end],
+			}); err != nil {
+				return expired[:i], fmt.Errorf("failed to delete account backup %s: %w", backup.ID, err)
+			}
+		}
+		am.metrics.IncrementCounter("account_backups_pruned")
+	}
+
+	am.logger.Info("account backups pruned",
+		zap.Int("pruned", len(expired)),
+		zap.Int("retentionDays", retentionDays))
+	return expired, nil
+}
+
+// stackManaged reports whether a parameter of the backup is managed by the stack
+func stackManaged(backup *StoredBackup, managed map[string]bool) bool {
+	for _, name := range backup.Parameters {
+		if managed[name] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package accounts
+
+import (
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// AccountsComponentType is the type token of the accounts component
+var AccountsComponentType = component.Type("accounts", "Accounts")
+
+// AccountsComponentArgs defines the inputs of the accounts component
+type AccountsComponentArgs struct {
+	// Accounts lists the accounts to create
+	Accounts []AccountConfig
+
+	// LandingZone enables the post-provision hooks of the accounts and the sharing of
+	// their parameters when set
+	LandingZone *config.LandingZoneConfig
+}
+
+// AccountsComponent groups a set of member accounts and their SSM records under a single
+// component resource
+type AccountsComponent struct {
+	pulumi.ResourceState
+
+	AccountIds  pulumi.StringMapOutput `pulumi:"accountIds"`
+	AccountArns pulumi.StringMapOutput `pulumi:"accountArns"`
+}
+
+// NewAccountsComponent creates the accounts as a component resource. Outputs are keyed
+// by account name.
+func NewAccountsComponent(ctx *pulumi.Context, name string, args *AccountsComponentArgs, opts ...pulumi.ResourceOption) (*AccountsComponent, error) {
+	if args == nil {
+		return nil, fmt.Errorf("accounts component arguments cannot be nil")
+	}
+
+	c := &AccountsComponent{}
+	if err := ctx.RegisterComponentResource(AccountsComponentType, name, c, opts...); err != nil {
+		return nil, fmt.Errorf("failed to register accounts component: %w", err)
+	}
+
+	var managerOpts []func(*AccountManager) error
+	if args.LandingZone != nil {
+		managerOpts = append(managerOpts, WithHooks(ctx.Context(), args.LandingZone),
+			WithParameterSharing(args.LandingZone), WithParameterNames(args.LandingZone),
+			WithAccountAttributes(args.LandingZone))
+	}
+
+	am, err := NewAccountManager(ctx.Context(), managerOpts...)
+	if err != nil {
+		return nil, err
+	}
+	am.opts = component.ChildOptions(c)
+
+	ids := pulumi.StringMap{}
+	arns := pulumi.StringMap{}
+	for i := range args.Accounts {
+		accountCfg := args.Accounts[i]
+		account, err := am.CreateAccount(ctx, &accountCfg)
+		if err != nil {
+			return nil, err
+		}
+		ids[accountCfg.Name] = account.ID()
+		arns[accountCfg.Name] = account.Arn
+	}
+
+	c.AccountIds = ids.ToStringMapOutput()
+	c.AccountArns = arns.ToStringMapOutput()
+
+	if err := ctx.RegisterResourceOutputs(c, pulumi.Map{
+		"accountIds":  c.AccountIds,
+		"accountArns": c.AccountArns,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to register accounts outputs: %w", err)
+	}
+
+	return c, nil
+}
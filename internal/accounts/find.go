@@ -0,0 +1,245 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package accounts
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	sdkaws "github.com/aws/aws-sdk-go-v2/aws"
+	ct "github.com/aws/aws-sdk-go-v2/service/controltower"
+	cttypes "github.com/aws/aws-sdk-go-v2/service/controltower/types"
+	sdkssm "github.com/aws/aws-sdk-go-v2/service/ssm"
+	"go.uber.org/zap"
+)
+
+// Enrollment status of an account in Control Tower
+const (
+	EnrollmentEnrolled    = "ENROLLED"
+	EnrollmentFailed      = "FAILED"
+	EnrollmentUnderChange = "UNDER_CHANGE"
+	EnrollmentNotEnrolled = "NOT_ENROLLED"
+)
+
+// Sources an account was found in
+const (
+	SourceRegistry     = "registry"
+	SourceOrganization = "organization"
+)
+
+// AccountMatch is an account found by FindAccounts
+type AccountMatch struct {
+	AccountInfo
+	Enrollment string   `json:"enrollment"`
+	Sources    []string `json:"sources"`
+}
+
+// FindAccounts searches the SSM account registry and the live organization for the
+// accounts holding every given tag and, when set, the given email address. Registry
+// accounts match on the tags they were created with, live accounts on their current
+// tags. Matches found in both are merged, with the OU and status of the organization.
+func (am *AccountManager) FindAccounts(ctx context.Context, tags map[string]string, email string) ([]*AccountMatch, error) {
+	if am.ssmClient == nil || am.ctClient == nil {
+		return nil, fmt.Errorf("searching accounts requires the organization cache, see WithOrganizationCache")
+	}
+
+	start := time.Now()
+	defer func() {
+		am.metrics.RecordDuration("account_search", time.Since(start))
+	}()
+
+	filter := AccountFilter{Tags: tags}
+	matchesEmail := func(info *AccountInfo) bool {
+		return email == "" || strings.EqualFold(info.Email, email)
+	}
+
+	registry, err := am.registryAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live, err := am.SearchAccounts(ctx, AccountFilter{})
+	if err != nil {
+		return nil, err
+	}
+	enrollment, err := am.enrollment(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(map[string]*AccountMatch)
+	for _, info := range registry {
+		if matchesEmail(info) && filter.matchesTags(info.Tags) {
+			matches[info.ID] = &AccountMatch{AccountInfo: *info, Sources: []string{SourceRegistry}}
+		}
+	}
+
+	for _, info := range live {
+		match, ok := matches[info.ID]
+		if !ok {
+			if !matchesEmail(info) {
+				continue
+			}
+			if len(tags) > 0 {
+				if info.Tags, err = am.accountTags(ctx, info.ID); err != nil {
+					return nil, err
+				}
+				if !filter.matchesTags(info.Tags) {
+					continue
+				}
+			}
+			match = &AccountMatch{AccountInfo: *info}
+			matches[info.ID] = match
+		} else {
+			match.ARN, match.Name, match.Email, match.Status = info.ARN, info.Name, info.Email, info.Status
+			match.OU, match.OUID = info.OU, info.OUID
+			if info.Attributes != nil {
+				match.Attributes = info.Attributes
+			}
+		}
+		match.Sources = append(match.Sources, SourceOrganization)
+	}
+
+	found := make([]*AccountMatch, 0, len(matches))
+	for _, match := range matches {
+		match.Enrollment = EnrollmentNotEnrolled
+		if status, ok := enrollment[match.ARN]; ok {
+			match.Enrollment = status
+		}
+		found = append(found, match)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Name != found[j].Name {
+			return found[i].Name < found[j].Name
+		}
+		return found[i].ID < found[j].ID
+	})
+
+	am.metrics.IncrementCounter("account_searches")
+	return found, nil
+}
+
+// registryAccounts returns the accounts recorded in the SSM registry by CreateAccount
+func (am *AccountManager) registryAccounts(ctx context.Context) ([]*AccountInfo, error) {
+	var registry []*AccountInfo
+
+	paginator := sdkssm.NewGetParametersByPathPaginator(am.ssmClient, &sdkssm.GetParametersByPathInput{
+		Path:           sdkaws.String(am.names.Path(config.ParameterKindAccounts)),
+		WithDecryption: sdkaws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read account registry: %w", err)
+		}
+		for _, param := range page.Parameters {
+			var info AccountInfo
+			if err := json.Unmarshal([]byte(sdkaws.ToString(param.Value)), &info); err != nil || info.ID == "" {
+				am.logger.Warn("skipping unreadable account registry entry",
+					zap.String("parameter", sdkaws.ToString(param.Name)))
+				continue
+			}
+			registry = append(registry, &info)
+		}
+	}
+	return registry, nil
+}
+
+// enrollment returns the Control Tower enrollment status of the enrolled accounts,
+// keyed by account ARN. Accounts are enrolled through the baseline of their OU, which
+// is listed with a child baseline per account.
+func (am *AccountManager) enrollment(ctx context.Context) (map[string]string, error) {
+	statuses := make(map[string]string)
+
+	paginator := ct.NewListEnabledBaselinesPaginator(am.ctClient, &ct.ListEnabledBaselinesInput{
+		IncludeChildren: true,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list enabled baselines: %w", err)
+		}
+		for _, baseline := range page.EnabledBaselines {
+			target := sdkaws.ToString(baseline.TargetIdentifier)
+			if !strings.Contains(target, ":account/") || baseline.StatusSummary == nil {
+				continue
+			}
+			switch baseline.StatusSummary.Status {
+			case cttypes.EnablementStatusSucceeded:
+				statuses[target] = EnrollmentEnrolled
+			case cttypes.EnablementStatusFailed:
+				statuses[target] = EnrollmentFailed
+			default:
+				statuses[target] = EnrollmentUnderChange
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// WriteMatches writes the accounts found by FindAccounts as a table (text), JSON or CSV
+func WriteMatches(w io.Writer, format string, matches []*AccountMatch) error {
+	switch format {
+	case report.FormatJSON:
+		if matches == nil {
+			matches = []*AccountMatch{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(matches); err != nil {
+			return fmt.Errorf("failed to encode accounts: %w", err)
+		}
+		return nil
+	case FormatCSV:
+		withAttributes := matchesHaveAttributes(matches)
+		cw := csv.NewWriter(w)
+		header := []string{"id", "name", "email", "status", "ou", "ou_id", "enrollment", "sources"}
+		if withAttributes {
+			header = append(header, "attributes")
+		}
+		if err := cw.Write(header); err != nil {
+			return fmt.Errorf("failed to write accounts: %w", err)
+		}
+		for _, match := range matches {
+			record := []string{match.ID, match.Name, match.Email, match.Status, match.OU, match.OUID,
+				match.Enrollment, strings.Join(match.Sources, ";")}
+			if withAttributes {
+				record = append(record, formatAttributes(match.Attributes, ";"))
+			}
+			if err := cw.Write(record); err != nil {
+				return fmt.Errorf("failed to write accounts: %w", err)
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case report.FormatText:
+		withAttributes := matchesHaveAttributes(matches)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		header := "ID\tNAME\tEMAIL\tOU\tSTATUS\tENROLLMENT\tSOURCES"
+		if withAttributes {
+			header += "\tATTRIBUTES"
+		}
+		fmt.Fprintln(tw, header)
+		for _, match := range matches {
+			line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s", match.ID, match.Name, match.Email,
+				report.OrDash(match.OU), match.Status, match.Enrollment, strings.Join(match.Sources, ","))
+			if withAttributes {
+				line += "\t" + report.OrDash(formatAttributes(match.Attributes, ","))
+			}
+			fmt.Fprintln(tw, line)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package accounts
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	sdkaws "github.com/aws/aws-sdk-go-v2/aws"
+	ct "github.com/aws/aws-sdk-go-v2/service/controltower"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	sdkssm "github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+const (
+	// Name the root is listed under in the OU column
+	rootName = "Root"
+
+	// FormatCSV writes account listings as comma separated values
+	FormatCSV = "csv"
+)
+
+// AccountFilter selects the accounts returned by SearchAccounts. Empty fields match
+// every account.
+type AccountFilter struct {
+	// OU is the name or ID of an OU. Accounts of nested OUs match as well.
+	OU string
+
+	// Status is an account status such as ACTIVE or SUSPENDED
+	Status string
+
+	// Tags must all be set on the account. An empty value matches any value.
+	Tags map[string]string
+
+	// EmailDomain is the domain of the account email address
+	EmailDomain string
+
+	// Attributes must all be set on the account, see WithAccountAttributes. An empty
+	// value matches any value.
+	Attributes map[string]string
+}
+
+// WithOrganizationCache lets the manager list and search the accounts of the live
+// organization, walking the OU tree of the cache. Only the Organizations client
+// assumes OrganizationRoleArn, SSM and Control Tower keep the caller credentials.
+func WithOrganizationCache(cache *orgcache.Cache) func(*AccountManager) error {
+	return func(am *AccountManager) error {
+		base := cache.AWSConfig()
+		am.orgClient = organizations.NewFromConfig(cache.OrganizationConfig())
+		am.ssmClient = sdkssm.NewFromConfig(base)
+		am.ctClient = ct.NewFromConfig(base)
+		am.orgCache = cache
+		return nil
+	}
+}
+
+// ListAccounts returns every account of the organization
+func (am *AccountManager) ListAccounts(ctx context.Context) ([]*AccountInfo, error) {
+	return am.SearchAccounts(ctx, AccountFilter{})
+}
+
+// GetAccountStatus returns the status of an account of the organization, such as
+// ACTIVE or SUSPENDED
+func (am *AccountManager) GetAccountStatus(ctx context.Context, accountID string) (string, error) {
+	if am.orgClient == nil {
+		return "", fmt.Errorf("reading accounts requires the organization cache, see WithOrganizationCache")
+	}
+	if err := am.limiter.Wait(ctx); err != nil {
+		return "", fmt.Errorf("rate limit exceeded: %w", err)
+	}
+
+	out, err := am.orgClient.DescribeAccount(ctx, &organizations.DescribeAccountInput{
+		AccountId: sdkaws.String(accountID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe account %s: %w", accountID, err)
+	}
+	return string(out.Account.Status), nil
+}
+
+// SearchAccounts returns the accounts of the organization matching the filter, sorted
+// by name. Accounts are listed per OU, so each carries the OU it is placed in. Tags are
+// only read when the filter selects on them. With WithAccountAttributes, accounts carry
+// their custom attributes.
+func (am *AccountManager) SearchAccounts(ctx context.Context, filter AccountFilter) ([]*AccountInfo, error) {
+	if am.orgClient == nil || am.orgCache == nil {
+		return nil, fmt.Errorf("listing accounts requires the organization cache, see WithOrganizationCache")
+	}
+	if len(filter.Attributes) > 0 && am.attributes == nil {
+		return nil, fmt.Errorf("no account attributes are declared in the configuration")
+	}
+
+	start := time.Now()
+	defer func() {
+		am.metrics.RecordDuration("account_listing", time.Since(start))
+	}()
+
+	parents, err := am.listParents(ctx, filter.OU)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*AccountInfo
+	for _, parent := range parents {
+		if err := am.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit exceeded: %w", err)
+		}
+
+		paginator := organizations.NewListAccountsForParentPaginator(am.orgClient,
+			&organizations.ListAccountsForParentInput{ParentId: sdkaws.String(parent.ID)})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list accounts of %s: %w", parent.Name, err)
+			}
+			for _, account := range page.Accounts {
+				info := &AccountInfo{
+					ID:     sdkaws.ToString(account.Id),
+					ARN:    sdkaws.ToString(account.Arn),
+					Name:   sdkaws.ToString(account.Name),
+					Email:  sdkaws.ToString(account.Email),
+					Status: string(account.Status),
+					OU:     parent.Name,
+					OUID:   parent.ID,
+				}
+				if filter.matches(info) {
+					matches = append(matches, info)
+				}
+			}
+		}
+	}
+
+	if len(filter.Tags) > 0 {
+		tagged := matches[:0]
+		for _, info := range matches {
+			if info.Tags, err = am.accountTags(ctx, info.ID); err != nil {
+				return nil, err
+			}
+			if filter.matchesTags(info.Tags) {
+				tagged = append(tagged, info)
+			}
+		}
+		matches = tagged
+	}
+
+	if am.attributes != nil {
+		if err := am.attachAttributes(ctx, matches); err != nil {
+			return nil, err
+		}
+		selected := matches[:0]
+		for _, info := range matches {
+			if filter.matchesAttributes(info.Attributes) {
+				selected = append(selected, info)
+			}
+		}
+		matches = selected
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Name != matches[j].Name {
+			return matches[i].Name < matches[j].Name
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	am.metrics.IncrementCounter("accounts_listed")
+	return matches, nil
+}
+
+// listParents returns the root and every OU, or the OUs matching a name or ID and the
+// OUs nested in them
+func (am *AccountManager) listParents(ctx context.Context, ou string) ([]orgcache.OU, error) {
+	rootID, err := am.orgCache.RootID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ous, err := am.orgCache.OUs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// OUs are listed parents first, so the scope of a parent is known before its children
+	all := append([]orgcache.OU{{ID: rootID, Name: rootName}}, ous...)
+	if ou == "" {
+		return all, nil
+	}
+
+	inScope := make(map[string]bool)
+	var parents []orgcache.OU
+	for _, node := range all {
+		if node.ID == ou || strings.EqualFold(node.Name, ou) || inScope[node.ParentID] {
+			inScope[node.ID] = true
+			parents = append(parents, node)
+		}
+	}
+	if len(parents) == 0 {
+		return nil, fmt.Errorf("OU %s not found", ou)
+	}
+	return parents, nil
+}
+
+// accountTags returns the tags of an account
+func (am *AccountManager) accountTags(ctx context.Context, accountID string) (map[string]string, error) {
+	tags := make(map[string]string)
+
+	paginator := organizations.NewListTagsForResourcePaginator(am.orgClient,
+		&organizations.ListTagsForResourceInput{ResourceId: sdkaws.String(accountID)})
+	for paginator.HasMorePages() {
+		if err := am.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit exceeded: %w", err)
+		}
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of account %s: %w", accountID, err)
+		}
+		for _, tag := range page.Tags {
+			tags[sdkaws.ToString(tag.Key)] = sdkaws.ToString(tag.Value)
+		}
+	}
+	return tags, nil
+}
+
+// matches reports whether an account matches the status and email domain of the filter
+func (f AccountFilter) matches(info *AccountInfo) bool {
+	if f.Status != "" && !strings.EqualFold(info.Status, f.Status) {
+		return false
+	}
+	if f.EmailDomain != "" {
+		domain := strings.TrimPrefix(f.EmailDomain, "@")
+		if !strings.HasSuffix(strings.ToLower(info.Email), "@"+strings.ToLower(domain)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesTags reports whether the tags of an account hold every tag of the filter
+func (f AccountFilter) matchesTags(tags map[string]string) bool {
+	for key, value := range f.Tags {
+		actual, ok := tags[key]
+		if !ok || (value != "" && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteAccounts writes an account listing as a table (text), JSON or CSV
+func WriteAccounts(w io.Writer, format string, accounts []*AccountInfo) error {
+	switch format {
+	case report.FormatJSON:
+		if accounts == nil {
+			accounts = []*AccountInfo{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(accounts); err != nil {
+			return fmt.Errorf("failed to encode accounts: %w", err)
+		}
+		return nil
+	case FormatCSV:
+		withAttributes := hasAttributes(accounts)
+		cw := csv.NewWriter(w)
+		header := []string{"id", "name", "email", "status", "ou", "ou_id", "arn"}
+		if withAttributes {
+			header = append(header, "attributes")
+		}
+		if err := cw.Write(header); err != nil {
+			return fmt.Errorf("failed to write accounts: %w", err)
+		}
+		for _, info := range accounts {
+			record := []string{info.ID, info.Name, info.Email, info.Status, info.OU, info.OUID, info.ARN}
+			if withAttributes {
+				record = append(record, formatAttributes(info.Attributes, ";"))
+			}
+			if err := cw.Write(record); err != nil {
+				return fmt.Errorf("failed to write accounts: %w", err)
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case report.FormatText:
+		withAttributes := hasAttributes(accounts)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		header := "ID\tNAME\tEMAIL\tSTATUS\tOU"
+		if withAttributes {
+			header += "\tATTRIBUTES"
+		}
+		fmt.Fprintln(tw, header)
+		for _, info := range accounts {
+			line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s", info.ID, info.Name, info.Email, info.Status, info.OU)
+			if withAttributes {
+				line += "\t" + report.OrDash(formatAttributes(info.Attributes, ","))
+			}
+			fmt.Fprintln(tw, line)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package accounts
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/keypolicy"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kms"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/organizations"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ram"
+	awsssm "github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ssm"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// Parameter tier required to share parameters through RAM
+	parameterTierAdvanced = "Advanced"
+
+	// Parameters encrypted with the key of the share
+	parameterTypeSecure = "SecureString"
+
+	// Service decrypting shared SecureString parameters
+	ssmService = "ssm"
+)
+
+// parameterSharing holds the accounts the /organization parameters are shared with
+type parameterSharing struct {
+	managementId string
+	accountIds   []string
+	adminRoles   []string
+	orgWide      bool
+	tags         map[string]string
+	names        config.ResourceNames
+
+	// Resource shares and keys are regional, so they are created once per set of
+	// resource options the parameters are created with
+	mutex  sync.Mutex
+	scopes map[string]*parameterShare
+}
+
+// parameterShare holds the resource share and the KMS key of the parameters of a scope
+type parameterShare struct {
+	share *ram.ResourceShare
+	key   *kms.Key
+}
+
+// WithParameterSharing shares the SSM parameters created by the manager with
+// the tooling accounts of the configuration
+func WithParameterSharing(lz *config.LandingZoneConfig) func(*AccountManager) error {
+	return func(am *AccountManager) error {
+		if lz.ParameterSharing == nil || len(lz.ParameterSharing.AccountIds) == 0 {
+			return nil
+		}
+		am.sharing = &parameterSharing{
+			managementId: lz.ManagementAccountId,
+			accountIds:   lz.ParameterSharing.AccountIds,
+			adminRoles:   lz.KeyAdminRoles(),
+			orgWide:      lz.KeysOrganizationWide(),
+			tags:         lz.Tags,
+			names:        lz.ResourceNames(),
+			scopes:       make(map[string]*parameterShare),
+		}
+		return nil
+	}
+}
+
+// shareArgs prepares the arguments of a parameter for sharing: shared parameters use
+// the advanced tier, and SecureString values the key the accounts may decrypt with
+func (am *AccountManager) shareArgs(ctx *pulumi.Context, scope string, args *awsssm.ParameterArgs, opts []pulumi.ResourceOption) error {
+	if am.sharing == nil {
+		return nil
+	}
+
+	share, err := am.parameterShare(ctx, scope, opts)
+	if err != nil {
+		return err
+	}
+
+	args.Tier = pulumi.String(parameterTierAdvanced)
+	if parameterType, ok := args.Type.(pulumi.String); ok && parameterType == parameterTypeSecure {
+		args.KeyId = share.key.Arn
+	}
+	return nil
+}
+
+// shareParameter adds a parameter to the resource share of its scope
+func (am *AccountManager) shareParameter(ctx *pulumi.Context, scope, name string, parameter *awsssm.Parameter, opts []pulumi.ResourceOption) error {
+	if am.sharing == nil {
+		return nil
+	}
+
+	share, err := am.parameterShare(ctx, scope, opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := ram.NewResourceAssociation(ctx, fmt.Sprintf("share-%s", name), &ram.ResourceAssociationArgs{
+		ResourceArn:      parameter.Arn,
+		ResourceShareArn: share.share.Arn,
+	}, opts...); err != nil {
+		return fmt.Errorf("failed to share parameter %s: %w", name, err)
+	}
+
+	am.metrics.IncrementCounter("parameters_shared")
+	return nil
+}
+
+// parameterShare returns the resource share and key of a scope, creating them and
+// sharing them with the accounts on first use
+func (am *AccountManager) parameterShare(ctx *pulumi.Context, scope string, opts []pulumi.ResourceOption) (*parameterShare, error) {
+	am.sharing.mutex.Lock()
+	defer am.sharing.mutex.Unlock()
+
+	if share, ok := am.sharing.scopes[scope]; ok {
+		return share, nil
+	}
+
+	policy, err := am.sharing.keyPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := kms.NewKey(ctx, fmt.Sprintf("organization-parameters-key-%s", scope), &kms.KeyArgs{
+		Description:       pulumi.String("Encryption of the shared organization parameters"),
+		EnableKeyRotation: pulumi.Bool(true),
+		Policy:            pulumi.String(policy),
+		Tags:              pulumi.ToStringMap(am.sharing.tags),
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization parameters key: %w", err)
+	}
+
+	if alias := am.sharing.names.KeyAlias(config.KeyParameterSharing.With(scope), ""); alias != "" {
+		if _, err := kms.NewAlias(ctx, fmt.Sprintf("organization-parameters-key-alias-%s", scope), &kms.AliasArgs{
+			Name:        pulumi.String(alias),
+			TargetKeyId: key.KeyId,
+		}, opts...); err != nil {
+			return nil, fmt.Errorf("failed to create organization parameters key alias: %w", err)
+		}
+	}
+
+	share, err := ram.NewResourceShare(ctx, fmt.Sprintf("organization-parameters-%s", scope), &ram.ResourceShareArgs{
+		Name:                    pulumi.Sprintf("organization-parameters-%s", scope),
+		AllowExternalPrincipals: pulumi.Bool(false),
+		Tags:                    pulumi.ToStringMap(am.sharing.tags),
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization parameters share: %w", err)
+	}
+
+	for _, accountId := range am.sharing.accountIds {
+		if _, err := ram.NewPrincipalAssociation(ctx, fmt.Sprintf("organization-parameters-%s-%s", scope, accountId),
+			&ram.PrincipalAssociationArgs{
+				Principal:        pulumi.String(accountId),
+				ResourceShareArn: share.Arn,
+			}, opts...); err != nil {
+			return nil, fmt.Errorf("failed to share organization parameters with %s: %w", accountId, err)
+		}
+	}
+
+	am.sharing.scopes[scope] = &parameterShare{share: share, key: key}
+	return am.sharing.scopes[scope], nil
+}
+
+// keyPolicy returns the policy of the parameters key, administered by the management
+// account and usable by the accounts, or the whole organization, to decrypt parameters
+// read through SSM
+func (s *parameterSharing) keyPolicy(ctx *pulumi.Context) (string, error) {
+	builder := keypolicy.New(s.managementId).AdminRoles(s.adminRoles...)
+	if s.orgWide {
+		org, err := organizations.LookupOrganization(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up organization: %w", err)
+		}
+		builder.GrantOrganization("AllowSharedParameterDecryption", org.Id, ssmService, "kms:Decrypt")
+	} else {
+		builder.GrantAccounts("AllowSharedParameterDecryption", s.accountIds, ssmService, "kms:Decrypt")
+	}
+
+	policy, err := builder.Build()
+	if err != nil {
+		return "", fmt.Errorf("invalid organization parameters key policy: %w", err)
+	}
+	return policy, nil
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package adopt provides the adoption of resources left behind by a partially failed run.
+// Version: 1.0.0
+package adopt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+// Adopter finds resources that already exist in the account under the name a resource
+// of the program is created with. A resource whose name and tags match is imported
+// into the stack instead of failing the run with an already exists error; resources
+// already in the stack are left as they are, since importing them is a no-op.
+type Adopter struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	orgClient *organizations.Client
+	iamClient *iam.Client
+	cache     *orgcache.Cache
+	disabled  bool
+}
+
+// New creates an adopter for the credentials of the current environment
+func New(ctx context.Context, cfg *config.LandingZoneConfig) (*Adopter, error) {
+	logger, err := logging.NewLogger("adopt")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("adopt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	a := &Adopter{
+		logger:   logger,
+		metrics:  metrics,
+		disabled: cfg.DisableAdoption,
+	}
+	if a.disabled {
+		return a, nil
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.orgClient = organizations.NewFromConfig(base)
+	a.iamClient = iam.NewFromConfig(base)
+
+	if a.cache, err = orgcache.New(ctx, cfg, nil); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// OU returns the option importing the OU of the given name below the root when it
+// exists with the given tags. Lookups are best effort: when the organization cannot
+// be read, for instance because it does not exist yet, the OU is created.
+func (a *Adopter) OU(ctx context.Context, name string, tags map[string]string) []pulumi.ResourceOption {
+	if a == nil || a.disabled {
+		return nil
+	}
+
+	rootId, err := a.cache.RootID(ctx)
+	if err != nil {
+		a.logger.Debug("organization not readable, nothing to adopt", zap.Error(err))
+		return nil
+	}
+	ous, err := a.cache.OUs(ctx)
+	if err != nil {
+		a.logger.Warn("failed to list OUs to adopt", zap.Error(err))
+		return nil
+	}
+
+	for _, ou := range ous {
+		if ou.ParentID != rootId || ou.Name != name {
+			continue
+		}
+
+		actual, err := a.ouTags(ctx, ou.ID)
+		if err != nil {
+			a.logger.Warn("failed to read tags of existing OU", zap.String("ou", name), zap.Error(err))
+			return nil
+		}
+		if !equalTags(tags, actual) {
+			a.logger.Warn("existing OU has other tags and is not adopted",
+				zap.String("ou", name), zap.String("id", ou.ID))
+			return nil
+		}
+		return a.adopt("OU", name, ou.ID)
+	}
+	return nil
+}
+
+// Role returns the option importing the IAM role of the given name when it exists with
+// the given path, description, trust policy and tags
+func (a *Adopter) Role(ctx context.Context, name, path, description, assumeRolePolicy string, tags map[string]string) []pulumi.ResourceOption {
+	if a == nil || a.disabled {
+		return nil
+	}
+
+	out, err := a.iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(name)})
+	if err != nil {
+		// A missing role is the common case of a first run
+		return nil
+	}
+	role := out.Role
+
+	trust, err := url.QueryUnescape(aws.ToString(role.AssumeRolePolicyDocument))
+	if err != nil {
+		a.logger.Warn("failed to decode trust policy of existing role", zap.String("role", name), zap.Error(err))
+		return nil
+	}
+
+	switch {
+	case aws.ToString(role.Path) != path:
+		a.logger.Warn("existing role has another path and is not adopted", zap.String("role", name))
+	case aws.ToString(role.Description) != description:
+		a.logger.Warn("existing role has another description and is not adopted", zap.String("role", name))
+	case !equalPolicies(trust, assumeRolePolicy):
+		a.logger.Warn("existing role has another trust policy and is not adopted", zap.String("role", name))
+	case !equalTags(tags, roleTags(role.Tags)):
+		a.logger.Warn("existing role has other tags and is not adopted", zap.String("role", name))
+	default:
+		return a.adopt("role", name, name)
+	}
+	return nil
+}
+
+// adopt returns the import option of an existing resource
+func (a *Adopter) adopt(kind, name, id string) []pulumi.ResourceOption {
+	a.metrics.IncrementCounter("resources_adopted")
+	a.logger.Info("adopting existing resource",
+		zap.String("kind", kind),
+		zap.String("name", name),
+		zap.String("id", id))
+	return []pulumi.ResourceOption{pulumi.Import(pulumi.ID(id))}
+}
+
+// ouTags returns the tags of an OU
+func (a *Adopter) ouTags(ctx context.Context, ouId string) (map[string]string, error) {
+	tags := make(map[string]string)
+	paginator := organizations.NewListTagsForResourcePaginator(a.orgClient, &organizations.ListTagsForResourceInput{
+		ResourceId: aws.String(ouId),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of %s: %w", ouId, err)
+		}
+		for _, tag := range page.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+	return tags, nil
+}
+
+// roleTags returns the tags of a role as a map
+func roleTags(tags []iamtypes.Tag) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		m[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return m
+}
+
+// equalTags reports whether two tag sets are equal, a nil set being empty
+func equalTags(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if actual, ok := b[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// equalPolicies reports whether two policy documents are equal regardless of layout
+func equalPolicies(a, b string) bool {
+	var x, y interface{}
+	if json.Unmarshal([]byte(a), &x) != nil || json.Unmarshal([]byte(b), &y) != nil {
+		return false
+	}
+	dx, errX := json.Marshal(x)
+	dy, errY := json.Marshal(y)
+	return errX == nil && errY == nil && bytes.Equal(dx, dy)
+}
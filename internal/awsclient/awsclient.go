@@ -0,0 +1,98 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package awsclient provides AWS SDK configuration for the management and member accounts.
+// Version: 1.0.0
+package awsclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
+)
+
+const (
+	// DefaultMemberRoleName is the role assumed in member accounts when none is configured
+	DefaultMemberRoleName = "OrganizationAccountAccessRole"
+
+	// SessionName is recorded in CloudTrail for assumed role sessions
+	SessionName = "aws-organization-config"
+)
+
+// Load returns the SDK configuration for the credentials of the current environment and
+// detects the partition they belong to
+func Load(ctx context.Context) (aws.Config, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRetryMode(aws.RetryModeStandard),
+		awsconfig.WithRetryMaxAttempts(config.MaxRetries),
+		awsconfig.WithAPIOptions([]func(*middleware.Stack) error{ClassifyErrors}),
+	)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if err := detectPartition(ctx, cfg); err != nil {
+		return aws.Config{}, err
+	}
+	return cfg, nil
+}
+
+// OrganizationConfig returns the SDK configuration of the Organizations API: a copy of
+// base assuming OrganizationRoleArn when the landing zone sets it, base otherwise. Only
+// Organizations clients use it, the other services keep the credentials of base.
+func OrganizationConfig(base aws.Config, cfg *config.LandingZoneConfig) aws.Config {
+	if cfg == nil || cfg.OrganizationRoleArn == "" {
+		return base
+	}
+	return assumeRoleArn(base, cfg.OrganizationRoleArn)
+}
+
+// MemberRoleName returns the role assumed in member accounts
+func MemberRoleName(cfg *config.LandingZoneConfig) string {
+	if cfg != nil && cfg.Compliance != nil && cfg.Compliance.MemberRoleName != "" {
+		return cfg.Compliance.MemberRoleName
+	}
+	return DefaultMemberRoleName
+}
+
+// ReadOnlyRoleName returns the role assumed in member accounts by commands that only
+// read, falling back to the member role
+func ReadOnlyRoleName(cfg *config.LandingZoneConfig) string {
+	if cfg != nil && cfg.Compliance != nil && cfg.Compliance.ReadOnlyRoleName != "" {
+		return cfg.Compliance.ReadOnlyRoleName
+	}
+	return MemberRoleName(cfg)
+}
+
+// RoleArn returns the ARN of a role in a member account
+func RoleArn(accountID, roleName string) string {
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", Partition(), accountID, roleName)
+}
+
+// AssumeRole returns a copy of base whose credentials come from assuming roleName in
+// the given member account. Credentials are cached and refreshed on expiry.
+func AssumeRole(base aws.Config, accountID, roleName string) aws.Config {
+	if roleName == "" {
+		roleName = DefaultMemberRoleName
+	}
+
+	return assumeRoleArn(base, RoleArn(accountID, roleName))
+}
+
+// assumeRoleArn returns a copy of base whose credentials come from assuming roleArn
+func assumeRoleArn(base aws.Config, roleArn string) aws.Config {
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(base), roleArn,
+		func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = SessionName
+		})
+
+	cfg := base.Copy()
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+	return cfg
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package awsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Classes of AWS API errors callers branch on with errors.Is
+var (
+	ErrThrottled     = errors.New("request throttled")
+	ErrAccessDenied  = errors.New("access denied")
+	ErrAlreadyExists = errors.New("resource already exists")
+	ErrLimitExceeded = errors.New("limit exceeded")
+)
+
+// Error codes of the services used by the landing zone, by class
+var errorClasses = map[string]error{
+	"Throttling":                             ErrThrottled,
+	"ThrottlingException":                    ErrThrottled,
+	"ThrottledException":                     ErrThrottled,
+	"TooManyRequestsException":               ErrThrottled,
+	"RequestLimitExceeded":                   ErrThrottled,
+	"RequestThrottled":                       ErrThrottled,
+	"SlowDown":                               ErrThrottled,
+	"ProvisionedThroughputExceededException": ErrThrottled,
+	"AccessDenied":                           ErrAccessDenied,
+	"AccessDeniedException":                  ErrAccessDenied,
+	"AccessDeniedForDependencyException":     ErrAccessDenied,
+	"UnauthorizedOperation":                  ErrAccessDenied,
+	"AlreadyExistsException":                 ErrAlreadyExists,
+	"EntityAlreadyExists":                    ErrAlreadyExists,
+	"ResourceAlreadyExistsException":         ErrAlreadyExists,
+	"AlreadyInOrganizationException":         ErrAlreadyExists,
+	"DuplicateAccountException":              ErrAlreadyExists,
+	"DuplicateOrganizationalUnitException":   ErrAlreadyExists,
+	"DuplicatePolicyException":               ErrAlreadyExists,
+	"DuplicatePolicyAttachmentException":     ErrAlreadyExists,
+	"ParameterAlreadyExists":                 ErrAlreadyExists,
+	"BucketAlreadyOwnedByYou":                ErrAlreadyExists,
+	"LimitExceededException":                 ErrLimitExceeded,
+	"ServiceQuotaExceededException":          ErrLimitExceeded,
+	"ConstraintViolationException":           ErrLimitExceeded,
+	"TooManyTagsException":                   ErrLimitExceeded,
+	"ParameterLimitExceeded":                 ErrLimitExceeded,
+}
+
+// Remediation hints of the error classes
+var hints = map[error]string{
+	ErrThrottled: "the AWS API rate limit was exceeded; rerun later or lower the request rate, " +
+		"for instance by raising cache.ttlMinutes",
+	ErrAccessDenied: "the credentials lack a permission; run from the management account with " +
+		"administrator access and check the SCPs applied to the target account",
+	ErrAlreadyExists: "the resource already exists outside the stack; import it with pulumi import " +
+		"or remove it from the configuration",
+	ErrLimitExceeded: "an AWS quota was reached; request an increase through Service Quotas or " +
+		"remove unused resources",
+}
+
+// APIError is an AWS API error of a known class. It matches its class with errors.Is
+// and unwraps to the error of the SDK.
+type APIError struct {
+	Class error
+	Code  string
+	Err   error
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Class, e.Err)
+}
+
+// Is reports whether target is the class of the error
+func (e *APIError) Is(target error) bool {
+	return target == e.Class
+}
+
+// Unwrap returns the error of the SDK
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Classify wraps an AWS API error of a known class in an APIError and returns any other
+// error unchanged
+func Classify(err error) error {
+	var apiErr smithy.APIError
+	if err == nil || !errors.As(err, &apiErr) {
+		return err
+	}
+
+	var classified *APIError
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	class, ok := errorClasses[apiErr.ErrorCode()]
+	if !ok {
+		return err
+	}
+	return &APIError{Class: class, Code: apiErr.ErrorCode(), Err: err}
+}
+
+// Permanent reports whether retrying an operation that failed with err cannot succeed
+func Permanent(err error) bool {
+	return errors.Is(err, ErrAccessDenied) || errors.Is(err, ErrAlreadyExists) || errors.Is(err, ErrLimitExceeded)
+}
+
+// Hint returns the remediation of the class of an error, or an empty string
+func Hint(err error) string {
+	for class, hint := range hints {
+		if errors.Is(err, class) {
+			return hint
+		}
+	}
+	return ""
+}
+
+// ClassifyErrors is an API option classifying the errors returned by the operations of a
+// client once the SDK has exhausted its own retries
+func ClassifyErrors(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("ClassifyErrors",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (
+			middleware.InitializeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			return out, metadata, Classify(err)
+		}), middleware.Before)
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package awsclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+var (
+	partitionMutex sync.RWMutex
+
+	// partition is the AWS partition ARNs are built in
+	partition = config.PartitionAWS
+
+	// partitionConfigured is set when the partition comes from the configuration, the
+	// detected partition must then match it
+	partitionConfigured bool
+
+	// detected is set once the partition of the credentials is known
+	detected bool
+)
+
+// SetPartition sets the partition of the selected organization, an empty partition
+// keeps the current one
+func SetPartition(p string) {
+	if p == "" {
+		return
+	}
+
+	partitionMutex.Lock()
+	defer partitionMutex.Unlock()
+	partition = p
+	partitionConfigured = true
+}
+
+// Partition returns the partition ARNs are built in
+func Partition() string {
+	partitionMutex.RLock()
+	defer partitionMutex.RUnlock()
+	return partition
+}
+
+// detectPartition asks STS for the identity of the credentials and takes the partition
+// from its ARN. Detection runs once per process.
+func detectPartition(ctx context.Context, cfg aws.Config) error {
+	partitionMutex.Lock()
+	defer partitionMutex.Unlock()
+	if detected {
+		return nil
+	}
+
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("failed to detect AWS partition: %w", err)
+	}
+	parsed, err := arn.Parse(aws.ToString(identity.Arn))
+	if err != nil {
+		return fmt.Errorf("failed to detect AWS partition: %w", err)
+	}
+
+	if partitionConfigured && parsed.Partition != partition {
+		return fmt.Errorf("credentials belong to partition %s, but the organization is configured for %s",
+			parsed.Partition, partition)
+	}
+	partition = parsed.Partition
+	detected = true
+	return nil
+}
+
+// PolicyArn returns the ARN of an AWS managed policy, e.g. "service-role/AWSControlTowerServiceRolePolicy"
+func PolicyArn(name string) string {
+	return fmt.Sprintf("arn:%s:iam::aws:policy/%s", Partition(), name)
+}
+
+// AccountRootArn returns the ARN of the root principal of an account
+func AccountRootArn(accountID string) string {
+	return fmt.Sprintf("arn:%s:iam::%s:root", Partition(), accountID)
+}
+
+// BucketArn returns the ARN of an S3 bucket
+func BucketArn(bucket string) string {
+	return fmt.Sprintf("arn:%s:s3:::%s", Partition(), bucket)
+}
+
+// ServicePrincipal returns the principal of an AWS service. Service principals keep
+// the amazonaws.com suffix in every partition, including aws-cn.
+func ServicePrincipal(service string) string {
+	return service + ".amazonaws.com"
+}
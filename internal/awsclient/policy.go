@@ -0,0 +1,25 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package awsclient
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PolicyVersion is the version of the IAM policy language
+const PolicyVersion = "2012-10-17"
+
+// PolicyDocument returns the IAM policy document made of the given statements
+func PolicyDocument(statements []map[string]interface{}) (string, error) {
+	document, err := json.Marshal(map[string]interface{}{
+		"Version":   PolicyVersion,
+		"Statement": statements,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal policy document: %w", err)
+	}
+	return string(document), nil
+}
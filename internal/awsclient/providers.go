@@ -0,0 +1,56 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package awsclient
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Providers creates the Pulumi providers of the accounts and regions a component
+// deploys to, once each. Member accounts are reached by assuming the member role.
+type Providers struct {
+	prefix       string
+	managementId string
+	roleName     string
+	providers    map[string]*aws.Provider
+}
+
+// NewProviders creates the providers of a component, named after prefix
+func NewProviders(prefix, managementId, roleName string) *Providers {
+	return &Providers{
+		prefix:       prefix,
+		managementId: managementId,
+		roleName:     roleName,
+		providers:    make(map[string]*aws.Provider),
+	}
+}
+
+// Get returns the provider for an account and region, created on first use
+func (p *Providers) Get(ctx *pulumi.Context, accountId, region string) (*aws.Provider, error) {
+	name := fmt.Sprintf("%s-%s-%s", p.prefix, accountId, region)
+	if provider, ok := p.providers[name]; ok {
+		return provider, nil
+	}
+
+	args := &aws.ProviderArgs{
+		Region: pulumi.String(region),
+	}
+	if accountId != p.managementId {
+		args.AssumeRole = &aws.ProviderAssumeRoleArgs{
+			RoleArn:     pulumi.String(RoleArn(accountId, p.roleName)),
+			SessionName: pulumi.String(SessionName),
+		}
+	}
+
+	provider, err := aws.NewProvider(ctx, name, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create provider for %s/%s: %w", accountId, region, err)
+	}
+	p.providers[name] = provider
+	return provider, nil
+}
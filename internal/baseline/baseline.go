@@ -0,0 +1,392 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package baseline provides the resource baseline applied to every account of the organization.
+// Version: 1.0.0
+package baseline
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/account"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudformation"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ebs"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/organizations"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Status of accounts the baseline is applied to
+	accountStatusActive = "ACTIVE"
+
+	// Alternate contact types of the Account Management API
+	alternateContactBilling    = "BILLING"
+	alternateContactOperations = "OPERATIONS"
+	alternateContactSecurity   = "SECURITY"
+)
+
+// Toggles holds the resource baseline of a single account
+type Toggles struct {
+	EBSEncryptionByDefault bool
+	S3BlockPublicAccess    bool
+	Regions                []string
+	Contacts               config.ContactsConfig
+	EnabledRegions         []string
+	DisabledRegions        []string
+	RootActivityAlarm      bool
+}
+
+// hasContacts reports whether any contact of the account is managed
+func (t Toggles) hasContacts() bool {
+	c := t.Contacts
+	return c.Primary != nil || c.Billing != nil || c.Operations != nil || c.Security != nil
+}
+
+// Baseline applies the resource baseline through providers assuming the member role of
+// every account, or through the StackSets of the baselines delivered by StackSet
+type Baseline struct {
+	logger       *zap.Logger
+	metrics      *metrics.Collector
+	roleName     string
+	managementId string
+	stackSets    map[string]*cloudformation.StackSet
+	orgId        string
+	alarm        *config.RootActivityAlarmConfig
+	alarmRegion  string
+	alarmBus     pulumi.Resource
+	forwardRoles map[string]*iam.Role
+	tags         map[string]string
+	providers    *awsclient.Providers
+}
+
+// SetupAccountBaseline enables EBS encryption by default and S3 account-level Block
+// Public Access, sets the primary and alternate contacts, manages the opt-in regions
+// and deploys the root activity alarm in every active account of the organization. Accounts created by the
+// same update are covered by the next one. Baselines delivered by StackSet are
+// deployed to each account as an instance of their StackSet instead.
+func SetupAccountBaseline(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("baseline")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("baseline")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("baseline_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup account baseline"); err != nil {
+		return err
+	}
+
+	if cfg.Baseline == nil || (!cfg.Baseline.EBSEncryptionByDefault && !cfg.Baseline.S3BlockPublicAccess &&
+		cfg.Baseline.Contacts == nil && cfg.Baseline.OptInRegions == nil && cfg.Baseline.RootActivityAlarm == nil &&
+		len(cfg.Baseline.AccountOverrides) == 0) {
+		logger.Info("no resource baseline configured")
+		return nil
+	}
+
+	org, err := organizations.LookupOrganization(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look up organization accounts: %w", err)
+	}
+
+	b := &Baseline{
+		logger:       logger,
+		metrics:      metrics,
+		roleName:     awsclient.MemberRoleName(cfg),
+		managementId: org.MasterAccountId,
+		stackSets:    make(map[string]*cloudformation.StackSet),
+		orgId:        org.Id,
+		alarm:        cfg.Baseline.RootActivityAlarm,
+		alarmRegion:  cfg.RootActivityAlarmRegion(),
+		forwardRoles: make(map[string]*iam.Role),
+		tags:         cfg.Tags,
+		providers:    awsclient.NewProviders("baseline", org.MasterAccountId, awsclient.MemberRoleName(cfg)),
+	}
+
+	if err := b.setupStackSets(ctx, cfg); err != nil {
+		return err
+	}
+
+	if b.alarm != nil {
+		if err := b.setupRootAlarm(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, account := range org.Accounts {
+		if account.Status != accountStatusActive {
+			continue
+		}
+
+		if err := b.applyAccount(ctx, account.Id, TogglesFor(cfg, account.Id)); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("account baseline setup completed successfully", zap.Int("accounts", len(org.Accounts)))
+	return nil
+}
+
+// TogglesFor returns the resource baseline of an account, applying its overrides
+func TogglesFor(cfg *config.LandingZoneConfig, accountId string) Toggles {
+	b := cfg.Baseline
+	t := Toggles{
+		EBSEncryptionByDefault: b.EBSEncryptionByDefault,
+		S3BlockPublicAccess:    b.S3BlockPublicAccess,
+		Regions:                cfg.BaselineRegions(accountId),
+		RootActivityAlarm:      cfg.RootActivityAlarmFor(accountId),
+	}
+	if b.Contacts != nil {
+		t.Contacts = *b.Contacts
+	}
+	if b.OptInRegions != nil {
+		t.EnabledRegions = cfg.EnabledOptInRegions()
+		t.DisabledRegions = b.OptInRegions.Disabled
+	}
+
+	if override, ok := b.AccountOverrides[accountId]; ok && override != nil {
+		if override.EBSEncryptionByDefault != nil {
+			t.EBSEncryptionByDefault = *override.EBSEncryptionByDefault
+		}
+		if override.S3BlockPublicAccess != nil {
+			t.S3BlockPublicAccess = *override.S3BlockPublicAccess
+		}
+		if c := override.Contacts; c != nil {
+			if c.Primary != nil {
+				t.Contacts.Primary = c.Primary
+			}
+			if c.Billing != nil {
+				t.Contacts.Billing = c.Billing
+			}
+			if c.Operations != nil {
+				t.Contacts.Operations = c.Operations
+			}
+			if c.Security != nil {
+				t.Contacts.Security = c.Security
+			}
+		}
+	}
+
+	return t
+}
+
+// applyAccount applies the baseline of a single account. Account-wide settings go
+// through a provider in the first region enabled by default, and EBS encryption waits
+// for the opt-in regions to be enabled.
+func (b *Baseline) applyAccount(ctx *pulumi.Context, accountId string, t Toggles) error {
+	if !t.EBSEncryptionByDefault && !t.S3BlockPublicAccess && !t.hasContacts() && !t.RootActivityAlarm &&
+		len(t.EnabledRegions) == 0 && len(t.DisabledRegions) == 0 {
+		return nil
+	}
+	if len(t.Regions) == 0 {
+		return fmt.Errorf("no region to apply the baseline of %s in", accountId)
+	}
+
+	home := t.Regions[0]
+	for _, region := range t.Regions {
+		if !config.IsOptInRegion(region) {
+			home = region
+			break
+		}
+	}
+	accountProvider, err := b.providers.Get(ctx, accountId, home)
+	if err != nil {
+		return err
+	}
+
+	enabled, err := b.applyRegions(ctx, accountId, t, accountProvider)
+	if err != nil {
+		return err
+	}
+
+	// Baseline resources are retained on delete: when a baseline switches to StackSet
+	// delivery, the engine deletes them after the StackSet instance applied it, which
+	// would otherwise undo the baseline. Block Public Access is an account-wide
+	// setting, applied once.
+	if t.S3BlockPublicAccess && b.viaStackSet(accountId, config.BaselineS3BlockPublicAccess) {
+		if err := b.deployStackSet(ctx, config.BaselineS3BlockPublicAccess, accountId, home, nil, nil); err != nil {
+			return err
+		}
+	} else if t.S3BlockPublicAccess {
+		if _, err := s3.NewAccountPublicAccessBlock(ctx, fmt.Sprintf("s3-block-public-access-%s", accountId),
+			&s3.AccountPublicAccessBlockArgs{
+				AccountId:             pulumi.String(accountId),
+				BlockPublicAcls:       pulumi.Bool(true),
+				BlockPublicPolicy:     pulumi.Bool(true),
+				IgnorePublicAcls:      pulumi.Bool(true),
+				RestrictPublicBuckets: pulumi.Bool(true),
+			}, pulumi.Provider(accountProvider), pulumi.RetainOnDelete(true)); err != nil {
+			return fmt.Errorf("failed to block S3 public access in %s: %w", accountId, err)
+		}
+		b.metrics.IncrementCounter("s3_public_access_blocked")
+	}
+
+	if t.hasContacts() && b.viaStackSet(accountId, config.BaselineContacts) {
+		parameters, err := contactParameters(t.Contacts)
+		if err != nil {
+			return err
+		}
+		if err := b.deployStackSet(ctx, config.BaselineContacts, accountId, home, parameters, nil); err != nil {
+			return err
+		}
+	} else if err := b.applyContacts(ctx, accountId, t.Contacts, accountProvider); err != nil {
+		return err
+	}
+
+	if t.EBSEncryptionByDefault {
+		for _, region := range t.Regions {
+			if b.viaStackSet(accountId, config.BaselineEBSEncryption) {
+				if err := b.deployStackSet(ctx, config.BaselineEBSEncryption, accountId, region, nil, enabled); err != nil {
+					return err
+				}
+				continue
+			}
+
+			provider := accountProvider
+			if region != home {
+				if provider, err = b.providers.Get(ctx, accountId, region); err != nil {
+					return err
+				}
+			}
+
+			if _, err := ebs.NewEncryptionByDefault(ctx, fmt.Sprintf("ebs-encryption-%s-%s", accountId, region),
+				&ebs.EncryptionByDefaultArgs{
+					Enabled: pulumi.Bool(true),
+				}, pulumi.Provider(provider), pulumi.DependsOn(enabled), pulumi.RetainOnDelete(true)); err != nil {
+				return fmt.Errorf("failed to enable EBS encryption by default in %s/%s: %w", accountId, region, err)
+			}
+			b.metrics.IncrementCounter("ebs_encryption_enabled")
+		}
+	}
+
+	if t.RootActivityAlarm {
+		for _, region := range t.Regions {
+			provider, err := b.providers.Get(ctx, accountId, region)
+			if err != nil {
+				return err
+			}
+			if err := b.applyRootAlarm(ctx, accountId, region, provider, enabled); err != nil {
+				return err
+			}
+		}
+	}
+
+	b.logger.Info("account baseline applied",
+		zap.String("accountId", accountId),
+		zap.Bool("ebsEncryptionByDefault", t.EBSEncryptionByDefault),
+		zap.Bool("s3BlockPublicAccess", t.S3BlockPublicAccess),
+		zap.Bool("contacts", t.hasContacts()),
+		zap.Bool("rootActivityAlarm", t.RootActivityAlarm),
+		zap.Strings("regions", t.Regions),
+		zap.Strings("enabledOptInRegions", t.EnabledRegions),
+		zap.Strings("disabledOptInRegions", t.DisabledRegions))
+	return nil
+}
+
+// applyRegions enables and disables the opt-in regions of an account and returns the
+// resources enabling them
+func (b *Baseline) applyRegions(ctx *pulumi.Context, accountId string, t Toggles, provider *aws.Provider) ([]pulumi.Resource, error) {
+	var enabled []pulumi.Resource
+	for _, region := range t.EnabledRegions {
+		resource, err := account.NewRegion(ctx, fmt.Sprintf("opt-in-region-%s-%s", accountId, region), &account.RegionArgs{
+			RegionName: pulumi.String(region),
+			Enabled:    pulumi.Bool(true),
+		}, pulumi.Provider(provider))
+		if err != nil {
+			return nil, fmt.Errorf("failed to enable region %s in %s: %w", region, accountId, err)
+		}
+		enabled = append(enabled, resource)
+		b.metrics.IncrementCounter("opt_in_regions_enabled")
+	}
+
+	for _, region := range t.DisabledRegions {
+		if _, err := account.NewRegion(ctx, fmt.Sprintf("opt-in-region-%s-%s", accountId, region), &account.RegionArgs{
+			RegionName: pulumi.String(region),
+			Enabled:    pulumi.Bool(false),
+		}, pulumi.Provider(provider)); err != nil {
+			return nil, fmt.Errorf("failed to disable region %s in %s: %w", region, accountId, err)
+		}
+		b.metrics.IncrementCounter("opt_in_regions_disabled")
+	}
+
+	return enabled, nil
+}
+
+// applyContacts manages the primary and alternate contacts of an account
+func (b *Baseline) applyContacts(ctx *pulumi.Context, accountId string, c config.ContactsConfig, provider *aws.Provider) error {
+	if p := c.Primary; p != nil {
+		if _, err := account.NewPrimaryContact(ctx, fmt.Sprintf("primary-contact-%s", accountId),
+			&account.PrimaryContactArgs{
+				FullName:         pulumi.String(p.FullName),
+				CompanyName:      optional(p.CompanyName),
+				AddressLine1:     pulumi.String(p.AddressLine1),
+				AddressLine2:     optional(p.AddressLine2),
+				AddressLine3:     optional(p.AddressLine3),
+				City:             pulumi.String(p.City),
+				StateOrRegion:    optional(p.StateOrRegion),
+				DistrictOrCounty: optional(p.DistrictOrCounty),
+				PostalCode:       pulumi.String(p.PostalCode),
+				CountryCode:      pulumi.String(p.CountryCode),
+				PhoneNumber:      pulumi.String(p.PhoneNumber),
+				WebsiteUrl:       optional(p.WebsiteUrl),
+			}, pulumi.Provider(provider), pulumi.RetainOnDelete(true)); err != nil {
+			return fmt.Errorf("failed to set primary contact of %s: %w", accountId, err)
+		}
+		b.metrics.IncrementCounter("primary_contacts_set")
+	}
+
+	alternates := []struct {
+		kind    string
+		contact *config.AlternateContactConfig
+	}{
+		{alternateContactBilling, c.Billing},
+		{alternateContactOperations, c.Operations},
+		{alternateContactSecurity, c.Security},
+	}
+	for _, alternate := range alternates {
+		if alternate.contact == nil {
+			continue
+		}
+		if _, err := account.NewAlternativeContact(ctx,
+			fmt.Sprintf("%s-contact-%s", strings.ToLower(alternate.kind), accountId),
+			&account.AlternativeContactArgs{
+				AlternateContactType: pulumi.String(alternate.kind),
+				Name:                 pulumi.String(alternate.contact.Name),
+				Title:                pulumi.String(alternate.contact.Title),
+				EmailAddress:         pulumi.String(alternate.contact.Email),
+				PhoneNumber:          pulumi.String(alternate.contact.PhoneNumber),
+			}, pulumi.Provider(provider), pulumi.RetainOnDelete(true)); err != nil {
+			return fmt.Errorf("failed to set %s contact of %s: %w", strings.ToLower(alternate.kind), accountId, err)
+		}
+		b.metrics.IncrementCounter("alternate_contacts_set")
+	}
+
+	return nil
+}
+
+// optional returns nil for empty optional string arguments
+func optional(value string) pulumi.StringPtrInput {
+	if value == "" {
+		return nil
+	}
+	return pulumi.String(value)
+}
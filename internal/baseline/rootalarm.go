@@ -0,0 +1,220 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package baseline
+
+import (
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/sns"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// rootActivityPattern matches the API calls and console sign-ins of the root user
+// recorded by CloudTrail
+const rootActivityPattern = `{
+	"detail-type": ["AWS API Call via CloudTrail", "AWS Console Sign In via CloudTrail"],
+	"detail": {
+		"userIdentity": {
+			"type": ["Root"]
+		}
+	}
+}`
+
+// eventsService is the service publishing the root activity to the topic and
+// forwarding it to the bus of the alarm
+const eventsService = "events"
+
+// setupRootAlarm deploys the central resources of the root activity alarm in the
+// management account: the SNS topic notifying the alarm emails, a permission letting
+// the accounts of the organization put events on the default event bus of the alarm
+// region and the rule publishing the root activity reaching the bus to the topic.
+// Every email receives a single subscription confirmation.
+func (b *Baseline) setupRootAlarm(ctx *pulumi.Context) error {
+	provider, err := b.providers.Get(ctx, b.managementId, b.alarmRegion)
+	if err != nil {
+		return err
+	}
+	name := b.alarm.AlarmName()
+
+	args := &sns.TopicArgs{
+		Name: pulumi.String(name),
+		Tags: pulumi.ToStringMap(b.tags),
+	}
+	if b.alarm.KmsKeyId != "" {
+		args.KmsMasterKeyId = pulumi.String(b.alarm.KmsKeyId)
+	}
+	topic, err := sns.NewTopic(ctx, "root-activity-topic", args, pulumi.Provider(provider))
+	if err != nil {
+		return fmt.Errorf("failed to create root activity topic: %w", err)
+	}
+
+	managementId := b.managementId
+	policy := topic.Arn.ApplyT(func(topicArn string) (string, error) {
+		document, err := awsclient.PolicyDocument([]map[string]interface{}{
+			{
+				"Sid":       "AllowRootActivityEvents",
+				"Effect":    "Allow",
+				"Principal": map[string]string{"Service": awsclient.ServicePrincipal(eventsService)},
+				"Action":    "sns:Publish",
+				"Resource":  topicArn,
+				"Condition": map[string]interface{}{
+					"StringEquals": map[string]string{"aws:SourceAccount": managementId},
+				},
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal root activity topic policy: %w", err)
+		}
+		return document, nil
+	}).(pulumi.StringOutput)
+
+	topicPolicy, err := sns.NewTopicPolicy(ctx, "root-activity-topic-policy", &sns.TopicPolicyArgs{
+		Arn:    topic.Arn,
+		Policy: policy,
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return fmt.Errorf("failed to set root activity topic policy: %w", err)
+	}
+
+	for i, email := range b.alarm.Emails {
+		if _, err := sns.NewTopicSubscription(ctx, fmt.Sprintf("root-activity-email-%d", i), &sns.TopicSubscriptionArgs{
+			Topic:    topic.Arn,
+			Protocol: pulumi.String("email"),
+			Endpoint: pulumi.String(email),
+		}, pulumi.Provider(provider)); err != nil {
+			return fmt.Errorf("failed to subscribe %s to root activity topic: %w", email, err)
+		}
+	}
+
+	permission, err := cloudwatch.NewEventPermission(ctx, "root-activity-bus-permission", &cloudwatch.EventPermissionArgs{
+		Principal:   pulumi.String("*"),
+		StatementId: pulumi.String(name),
+		Condition: &cloudwatch.EventPermissionConditionArgs{
+			Key:   pulumi.String("aws:PrincipalOrgID"),
+			Type:  pulumi.String("StringEquals"),
+			Value: pulumi.String(b.orgId),
+		},
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return fmt.Errorf("failed to let the organization forward root activity: %w", err)
+	}
+	b.alarmBus = permission
+
+	rule, err := cloudwatch.NewEventRule(ctx, "root-activity-rule", &cloudwatch.EventRuleArgs{
+		Name:         pulumi.String(name),
+		Description:  pulumi.String("Root user activity recorded by CloudTrail in the organization"),
+		EventPattern: pulumi.String(rootActivityPattern),
+		Tags:         pulumi.ToStringMap(b.tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return fmt.Errorf("failed to create root activity rule: %w", err)
+	}
+
+	if _, err := cloudwatch.NewEventTarget(ctx, "root-activity-target", &cloudwatch.EventTargetArgs{
+		Rule: rule.Name,
+		Arn:  topic.Arn,
+	}, pulumi.Provider(provider), pulumi.DependsOn([]pulumi.Resource{topicPolicy})); err != nil {
+		return fmt.Errorf("failed to route root activity to its topic: %w", err)
+	}
+	return nil
+}
+
+// alarmBusArn returns the ARN of the event bus the root activity is forwarded to
+func (b *Baseline) alarmBusArn() string {
+	return fmt.Sprintf("arn:%s:events:%s:%s:event-bus/default", awsclient.Partition(), b.alarmRegion, b.managementId)
+}
+
+// applyRootAlarm deploys the root activity alarm of an account in a region: an
+// EventBridge rule forwarding the CloudTrail events of the root user to the bus of the
+// alarm. The rule of the management account in the alarm region is the central one.
+// Console sign-ins are recorded in us-east-1, so the alarm only sees them when that
+// region is one of the baseline regions.
+func (b *Baseline) applyRootAlarm(ctx *pulumi.Context, accountId, region string, provider *aws.Provider, enabled []pulumi.Resource) error {
+	if accountId == b.managementId && region == b.alarmRegion {
+		return nil
+	}
+	suffix := fmt.Sprintf("%s-%s", accountId, region)
+
+	role, err := b.forwardRole(ctx, accountId, provider)
+	if err != nil {
+		return err
+	}
+
+	rule, err := cloudwatch.NewEventRule(ctx, fmt.Sprintf("root-activity-rule-%s", suffix), &cloudwatch.EventRuleArgs{
+		Name:         pulumi.String(b.alarm.AlarmName()),
+		Description:  pulumi.String("Root user activity recorded by CloudTrail"),
+		EventPattern: pulumi.String(rootActivityPattern),
+		Tags:         pulumi.ToStringMap(b.tags),
+	}, pulumi.Provider(provider), pulumi.DependsOn(enabled))
+	if err != nil {
+		return fmt.Errorf("failed to create root activity rule in %s/%s: %w", accountId, region, err)
+	}
+
+	if _, err := cloudwatch.NewEventTarget(ctx, fmt.Sprintf("root-activity-target-%s", suffix), &cloudwatch.EventTargetArgs{
+		Rule:    rule.Name,
+		Arn:     pulumi.String(b.alarmBusArn()),
+		RoleArn: role.Arn,
+	}, pulumi.Provider(provider), pulumi.DependsOn([]pulumi.Resource{b.alarmBus})); err != nil {
+		return fmt.Errorf("failed to forward root activity of %s/%s: %w", accountId, region, err)
+	}
+
+	b.metrics.IncrementCounter("root_activity_alarms_deployed")
+	return nil
+}
+
+// forwardRole returns the role EventBridge assumes in an account to forward the root
+// activity to the bus of the alarm, created once per account
+func (b *Baseline) forwardRole(ctx *pulumi.Context, accountId string, provider *aws.Provider) (*iam.Role, error) {
+	if role, ok := b.forwardRoles[accountId]; ok {
+		return role, nil
+	}
+
+	trust, err := awsclient.PolicyDocument([]map[string]interface{}{
+		{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": awsclient.ServicePrincipal(eventsService)},
+			"Action":    "sts:AssumeRole",
+			"Condition": map[string]interface{}{
+				"StringEquals": map[string]string{"aws:SourceAccount": accountId},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal root activity forwarding trust policy: %w", err)
+	}
+	policy, err := awsclient.PolicyDocument([]map[string]interface{}{
+		{
+			"Effect":   "Allow",
+			"Action":   "events:PutEvents",
+			"Resource": b.alarmBusArn(),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal root activity forwarding policy: %w", err)
+	}
+
+	name := fmt.Sprintf("root-activity-forward-%s", accountId)
+	role, err := iam.NewRole(ctx, name, &iam.RoleArgs{
+		Description:      pulumi.String("Forwards the root user activity to the root activity alarm"),
+		AssumeRolePolicy: pulumi.String(trust),
+		Tags:             pulumi.ToStringMap(b.tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create root activity forwarding role in %s: %w", accountId, err)
+	}
+	if _, err := iam.NewRolePolicy(ctx, name, &iam.RolePolicyArgs{
+		Role:   role.ID(),
+		Policy: pulumi.String(policy),
+	}, pulumi.Provider(provider)); err != nil {
+		return nil, fmt.Errorf("failed to set root activity forwarding policy in %s: %w", accountId, err)
+	}
+
+	b.forwardRoles[accountId] = role
+	return role, nil
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudformation"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// Permission model of the baseline StackSets, executed with the member role
+	permissionModelSelfManaged = "SELF_MANAGED"
+
+	// Prefix of the names of the baseline StackSets
+	stackSetPrefix = "landing-zone-baseline-"
+)
+
+// stackSetNames are the StackSet names of the baselines delivered by StackSet
+var stackSetNames = map[string]string{
+	config.BaselineEBSEncryption:       "ebs-encryption",
+	config.BaselineS3BlockPublicAccess: "s3-block-public-access",
+	config.BaselineContacts:            "contacts",
+}
+
+// setupStackSets creates the StackSet of every baseline delivered by StackSet, and the
+// role CloudFormation assumes the member role of each account with
+func (b *Baseline) setupStackSets(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	var baselines []string
+	for _, baseline := range config.DeliverableBaselines {
+		if cfg.Baseline.DeliveryOf(baseline) == config.DeliveryStackSet {
+			baselines = append(baselines, baseline)
+		}
+	}
+	if len(baselines) == 0 {
+		return nil
+	}
+
+	admin, err := b.administrationRole(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, baseline := range baselines {
+		body, err := Template(baseline)
+		if err != nil {
+			return err
+		}
+
+		name := stackSetNames[baseline]
+		stackSet, err := cloudformation.NewStackSet(ctx, fmt.Sprintf("baseline-%s-stackset", name), &cloudformation.StackSetArgs{
+			Name:                  pulumi.String(stackSetPrefix + name),
+			Description:           pulumi.Sprintf("Landing zone resource baseline %s", baseline),
+			PermissionModel:       pulumi.String(permissionModelSelfManaged),
+			AdministrationRoleArn: admin.Arn,
+			ExecutionRoleName:     pulumi.String(b.roleName),
+			Capabilities:          pulumi.ToStringArray([]string{"CAPABILITY_IAM"}),
+			TemplateBody:          pulumi.String(body),
+			Tags:                  pulumi.ToStringMap(cfg.Tags),
+		}, pulumi.DependsOn([]pulumi.Resource{admin}))
+		if err != nil {
+			return fmt.Errorf("failed to create StackSet of baseline %s: %w", baseline, err)
+		}
+		b.stackSets[baseline] = stackSet
+	}
+
+	b.logger.Info("baseline StackSets created")
+	return nil
+}
+
+// administrationRole creates the role the baseline StackSets are administered with,
+// allowed to assume the member role of every account
+func (b *Baseline) administrationRole(ctx *pulumi.Context, cfg *config.LandingZoneConfig) (*iam.Role, error) {
+	role, err := iam.NewRole(ctx, "baseline-stackset-administration", &iam.RoleArgs{
+		Name:        component.Name(cfg.ResourceNames().Name(config.RoleStackSetAdministration, "")),
+		Description: pulumi.String("Administers the landing zone baseline StackSets"),
+		AssumeRolePolicy: pulumi.String(fmt.Sprintf(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Allow",
+				"Principal": {
+					"Service": "%s"
+				},
+				"Action": "sts:AssumeRole"
+			}]
+		}`, awsclient.ServicePrincipal("cloudformation"))),
+		Tags: pulumi.ToStringMap(cfg.Tags),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create baseline StackSet administration role: %w", err)
+	}
+
+	policy, err := awsclient.PolicyDocument([]map[string]interface{}{{
+		"Effect":   "Allow",
+		"Action":   "sts:AssumeRole",
+		"Resource": awsclient.RoleArn("*", b.roleName),
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal baseline StackSet administration policy: %w", err)
+	}
+
+	if _, err := iam.NewRolePolicy(ctx, "baseline-stackset-administration-assume-member-role", &iam.RolePolicyArgs{
+		Role:   role.ID(),
+		Policy: pulumi.String(policy),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to attach baseline StackSet administration policy: %w", err)
+	}
+	return role, nil
+}
+
+// viaStackSet reports whether a baseline of an account is delivered by StackSet. The
+// management account has no member role to execute StackSets with, so its baselines
+// are always applied through Pulumi.
+func (b *Baseline) viaStackSet(accountId, baseline string) bool {
+	return accountId != b.managementId && b.stackSets[baseline] != nil
+}
+
+// deployStackSet deploys the StackSet of a baseline to an account and region. A
+// replaced instance is deleted before its successor is created, so two stacks never
+// apply the baseline of the same account and region.
+func (b *Baseline) deployStackSet(ctx *pulumi.Context, baseline, accountId, region string, parameters map[string]string, dependsOn []pulumi.Resource) error {
+	stackSet := b.stackSets[baseline]
+	if _, err := cloudformation.NewStackSetInstance(ctx, fmt.Sprintf("baseline-%s-%s-%s", stackSetNames[baseline], accountId, region),
+		&cloudformation.StackSetInstanceArgs{
+			StackSetName:       stackSet.Name,
+			AccountId:          pulumi.String(accountId),
+			Region:             pulumi.String(region),
+			ParameterOverrides: pulumi.ToStringMap(parameters),
+		}, pulumi.DependsOn(append([]pulumi.Resource{stackSet}, dependsOn...)), pulumi.DeleteBeforeReplace(true)); err != nil {
+		return fmt.Errorf("failed to deploy baseline %s to %s/%s: %w", baseline, accountId, region, err)
+	}
+	b.metrics.IncrementCounter("baseline_stackset_instances_created")
+	return nil
+}
+
+// contactParameters returns the parameters of the contacts template for the contacts
+// of an account, in the shape of the Account Management API
+func contactParameters(c config.ContactsConfig) (map[string]string, error) {
+	parameters := make(map[string]string)
+
+	if p := c.Primary; p != nil {
+		contact := map[string]string{
+			"FullName":     p.FullName,
+			"AddressLine1": p.AddressLine1,
+			"City":         p.City,
+			"PostalCode":   p.PostalCode,
+			"CountryCode":  p.CountryCode,
+			"PhoneNumber":  p.PhoneNumber,
+		}
+		for key, value := range map[string]string{
+			"CompanyName":      p.CompanyName,
+			"AddressLine2":     p.AddressLine2,
+			"AddressLine3":     p.AddressLine3,
+			"StateOrRegion":    p.StateOrRegion,
+			"DistrictOrCounty": p.DistrictOrCounty,
+			"WebsiteUrl":       p.WebsiteUrl,
+		} {
+			if value != "" {
+				contact[key] = value
+			}
+		}
+		if err := setParameter(parameters, paramPrimaryContact, contact); err != nil {
+			return nil, err
+		}
+	}
+
+	alternates := map[string]*config.AlternateContactConfig{
+		paramBillingContact:    c.Billing,
+		paramOperationsContact: c.Operations,
+		paramSecurityContact:   c.Security,
+	}
+	for name, alternate := range alternates {
+		if alternate == nil {
+			continue
+		}
+		if err := setParameter(parameters, name, map[string]string{
+			"Name":         alternate.Name,
+			"Title":        alternate.Title,
+			"EmailAddress": alternate.Email,
+			"PhoneNumber":  alternate.PhoneNumber,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return parameters, nil
+}
+
+// setParameter sets a template parameter to the JSON encoding of a contact
+func setParameter(parameters map[string]string, name string, contact map[string]string) error {
+	value, err := json.Marshal(contact)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	parameters[name] = string(value)
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+)
+
+// Runtime of the functions applying the baselines in the StackSet templates
+const functionRuntime = "python3.12"
+
+// Parameters of the contacts template, holding the contacts as JSON in the shape of
+// the Account Management API. Empty alternate contacts are deleted.
+const (
+	paramPrimaryContact    = "PrimaryContact"
+	paramBillingContact    = "BillingContact"
+	paramOperationsContact = "OperationsContact"
+	paramSecurityContact   = "SecurityContact"
+)
+
+// ebsEncryptionCode enables EBS encryption by default in the region of the stack. It
+// stays enabled when the stack is deleted, so the baseline survives a switch of its
+// delivery.
+const ebsEncryptionCode = `import boto3
+import cfnresponse
+
+def handler(event, context):
+    try:
+        ec2 = boto3.client('ec2')
+        if event['RequestType'] != 'Delete':
+            ec2.enable_ebs_encryption_by_default()
+        cfnresponse.send(event, context, cfnresponse.SUCCESS, {}, 'ebs-encryption-by-default')
+    except Exception as e:
+        cfnresponse.send(event, context, cfnresponse.FAILED, {'Error': str(e)}, 'ebs-encryption-by-default')
+`
+
+// s3BlockPublicAccessCode blocks S3 public access at the account level. The block is
+// kept when the stack is deleted.
+const s3BlockPublicAccessCode = `import boto3
+import cfnresponse
+
+def handler(event, context):
+    try:
+        s3control = boto3.client('s3control')
+        account = event['ResourceProperties']['AccountId']
+        if event['RequestType'] != 'Delete':
+            s3control.put_public_access_block(AccountId=account, PublicAccessBlockConfiguration={
+                'BlockPublicAcls': True,
+                'IgnorePublicAcls': True,
+                'BlockPublicPolicy': True,
+                'RestrictPublicBuckets': True,
+            })
+        cfnresponse.send(event, context, cfnresponse.SUCCESS, {}, 's3-block-public-access-' + account)
+    except Exception as e:
+        cfnresponse.send(event, context, cfnresponse.FAILED, {'Error': str(e)}, 's3-block-public-access')
+`
+
+// contactsCode sets the primary and alternate contacts of the account. Empty alternate
+// contacts are deleted; the contacts are kept when the stack is deleted.
+const contactsCode = `import json
+import boto3
+import cfnresponse
+
+ALTERNATES = {'BillingContact': 'BILLING', 'OperationsContact': 'OPERATIONS', 'SecurityContact': 'SECURITY'}
+
+def handler(event, context):
+    try:
+        client = boto3.client('account')
+        props = event['ResourceProperties']
+        if event['RequestType'] == 'Delete':
+            cfnresponse.send(event, context, cfnresponse.SUCCESS, {}, 'contacts')
+            return
+        if props.get('PrimaryContact'):
+            client.put_contact_information(ContactInformation=json.loads(props['PrimaryContact']))
+        for key, kind in ALTERNATES.items():
+            contact = props.get(key, '')
+            if contact:
+                client.put_alternate_contact(AlternateContactType=kind, **json.loads(contact))
+                continue
+            try:
+                client.delete_alternate_contact(AlternateContactType=kind)
+            except client.exceptions.ResourceNotFoundException:
+                pass
+        cfnresponse.send(event, context, cfnresponse.SUCCESS, {}, 'contacts')
+    except Exception as e:
+        cfnresponse.send(event, context, cfnresponse.FAILED, {'Error': str(e)}, 'contacts')
+`
+
+// Template returns the CloudFormation template delivering a resource baseline by
+// StackSet. Each template applies its baseline through a custom resource backed by a
+// function calling the same APIs as the Pulumi resources of the baseline.
+func Template(baseline string) (string, error) {
+	var t map[string]interface{}
+	switch baseline {
+	case config.BaselineEBSEncryption:
+		t = functionTemplate("EBS encryption by default",
+			[]string{"ec2:EnableEbsEncryptionByDefault", "ec2:GetEbsEncryptionByDefault"},
+			ebsEncryptionCode, nil, nil)
+	case config.BaselineS3BlockPublicAccess:
+		t = functionTemplate("S3 account-level Block Public Access",
+			[]string{"s3:PutAccountPublicAccessBlock", "s3:GetAccountPublicAccessBlock"},
+			s3BlockPublicAccessCode, nil,
+			map[string]interface{}{"AccountId": map[string]interface{}{"Ref": "AWS::AccountId"}})
+	case config.BaselineContacts:
+		parameters := make(map[string]interface{})
+		properties := make(map[string]interface{})
+		for _, name := range []string{paramPrimaryContact, paramBillingContact, paramOperationsContact, paramSecurityContact} {
+			parameters[name] = map[string]interface{}{"Type": "String", "Default": ""}
+			properties[name] = map[string]interface{}{"Ref": name}
+		}
+		t = functionTemplate("primary and alternate contacts",
+			[]string{"account:PutContactInformation", "account:GetContactInformation", "account:PutAlternateContact",
+				"account:GetAlternateContact", "account:DeleteAlternateContact"},
+			contactsCode, parameters, properties)
+	default:
+		return "", fmt.Errorf("baseline %s cannot be delivered by StackSet", baseline)
+	}
+
+	body, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal template of baseline %s: %w", baseline, err)
+	}
+	return string(body), nil
+}
+
+// functionTemplate returns a template running code in a function as a custom resource
+// with the given properties, the function being allowed the given actions
+func functionTemplate(description string, actions []string, code string, parameters, properties map[string]interface{}) map[string]interface{} {
+	resourceProperties := map[string]interface{}{
+		"ServiceToken": map[string]interface{}{"Fn::GetAtt": []string{"Function", "Arn"}},
+	}
+	for key, value := range properties {
+		resourceProperties[key] = value
+	}
+
+	t := map[string]interface{}{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Description":              fmt.Sprintf("Landing zone baseline: %s", description),
+		"Resources": map[string]interface{}{
+			"FunctionRole": map[string]interface{}{
+				"Type": "AWS::IAM::Role",
+				"Properties": map[string]interface{}{
+					"AssumeRolePolicyDocument": map[string]interface{}{
+						"Version": "2012-10-17",
+						"Statement": []map[string]interface{}{{
+							"Effect":    "Allow",
+							"Principal": map[string]interface{}{"Service": "lambda.amazonaws.com"},
+							"Action":    "sts:AssumeRole",
+						}},
+					},
+					"ManagedPolicyArns": []interface{}{
+						map[string]interface{}{"Fn::Sub": "arn:${AWS::Partition}:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"},
+					},
+					"Policies": []map[string]interface{}{{
+						"PolicyName": "baseline",
+						"PolicyDocument": map[string]interface{}{
+							"Version": "2012-10-17",
+							"Statement": []map[string]interface{}{{
+								"Effect":   "Allow",
+								"Action":   actions,
+								"Resource": "*",
+							}},
+						},
+					}},
+				},
+			},
+			"Function": map[string]interface{}{
+				"Type": "AWS::Lambda::Function",
+				"Properties": map[string]interface{}{
+					"Runtime": functionRuntime,
+					"Handler": "index.handler",
+					"Timeout": 60,
+					"Role":    map[string]interface{}{"Fn::GetAtt": []string{"FunctionRole", "Arn"}},
+					"Code":    map[string]interface{}{"ZipFile": code},
+				},
+			},
+			"Baseline": map[string]interface{}{
+				"Type":       "Custom::Baseline",
+				"Properties": resourceProperties,
+			},
+		},
+	}
+	if len(parameters) > 0 {
+		t["Parameters"] = parameters
+	}
+	return t
+}
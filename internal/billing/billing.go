@@ -0,0 +1,266 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package billing provides the cost categories and Cost and Usage Report of the organization.
+// Version: 1.0.0
+package billing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/costexplorer"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/organizations"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Cost category rule settings
+	costCategoryRuleVersion = "CostCategoryExpression.v1"
+	costCategoryRuleType    = "REGULAR"
+	dimensionLinkedAccount  = "LINKED_ACCOUNT"
+	matchOptionEquals       = "EQUALS"
+)
+
+// Billing manages the billing resources of the management account
+type Billing struct {
+	logger   *zap.Logger
+	metrics  *metrics.Collector
+	roleName string
+	mgmt     *aws.Provider
+
+	// Account IDs of the OUs referenced by cost categories keyed by path below the
+	// root, looked up once
+	ouAccounts map[string][]string
+}
+
+// SetupBilling creates the cost categories of the organization and delivers the Cost
+// and Usage Report to the log archive account
+func SetupBilling(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("billing")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("billing")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("billing_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup billing"); err != nil {
+		return err
+	}
+
+	if cfg.Billing == nil || (len(cfg.Billing.CostCategories) == 0 && cfg.Billing.CostAndUsageReport == nil) {
+		logger.Info("no billing configured")
+		return nil
+	}
+
+	// Cost Explorer and the Cost and Usage Report are served from a single region of
+	// the partition
+	mgmt, err := aws.NewProvider(ctx, "billing-mgmt", &aws.ProviderArgs{
+		Region: pulumi.String(reportRegion(awsclient.Partition())),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create management provider for billing: %w", err)
+	}
+
+	b := &Billing{
+		logger:   logger,
+		metrics:  metrics,
+		roleName: awsclient.MemberRoleName(cfg),
+		mgmt:     mgmt,
+	}
+
+	for _, category := range cfg.Billing.CostCategories {
+		if err := b.costCategory(ctx, category, cfg); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Billing.CostAndUsageReport != nil {
+		if err := b.setupReport(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("billing setup completed successfully",
+		zap.Int("costCategories", len(cfg.Billing.CostCategories)),
+		zap.Bool("costAndUsageReport", cfg.Billing.CostAndUsageReport != nil))
+	return nil
+}
+
+// reportRegion returns the region Cost Explorer and the Cost and Usage Report are
+// managed in
+func reportRegion(partition string) string {
+	if partition == config.PartitionChina {
+		return "cn-northwest-1"
+	}
+	return "us-east-1"
+}
+
+// costCategory creates a cost category assigning the value of each rule to its
+// accounts. Rules whose OUs hold no account are skipped, since a rule must match at
+// least one account.
+func (b *Billing) costCategory(ctx *pulumi.Context, category config.CostCategoryConfig, cfg *config.LandingZoneConfig) error {
+	rules := make(costexplorer.CostCategoryRuleArray, 0, len(category.Rules))
+	for _, rule := range category.Rules {
+		accountIds, err := b.ruleAccounts(ctx, rule, cfg)
+		if err != nil {
+			return err
+		}
+		if len(accountIds) == 0 {
+			b.logger.Warn("cost category rule matches no account, skipping",
+				zap.String("costCategory", category.Name),
+				zap.String("value", rule.Value))
+			continue
+		}
+
+		rules = append(rules, &costexplorer.CostCategoryRuleArgs{
+			Type:  pulumi.String(costCategoryRuleType),
+			Value: pulumi.String(rule.Value),
+			Rule: &costexplorer.CostCategoryRuleRuleArgs{
+				Dimension: &costexplorer.CostCategoryRuleRuleDimensionArgs{
+					Key:          pulumi.String(dimensionLinkedAccount),
+					Values:       pulumi.ToStringArray(accountIds),
+					MatchOptions: pulumi.ToStringArray([]string{matchOptionEquals}),
+				},
+			},
+		})
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("no rule of cost category %s matches an account", category.Name)
+	}
+
+	args := &costexplorer.CostCategoryArgs{
+		Name:        pulumi.String(category.Name),
+		RuleVersion: pulumi.String(costCategoryRuleVersion),
+		Rules:       rules,
+		Tags:        pulumi.ToStringMap(cfg.Tags),
+	}
+	if category.DefaultValue != "" {
+		args.DefaultValue = pulumi.String(category.DefaultValue)
+	}
+
+	if _, err := costexplorer.NewCostCategory(ctx, fmt.Sprintf("cost-category-%s", category.Name), args,
+		pulumi.Provider(b.mgmt)); err != nil {
+		return fmt.Errorf("failed to create cost category %s: %w", category.Name, err)
+	}
+
+	b.metrics.IncrementCounter("cost_categories_created")
+	return nil
+}
+
+// ruleAccounts returns the sorted, unique account IDs of a rule: the listed accounts
+// and the accounts of the listed OUs
+func (b *Billing) ruleAccounts(ctx *pulumi.Context, rule config.CostCategoryRuleConfig, cfg *config.LandingZoneConfig) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, id := range rule.AccountIds {
+		seen[id] = true
+	}
+
+	for _, key := range rule.OUs {
+		accounts, err := b.accountsOf(ctx, ouName(cfg, key))
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range accounts {
+			seen[id] = true
+		}
+	}
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// accountsOf returns the IDs of the accounts of the OU of the given path and its nested
+// OUs. Configured OUs are below the root, so their path is their name.
+func (b *Billing) accountsOf(ctx *pulumi.Context, path string) ([]string, error) {
+	if b.ouAccounts == nil {
+		if err := b.lookupOUs(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	accounts, ok := b.ouAccounts[path]
+	if !ok {
+		return nil, fmt.Errorf("OU %s does not exist in the organization", path)
+	}
+	return accounts, nil
+}
+
+// lookupOUs reads the accounts of every OU of the organization, walking the OUs from
+// the root so OUs of the same name under different parents keep apart
+func (b *Billing) lookupOUs(ctx *pulumi.Context) error {
+	org, err := organizations.LookupOrganization(ctx, pulumi.Provider(b.mgmt))
+	if err != nil {
+		return fmt.Errorf("failed to look up organization: %w", err)
+	}
+	if len(org.Roots) == 0 {
+		return fmt.Errorf("organization has no root")
+	}
+
+	b.ouAccounts = make(map[string][]string)
+	return b.lookupChildren(ctx, org.Roots[0].Id, "")
+}
+
+// lookupChildren reads the accounts of the OUs below a parent of the given path
+func (b *Billing) lookupChildren(ctx *pulumi.Context, parentId, parentPath string) error {
+	children, err := organizations.GetOrganizationalUnits(ctx,
+		&organizations.GetOrganizationalUnitsArgs{ParentId: parentId},
+		pulumi.Provider(b.mgmt))
+	if err != nil {
+		return fmt.Errorf("failed to list organizational units of %s: %w", parentId, err)
+	}
+
+	for _, ou := range children.Children {
+		path := ou.Name
+		if parentPath != "" {
+			path = parentPath + "/" + ou.Name
+		}
+
+		descendants, err := organizations.GetOrganizationalUnitDescendantAccounts(ctx,
+			&organizations.GetOrganizationalUnitDescendantAccountsArgs{ParentId: ou.Id},
+			pulumi.Provider(b.mgmt))
+		if err != nil {
+			return fmt.Errorf("failed to list accounts of OU %s: %w", path, err)
+		}
+
+		ids := make([]string, 0, len(descendants.Accounts))
+		for _, account := range descendants.Accounts {
+			ids = append(ids, account.Id)
+		}
+		b.ouAccounts[path] = ids
+
+		if err := b.lookupChildren(ctx, ou.Id, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ouName returns the name of a configured OU
+func ouName(cfg *config.LandingZoneConfig, key string) string {
+	if ou, ok := cfg.OrganizationUnits[key]; ok && ou != nil && ou.Name != "" {
+		return ou.Name
+	}
+	return key
+}
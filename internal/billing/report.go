@@ -0,0 +1,323 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package billing
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/athena"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cur"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/glue"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Cost and Usage Report defaults
+	DefaultCrawlerSchedule = "cron(0 6 * * ? *)"
+
+	// Report settings required by the Athena integration
+	reportTimeUnit       = "DAILY"
+	reportFormat         = "Parquet"
+	reportCompression    = "Parquet"
+	reportVersioning     = "OVERWRITE_REPORT"
+	reportArtifactAthena = "ATHENA"
+	reportSchemaResource = "RESOURCES"
+
+	// Prefix of the Athena query results in the report bucket
+	athenaResultsPrefix = "athena-results"
+
+	// Services writing the report and crawling it
+	billingReportsService = "billingreports"
+	glueService           = "glue"
+)
+
+// databaseNameRE matches the characters not allowed in Glue database names
+var databaseNameRE = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// setupReport creates the report bucket in the log archive account, the report
+// definition in the management account and the Glue catalog and Athena workgroup the
+// report is queried with
+func (b *Billing) setupReport(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	report := cfg.Billing.CostAndUsageReport
+	region := cfg.LogBucketRegion()
+
+	logArchive, err := aws.NewProvider(ctx, "billing-log-archive", &aws.ProviderArgs{
+		Region: pulumi.String(region),
+		AssumeRole: &aws.ProviderAssumeRoleArgs{
+			RoleArn:     pulumi.String(awsclient.RoleArn(cfg.LogArchiveAccountId, b.roleName)),
+			SessionName: pulumi.String(awsclient.SessionName),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create log archive provider for the Cost and Usage Report: %w", err)
+	}
+
+	bucketPolicy, err := b.reportBucket(ctx, cfg, logArchive)
+	if err != nil {
+		return err
+	}
+
+	args := &cur.ReportDefinitionArgs{
+		ReportName:               pulumi.String(report.ReportName),
+		TimeUnit:                 pulumi.String(reportTimeUnit),
+		Format:                   pulumi.String(reportFormat),
+		Compression:              pulumi.String(reportCompression),
+		ReportVersioning:         pulumi.String(reportVersioning),
+		AdditionalArtifacts:      pulumi.ToStringArray([]string{reportArtifactAthena}),
+		AdditionalSchemaElements: pulumi.ToStringArray([]string{reportSchemaResource}),
+		S3Bucket:                 pulumi.String(report.BucketName),
+		S3Region:                 pulumi.String(region),
+		Tags:                     pulumi.ToStringMap(cfg.Tags),
+	}
+	if report.Prefix != "" {
+		args.S3Prefix = pulumi.String(report.Prefix)
+	}
+
+	if _, err := cur.NewReportDefinition(ctx, "cost-and-usage-report", args,
+		pulumi.Provider(b.mgmt), pulumi.DependsOn([]pulumi.Resource{bucketPolicy})); err != nil {
+		return fmt.Errorf("failed to create Cost and Usage Report %s: %w", report.ReportName, err)
+	}
+
+	if err := b.reportCatalog(ctx, cfg, logArchive); err != nil {
+		return err
+	}
+
+	b.logger.Info("Cost and Usage Report configured",
+		zap.String("report", report.ReportName),
+		zap.String("bucket", report.BucketName),
+		zap.String("region", region))
+	return nil
+}
+
+// reportBucket creates the bucket the report is delivered to. Only the billing
+// service acting for the management account may write to it.
+func (b *Billing) reportBucket(ctx *pulumi.Context, cfg *config.LandingZoneConfig, provider *aws.Provider) (*s3.BucketPolicy, error) {
+	report := cfg.Billing.CostAndUsageReport
+
+	bucket, err := s3.NewBucketV2(ctx, "cost-and-usage-report-bucket", &s3.BucketV2Args{
+		Bucket: pulumi.String(report.BucketName),
+		Tags:   pulumi.ToStringMap(cfg.Tags),
+	}, pulumi.Provider(provider), pulumi.Protect(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cost and Usage Report bucket: %w", err)
+	}
+
+	if _, err := s3.NewBucketPublicAccessBlock(ctx, "cost-and-usage-report-public-access", &s3.BucketPublicAccessBlockArgs{
+		Bucket:                bucket.ID(),
+		BlockPublicAcls:       pulumi.Bool(true),
+		BlockPublicPolicy:     pulumi.Bool(true),
+		IgnorePublicAcls:      pulumi.Bool(true),
+		RestrictPublicBuckets: pulumi.Bool(true),
+	}, pulumi.Provider(provider)); err != nil {
+		return nil, fmt.Errorf("failed to block public access to Cost and Usage Report bucket: %w", err)
+	}
+
+	if _, err := s3.NewBucketServerSideEncryptionConfigurationV2(ctx, "cost-and-usage-report-encryption",
+		&s3.BucketServerSideEncryptionConfigurationV2Args{
+			Bucket: bucket.ID(),
+			Rules: s3.BucketServerSideEncryptionConfigurationV2RuleArray{
+				&s3.BucketServerSideEncryptionConfigurationV2RuleArgs{
+					ApplyServerSideEncryptionByDefault: &s3.BucketServerSideEncryptionConfigurationV2RuleApplyServerSideEncryptionByDefaultArgs{
+						SseAlgorithm: pulumi.String("AES256"),
+					},
+				},
+			},
+		}, pulumi.Provider(provider)); err != nil {
+		return nil, fmt.Errorf("failed to encrypt Cost and Usage Report bucket: %w", err)
+	}
+
+	document, err := reportBucketPolicy(awsclient.BucketArn(report.BucketName), cfg.ManagementAccountId)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := s3.NewBucketPolicy(ctx, "cost-and-usage-report-bucket-policy", &s3.BucketPolicyArgs{
+		Bucket: bucket.ID(),
+		Policy: pulumi.String(document),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach Cost and Usage Report bucket policy: %w", err)
+	}
+	return policy, nil
+}
+
+// reportBucketPolicy returns the policy of the report bucket, allowing the billing
+// service to deliver the reports of the management account
+func reportBucketPolicy(bucketArn, managementAccountId string) (string, error) {
+	condition := map[string]interface{}{
+		"StringEquals": map[string]interface{}{
+			"aws:SourceAccount": managementAccountId,
+			"aws:SourceArn": fmt.Sprintf("arn:%s:cur:%s:%s:definition/*",
+				awsclient.Partition(), reportRegion(awsclient.Partition()), managementAccountId),
+		},
+	}
+
+	document := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Sid":       "AllowBillingReportsBucketCheck",
+				"Effect":    "Allow",
+				"Principal": map[string]interface{}{"Service": awsclient.ServicePrincipal(billingReportsService)},
+				"Action":    []string{"s3:GetBucketAcl", "s3:GetBucketPolicy"},
+				"Resource":  bucketArn,
+				"Condition": condition,
+			},
+			{
+				"Sid":       "AllowBillingReportsPutObject",
+				"Effect":    "Allow",
+				"Principal": map[string]interface{}{"Service": awsclient.ServicePrincipal(billingReportsService)},
+				"Action":    "s3:PutObject",
+				"Resource":  bucketArn + "/*",
+				"Condition": condition,
+			},
+			{
+				"Sid":       "DenyInsecureTransport",
+				"Effect":    "Deny",
+				"Principal": "*",
+				"Action":    "s3:*",
+				"Resource":  []string{bucketArn, bucketArn + "/*"},
+				"Condition": map[string]interface{}{
+					"Bool": map[string]interface{}{"aws:SecureTransport": "false"},
+				},
+			},
+		},
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal Cost and Usage Report bucket policy: %w", err)
+	}
+	return string(data), nil
+}
+
+// reportCatalog creates the Glue database of the report, the crawler keeping its
+// tables current and, when configured, the Athena workgroup it is queried with
+func (b *Billing) reportCatalog(ctx *pulumi.Context, cfg *config.LandingZoneConfig, provider *aws.Provider) error {
+	report := cfg.Billing.CostAndUsageReport
+	bucketArn := awsclient.BucketArn(report.BucketName)
+
+	database, err := glue.NewCatalogDatabase(ctx, "cost-and-usage-report-database", &glue.CatalogDatabaseArgs{
+		Name:        pulumi.String(DatabaseName(report)),
+		Description: pulumi.String(fmt.Sprintf("Cost and Usage Report %s", report.ReportName)),
+		Tags:        pulumi.ToStringMap(cfg.Tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return fmt.Errorf("failed to create Cost and Usage Report database: %w", err)
+	}
+
+	role, err := iam.NewRole(ctx, "cost-and-usage-report-crawler-role", &iam.RoleArgs{
+		Name:        component.Name(cfg.ResourceNames().Name(config.RoleReportCrawler, cfg.LogBucketRegion())),
+		Description: pulumi.String("Crawls the Cost and Usage Report into the Glue catalog"),
+		AssumeRolePolicy: pulumi.String(fmt.Sprintf(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Allow",
+				"Principal": {
+					"Service": "%s"
+				},
+				"Action": "sts:AssumeRole"
+			}]
+		}`, awsclient.ServicePrincipal(glueService))),
+		ManagedPolicyArns: pulumi.ToStringArray([]string{awsclient.PolicyArn("service-role/AWSGlueServiceRole")}),
+		Tags:              pulumi.ToStringMap(cfg.Tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return fmt.Errorf("failed to create Cost and Usage Report crawler role: %w", err)
+	}
+
+	readPolicy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"s3:GetObject", "s3:ListBucket"},
+				"Resource": []string{bucketArn, bucketArn + "/*"},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Cost and Usage Report crawler policy: %w", err)
+	}
+
+	rolePolicy, err := iam.NewRolePolicy(ctx, "cost-and-usage-report-crawler-read", &iam.RolePolicyArgs{
+		Role:   role.ID(),
+		Policy: pulumi.String(string(readPolicy)),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return fmt.Errorf("failed to attach Cost and Usage Report crawler policy: %w", err)
+	}
+
+	schedule := report.CrawlerSchedule
+	if schedule == "" {
+		schedule = DefaultCrawlerSchedule
+	}
+
+	if _, err := glue.NewCrawler(ctx, "cost-and-usage-report-crawler", &glue.CrawlerArgs{
+		DatabaseName: database.Name,
+		Role:         role.Arn,
+		Schedule:     pulumi.String(schedule),
+		S3Targets: glue.CrawlerS3TargetArray{
+			&glue.CrawlerS3TargetArgs{
+				Path:       pulumi.String(reportPath(report)),
+				Exclusions: pulumi.ToStringArray([]string{"**.json", "**.yml", "**.sql", "**.csv", "**.gz", "**.zip"}),
+			},
+		},
+		SchemaChangePolicy: &glue.CrawlerSchemaChangePolicyArgs{
+			DeleteBehavior: pulumi.String("DELETE_FROM_DATABASE"),
+			UpdateBehavior: pulumi.String("UPDATE_IN_DATABASE"),
+		},
+		Tags: pulumi.ToStringMap(cfg.Tags),
+	}, pulumi.Provider(provider), pulumi.DependsOn([]pulumi.Resource{rolePolicy})); err != nil {
+		return fmt.Errorf("failed to create Cost and Usage Report crawler: %w", err)
+	}
+
+	if report.AthenaWorkgroup != "" {
+		if _, err := athena.NewWorkgroup(ctx, "cost-and-usage-report-workgroup", &athena.WorkgroupArgs{
+			Name:        pulumi.String(report.AthenaWorkgroup),
+			Description: pulumi.String(fmt.Sprintf("Queries of the Cost and Usage Report %s", report.ReportName)),
+			Configuration: &athena.WorkgroupConfigurationArgs{
+				EnforceWorkgroupConfiguration: pulumi.Bool(true),
+				ResultConfiguration: &athena.WorkgroupConfigurationResultConfigurationArgs{
+					OutputLocation: pulumi.String(fmt.Sprintf("s3://%s/%s/", report.BucketName, athenaResultsPrefix)),
+					EncryptionConfiguration: &athena.WorkgroupConfigurationResultConfigurationEncryptionConfigurationArgs{
+						EncryptionOption: pulumi.String("SSE_S3"),
+					},
+				},
+			},
+			Tags: pulumi.ToStringMap(cfg.Tags),
+		}, pulumi.Provider(provider)); err != nil {
+			return fmt.Errorf("failed to create Athena workgroup %s: %w", report.AthenaWorkgroup, err)
+		}
+	}
+
+	return nil
+}
+
+// DatabaseName returns the Glue database of the report: the configured one, or the
+// report name in lower case with every other character replaced by an underscore
+func DatabaseName(report *config.CostAndUsageReportConfig) string {
+	if report.DatabaseName != "" {
+		return report.DatabaseName
+	}
+	return "cur_" + strings.Trim(databaseNameRE.ReplaceAllString(strings.ToLower(report.ReportName), "_"), "_")
+}
+
+// reportPath returns the S3 location of the Parquet files of the report, delivered
+// under <prefix>/<report>/<report>/ when Athena integration is enabled
+func reportPath(report *config.CostAndUsageReportConfig) string {
+	return fmt.Sprintf("s3://%s/", path.Join(report.BucketName, report.Prefix, report.ReportName, report.ReportName))
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package blueprints provides the Account Factory Customization blueprints of Control Tower.
+// Version: 1.0.0
+package blueprints
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/servicecatalog"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Product and artifact type of CloudFormation blueprints
+	productTypeCloudFormation = "CLOUD_FORMATION_TEMPLATE"
+
+	// Principal type of the blueprint access role
+	principalTypeIAM = "IAM"
+
+	// Role of the management account Control Tower reads the blueprints as
+	controlTowerAdminRole = "service-role/AWSControlTowerAdmin"
+
+	// Managed policy of the blueprint access role
+	serviceCatalogAdminPolicy = "AWSServiceCatalogAdminFullAccess"
+
+	// Owner of blueprints configured without one
+	defaultOwner = "Platform"
+)
+
+// Blueprints registers the blueprint products in the hub account
+type Blueprints struct {
+	logger  *zap.Logger
+	metrics *metrics.Collector
+	cfg     *config.LandingZoneConfig
+}
+
+// SetupBlueprints registers the configured blueprints as products of a Service Catalog
+// portfolio in the hub account, shared with the role Control Tower reads blueprints
+// through. Accounts are customized by Account Factory Customization itself, which
+// applies a blueprint when an account is vended or updated with it, so nothing is
+// deployed to the accounts outside of it.
+func SetupBlueprints(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("blueprints")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("blueprints")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("blueprints_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup blueprints"); err != nil {
+		return err
+	}
+
+	afc := cfg.AccountFactoryCustomization
+	if afc == nil {
+		logger.Info("no account factory customization configured")
+		return nil
+	}
+
+	b := &Blueprints{
+		logger:  logger,
+		metrics: metrics,
+		cfg:     cfg,
+	}
+
+	hub, err := b.hubProvider(ctx, afc.HubAccountId)
+	if err != nil {
+		return err
+	}
+
+	portfolio, err := b.portfolio(ctx, afc, hub)
+	if err != nil {
+		return err
+	}
+
+	for _, blueprint := range afc.Blueprints {
+		if err := b.registerBlueprint(ctx, blueprint, portfolio, hub); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("account factory customization setup completed successfully",
+		zap.String("hubAccount", afc.HubAccountId),
+		zap.Int("blueprints", len(afc.Blueprints)))
+	return nil
+}
+
+// hubProvider returns the provider of the hub account in the home region
+func (b *Blueprints) hubProvider(ctx *pulumi.Context, hubAccountId string) (*aws.Provider, error) {
+	region := b.cfg.HomeRegion
+	if region == "" && len(b.cfg.GovernedRegions) > 0 {
+		region = b.cfg.GovernedRegions[0]
+	}
+
+	args := &aws.ProviderArgs{
+		Region: pulumi.String(region),
+	}
+	if hubAccountId != b.cfg.ManagementAccountId {
+		args.AssumeRole = &aws.ProviderAssumeRoleArgs{
+			RoleArn:     pulumi.String(awsclient.RoleArn(hubAccountId, awsclient.MemberRoleName(b.cfg))),
+			SessionName: pulumi.String(awsclient.SessionName),
+		}
+	}
+
+	provider, err := aws.NewProvider(ctx, "blueprints-hub", args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create provider for blueprint hub account %s: %w", hubAccountId, err)
+	}
+	return provider, nil
+}
+
+// portfolio creates the portfolio of the blueprints and the role Control Tower reads
+// them through, trusted by the Control Tower administration role of the management
+// account
+func (b *Blueprints) portfolio(ctx *pulumi.Context, afc *config.AccountFactoryCustomizationConfig, hub *aws.Provider) (*servicecatalog.Portfolio, error) {
+	role, err := iam.NewRole(ctx, "blueprint-access-role", &iam.RoleArgs{
+		Name:        pulumi.String(config.BlueprintAccessRoleName),
+		Description: pulumi.String("Control Tower access to the Account Factory Customization blueprints"),
+		AssumeRolePolicy: pulumi.String(fmt.Sprintf(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Allow",
+				"Principal": {
+					"AWS": "%s"
+				},
+				"Action": "sts:AssumeRole"
+			}]
+		}`, awsclient.RoleArn(b.cfg.ManagementAccountId, controlTowerAdminRole))),
+		Tags: pulumi.ToStringMap(b.cfg.Tags),
+	}, pulumi.Provider(hub))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blueprint access role: %w", err)
+	}
+
+	if _, err := iam.NewRolePolicyAttachment(ctx, "blueprint-access-role-catalog", &iam.RolePolicyAttachmentArgs{
+		Role:      role.Name,
+		PolicyArn: pulumi.String(awsclient.PolicyArn(serviceCatalogAdminPolicy)),
+	}, pulumi.Provider(hub)); err != nil {
+		return nil, fmt.Errorf("failed to attach policy to blueprint access role: %w", err)
+	}
+
+	portfolio, err := servicecatalog.NewPortfolio(ctx, "blueprint-portfolio", &servicecatalog.PortfolioArgs{
+		Name:         pulumi.String(afc.Portfolio()),
+		Description:  pulumi.String("Blueprints of Control Tower Account Factory Customization"),
+		ProviderName: pulumi.String(defaultOwner),
+		Tags:         pulumi.ToStringMap(b.cfg.Tags),
+	}, pulumi.Provider(hub))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blueprint portfolio: %w", err)
+	}
+
+	if _, err := servicecatalog.NewPrincipalPortfolioAssociation(ctx, "blueprint-portfolio-access", &servicecatalog.PrincipalPortfolioAssociationArgs{
+		PortfolioId:   portfolio.ID(),
+		PrincipalArn:  role.Arn,
+		PrincipalType: pulumi.String(principalTypeIAM),
+	}, pulumi.Provider(hub)); err != nil {
+		return nil, fmt.Errorf("failed to grant access to blueprint portfolio: %w", err)
+	}
+	return portfolio, nil
+}
+
+// registerBlueprint creates the product of a blueprint in the portfolio. A new version
+// adds a provisioning artifact Control Tower offers when updating accounts.
+func (b *Blueprints) registerBlueprint(ctx *pulumi.Context, blueprint config.BlueprintConfig, portfolio *servicecatalog.Portfolio, hub *aws.Provider) error {
+	owner := blueprint.Owner
+	if owner == "" {
+		owner = defaultOwner
+	}
+
+	product, err := servicecatalog.NewProduct(ctx, fmt.Sprintf("blueprint-%s", blueprint.Name), &servicecatalog.ProductArgs{
+		Name:        pulumi.String(blueprint.Name),
+		Description: pulumi.String(blueprint.Description),
+		Owner:       pulumi.String(owner),
+		Type:        pulumi.String(productTypeCloudFormation),
+		ProvisioningArtifactParameters: &servicecatalog.ProductProvisioningArtifactParametersArgs{
+			Name:        pulumi.String(blueprint.Version),
+			TemplateUrl: pulumi.String(blueprint.TemplateURL),
+			Type:        pulumi.String(productTypeCloudFormation),
+		},
+		Tags: pulumi.ToStringMap(b.cfg.Tags),
+	}, pulumi.Provider(hub))
+	if err != nil {
+		return fmt.Errorf("failed to create blueprint product %s: %w", blueprint.Name, err)
+	}
+
+	if _, err := servicecatalog.NewProductPortfolioAssociation(ctx, fmt.Sprintf("blueprint-%s-portfolio", blueprint.Name),
+		&servicecatalog.ProductPortfolioAssociationArgs{
+			PortfolioId: portfolio.ID(),
+			ProductId:   product.ID(),
+		}, pulumi.Provider(hub)); err != nil {
+		return fmt.Errorf("failed to add blueprint %s to portfolio: %w", blueprint.Name, err)
+	}
+
+	b.metrics.IncrementCounter("blueprints_registered")
+	return nil
+}
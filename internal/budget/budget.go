@@ -0,0 +1,245 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package budget provides the time budget of a deployment: the deadline of the run, the
+// timeouts of the updates of its modules and the share of the budget each consumed.
+// Version: 1.0.0
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+	"go.uber.org/zap"
+)
+
+// Outcomes of an update
+const (
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusTimedOut  = "timed-out"
+	StatusSkipped   = "skipped"
+)
+
+// ErrExhausted is returned for updates not started because the deadline has passed
+var ErrExhausted = errors.New("deployment deadline exceeded")
+
+// Run is the update of a module, or of the modules of a component, within the budget
+type Run struct {
+	Name      string        `json:"name"`
+	Modules   []string      `json:"modules"`
+	Status    string        `json:"status"`
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+	Timeout   time.Duration `json:"timeout,omitempty"`
+
+	// Share is the part of the budget of the run the update consumed
+	Share float64 `json:"share"`
+	Error string  `json:"error,omitempty"`
+}
+
+// Record holds the updates of a run and the time each consumed
+type Record struct {
+	RunID     string        `json:"runId"`
+	StartedAt time.Time     `json:"startedAt"`
+	Deadline  time.Time     `json:"deadline"`
+	Duration  time.Duration `json:"duration"`
+	Exhausted bool          `json:"exhausted"`
+	Runs      []Run         `json:"runs"`
+}
+
+// Budget bounds the updates of a run by the deadline and the module timeouts
+type Budget struct {
+	logger   *zap.Logger
+	metrics  *metrics.Collector
+	cfg      *config.TimeoutsConfig
+	start    time.Time
+	deadline time.Time
+
+	mutex sync.Mutex
+	runs  []Run
+}
+
+// New creates the budget of a run, ending at the deadline of ctx or, without one, after
+// the configured deadline
+func New(ctx context.Context, cfg *config.TimeoutsConfig, fallback time.Duration) (*Budget, error) {
+	logger, err := logging.NewLogger("budget")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("budget")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = start.Add(cfg.Deadline(fallback))
+	}
+
+	return &Budget{
+		logger:   logger,
+		metrics:  metrics,
+		cfg:      cfg,
+		start:    start,
+		deadline: deadline,
+	}, nil
+}
+
+// PerModule reports whether the modules are updated one at a time
+func (b *Budget) PerModule() bool {
+	return b.cfg.PerModule()
+}
+
+// Grace returns the time an interrupted update is given to stop
+func (b *Budget) Grace() time.Duration {
+	return b.cfg.Grace()
+}
+
+// Remaining returns the time left before the deadline
+func (b *Budget) Remaining() time.Duration {
+	return time.Until(b.deadline)
+}
+
+// Timeout returns the time the update of the given modules may take: the sum of their
+// timeouts, or zero when one of them is only bounded by the deadline
+func (b *Budget) Timeout(modules []string) time.Duration {
+	var total time.Duration
+	for _, module := range modules {
+		timeout := b.cfg.ModuleTimeout(module)
+		if timeout == 0 {
+			return 0
+		}
+		total += timeout
+	}
+	return total
+}
+
+// Run runs the update of the given modules with a context ending at their timeout or
+// at the deadline, whichever comes first, and records the time it consumed. Updates
+// are not started once the deadline has passed.
+func (b *Budget) Run(ctx context.Context, name string, modules []string, update func(context.Context) error) error {
+	run := Run{Name: name, Modules: modules, Timeout: b.Timeout(modules)}
+	if b.Remaining() <= 0 {
+		run.Status = StatusSkipped
+		b.record(run)
+		return fmt.Errorf("%w, update of %s not started", ErrExhausted, name)
+	}
+
+	deadline := b.deadline
+	run.StartedAt = time.Now()
+	if run.Timeout > 0 && run.StartedAt.Add(run.Timeout).Before(deadline) {
+		deadline = run.StartedAt.Add(run.Timeout)
+	}
+	runCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	b.logger.Info("starting update within budget",
+		zap.String("update", name),
+		zap.Strings("modules", modules),
+		zap.Duration("available", time.Until(deadline)))
+
+	err := update(runCtx)
+	run.Duration = time.Since(run.StartedAt)
+	switch {
+	case err == nil:
+		run.Status = StatusCompleted
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		run.Status = StatusTimedOut
+		if deadline.Equal(b.deadline) {
+			err = fmt.Errorf("%w during the update of %s: %v", ErrExhausted, name, err)
+		} else {
+			err = fmt.Errorf("update of %s exceeded its timeout of %s: %w", name, run.Timeout, err)
+		}
+	default:
+		run.Status = StatusFailed
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	b.record(run)
+	return err
+}
+
+// record adds a run to the budget and reports the time it consumed
+func (b *Budget) record(run Run) {
+	available := b.deadline.Sub(b.start)
+	if run.Timeout > 0 {
+		available = run.Timeout
+	}
+	if available > 0 {
+		run.Share = run.Duration.Seconds() / available.Seconds()
+	}
+
+	b.mutex.Lock()
+	b.runs = append(b.runs, run)
+	b.mutex.Unlock()
+
+	name := metricName(run.Name)
+	b.metrics.RecordDuration(name+"_duration", run.Duration)
+	b.metrics.SetGauge(name+"_share", run.Share)
+	if run.Status == StatusTimedOut {
+		b.metrics.IncrementCounter("updates_timed_out")
+	}
+
+	b.logger.Info("update budget consumed",
+		zap.String("update", run.Name),
+		zap.String("status", run.Status),
+		zap.Duration("duration", run.Duration),
+		zap.Float64("share", run.Share))
+}
+
+// Record returns the record of the updates run so far
+func (b *Budget) Record() Record {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	record := Record{
+		RunID:     runid.ID(),
+		StartedAt: b.start.UTC(),
+		Deadline:  b.deadline.UTC(),
+		Duration:  time.Since(b.start),
+		Exhausted: b.Remaining() <= 0,
+		Runs:      append([]Run(nil), b.runs...),
+	}
+	return record
+}
+
+// Finish returns the record of the updates of the run once it ends, and reports the
+// update that consumed the most time
+func (b *Budget) Finish() *Record {
+	record := b.Record()
+
+	var largest Run
+	for _, run := range record.Runs {
+		if run.Duration > largest.Duration {
+			largest = run
+		}
+	}
+	b.metrics.RecordDuration("run_duration", record.Duration)
+	b.logger.Info("deployment budget consumed",
+		zap.Duration("duration", record.Duration),
+		zap.Time("deadline", record.Deadline),
+		zap.Bool("exhausted", record.Exhausted),
+		zap.String("largestUpdate", largest.Name),
+		zap.Duration("largestDuration", largest.Duration))
+	return &record
+}
+
+// metricName returns the prefix of the metrics of an update, whose name may hold
+// characters Prometheus does not accept
+func metricName(update string) string {
+	return strings.NewReplacer("-", "_", ",", "_").Replace(update)
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package canary provides the validation probes of the canary of the guardrails: API
+// calls made in the canary account whose outcome shows whether the organization
+// policies allow or deny them, before the policies are rolled out to the organization.
+// Version: 1.0.0
+package canary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/configservice"
+	"github.com/aws/aws-sdk-go-v2/service/guardduty"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// Name of the resources the mutating probes target. They do not exist, so an
+	// allowed call fails with a not found error and changes nothing.
+	probeResourceName = "aws-organization-canary-probe"
+
+	// Identifiers of the detector and key the mutating probes target
+	probeDetectorId = "00000000000000000000000000000000"
+	probeKeyId      = "00000000-0000-0000-0000-000000000000"
+
+	// Outcome of a probe whose call failed before the guardrails were evaluated, or was
+	// refused by something other than a service control policy
+	outcomeError = "error"
+
+	// scpDenial is named by the message of the access errors caused by an SCP
+	scpDenial = "service control policy"
+)
+
+// Result is the outcome of a probe
+type Result struct {
+	Action  string `json:"action"`
+	Region  string `json:"region"`
+	Expect  string `json:"expect"`
+	Outcome string `json:"outcome"`
+	Passed  bool   `json:"passed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Prober runs the probes of the canary in the canary account
+type Prober struct {
+	logger  *zap.Logger
+	metrics *metrics.Collector
+	cfg     *config.CanaryConfig
+	aws     aws.Config
+	region  string
+}
+
+// NewProber creates a prober assuming the canary role in the canary account from the
+// credentials of the management account. It fails when the role cannot be assumed.
+func NewProber(ctx context.Context, lz *config.LandingZoneConfig) (*Prober, error) {
+	logger, err := logging.NewLogger("canary")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("canary")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	cfg := lz.Canary
+	if cfg == nil {
+		return nil, fmt.Errorf("no canary configured")
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roleName := cfg.RoleName
+	if roleName == "" {
+		roleName = awsclient.MemberRoleName(lz)
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = lz.HomeRegion
+	}
+	if region == "" {
+		region = base.Region
+	}
+
+	// The role is assumed up front, so a refused assumption fails the canary instead of
+	// passing for the denial of every probe
+	probe := awsclient.AssumeRole(base, cfg.ProbeAccount(), roleName)
+	if _, err := sts.NewFromConfig(probe).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err != nil {
+		return nil, fmt.Errorf("failed to assume %s in canary account %s: %w", roleName, cfg.ProbeAccount(), err)
+	}
+
+	return &Prober{
+		logger:  logger,
+		metrics: metrics,
+		cfg:     cfg,
+		aws:     probe,
+		region:  region,
+	}, nil
+}
+
+// Run runs the probes one after the other and returns their outcomes
+func (p *Prober) Run(ctx context.Context) []Result {
+	start := time.Now()
+	defer func() {
+		p.metrics.RecordDuration("canary_probes", time.Since(start))
+	}()
+
+	results := make([]Result, 0, len(p.cfg.Probes))
+	for _, probe := range p.cfg.Probes {
+		region := probe.Region
+		if region == "" {
+			region = p.region
+		}
+
+		result := Result{Action: probe.Action, Region: region, Expect: probe.Expect}
+		err := p.call(ctx, probe.Action, region)
+		result.Outcome = outcome(err)
+		result.Passed = result.Outcome == probe.Expect
+		if err != nil && result.Outcome != config.ProbeAllowed {
+			result.Error = err.Error()
+		}
+
+		if !result.Passed {
+			p.metrics.IncrementCounter("canary_probes_failed")
+			p.logger.Warn("canary probe failed",
+				zap.String("action", result.Action),
+				zap.String("expect", result.Expect),
+				zap.String("outcome", result.Outcome))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// call makes the API call of an action in the canary account
+func (p *Prober) call(ctx context.Context, action, region string) error {
+	cfg := p.aws.Copy()
+	cfg.Region = region
+	name := aws.String(probeResourceName)
+
+	var err error
+	switch action {
+	case "cloudtrail:DescribeTrails":
+		_, err = cloudtrail.NewFromConfig(cfg).DescribeTrails(ctx, &cloudtrail.DescribeTrailsInput{})
+	case "cloudtrail:StopLogging":
+		_, err = cloudtrail.NewFromConfig(cfg).StopLogging(ctx, &cloudtrail.StopLoggingInput{Name: name})
+	case "cloudtrail:DeleteTrail":
+		_, err = cloudtrail.NewFromConfig(cfg).DeleteTrail(ctx, &cloudtrail.DeleteTrailInput{Name: name})
+	case "config:DescribeConfigurationRecorders":
+		_, err = configservice.NewFromConfig(cfg).DescribeConfigurationRecorders(ctx,
+			&configservice.DescribeConfigurationRecordersInput{})
+	case "config:StopConfigurationRecorder":
+		_, err = configservice.NewFromConfig(cfg).StopConfigurationRecorder(ctx,
+			&configservice.StopConfigurationRecorderInput{ConfigurationRecorderName: name})
+	case "config:DeleteConfigurationRecorder":
+		_, err = configservice.NewFromConfig(cfg).DeleteConfigurationRecorder(ctx,
+			&configservice.DeleteConfigurationRecorderInput{ConfigurationRecorderName: name})
+	case "guardduty:ListDetectors":
+		_, err = guardduty.NewFromConfig(cfg).ListDetectors(ctx, &guardduty.ListDetectorsInput{})
+	case "guardduty:DeleteDetector":
+		_, err = guardduty.NewFromConfig(cfg).DeleteDetector(ctx,
+			&guardduty.DeleteDetectorInput{DetectorId: aws.String(probeDetectorId)})
+	case "iam:ListRoles":
+		_, err = iam.NewFromConfig(cfg).ListRoles(ctx, &iam.ListRolesInput{})
+	case "iam:DeleteRole":
+		_, err = iam.NewFromConfig(cfg).DeleteRole(ctx, &iam.DeleteRoleInput{RoleName: name})
+	case "kms:ListKeys":
+		_, err = kms.NewFromConfig(cfg).ListKeys(ctx, &kms.ListKeysInput{})
+	case "kms:ScheduleKeyDeletion":
+		_, err = kms.NewFromConfig(cfg).ScheduleKeyDeletion(ctx,
+			&kms.ScheduleKeyDeletionInput{KeyId: aws.String(probeKeyId)})
+	case "lambda:ListFunctions":
+		_, err = lambda.NewFromConfig(cfg).ListFunctions(ctx, &lambda.ListFunctionsInput{})
+	case "logs:DeleteLogGroup":
+		_, err = cloudwatchlogs.NewFromConfig(cfg).DeleteLogGroup(ctx,
+			&cloudwatchlogs.DeleteLogGroupInput{LogGroupName: name})
+	case "s3:ListAllMyBuckets":
+		_, err = s3.NewFromConfig(cfg).ListBuckets(ctx, &s3.ListBucketsInput{})
+	case "ssm:GetParameter":
+		_, err = ssm.NewFromConfig(cfg).GetParameter(ctx, &ssm.GetParameterInput{Name: name})
+	default:
+		return fmt.Errorf("unknown canary probe action %q", action)
+	}
+	return err
+}
+
+// outcome returns the outcome of a call: denied when the service refused it for a
+// service control policy, allowed when it succeeded or failed past the authorization,
+// such as on a missing resource. Credential errors, such as a refused role assumption,
+// and access errors not caused by an SCP are errors.
+func outcome(err error) string {
+	if err == nil {
+		return config.ProbeAllowed
+	}
+	if credentialError(err) {
+		return outcomeError
+	}
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return outcomeError
+	}
+	if errors.Is(err, awsclient.ErrAccessDenied) {
+		if strings.Contains(strings.ToLower(apiErr.ErrorMessage()), scpDenial) {
+			return config.ProbeDenied
+		}
+		return outcomeError
+	}
+	return config.ProbeAllowed
+}
+
+// credentialError reports whether a call failed on an STS operation, which the
+// credentials of the canary role are retrieved with, rather than on its own service
+func credentialError(err error) bool {
+	for err != nil {
+		var opErr *smithy.OperationError
+		if !errors.As(err, &opErr) {
+			return false
+		}
+		if opErr.ServiceID == sts.ServiceID {
+			return true
+		}
+		err = opErr.Err
+	}
+	return false
+}
+
+// Failed returns the results of the probes without the expected outcome
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, result := range results {
+		if !result.Passed {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Write writes the results as a table or as JSON
+func Write(w io.Writer, format string, results []Result) error {
+	switch format {
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return fmt.Errorf("failed to encode canary probes: %w", err)
+		}
+		return nil
+	case report.FormatText:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ACTION\tREGION\tEXPECT\tOUTCOME\tRESULT")
+		for _, result := range results {
+			status := "pass"
+			if !result.Passed {
+				status = "FAIL"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				result.Action, result.Region, result.Expect, result.Outcome, status)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
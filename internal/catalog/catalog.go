@@ -0,0 +1,250 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package catalog provides the Service Catalog portfolios shared with the organization.
+// Version: 1.0.0
+package catalog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/policies"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/servicecatalog"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Product and artifact type of CloudFormation products
+	productTypeCloudFormation = "CLOUD_FORMATION_TEMPLATE"
+
+	// Types of organizational shares
+	shareTypeAccount      = "ACCOUNT"
+	shareTypeOrganization = "ORGANIZATION"
+	shareTypeOU           = "ORGANIZATIONAL_UNIT"
+
+	// Principal type of roles given access by name in the recipient accounts
+	principalTypeIAMPattern = "IAM_PATTERN"
+
+	// Provider and owner of portfolios and products configured without one
+	defaultProvider = "Platform"
+)
+
+var accountIdRE = regexp.MustCompile(`^\d{12}$`)
+
+// Catalog creates the portfolios of the platform products and shares them
+type Catalog struct {
+	logger          *zap.Logger
+	metrics         *metrics.Collector
+	cfg             *config.LandingZoneConfig
+	organizationArn pulumi.StringOutput
+	resolver        *policies.Resolver
+}
+
+// SetupServiceCatalog enables organizational sharing of Service Catalog, creates the
+// configured portfolios and their products in the management account and shares them
+// with their OUs, accounts or the whole organization. Roles matching the principals
+// of a portfolio are given access to it in every recipient account.
+func SetupServiceCatalog(ctx *pulumi.Context, cfg *config.LandingZoneConfig, organizationArn pulumi.StringOutput, targets policies.Targets, opts ...pulumi.ResourceOption) error {
+	logger, err := logging.NewLogger("catalog")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("catalog")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("service_catalog_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup service catalog"); err != nil {
+		return err
+	}
+
+	if cfg.ServiceCatalog == nil || len(cfg.ServiceCatalog.Portfolios) == 0 {
+		logger.Info("no service catalog portfolios configured")
+		return nil
+	}
+
+	c := &Catalog{
+		logger:          logger,
+		metrics:         metrics,
+		cfg:             cfg,
+		organizationArn: organizationArn,
+		resolver:        policies.NewResolver(cfg, targets),
+	}
+
+	access, err := servicecatalog.NewOrganizationsAccess(ctx, "service-catalog-organizations-access",
+		&servicecatalog.OrganizationsAccessArgs{
+			Enabled: pulumi.Bool(true),
+		}, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to enable service catalog organizational sharing: %w", err)
+	}
+
+	for _, portfolio := range cfg.ServiceCatalog.Portfolios {
+		if err := c.createPortfolio(ctx, portfolio, access, opts); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("service catalog setup completed successfully",
+		zap.Int("portfolios", len(cfg.ServiceCatalog.Portfolios)))
+	return nil
+}
+
+// createPortfolio creates a portfolio with its products, shares and principals
+func (c *Catalog) createPortfolio(ctx *pulumi.Context, portfolioCfg config.PortfolioConfig, access *servicecatalog.OrganizationsAccess, opts []pulumi.ResourceOption) error {
+	key := resourceKey(portfolioCfg.Name)
+
+	providerName := portfolioCfg.ProviderName
+	if providerName == "" {
+		providerName = defaultProvider
+	}
+
+	portfolio, err := servicecatalog.NewPortfolio(ctx, fmt.Sprintf("portfolio-%s", key), &servicecatalog.PortfolioArgs{
+		Name:         pulumi.String(portfolioCfg.Name),
+		Description:  pulumi.String(portfolioCfg.Description),
+		ProviderName: pulumi.String(providerName),
+		Tags:         pulumi.ToStringMap(c.cfg.Tags),
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create portfolio %s: %w", portfolioCfg.Name, err)
+	}
+
+	for _, productCfg := range portfolioCfg.Products {
+		if err := c.createProduct(ctx, key, portfolio, productCfg, opts); err != nil {
+			return err
+		}
+	}
+
+	for _, target := range portfolioCfg.ShareWith {
+		shareType, principalId, err := c.principal(ctx, target)
+		if err != nil {
+			return fmt.Errorf("failed to resolve share %s of portfolio %s: %w", target, portfolioCfg.Name, err)
+		}
+
+		if _, err := servicecatalog.NewPortfolioShare(ctx, fmt.Sprintf("portfolio-%s-share-%s", key, target),
+			&servicecatalog.PortfolioShareArgs{
+				PortfolioId:     portfolio.ID(),
+				Type:            pulumi.String(shareType),
+				PrincipalId:     principalId,
+				SharePrincipals: pulumi.Bool(len(portfolioCfg.Principals) > 0),
+			}, component.Options(opts, pulumi.DependsOn([]pulumi.Resource{access}))...); err != nil {
+			return fmt.Errorf("failed to share portfolio %s with %s: %w", portfolioCfg.Name, target, err)
+		}
+		c.metrics.IncrementCounter("portfolio_shares_created")
+	}
+
+	// Principal names are shared with the portfolio and resolved in each recipient account
+	for _, principal := range portfolioCfg.Principals {
+		if _, err := servicecatalog.NewPrincipalPortfolioAssociation(ctx, fmt.Sprintf("portfolio-%s-principal-%s", key, resourceKey(principal)),
+			&servicecatalog.PrincipalPortfolioAssociationArgs{
+				PortfolioId:   portfolio.ID(),
+				PrincipalArn:  pulumi.String(rolePattern(principal)),
+				PrincipalType: pulumi.String(principalTypeIAMPattern),
+			}, opts...); err != nil {
+			return fmt.Errorf("failed to give %s access to portfolio %s: %w", principal, portfolioCfg.Name, err)
+		}
+	}
+
+	c.metrics.IncrementCounter("portfolios_created")
+	return nil
+}
+
+// createProduct creates a product and adds it to its portfolio
+func (c *Catalog) createProduct(ctx *pulumi.Context, portfolioKey string, portfolio *servicecatalog.Portfolio, productCfg config.ProductConfig, opts []pulumi.ResourceOption) error {
+	name := fmt.Sprintf("portfolio-%s-product-%s", portfolioKey, resourceKey(productCfg.Name))
+
+	owner := productCfg.Owner
+	if owner == "" {
+		owner = defaultProvider
+	}
+
+	args := &servicecatalog.ProductArgs{
+		Name:        pulumi.String(productCfg.Name),
+		Description: pulumi.String(productCfg.Description),
+		Owner:       pulumi.String(owner),
+		Type:        pulumi.String(productTypeCloudFormation),
+		ProvisioningArtifactParameters: &servicecatalog.ProductProvisioningArtifactParametersArgs{
+			Name:        pulumi.String(productCfg.Version),
+			TemplateUrl: pulumi.String(productCfg.TemplateURL),
+			Type:        pulumi.String(productTypeCloudFormation),
+		},
+		Tags: pulumi.ToStringMap(c.cfg.Tags),
+	}
+	if productCfg.SupportEmail != "" {
+		args.SupportEmail = pulumi.String(productCfg.SupportEmail)
+	}
+
+	product, err := servicecatalog.NewProduct(ctx, name, args, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create product %s: %w", productCfg.Name, err)
+	}
+
+	if _, err := servicecatalog.NewProductPortfolioAssociation(ctx, name, &servicecatalog.ProductPortfolioAssociationArgs{
+		PortfolioId: portfolio.ID(),
+		ProductId:   product.ID(),
+	}, opts...); err != nil {
+		return fmt.Errorf("failed to add product %s to portfolio: %w", productCfg.Name, err)
+	}
+
+	c.metrics.IncrementCounter("products_created")
+	return nil
+}
+
+// principal returns the share type and principal of a target: the organization for
+// the root, an account ID, or the ARN of an OU
+func (c *Catalog) principal(ctx *pulumi.Context, target string) (string, pulumi.StringInput, error) {
+	if target == config.PolicyTargetRoot {
+		return shareTypeOrganization, c.organizationArn, nil
+	}
+	if accountIdRE.MatchString(target) {
+		return shareTypeAccount, pulumi.String(target), nil
+	}
+
+	ouId, err := c.resolver.Resolve(ctx, target)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// OU ARNs are the organization ARN with the OU appended:
+	// arn:aws:organizations::<management>:ou/<organization>/<ou>
+	arn := pulumi.All(c.organizationArn, ouId).ApplyT(func(args []interface{}) string {
+		return strings.Replace(args[0].(string), ":organization/", ":ou/", 1) + "/" + args[1].(string)
+	}).(pulumi.StringOutput)
+	return shareTypeOU, arn, nil
+}
+
+// rolePattern returns the IAM pattern of a role name in any recipient account
+func rolePattern(name string) string {
+	return fmt.Sprintf("arn:%s:iam:::role/%s", awsclient.Partition(), name)
+}
+
+// resourceKey returns a name usable in resource names
+func resourceKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '-'
+		}
+	}, name)
+}
@@ -0,0 +1,368 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package centrallogging provides the centralized logging pipeline of the organization,
+// subscribing the log groups of every account to a Firehose delivery stream of the log
+// archive account.
+// Version: 1.0.0
+package centrallogging
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kinesis"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/organizations"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// OutputDestinationArns is the stack output holding the ARN of the destination of
+	// each region
+	OutputDestinationArns = "centralLoggingDestinationArns"
+
+	// Services assuming the roles of the pipeline
+	firehoseService = "firehose"
+	logsService     = "logs"
+
+	// Destination of the delivery streams
+	destinationExtendedS3 = "extended_s3"
+
+	// Status of the accounts subscribed to the destinations
+	accountStatusActive = "ACTIVE"
+)
+
+// CentralLogging holds the state of the centralized logging setup
+type CentralLogging struct {
+	logger       *zap.Logger
+	cfg          *config.CentralLoggingConfig
+	tags         map[string]string
+	names        config.ResourceNames
+	bucket       string
+	logArchiveId string
+	providers    *awsclient.Providers
+}
+
+// SetupCentralLogging creates in the log archive account, in every central logging
+// region, a Firehose delivery stream to the log bucket and a CloudWatch Logs destination
+// in front of it with a policy letting the accounts of the organization subscribe to
+// it, then subscribes the configured log groups of every other active account.
+// Accounts created by the same update are subscribed by the next one.
+func SetupCentralLogging(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("centrallogging")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("centrallogging")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("central_logging_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup centralized logging"); err != nil {
+		return err
+	}
+
+	if cfg.CentralLogging == nil {
+		logger.Info("no centralized logging configured")
+		return nil
+	}
+
+	org, err := organizations.LookupOrganization(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look up organization accounts: %w", err)
+	}
+
+	l := &CentralLogging{
+		logger:       logger,
+		cfg:          cfg.CentralLogging,
+		tags:         cfg.Tags,
+		names:        cfg.ResourceNames(),
+		bucket:       cfg.CentralLoggingBucket(),
+		logArchiveId: cfg.LogArchiveAccountId,
+		providers:    awsclient.NewProviders("central-logging", org.MasterAccountId, awsclient.MemberRoleName(cfg)),
+	}
+
+	destinationArns := pulumi.StringMap{}
+	subscribed := 0
+	for _, region := range cfg.CentralLoggingRegions() {
+		destination, policy, err := l.createDestination(ctx, org.Id, region)
+		if err != nil {
+			return err
+		}
+		destinationArns[region] = destination.Arn
+
+		for _, account := range org.Accounts {
+			if account.Status != accountStatusActive || account.Id == l.logArchiveId || l.cfg.Excluded(account.Id) {
+				continue
+			}
+			if err := l.subscribe(ctx, account.Id, region, destination, policy); err != nil {
+				return err
+			}
+			subscribed++
+		}
+	}
+	metrics.SetGauge("central_logging_subscriptions", float64(subscribed))
+	ctx.Export(OutputDestinationArns, destinationArns)
+
+	logger.Info("centralized logging setup completed successfully",
+		zap.String("logArchiveAccount", l.logArchiveId),
+		zap.String("bucket", l.bucket),
+		zap.Strings("regions", cfg.CentralLoggingRegions()))
+	return nil
+}
+
+// createDestination creates the delivery stream of a region in the log archive
+// account, the destination forwarding to it and the policy of the destination
+func (l *CentralLogging) createDestination(ctx *pulumi.Context, orgId, region string) (*cloudwatch.LogDestination, *cloudwatch.LogDestinationPolicy, error) {
+	provider, err := l.providers.Get(ctx, l.logArchiveId, region)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deliveryRole, err := l.deliveryRole(ctx, region, provider)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s3Config := &kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationArgs{
+		BucketArn:         pulumi.String(awsclient.BucketArn(l.bucket)),
+		RoleArn:           deliveryRole.Arn,
+		Prefix:            pulumi.String(l.cfg.S3Prefix()),
+		ErrorOutputPrefix: pulumi.String(l.cfg.S3Prefix() + "errors/!{firehose:error-output-type}/"),
+		BufferingInterval: pulumi.Int(l.cfg.Interval()),
+		BufferingSize:     pulumi.Int(l.cfg.Size()),
+	}
+	if l.cfg.KmsKeyArn != "" {
+		s3Config.KmsKeyArn = pulumi.String(l.cfg.KmsKeyArn)
+	}
+	stream, err := kinesis.NewFirehoseDeliveryStream(ctx, fmt.Sprintf("central-logging-stream-%s", region), &kinesis.FirehoseDeliveryStreamArgs{
+		Name:                    pulumi.String(l.cfg.DestinationName()),
+		Destination:             pulumi.String(destinationExtendedS3),
+		ExtendedS3Configuration: s3Config,
+		Tags:                    pulumi.ToStringMap(l.tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create central logging delivery stream in %s: %w", region, err)
+	}
+
+	subscriptionRole, err := l.subscriptionRole(ctx, orgId, region, stream, provider)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	destination, err := cloudwatch.NewLogDestination(ctx, fmt.Sprintf("central-logging-destination-%s", region), &cloudwatch.LogDestinationArgs{
+		Name:      pulumi.String(l.cfg.DestinationName()),
+		RoleArn:   subscriptionRole.Arn,
+		TargetArn: stream.Arn,
+		Tags:      pulumi.ToStringMap(l.tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create central logging destination in %s: %w", region, err)
+	}
+
+	document, err := destinationPolicy(orgId, destinationArn(region, l.logArchiveId, l.cfg.DestinationName()))
+	if err != nil {
+		return nil, nil, err
+	}
+	policy, err := cloudwatch.NewLogDestinationPolicy(ctx, fmt.Sprintf("central-logging-destination-%s", region), &cloudwatch.LogDestinationPolicyArgs{
+		DestinationName: destination.Name,
+		AccessPolicy:    pulumi.String(document),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create central logging destination policy in %s: %w", region, err)
+	}
+	return destination, policy, nil
+}
+
+// deliveryRole creates the role the delivery stream of a region assumes to write to
+// the bucket
+func (l *CentralLogging) deliveryRole(ctx *pulumi.Context, region string, provider *aws.Provider) (*iam.Role, error) {
+	statements := []map[string]interface{}{
+		{
+			"Effect": "Allow",
+			"Action": []string{
+				"s3:AbortMultipartUpload",
+				"s3:GetBucketLocation",
+				"s3:GetObject",
+				"s3:ListBucket",
+				"s3:ListBucketMultipartUploads",
+				"s3:PutObject",
+			},
+			"Resource": []string{
+				awsclient.BucketArn(l.bucket),
+				awsclient.BucketArn(l.bucket) + "/*",
+			},
+		},
+	}
+	if l.cfg.KmsKeyArn != "" {
+		statements = append(statements, map[string]interface{}{
+			"Effect":   "Allow",
+			"Action":   []string{"kms:Decrypt", "kms:GenerateDataKey"},
+			"Resource": l.cfg.KmsKeyArn,
+		})
+	}
+
+	document, err := awsclient.PolicyDocument(statements)
+	if err != nil {
+		return nil, err
+	}
+	role, err := l.role(ctx, fmt.Sprintf("central-logging-delivery-%s", region), config.RoleLoggingDelivery, region,
+		"Delivers the centralized logs to the log bucket", firehoseService,
+		map[string]interface{}{"StringEquals": map[string]string{"aws:SourceAccount": l.logArchiveId}},
+		pulumi.String(document), provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create central logging delivery role in %s: %w", region, err)
+	}
+	return role, nil
+}
+
+// subscriptionRole creates the role CloudWatch Logs assumes to put the events of the
+// subscribed log groups of the organization into the delivery stream of a region
+func (l *CentralLogging) subscriptionRole(ctx *pulumi.Context, orgId, region string, stream *kinesis.FirehoseDeliveryStream, provider *aws.Provider) (*iam.Role, error) {
+	policy := stream.Arn.ApplyT(func(streamArn string) (string, error) {
+		return awsclient.PolicyDocument([]map[string]interface{}{
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"firehose:PutRecord", "firehose:PutRecordBatch"},
+				"Resource": streamArn,
+			},
+		})
+	}).(pulumi.StringOutput)
+
+	role, err := l.role(ctx, fmt.Sprintf("central-logging-subscription-%s", region), config.RoleLoggingSubscription, region,
+		"Puts the events of the subscribed log groups into the central logging delivery stream", logsService,
+		map[string]interface{}{"StringEquals": map[string]string{"aws:SourceOrgID": orgId}},
+		policy, provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create central logging subscription role in %s: %w", region, err)
+	}
+	return role, nil
+}
+
+// role creates a role of the log archive account assumed by a service under the given
+// condition, with an inline policy
+func (l *CentralLogging) role(ctx *pulumi.Context, name string, resource config.NamedResource, region, description, service string, condition map[string]interface{}, policy pulumi.StringInput, provider *aws.Provider) (*iam.Role, error) {
+	trust, err := awsclient.PolicyDocument([]map[string]interface{}{
+		{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": awsclient.ServicePrincipal(service)},
+			"Action":    "sts:AssumeRole",
+			"Condition": condition,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal trust policy: %w", err)
+	}
+
+	role, err := iam.NewRole(ctx, name, &iam.RoleArgs{
+		Name:             component.Name(l.names.Name(resource, region)),
+		Description:      pulumi.String(description),
+		AssumeRolePolicy: pulumi.String(trust),
+		Tags:             pulumi.ToStringMap(l.tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := iam.NewRolePolicy(ctx, name, &iam.RolePolicyArgs{
+		Role:   role.ID(),
+		Policy: policy,
+	}, pulumi.Provider(provider)); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// subscribe subscribes the configured log groups of an account in a region to the
+// destination of the region. Log groups the account does not have in the region are
+// skipped with a warning.
+func (l *CentralLogging) subscribe(ctx *pulumi.Context, accountId, region string, destination *cloudwatch.LogDestination, policy *cloudwatch.LogDestinationPolicy) error {
+	provider, err := l.providers.Get(ctx, accountId, region)
+	if err != nil {
+		return err
+	}
+
+	for i, logGroup := range l.cfg.LogGroups {
+		exists, err := logGroupExists(ctx, logGroup, provider)
+		if err != nil {
+			return fmt.Errorf("failed to look up log group %s of %s in %s: %w", logGroup, accountId, region, err)
+		}
+		if !exists {
+			l.logger.Warn("log group not found, not subscribed to central logging",
+				zap.String("logGroup", logGroup),
+				zap.String("accountId", accountId),
+				zap.String("region", region))
+			continue
+		}
+
+		_, err = cloudwatch.NewLogSubscriptionFilter(ctx, fmt.Sprintf("central-logging-%s-%s-%d", accountId, region, i), &cloudwatch.LogSubscriptionFilterArgs{
+			Name:           pulumi.String(l.cfg.DestinationName()),
+			LogGroup:       pulumi.String(logGroup),
+			FilterPattern:  pulumi.String(l.cfg.FilterPattern),
+			DestinationArn: destination.Arn,
+		}, pulumi.Provider(provider), pulumi.DependsOn([]pulumi.Resource{policy}))
+		if err != nil {
+			return fmt.Errorf("failed to subscribe log group %s of %s to central logging in %s: %w", logGroup, accountId, region, err)
+		}
+	}
+	return nil
+}
+
+// logGroupExists reports whether the log group exists in the account and region of
+// the provider
+func logGroupExists(ctx *pulumi.Context, logGroup string, provider *aws.Provider) (bool, error) {
+	result, err := cloudwatch.GetLogGroups(ctx, &cloudwatch.GetLogGroupsArgs{
+		LogGroupNamePrefix: pulumi.StringRef(logGroup),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return false, err
+	}
+	for _, name := range result.LogGroupNames {
+		if name == logGroup {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// destinationArn returns the ARN of a CloudWatch Logs destination
+func destinationArn(region, accountId, name string) string {
+	return fmt.Sprintf("arn:%s:logs:%s:%s:destination:%s", awsclient.Partition(), region, accountId, name)
+}
+
+// destinationPolicy returns the policy letting the accounts of the organization
+// subscribe their log groups to a destination
+func destinationPolicy(orgId, destinationArn string) (string, error) {
+	document, err := awsclient.PolicyDocument([]map[string]interface{}{{
+		"Effect":    "Allow",
+		"Principal": "*",
+		"Action":    "logs:PutSubscriptionFilter",
+		"Resource":  destinationArn,
+		"Condition": map[string]interface{}{
+			"StringEquals": map[string]string{
+				"aws:PrincipalOrgID": orgId,
+			},
+		},
+	}})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal central logging destination policy: %w", err)
+	}
+	return document, nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/accessreview"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "access-review",
+		Description: "review the accounts of each owner, emailing or posting the review with --send",
+		Run:         runAccessReview,
+	})
+}
+
+// runAccessReview implements the access-review command. The review is written out and,
+// with --send, delivered through the channels of the configuration.
+func runAccessReview(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("access-review")
+	if err != nil {
+		return err
+	}
+
+	var format, output string
+	var send bool
+	fs := flag.NewFlagSet("access-review", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	fs.StringVar(&output, "output", "", "file to write the review to instead of standard output")
+	fs.BoolVar(&send, "send", false, "email the review and post it to Slack as configured")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch format {
+	case report.FormatText, report.FormatJSON:
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	var review *accessreview.Review
+	var reviewer *accessreview.Reviewer
+	if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+		reviewer, err = accessreview.NewReviewer(ctx, config.DefaultConfig.LandingZoneConfig, cache)
+		if err != nil {
+			return err
+		}
+		review, err = reviewer.Review(ctx)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create access review file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := accessreview.Write(w, format, review); err != nil {
+		return err
+	}
+
+	if !send {
+		return nil
+	}
+	logger.Info("delivering access review", zap.Int("owners", len(review.Owners)))
+	return reviewer.Deliver(ctx, review)
+}
@@ -0,0 +1,223 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/accounts"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "accounts",
+		Description: "inspect the accounts of the organization: list, find, status",
+		Run:         runAccounts,
+	})
+}
+
+// tagFlags collects repeated --tag key=value flags. A tag without a value matches any
+// value of the key.
+type tagFlags map[string]string
+
+func (t tagFlags) String() string {
+	pairs := make([]string, 0, len(t))
+	for key, value := range t {
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (t tagFlags) Set(value string) error {
+	key, tagValue, _ := strings.Cut(value, "=")
+	if key = strings.TrimSpace(key); key == "" {
+		return fmt.Errorf("invalid tag %q, expected key=value", value)
+	}
+	t[key] = strings.TrimSpace(tagValue)
+	return nil
+}
+
+// runAccounts dispatches the accounts sub-commands
+func runAccounts(ctx context.Context, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no accounts command specified")
+	}
+
+	switch args[0] {
+	case "list":
+		return runAccountsList(ctx, args[1:])
+	case "find":
+		return runAccountsFind(ctx, args[1:])
+	case "status":
+		return runAccountsStatus(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown accounts command %q", args[0])
+	}
+}
+
+// runAccountsList implements the accounts list command. Accounts are read from the live
+// organization; only the OU tree is served from the organization cache.
+func runAccountsList(ctx context.Context, args []string) error {
+	logger, err := logging.NewLogger("accounts-list")
+	if err != nil {
+		return err
+	}
+
+	filter := accounts.AccountFilter{Tags: make(tagFlags), Attributes: make(tagFlags)}
+	var format string
+	fs := flag.NewFlagSet("accounts list", flag.ContinueOnError)
+	fs.StringVar(&filter.OU, "ou", "", "name or ID of an OU, including the accounts of nested OUs")
+	fs.StringVar(&filter.Status, "status", "", "account status: ACTIVE, SUSPENDED or PENDING_CLOSURE")
+	fs.Var(tagFlags(filter.Tags), "tag", "tag the accounts must have, as key=value or key; repeatable")
+	fs.StringVar(&filter.EmailDomain, "email-domain", "", "domain of the account email addresses")
+	fs.Var(tagFlags(filter.Attributes), "attribute", "custom attribute the accounts must have, as name=value or name; repeatable")
+	fs.StringVar(&format, "format", report.FormatText, "output format: text, json or csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch format {
+	case report.FormatText, report.FormatJSON, accounts.FormatCSV:
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	var listed []*accounts.AccountInfo
+	if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+		manager, err := accounts.NewAccountManager(ctx, accounts.WithOrganizationCache(cache),
+			accounts.WithParameterNames(config.DefaultConfig.LandingZoneConfig),
+			accounts.WithAccountAttributes(config.DefaultConfig.LandingZoneConfig))
+		if err != nil {
+			return err
+		}
+		listed, err = manager.SearchAccounts(ctx, filter)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	logger.Info("accounts listed", zap.Int("accounts", len(listed)))
+	return accounts.WriteAccounts(os.Stdout, format, listed)
+}
+
+// runAccountsFind implements the accounts find command
+func runAccountsFind(ctx context.Context, args []string) error {
+	logger, err := logging.NewLogger("accounts-find")
+	if err != nil {
+		return err
+	}
+
+	tags := make(tagFlags)
+	var email, format string
+	fs := flag.NewFlagSet("accounts find", flag.ContinueOnError)
+	fs.Var(tags, "tag", "tag the accounts must have, as key=value or key; repeatable")
+	fs.StringVar(&email, "email", "", "email address of the account")
+	fs.StringVar(&format, "format", report.FormatText, "output format: text, json or csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(tags) == 0 && email == "" {
+		return fmt.Errorf("usage: accounts find --tag key=value [--tag ...] | --email address")
+	}
+	switch format {
+	case report.FormatText, report.FormatJSON, accounts.FormatCSV:
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	var found []*accounts.AccountMatch
+	if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+		manager, err := accounts.NewAccountManager(ctx, accounts.WithOrganizationCache(cache),
+			accounts.WithParameterNames(config.DefaultConfig.LandingZoneConfig),
+			accounts.WithAccountAttributes(config.DefaultConfig.LandingZoneConfig))
+		if err != nil {
+			return err
+		}
+		found, err = manager.FindAccounts(ctx, tags, email)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	logger.Info("account search completed", zap.Int("accounts", len(found)))
+	return accounts.WriteMatches(os.Stdout, format, found)
+}
+
+// accountStatus is the status of an account printed by accounts status
+type accountStatus struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// runAccountsStatus implements the accounts status command. Statuses are read from
+// the Organizations API directly, without a Pulumi run.
+func runAccountsStatus(ctx context.Context, args []string) error {
+	logger, err := logging.NewLogger("accounts-status")
+	if err != nil {
+		return err
+	}
+
+	var format string
+	fs := flag.NewFlagSet("accounts status", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: accounts status [--format text|json] ACCOUNT_ID...")
+	}
+	switch format {
+	case report.FormatText, report.FormatJSON:
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	var statuses []accountStatus
+	if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+		manager, err := accounts.NewAccountManager(ctx, accounts.WithOrganizationCache(cache))
+		if err != nil {
+			return err
+		}
+		for _, accountID := range fs.Args() {
+			status, err := manager.GetAccountStatus(ctx, accountID)
+			if err != nil {
+				return err
+			}
+			statuses = append(statuses, accountStatus{ID: accountID, Status: status})
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	logger.Info("account statuses read", zap.Int("accounts", len(statuses)))
+	if format == report.FormatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(statuses); err != nil {
+			return fmt.Errorf("failed to encode account statuses: %w", err)
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tSTATUS")
+	for _, status := range statuses {
+		fmt.Fprintf(tw, "%s\t%s\n", status.ID, status.Status)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/accounts"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/engine"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runs"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+	"go.uber.org/zap"
+)
+
+// Pulumi type of the SSM parameters holding the account backups
+const ssmParameterType = "aws:ssm/parameter:Parameter"
+
+func init() {
+	register(&Command{
+		Name:        "backups",
+		Description: "enforce the retention of the state and account backups: prune",
+		Run:         runBackups,
+	})
+}
+
+// prunedBackups are the backups deleted, or listed with --dry-run, by backups prune
+type prunedBackups struct {
+	DryRun   bool                     `json:"dryRun"`
+	State    []state.Version          `json:"state"`
+	Accounts []*accounts.StoredBackup `json:"accounts"`
+}
+
+// runBackups dispatches the backups sub-commands
+func runBackups(ctx context.Context, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no backups command specified")
+	}
+
+	switch args[0] {
+	case "prune":
+		return runBackupsPrune(ctx, opts, args[1:])
+	default:
+		return fmt.Errorf("unknown backups command %q", args[0])
+	}
+}
+
+// runBackupsPrune implements the backups prune command. It deletes the state backups
+// and the account backups stored in SSM that are older than the backup retention, or
+// only lists them with --dry-run.
+func runBackupsPrune(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("backups-prune")
+	if err != nil {
+		return err
+	}
+
+	var dryRun bool
+	var stackName, workDir, format string
+	fs := flag.NewFlagSet("backups prune", flag.ContinueOnError)
+	fs.StringVar(&stackName, "stack", deployStackDefault(), "Pulumi stack whose account backups are kept")
+	fs.StringVar(&workDir, "dir", ".", "directory containing the Pulumi project")
+	fs.BoolVar(&dryRun, "dry-run", false, "only list the backups past their retention")
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch format {
+	case report.FormatText, report.FormatJSON:
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	result := prunedBackups{DryRun: dryRun || opts.ReadOnly}
+	prune := func(run *runs.Run) error {
+		manager, err := state.NewManager(ctx, state.OptionsFor(cfg)...)
+		if err != nil {
+			return err
+		}
+		defer manager.Close()
+
+		result.State, err = manager.PruneBackups(ctx, result.DryRun)
+		if err != nil {
+			return err
+		}
+
+		// The backup the stack holds is deleted by the stack itself on a later update
+		managed, err := stackParameters(ctx, opts, stackName, workDir)
+		if err != nil {
+			return err
+		}
+
+		return withCache(ctx, logger, func(cache *orgcache.Cache) error {
+			am, err := accounts.NewAccountManager(ctx, accounts.WithOrganizationCache(cache),
+				accounts.WithParameterNames(cfg))
+			if err != nil {
+				return err
+			}
+			result.Accounts, err = am.PruneBackups(ctx, cfg.BackupRetention(), managed, result.DryRun)
+			return err
+		})
+	}
+
+	if result.DryRun {
+		err = prune(nil)
+	} else {
+		err = withRun(ctx, "backups-prune", fs, prune)
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info("backups past retention",
+		zap.Int("state", len(result.State)),
+		zap.Int("accounts", len(result.Accounts)),
+		zap.Int("retentionDays", cfg.BackupRetention()),
+		zap.Bool("dryRun", result.DryRun))
+	return writePrunedBackups(os.Stdout, format, &result)
+}
+
+// stackParameters returns the names of the SSM parameters managed by the stack
+func stackParameters(ctx context.Context, opts *Options, stackName, workDir string) (map[string]bool, error) {
+	sel, err := opts.Selection()
+	if err != nil {
+		return nil, err
+	}
+	runner, err := engine.NewRunner(ctx, stackName, workDir, sel, io.Discard)
+	if err != nil {
+		return nil, err
+	}
+	return runner.ResourceIDs(ctx, ssmParameterType)
+}
+
+// writePrunedBackups writes the pruned backups as a table or as JSON
+func writePrunedBackups(w io.Writer, format string, result *prunedBackups) error {
+	if format == report.FormatJSON {
+		if result.State == nil {
+			result.State = []state.Version{}
+		}
+		if result.Accounts == nil {
+			result.Accounts = []*accounts.StoredBackup{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("failed to encode pruned backups: %w", err)
+		}
+		return nil
+	}
+
+	verb := "pruned"
+	if result.DryRun {
+		verb = "would prune"
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tBACKUP\tCREATED")
+	for _, version := range result.State {
+		fmt.Fprintf(tw, "state\t%s\t%s\n", version.ID, version.Timestamp.Format(time.RFC3339))
+	}
+	for _, backup := range result.Accounts {
+		fmt.Fprintf(tw, "accounts\t%s\t%s\n", backup.ID, backup.Created.Format(time.RFC3339))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %d state and %d account backups\n", verb, len(result.State), len(result.Accounts))
+	return err
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/compliance"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "baseline",
+		Description: "apply the account alias and IAM password policy baseline to every member account",
+		Run:         runBaseline,
+	})
+}
+
+// runBaseline implements the baseline command
+func runBaseline(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("baseline")
+	if err != nil {
+		return err
+	}
+
+	var dryRun bool
+	var format, output string
+	fs := flag.NewFlagSet("baseline", flag.ContinueOnError)
+	fs.BoolVar(&dryRun, "dry-run", false, "only report the accounts that differ from the baseline")
+	fs.StringVar(&format, "format", report.FormatText, "report format: text or json")
+	fs.StringVar(&output, "output", "", "file to write the report to instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	if cfg.Baseline == nil {
+		return fmt.Errorf("no baseline configured")
+	}
+
+	apply := !dryRun && !opts.ReadOnly
+	if apply {
+		if err := readonly.Check("apply account baseline"); err != nil {
+			return err
+		}
+	}
+
+	auditor, err := compliance.NewAuditor(ctx, cfg, compliance.NewBaselineCheck(cfg, apply))
+	if err != nil {
+		return err
+	}
+
+	r := report.New()
+	if err := auditor.Run(ctx, r); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create report file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	logger.Info("account baseline completed",
+		zap.Bool("applied", apply),
+		zap.Int("findings", len(r.Findings)))
+
+	return r.Write(w, format)
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+	"go.uber.org/zap"
+)
+
+// saveCache persists the listings read through the organization cache. The cache only
+// spares API calls, so a failure is logged rather than failing the command.
+func saveCache(ctx context.Context, logger *zap.Logger, cache *orgcache.Cache) {
+	if err := cache.Save(ctx); err != nil {
+		logger.Warn("failed to save organization cache", zap.Error(err))
+	}
+}
+
+// withCache runs fn with the organization cache persisted as a state record, and saves
+// the listings read by fn when it succeeds. Without a usable state the listings are
+// cached for the run only.
+func withCache(ctx context.Context, logger *zap.Logger, fn func(cache *orgcache.Cache) error) error {
+	cfg := config.DefaultConfig.LandingZoneConfig
+	var store orgcache.Store
+	manager, err := state.NewManager(ctx, state.OptionsFor(cfg)...)
+	if err != nil {
+		logger.Warn("failed to open state, organization cache is not persisted", zap.Error(err))
+	} else {
+		defer manager.Close()
+		store = manager
+	}
+
+	cache, err := orgcache.New(ctx, cfg, store)
+	if err != nil {
+		return err
+	}
+	if err := fn(cache); err != nil {
+		return err
+	}
+	saveCache(ctx, logger, cache)
+	return nil
+}
+
+// invalidateCache drops the cached organization listings after a command that mutates
+// the organization, whether or not it succeeded
+func invalidateCache(ctx context.Context, logger *zap.Logger) {
+	if err := clearCache(ctx); err != nil {
+		logger.Warn("failed to invalidate organization cache", zap.Error(err))
+	}
+}
+
+// clearCache drops every cached organization listing from the state
+func clearCache(ctx context.Context) error {
+	cfg := config.DefaultConfig.LandingZoneConfig
+	manager, err := state.NewManager(ctx, state.OptionsFor(cfg)...)
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+
+	cache, err := orgcache.New(ctx, cfg, manager)
+	if err != nil {
+		return err
+	}
+	cache.Invalidate()
+	return cache.Save(ctx)
+}
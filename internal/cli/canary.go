@@ -0,0 +1,118 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/canary"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/engine"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/policies"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/rollout"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "canary",
+		Description: "run the validation probes of the guardrails in the canary account",
+		Run:         runCanary,
+	})
+}
+
+// runCanary implements the canary command. It fails when a probe does not have its
+// expected outcome.
+func runCanary(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("canary")
+	if err != nil {
+		return err
+	}
+
+	var format string
+	fs := flag.NewFlagSet("canary", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	if cfg.Canary == nil {
+		return fmt.Errorf("no canary configured, set landingZoneConfig.canary")
+	}
+
+	prober, err := canary.NewProber(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	results := prober.Run(ctx)
+	if err := canary.Write(os.Stdout, format, results); err != nil {
+		return err
+	}
+
+	failed := canary.Failed(results)
+	logger.Info("canary probes run",
+		zap.String("account", cfg.Canary.ProbeAccount()),
+		zap.Int("probes", len(results)),
+		zap.Int("failed", len(failed)))
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d canary probes failed", len(failed))
+	}
+	return nil
+}
+
+// validateCanary applies the staged changes to the canary alone and runs its probes
+// once the policies took effect. When a probe fails, the policies of the canary are
+// returned to those of the organization and the rollout stops.
+func validateCanary(ctx context.Context, logger *zap.Logger, runner *engine.Runner, accounts *rollout.Accounts, stage *policies.Stage) error {
+	cfg := config.DefaultConfig.LandingZoneConfig
+	prober, err := canary.NewProber(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("applying changes to the canary",
+		zap.String("target", cfg.Canary.Target),
+		zap.Strings("created", stage.Created),
+		zap.Strings("updated", stage.Updated),
+		zap.Strings("attachments", stage.Attachments))
+	if _, err := upStage(ctx, runner, accounts, stage); err != nil {
+		return fmt.Errorf("canary update failed: %w", err)
+	}
+
+	logger.Info("waiting for the policies to take effect on the canary",
+		zap.Duration("propagation", cfg.Canary.Propagation()))
+	select {
+	case <-time.After(cfg.Canary.Propagation()):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	failed := canary.Failed(prober.Run(ctx))
+	if len(failed) == 0 {
+		logger.Info("canary validation passed")
+		return nil
+	}
+
+	problems := make([]string, 0, len(failed))
+	for _, result := range failed {
+		problems = append(problems, fmt.Sprintf("%s expected %s, was %s", result.Action, result.Expect, result.Outcome))
+	}
+	logger.Error("canary validation failed, reverting the canary", zap.Strings("probes", problems))
+
+	if _, err := upStage(ctx, runner, accounts, stage.Held()); err != nil {
+		logger.Error("failed to revert the canary", zap.Error(err))
+	}
+	return fmt.Errorf("canary validation failed: %s", strings.Join(problems, "; "))
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Command represents a sub-command of the tool. Running the binary without a
+// sub-command executes the Pulumi program.
+type Command struct {
+	Name        string
+	Description string
+	Run         func(ctx context.Context, opts *Options, args []string) error
+}
+
+var commands = make(map[string]*Command)
+
+// register adds a command to the registry
+func register(cmd *Command) {
+	commands[cmd.Name] = cmd
+}
+
+// Lookup returns the command with the given name
+func Lookup(name string) (*Command, bool) {
+	cmd, ok := commands[name]
+	return cmd, ok
+}
+
+// Run executes the sub-command named by the first positional argument
+func Run(ctx context.Context, opts *Options) error {
+	if len(opts.Args) == 0 {
+		return fmt.Errorf("no command specified")
+	}
+
+	cmd, ok := Lookup(opts.Args[0])
+	if !ok {
+		return fmt.Errorf("unknown command %q", opts.Args[0])
+	}
+
+	return cmd.Run(ctx, opts, opts.Args[1:])
+}
+
+// PrintUsage writes the list of available commands
+func PrintUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "Usage: %s [flags] [command] [command flags]\n\nCommands:\n", programName)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-20s %s\n", name, commands[name].Description)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/importer"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runs"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "config",
+		Description: "inspect configuration files: diff, preset, import",
+		Run:         runConfig,
+	})
+}
+
+// runConfig dispatches the config sub-commands
+func runConfig(ctx context.Context, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no config command specified")
+	}
+
+	switch args[0] {
+	case "diff":
+		return runConfigDiff(ctx, args[1:])
+	case "preset":
+		return runConfigPreset(args[1:])
+	case "import":
+		return runConfigImport(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown config command %q", args[0])
+	}
+}
+
+// runConfigDiff implements the config diff command. It compares two configuration
+// files, or a file with the configuration of the stored state when --state is set.
+func runConfigDiff(ctx context.Context, args []string) error {
+	logger, err := logging.NewLogger("config-diff")
+	if err != nil {
+		return err
+	}
+
+	var format string
+	var fromState bool
+	fs := flag.NewFlagSet("config diff", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	fs.BoolVar(&fromState, "state", false, "compare the stored state with the given file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var from, to *config.OrganizationConfig
+	switch {
+	case fromState && fs.NArg() == 1:
+		if from, err = stateConfig(ctx); err != nil {
+			return err
+		}
+		if to, err = config.ReadFile(fs.Arg(0)); err != nil {
+			return err
+		}
+	case !fromState && fs.NArg() == 2:
+		if from, err = config.ReadFile(fs.Arg(0)); err != nil {
+			return err
+		}
+		if to, err = config.ReadFile(fs.Arg(1)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("usage: config diff OLD NEW, or config diff --state NEW")
+	}
+
+	changes, err := config.Diff(from, to)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("configuration compared", zap.Int("changes", len(changes)))
+
+	switch format {
+	case report.FormatJSON:
+		if changes == nil {
+			changes = []config.Change{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(changes); err != nil {
+			return fmt.Errorf("failed to encode configuration diff: %w", err)
+		}
+	case report.FormatText:
+		for _, change := range changes {
+			fmt.Fprintln(os.Stdout, change.String())
+		}
+		if len(changes) == 0 {
+			fmt.Fprintln(os.Stdout, "no changes")
+		}
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+	return nil
+}
+
+// runConfigPreset implements the config preset command. It prints the landing zone
+// configuration a preset expands into, or the available presets.
+func runConfigPreset(args []string) error {
+	if len(args) == 0 {
+		for _, name := range config.Presets() {
+			fmt.Fprintln(os.Stdout, name)
+		}
+		return nil
+	}
+
+	lz, err := config.PresetConfig(args[0])
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(lz); err != nil {
+		return fmt.Errorf("failed to encode preset %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// runConfigImport implements the config import command. It generates the configuration
+// of the existing organization, including its SCPs and tag policies, to adopt it, or
+// converts the configuration of a Control Tower or Landing Zone Accelerator deployment
+// when --from is set.
+func runConfigImport(ctx context.Context, args []string) error {
+	logger, err := logging.NewLogger("config-import")
+	if err != nil {
+		return err
+	}
+
+	var output, from string
+	fs := flag.NewFlagSet("config import", flag.ContinueOnError)
+	fs.StringVar(&output, "output", "", "file to write the configuration to instead of stdout")
+	fs.StringVar(&from, "from", "", "Control Tower manifest or template, or Landing Zone Accelerator configuration directory, to convert")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return withRun(ctx, "config-import", fs, func(run *runs.Run) error {
+		return importConfig(ctx, logger, run, from, output)
+	})
+}
+
+// importConfig imports or converts the configuration and writes it to output, or to
+// stdout when output is empty, recording the OUs and accounts found in the run
+func importConfig(ctx context.Context, logger *zap.Logger, run *runs.Run, from, output string) error {
+	var lz *config.LandingZoneConfig
+	if from != "" {
+		converter, err := importer.NewConverter()
+		if err != nil {
+			return err
+		}
+		if lz, err = converter.Convert(from); err != nil {
+			return err
+		}
+	} else if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+		i, err := importer.NewImporter(ctx, cache)
+		if err != nil {
+			return err
+		}
+		lz, err = i.Import(ctx)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	run.Count("organizationalUnits", len(lz.OrganizationUnits))
+	for _, ou := range lz.OrganizationUnits {
+		run.Count("accounts", len(ou.Accounts))
+	}
+
+	data, err := json.MarshalIndent(struct {
+		LandingZoneConfig *config.LandingZoneConfig `json:"LandingZoneConfig"`
+	}{lz}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode imported configuration: %w", err)
+	}
+	data = append(data, '\n')
+
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := config.WriteFile(output, data); err != nil {
+		return err
+	}
+	logger.Info("imported configuration written", zap.String("file", output))
+	return nil
+}
+
+// stateConfig returns the configuration recorded in the stored state
+func stateConfig(ctx context.Context) (*config.OrganizationConfig, error) {
+	manager, err := state.NewManager(ctx, state.OptionsFor(config.DefaultConfig.LandingZoneConfig)...)
+	if err != nil {
+		return nil, err
+	}
+	defer manager.Close()
+
+	stored, err := manager.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.State == nil {
+		return nil, fmt.Errorf("no stored state found, the configuration is recorded by the first update")
+	}
+	return storedConfig(stored)
+}
+
+// storedConfig returns the configuration recorded in a stored state
+func storedConfig(stored *config.StateData) (*config.OrganizationConfig, error) {
+	data, err := json.Marshal(stored.State)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stored state: %w", err)
+	}
+
+	cfg, err := config.NewOrganizationConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse stored configuration: %w", err)
+	}
+	if cfg.LandingZoneConfig == nil {
+		return nil, fmt.Errorf("stored state version %s holds no configuration", stored.Version)
+	}
+	return cfg, nil
+}
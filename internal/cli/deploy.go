@@ -0,0 +1,275 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/budget"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/engine"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runs"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/stacks"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/stream"
+	"go.uber.org/zap"
+)
+
+const (
+	// Default stack used when neither --stack nor PULUMI_STACK is set
+	defaultStackName = "dev"
+
+	// Default Pulumi organization of component stacks when neither --org nor PULUMI_ORG is set
+	defaultPulumiOrg = "organization"
+
+	// Deadline of a deployment when neither the context nor the configuration sets one
+	defaultDeadline = 30 * time.Minute
+)
+
+func init() {
+	register(&Command{
+		Name:        "deploy",
+		Description: "preview or apply the landing zone, optionally limited with --only/--skip",
+		Run:         runDeploy,
+	})
+}
+
+// runDeploy implements the deploy command
+func runDeploy(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("deploy")
+	if err != nil {
+		return err
+	}
+
+	stackDefault := deployStackDefault()
+	orgDefault := os.Getenv("PULUMI_ORG")
+	if orgDefault == "" {
+		orgDefault = defaultPulumiOrg
+	}
+
+	var stackName, workDir, components, pulumiOrg, format string
+	var preview bool
+	fs := flag.NewFlagSet("deploy", flag.ContinueOnError)
+	fs.StringVar(&stackName, "stack", stackDefault, "Pulumi stack to operate on")
+	fs.StringVar(&workDir, "dir", ".", "directory containing the Pulumi project")
+	fs.BoolVar(&preview, "preview", false, "only preview the changes")
+	fs.StringVar(&components, "components", "", "comma separated components to deploy as separate stacks, or \"all\"")
+	fs.StringVar(&pulumiOrg, "org", orgDefault, "Pulumi organization of the component stacks")
+	fs.StringVar(&format, "format", report.FormatText, "preview output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	preview = preview || opts.ReadOnly
+	switch format {
+	case report.FormatText:
+	case report.FormatJSON:
+		if !preview {
+			return fmt.Errorf("JSON output is only available for previews, use --preview")
+		}
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	return withRun(ctx, "deploy", fs, func(run *runs.Run) error {
+		if components != "" {
+			return deployComponents(ctx, logger, opts, run, pulumiOrg, stackName, workDir, components, preview, format)
+		}
+		return deployStack(ctx, logger, opts, run, stackName, workDir, preview, format)
+	})
+}
+
+// deployStackDefault returns the stack deployed when --stack is not set
+func deployStackDefault() string {
+	if stack := os.Getenv("PULUMI_STACK"); stack != "" {
+		return stack
+	}
+	return environmentStack(&config.DefaultConfig, defaultStackName)
+}
+
+// deployStack deploys the landing zone as a single stack
+func deployStack(ctx context.Context, logger *zap.Logger, opts *Options, run *runs.Run, stackName, workDir string, preview bool, format string) error {
+	sel, err := opts.Selection()
+	if err != nil {
+		return err
+	}
+
+	runner, err := engine.NewRunner(ctx, stackName, workDir, sel, os.Stdout)
+	if err != nil {
+		return err
+	}
+	runner.UseProgress(opts.Progress())
+	publisher, err := progressPublisher()
+	if err != nil {
+		return err
+	}
+	runner.UsePublisher(publisher)
+	run.Parameters["modules"] = sel.String()
+
+	logger.Info("running deployment",
+		zap.String("stack", stackName),
+		zap.String("modules", sel.String()),
+		zap.Bool("preview", preview))
+
+	if format == report.FormatJSON {
+		return writePlan(run, runner.PreviewChanges)(ctx)
+	}
+
+	if preview {
+		result, err := runner.Preview(ctx)
+		for operation, count := range result.ChangeSummary {
+			run.Count(string(operation), count)
+		}
+		return err
+	}
+
+	if err := verifyEmails(ctx, logger); err != nil {
+		return err
+	}
+
+	// The bake times of a staged rollout extend the default deadline
+	b, err := budget.New(ctx, config.DefaultConfig.LandingZoneConfig.Timeouts,
+		defaultDeadline+config.DefaultConfig.LandingZoneConfig.Rollout.TotalBake())
+	if err != nil {
+		return err
+	}
+	runner.UseBudget(b)
+
+	return withLock(ctx, "deploy", func() error {
+		if err := withChangeTickets(ctx, logger, stackName, sel.String(), recordChanges(run, runner.PreviewChanges), func() error {
+			result, err := upStaged(ctx, logger, runner)
+			if len(run.Changes) == 0 && result.Summary.ResourceChanges != nil {
+				for operation, count := range *result.Summary.ResourceChanges {
+					run.Count(operation, count)
+				}
+			}
+			run.Budget = b.Finish()
+			invalidateCache(ctx, logger)
+			return err
+		}); err != nil {
+			return err
+		}
+		notifyLifecycle(ctx, logger)
+		return nil
+	})
+}
+
+// deployComponents deploys the landing zone as one stack per component
+func deployComponents(ctx context.Context, logger *zap.Logger, opts *Options, run *runs.Run, pulumiOrg, stackName, workDir, list string, preview bool, format string) error {
+	var names []string
+	if list != "all" {
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("no components given")
+		}
+	}
+
+	resolved, err := stacks.Resolve(names)
+	if err != nil {
+		return err
+	}
+
+	coordinator, err := engine.NewCoordinator(ctx, pulumiOrg, stackName, workDir, resolved, os.Stdout)
+	if err != nil {
+		return err
+	}
+	coordinator.UseProgress(opts.Progress())
+	publisher, err := progressPublisher()
+	if err != nil {
+		return err
+	}
+	coordinator.UsePublisher(publisher)
+
+	componentNames := make([]string, 0, len(resolved))
+	for _, component := range resolved {
+		componentNames = append(componentNames, component.Name)
+	}
+	logger.Info("running multi-stack deployment",
+		zap.String("stack", stackName),
+		zap.Strings("components", componentNames),
+		zap.Bool("preview", preview))
+
+	if format == report.FormatJSON {
+		return writePlan(run, coordinator.PreviewChanges)(ctx)
+	}
+
+	if preview {
+		return coordinator.Preview(ctx)
+	}
+	if lz := config.DefaultConfig.LandingZoneConfig; lz.Canary != nil || lz.Rollout != nil {
+		logger.Warn("the canary and staged rollout only apply to single stack deployments, the changes are rolled out directly")
+	}
+	if err := verifyEmails(ctx, logger); err != nil {
+		return err
+	}
+
+	b, err := budget.New(ctx, config.DefaultConfig.LandingZoneConfig.Timeouts, defaultDeadline)
+	if err != nil {
+		return err
+	}
+	coordinator.UseBudget(b)
+
+	return withLock(ctx, "deploy", func() error {
+		if err := withChangeTickets(ctx, logger, stackName, strings.Join(componentNames, ","), recordChanges(run, coordinator.PreviewChanges), func() error {
+			err := coordinator.Up(ctx)
+			run.Budget = b.Finish()
+			invalidateCache(ctx, logger)
+			return err
+		}); err != nil {
+			return err
+		}
+		notifyLifecycle(ctx, logger)
+		return nil
+	})
+}
+
+// progressPublisher returns the publisher of the progress of the updates to the server
+// of the serve command, or nil when no progress stream is configured
+func progressPublisher() (*stream.Publisher, error) {
+	cfg := config.DefaultConfig.LandingZoneConfig.ProgressStream
+	if cfg == nil || cfg.URL == "" {
+		return nil, nil
+	}
+	return stream.NewPublisher(cfg)
+}
+
+// recordChanges returns preview, recording the changes it plans in the run
+func recordChanges(run *runs.Run, preview func(context.Context) ([]plan.Change, error)) func(context.Context) ([]plan.Change, error) {
+	return func(ctx context.Context) ([]plan.Change, error) {
+		changes, err := preview(ctx)
+		run.AddChanges(changes...)
+		return changes, err
+	}
+}
+
+// writePlan returns a function writing the result of preview to standard output in
+// the JSON schema of the plan package, recording the changes in the run. A failed
+// preview is reported in the document and returned.
+func writePlan(run *runs.Run, preview func(context.Context) ([]plan.Change, error)) func(context.Context) error {
+	return func(ctx context.Context) error {
+		changes, err := recordChanges(run, preview)(ctx)
+		doc := plan.New("preview")
+		if err != nil {
+			doc.Fail(err)
+		}
+		doc.AddChanges(changes...)
+
+		if writeErr := doc.Write(os.Stdout); writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/teardown"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "destroy",
+		Description: "tear down the landing zone in dependency order",
+		Run:         runDestroy,
+	})
+}
+
+// runDestroy implements the destroy command
+func runDestroy(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("destroy")
+	if err != nil {
+		return err
+	}
+
+	var confirm, retain string
+	fs := flag.NewFlagSet("destroy", flag.ContinueOnError)
+	fs.StringVar(&confirm, "confirm", "", "organization ID to confirm the teardown non-interactively")
+	fs.StringVar(&retain, "retain", "", "comma separated resource names to keep in addition to the configured ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	decommissioner, err := teardown.NewDecommissioner(ctx)
+	if err != nil {
+		return err
+	}
+
+	orgID, err := decommissioner.OrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if confirm == "" {
+		confirm, err = prompt(fmt.Sprintf(
+			"This will decommission organization %s. Type the organization ID to confirm: ", orgID))
+		if err != nil {
+			return err
+		}
+	}
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	logger.Info("destroying landing zone",
+		zap.String("organizationId", orgID),
+		zap.Any("retained", teardown.RetainedResources(cfg, splitList(retain))))
+
+	return withLock(ctx, "destroy", func() error {
+		err := decommissioner.Destroy(ctx, cfg, teardown.Options{
+			Confirmation: confirm,
+			Retain:       splitList(retain),
+		})
+		invalidateCache(ctx, logger)
+		return err
+	})
+}
+
+// prompt prints a message and reads a single line from standard input
+func prompt(message string) (string, error) {
+	fmt.Fprint(os.Stderr, message)
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,286 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/ctdrift"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/mail"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/membership"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runs"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/stacksets"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "drift",
+		Description: "detect drift of the baseline StackSets and Control Tower and report drifted resources and untracked accounts",
+		Run:         runDrift,
+	})
+}
+
+// runDrift implements the drift command. It is meant to be run on a schedule; the
+// report command includes the results of the last detection. With
+// --reset-landing-zone, a drifted Control Tower landing zone is reset once approved.
+func runDrift(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("drift")
+	if err != nil {
+		return err
+	}
+
+	var format, output, approve string
+	var reset, sign bool
+	fs := flag.NewFlagSet("drift", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "report format: text or json")
+	fs.StringVar(&output, "output", "", "file to write the report to instead of standard output")
+	fs.BoolVar(&reset, "reset-landing-zone", false, "reset the Control Tower landing zone when it drifted")
+	fs.StringVar(&approve, "approve", "", "token approving the reset non-interactively")
+	fs.BoolVar(&sign, "sign-reset", false, "print the tokens approving the reset of the drifted landing zones, signed with the approval key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if sign {
+		return signReset(ctx, logger)
+	}
+	if reset && opts.ReadOnly {
+		return fmt.Errorf("--reset-landing-zone cannot be used in read-only mode")
+	}
+
+	return withRun(ctx, "drift", fs, func(run *runs.Run) error {
+		return detectDrift(ctx, logger, run, format, output, approve, reset)
+	})
+}
+
+// detectDrift detects the drift, writes its report and resets the drifted landing
+// zones when reset is set, recording the findings in the run by severity
+func detectDrift(ctx context.Context, logger *zap.Logger, run *runs.Run, format, output, approve string, reset bool) error {
+	cfg := config.DefaultConfig.LandingZoneConfig
+	detector, err := stacksets.NewDetector(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	ctDetector, err := ctdrift.NewDetector(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	r := report.New()
+	detectErr := detector.Detect(ctx, r)
+	if detectErr == nil {
+		detectErr = ctDetector.Collect(ctx, r)
+	}
+	if detectErr == nil {
+		detectErr = collectMembership(ctx, logger, r)
+	}
+	for _, finding := range r.Findings {
+		run.Count(string(finding.Severity), 1)
+	}
+	if detectErr != nil && format != report.FormatJSON {
+		return detectErr
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create report file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	logger.Info("StackSet drift detection completed",
+		zap.Int("findings", len(r.Findings)),
+		zap.String("format", format))
+
+	// JSON results follow the plan schema shared with the preview and validate commands
+	if format == report.FormatJSON {
+		doc := plan.New("drift")
+		if detectErr != nil {
+			doc.Fail(detectErr)
+		}
+		doc.AddDrift(r.Findings...)
+		if err := doc.Write(w); err != nil {
+			return err
+		}
+		if detectErr != nil {
+			return detectErr
+		}
+	} else if err := r.Write(w, format); err != nil {
+		return err
+	}
+
+	if !reset {
+		return nil
+	}
+	return resetLandingZone(ctx, logger, ctDetector, approve)
+}
+
+// resetLandingZone resets the drifted landing zones once approved. When reset
+// approvers are configured, the approval is the token they sign with their private key,
+// given with --approve; without it they are emailed an approval request. Otherwise the
+// confirmation token derived from the drift is given with --approve or typed at the
+// prompt.
+func resetLandingZone(ctx context.Context, logger *zap.Logger, detector *ctdrift.Detector, approve string) error {
+	zones, err := detector.LandingZones(ctx)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	approvers := cfg.Drift != nil && len(cfg.Drift.ResetApprovers) > 0
+	for _, zone := range zones {
+		if !zone.Drifted {
+			logger.Info("landing zone in sync, not reset", zap.String("landingZone", zone.ARN))
+			continue
+		}
+
+		if approvers {
+			if approve == "" {
+				if err := requestResetApproval(ctx, cfg, zone); err != nil {
+					return err
+				}
+				logger.Info("landing zone reset approval requested",
+					zap.String("landingZone", zone.ARN),
+					zap.Strings("approvers", cfg.Drift.ResetApprovers))
+				continue
+			}
+			key, err := ctdrift.PublicKey(cfg.Drift.ResetApprovalPublicKey)
+			if err != nil {
+				return err
+			}
+			if !zone.Approved(key, approve) {
+				return fmt.Errorf("reset of landing zone %s not approved, the token is not signed by the approvers for its current drift", zone.ARN)
+			}
+		} else {
+			confirmation := approve
+			if confirmation == "" {
+				if confirmation, err = prompt(fmt.Sprintf(
+					"Landing zone %s drifted. Type %s to reset it: ", zone.ARN, zone.Confirmation())); err != nil {
+					return err
+				}
+			}
+			if !strings.EqualFold(confirmation, zone.Confirmation()) {
+				return fmt.Errorf("reset of landing zone %s not approved, the confirmation does not match its current drift", zone.ARN)
+			}
+		}
+
+		if err := withLock(ctx, "reset-landing-zone", func() error {
+			return detector.Reset(ctx, zone)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signReset prints the tokens approving the reset of the drifted landing zones. It is
+// run by the reset approvers, who alone can read the approval key.
+func signReset(ctx context.Context, logger *zap.Logger) error {
+	cfg := config.DefaultConfig.LandingZoneConfig
+	if cfg.Drift == nil || cfg.Drift.ResetApprovalKeySecret == "" {
+		return fmt.Errorf("--sign-reset requires drift.resetApprovalKeySecret")
+	}
+
+	detector, err := ctdrift.NewDetector(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	key, err := detector.ApprovalKey(ctx, cfg.Drift.ResetApprovalKeySecret)
+	if err != nil {
+		return err
+	}
+	zones, err := detector.LandingZones(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, zone := range zones {
+		if !zone.Drifted {
+			logger.Info("landing zone in sync, no reset to approve", zap.String("landingZone", zone.ARN))
+			continue
+		}
+		fmt.Printf("%s\t%s\n", zone.ARN, zone.ApprovalToken(key))
+	}
+	return nil
+}
+
+// requestResetApproval emails the reset approvers the command signing the approval of
+// the reset of a landing zone
+func requestResetApproval(ctx context.Context, cfg *config.LandingZoneConfig, zone ctdrift.LandingZone) error {
+	mailer, err := mail.NewMailer(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	command := "go run . drift --sign-reset"
+	title := "Approve the reset of the Control Tower landing zone"
+	return mailer.Send(ctx, mail.Message{
+		To:       cfg.Drift.ResetApprovers,
+		Subject:  title,
+		Template: mail.TemplateApproval,
+		Data: mail.Approval{
+			Title: title,
+			Description: "The landing zone drifted from the configuration Control Tower expects. " +
+				"Resetting it redeploys the landing zone with its current version and manifest. " +
+				"Run the command with access to the approval key and send the printed token to the requester, " +
+				"who resets it with --approve. The approval lapses when the landing zone changes before it is used.",
+			Fields: []mail.Field{
+				{Label: "Landing zone", Value: zone.ARN},
+				{Label: "Version", Value: zone.Version},
+				{Label: "Status", Value: zone.Status},
+			},
+			Command: command,
+		},
+		Text: fmt.Sprintf("Landing zone %s (version %s) drifted. To approve its reset, run the following "+
+			"with access to the approval key and send the printed token to the requester:\n\n  %s\n", zone.ARN, zone.Version, command),
+	})
+}
+
+// collectMembership adds the accounts that joined, left or are missing from the
+// configuration to a drift report, without recording the membership
+func collectMembership(ctx context.Context, logger *zap.Logger, r *report.Report) error {
+	cfg := config.DefaultConfig.LandingZoneConfig
+	manager, err := state.NewManager(ctx, state.OptionsFor(cfg)...)
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+
+	previous, err := membershipRecord(ctx, manager)
+	if err != nil {
+		return err
+	}
+
+	reconciler, err := membership.NewReconciler(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	cache, err := orgcache.New(ctx, cfg, manager)
+	if err != nil {
+		return err
+	}
+	reconciler.UseCache(cache)
+
+	if err := reconciler.Collect(ctx, previous, r); err != nil {
+		return err
+	}
+	saveCache(ctx, logger, cache)
+	return nil
+}
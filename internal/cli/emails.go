@@ -0,0 +1,109 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/emailcheck"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "verify-emails",
+		Description: "check that the email addresses of the configured accounts are deliverable",
+		Run:         runVerifyEmails,
+	})
+}
+
+// runVerifyEmails implements the verify-emails command. It fails when an address is
+// undeliverable.
+func runVerifyEmails(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("verify-emails")
+	if err != nil {
+		return err
+	}
+
+	var format string
+	fs := flag.NewFlagSet("verify-emails", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	verifier, err := emailcheck.NewVerifier(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	results := verifier.Verify(ctx, emailcheck.Addresses(cfg))
+	if err := emailcheck.Write(os.Stdout, format, results); err != nil {
+		return err
+	}
+
+	invalid := emailcheck.Invalid(results)
+	logger.Info("account email addresses verified",
+		zap.Int("addresses", len(results)),
+		zap.Int("invalid", len(invalid)))
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("%d account email addresses are undeliverable", len(invalid))
+	}
+	return nil
+}
+
+// verifyEmails fails a deployment before it applies any change when the email address
+// of an account it is about to create is undeliverable, since a failed account creation
+// part way through a run is expensive to unwind. The addresses of the accounts already
+// in the organization are not verified, and addresses that could not be verified are
+// only logged.
+func verifyEmails(ctx context.Context, logger *zap.Logger) error {
+	cfg := config.DefaultConfig.LandingZoneConfig
+	if cfg.EmailVerification == nil || !cfg.EmailVerification.Enabled {
+		return nil
+	}
+
+	var addresses []emailcheck.Address
+	if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+		existing, err := cache.Accounts(ctx)
+		if err != nil {
+			return err
+		}
+		addresses = emailcheck.ToCreate(emailcheck.Addresses(cfg), existing)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	verifier, err := emailcheck.NewVerifier(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	invalid := emailcheck.Invalid(verifier.Verify(ctx, addresses))
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	problems := make([]string, 0, len(invalid))
+	for _, result := range invalid {
+		problems = append(problems, fmt.Sprintf("%s (%s): %s", result.Email, result.Account, result.Reason))
+	}
+	logger.Error("undeliverable account email addresses", zap.Strings("addresses", problems))
+	return fmt.Errorf("undeliverable account email addresses: %s", strings.Join(problems, "; "))
+}
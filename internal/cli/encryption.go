@@ -0,0 +1,76 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/encryption"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "scan-encryption",
+		Description: "check the encryption at rest and in transit of the resources created by the landing zone",
+		Run:         runScanEncryption,
+	})
+}
+
+// runScanEncryption implements the scan-encryption command. It fails when a resource
+// is not encrypted as configured.
+func runScanEncryption(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("scan-encryption")
+	if err != nil {
+		return err
+	}
+
+	var format, output string
+	fs := flag.NewFlagSet("scan-encryption", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "report format: text or json")
+	fs.StringVar(&output, "output", "", "file to write the report to instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r := report.New()
+	if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+		scanner, err := encryption.NewScanner(ctx, config.DefaultConfig.LandingZoneConfig, cache)
+		if err != nil {
+			return err
+		}
+		return scanner.Collect(ctx, r)
+	}); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create report file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := r.Write(w, format); err != nil {
+		return err
+	}
+
+	logger.Info("encryption scanned", zap.Int("gaps", len(r.Findings)))
+	if len(r.Findings) > 0 {
+		return fmt.Errorf("%d encryption gaps found", len(r.Findings))
+	}
+	return nil
+}
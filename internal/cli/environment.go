@@ -0,0 +1,47 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+)
+
+// SelectEnvironment applies the environment chosen with --env to the current
+// configuration and points the AWS SDK and the Pulumi engine at its credentials and
+// region. The selection is exported to the environment so the program run by the
+// Pulumi engine operates on the same environment.
+func SelectEnvironment(cfg *config.OrganizationConfig, name string) error {
+	if err := cfg.SelectEnvironment(name); err != nil {
+		return err
+	}
+
+	env := map[string]string{
+		EnvEnvironment: cfg.Environment,
+		"AWS_PROFILE":  cfg.AWSProfile,
+		"AWS_REGION":   cfg.Region,
+	}
+	for key, value := range env {
+		if value == "" {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// environmentStack returns the Pulumi stack of the selected environment, or the
+// fallback without an environment
+func environmentStack(cfg *config.OrganizationConfig, fallback string) string {
+	env, ok := cfg.Environments[cfg.Environment]
+	if !ok || env == nil {
+		return fallback
+	}
+	return env.StackName(cfg.Environment)
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/explain"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+)
+
+var (
+	accountIdRE     = regexp.MustCompile(`^\d{12}$`)
+	explainActionRE = regexp.MustCompile(`^[A-Za-z0-9-]+:[A-Za-z0-9*?]+$`)
+)
+
+func init() {
+	register(&Command{
+		Name:        "explain",
+		Description: "explain whether the SCPs allow an action in an account: explain <account> <action> [region]",
+		Run:         runExplain,
+	})
+}
+
+// contextFlags collects repeated --context key=value flags
+type contextFlags map[string]string
+
+func (c contextFlags) String() string {
+	pairs := make([]string, 0, len(c))
+	for key, value := range c {
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (c contextFlags) Set(value string) error {
+	key, keyValue, ok := strings.Cut(value, "=")
+	if key = strings.TrimSpace(key); key == "" || !ok {
+		return fmt.Errorf("invalid condition key %q, expected key=value", value)
+	}
+	c[key] = strings.TrimSpace(keyValue)
+	return nil
+}
+
+// runExplain implements the explain command. It walks the SCPs attached from the root
+// down to the account and reports whether they allow the action, or which policy and
+// statement deny it.
+func runExplain(ctx context.Context, opts *Options, args []string) error {
+	request := explain.Request{Context: make(contextFlags)}
+	var format string
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	fs.Var(contextFlags(request.Context), "context",
+		"value of a condition key, such as aws:PrincipalArn=arn:aws:iam::123456789012:role/Admin; repeatable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch format {
+	case report.FormatText, report.FormatJSON:
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	positional := fs.Args()
+	if len(positional) < 2 || len(positional) > 3 {
+		return fmt.Errorf("usage: explain [flags] <account> <action> [region]")
+	}
+	accountID := positional[0]
+	if !accountIdRE.MatchString(accountID) {
+		return fmt.Errorf("invalid account ID %q", accountID)
+	}
+	request.Action = positional[1]
+	if !explainActionRE.MatchString(request.Action) {
+		return fmt.Errorf("invalid action %q, expected service:Action", request.Action)
+	}
+	if len(positional) == 3 {
+		request.Context[explain.ConditionKeyRegion] = positional[2]
+	}
+
+	explainer, err := explain.NewExplainer(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := explainer.Explain(ctx, accountID, request)
+	if err != nil {
+		return err
+	}
+	return explain.Write(os.Stdout, format, result)
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/export"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "export",
+		Description: "render the organization model for other tools: terraform",
+		Run:         runExport,
+	})
+}
+
+// runExport dispatches the export sub-commands
+func runExport(ctx context.Context, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no export command specified")
+	}
+
+	switch args[0] {
+	case "terraform":
+		return runExportTerraform(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown export command %q", args[0])
+	}
+}
+
+// runExportTerraform implements the export terraform command. It renders the OUs,
+// accounts and policies of the configuration as Terraform and OpenTofu HCL, with import
+// blocks of the matching live resources when --import is set.
+func runExportTerraform(ctx context.Context, args []string) error {
+	logger, err := logging.NewLogger("export-terraform")
+	if err != nil {
+		return err
+	}
+
+	var output string
+	var imports bool
+	fs := flag.NewFlagSet("export terraform", flag.ContinueOnError)
+	fs.StringVar(&output, "output", "", "file to write the HCL to instead of stdout")
+	fs.BoolVar(&imports, "import", false, "add import blocks of the resources of the live organization")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var live *export.Live
+	if imports {
+		if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+			live, err = export.ReadLive(ctx, cache)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := export.Terraform(w, config.DefaultConfig.LandingZoneConfig, live); err != nil {
+		return err
+	}
+
+	if output != "" {
+		logger.Info("terraform export written", zap.String("file", output), zap.Bool("imports", imports))
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/extensions"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "extensions",
+		Description: "load the extension plugins and list the custom guardrails and baselines they register",
+		Run:         runExtensions,
+	})
+}
+
+// runExtensions implements the extensions command
+func runExtensions(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("extensions")
+	if err != nil {
+		return err
+	}
+
+	var format string
+	fs := flag.NewFlagSet("extensions", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	registered, err := extensions.Load(config.DefaultConfig.LandingZoneConfig)
+	if err != nil {
+		return err
+	}
+	logger.Info("extensions loaded", zap.Int("extensions", len(registered)))
+
+	return extensions.Write(os.Stdout, format, registered)
+}
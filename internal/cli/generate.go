@@ -0,0 +1,141 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/controller"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/vending"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "generate",
+		Description: "render files to run the tool elsewhere: deploy-manifests",
+		Run:         runGenerate,
+	})
+}
+
+// runGenerate dispatches the generate sub-commands
+func runGenerate(ctx context.Context, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no generate command specified")
+	}
+
+	switch args[0] {
+	case "deploy-manifests":
+		return runGenerateDeployManifests(opts, args[1:])
+	default:
+		return fmt.Errorf("unknown generate command %q", args[0])
+	}
+}
+
+// runGenerateDeployManifests implements the generate deploy-manifests command. It
+// renders the Kubernetes manifests or the ECS task definition running the vending
+// worker in the management account, and the IAM policies of its role.
+func runGenerateDeployManifests(opts *Options, args []string) error {
+	logger, err := logging.NewLogger("generate-deploy-manifests")
+	if err != nil {
+		return err
+	}
+
+	cfg := &config.DefaultConfig
+	lz := cfg.LandingZoneConfig
+	if lz.Vending == nil {
+		return fmt.Errorf("no account vending state machine configured")
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = lz.HomeRegion
+	}
+
+	o := controller.Options{
+		Region: region,
+		Env: map[string]string{
+			EnvOrganization: opts.Organization,
+			EnvEnvironment:  opts.Environment,
+			"AWS_REGION":    region,
+		},
+	}
+	var output, policyOutput, trustOutput, oidcProvider string
+	fs := flag.NewFlagSet("generate deploy-manifests", flag.ContinueOnError)
+	fs.StringVar(&o.Format, "format", controller.FormatKubernetes,
+		"manifests to render: "+controller.FormatKubernetes+" or "+controller.FormatECS)
+	fs.StringVar(&o.Image, "image", "", "container image of the tool")
+	fs.StringVar(&o.Name, "name", controller.DefaultName, "name of the deployment or task definition family")
+	fs.StringVar(&o.Namespace, "namespace", controller.DefaultNamespace, "kubernetes namespace of the worker")
+	fs.StringVar(&o.RoleArn, "role-arn", "", "IAM role of the worker: the IRSA role or the ECS task role")
+	fs.StringVar(&o.ExecutionRoleArn, "execution-role-arn", "", "ECS task execution role")
+	fs.StringVar(&o.LogGroup, "log-group", "", "CloudWatch log group of the ECS task (default /ecs/<name>)")
+	fs.IntVar(&o.ProbePort, "probe-port", controller.DefaultProbePort, "port of the liveness and readiness probes")
+	fs.DurationVar(&o.DrainTimeout, "drain-timeout", vending.DefaultDrainTimeout,
+		"time the steps in flight are given to finish once the worker is stopped")
+	fs.StringVar(&output, "output", "", "file to write the manifests to instead of stdout")
+	fs.StringVar(&policyOutput, "policy-output", "", "file to write the IAM permissions policy of the worker role to")
+	fs.StringVar(&trustOutput, "trust-output", "", "file to write the IAM trust policy of the worker role to")
+	fs.StringVar(&oidcProvider, "oidc-provider", "", "OIDC provider of the EKS cluster, for the IRSA trust policy")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	if err := writeTo(output, func(w io.Writer) error {
+		return controller.Render(w, &o)
+	}); err != nil {
+		return err
+	}
+
+	if policyOutput != "" {
+		if err := writeTo(policyOutput, func(w io.Writer) error {
+			return controller.WritePolicy(w, controller.PermissionsPolicy(lz))
+		}); err != nil {
+			return err
+		}
+	}
+
+	if trustOutput != "" {
+		trust, err := controller.TrustPolicy(&o, lz.ManagementAccountId, oidcProvider)
+		if err != nil {
+			return err
+		}
+		if err := writeTo(trustOutput, func(w io.Writer) error {
+			return controller.WritePolicy(w, trust)
+		}); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("deploy manifests generated",
+		zap.String("format", o.Format),
+		zap.String("output", output),
+		zap.String("policy", policyOutput),
+		zap.String("trust", trustOutput))
+	return nil
+}
+
+// writeTo writes to a file, or to stdout when no file is named
+func writeTo(path string, write func(io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+	return write(file)
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/accounts"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/explain"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/impact"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+)
+
+var policyTargetRE = regexp.MustCompile(`^(r-[0-9a-z]{4,32}|ou-[0-9a-z]{4,32}-[a-z0-9]{8,32}|\d{12})$`)
+
+func init() {
+	register(&Command{
+		Name:        "impact",
+		Description: "report the API calls of the CloudTrail history an SCP change would deny",
+		Run:         runImpact,
+	})
+}
+
+// runImpact implements the impact command. It replays the calls the accounts below
+// the target made over the last days against their SCPs with the proposed policy
+// attached to the target, and reports those it would deny.
+func runImpact(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("impact")
+	if err != nil {
+		return err
+	}
+
+	var policyFile, format string
+	var days int
+	var proposal explain.Proposal
+	fs := flag.NewFlagSet("impact", flag.ContinueOnError)
+	fs.StringVar(&policyFile, "policy", "", "file holding the proposed SCP document")
+	fs.StringVar(&proposal.Name, "name", "", "name of the proposed SCP, the file name by default")
+	fs.StringVar(&proposal.Target, "target", "", "ID of the root, OU or account the SCP is attached to")
+	fs.StringVar(&proposal.Replaces, "replace", "", "name or ID of the SCP of the target the proposed one replaces")
+	fs.IntVar(&days, "days", impact.DefaultDays, "days of CloudTrail history analyzed")
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if policyFile == "" || proposal.Target == "" {
+		return fmt.Errorf("usage: impact --policy FILE --target ID [--replace POLICY] [--days N]")
+	}
+	if !policyTargetRE.MatchString(proposal.Target) {
+		return fmt.Errorf("invalid target %q, expected a root, OU or account ID", proposal.Target)
+	}
+	switch format {
+	case report.FormatText, report.FormatJSON:
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	content, err := os.ReadFile(policyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read proposed policy: %w", err)
+	}
+	proposal.Content = string(content)
+	if proposal.Name == "" {
+		proposal.Name = strings.TrimSuffix(filepath.Base(policyFile), filepath.Ext(policyFile))
+	}
+
+	accountIDs := []string{proposal.Target}
+	if !accountIdRE.MatchString(proposal.Target) {
+		if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+			manager, err := accounts.NewAccountManager(ctx, accounts.WithOrganizationCache(cache))
+			if err != nil {
+				return err
+			}
+			below, err := manager.SearchAccounts(ctx, accounts.AccountFilter{OU: proposal.Target})
+			if err != nil {
+				return err
+			}
+			accountIDs = accountIDs[:0]
+			for _, account := range below {
+				accountIDs = append(accountIDs, account.ID)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	analyzer, err := impact.NewAnalyzer(ctx, config.DefaultConfig.LandingZoneConfig)
+	if err != nil {
+		return err
+	}
+	r, err := analyzer.Analyze(ctx, proposal, accountIDs, days)
+	if err != nil {
+		return err
+	}
+	return impact.Write(os.Stdout, format, r)
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/explain"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "inheritance",
+		Description: "report the SCPs each account inherits from the root and its OUs: inheritance [account...]",
+		Run:         runInheritance,
+	})
+}
+
+// runInheritance implements the inheritance command. It resolves, for the given
+// accounts or every account of the organization, the SCPs attached from the root down
+// to the account and the statements each of them holds.
+func runInheritance(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("inheritance")
+	if err != nil {
+		return err
+	}
+
+	var format, output string
+	fs := flag.NewFlagSet("inheritance", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text, json or markdown")
+	fs.StringVar(&output, "output", "", "file to write the report to instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch format {
+	case report.FormatText, report.FormatJSON, explain.FormatMarkdown:
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+	for _, accountID := range fs.Args() {
+		if !accountIdRE.MatchString(accountID) {
+			return fmt.Errorf("invalid account ID %q", accountID)
+		}
+	}
+
+	explainer, err := explain.NewExplainer(ctx)
+	if err != nil {
+		return err
+	}
+	chains, err := explainer.Inheritance(ctx, fs.Args()...)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create report file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	logger.Info("SCP inheritance report generated",
+		zap.Int("accounts", len(chains)),
+		zap.String("format", format))
+	return explain.WriteInheritance(w, format, chains)
+}
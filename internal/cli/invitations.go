@@ -0,0 +1,71 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/invitations"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "invite",
+		Description: "invite the configured existing accounts and place them in their OU once they accept",
+		Run:         runInvite,
+	})
+}
+
+// runInvite implements the invite command. It is meant to be run repeatedly: every run
+// reports the handshake status of each invitation, sends the missing invitations and
+// places the accounts that accepted since the previous run.
+func runInvite(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("invite")
+	if err != nil {
+		return err
+	}
+
+	var dryRun bool
+	var format string
+	fs := flag.NewFlagSet("invite", flag.ContinueOnError)
+	fs.BoolVar(&dryRun, "dry-run", false, "only report the invitation status and the actions that would be applied")
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	manager, err := invitations.NewManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	var pending []*invitations.Invitation
+	if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+		manager.UseCache(cache)
+		pending, err = manager.Evaluate(ctx, cfg)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if dryRun || opts.ReadOnly {
+		logger.Info("dry run, no invitations sent", zap.Int("invitations", len(pending)))
+		return invitations.Write(os.Stdout, format, pending)
+	}
+
+	applyErr := manager.Apply(ctx, cfg, pending)
+	if err := invitations.Write(os.Stdout, format, pending); err != nil {
+		return err
+	}
+	return applyErr
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/lifecycle"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+	"go.uber.org/zap"
+)
+
+// trackLifecycle observes the lifecycle states of the accounts and notifies the webhooks
+// and the lifecycle recipients of their transitions. Deliveries that failed are kept in
+// the record, so the next run delivers them again to the channels that failed only.
+func trackLifecycle(ctx context.Context, logger *zap.Logger, manager *state.StateManager, cache *orgcache.Cache, dryRun bool) error {
+	cfg := config.DefaultConfig.LandingZoneConfig
+	if len(cfg.Webhooks) == 0 && !cfg.Mail.NotifiesLifecycle() {
+		return nil
+	}
+
+	var previous *lifecycle.Record
+	var record lifecycle.Record
+	found, err := manager.LoadRecord(ctx, lifecycle.RecordName, &record)
+	if err != nil {
+		return err
+	}
+	if found {
+		previous = &record
+	}
+
+	tracker, err := lifecycle.NewTracker(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	tracker.UseCache(cache)
+
+	transitions, current, err := tracker.Observe(ctx, previous)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		logger.Info("dry run, account lifecycle not notified", zap.Int("transitions", len(transitions)))
+		return nil
+	}
+
+	notifier, err := lifecycle.NewNotifier(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	var pending []lifecycle.Delivery
+	if previous != nil {
+		pending = previous.Pending
+	}
+	failed := notifier.Notify(ctx, current.ObservedAt, transitions, pending)
+	current.Pending = failed
+
+	if err := manager.SaveRecord(ctx, lifecycle.RecordName, current); err != nil {
+		return fmt.Errorf("failed to record account lifecycle: %w", err)
+	}
+	logger.Info("account lifecycle notified",
+		zap.Int("transitions", len(transitions)),
+		zap.Int("failed", len(failed)))
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d account lifecycle transitions were not delivered to every channel", len(failed))
+	}
+	return nil
+}
+
+// notifyLifecycle notifies the webhooks of the account transitions caused by a
+// deployment. Delivery is best effort: the deployment already succeeded, so failures
+// are logged and the deliveries left are retried by the next run.
+func notifyLifecycle(ctx context.Context, logger *zap.Logger) {
+	cfg := config.DefaultConfig.LandingZoneConfig
+	if len(cfg.Webhooks) == 0 && !cfg.Mail.NotifiesLifecycle() {
+		return
+	}
+
+	err := func() error {
+		manager, err := state.NewManager(ctx, state.OptionsFor(cfg)...)
+		if err != nil {
+			return err
+		}
+		defer manager.Close()
+
+		cache, err := orgcache.New(ctx, cfg, manager)
+		if err != nil {
+			return err
+		}
+		return trackLifecycle(ctx, logger, manager, cache, false)
+	}()
+	if err != nil {
+		logger.Warn("failed to notify account lifecycle transitions of the deployment", zap.Error(err))
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/lock"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "force-unlock",
+		Description: "release the deployment lock of a run that died, given its lock ID",
+		Run:         runForceUnlock,
+	})
+}
+
+// runForceUnlock implements the force-unlock command
+func runForceUnlock(ctx context.Context, opts *Options, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: force-unlock LOCK_ID")
+	}
+
+	l, err := lock.NewLock(ctx, lock.OptionsFor(config.DefaultConfig.LandingZoneConfig)...)
+	if err != nil {
+		return err
+	}
+	return l.ForceUnlock(ctx, args[0])
+}
+
+// withLock runs fn holding the deployment lock. Only the DynamoDB state backend
+// provides the lock; other backends run fn unlocked.
+func withLock(ctx context.Context, operation string, fn func() error) error {
+	backend := config.DefaultConfig.LandingZoneConfig.StateBackend
+	if backend != "" && backend != config.StateBackendDynamoDB {
+		return fn()
+	}
+
+	logger, err := logging.NewLogger("lock")
+	if err != nil {
+		return err
+	}
+
+	l, err := lock.NewLock(ctx, lock.OptionsFor(config.DefaultConfig.LandingZoneConfig)...)
+	if err != nil {
+		return err
+	}
+	if err := l.Acquire(ctx, operation); err != nil {
+		return err
+	}
+	defer func() {
+		if err := l.Release(context.WithoutCancel(ctx)); err != nil {
+			logger.Error("failed to release deployment lock", zap.Error(err))
+		}
+	}()
+
+	return fn()
+}
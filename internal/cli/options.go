@@ -0,0 +1,140 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package cli provides command line option parsing for the AWS Organization configuration tool.
+// Version: 1.0.0
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/progress"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/selection"
+)
+
+const (
+	// Program name used in usage output
+	programName = "aws-organization"
+
+	// Environment variables mirroring the command line flags. The Pulumi engine does not
+	// forward arguments to the program, so these are the only way to set options under
+	// `pulumi up` or `pulumi preview`.
+	EnvReadOnly     = "AWS_ORG_READ_ONLY"
+	EnvOrganization = "AWS_ORG_ORGANIZATION"
+	EnvEnvironment  = "AWS_ORG_ENV"
+	EnvQuiet        = "AWS_ORG_QUIET"
+	EnvVerbose      = "AWS_ORG_VERBOSE"
+)
+
+// Options represents the parsed command line options
+type Options struct {
+	// ReadOnly guarantees that no resources are created or modified
+	ReadOnly bool
+
+	// Organization selects one organization of a multi-organization configuration
+	Organization string
+
+	// Environment selects one environment of the landing zone, such as a sandbox
+	Environment string
+
+	// Quiet only shows warnings and errors; Verbose shows the raw Pulumi output and
+	// debug logs instead of the progress of each module
+	Quiet   bool
+	Verbose bool
+
+	// Only and Skip restrict the run to a subset of modules
+	Only []string
+	Skip []string
+
+	// Args holds the positional arguments left after flag parsing
+	Args []string
+}
+
+// ParseOptions parses the command line arguments, falling back to environment variables
+func ParseOptions(args []string) (*Options, error) {
+	opts := &Options{}
+
+	readOnlyDefault, err := envBool(EnvReadOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	quietDefault, err := envBool(EnvQuiet)
+	if err != nil {
+		return nil, err
+	}
+	verboseDefault, err := envBool(EnvVerbose)
+	if err != nil {
+		return nil, err
+	}
+
+	var only, skip string
+	fs := flag.NewFlagSet(programName, flag.ContinueOnError)
+	fs.BoolVar(&opts.ReadOnly, "read-only", readOnlyDefault,
+		"guarantee zero mutations; only previews, reads and reports are allowed (env "+EnvReadOnly+")")
+	fs.StringVar(&opts.Organization, "organization", os.Getenv(EnvOrganization),
+		"organization to operate on in a multi-organization configuration (env "+EnvOrganization+")")
+	fs.StringVar(&opts.Environment, "env", os.Getenv(EnvEnvironment),
+		"environment of the landing zone to operate on (env "+EnvEnvironment+")")
+	fs.BoolVar(&opts.Quiet, "quiet", quietDefault,
+		"only print warnings and errors (env "+EnvQuiet+")")
+	fs.BoolVar(&opts.Verbose, "verbose", verboseDefault,
+		"print debug logs and the raw Pulumi output instead of the progress (env "+EnvVerbose+")")
+	fs.StringVar(&only, "only", os.Getenv(selection.EnvOnly),
+		"comma separated modules to apply (env "+selection.EnvOnly+")")
+	fs.StringVar(&skip, "skip", os.Getenv(selection.EnvSkip),
+		"comma separated modules to leave untouched (env "+selection.EnvSkip+")")
+
+	fs.Usage = func() {
+		PrintUsage(fs.Output())
+		fmt.Fprintln(fs.Output(), "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if _, err := progress.ParseMode(opts.Quiet, opts.Verbose); err != nil {
+		return nil, err
+	}
+
+	opts.Only = splitList(only)
+	opts.Skip = splitList(skip)
+	opts.Args = fs.Args()
+	return opts, nil
+}
+
+// Selection returns the module selection described by --only and --skip
+func (o *Options) Selection() (*selection.Selection, error) {
+	return selection.New(o.Only, o.Skip)
+}
+
+// OutputMode returns the output mode selected by --quiet and --verbose
+func (o *Options) OutputMode() progress.Mode {
+	mode, _ := progress.ParseMode(o.Quiet, o.Verbose)
+	return mode
+}
+
+// Progress returns a display of the progress of deployments on standard error
+func (o *Options) Progress() *progress.Display {
+	return progress.New(os.Stderr, o.OutputMode())
+}
+
+// envBool reads a boolean environment variable, treating an unset variable as false
+func envBool(name string) (bool, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %s: %q", name, value)
+	}
+	return parsed, nil
+}
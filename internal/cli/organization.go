@@ -0,0 +1,39 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+)
+
+// SelectOrganization makes the organization chosen with --organization the current
+// configuration and points the AWS SDK and the Pulumi engine at its credentials,
+// region and partition. The selection is exported to the environment so the program
+// run by the Pulumi engine operates on the same organization.
+func SelectOrganization(cfg *config.OrganizationConfig, name string) error {
+	if err := cfg.Select(name); err != nil {
+		return err
+	}
+	awsclient.SetPartition(cfg.PartitionName())
+
+	env := map[string]string{
+		EnvOrganization: cfg.Selected,
+		"AWS_PROFILE":   cfg.AWSProfile,
+		"AWS_REGION":    cfg.Region,
+	}
+	for key, value := range env {
+		if value == "" {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
+	return nil
+}
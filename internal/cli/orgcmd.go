@@ -0,0 +1,84 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgtree"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "org",
+		Description: "inspect the organization: tree",
+		Run:         runOrg,
+	})
+}
+
+// runOrg dispatches the org sub-commands
+func runOrg(ctx context.Context, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no org command specified")
+	}
+
+	switch args[0] {
+	case "tree":
+		return runOrgTree(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown org command %q", args[0])
+	}
+}
+
+// runOrgTree implements the org tree command. The live tree walks the OU tree of the
+// organization cache; the planned tree is derived from the configuration alone.
+func runOrgTree(ctx context.Context, args []string) error {
+	logger, err := logging.NewLogger("org-tree")
+	if err != nil {
+		return err
+	}
+
+	var format string
+	var planned bool
+	fs := flag.NewFlagSet("org tree", flag.ContinueOnError)
+	fs.BoolVar(&planned, "planned", false, "render the hierarchy of the configuration instead of the live organization")
+	fs.StringVar(&format, "format", report.FormatText, "output format: text, dot, mermaid or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch format {
+	case report.FormatText, report.FormatJSON, orgtree.FormatDOT, orgtree.FormatMermaid:
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	if planned {
+		return orgtree.Write(os.Stdout, format, orgtree.Planned(config.DefaultConfig.LandingZoneConfig))
+	}
+
+	var root *orgtree.Node
+	if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+		builder, err := orgtree.NewBuilder(ctx, cache)
+		if err != nil {
+			return err
+		}
+		root, err = builder.Live(ctx)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	logger.Info("organization tree rendered", zap.String("format", format))
+	return orgtree.Write(os.Stdout, format, root)
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/policies"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "policies",
+		Description: "inspect the organization policies of the configuration: budget, merge",
+		Run:         runPolicies,
+	})
+}
+
+// runPolicies dispatches the policies sub-commands
+func runPolicies(ctx context.Context, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no policies command specified")
+	}
+
+	switch args[0] {
+	case "budget":
+		return runPoliciesBudget(args[1:])
+	case "merge":
+		return runPoliciesMerge(args[1:])
+	default:
+		return fmt.Errorf("unknown policies command %q", args[0])
+	}
+}
+
+// runPoliciesBudget implements the policies budget command, reporting the SCPs
+// attached to each target against the limits of Organizations. It fails when a target
+// exceeds them, as the deployment would.
+func runPoliciesBudget(args []string) error {
+	logger, err := logging.NewLogger("policies-budget")
+	if err != nil {
+		return err
+	}
+
+	var format string
+	fs := flag.NewFlagSet("policies budget", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	budgets, err := policies.SCPBudget(config.DefaultConfig.LandingZoneConfig)
+	if err != nil {
+		return err
+	}
+	if err := policies.WriteSCPBudget(os.Stdout, format, budgets); err != nil {
+		return err
+	}
+
+	exceeded := 0
+	for _, budget := range budgets {
+		if budget.Exceeded {
+			exceeded++
+		}
+	}
+	logger.Info("SCP budget computed",
+		zap.Int("targets", len(budgets)),
+		zap.Int("exceeded", exceeded))
+
+	if exceeded > 0 {
+		return fmt.Errorf("%d targets exceed the SCP limits", exceeded)
+	}
+	return nil
+}
+
+// runPoliciesMerge implements the policies merge command, reporting the deny SCPs
+// mergeScps consolidates, whether or not it is set. With --show, the merged documents
+// are printed as well.
+func runPoliciesMerge(args []string) error {
+	logger, err := logging.NewLogger("policies-merge")
+	if err != nil {
+		return err
+	}
+
+	var format string
+	var show bool
+	fs := flag.NewFlagSet("policies merge", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	fs.BoolVar(&show, "show", false, "print the merged policy documents")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	_, merges, err := policies.MergeSCPs(cfg)
+	if err != nil {
+		return err
+	}
+
+	merged := 0
+	for _, merge := range merges {
+		merged += len(merge.Policies)
+	}
+	logger.Info("deny SCPs merged",
+		zap.Int("policies", merged),
+		zap.Int("merged", len(merges)),
+		zap.Bool("enabled", cfg.MergeSCPs))
+
+	return policies.WriteMerges(os.Stdout, format, merges, show)
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/prcomment"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "pr-comment",
+		Description: "post JSON preview, drift and validation results as a pull request comment",
+		Run:         runPRComment,
+	})
+}
+
+// runPRComment implements the pr-comment command. Its arguments are documents written
+// with --format json by the deploy, drift and validate commands.
+func runPRComment(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("pr-comment")
+	if err != nil {
+		return err
+	}
+
+	var reportPath string
+	var dryRun bool
+	fs := flag.NewFlagSet("pr-comment", flag.ContinueOnError)
+	fs.StringVar(&reportPath, "report", "", "compliance report written by report --format json")
+	fs.BoolVar(&dryRun, "dry-run", false, "print the comment instead of posting it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 && reportPath == "" {
+		return fmt.Errorf("no results given")
+	}
+
+	docs := make([]*plan.Document, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		doc := &plan.Document{}
+		if err := readJSON(path, doc); err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+	}
+
+	var r *report.Report
+	if reportPath != "" {
+		r = &report.Report{}
+		if err := readJSON(reportPath, r); err != nil {
+			return err
+		}
+	}
+
+	body := prcomment.Render(docs, r)
+	if dryRun {
+		fmt.Fprint(os.Stdout, body)
+		return nil
+	}
+
+	poster, err := prcomment.Detect()
+	if err != nil {
+		return err
+	}
+	if poster == nil {
+		logger.Info("not running for a pull request, skipping comment")
+		return nil
+	}
+
+	if err := poster.Upsert(ctx, body); err != nil {
+		return fmt.Errorf("failed to post %s comment: %w", poster.Name(), err)
+	}
+
+	logger.Info("pull request comment posted",
+		zap.String("service", poster.Name()),
+		zap.Int("documents", len(docs)))
+	return nil
+}
+
+// readJSON decodes a JSON file into out
+func readJSON(path string, out interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/quarantine"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "quarantine",
+		Description: "move critically non-compliant accounts into the Quarantine OU and back once compliant",
+		Run:         runQuarantine,
+	})
+}
+
+// runQuarantine implements the quarantine command
+func runQuarantine(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("quarantine")
+	if err != nil {
+		return err
+	}
+
+	var dryRun bool
+	fs := flag.NewFlagSet("quarantine", flag.ContinueOnError)
+	fs.BoolVar(&dryRun, "dry-run", false, "only print the transitions that would be applied")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	manager, err := quarantine.NewManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	transitions, err := manager.Evaluate(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range transitions {
+		fmt.Fprintf(os.Stdout, "%-10s %s (%s -> %s) %s\n",
+			t.Action, t.AccountID, t.FromParent, t.ToParent, strings.Join(t.Reasons, "; "))
+	}
+
+	if dryRun || opts.ReadOnly {
+		logger.Info("dry run, no accounts moved", zap.Int("transitions", len(transitions)))
+		return nil
+	}
+
+	return manager.Apply(ctx, cfg, transitions)
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/quotas"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "quotas",
+		Description: "track and request the service quota increases of the baseline: status, request",
+		Run:         runQuotas,
+	})
+}
+
+// runQuotas dispatches the quotas sub-commands
+func runQuotas(ctx context.Context, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no quotas command specified")
+	}
+
+	switch args[0] {
+	case "status":
+		return runQuotasStatus(ctx, opts, args[1:])
+	case "request":
+		return runQuotasRequest(ctx, opts, args[1:])
+	default:
+		return fmt.Errorf("unknown quotas command %q", args[0])
+	}
+}
+
+// runQuotasStatus implements the quotas status command. The status of the open
+// requests is read from Service Quotas and recorded, unless the run is read-only.
+func runQuotasStatus(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("quotas-status")
+	if err != nil {
+		return err
+	}
+
+	var format string
+	fs := flag.NewFlagSet("quotas status", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return withQuotaRequester(ctx, func(requester *quotas.Requester) error {
+		var requests []*quotas.Request
+		var refreshErr error
+		if opts.ReadOnly {
+			requests, refreshErr = requester.Load(ctx)
+		} else {
+			requests, refreshErr = requester.Refresh(ctx)
+		}
+		if requests == nil && refreshErr != nil {
+			return refreshErr
+		}
+
+		open := 0
+		for _, request := range requests {
+			if request.Open() {
+				open++
+			}
+		}
+		logger.Info("service quota requests read",
+			zap.Int("requests", len(requests)),
+			zap.Int("open", open),
+			zap.Bool("refreshed", !opts.ReadOnly))
+
+		if err := quotas.Write(os.Stdout, format, requests); err != nil {
+			return err
+		}
+		return refreshErr
+	})
+}
+
+// runQuotasRequest implements the quotas request command, requesting the increases of
+// the service quota targets of an existing account
+func runQuotasRequest(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("quotas-request")
+	if err != nil {
+		return err
+	}
+
+	var accountId, ou, format string
+	fs := flag.NewFlagSet("quotas request", flag.ContinueOnError)
+	fs.StringVar(&accountId, "account", "", "ID of the account")
+	fs.StringVar(&ou, "ou", "", "name of the OU of the account, selecting the OU targets")
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if accountId == "" {
+		return fmt.Errorf("--account is required")
+	}
+	if opts.ReadOnly {
+		return fmt.Errorf("cannot request service quota increases in read-only mode")
+	}
+
+	return withQuotaRequester(ctx, func(requester *quotas.Requester) error {
+		requests, applyErr := requester.Apply(ctx, accountId, ou)
+		logger.Info("service quota targets applied",
+			zap.String("accountId", accountId),
+			zap.Int("requests", len(requests)))
+
+		if err := quotas.Write(os.Stdout, format, requests); err != nil {
+			return err
+		}
+		return applyErr
+	})
+}
+
+// withQuotaRequester runs fn with a requester recording the requests in the state
+func withQuotaRequester(ctx context.Context, fn func(*quotas.Requester) error) error {
+	cfg := config.DefaultConfig.LandingZoneConfig
+	if cfg.Baseline == nil || len(cfg.Baseline.ServiceQuotas) == 0 {
+		return fmt.Errorf("no service quota targets configured")
+	}
+
+	manager, err := state.NewManager(ctx, state.OptionsFor(cfg)...)
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+
+	requester, err := quotas.NewRequester(ctx, cfg, manager)
+	if err != nil {
+		return err
+	}
+	return fn(requester)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/membership"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "reconcile",
+		Description: "report pending handshakes and accounts that joined, left or are missing from the configuration",
+		Run:         runReconcile,
+	})
+}
+
+// runReconcile implements the reconcile command. The membership is recorded in the
+// state, so the next run reports the accounts that joined or left in between, and the
+// webhooks are notified of the account lifecycle transitions.
+func runReconcile(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("reconcile")
+	if err != nil {
+		return err
+	}
+
+	var dryRun bool
+	var format string
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	fs.BoolVar(&dryRun, "dry-run", false, "do not record the membership in the state")
+	fs.StringVar(&format, "format", report.FormatText, "report format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	manager, err := state.NewManager(ctx, state.OptionsFor(cfg)...)
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+
+	previous, err := membershipRecord(ctx, manager)
+	if err != nil {
+		return err
+	}
+
+	reconciler, err := membership.NewReconciler(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	cache, err := orgcache.New(ctx, cfg, manager)
+	if err != nil {
+		return err
+	}
+	reconciler.UseCache(cache)
+
+	result, record, err := reconciler.Reconcile(ctx, previous)
+	if err != nil {
+		return err
+	}
+	saveCache(ctx, logger, cache)
+
+	r := report.New()
+	r.Add(result.Findings(cfg.ManagementAccountId)...)
+	if err := r.Write(os.Stdout, format); err != nil {
+		return err
+	}
+
+	if err := trackLifecycle(ctx, logger, manager, cache, dryRun || opts.ReadOnly); err != nil {
+		return err
+	}
+
+	if dryRun || opts.ReadOnly {
+		logger.Info("dry run, membership not recorded", zap.Int("accounts", len(record.Accounts)))
+		return nil
+	}
+
+	if err := manager.SaveRecord(ctx, membership.RecordName, record); err != nil {
+		return fmt.Errorf("failed to record membership: %w", err)
+	}
+	logger.Info("membership recorded", zap.Int("accounts", len(record.Accounts)))
+	return nil
+}
+
+// membershipRecord returns the membership recorded by the last reconciliation, or nil
+func membershipRecord(ctx context.Context, manager *state.StateManager) (*membership.Record, error) {
+	var record membership.Record
+	found, err := manager.LoadRecord(ctx, membership.RecordName, &record)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/accounts"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/compliance"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/ctdrift"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/encryption"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/mail"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/optimization"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/stacksets"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "report",
+		Description: "generate the drift and compliance report for every account",
+		Run:         runReport,
+	})
+}
+
+// runReport implements the report command
+func runReport(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("report")
+	if err != nil {
+		return err
+	}
+
+	var format, output, email string
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "report format: text or json")
+	fs.StringVar(&output, "output", "", "file to write the report to instead of standard output")
+	fs.StringVar(&email, "email", "", "comma-separated addresses to email the report to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	auditor, err := compliance.NewAuditor(ctx, cfg, compliance.DefaultChecks(cfg)...)
+	if err != nil {
+		return err
+	}
+
+	r := report.New()
+	if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+		auditor.UseCache(cache)
+		if err := auditor.Run(ctx, r); err != nil {
+			return err
+		}
+
+		// Findings summaries of the advisors the organization is enrolled in
+		optimizer, err := optimization.NewCollector(ctx, cfg, cache)
+		if err != nil {
+			return err
+		}
+		if err := optimizer.Collect(ctx, r); err != nil {
+			return err
+		}
+
+		// Encryption of the resources created by the landing zone
+		scanner, err := encryption.NewScanner(ctx, cfg, cache)
+		if err != nil {
+			return err
+		}
+		if err := scanner.Collect(ctx, r); err != nil {
+			return err
+		}
+
+		// Custom attributes of the accounts, when the configuration declares them
+		manager, err := accounts.NewAccountManager(ctx, accounts.WithOrganizationCache(cache),
+			accounts.WithParameterNames(cfg), accounts.WithAccountAttributes(cfg))
+		if err != nil {
+			return err
+		}
+		return manager.CollectAttributes(ctx, r)
+	}); err != nil {
+		return err
+	}
+
+	// Drift of the baseline StackSets is reported as of the last detection run
+	detector, err := stacksets.NewDetector(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := detector.Collect(ctx, r); err != nil {
+		return err
+	}
+
+	// Control Tower keeps the drift status of the landing zone and controls current
+	ctDetector, err := ctdrift.NewDetector(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := ctDetector.Collect(ctx, r); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create report file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	logger.Info("compliance report generated",
+		zap.Int("findings", len(r.Findings)),
+		zap.String("format", format))
+
+	if err := r.Write(w, format); err != nil {
+		return err
+	}
+	if email == "" {
+		return nil
+	}
+	return emailReport(ctx, cfg, splitList(email), r)
+}
+
+// emailReport emails the text rendering of a report
+func emailReport(ctx context.Context, cfg *config.LandingZoneConfig, to []string, r *report.Report) error {
+	mailer, err := mail.NewMailer(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	if err := r.Write(&body, report.FormatText); err != nil {
+		return err
+	}
+	title := fmt.Sprintf("Compliance report %s", r.GeneratedAt.Format("2006-01-02"))
+	return mailer.Send(ctx, mail.Message{
+		To:       to,
+		Subject:  title,
+		Template: mail.TemplateReport,
+		Data: mail.Report{
+			Title:   title,
+			Summary: fmt.Sprintf("%d findings in %d accounts.", len(r.Findings), len(r.ByAccount())),
+			Body:    body.String(),
+		},
+		Text: body.String(),
+	})
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runs"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+	"go.uber.org/zap"
+)
+
+// EnvRollback carries the state version a rollback applies into the Pulumi program,
+// which then deploys the configuration recorded in that version in place of its own
+const EnvRollback = "AWS_ORG_ROLLBACK"
+
+func init() {
+	register(&Command{
+		Name:        "rollback",
+		Description: "roll the landing zone back to the configuration of a previous state version or backup",
+		Run:         runRollback,
+	})
+}
+
+// runRollback implements the rollback command. It lists the state history with
+// --list, and otherwise prints the changes reverting the configuration of the stored
+// state to that of the version given with --to, then deploys that configuration like
+// the deploy command: under the deployment lock, with the change tickets, the email
+// verification and the canary and staged rollout of the configuration rolled back to.
+func runRollback(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("rollback")
+	if err != nil {
+		return err
+	}
+
+	var to, stackName, workDir string
+	var list, preview bool
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	fs.StringVar(&to, "to", "", "state version or backup ID to roll back to")
+	fs.BoolVar(&list, "list", false, "list the state versions and backups to roll back to")
+	fs.BoolVar(&preview, "preview", false, "only preview the rollback")
+	fs.StringVar(&stackName, "stack", deployStackDefault(), "Pulumi stack to operate on")
+	fs.StringVar(&workDir, "dir", ".", "directory containing the Pulumi project")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	manager, err := state.NewManager(ctx, state.OptionsFor(config.DefaultConfig.LandingZoneConfig)...)
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+
+	if list {
+		versions, err := manager.History(ctx)
+		if err != nil {
+			return err
+		}
+		return writeVersions(os.Stdout, versions)
+	}
+	if to == "" {
+		return fmt.Errorf("usage: rollback --to VERSION, or rollback --list")
+	}
+
+	target, err := manager.LoadVersion(ctx, to)
+	if err != nil {
+		return err
+	}
+	targetCfg, err := storedConfig(target)
+	if err != nil {
+		return err
+	}
+	current, err := stateConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	changes, err := config.Diff(current, targetCfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "Rolling back to %s (%s):\n", to, target.Timestamp.Format(time.RFC3339))
+	for _, change := range changes {
+		fmt.Fprintln(os.Stdout, "  "+change.String())
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(os.Stdout, "  no configuration changes, the deployment reverts the drift from it")
+	}
+
+	preview = preview || opts.ReadOnly
+	logger.Info("rolling back landing zone",
+		zap.String("version", to),
+		zap.Int("changes", len(changes)),
+		zap.Bool("preview", preview))
+
+	return withRun(ctx, "rollback", fs, func(run *runs.Run) error {
+		if err := os.Setenv(EnvRollback, to); err != nil {
+			return fmt.Errorf("failed to set %s: %w", EnvRollback, err)
+		}
+		if err := SelectRollback(ctx, &config.DefaultConfig); err != nil {
+			return err
+		}
+		return deployStack(ctx, logger, opts, run, stackName, workDir, preview, report.FormatText)
+	})
+}
+
+// SelectRollback replaces the configuration with the one recorded in the state version
+// set in EnvRollback, so the Pulumi program run by a rollback deploys it. Without a
+// rollback, the configuration is left unchanged.
+func SelectRollback(ctx context.Context, cfg *config.OrganizationConfig) error {
+	version := os.Getenv(EnvRollback)
+	if version == "" {
+		return nil
+	}
+
+	manager, err := state.NewManager(ctx, state.OptionsFor(cfg.LandingZoneConfig)...)
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+
+	stored, err := manager.LoadVersion(ctx, version)
+	if err != nil {
+		return err
+	}
+	rollback, err := storedConfig(stored)
+	if err != nil {
+		return err
+	}
+	if err := rollback.Validate(); err != nil {
+		return fmt.Errorf("configuration of state version %s is invalid: %w", version, err)
+	}
+
+	cfg.Replace(rollback)
+	return nil
+}
+
+// writeVersions writes the versions of the state history as a table
+func writeVersions(w io.Writer, versions []state.Version) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tTIMESTAMP\tKIND")
+	for _, version := range versions {
+		kind := "state"
+		if version.Backup {
+			kind = "backup"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", version.ID, version.Timestamp.Format(time.RFC3339), kind)
+	}
+	return tw.Flush()
+}
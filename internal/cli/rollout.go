@@ -0,0 +1,114 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/engine"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/policies"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/rollout"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"go.uber.org/zap"
+)
+
+// upStaged applies the stack. With a canary or a staged rollout configured, the
+// policies the update creates, changes or newly attaches and the changes of the
+// resources of member accounts reach the canary first, then the waves of the rollout
+// one after the other, each baking before the next, and the rest of the organization
+// last. A failed canary, a firing alarm or a drift increase stops the rollout.
+func upStaged(ctx context.Context, logger *zap.Logger, runner *engine.Runner) (auto.UpResult, error) {
+	cfg := config.DefaultConfig.LandingZoneConfig
+	if cfg.Canary == nil && cfg.Rollout == nil {
+		return runner.Up(ctx)
+	}
+
+	changes, err := runner.PreviewChanges(ctx)
+	if err != nil {
+		return auto.UpResult{}, err
+	}
+	if policies.NewStage(changes, nil).Empty() && !accountChanges(changes) {
+		logger.Info("no policy or account changes to stage")
+		return runner.Up(ctx)
+	}
+
+	accounts, err := rollout.NewAccounts(ctx, cfg)
+	if err != nil {
+		return auto.UpResult{}, err
+	}
+
+	var reached []string
+	if cfg.Canary != nil {
+		reached = append(reached, cfg.Canary.Target)
+		if err := validateCanary(ctx, logger, runner, accounts, policies.NewStage(changes, reached)); err != nil {
+			return auto.UpResult{}, err
+		}
+	}
+
+	if cfg.Rollout != nil {
+		watcher, err := rollout.NewWatcher(ctx, cfg)
+		if err != nil {
+			return auto.UpResult{}, err
+		}
+		if err := watcher.Start(ctx); err != nil {
+			return auto.UpResult{}, err
+		}
+
+		for _, wave := range cfg.Rollout.Waves {
+			reached = append(reached, wave.Targets...)
+			logger.Info("rolling out wave",
+				zap.String("wave", wave.Name),
+				zap.Strings("targets", wave.Targets))
+			if _, err := upStage(ctx, runner, accounts, policies.NewStage(changes, reached)); err != nil {
+				return auto.UpResult{}, fmt.Errorf("rollout wave %s failed: %w", wave.Name, err)
+			}
+			if err := watcher.Bake(ctx, wave.Name, cfg.Rollout.Bake(wave)); err != nil {
+				logger.Error("rollout halted", zap.String("wave", wave.Name), zap.Error(err))
+				return auto.UpResult{}, err
+			}
+		}
+	}
+
+	logger.Info("rolling out to the organization")
+	return runner.Up(ctx)
+}
+
+// upStage applies the stack with the changes of the policies limited to a stage and
+// the changes of the resources of the accounts the stage does not reach held back
+func upStage(ctx context.Context, runner *engine.Runner, accounts *rollout.Accounts, stage *policies.Stage) (auto.UpResult, error) {
+	reached, err := accounts.Reached(ctx, stage.Targets)
+	if err != nil {
+		return auto.UpResult{}, err
+	}
+
+	env, err := stage.Env()
+	if err != nil {
+		return auto.UpResult{}, err
+	}
+	if err := runner.SetEnv(env); err != nil {
+		return auto.UpResult{}, err
+	}
+	runner.HoldBack(rollout.Hold(reached))
+	defer func() {
+		runner.UnsetEnv(policies.EnvStage)
+		runner.HoldBack(nil)
+	}()
+
+	return runner.Up(ctx)
+}
+
+// accountChanges reports whether changes include resources of member accounts
+func accountChanges(changes []plan.Change) bool {
+	hold := rollout.Hold(nil)
+	for _, change := range changes {
+		if hold(change.URN, change.Type) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runs"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "runs",
+		Description: "audit the history of the deploy, drift and import runs: list, show",
+		Run:         runRuns,
+	})
+}
+
+// runRuns dispatches the runs sub-commands
+func runRuns(ctx context.Context, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no runs command specified")
+	}
+
+	switch args[0] {
+	case "list":
+		return runRunsList(ctx, args[1:])
+	case "show":
+		return runRunsShow(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown runs command %q", args[0])
+	}
+}
+
+// runRunsList implements the runs list command
+func runRunsList(ctx context.Context, args []string) error {
+	var command, format string
+	var limit int
+	fs := flag.NewFlagSet("runs list", flag.ContinueOnError)
+	fs.StringVar(&command, "command", "", "only list the runs of a command: deploy, drift or config-import")
+	fs.IntVar(&limit, "limit", runs.DefaultLimit, "maximum number of runs listed, newest first")
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := runs.NewStore(ctx, runs.OptionsFor(config.DefaultConfig.LandingZoneConfig)...)
+	if err != nil {
+		return err
+	}
+	list, err := store.List(ctx, command, limit)
+	if err != nil {
+		return err
+	}
+	return runs.Write(os.Stdout, format, list)
+}
+
+// runRunsShow implements the runs show command
+func runRunsShow(ctx context.Context, args []string) error {
+	var format string
+	fs := flag.NewFlagSet("runs show", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: runs show [--format text|json] RUN_ID")
+	}
+
+	store, err := runs.NewStore(ctx, runs.OptionsFor(config.DefaultConfig.LandingZoneConfig)...)
+	if err != nil {
+		return err
+	}
+	run, err := store.Get(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return runs.WriteRun(os.Stdout, format, run)
+}
+
+// withRun runs fn as a run of a command recorded in the run history, with the values
+// of the flags of the command as its parameters. Only the DynamoDB state backend keeps
+// the history, and read-only runs are not recorded. A run that cannot be recorded is
+// logged and still executed.
+func withRun(ctx context.Context, command string, fs *flag.FlagSet, fn func(*runs.Run) error) error {
+	parameters := map[string]string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		if value := f.Value.String(); value != "" {
+			parameters[f.Name] = value
+		}
+	})
+	run := runs.New(command, parameters)
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	backend := cfg.StateBackend
+	if (backend != "" && backend != config.StateBackendDynamoDB) || !cfg.RunHistory.Enabled() || readonly.Enabled() {
+		return fn(run)
+	}
+
+	logger, err := logging.NewLogger("runs")
+	if err != nil {
+		return err
+	}
+
+	store, err := runs.NewStore(ctx, runs.OptionsFor(cfg)...)
+	if err == nil {
+		err = store.Start(ctx, run)
+	}
+	if err != nil {
+		logger.Warn("failed to record run, continuing unrecorded", zap.Error(err))
+		return fn(run)
+	}
+
+	runErr := fn(run)
+	if err := store.Finish(context.WithoutCancel(ctx), run, runErr); err != nil {
+		logger.Warn("failed to record result of run", zap.String("run", run.ID), zap.Error(err))
+	}
+	return runErr
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/grpcapi"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/stream"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// Default address of the gRPC organization service, reachable from the host only
+	defaultGRPCAddress = "localhost:50051"
+
+	// Default variable holding the bearer token of the gRPC organization service
+	defaultGRPCTokenEnv = "LANDING_ZONE_GRPC_TOKEN"
+
+	// Default address of the progress endpoints, reachable from the host only
+	defaultHTTPAddress = "localhost:8080"
+)
+
+func init() {
+	register(&Command{
+		Name:        "serve",
+		Description: "serve the gRPC organization service and the deployment progress stream until interrupted",
+		Run:         runServe,
+	})
+}
+
+// runServe implements the serve command. The servers are not bound by the deadline of
+// a deployment: they run until interrupted, each preview bounded by the deadline instead.
+func runServe(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("serve")
+	if err != nil {
+		return err
+	}
+
+	stackDefault := os.Getenv("PULUMI_STACK")
+	if stackDefault == "" {
+		stackDefault = environmentStack(&config.DefaultConfig, defaultStackName)
+	}
+
+	var address, httpAddress, stackName, stacks, workDir, certFile, keyFile, clientCAFile, tokenEnv string
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.StringVar(&address, "grpc-addr", defaultGRPCAddress, "address the gRPC organization service listens on")
+	fs.StringVar(&httpAddress, "http-addr", defaultHTTPAddress, "address the progress stream listens on, empty to disable it")
+	fs.StringVar(&stackName, "stack", stackDefault, "Pulumi stack previewed by default")
+	fs.StringVar(&stacks, "stacks", "", "comma-separated Pulumi stacks Plan requests may preview besides --stack")
+	fs.StringVar(&workDir, "dir", ".", "directory containing the Pulumi project")
+	fs.StringVar(&certFile, "tls-cert", "", "TLS certificate of the server")
+	fs.StringVar(&keyFile, "tls-key", "", "TLS private key of the server")
+	fs.StringVar(&clientCAFile, "tls-client-ca", "", "CA bundle client certificates are verified with, requiring mutual TLS")
+	fs.StringVar(&tokenEnv, "token-env", defaultGRPCTokenEnv, "environment variable holding the bearer token callers present")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	serverOpts, err := grpcCredentials(certFile, keyFile, clientCAFile)
+	if err != nil {
+		return err
+	}
+	token := os.Getenv(tokenEnv)
+	if token == "" && clientCAFile == "" {
+		if !loopback(address) {
+			return fmt.Errorf("serving on %s requires mutual TLS (--tls-client-ca) or a bearer token in %s", address, tokenEnv)
+		}
+		logger.Warn("serving without authentication, the service is only reachable from this host")
+	}
+
+	grpcOpts := []func(*grpcapi.Server) error{grpcapi.WithToken(token)}
+	if stacks != "" {
+		grpcOpts = append(grpcOpts, grpcapi.WithStacks(strings.Split(stacks, ",")...))
+	}
+
+	ctx, stop := signal.NotifyContext(context.WithoutCancel(ctx), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logging.HandleSIGHUP(ctx)
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	server, err := grpcapi.NewServer(ctx, cfg, stackName, workDir, cfg.Timeouts.Deadline(defaultDeadline), grpcOpts...)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	defer listener.Close()
+
+	var progressServer *stream.Server
+	var httpListener net.Listener
+	if httpAddress != "" {
+		progressToken := cfg.ProgressStream.Token()
+		if progressToken == "" && !loopback(httpAddress) {
+			return fmt.Errorf("serving the progress stream on %s requires a token in the variable of progressStream.tokenEnv", httpAddress)
+		}
+		if progressServer, err = stream.NewServer(progressToken); err != nil {
+			return err
+		}
+		if httpListener, err = net.Listen("tcp", httpAddress); err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", httpAddress, err)
+		}
+		defer httpListener.Close()
+	}
+
+	// The servers stop together: when one fails, the other is stopped
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, 2)
+	servers := 1
+	go func() {
+		errs <- server.Serve(ctx, listener, serverOpts...)
+	}()
+	if progressServer != nil {
+		servers++
+		go func() {
+			errs <- progressServer.Serve(ctx, httpListener)
+		}()
+	}
+
+	logger.Info("serving organization service",
+		zap.String("address", listener.Addr().String()),
+		zap.String("progressAddress", httpAddress),
+		zap.String("stack", stackName),
+		zap.Bool("tls", certFile != ""),
+		zap.Bool("mutualTLS", clientCAFile != ""),
+		zap.Bool("token", token != ""))
+
+	var result error
+	for i := 0; i < servers; i++ {
+		if err := <-errs; err != nil && result == nil {
+			result = err
+			cancel()
+		}
+	}
+	return result
+}
+
+// grpcCredentials returns the TLS credentials of the gRPC server, verifying the client
+// certificates when a client CA bundle is given. Without certificate the server
+// listens in plaintext.
+func grpcCredentials(certFile, keyFile, clientCAFile string) ([]grpc.ServerOption, error) {
+	switch {
+	case certFile == "" && keyFile == "" && clientCAFile == "":
+		return nil, nil
+	case certFile == "" || keyFile == "":
+		return nil, fmt.Errorf("--tls-cert and --tls-key must be given together, and with --tls-client-ca")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS credentials: %w", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in client CA bundle %s", clientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(tlsConfig))}, nil
+}
+
+// loopback reports whether an address only accepts connections from the host
+func loopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
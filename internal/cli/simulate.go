@@ -0,0 +1,83 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/simulate"
+)
+
+func init() {
+	register(&Command{
+		Name:        "simulate",
+		Description: "analyze a change to the organization before making it: move",
+		Run:         runSimulate,
+	})
+}
+
+// runSimulate dispatches the simulate sub-commands
+func runSimulate(ctx context.Context, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no simulate command specified")
+	}
+
+	switch args[0] {
+	case "move":
+		return runSimulateMove(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown simulate command %q", args[0])
+	}
+}
+
+// runSimulateMove implements the simulate move command. It reports the SCPs, tag
+// policies, controls, baselines and blueprints an account would gain and lose moving
+// to another OU, without moving it.
+func runSimulateMove(ctx context.Context, args []string) error {
+	logger, err := logging.NewLogger("simulate-move")
+	if err != nil {
+		return err
+	}
+
+	var format string
+	fs := flag.NewFlagSet("simulate move", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch format {
+	case report.FormatText, report.FormatJSON:
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: simulate move [flags] <account> <ou>")
+	}
+	accountID, target := fs.Arg(0), fs.Arg(1)
+	if !accountIdRE.MatchString(accountID) {
+		return fmt.Errorf("invalid account ID %q", accountID)
+	}
+
+	var move *simulate.Move
+	if err := withCache(ctx, logger, func(cache *orgcache.Cache) error {
+		simulator, err := simulate.NewSimulator(ctx, config.DefaultConfig.LandingZoneConfig, cache)
+		if err != nil {
+			return err
+		}
+		move, err = simulator.Move(ctx, accountID, target)
+		return err
+	}); err != nil {
+		return err
+	}
+	return simulate.Write(os.Stdout, format, move)
+}
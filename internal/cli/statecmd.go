@@ -0,0 +1,45 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+)
+
+func init() {
+	register(&Command{
+		Name:        "state",
+		Description: "manage the stored state: bootstrap, cleanup, clear-cache",
+		Run:         runState,
+	})
+}
+
+// runState dispatches the state sub-commands
+func runState(ctx context.Context, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no state command specified")
+	}
+
+	manager, err := state.NewManager(ctx, state.OptionsFor(config.DefaultConfig.LandingZoneConfig)...)
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+
+	switch args[0] {
+	case "bootstrap":
+		return manager.Bootstrap(ctx)
+	case "cleanup":
+		return manager.CleanupOldStates(ctx)
+	case "clear-cache":
+		return clearCache(ctx)
+	default:
+		return fmt.Errorf("unknown state command %q", args[0])
+	}
+}
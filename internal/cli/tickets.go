@@ -0,0 +1,55 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/tickets"
+	"go.uber.org/zap"
+)
+
+// withChangeTickets runs apply between the opening and approval of the change tickets of
+// the stack, holding the planned changes, and their closing with the result. Without a
+// ticket system tracking the stack, apply runs alone and no state is opened.
+func withChangeTickets(ctx context.Context, logger *zap.Logger, stack, modules string,
+	preview func(context.Context) ([]plan.Change, error), apply func() error) error {
+	cfg := config.DefaultConfig.LandingZoneConfig
+	if len(cfg.ChangeTicketsFor(stack)) == 0 {
+		return apply()
+	}
+
+	manager, err := state.NewManager(ctx, state.OptionsFor(cfg)...)
+	if err != nil {
+		return err
+	}
+	defer manager.Close()
+
+	ticketManager, err := tickets.NewManager(cfg, stack, manager)
+	if err != nil {
+		return err
+	}
+
+	changes, err := preview(ctx)
+	if err != nil {
+		return err
+	}
+	if err := ticketManager.Open(ctx, modules, changes); err != nil {
+		return err
+	}
+
+	applyErr := apply()
+	if err := ticketManager.Close(ctx, applyErr); err != nil {
+		if applyErr != nil {
+			logger.Error("failed to close change tickets", zap.Error(err))
+			return applyErr
+		}
+		return err
+	}
+	return applyErr
+}
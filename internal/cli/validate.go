@@ -0,0 +1,93 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+// errInvalidConfig is returned when validation fails, so the exit status reflects it
+var errInvalidConfig = errors.New("configuration is invalid")
+
+func init() {
+	register(&Command{
+		Name:        "validate",
+		Description: "validate a configuration file and report every invalid section",
+		Run:         runValidate,
+	})
+}
+
+// runValidate implements the validate command
+func runValidate(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("validate")
+	if err != nil {
+		return err
+	}
+
+	var path, format string
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.StringVar(&path, "config", "", "JSON configuration file to validate")
+	fs.StringVar(&format, "format", report.FormatText, "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("--config is required")
+	}
+	if format != report.FormatText && format != report.FormatJSON {
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	doc := plan.New("validate")
+	cfg, err := config.ReadFile(path)
+	if err == nil && opts.Organization != "" {
+		err = cfg.Select(opts.Organization)
+	}
+	if err != nil {
+		doc.Fail(err)
+	} else {
+		for _, verr := range cfg.ValidateAll() {
+			doc.AddValidationErrors(plan.ValidationError{
+				Section: verr.Section,
+				Message: verr.Err.Error(),
+			})
+		}
+	}
+
+	logger.Info("configuration validated",
+		zap.String("path", path),
+		zap.String("status", string(doc.Status)))
+
+	if format == report.FormatJSON {
+		if writeErr := doc.Write(os.Stdout); writeErr != nil {
+			return writeErr
+		}
+	} else {
+		for _, verr := range doc.ValidationErrors {
+			fmt.Fprintf(os.Stdout, "%s: %s\n", verr.Section, verr.Message)
+		}
+		if doc.Status == plan.StatusOK {
+			fmt.Fprintf(os.Stdout, "%s is valid\n", path)
+		}
+	}
+
+	switch {
+	case err != nil:
+		return err
+	case len(doc.ValidationErrors) > 0:
+		return errInvalidConfig
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/probe"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/vending"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "vending",
+		Description: "vend accounts through the Step Functions state machine: start, worker",
+		Run:         runVending,
+	})
+}
+
+// runVending dispatches the vending sub-commands
+func runVending(ctx context.Context, opts *Options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no vending command specified")
+	}
+
+	switch args[0] {
+	case "start":
+		return runVendingStart(ctx, args[1:])
+	case "worker":
+		return runVendingWorker(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown vending command %q", args[0])
+	}
+}
+
+// runVendingStart implements the vending start command
+func runVendingStart(ctx context.Context, args []string) error {
+	logger, err := logging.NewLogger("vending-start")
+	if err != nil {
+		return err
+	}
+
+	var request vending.Request
+	fs := flag.NewFlagSet("vending start", flag.ContinueOnError)
+	fs.StringVar(&request.AccountName, "name", "", "name of the account")
+	fs.StringVar(&request.Email, "email", "", "email address of the account root user")
+	fs.StringVar(&request.OUName, "ou", "", "name of the OU enrolling the account")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	worker, err := vending.NewWorker(ctx, config.DefaultConfig.LandingZoneConfig)
+	if err != nil {
+		return err
+	}
+
+	arn, err := worker.Start(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stdout, arn)
+	logger.Info("account vending started",
+		zap.String("accountName", request.AccountName),
+		zap.String("execution", arn))
+	return nil
+}
+
+// runVendingWorker implements the vending worker command. The worker outlives the
+// timeout of other commands and runs until it is interrupted, then drains the steps in
+// flight; a task it abandons is retried by the state machine once its heartbeat times
+// out. With --probe-addr it serves liveness and readiness probes for ECS and
+// Kubernetes. SIGHUP toggles debug logs.
+func runVendingWorker(ctx context.Context, args []string) error {
+	logger, err := logging.NewLogger("vending-worker")
+	if err != nil {
+		return err
+	}
+
+	var probeAddr string
+	var drain time.Duration
+	fs := flag.NewFlagSet("vending worker", flag.ContinueOnError)
+	fs.StringVar(&probeAddr, "probe-addr", os.Getenv(probe.EnvAddr),
+		"address serving "+probe.PathHealth+" and "+probe.PathReady+", such as :8080 (env "+probe.EnvAddr+")")
+	fs.DurationVar(&drain, "drain-timeout", vending.DefaultDrainTimeout,
+		"time the steps in flight are given to finish once the worker is stopped")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.DefaultConfig.LandingZoneConfig
+	p := probe.New()
+	workerOpts := []func(*vending.Worker) error{vending.WithDrainTimeout(drain), vending.WithProbe(p)}
+
+	// The service quota requests of new accounts are tracked in the state
+	if cfg.Baseline != nil && len(cfg.Baseline.ServiceQuotas) > 0 {
+		manager, err := state.NewManager(ctx, state.OptionsFor(cfg)...)
+		if err != nil {
+			return err
+		}
+		defer manager.Close()
+		workerOpts = append(workerOpts, vending.WithQuotaStore(ctx, manager))
+	}
+
+	worker, err := vending.NewWorker(ctx, cfg, workerOpts...)
+	if err != nil {
+		return err
+	}
+
+	workerCtx, stop := signal.NotifyContext(context.WithoutCancel(ctx), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logging.HandleSIGHUP(workerCtx)
+
+	// The probes keep answering while the worker drains
+	probeCtx, stopProbes := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopProbes()
+	probeErr := make(chan error, 1)
+	if probeAddr != "" {
+		go func() {
+			probeErr <- p.Serve(probeCtx, probeAddr, logger)
+		}()
+	}
+
+	runErr := worker.Run(workerCtx)
+	stopProbes()
+	if probeAddr != "" {
+		if err := <-probeErr; err != nil && runErr == nil {
+			return err
+		}
+	}
+	return runErr
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/compliance"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"go.uber.org/zap"
+)
+
+func init() {
+	register(&Command{
+		Name:        "verify-baselines",
+		Description: "check the baseline roles, Config recorder, GuardDuty membership, password policy and root activity alarm of every account",
+		Run:         runVerifyBaselines,
+	})
+}
+
+// runVerifyBaselines implements the verify-baselines command. It fails when an
+// account misses a baseline artifact.
+func runVerifyBaselines(ctx context.Context, opts *Options, args []string) error {
+	logger, err := logging.NewLogger("verify-baselines")
+	if err != nil {
+		return err
+	}
+
+	var format, output string
+	fs := flag.NewFlagSet("verify-baselines", flag.ContinueOnError)
+	fs.StringVar(&format, "format", report.FormatText, "matrix format: text or json")
+	fs.StringVar(&output, "output", "", "file to write the matrix to instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	verifier, err := compliance.NewVerifier(ctx, config.DefaultConfig.LandingZoneConfig)
+	if err != nil {
+		return err
+	}
+
+	matrix, err := verifier.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create matrix file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := matrix.Write(w, format); err != nil {
+		return err
+	}
+
+	logger.Info("account baselines verified",
+		zap.Int("accounts", len(matrix.Accounts)),
+		zap.Int("failed", matrix.Failed()))
+
+	if failed := matrix.Failed(); failed > 0 {
+		return fmt.Errorf("%d accounts failed baseline verification", failed)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package compliance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+const (
+	// Check names used in the report
+	baselineCheckName   = "account-baseline"
+	CheckAccountAlias   = "account-alias"
+	CheckPasswordPolicy = "password-policy"
+
+	// Account alias length limits
+	minAliasLength = 3
+	maxAliasLength = 63
+)
+
+// invalidAliasChars matches the characters not allowed in account aliases
+var invalidAliasChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// aliasInput holds the fields available to the account alias template
+type aliasInput struct {
+	AccountId   string
+	AccountName string
+}
+
+// BaselineCheck sets the account alias and IAM password policy of member accounts. In
+// dry-run mode it only reports the accounts that differ from the baseline.
+type BaselineCheck struct {
+	alias  string
+	policy *config.PasswordPolicyConfig
+	apply  bool
+}
+
+// NewBaselineCheck creates the account baseline step. Nothing is changed unless apply is set.
+func NewBaselineCheck(cfg *config.LandingZoneConfig, apply bool) *BaselineCheck {
+	c := &BaselineCheck{apply: apply}
+	if cfg.Baseline != nil {
+		c.alias = cfg.Baseline.AccountAliasTemplate
+		c.policy = cfg.Baseline.PasswordPolicy
+	}
+	return c
+}
+
+// Name returns the identifier of the check
+func (c *BaselineCheck) Name() string {
+	return baselineCheckName
+}
+
+// Run applies or verifies the baseline of a member account. The management account is
+// not vended and is skipped.
+func (c *BaselineCheck) Run(ctx context.Context, account Account, cfg aws.Config) ([]report.Finding, error) {
+	if account.Management {
+		return nil, nil
+	}
+
+	client := iam.NewFromConfig(cfg)
+	var findings []report.Finding
+
+	if c.alias != "" {
+		if finding := c.baselineAlias(ctx, client, account); finding != nil {
+			findings = append(findings, *finding)
+		}
+	}
+
+	if c.policy != nil {
+		if finding := c.baselinePasswordPolicy(ctx, client, account); finding != nil {
+			findings = append(findings, *finding)
+		}
+	}
+
+	return findings, nil
+}
+
+// baselineAlias sets the account alias rendered from the template, replacing any other alias
+func (c *BaselineCheck) baselineAlias(ctx context.Context, client *iam.Client, account Account) *report.Finding {
+	finding := func(severity report.Severity, message string) *report.Finding {
+		return &report.Finding{
+			AccountID: account.ID,
+			Check:     CheckAccountAlias,
+			Severity:  severity,
+			Resource:  account.Name,
+			Message:   message,
+		}
+	}
+
+	alias, err := c.renderAlias(account)
+	if err != nil {
+		return finding(report.SeverityHigh, err.Error())
+	}
+
+	out, err := client.ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
+	if err != nil {
+		return finding(report.SeverityHigh, fmt.Sprintf("failed to list account aliases: %v", err))
+	}
+
+	current := ""
+	if len(out.AccountAliases) > 0 {
+		current = out.AccountAliases[0]
+	}
+	if current == alias {
+		return nil
+	}
+
+	if !c.apply {
+		return finding(report.SeverityMedium, fmt.Sprintf("account alias is %q instead of %q", current, alias))
+	}
+
+	// An account has at most one alias
+	if current != "" {
+		if _, err := client.DeleteAccountAlias(ctx, &iam.DeleteAccountAliasInput{
+			AccountAlias: aws.String(current),
+		}); err != nil {
+			return finding(report.SeverityHigh, fmt.Sprintf("failed to remove account alias %s: %v", current, err))
+		}
+	}
+
+	if _, err := client.CreateAccountAlias(ctx, &iam.CreateAccountAliasInput{
+		AccountAlias: aws.String(alias),
+	}); err != nil {
+		return finding(report.SeverityHigh, fmt.Sprintf("failed to set account alias %s: %v", alias, err))
+	}
+
+	return nil
+}
+
+// renderAlias renders the alias template and normalizes the result to the characters
+// allowed in account aliases
+func (c *BaselineCheck) renderAlias(account Account) (string, error) {
+	tmpl, err := template.New("alias").Parse(c.alias)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse account alias template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, aliasInput{AccountId: account.ID, AccountName: account.Name}); err != nil {
+		return "", fmt.Errorf("failed to render account alias: %w", err)
+	}
+
+	alias := invalidAliasChars.ReplaceAllString(strings.ToLower(buf.String()), "-")
+	alias = strings.Trim(alias, "-")
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return "", fmt.Errorf("account alias %q must be between %d and %d characters",
+			alias, minAliasLength, maxAliasLength)
+	}
+	return alias, nil
+}
+
+// baselinePasswordPolicy applies the configured IAM password policy
+func (c *BaselineCheck) baselinePasswordPolicy(ctx context.Context, client *iam.Client, account Account) *report.Finding {
+	finding := func(severity report.Severity, message string) *report.Finding {
+		return &report.Finding{
+			AccountID: account.ID,
+			Check:     CheckPasswordPolicy,
+			Severity:  severity,
+			Resource:  account.Name,
+			Message:   message,
+		}
+	}
+
+	out, err := client.GetAccountPasswordPolicy(ctx, &iam.GetAccountPasswordPolicyInput{})
+	var noPolicy *iamtypes.NoSuchEntityException
+	switch {
+	case errors.As(err, &noPolicy):
+		out = &iam.GetAccountPasswordPolicyOutput{}
+	case err != nil:
+		return finding(report.SeverityHigh, fmt.Sprintf("failed to read password policy: %v", err))
+	}
+
+	if c.matchesPolicy(out.PasswordPolicy) {
+		return nil
+	}
+
+	if !c.apply {
+		return finding(report.SeverityMedium, "password policy differs from the baseline")
+	}
+
+	p := c.policy
+	input := &iam.UpdateAccountPasswordPolicyInput{
+		MinimumPasswordLength:      aws.Int32(int32(p.MinimumPasswordLength)),
+		RequireSymbols:             p.RequireSymbols,
+		RequireNumbers:             p.RequireNumbers,
+		RequireUppercaseCharacters: p.RequireUppercaseCharacters,
+		RequireLowercaseCharacters: p.RequireLowercaseCharacters,
+		AllowUsersToChangePassword: p.AllowUsersToChangePassword,
+		HardExpiry:                 aws.Bool(p.HardExpiry),
+	}
+	if p.MaxPasswordAge > 0 {
+		input.MaxPasswordAge = aws.Int32(int32(p.MaxPasswordAge))
+	}
+	if p.PasswordReusePrevention > 0 {
+		input.PasswordReusePrevention = aws.Int32(int32(p.PasswordReusePrevention))
+	}
+
+	if _, err := client.UpdateAccountPasswordPolicy(ctx, input); err != nil {
+		return finding(report.SeverityHigh, fmt.Sprintf("failed to apply password policy: %v", err))
+	}
+
+	return nil
+}
+
+// matchesPolicy reports whether the current password policy equals the baseline
+func (c *BaselineCheck) matchesPolicy(current *iamtypes.PasswordPolicy) bool {
+	if current == nil {
+		return false
+	}
+
+	p := c.policy
+	return int(aws.ToInt32(current.MinimumPasswordLength)) == p.MinimumPasswordLength &&
+		current.RequireSymbols == p.RequireSymbols &&
+		current.RequireNumbers == p.RequireNumbers &&
+		current.RequireUppercaseCharacters == p.RequireUppercaseCharacters &&
+		current.RequireLowercaseCharacters == p.RequireLowercaseCharacters &&
+		current.AllowUsersToChangePassword == p.AllowUsersToChangePassword &&
+		int(aws.ToInt32(current.MaxPasswordAge)) == p.MaxPasswordAge &&
+		int(aws.ToInt32(current.PasswordReusePrevention)) == p.PasswordReusePrevention &&
+		aws.ToBool(current.HardExpiry) == p.HardExpiry
+}
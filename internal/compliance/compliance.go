@@ -0,0 +1,213 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package compliance provides compliance checks run against every account of the organization.
+// Version: 1.0.0
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// Maximum number of accounts checked in parallel
+	maxConcurrentAccounts = 5
+
+	// Check reported when an account could not be checked
+	checkErrorName = "check-error"
+)
+
+// Account identifies a member account being checked
+type Account struct {
+	ID         string
+	Name       string
+	Management bool
+}
+
+// Check is a single compliance check run against an account
+type Check interface {
+	// Name returns the identifier of the check used in the report
+	Name() string
+	// Run checks the account with the given SDK configuration, which holds
+	// credentials valid in that account
+	Run(ctx context.Context, account Account, cfg aws.Config) ([]report.Finding, error)
+}
+
+// Auditor runs compliance checks against every active account of the organization
+type Auditor struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	base      aws.Config
+	orgClient *organizations.Client
+	cache     *orgcache.Cache
+	roleName  string
+	checks    []Check
+}
+
+// NewAuditor creates a new auditor instance with the given checks
+func NewAuditor(ctx context.Context, cfg *config.LandingZoneConfig, checks ...Check) (*Auditor, error) {
+	logger, err := logging.NewLogger("compliance")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("compliance")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Auditor{
+		logger:    logger,
+		metrics:   metrics,
+		base:      base,
+		orgClient: organizations.NewFromConfig(base),
+		roleName:  awsclient.MemberRoleName(cfg),
+		checks:    checks,
+	}, nil
+}
+
+// UseCache lists the accounts of the organization through the organization cache
+func (a *Auditor) UseCache(cache *orgcache.Cache) {
+	a.cache = cache
+}
+
+// DefaultChecks returns the checks run by the report command
+func DefaultChecks(cfg *config.LandingZoneConfig) []Check {
+	checks := []Check{
+		NewCredentialCheck(cfg),
+	}
+	if cfg.Baseline != nil {
+		checks = append(checks, NewBaselineCheck(cfg, false))
+	}
+	return checks
+}
+
+// Run checks every active account and adds the findings to the report. Accounts
+// that cannot be checked are reported rather than aborting the run.
+func (a *Auditor) Run(ctx context.Context, r *report.Report) error {
+	start := time.Now()
+	defer func() {
+		a.metrics.RecordDuration("audit_duration", time.Since(start))
+	}()
+
+	accounts, err := a.listAccounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.logger.Info("running compliance checks",
+		zap.Int("accounts", len(accounts)),
+		zap.Int("checks", len(a.checks)))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentAccounts)
+	for _, account := range accounts {
+		wg.Add(1)
+		go func(account Account) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			a.checkAccount(ctx, account, r)
+		}(account)
+	}
+	wg.Wait()
+
+	a.metrics.IncrementCounter("audits_completed")
+	return nil
+}
+
+// checkAccount runs every check against a single account
+func (a *Auditor) checkAccount(ctx context.Context, account Account, r *report.Report) {
+	cfg := a.base
+	if !account.Management {
+		cfg = awsclient.AssumeRole(a.base, account.ID, a.roleName)
+	}
+
+	for _, check := range a.checks {
+		findings, err := check.Run(ctx, account, cfg)
+		if err != nil {
+			a.logger.Error("compliance check failed",
+				zap.String("accountId", account.ID),
+				zap.String("check", check.Name()),
+				zap.Error(err))
+			a.metrics.IncrementCounter("check_errors")
+			r.Add(report.Finding{
+				AccountID: account.ID,
+				Check:     checkErrorName,
+				Severity:  report.SeverityHigh,
+				Resource:  check.Name(),
+				Message:   err.Error(),
+			})
+			continue
+		}
+		r.Add(findings...)
+	}
+}
+
+// listAccounts returns the active accounts of the organization
+func (a *Auditor) listAccounts(ctx context.Context) ([]Account, error) {
+	org, err := a.orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe organization: %w", err)
+	}
+	managementID := aws.ToString(org.Organization.MasterAccountId)
+
+	members, err := a.listMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []Account
+	for _, account := range members {
+		if account.Status != orgtypes.AccountStatusActive {
+			continue
+		}
+		id := aws.ToString(account.Id)
+		accounts = append(accounts, Account{
+			ID:         id,
+			Name:       aws.ToString(account.Name),
+			Management: id == managementID,
+		})
+	}
+
+	return accounts, nil
+}
+
+// listMembers returns every account of the organization, through the cache when set
+func (a *Auditor) listMembers(ctx context.Context) ([]orgtypes.Account, error) {
+	if a.cache != nil {
+		return a.cache.Accounts(ctx)
+	}
+
+	var members []orgtypes.Account
+	paginator := organizations.NewListAccountsPaginator(a.orgClient, &organizations.ListAccountsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list accounts: %w", err)
+		}
+		members = append(members, page.Accounts...)
+	}
+	return members, nil
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package compliance
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+const (
+	// Check names used in the report
+	credentialCheckName = "credential-hygiene"
+	CheckRootAccessKeys = "root-access-keys"
+	CheckRootMFA        = "root-mfa"
+	CheckStaleUsers     = "stale-iam-users"
+
+	// DefaultStaleUserDays is the inactivity after which an IAM user is reported
+	DefaultStaleUserDays = 90
+
+	// Credential reports are generated asynchronously
+	reportPollInterval = 2 * time.Second
+	reportTimeout      = 2 * time.Minute
+
+	// Credential report columns and values
+	rootUserName      = "<root_account>"
+	columnUser        = "user"
+	columnArn         = "arn"
+	columnCreated     = "user_creation_time"
+	columnMFAActive   = "mfa_active"
+	columnPassword    = "password_enabled"
+	columnPasswordUse = "password_last_used"
+	columnKey1Active  = "access_key_1_active"
+	columnKey1Used    = "access_key_1_last_used_date"
+	columnKey2Active  = "access_key_2_active"
+	columnKey2Used    = "access_key_2_last_used_date"
+)
+
+// CredentialCheck reads the IAM credential report of an account to detect root access
+// keys, a root user without MFA and IAM users that have not been used recently
+type CredentialCheck struct {
+	staleAfter time.Duration
+}
+
+// NewCredentialCheck creates a credential hygiene check
+func NewCredentialCheck(cfg *config.LandingZoneConfig) *CredentialCheck {
+	days := DefaultStaleUserDays
+	if cfg.Compliance != nil && cfg.Compliance.StaleUserDays > 0 {
+		days = cfg.Compliance.StaleUserDays
+	}
+	return &CredentialCheck{staleAfter: time.Duration(days) * 24 * time.Hour}
+}
+
+// Name returns the identifier of the check
+func (c *CredentialCheck) Name() string {
+	return credentialCheckName
+}
+
+// Run checks the credential report of the account
+func (c *CredentialCheck) Run(ctx context.Context, account Account, cfg aws.Config) ([]report.Finding, error) {
+	client := iam.NewFromConfig(cfg)
+
+	rows, err := credentialReport(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var findings []report.Finding
+	for _, row := range rows {
+		if row[columnUser] == rootUserName {
+			findings = append(findings, c.checkRoot(account, row)...)
+			continue
+		}
+
+		since, used, ok := lastActivity(row)
+		if !ok || now.Sub(since) <= c.staleAfter {
+			continue
+		}
+
+		message := fmt.Sprintf("credentials last used %s", since.Format(time.RFC3339))
+		if !used {
+			message = fmt.Sprintf("credentials never used since creation on %s", since.Format(time.RFC3339))
+		}
+		findings = append(findings, report.Finding{
+			AccountID: account.ID,
+			Check:     CheckStaleUsers,
+			Severity:  report.SeverityMedium,
+			Resource:  row[columnArn],
+			Message:   message,
+		})
+	}
+
+	return findings, nil
+}
+
+// checkRoot checks the root user row of the credential report
+func (c *CredentialCheck) checkRoot(account Account, row map[string]string) []report.Finding {
+	var findings []report.Finding
+
+	if row[columnKey1Active] == "true" || row[columnKey2Active] == "true" {
+		findings = append(findings, report.Finding{
+			AccountID: account.ID,
+			Check:     CheckRootAccessKeys,
+			Severity:  report.SeverityCritical,
+			Resource:  row[columnArn],
+			Message:   "root user has active access keys",
+		})
+	}
+
+	if row[columnMFAActive] != "true" {
+		findings = append(findings, report.Finding{
+			AccountID: account.ID,
+			Check:     CheckRootMFA,
+			Severity:  report.SeverityCritical,
+			Resource:  row[columnArn],
+			Message:   "root user has no MFA device",
+		})
+	}
+
+	return findings
+}
+
+// credentialReport generates the credential report of the account and returns its rows
+// keyed by column name
+func credentialReport(ctx context.Context, client *iam.Client) ([]map[string]string, error) {
+	deadline := time.Now().Add(reportTimeout)
+	for {
+		out, err := client.GenerateCredentialReport(ctx, &iam.GenerateCredentialReportInput{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate credential report: %w", err)
+		}
+		if out.State == iamtypes.ReportStateTypeComplete {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for credential report")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(reportPollInterval):
+		}
+	}
+
+	out, err := client.GetCredentialReport(ctx, &iam.GetCredentialReportInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential report: %w", err)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(out.Content)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse credential report: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("credential report is empty")
+	}
+
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// lastActivity returns the most recent use of the password or access keys of a user,
+// or its creation time when the credentials were never used. Users without any
+// credentials are skipped.
+func lastActivity(row map[string]string) (since time.Time, used bool, ok bool) {
+	hasCredentials := row[columnPassword] == "true" ||
+		row[columnKey1Active] == "true" ||
+		row[columnKey2Active] == "true"
+	if !hasCredentials {
+		return time.Time{}, false, false
+	}
+
+	for _, column := range []string{columnPasswordUse, columnKey1Used, columnKey2Used} {
+		if t, err := time.Parse(time.RFC3339, row[column]); err == nil && t.After(since) {
+			since = t
+		}
+	}
+	if !since.IsZero() {
+		return since, true, true
+	}
+
+	created, err := time.Parse(time.RFC3339, row[columnCreated])
+	if err != nil {
+		return time.Time{}, false, false
+	}
+	return created, false, true
+}
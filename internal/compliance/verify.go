@@ -0,0 +1,468 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package compliance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/configservice"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/guardduty"
+	gdtypes "github.com/aws/aws-sdk-go-v2/service/guardduty/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"go.uber.org/zap"
+)
+
+// Artifact identifies a baseline artifact verified in every account
+type Artifact string
+
+const (
+	// Verified baseline artifacts
+	ArtifactRoles           Artifact = "roles"
+	ArtifactConfigRecorder  Artifact = "config-recorder"
+	ArtifactGuardDutyMember Artifact = "guardduty-member"
+	ArtifactPasswordPolicy  Artifact = "password-policy"
+	ArtifactRootAlarm       Artifact = "root-alarm"
+
+	// Outcomes of an artifact
+	OutcomePass = "PASS"
+	OutcomeFail = "FAIL"
+	OutcomeSkip = "SKIP"
+)
+
+// Artifacts lists the verified artifacts in matrix column order
+var Artifacts = []Artifact{
+	ArtifactRoles,
+	ArtifactConfigRecorder,
+	ArtifactGuardDutyMember,
+	ArtifactPasswordPolicy,
+	ArtifactRootAlarm,
+}
+
+// Result is the outcome of an artifact in an account
+type Result struct {
+	Outcome string `json:"outcome"`
+	Message string `json:"message,omitempty"`
+}
+
+// AccountResults holds the outcome of every artifact in an account
+type AccountResults struct {
+	AccountID string              `json:"accountId"`
+	Name      string              `json:"name"`
+	Passed    bool                `json:"passed"`
+	Results   map[Artifact]Result `json:"results"`
+}
+
+// Matrix is the per-account outcome of the baseline verification
+type Matrix struct {
+	GeneratedAt time.Time         `json:"generatedAt"`
+	Artifacts   []Artifact        `json:"artifacts"`
+	Accounts    []*AccountResults `json:"accounts"`
+	mutex       sync.Mutex
+}
+
+// Failed returns the number of accounts with a failed artifact
+func (m *Matrix) Failed() int {
+	failed := 0
+	for _, account := range m.Accounts {
+		if !account.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Write renders the matrix in the given format
+func (m *Matrix) Write(w io.Writer, format string) error {
+	switch format {
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(m); err != nil {
+			return fmt.Errorf("failed to encode verification matrix: %w", err)
+		}
+		return nil
+	case report.FormatText, "":
+		return m.writeText(w)
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+}
+
+// writeText renders the matrix as a table followed by the failure details
+func (m *Matrix) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	header := []string{"ACCOUNT", "NAME"}
+	for _, artifact := range m.Artifacts {
+		header = append(header, strings.ToUpper(string(artifact)))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	var details []string
+	for _, account := range m.Accounts {
+		row := []string{account.AccountID, account.Name}
+		for _, artifact := range m.Artifacts {
+			result := account.Results[artifact]
+			row = append(row, result.Outcome)
+			if result.Outcome == OutcomeFail {
+				details = append(details, fmt.Sprintf("%s %s: %s", account.AccountID, artifact, result.Message))
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write verification matrix: %w", err)
+	}
+
+	if len(details) > 0 {
+		fmt.Fprintln(w)
+		for _, detail := range details {
+			fmt.Fprintln(w, detail)
+		}
+	}
+	fmt.Fprintf(w, "\n%d of %d accounts failed verification\n", m.Failed(), len(m.Accounts))
+	return nil
+}
+
+// Verifier checks that the baseline artifacts exist in every account through a
+// read-only role. Nothing is changed.
+type Verifier struct {
+	auditor   *Auditor
+	region    string
+	roles     []string
+	baseline  *BaselineCheck
+	guardDuty bool
+	config    bool
+	adminId   string
+	lz        *config.LandingZoneConfig
+}
+
+// NewVerifier creates a baseline verifier for the landing zone configuration
+func NewVerifier(ctx context.Context, cfg *config.LandingZoneConfig) (*Verifier, error) {
+	auditor, err := NewAuditor(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	auditor.roleName = awsclient.ReadOnlyRoleName(cfg)
+
+	region := cfg.HomeRegion
+	if region == "" && len(cfg.GovernedRegions) > 0 {
+		region = cfg.GovernedRegions[0]
+	}
+
+	v := &Verifier{
+		auditor:   auditor,
+		region:    region,
+		roles:     []string{awsclient.MemberRoleName(cfg)},
+		guardDuty: cfg.EnableGuardDuty,
+		config:    cfg.EnableConfig,
+		adminId:   cfg.SecurityAccountId,
+		lz:        cfg,
+	}
+	if cfg.Baseline != nil && cfg.Baseline.PasswordPolicy != nil {
+		v.baseline = NewBaselineCheck(cfg, false)
+	}
+	return v, nil
+}
+
+// Run verifies every active account and returns the matrix of outcomes
+func (v *Verifier) Run(ctx context.Context) (*Matrix, error) {
+	a := v.auditor
+	start := time.Now()
+	defer func() {
+		a.metrics.RecordDuration("baseline_verification_duration", time.Since(start))
+	}()
+
+	accounts, err := a.listAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a.logger.Info("verifying account baselines",
+		zap.Int("accounts", len(accounts)),
+		zap.String("role", a.roleName))
+
+	m := &Matrix{
+		GeneratedAt: time.Now().UTC(),
+		Artifacts:   Artifacts,
+		Accounts:    make([]*AccountResults, 0, len(accounts)),
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentAccounts)
+	for _, account := range accounts {
+		wg.Add(1)
+		go func(account Account) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results := v.verifyAccount(ctx, account)
+			m.mutex.Lock()
+			m.Accounts = append(m.Accounts, results)
+			m.mutex.Unlock()
+		}(account)
+	}
+	wg.Wait()
+
+	sort.Slice(m.Accounts, func(i, j int) bool {
+		return m.Accounts[i].AccountID < m.Accounts[j].AccountID
+	})
+
+	a.metrics.IncrementCounter("baseline_verifications")
+	return m, nil
+}
+
+// VerifyAccount checks the baseline artifacts of a single member account, as the
+// last step of its vending
+func (v *Verifier) VerifyAccount(ctx context.Context, accountID, name string) *AccountResults {
+	return v.verifyAccount(ctx, Account{ID: accountID, Name: name})
+}
+
+// verifyAccount checks every artifact of a single account
+func (v *Verifier) verifyAccount(ctx context.Context, account Account) *AccountResults {
+	cfg := v.auditor.base.Copy()
+	if !account.Management {
+		cfg = awsclient.AssumeRole(v.auditor.base, account.ID, v.auditor.roleName)
+	}
+	if v.region != "" {
+		cfg.Region = v.region
+	}
+
+	results := &AccountResults{
+		AccountID: account.ID,
+		Name:      account.Name,
+		Passed:    true,
+		Results: map[Artifact]Result{
+			ArtifactRoles:           v.verifyRoles(ctx, account, cfg),
+			ArtifactConfigRecorder:  v.verifyConfigRecorder(ctx, cfg),
+			ArtifactGuardDutyMember: v.verifyGuardDuty(ctx, account, cfg),
+			ArtifactPasswordPolicy:  v.verifyPasswordPolicy(ctx, account, cfg),
+			ArtifactRootAlarm:       v.verifyRootAlarm(ctx, account, cfg),
+		},
+	}
+	for artifact, result := range results.Results {
+		if result.Outcome == OutcomeFail {
+			results.Passed = false
+			v.auditor.logger.Warn("baseline artifact missing",
+				zap.String("accountId", account.ID),
+				zap.String("artifact", string(artifact)),
+				zap.String("message", result.Message))
+		}
+	}
+	return results
+}
+
+// verifyRoles checks that the roles of the baseline exist. The management account is
+// not vended and has no member role.
+func (v *Verifier) verifyRoles(ctx context.Context, account Account, cfg aws.Config) Result {
+	if account.Management {
+		return Result{Outcome: OutcomeSkip, Message: "management account"}
+	}
+
+	client := iam.NewFromConfig(cfg)
+	var missing []string
+	for _, role := range v.roles {
+		_, err := client.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(role)})
+		var notFound *iamtypes.NoSuchEntityException
+		switch {
+		case errors.As(err, &notFound):
+			missing = append(missing, role)
+		case err != nil:
+			return fail("failed to read role %s: %v", role, err)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fail("missing roles: %s", strings.Join(missing, ", "))
+	}
+	return Result{Outcome: OutcomePass}
+}
+
+// verifyConfigRecorder checks that an AWS Config recorder is recording in the home region
+func (v *Verifier) verifyConfigRecorder(ctx context.Context, cfg aws.Config) Result {
+	if !v.config {
+		return Result{Outcome: OutcomeSkip, Message: "AWS Config not enabled"}
+	}
+
+	out, err := configservice.NewFromConfig(cfg).DescribeConfigurationRecorderStatus(ctx,
+		&configservice.DescribeConfigurationRecorderStatusInput{})
+	if err != nil {
+		return fail("failed to read configuration recorders: %v", err)
+	}
+
+	for _, status := range out.ConfigurationRecordersStatus {
+		if status.Recording {
+			return Result{Outcome: OutcomePass}
+		}
+	}
+	if len(out.ConfigurationRecordersStatus) == 0 {
+		return fail("no configuration recorder in %s", cfg.Region)
+	}
+	return fail("configuration recorder is not recording in %s", cfg.Region)
+}
+
+// verifyGuardDuty checks that GuardDuty is enabled and that member accounts are
+// administered by the delegated administrator
+func (v *Verifier) verifyGuardDuty(ctx context.Context, account Account, cfg aws.Config) Result {
+	if !v.guardDuty {
+		return Result{Outcome: OutcomeSkip, Message: "GuardDuty not enabled"}
+	}
+
+	client := guardduty.NewFromConfig(cfg)
+	detectors, err := client.ListDetectors(ctx, &guardduty.ListDetectorsInput{})
+	if err != nil {
+		return fail("failed to list GuardDuty detectors: %v", err)
+	}
+	if len(detectors.DetectorIds) == 0 {
+		return fail("no GuardDuty detector in %s", cfg.Region)
+	}
+
+	detectorId := detectors.DetectorIds[0]
+	detector, err := client.GetDetector(ctx, &guardduty.GetDetectorInput{DetectorId: aws.String(detectorId)})
+	if err != nil {
+		return fail("failed to read GuardDuty detector: %v", err)
+	}
+	if detector.Status != gdtypes.DetectorStatusEnabled {
+		return fail("GuardDuty detector is %s", detector.Status)
+	}
+
+	// The delegated administrator has no administrator of its own
+	if v.adminId == "" || account.ID == v.adminId {
+		return Result{Outcome: OutcomePass}
+	}
+
+	admin, err := client.GetAdministratorAccount(ctx, &guardduty.GetAdministratorAccountInput{
+		DetectorId: aws.String(detectorId),
+	})
+	if err != nil {
+		return fail("failed to read GuardDuty administrator: %v", err)
+	}
+	if admin.Administrator == nil || aws.ToString(admin.Administrator.AccountId) != v.adminId {
+		return fail("not a GuardDuty member of %s", v.adminId)
+	}
+	if status := aws.ToString(admin.Administrator.RelationshipStatus); status != "Enabled" {
+		return fail("GuardDuty membership is %s", status)
+	}
+	return Result{Outcome: OutcomePass}
+}
+
+// verifyPasswordPolicy checks the IAM password policy against the baseline
+func (v *Verifier) verifyPasswordPolicy(ctx context.Context, account Account, cfg aws.Config) Result {
+	if v.baseline == nil {
+		return Result{Outcome: OutcomeSkip, Message: "no password policy configured"}
+	}
+	if account.Management {
+		return Result{Outcome: OutcomeSkip, Message: "management account"}
+	}
+
+	if finding := v.baseline.baselinePasswordPolicy(ctx, iam.NewFromConfig(cfg), account); finding != nil {
+		return Result{Outcome: OutcomeFail, Message: finding.Message}
+	}
+	return Result{Outcome: OutcomePass}
+}
+
+// verifyRootAlarm checks that the root activity rule of every baseline region of the
+// account is enabled and forwards to the bus of the alarm. The rule of the management
+// account in the alarm region must publish to a topic with a confirmed subscription.
+func (v *Verifier) verifyRootAlarm(ctx context.Context, account Account, cfg aws.Config) Result {
+	if !v.lz.RootActivityAlarmFor(account.ID) {
+		return Result{Outcome: OutcomeSkip, Message: "no root activity alarm configured"}
+	}
+
+	name := v.lz.Baseline.RootActivityAlarm.AlarmName()
+	alarmRegion := v.lz.RootActivityAlarmRegion()
+	bus := fmt.Sprintf("arn:%s:events:%s:%s:event-bus/default", awsclient.Partition(), alarmRegion, v.lz.ManagementAccountId)
+	var problems []string
+	for _, region := range v.lz.BaselineRegions(account.ID) {
+		regional := cfg.Copy()
+		regional.Region = region
+		central := account.ID == v.lz.ManagementAccountId && region == alarmRegion
+		if problem := rootAlarmProblem(ctx, regional, name, bus, central); problem != "" {
+			problems = append(problems, fmt.Sprintf("%s in %s", problem, region))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fail("%s", strings.Join(problems, "; "))
+	}
+	return Result{Outcome: OutcomePass}
+}
+
+// rootAlarmProblem returns what is missing from the root activity alarm of a region,
+// or an empty string. The central rule publishes to the topic of the alarm, the others
+// forward to its bus.
+func rootAlarmProblem(ctx context.Context, cfg aws.Config, name, bus string, central bool) string {
+	events := eventbridge.NewFromConfig(cfg)
+	rule, err := events.DescribeRule(ctx, &eventbridge.DescribeRuleInput{Name: aws.String(name)})
+	var notFound *ebtypes.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+		return fmt.Sprintf("no rule %s", name)
+	case err != nil:
+		return fmt.Sprintf("failed to read rule %s: %v", name, err)
+	}
+	if rule.State != ebtypes.RuleStateEnabled {
+		return fmt.Sprintf("rule %s is %s", name, rule.State)
+	}
+
+	targets, err := events.ListTargetsByRule(ctx, &eventbridge.ListTargetsByRuleInput{Rule: aws.String(name)})
+	if err != nil {
+		return fmt.Sprintf("failed to list targets of rule %s: %v", name, err)
+	}
+	if !central {
+		for _, target := range targets.Targets {
+			if aws.ToString(target.Arn) == bus {
+				return ""
+			}
+		}
+		return fmt.Sprintf("rule %s does not forward to %s", name, bus)
+	}
+
+	var topicArn string
+	for _, target := range targets.Targets {
+		if parsed, err := arn.Parse(aws.ToString(target.Arn)); err == nil && parsed.Service == "sns" {
+			topicArn = aws.ToString(target.Arn)
+			break
+		}
+	}
+	if topicArn == "" {
+		return fmt.Sprintf("rule %s has no topic target", name)
+	}
+
+	attributes, err := sns.NewFromConfig(cfg).GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{
+		TopicArn: aws.String(topicArn),
+	})
+	if err != nil {
+		return fmt.Sprintf("failed to read topic %s: %v", topicArn, err)
+	}
+	if confirmed := attributes.Attributes["SubscriptionsConfirmed"]; confirmed == "" || confirmed == "0" {
+		return fmt.Sprintf("topic %s has no confirmed subscription", topicArn)
+	}
+	return ""
+}
+
+// fail returns a failed result with a formatted message
+func fail(format string, args ...interface{}) Result {
+	return Result{Outcome: OutcomeFail, Message: fmt.Sprintf(format, args...)}
+}
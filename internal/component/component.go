@@ -0,0 +1,62 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package component provides helpers shared by the Pulumi component resources of the landing zone.
+// Version: 1.0.0
+package component
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// Package name of the component type tokens
+	typePackage = "aws-org"
+)
+
+// Type returns the type token of a component resource of a module
+func Type(module, name string) string {
+	return fmt.Sprintf("%s:%s:%s", typePackage, module, name)
+}
+
+// ChildOptions returns the options registering a resource as a child of a component.
+// Resources created before the components existed were registered without a parent, so
+// they are aliased to keep existing stacks from replacing them.
+func ChildOptions(parent pulumi.Resource) []pulumi.ResourceOption {
+	return []pulumi.ResourceOption{
+		pulumi.Parent(parent),
+		pulumi.Aliases([]pulumi.Alias{{NoParent: pulumi.Bool(true)}}),
+	}
+}
+
+// Options returns a copy of the base options followed by the extra options, so callers
+// can add options without sharing the backing array of the base options
+func Options(base []pulumi.ResourceOption, extra ...pulumi.ResourceOption) []pulumi.ResourceOption {
+	opts := make([]pulumi.ResourceOption, 0, len(base)+len(extra))
+	opts = append(opts, base...)
+	return append(opts, extra...)
+}
+
+// Name returns the name of a resource, or nil when the name is empty so Pulumi
+// generates one
+func Name(name string) pulumi.StringPtrInput {
+	if name == "" {
+		return nil
+	}
+	return pulumi.String(name)
+}
+
+// ModuleOf returns the module of a component type token, and false for the types of
+// other packages
+func ModuleOf(typeToken string) (string, bool) {
+	pkg, rest, ok := strings.Cut(typeToken, ":")
+	if !ok || pkg != typePackage {
+		return "", false
+	}
+	module, _, ok := strings.Cut(rest, ":")
+	return module, ok && module != ""
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import "fmt"
+
+const (
+	// DefaultOwnerAttribute is the account attribute the access review groups on
+	DefaultOwnerAttribute = "owner"
+
+	// DefaultInactiveDays is the number of days without CloudTrail activity after
+	// which an account is flagged as inactive
+	DefaultInactiveDays = 90
+)
+
+// AccessReviewConfig defines the periodic access review, listing the accounts of each
+// owner with their enabled controls, last activity and compliance status. The review
+// is emailed through SES when Email is set and posted to the Slack incoming webhook
+// whose URL is held by the environment variable named by SlackWebhookEnv.
+type AccessReviewConfig struct {
+	OwnerAttribute  string                   `json:"ownerAttribute,omitempty"`
+	InactiveDays    int                      `json:"inactiveDays,omitempty"`
+	Email           *AccessReviewEmailConfig `json:"email,omitempty"`
+	SlackWebhookEnv string                   `json:"slackWebhookEnv,omitempty"`
+}
+
+// AccessReviewEmailConfig defines the recipients of the access review, sent from the
+// sender of the mail configuration. With SendToOwners, each owner holding an email
+// address also receives the review of their own accounts.
+type AccessReviewEmailConfig struct {
+	To           []string `json:"to,omitempty"`
+	SendToOwners bool     `json:"sendToOwners,omitempty"`
+}
+
+// Owner returns the account attribute holding the owner of an account
+func (c *AccessReviewConfig) Owner() string {
+	if c.OwnerAttribute == "" {
+		return DefaultOwnerAttribute
+	}
+	return c.OwnerAttribute
+}
+
+// Inactivity returns the number of days without activity flagging an account
+func (c *AccessReviewConfig) Inactivity() int {
+	if c.InactiveDays == 0 {
+		return DefaultInactiveDays
+	}
+	return c.InactiveDays
+}
+
+// validateAccessReview validates the access review configuration
+func (c *OrganizationConfig) validateAccessReview() error {
+	review := c.LandingZoneConfig.AccessReview
+	if review == nil {
+		return nil
+	}
+
+	if _, ok := c.LandingZoneConfig.AccountAttributes[review.Owner()]; !ok {
+		return fmt.Errorf("access review owner attribute %s is not declared in accountAttributes", review.Owner())
+	}
+	if review.InactiveDays < 0 {
+		return fmt.Errorf("access review inactive days cannot be negative")
+	}
+	if review.SlackWebhookEnv != "" && !envNameRE.MatchString(review.SlackWebhookEnv) {
+		return fmt.Errorf("access review has an invalid Slack webhook environment variable %q", review.SlackWebhookEnv)
+	}
+
+	if email := review.Email; email != nil {
+		if c.LandingZoneConfig.Mail == nil {
+			return fmt.Errorf("access review email requires the mail configuration")
+		}
+		if len(email.To) == 0 && !email.SendToOwners {
+			return fmt.Errorf("access review email requires recipients or sendToOwners")
+		}
+		for _, to := range email.To {
+			if !attributeEmailRE.MatchString(to) {
+				return fmt.Errorf("access review recipient %q is not an email address", to)
+			}
+		}
+	}
+	return nil
+}
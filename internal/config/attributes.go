@@ -0,0 +1,205 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Types of the custom account attributes
+const (
+	AttributeTypeString = "string"
+	AttributeTypeEmail  = "email"
+	AttributeTypeEnum   = "enum"
+)
+
+// maxAttributeValueLength keeps an attribute within a tag value, so attributes can be
+// copied to tags
+const maxAttributeValueLength = 256
+
+var (
+	attributeNameRE  = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,63}$`)
+	attributeEmailRE = regexp.MustCompile(EmailRegexPattern)
+)
+
+// AccountAttributeConfig declares a custom attribute of the accounts, such as a cost
+// center, owner email, data classification or environment. Attributes are set per
+// account in the configuration and recorded with the account in the SSM registry.
+// Enum attributes take one of Values. A required attribute without a value on an
+// account takes Default.
+type AccountAttributeConfig struct {
+	Description string   `json:"description,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Values      []string `json:"values,omitempty"`
+	Required    bool     `json:"required,omitempty"`
+	Default     string   `json:"default,omitempty"`
+}
+
+// AttributeType returns the type of the attribute, string by default
+func (a *AccountAttributeConfig) AttributeType() string {
+	if a.Type == "" {
+		return AttributeTypeString
+	}
+	return a.Type
+}
+
+// Check validates a value of the attribute
+func (a *AccountAttributeConfig) Check(value string) error {
+	if len(value) > maxAttributeValueLength {
+		return fmt.Errorf("value is longer than %d characters", maxAttributeValueLength)
+	}
+	switch a.AttributeType() {
+	case AttributeTypeEmail:
+		if !attributeEmailRE.MatchString(value) {
+			return fmt.Errorf("%q is not an email address", value)
+		}
+	case AttributeTypeEnum:
+		for _, allowed := range a.Values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", value, strings.Join(a.Values, ", "))
+	}
+	return nil
+}
+
+// AttributeNames returns the names of the declared account attributes, sorted
+func (c *LandingZoneConfig) AttributeNames() []string {
+	names := make([]string, 0, len(c.AccountAttributes))
+	for name := range c.AccountAttributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveAttributes returns the attributes of an account with the defaults of the
+// attributes it does not set
+func (c *LandingZoneConfig) ResolveAttributes(attributes map[string]string) map[string]string {
+	if len(c.AccountAttributes) == 0 {
+		return nil
+	}
+
+	resolved := make(map[string]string, len(c.AccountAttributes))
+	for name, attribute := range c.AccountAttributes {
+		if value, ok := attributes[name]; ok {
+			resolved[name] = value
+		} else if attribute != nil && attribute.Default != "" {
+			resolved[name] = attribute.Default
+		}
+	}
+	return resolved
+}
+
+// ConfiguredAttributes returns the resolved attributes of the accounts of the
+// configured OUs, keyed by lower-cased email address
+func (c *LandingZoneConfig) ConfiguredAttributes() map[string]map[string]string {
+	if len(c.AccountAttributes) == 0 {
+		return nil
+	}
+
+	configured := make(map[string]map[string]string)
+	for _, ou := range c.OrganizationUnits {
+		if ou == nil {
+			continue
+		}
+		for _, account := range ou.Accounts {
+			configured[strings.ToLower(account.Email)] = c.ResolveAttributes(account.Attributes)
+		}
+	}
+	return configured
+}
+
+// validateAccountAttributes validates the declared attributes and the attributes of
+// every configured account
+func (c *OrganizationConfig) validateAccountAttributes() error {
+	lz := c.LandingZoneConfig
+	for _, name := range lz.AttributeNames() {
+		attribute := lz.AccountAttributes[name]
+		if !attributeNameRE.MatchString(name) {
+			return fmt.Errorf("invalid account attribute name %q", name)
+		}
+		if attribute == nil {
+			return fmt.Errorf("account attribute %s has no definition", name)
+		}
+
+		switch attribute.AttributeType() {
+		case AttributeTypeString, AttributeTypeEmail:
+			if len(attribute.Values) > 0 {
+				return fmt.Errorf("account attribute %s lists values but is not an enum", name)
+			}
+		case AttributeTypeEnum:
+			if len(attribute.Values) == 0 {
+				return fmt.Errorf("enum account attribute %s lists no values", name)
+			}
+		default:
+			return fmt.Errorf("account attribute %s has unknown type %q", name, attribute.Type)
+		}
+
+		if attribute.Default != "" {
+			if err := attribute.Check(attribute.Default); err != nil {
+				return fmt.Errorf("default of account attribute %s: %w", name, err)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(lz.OrganizationUnits))
+	for key := range lz.OrganizationUnits {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ou := lz.OrganizationUnits[key]
+		if ou == nil {
+			continue
+		}
+		for _, account := range ou.Accounts {
+			if err := lz.CheckAttributes(account.Attributes); err != nil {
+				return fmt.Errorf("account %s: %w", account.Name, err)
+			}
+			if missing := lz.MissingAttributes(lz.ResolveAttributes(account.Attributes)); len(missing) > 0 {
+				return fmt.Errorf("account %s: required attribute %s is not set", account.Name, missing[0])
+			}
+		}
+	}
+	return nil
+}
+
+// CheckAttributes checks that the attributes of an account are declared and their
+// values valid. Required attributes left unset are reported by MissingAttributes.
+func (c *LandingZoneConfig) CheckAttributes(attributes map[string]string) error {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		attribute, ok := c.AccountAttributes[name]
+		if !ok || attribute == nil {
+			return fmt.Errorf("attribute %s is not declared in accountAttributes", name)
+		}
+		if err := attribute.Check(attributes[name]); err != nil {
+			return fmt.Errorf("attribute %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// MissingAttributes returns the required attributes an account has no value for
+func (c *LandingZoneConfig) MissingAttributes(attributes map[string]string) []string {
+	var missing []string
+	for _, name := range c.AttributeNames() {
+		attribute := c.AccountAttributes[name]
+		if attribute != nil && attribute.Required && attributes[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
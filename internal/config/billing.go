@@ -0,0 +1,125 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	// Limits of the Cost Categories API
+	MaxCostCategoryNameLength = 50
+	MaxCostCategoryRules      = 500
+)
+
+var (
+	costCategoryNameRE = regexp.MustCompile(`^[\w\s\-.:+=@/]+$`)
+	reportNameRE       = regexp.MustCompile(`^[0-9A-Za-z!\-_.*'()]{1,256}$`)
+	glueDatabaseNameRE = regexp.MustCompile(`^[a-z0-9_]{1,255}$`)
+)
+
+// BillingConfig defines the cost categories of the organization and the delivery of
+// the Cost and Usage Report to the log archive account
+type BillingConfig struct {
+	CostCategories     []CostCategoryConfig      `json:"costCategories,omitempty"`
+	CostAndUsageReport *CostAndUsageReportConfig `json:"costAndUsageReport,omitempty"`
+}
+
+// CostCategoryConfig defines a cost category mapping accounts to business units.
+// Costs of accounts matching no rule are assigned the default value.
+type CostCategoryConfig struct {
+	Name         string                   `json:"name"`
+	DefaultValue string                   `json:"defaultValue,omitempty"`
+	Rules        []CostCategoryRuleConfig `json:"rules"`
+}
+
+// CostCategoryRuleConfig assigns a business unit to accounts listed by ID and to the
+// accounts of the listed OUs, which are keys of organizationUnits
+type CostCategoryRuleConfig struct {
+	Value      string   `json:"value"`
+	AccountIds []string `json:"accountIds,omitempty"`
+	OUs        []string `json:"ous,omitempty"`
+}
+
+// CostAndUsageReportConfig defines the Cost and Usage Report delivered to a bucket of
+// the log archive account and the Glue catalog it is queried through with Athena
+type CostAndUsageReportConfig struct {
+	ReportName      string `json:"reportName"`
+	BucketName      string `json:"bucketName"`
+	Prefix          string `json:"prefix,omitempty"`
+	DatabaseName    string `json:"databaseName,omitempty"`
+	CrawlerSchedule string `json:"crawlerSchedule,omitempty"`
+	AthenaWorkgroup string `json:"athenaWorkgroup,omitempty"`
+}
+
+// validateBillingConfig validates the cost categories and the Cost and Usage Report
+func (c *OrganizationConfig) validateBillingConfig() error {
+	lz := c.LandingZoneConfig
+	b := lz.Billing
+	if b == nil {
+		return nil
+	}
+
+	names := make(map[string]bool)
+	for _, category := range b.CostCategories {
+		if category.Name == "" || len(category.Name) > MaxCostCategoryNameLength || !costCategoryNameRE.MatchString(category.Name) {
+			return fmt.Errorf("invalid cost category name %q", category.Name)
+		}
+		if names[category.Name] {
+			return fmt.Errorf("cost category %s is configured more than once", category.Name)
+		}
+		names[category.Name] = true
+
+		if len(category.Rules) == 0 {
+			return fmt.Errorf("cost category %s requires at least one rule", category.Name)
+		}
+		if len(category.Rules) > MaxCostCategoryRules {
+			return fmt.Errorf("cost category %s has more than %d rules", category.Name, MaxCostCategoryRules)
+		}
+
+		for _, rule := range category.Rules {
+			if rule.Value == "" {
+				return fmt.Errorf("rules of cost category %s require a value", category.Name)
+			}
+			if len(rule.AccountIds) == 0 && len(rule.OUs) == 0 {
+				return fmt.Errorf("rule %s of cost category %s must list accounts or OUs", rule.Value, category.Name)
+			}
+			for _, id := range rule.AccountIds {
+				if !isValidAccountId(id) {
+					return fmt.Errorf("invalid account ID %s in cost category %s", id, category.Name)
+				}
+			}
+			for _, ou := range rule.OUs {
+				if _, ok := lz.OrganizationUnits[ou]; !ok {
+					return fmt.Errorf("OU %q of cost category %s is not configured", ou, category.Name)
+				}
+			}
+		}
+	}
+
+	if report := b.CostAndUsageReport; report != nil {
+		if !reportNameRE.MatchString(report.ReportName) {
+			return fmt.Errorf("invalid Cost and Usage Report name %q", report.ReportName)
+		}
+		if report.BucketName == "" {
+			return fmt.Errorf("a bucket is required to deliver the Cost and Usage Report")
+		}
+		if report.DatabaseName != "" && !glueDatabaseNameRE.MatchString(report.DatabaseName) {
+			return fmt.Errorf("invalid Glue database name %q, must be lower case letters, digits and underscores", report.DatabaseName)
+		}
+		if report.BucketName == lz.LogBucketName {
+			return fmt.Errorf("the Cost and Usage Report requires a bucket other than the log bucket, whose policy is managed by Control Tower")
+		}
+		if !isValidAccountId(lz.LogArchiveAccountId) {
+			return fmt.Errorf("a valid log archive account ID is required to deliver the Cost and Usage Report")
+		}
+		if !isValidAccountId(lz.ManagementAccountId) {
+			return fmt.Errorf("a valid management account ID is required to deliver the Cost and Usage Report")
+		}
+	}
+
+	return nil
+}
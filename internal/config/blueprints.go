@@ -0,0 +1,104 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	// DefaultBlueprintPortfolio is the Service Catalog portfolio holding the blueprints
+	DefaultBlueprintPortfolio = "Account Factory Customization"
+
+	// BlueprintAccessRoleName is the role of the hub account Control Tower reads the
+	// blueprints through
+	BlueprintAccessRoleName = "AWSControlTowerBlueprintAccess"
+)
+
+var (
+	// Blueprint names are part of the names of their resources
+	blueprintNameRE = regexp.MustCompile(`^[a-zA-Z][-a-zA-Z0-9]{0,99}$`)
+
+	// Account Factory Customization reads the templates of blueprints from S3 only
+	blueprintTemplateURLRE = regexp.MustCompile(`^https://[a-z0-9][-a-z0-9.]*\.s3[-.a-z0-9]*\.amazonaws\.com(\.cn)?/.+`)
+)
+
+// AccountFactoryCustomizationConfig defines the Service Catalog products registered as
+// Account Factory Customization blueprints in the hub account
+type AccountFactoryCustomizationConfig struct {
+	HubAccountId  string            `json:"hubAccountId"`
+	PortfolioName string            `json:"portfolioName,omitempty"`
+	Blueprints    []BlueprintConfig `json:"blueprints"`
+}
+
+// BlueprintConfig defines a blueprint product, with the template of its S3 bucket, and
+// the OUs whose accounts are vended or updated with it through Account Factory. OUs are
+// Root, keys or names of OUs.
+type BlueprintConfig struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Owner       string            `json:"owner,omitempty"`
+	TemplateURL string            `json:"templateUrl"`
+	Version     string            `json:"version"`
+	Parameters  map[string]string `json:"parameters,omitempty"`
+	OUs         []string          `json:"ous,omitempty"`
+}
+
+// Portfolio returns the name of the portfolio holding the blueprints
+func (c *AccountFactoryCustomizationConfig) Portfolio() string {
+	if c.PortfolioName != "" {
+		return c.PortfolioName
+	}
+	return DefaultBlueprintPortfolio
+}
+
+// validateBlueprintConfig validates the hub account and the blueprints
+func (c *OrganizationConfig) validateBlueprintConfig() error {
+	lz := c.LandingZoneConfig
+	afc := lz.AccountFactoryCustomization
+	if afc == nil {
+		return nil
+	}
+
+	if !isValidAccountId(afc.HubAccountId) {
+		return fmt.Errorf("a valid hub account ID is required for Account Factory Customization")
+	}
+	if !isValidAccountId(lz.ManagementAccountId) {
+		return fmt.Errorf("a valid management account ID is required for Account Factory Customization")
+	}
+	if len(afc.Blueprints) == 0 {
+		return fmt.Errorf("Account Factory Customization requires at least one blueprint")
+	}
+
+	names := make(map[string]bool)
+	for _, blueprint := range afc.Blueprints {
+		if !blueprintNameRE.MatchString(blueprint.Name) {
+			return fmt.Errorf("invalid blueprint name %q, must start with a letter and hold letters, digits and hyphens",
+				blueprint.Name)
+		}
+		if names[blueprint.Name] {
+			return fmt.Errorf("blueprint %s is configured more than once", blueprint.Name)
+		}
+		names[blueprint.Name] = true
+
+		if !blueprintTemplateURLRE.MatchString(blueprint.TemplateURL) {
+			return fmt.Errorf("blueprint %s requires the https://<bucket>.s3.<region>.amazonaws.com URL of its template", blueprint.Name)
+		}
+		if blueprint.Version == "" {
+			return fmt.Errorf("blueprint %s requires a version", blueprint.Name)
+		}
+		for _, ou := range blueprint.OUs {
+			if ou == "" {
+				return fmt.Errorf("blueprint %s lists an empty OU", blueprint.Name)
+			}
+			if isValidAccountId(ou) {
+				return fmt.Errorf("blueprint %s lists account %s, blueprints are deployed to OUs", blueprint.Name, ou)
+			}
+		}
+	}
+
+	return nil
+}
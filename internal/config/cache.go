@@ -0,0 +1,42 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import "fmt"
+
+const (
+	// Time the organization listings are served from the cache
+	DefaultCacheTTLMinutes = 15
+	MaxCacheTTLMinutes     = 1440
+)
+
+// CacheConfig defines the cache of the accounts, OUs and policies listed from the
+// Organizations API. Listings are kept in the state for the TTL, or refreshed on
+// every read when the cache is disabled.
+type CacheConfig struct {
+	Disabled   bool `json:"disabled,omitempty"`
+	TTLMinutes int  `json:"ttlMinutes,omitempty"`
+}
+
+// TTL returns the configured TTL in minutes, or the default
+func (c *CacheConfig) TTL() int {
+	if c == nil || c.TTLMinutes == 0 {
+		return DefaultCacheTTLMinutes
+	}
+	return c.TTLMinutes
+}
+
+// validateCacheConfig validates the organization cache
+func (c *OrganizationConfig) validateCacheConfig() error {
+	cache := c.LandingZoneConfig.Cache
+	if cache == nil {
+		return nil
+	}
+
+	if cache.TTLMinutes < 0 || cache.TTLMinutes > MaxCacheTTLMinutes {
+		return fmt.Errorf("cache TTL must be between 0 and %d minutes", MaxCacheTTLMinutes)
+	}
+	return nil
+}
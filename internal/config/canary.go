@@ -0,0 +1,142 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Expected outcomes of a canary probe
+const (
+	ProbeAllowed = "allowed"
+	ProbeDenied  = "denied"
+)
+
+// Defaults of the canary rollout
+const (
+	DefaultCanaryPropagationSeconds = 60
+	MaxCanaryPropagationSeconds     = 900
+)
+
+// CanaryProbeActions are the API calls a canary probe can make. Mutating calls target
+// resources that do not exist, so they change nothing when the guardrails allow them.
+var CanaryProbeActions = []string{
+	"cloudtrail:DescribeTrails",
+	"cloudtrail:StopLogging",
+	"cloudtrail:DeleteTrail",
+	"config:DescribeConfigurationRecorders",
+	"config:StopConfigurationRecorder",
+	"config:DeleteConfigurationRecorder",
+	"guardduty:ListDetectors",
+	"guardduty:DeleteDetector",
+	"iam:ListRoles",
+	"iam:DeleteRole",
+	"kms:ListKeys",
+	"kms:ScheduleKeyDeletion",
+	"lambda:ListFunctions",
+	"logs:DeleteLogGroup",
+	"s3:ListAllMyBuckets",
+	"ssm:GetParameter",
+}
+
+// CanaryConfig defines the canary of the guardrails: new and changed organization
+// policies are applied to Target first, the probes are run in the canary account, and
+// the policies are rolled out to their targets only when every probe has the expected
+// outcome
+type CanaryConfig struct {
+	// OU name or account ID receiving the new guardrails first
+	Target string `json:"target"`
+
+	// Account the probes run in, the target when it is an account
+	AccountId string `json:"accountId,omitempty"`
+
+	// Role assumed in the canary account, the member role by default. Its permissions
+	// must allow the probed actions, so only the guardrails can deny them.
+	RoleName string `json:"roleName,omitempty"`
+
+	// Region the probes call, the home region by default
+	Region string `json:"region,omitempty"`
+
+	// Time given to the policies to take effect before the probes run
+	PropagationSeconds int `json:"propagationSeconds,omitempty"`
+
+	Probes []CanaryProbeConfig `json:"probes"`
+}
+
+// CanaryProbeConfig defines an API call of the canary probe and its expected outcome
+type CanaryProbeConfig struct {
+	Action string `json:"action"`
+	Expect string `json:"expect"`
+
+	// Region of the call, for guardrails restricting regions
+	Region string `json:"region,omitempty"`
+}
+
+// ProbeAccount returns the account the probes run in
+func (c *CanaryConfig) ProbeAccount() string {
+	if c.AccountId != "" {
+		return c.AccountId
+	}
+	if isValidAccountId(c.Target) {
+		return c.Target
+	}
+	return ""
+}
+
+// Propagation returns the time given to the policies to take effect
+func (c *CanaryConfig) Propagation() time.Duration {
+	if c.PropagationSeconds == 0 {
+		return DefaultCanaryPropagationSeconds * time.Second
+	}
+	return time.Duration(c.PropagationSeconds) * time.Second
+}
+
+// validateCanaryConfig validates the target, account and probes of the canary
+func (c *OrganizationConfig) validateCanaryConfig() error {
+	canary := c.LandingZoneConfig.Canary
+	if canary == nil {
+		return nil
+	}
+
+	if canary.Target == "" {
+		return fmt.Errorf("canary requires a target OU or account")
+	}
+	if canary.Target == PolicyTargetRoot {
+		return fmt.Errorf("the root cannot be the canary target")
+	}
+	if canary.AccountId != "" && !isValidAccountId(canary.AccountId) {
+		return fmt.Errorf("invalid canary account ID %q", canary.AccountId)
+	}
+	if canary.ProbeAccount() == "" {
+		return fmt.Errorf("canary target %s is an OU, the account of the probes is required", canary.Target)
+	}
+	if canary.PropagationSeconds < 0 || canary.PropagationSeconds > MaxCanaryPropagationSeconds {
+		return fmt.Errorf("canary propagation must be between 0 and %d seconds", MaxCanaryPropagationSeconds)
+	}
+
+	if len(canary.Probes) == 0 {
+		return fmt.Errorf("canary requires at least one probe")
+	}
+	for _, probe := range canary.Probes {
+		if !knownProbeAction(probe.Action) {
+			return fmt.Errorf("unknown canary probe action %q, available actions: %v", probe.Action, CanaryProbeActions)
+		}
+		if probe.Expect != ProbeAllowed && probe.Expect != ProbeDenied {
+			return fmt.Errorf("canary probe %s must expect %s or %s", probe.Action, ProbeAllowed, ProbeDenied)
+		}
+	}
+	return nil
+}
+
+// knownProbeAction reports whether an action is one of CanaryProbeActions
+func knownProbeAction(action string) bool {
+	for _, known := range CanaryProbeActions {
+		if known == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+const (
+	// Limits of the Service Catalog API
+	MaxPortfolioNameLength = 100
+	MaxProductNameLength   = 8191
+)
+
+// Role names, with wildcards, given access to shared portfolios
+var rolePatternRE = regexp.MustCompile(`^[\w+=,.@\-/*?]{1,512}$`)
+
+// ServiceCatalogConfig defines the Service Catalog portfolios of the platform products
+// distributed to the accounts of the organization
+type ServiceCatalogConfig struct {
+	Portfolios []PortfolioConfig `json:"portfolios"`
+}
+
+// PortfolioConfig defines a portfolio created in the management account and shared
+// with Root, OUs by key or name, or account IDs through organizational sharing. Roles
+// matching the principals are given access to the portfolio in the recipient accounts.
+type PortfolioConfig struct {
+	Name         string          `json:"name"`
+	Description  string          `json:"description,omitempty"`
+	ProviderName string          `json:"providerName,omitempty"`
+	Products     []ProductConfig `json:"products"`
+	ShareWith    []string        `json:"shareWith,omitempty"`
+	Principals   []string        `json:"principals,omitempty"`
+}
+
+// ProductConfig defines a CloudFormation product and its current version
+type ProductConfig struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	Owner        string `json:"owner,omitempty"`
+	TemplateURL  string `json:"templateUrl"`
+	Version      string `json:"version"`
+	SupportEmail string `json:"supportEmail,omitempty"`
+}
+
+// validateServiceCatalogConfig validates the portfolios, their products and shares
+func (c *OrganizationConfig) validateServiceCatalogConfig() error {
+	sc := c.LandingZoneConfig.ServiceCatalog
+	if sc == nil {
+		return nil
+	}
+
+	portfolios := make(map[string]bool)
+	for _, portfolio := range sc.Portfolios {
+		if portfolio.Name == "" || len(portfolio.Name) > MaxPortfolioNameLength {
+			return fmt.Errorf("invalid portfolio name %q", portfolio.Name)
+		}
+		if portfolios[portfolio.Name] {
+			return fmt.Errorf("portfolio %s is configured more than once", portfolio.Name)
+		}
+		portfolios[portfolio.Name] = true
+
+		if len(portfolio.Products) == 0 {
+			return fmt.Errorf("portfolio %s requires at least one product", portfolio.Name)
+		}
+
+		products := make(map[string]bool)
+		for _, product := range portfolio.Products {
+			if product.Name == "" || len(product.Name) > MaxProductNameLength {
+				return fmt.Errorf("invalid product name %q in portfolio %s", product.Name, portfolio.Name)
+			}
+			if products[product.Name] {
+				return fmt.Errorf("product %s is configured more than once in portfolio %s", product.Name, portfolio.Name)
+			}
+			products[product.Name] = true
+
+			if u, err := url.Parse(product.TemplateURL); err != nil || u.Scheme != "https" || u.Host == "" {
+				return fmt.Errorf("product %s requires the HTTPS URL of its template", product.Name)
+			}
+			if product.Version == "" {
+				return fmt.Errorf("product %s requires a version", product.Name)
+			}
+		}
+
+		for _, target := range portfolio.ShareWith {
+			if target == "" {
+				return fmt.Errorf("portfolio %s is shared with an empty target", portfolio.Name)
+			}
+		}
+		for _, principal := range portfolio.Principals {
+			if !rolePatternRE.MatchString(principal) {
+				return fmt.Errorf("invalid principal %q of portfolio %s, must be a role name or pattern",
+					principal, portfolio.Name)
+			}
+		}
+		if len(portfolio.Principals) > 0 && len(portfolio.ShareWith) == 0 {
+			return fmt.Errorf("principals of portfolio %s require the portfolio to be shared", portfolio.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Defaults of the centralized logging pipeline
+const (
+	DefaultCentralLoggingName              = "landing-zone-central-logging"
+	DefaultCentralLoggingPrefix            = "central-logging/"
+	DefaultCentralLoggingBufferingInterval = 300
+	DefaultCentralLoggingBufferingSize     = 5
+)
+
+// destinationNameRE matches the names valid for both a delivery stream and a destination
+var destinationNameRE = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// CentralLoggingConfig enables the centralized logging pipeline. A Kinesis Data
+// Firehose delivery stream in the log archive account delivers to Bucket, the log
+// bucket by default, under Prefix. A CloudWatch Logs destination in front of it in
+// each of Regions, the governed regions by default, lets the accounts of the
+// organization subscribe to it, and the subscription filters of LogGroups in every
+// other active account send it the events matching FilterPattern, every event by
+// default. The log groups must exist in the subscribed accounts. ExcludedAccounts are
+// not subscribed. The delivered objects are encrypted with KmsKeyArn when set.
+type CentralLoggingConfig struct {
+	Name              string   `json:"name,omitempty"`
+	Bucket            string   `json:"bucket,omitempty"`
+	Prefix            string   `json:"prefix,omitempty"`
+	Regions           []string `json:"regions,omitempty"`
+	LogGroups         []string `json:"logGroups"`
+	FilterPattern     string   `json:"filterPattern,omitempty"`
+	ExcludedAccounts  []string `json:"excludedAccounts,omitempty"`
+	BufferingInterval int      `json:"bufferingInterval,omitempty"`
+	BufferingSize     int      `json:"bufferingSize,omitempty"`
+	KmsKeyArn         string   `json:"kmsKeyArn,omitempty"`
+}
+
+// DestinationName returns the name of the delivery stream and destination of each region
+func (l *CentralLoggingConfig) DestinationName() string {
+	if l.Name == "" {
+		return DefaultCentralLoggingName
+	}
+	return l.Name
+}
+
+// S3Prefix returns the prefix of the objects delivered to the bucket
+func (l *CentralLoggingConfig) S3Prefix() string {
+	if l.Prefix == "" {
+		return DefaultCentralLoggingPrefix
+	}
+	return l.Prefix
+}
+
+// Interval returns the seconds the delivery stream buffers events for
+func (l *CentralLoggingConfig) Interval() int {
+	if l.BufferingInterval == 0 {
+		return DefaultCentralLoggingBufferingInterval
+	}
+	return l.BufferingInterval
+}
+
+// Size returns the megabytes the delivery stream buffers before delivering
+func (l *CentralLoggingConfig) Size() int {
+	if l.BufferingSize == 0 {
+		return DefaultCentralLoggingBufferingSize
+	}
+	return l.BufferingSize
+}
+
+// Excluded reports whether an account is left out of the centralized logging
+func (l *CentralLoggingConfig) Excluded(accountId string) bool {
+	for _, excluded := range l.ExcludedAccounts {
+		if excluded == accountId {
+			return true
+		}
+	}
+	return false
+}
+
+// CentralLoggingBucket returns the bucket the centralized logs are delivered to
+func (c *LandingZoneConfig) CentralLoggingBucket() string {
+	if l := c.CentralLogging; l != nil && l.Bucket != "" {
+		return l.Bucket
+	}
+	return c.LogBucketName
+}
+
+// CentralLoggingRegions returns the regions the log groups are subscribed in
+func (c *LandingZoneConfig) CentralLoggingRegions() []string {
+	if l := c.CentralLogging; l != nil && len(l.Regions) > 0 {
+		return l.Regions
+	}
+	return c.GovernedRegions
+}
+
+// validateCentralLoggingConfig validates the centralized logging pipeline
+func (c *OrganizationConfig) validateCentralLoggingConfig() error {
+	lz := c.LandingZoneConfig
+	l := lz.CentralLogging
+	if l == nil {
+		return nil
+	}
+
+	if !isValidAccountId(lz.LogArchiveAccountId) {
+		return fmt.Errorf("central logging requires a valid log archive account ID")
+	}
+	if !destinationNameRE.MatchString(l.DestinationName()) {
+		return fmt.Errorf("invalid central logging name %q", l.Name)
+	}
+	if lz.CentralLoggingBucket() == "" {
+		return fmt.Errorf("central logging requires a bucket or the log bucket name")
+	}
+
+	governed := make(map[string]bool)
+	for _, region := range lz.GovernedRegions {
+		governed[region] = true
+	}
+	for _, region := range l.Regions {
+		if !governed[region] {
+			return fmt.Errorf("central logging region %s is not a governed region", region)
+		}
+	}
+	if len(lz.CentralLoggingRegions()) == 0 {
+		return fmt.Errorf("central logging requires regions or governed regions")
+	}
+
+	if len(l.LogGroups) == 0 {
+		return fmt.Errorf("central logging requires at least one log group")
+	}
+	seen := make(map[string]bool)
+	for _, logGroup := range l.LogGroups {
+		if err := checkResourceName(ResourceLogGroup, logGroup); err != nil {
+			return fmt.Errorf("invalid central logging log group: %w", err)
+		}
+		if seen[logGroup] {
+			return fmt.Errorf("duplicate central logging log group %s", logGroup)
+		}
+		seen[logGroup] = true
+	}
+	if len(l.FilterPattern) > 1024 {
+		return fmt.Errorf("central logging filter pattern cannot exceed 1024 characters")
+	}
+
+	if l.BufferingInterval != 0 && (l.BufferingInterval < 60 || l.BufferingInterval > 900) {
+		return fmt.Errorf("central logging buffering interval must be between 60 and 900 seconds")
+	}
+	if l.BufferingSize != 0 && (l.BufferingSize < 1 || l.BufferingSize > 128) {
+		return fmt.Errorf("central logging buffering size must be between 1 and 128 MB")
+	}
+	for _, accountId := range l.ExcludedAccounts {
+		if !isValidAccountId(accountId) {
+			return fmt.Errorf("invalid central logging excluded account ID %q", accountId)
+		}
+	}
+	return nil
+}
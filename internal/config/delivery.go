@@ -0,0 +1,60 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Delivery mechanisms of the resource baselines
+const (
+	// DeliveryPulumi applies a baseline through providers assuming the member role
+	DeliveryPulumi = "pulumi"
+
+	// DeliveryStackSet applies a baseline through a self-managed CloudFormation
+	// StackSet executed with the member role
+	DeliveryStackSet = "stackset"
+)
+
+// Resource baselines whose delivery mechanism can be chosen
+const (
+	BaselineEBSEncryption       = "ebsEncryptionByDefault"
+	BaselineS3BlockPublicAccess = "s3BlockPublicAccess"
+	BaselineContacts            = "contacts"
+)
+
+// DeliverableBaselines lists the resource baselines that can be delivered by StackSet
+var DeliverableBaselines = []string{BaselineEBSEncryption, BaselineS3BlockPublicAccess, BaselineContacts}
+
+// DeliveryOf returns the delivery mechanism of a resource baseline, Pulumi by default
+func (b *BaselineConfig) DeliveryOf(baseline string) string {
+	if delivery, ok := b.Delivery[baseline]; ok && delivery != "" {
+		return delivery
+	}
+	return DeliveryPulumi
+}
+
+// validateBaselineDelivery validates the delivery mechanism of each resource baseline
+func (c *OrganizationConfig) validateBaselineDelivery() error {
+	deliverable := make(map[string]bool, len(DeliverableBaselines))
+	for _, baseline := range DeliverableBaselines {
+		deliverable[baseline] = true
+	}
+
+	for baseline, delivery := range c.LandingZoneConfig.Baseline.Delivery {
+		if !deliverable[baseline] {
+			return fmt.Errorf("baseline %q has no choice of delivery, must be one of: %s",
+				baseline, strings.Join(DeliverableBaselines, ", "))
+		}
+		switch delivery {
+		case DeliveryPulumi, DeliveryStackSet:
+		default:
+			return fmt.Errorf("unsupported delivery %q of baseline %s, must be %s or %s",
+				delivery, baseline, DeliveryPulumi, DeliveryStackSet)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ChangeKind describes how an item differs between two configurations
+type ChangeKind string
+
+const (
+	// Change kinds
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeUpdated ChangeKind = "updated"
+	ChangeMoved   ChangeKind = "moved"
+
+	// Change categories
+	CategoryOU      = "ou"
+	CategoryAccount = "account"
+	CategoryControl = "control"
+	CategoryRegion  = "region"
+	CategoryHook    = "hook"
+	CategoryTag     = "tag"
+	CategorySetting = "setting"
+)
+
+// Change is a single semantic difference between two configurations
+type Change struct {
+	Kind     ChangeKind `json:"kind"`
+	Category string     `json:"category"`
+	Path     string     `json:"path"`
+	Old      string     `json:"from,omitempty"`
+	New      string     `json:"to,omitempty"`
+}
+
+// String renders the change as a single line, prefixed with +, - or ~
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s %s", c.Category, c.Path)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s %s", c.Category, c.Path)
+	case ChangeMoved:
+		return fmt.Sprintf("~ %s %s moved: %s -> %s", c.Category, c.Path, c.Old, c.New)
+	default:
+		return fmt.Sprintf("~ %s %s: %s -> %s", c.Category, c.Path, c.Old, c.New)
+	}
+}
+
+// toggles lists the security controls reported as toggled rather than as settings
+var toggles = map[string]func(*LandingZoneConfig) bool{
+	"enableSecurityHub": func(c *LandingZoneConfig) bool { return c.EnableSecurityHub },
+	"enableGuardDuty":   func(c *LandingZoneConfig) bool { return c.EnableGuardDuty },
+	"enableConfig":      func(c *LandingZoneConfig) bool { return c.EnableConfig },
+	"enableCloudTrail":  func(c *LandingZoneConfig) bool { return c.EnableCloudTrail },
+	"enableDetective":   func(c *LandingZoneConfig) bool { return c.EnableDetective },
+	"enableInspector":   func(c *LandingZoneConfig) bool { return c.EnableInspector },
+	"enableMacie":       func(c *LandingZoneConfig) bool { return c.EnableMacie },
+	"requireMFA":        func(c *LandingZoneConfig) bool { return c.RequireMFA },
+	"enableSSLRequests": func(c *LandingZoneConfig) bool { return c.EnableSSLRequests },
+}
+
+// structuredKeys are compared semantically and skipped by the settings comparison
+var structuredKeys = []string{"organizationUnits", "tags", "enabledGuardrails", "governedRegions"}
+
+// Diff returns the semantic differences between two configurations: OUs added or
+// removed, accounts added, removed or moved between OUs, controls toggled, tag changes
+// and any other setting that changed. Changes are sorted by category and path.
+func Diff(from, to *OrganizationConfig) ([]Change, error) {
+	if from == nil || from.LandingZoneConfig == nil || to == nil || to.LandingZoneConfig == nil {
+		return nil, fmt.Errorf("both configurations need a landing zone configuration")
+	}
+	o, n := from.LandingZoneConfig, to.LandingZoneConfig
+
+	var changes []Change
+	changes = append(changes, diffOUs(o.OrganizationUnits, n.OrganizationUnits)...)
+	changes = append(changes, diffAccounts(o.OrganizationUnits, n.OrganizationUnits)...)
+	changes = append(changes, diffSet(CategoryControl, "", o.EnabledGuardrails, n.EnabledGuardrails)...)
+	changes = append(changes, diffSet(CategoryRegion, "", o.GovernedRegions, n.GovernedRegions)...)
+	changes = append(changes, diffTags("", o.Tags, n.Tags)...)
+
+	for name, value := range toggles {
+		if value(o) != value(n) {
+			changes = append(changes, Change{
+				Kind:     ChangeUpdated,
+				Category: CategoryControl,
+				Path:     name,
+				Old:      fmt.Sprint(value(o)),
+				New:      fmt.Sprint(value(n)),
+			})
+		}
+	}
+
+	settings, err := diffSettings(o, n)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, settings...)
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Category != changes[j].Category {
+			return changes[i].Category < changes[j].Category
+		}
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// diffOUs reports OUs added or removed, and the tag and hook changes of kept OUs
+func diffOUs(from, to map[string]*OUConfig) []Change {
+	var changes []Change
+	for name := range from {
+		if _, ok := to[name]; !ok {
+			changes = append(changes, Change{Kind: ChangeRemoved, Category: CategoryOU, Path: name})
+		}
+	}
+
+	for name, ou := range to {
+		previous, ok := from[name]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, Category: CategoryOU, Path: name})
+			continue
+		}
+		if previous == nil || ou == nil {
+			continue
+		}
+
+		changes = append(changes, diffTags(name, previous.Tags, ou.Tags)...)
+
+		oldHooks, newHooks := make(map[string]HookConfig), make(map[string]HookConfig)
+		for _, hook := range previous.Hooks {
+			oldHooks[hook.Name] = hook
+		}
+		for _, hook := range ou.Hooks {
+			newHooks[hook.Name] = hook
+		}
+		for hookName, hook := range newHooks {
+			path := name + "/" + hookName
+			previousHook, ok := oldHooks[hookName]
+			switch {
+			case !ok:
+				changes = append(changes, Change{Kind: ChangeAdded, Category: CategoryHook, Path: path})
+			case !reflect.DeepEqual(previousHook, hook):
+				changes = append(changes, Change{Kind: ChangeUpdated, Category: CategoryHook, Path: path,
+					Old: compact(previousHook), New: compact(hook)})
+			}
+		}
+		for hookName := range oldHooks {
+			if _, ok := newHooks[hookName]; !ok {
+				changes = append(changes, Change{Kind: ChangeRemoved, Category: CategoryHook, Path: name + "/" + hookName})
+			}
+		}
+	}
+	return changes
+}
+
+// diffAccounts reports accounts added, removed, moved to another OU or whose email or
+// tags changed. Accounts are identified by name.
+func diffAccounts(from, to map[string]*OUConfig) []Change {
+	type placed struct {
+		ou      string
+		account AccountConfig
+	}
+	index := func(ous map[string]*OUConfig) map[string]placed {
+		accounts := make(map[string]placed)
+		for ouName, ou := range ous {
+			if ou == nil {
+				continue
+			}
+			for _, account := range ou.Accounts {
+				accounts[account.Name] = placed{ou: ouName, account: account}
+			}
+		}
+		return accounts
+	}
+
+	oldAccounts, newAccounts := index(from), index(to)
+	var changes []Change
+	for name, current := range newAccounts {
+		previous, ok := oldAccounts[name]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, Category: CategoryAccount, Path: name, New: current.ou})
+			continue
+		}
+		if previous.ou != current.ou {
+			changes = append(changes, Change{Kind: ChangeMoved, Category: CategoryAccount, Path: name,
+				Old: previous.ou, New: current.ou})
+		}
+		if previous.account.Email != current.account.Email {
+			changes = append(changes, Change{Kind: ChangeUpdated, Category: CategoryAccount, Path: name + "/email",
+				Old: previous.account.Email, New: current.account.Email})
+		}
+		changes = append(changes, diffTags(current.ou+"/"+name, previous.account.Tags, current.account.Tags)...)
+	}
+	for name, previous := range oldAccounts {
+		if _, ok := newAccounts[name]; !ok {
+			changes = append(changes, Change{Kind: ChangeRemoved, Category: CategoryAccount, Path: name, Old: previous.ou})
+		}
+	}
+	return changes
+}
+
+// diffSet reports the values added to or removed from a list
+func diffSet(category, prefix string, from, to []string) []Change {
+	oldSet, newSet := make(map[string]bool), make(map[string]bool)
+	for _, value := range from {
+		oldSet[value] = true
+	}
+	for _, value := range to {
+		newSet[value] = true
+	}
+
+	var changes []Change
+	for value := range newSet {
+		if !oldSet[value] {
+			changes = append(changes, Change{Kind: ChangeAdded, Category: category, Path: prefix + value})
+		}
+	}
+	for value := range oldSet {
+		if !newSet[value] {
+			changes = append(changes, Change{Kind: ChangeRemoved, Category: category, Path: prefix + value})
+		}
+	}
+	return changes
+}
+
+// diffTags reports the tags added, removed or changed on an owner, the landing zone
+// when owner is empty
+func diffTags(owner string, from, to map[string]string) []Change {
+	prefix := ""
+	if owner != "" {
+		prefix = owner + ":"
+	}
+
+	var changes []Change
+	for key, value := range to {
+		previous, ok := from[key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: ChangeAdded, Category: CategoryTag, Path: prefix + key, New: value})
+		case previous != value:
+			changes = append(changes, Change{Kind: ChangeUpdated, Category: CategoryTag, Path: prefix + key,
+				Old: previous, New: value})
+		}
+	}
+	for key, value := range from {
+		if _, ok := to[key]; !ok {
+			changes = append(changes, Change{Kind: ChangeRemoved, Category: CategoryTag, Path: prefix + key, Old: value})
+		}
+	}
+	return changes
+}
+
+// diffSettings compares every other field of the landing zone configuration by its
+// JSON representation
+func diffSettings(from, to *LandingZoneConfig) ([]Change, error) {
+	oldFields, err := fields(from)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := fields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]bool)
+	for _, key := range structuredKeys {
+		skip[key] = true
+	}
+	for key := range toggles {
+		skip[key] = true
+	}
+
+	keys := make(map[string]bool)
+	for key := range oldFields {
+		keys[key] = true
+	}
+	for key := range newFields {
+		keys[key] = true
+	}
+
+	var changes []Change
+	for key := range keys {
+		if skip[key] {
+			continue
+		}
+		previous, current := oldFields[key], newFields[key]
+		if reflect.DeepEqual(previous, current) {
+			continue
+		}
+		changes = append(changes, Change{Kind: ChangeUpdated, Category: CategorySetting, Path: key,
+			Old: compact(previous), New: compact(current)})
+	}
+	return changes, nil
+}
+
+// fields returns the JSON fields of a landing zone configuration, without empty values
+func fields(lz *LandingZoneConfig) (map[string]interface{}, error) {
+	data, err := json.Marshal(lz)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal configuration: %w", err)
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal configuration: %w", err)
+	}
+
+	for key, value := range values {
+		if value == nil || reflect.DeepEqual(value, "") || reflect.DeepEqual(value, false) ||
+			reflect.DeepEqual(value, float64(0)) || reflect.DeepEqual(value, []interface{}{}) ||
+			reflect.DeepEqual(value, map[string]interface{}{}) {
+			delete(values, key)
+		}
+	}
+	return values, nil
+}
+
+// compact renders a value as compact JSON, or an empty string when it is unset
+func compact(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
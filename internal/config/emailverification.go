@@ -0,0 +1,62 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Defaults of the email verification
+const (
+	DefaultEmailConcurrency    = 8
+	MaxEmailConcurrency        = 64
+	DefaultEmailTimeoutSeconds = 10
+)
+
+// EmailVerificationConfig defines the pre-flight verification of the email addresses of
+// the accounts. When enabled, the deploy command checks that the domain of every
+// address receives mail before applying any change. With SES, the addresses are also
+// looked up in the SES suppression list of the management account, which holds the
+// addresses that bounced or complained.
+type EmailVerificationConfig struct {
+	Enabled        bool `json:"enabled"`
+	SES            bool `json:"ses,omitempty"`
+	Concurrency    int  `json:"concurrency,omitempty"`
+	TimeoutSeconds int  `json:"timeoutSeconds,omitempty"`
+}
+
+// Workers returns the number of addresses verified at the same time
+func (e *EmailVerificationConfig) Workers() int {
+	if e == nil || e.Concurrency == 0 {
+		return DefaultEmailConcurrency
+	}
+	return e.Concurrency
+}
+
+// Timeout returns the time the verification of a single address may take
+func (e *EmailVerificationConfig) Timeout() time.Duration {
+	if e == nil || e.TimeoutSeconds == 0 {
+		return DefaultEmailTimeoutSeconds * time.Second
+	}
+	return time.Duration(e.TimeoutSeconds) * time.Second
+}
+
+// validateEmailVerificationConfig validates the concurrency and timeout of the email
+// verification
+func (c *OrganizationConfig) validateEmailVerificationConfig() error {
+	e := c.LandingZoneConfig.EmailVerification
+	if e == nil {
+		return nil
+	}
+
+	if e.Concurrency < 0 || e.Concurrency > MaxEmailConcurrency {
+		return fmt.Errorf("email verification concurrency must be between 0 and %d", MaxEmailConcurrency)
+	}
+	if e.TimeoutSeconds < 0 {
+		return fmt.Errorf("email verification timeout must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EnvironmentTag is the tag of every resource naming the environment it belongs to
+const EnvironmentTag = "Environment"
+
+var (
+	environmentNameRE = regexp.MustCompile(`^[a-z][a-z0-9-]{0,15}$`)
+	namePrefixRE      = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,19}$`)
+)
+
+// EnvironmentConfig is one environment of the landing zone, such as a sandbox
+// organization the configuration is tested in before production. AWSProfile and
+// Region point at the organization of the environment. Stack is the Pulumi stack of
+// the environment, its name by default. NamePrefix prefixes the names of the state
+// table and bucket, the state machine, the observability sink and workspaces and the
+// break-glass user, "<name>-" by default, and the SSM parameters are kept under
+// /organization/<name> unless a namespace is configured. Tags are added to the tags
+// of the landing zone, along with the Environment tag. Mail overrides the sender of
+// the emails, whose subjects are prefixed with the environment.
+type EnvironmentConfig struct {
+	AWSProfile string            `json:"awsProfile,omitempty"`
+	Region     string            `json:"region,omitempty"`
+	Stack      string            `json:"stack,omitempty"`
+	NamePrefix *string           `json:"namePrefix,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Mail       *MailConfig       `json:"mail,omitempty"`
+}
+
+// EnvironmentNames returns the sorted names of the configured environments
+func (c *OrganizationConfig) EnvironmentNames() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	names := make([]string, 0, len(c.Environments))
+	for name := range c.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SelectEnvironment applies the named environment to the current configuration. An
+// empty name leaves the configuration unchanged.
+func (c *OrganizationConfig) SelectEnvironment(name string) error {
+	if name == "" {
+		return nil
+	}
+	names := c.EnvironmentNames()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	env, ok := c.Environments[name]
+	if !ok || env == nil {
+		if len(names) == 0 {
+			return fmt.Errorf("environment %q is not configured, the configuration has no environments", name)
+		}
+		return fmt.Errorf("unknown environment %q, must be one of: %s", name, strings.Join(names, ", "))
+	}
+	if c.LandingZoneConfig == nil {
+		return fmt.Errorf("environment %q has no landing zone configuration", name)
+	}
+
+	if env.AWSProfile != "" {
+		c.AWSProfile = env.AWSProfile
+	}
+	if env.Region != "" {
+		c.Region = env.Region
+	}
+	c.LandingZoneConfig.applyEnvironment(name, env)
+	c.Environment = name
+	return nil
+}
+
+// StackName returns the Pulumi stack of the environment
+func (e *EnvironmentConfig) StackName(name string) string {
+	if e.Stack == "" {
+		return name
+	}
+	return e.Stack
+}
+
+// Prefix returns the prefix of the names of the environment
+func (e *EnvironmentConfig) Prefix(name string) string {
+	if e.NamePrefix == nil {
+		return name + "-"
+	}
+	return *e.NamePrefix
+}
+
+// applyEnvironment tags the landing zone with the environment and prefixes the names
+// left to their defaults
+func (lz *LandingZoneConfig) applyEnvironment(name string, env *EnvironmentConfig) {
+	tags := make(map[string]string, len(lz.Tags)+len(env.Tags)+1)
+	for key, value := range lz.Tags {
+		tags[key] = value
+	}
+	for key, value := range env.Tags {
+		tags[key] = value
+	}
+	tags[EnvironmentTag] = name
+	lz.Tags = tags
+
+	lz.environment = name
+
+	prefix := env.Prefix(name)
+	if lz.StateTableName == "" {
+		lz.StateTableName = prefix + StateTableName
+	}
+	if lz.StateBucketName == "" {
+		lz.StateBucketName = prefix + StateBackupBucket
+	}
+	if lz.Parameters == nil {
+		lz.Parameters = &ParametersConfig{}
+	}
+	if lz.Parameters.Namespace == "" {
+		lz.Parameters.Namespace = fmt.Sprintf("%s/%s", DefaultParameterNamespace, name)
+	}
+	if v := lz.Vending; v != nil && v.StateMachineName == "" {
+		v.StateMachineName = prefix + DefaultVendingStateMachineName
+	}
+	if o := lz.Observability; o != nil {
+		if o.SinkName == "" {
+			o.SinkName = prefix + DefaultObservabilitySinkName
+		}
+		if o.Grafana != nil && o.Grafana.WorkspaceName == "" {
+			o.Grafana.WorkspaceName = prefix + DefaultGrafanaWorkspaceName
+		}
+		if o.Prometheus != nil && o.Prometheus.Alias == "" {
+			o.Prometheus.Alias = prefix + DefaultPrometheusAlias
+		}
+	}
+	if s := lz.Secrets; s != nil && s.BreakGlass != nil && s.BreakGlass.UserName == "" {
+		s.BreakGlass.UserName = prefix + DefaultBreakGlassUserName
+	}
+	if env.Mail != nil {
+		lz.Mail = lz.Mail.merge(env.Mail)
+	}
+	if lz.Mail != nil && lz.Mail.SubjectPrefix == "" {
+		lz.Mail.SubjectPrefix = fmt.Sprintf("[%s] ", name)
+	}
+}
+
+// validateEnvironments validates the names, stacks and prefixes of the environments
+func (c *OrganizationConfig) validateEnvironments() error {
+	stacks := make(map[string]string)
+	for name, env := range c.Environments {
+		if !environmentNameRE.MatchString(name) {
+			return fmt.Errorf("invalid environment name %q, must be lowercase letters, digits and '-' of up to 16 characters", name)
+		}
+		if env == nil {
+			return fmt.Errorf("environment %s has no configuration", name)
+		}
+
+		stack := env.StackName(name)
+		if other, ok := stacks[stack]; ok {
+			return fmt.Errorf("environments %s and %s use the same stack %s", other, name, stack)
+		}
+		stacks[stack] = name
+
+		if prefix := env.Prefix(name); prefix != "" && !namePrefixRE.MatchString(prefix) {
+			return fmt.Errorf("invalid name prefix %q of environment %s", prefix, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExtensionsConfig defines the custom guardrails and baselines deployed with the
+// landing zone. Plugins are Go plugins built against the same version of this module
+// that register their guardrails and baselines with pkg/extensions when they are
+// opened; Directory is searched for plugins as well. Settings are passed to the
+// extension of the same name, and Disabled extensions are registered but not deployed.
+type ExtensionsConfig struct {
+	Plugins   []string                     `json:"plugins,omitempty"`
+	Directory string                       `json:"directory,omitempty"`
+	Settings  map[string]map[string]string `json:"settings,omitempty"`
+	Disabled  []string                     `json:"disabled,omitempty"`
+}
+
+// ExtensionEnabled reports whether an extension is deployed
+func (e *ExtensionsConfig) ExtensionEnabled(name string) bool {
+	if e == nil {
+		return true
+	}
+	for _, disabled := range e.Disabled {
+		if disabled == name {
+			return false
+		}
+	}
+	return true
+}
+
+// ExtensionSettings returns the settings of an extension
+func (e *ExtensionsConfig) ExtensionSettings(name string) map[string]string {
+	if e == nil {
+		return nil
+	}
+	return e.Settings[name]
+}
+
+// validateExtensionsConfig validates the plugins and settings of the extensions
+func (c *OrganizationConfig) validateExtensionsConfig() error {
+	e := c.LandingZoneConfig.Extensions
+	if e == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, plugin := range e.Plugins {
+		if !strings.HasSuffix(plugin, PluginSuffix) {
+			return fmt.Errorf("extension plugin %q must be a %s file", plugin, PluginSuffix)
+		}
+		if seen[plugin] {
+			return fmt.Errorf("duplicate extension plugin %q", plugin)
+		}
+		seen[plugin] = true
+	}
+
+	for name, settings := range e.Settings {
+		if name == "" {
+			return fmt.Errorf("extension settings require the name of the extension")
+		}
+		for key := range settings {
+			if key == "" {
+				return fmt.Errorf("settings of extension %s hold an empty key", name)
+			}
+		}
+	}
+	for _, name := range e.Disabled {
+		if name == "" {
+			return fmt.Errorf("disabled extensions require a name")
+		}
+	}
+	return nil
+}
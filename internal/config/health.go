@@ -0,0 +1,63 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// Health event type categories
+const (
+	HealthCategoryIssue               = "issue"
+	HealthCategoryAccountNotification = "accountNotification"
+	HealthCategoryScheduledChange     = "scheduledChange"
+	HealthCategoryInvestigation       = "investigation"
+)
+
+// HealthConfig defines the AWS Health organizational view and the routing of the health
+// events of every account to an SNS topic
+type HealthConfig struct {
+	OrganizationalView   bool     `json:"organizationalView"`
+	NotificationTopicArn string   `json:"notificationTopicArn,omitempty"`
+	EventTypeCategories  []string `json:"eventTypeCategories,omitempty"`
+}
+
+// validateHealthConfig validates the health event routing
+func (c *OrganizationConfig) validateHealthConfig() error {
+	h := c.LandingZoneConfig.Health
+	if h == nil {
+		return nil
+	}
+
+	if h.NotificationTopicArn != "" {
+		topic, err := arn.Parse(h.NotificationTopicArn)
+		if err != nil || topic.Service != "sns" {
+			return fmt.Errorf("invalid health notification topic ARN: %s", h.NotificationTopicArn)
+		}
+		// Events of other regions are forwarded to the event bus of the topic account,
+		// which only accepts them from the same account
+		if mgmt := c.LandingZoneConfig.ManagementAccountId; mgmt != "" && topic.AccountID != mgmt {
+			return fmt.Errorf("health notification topic must be in the management account %s", mgmt)
+		}
+	}
+
+	for _, category := range h.EventTypeCategories {
+		switch category {
+		case HealthCategoryIssue, HealthCategoryAccountNotification, HealthCategoryScheduledChange, HealthCategoryInvestigation:
+		default:
+			return fmt.Errorf("unsupported health event type category %q, must be one of: %s", category,
+				strings.Join([]string{HealthCategoryIssue, HealthCategoryAccountNotification,
+					HealthCategoryScheduledChange, HealthCategoryInvestigation}, ", "))
+		}
+	}
+
+	if !h.OrganizationalView && h.NotificationTopicArn == "" {
+		return fmt.Errorf("health requires the organizational view or a notification topic")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// KeyPolicyConfig defines the policies of the customer managed KMS keys created by the
+// landing zone. The root of the management account always administers the keys, and
+// AdminRoleArns administer them besides without being able to use them. With
+// OrganizationWide, keys used by the accounts of the organization are granted to the
+// organization through aws:PrincipalOrgID rather than to each account.
+type KeyPolicyConfig struct {
+	AdminRoleArns    []string `json:"adminRoleArns,omitempty"`
+	OrganizationWide bool     `json:"organizationWide,omitempty"`
+}
+
+// KeyAdminRoles returns the roles administering the customer managed keys
+func (c *LandingZoneConfig) KeyAdminRoles() []string {
+	if c.KeyPolicy == nil {
+		return nil
+	}
+	return c.KeyPolicy.AdminRoleArns
+}
+
+// KeysOrganizationWide reports whether keys are granted to the whole organization
+func (c *LandingZoneConfig) KeysOrganizationWide() bool {
+	return c.KeyPolicy != nil && c.KeyPolicy.OrganizationWide
+}
+
+// validateKeyPolicyConfig validates the key administrator roles
+func (c *OrganizationConfig) validateKeyPolicyConfig() error {
+	p := c.LandingZoneConfig.KeyPolicy
+	if p == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(p.AdminRoleArns))
+	for _, roleArn := range p.AdminRoleArns {
+		role, err := arn.Parse(roleArn)
+		if err != nil || role.Service != "iam" || !strings.HasPrefix(role.Resource, "role/") {
+			return fmt.Errorf("invalid key administrator role ARN: %s", roleArn)
+		}
+		if !isValidAccountId(role.AccountID) {
+			return fmt.Errorf("key administrator role %s has an invalid account ID", roleArn)
+		}
+		if seen[roleArn] {
+			return fmt.Errorf("duplicate key administrator role %s", roleArn)
+		}
+		seen[roleArn] = true
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/sops"
+)
+
+// ErrEncryptedFile is returned when a plaintext configuration would replace a file
+// encrypted with SOPS
+var ErrEncryptedFile = errors.New("refusing to overwrite a SOPS-encrypted file with plaintext")
+
+// LoadFile reads and validates a JSON configuration file
+func LoadFile(path string) (*OrganizationConfig, error) {
+	cfg, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// ReadFile reads a JSON configuration file without validating it. Files encrypted
+// with SOPS are decrypted in memory.
+func ReadFile(path string) (*OrganizationConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration %s: %w", path, err)
+	}
+	if sops.IsEncrypted(data) {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		defer cancel()
+		if data, err = sops.Decrypt(ctx, data); err != nil {
+			return nil, fmt.Errorf("failed to decrypt configuration %s: %w", path, err)
+		}
+	}
+
+	cfg, err := NewOrganizationConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// A preset is expanded first, the file then overrides the fields it sets
+	type presetHeader struct {
+		LandingZoneConfig *struct {
+			Preset string `json:"preset"`
+		} `json:"LandingZoneConfig"`
+	}
+	var header struct {
+		presetHeader
+		Organizations map[string]json.RawMessage `json:"organizations"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration %s: %w", path, err)
+	}
+	if header.LandingZoneConfig != nil && header.LandingZoneConfig.Preset != "" {
+		if cfg.LandingZoneConfig, err = PresetConfig(header.LandingZoneConfig.Preset); err != nil {
+			return nil, fmt.Errorf("invalid configuration %s: %w", path, err)
+		}
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration %s: %w", path, err)
+	}
+
+	// Organizations are decoded into fresh values, so their presets are expanded and
+	// overridden separately
+	for name, raw := range header.Organizations {
+		var org presetHeader
+		if err := json.Unmarshal(raw, &org); err != nil {
+			return nil, fmt.Errorf("failed to parse configuration %s: organization %s: %w", path, name, err)
+		}
+		if org.LandingZoneConfig == nil || org.LandingZoneConfig.Preset == "" {
+			continue
+		}
+		preset, err := PresetConfig(org.LandingZoneConfig.Preset)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration %s: organization %s: %w", path, name, err)
+		}
+		target := &OrganizationTarget{LandingZoneConfig: preset}
+		if err := json.Unmarshal(raw, target); err != nil {
+			return nil, fmt.Errorf("failed to parse configuration %s: organization %s: %w", path, name, err)
+		}
+		cfg.Organizations[name] = target
+	}
+
+	if cfg.Version == "" {
+		cfg.Version = ConfigVersion
+	}
+
+	return cfg, nil
+}
+
+// WriteFile writes a plaintext JSON configuration file. A file encrypted with SOPS is
+// never replaced, so no decrypted copy takes the place of a sensitive configuration.
+func WriteFile(path string, data []byte) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read configuration %s: %w", path, err)
+	}
+	if err == nil && sops.IsEncrypted(existing) {
+		return fmt.Errorf("%w: %s", ErrEncryptedFile, path)
+	}
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write configuration %s: %w", path, err)
+	}
+	return nil
+}
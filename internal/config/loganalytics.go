@@ -0,0 +1,131 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Defaults of the log analytics
+const (
+	DefaultLogAnalyticsDatabase  = "landing_zone_logs"
+	DefaultLogAnalyticsWorkgroup = "landing-zone-logs"
+	DefaultLogAnalyticsStartDate = "2024/01/01"
+)
+
+// LogAnalyticsDateFormat is the format of the dates of the partitions of the log tables
+const LogAnalyticsDateFormat = "2006/01/02"
+
+var (
+	glueDatabaseRE     = regexp.MustCompile(`^[a-z0-9_]{1,255}$`)
+	athenaWorkgroupRE  = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+	logAnalyticsPathRE = regexp.MustCompile(`^[A-Za-z0-9!_.*'()/-]*$`)
+)
+
+// LogAnalyticsConfig enables the Athena queries of the logs of the landing zone. A Glue
+// database of the log archive account holds a table of the organization trail delivered
+// to the log bucket and, when FlowLogBucketName is set, a table of the VPC flow logs.
+// Both tables project their partitions by account, region and date rather than loading
+// them, from StartDate onwards and for Regions, the governed regions by default. The
+// trail is delivered under CloudTrailPrefix, the organization ID by default, and the flow
+// logs under FlowLogPrefix. Saved queries of the tables run in Workgroup, whose results
+// go to ResultsBucket, the log bucket by default.
+type LogAnalyticsConfig struct {
+	DatabaseName     string   `json:"databaseName,omitempty"`
+	Workgroup        string   `json:"workgroup,omitempty"`
+	ResultsBucket    string   `json:"resultsBucket,omitempty"`
+	CloudTrailPrefix string   `json:"cloudTrailPrefix,omitempty"`
+	FlowLogPrefix    string   `json:"flowLogPrefix,omitempty"`
+	StartDate        string   `json:"startDate,omitempty"`
+	Regions          []string `json:"regions,omitempty"`
+}
+
+// Database returns the Glue database of the log tables
+func (a *LogAnalyticsConfig) Database() string {
+	if a.DatabaseName == "" {
+		return DefaultLogAnalyticsDatabase
+	}
+	return a.DatabaseName
+}
+
+// WorkgroupName returns the Athena workgroup of the saved queries
+func (a *LogAnalyticsConfig) WorkgroupName() string {
+	if a.Workgroup == "" {
+		return DefaultLogAnalyticsWorkgroup
+	}
+	return a.Workgroup
+}
+
+// Since returns the date of the first partition of the log tables
+func (a *LogAnalyticsConfig) Since() string {
+	if a.StartDate == "" {
+		return DefaultLogAnalyticsStartDate
+	}
+	return a.StartDate
+}
+
+// LogAnalyticsResultsBucket returns the bucket the query results are written to
+func (c *LandingZoneConfig) LogAnalyticsResultsBucket() string {
+	if a := c.LogAnalytics; a != nil && a.ResultsBucket != "" {
+		return a.ResultsBucket
+	}
+	return c.LogBucketName
+}
+
+// LogAnalyticsRegions returns the regions the partitions of the log tables are
+// projected for
+func (c *LandingZoneConfig) LogAnalyticsRegions() []string {
+	if a := c.LogAnalytics; a != nil && len(a.Regions) > 0 {
+		return a.Regions
+	}
+	return c.GovernedRegions
+}
+
+// validateLogAnalyticsConfig validates the Glue tables and Athena queries of the logs
+func (c *OrganizationConfig) validateLogAnalyticsConfig() error {
+	lz := c.LandingZoneConfig
+	a := lz.LogAnalytics
+	if a == nil {
+		return nil
+	}
+
+	if !isValidAccountId(lz.LogArchiveAccountId) {
+		return fmt.Errorf("log analytics requires a valid log archive account ID")
+	}
+	if lz.LogBucketName == "" {
+		return fmt.Errorf("log analytics requires the log bucket name")
+	}
+	if !glueDatabaseRE.MatchString(a.Database()) {
+		return fmt.Errorf("invalid log analytics database name %q: only lower case letters, digits and underscores are allowed", a.DatabaseName)
+	}
+	if !athenaWorkgroupRE.MatchString(a.WorkgroupName()) {
+		return fmt.Errorf("invalid log analytics workgroup %q", a.Workgroup)
+	}
+	for _, prefix := range []string{a.CloudTrailPrefix, a.FlowLogPrefix} {
+		if !logAnalyticsPathRE.MatchString(prefix) || strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("invalid log analytics prefix %q", prefix)
+		}
+	}
+	if _, err := time.Parse(LogAnalyticsDateFormat, a.Since()); err != nil {
+		return fmt.Errorf("invalid log analytics start date %q, must be yyyy/MM/dd", a.StartDate)
+	}
+
+	governed := make(map[string]bool)
+	for _, region := range lz.GovernedRegions {
+		governed[region] = true
+	}
+	for _, region := range a.Regions {
+		if !governed[region] {
+			return fmt.Errorf("log analytics region %s is not a governed region", region)
+		}
+	}
+	if len(lz.LogAnalyticsRegions()) == 0 {
+		return fmt.Errorf("log analytics requires regions or governed regions")
+	}
+	return nil
+}
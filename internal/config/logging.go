@@ -0,0 +1,66 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// logLevels lists the log levels accepted by the logging configuration
+var logLevels = map[string]bool{
+	"debug": true,
+	"info":  true,
+	"warn":  true,
+	"error": true,
+}
+
+// logFormats lists the formats accepted for the console output
+var logFormats = map[string]bool{
+	"console": true,
+	"json":    true,
+}
+
+// LoggingConfig sets the level of the logs and the format of the console output.
+// Components overrides the level of single components, such as engine or vending.
+// Directory replaces the default directory of the log files, DisableFile logs to the
+// console only.
+type LoggingConfig struct {
+	Level       string            `json:"level,omitempty"`
+	Format      string            `json:"format,omitempty"`
+	Components  map[string]string `json:"components,omitempty"`
+	Directory   string            `json:"directory,omitempty"`
+	DisableFile bool              `json:"disableFile,omitempty"`
+}
+
+// validateLoggingConfig validates the logging configuration
+func (c *OrganizationConfig) validateLoggingConfig() error {
+	l := c.LandingZoneConfig.Logging
+	if l == nil {
+		return nil
+	}
+
+	if l.Level != "" && !logLevels[l.Level] {
+		return fmt.Errorf("invalid log level: %s", l.Level)
+	}
+	if l.Format != "" && !logFormats[l.Format] {
+		return fmt.Errorf("invalid log format: %s", l.Format)
+	}
+	if l.Directory != "" && l.DisableFile {
+		return fmt.Errorf("log directory %s is set but log files are disabled", l.Directory)
+	}
+
+	names := make([]string, 0, len(l.Components))
+	for name := range l.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !logLevels[l.Components[name]] {
+			return fmt.Errorf("invalid log level of component %s: %s", name, l.Components[name])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import "fmt"
+
+// MailConfig defines the emails sent through SES by the reports, approvals and
+// notifications. From must be a verified SES identity of Region, the region of the
+// credentials by default. ConfigurationSet names the SES configuration set tracking
+// the deliveries. SubjectPrefix starts every subject, "[<environment>] " when an
+// environment is selected. LifecycleTo receives the account lifecycle transitions
+// along with the webhooks. An environment may override the sender with its own mail
+// configuration.
+type MailConfig struct {
+	From             string   `json:"from,omitempty"`
+	ReplyTo          []string `json:"replyTo,omitempty"`
+	Region           string   `json:"region,omitempty"`
+	ConfigurationSet string   `json:"configurationSet,omitempty"`
+	SubjectPrefix    string   `json:"subjectPrefix,omitempty"`
+	LifecycleTo      []string `json:"lifecycleTo,omitempty"`
+}
+
+// NotifiesLifecycle reports whether the account lifecycle transitions are emailed
+func (m *MailConfig) NotifiesLifecycle() bool {
+	return m != nil && len(m.LifecycleTo) > 0
+}
+
+// merge returns the configuration with the fields set by an override replaced
+func (m *MailConfig) merge(override *MailConfig) *MailConfig {
+	merged := MailConfig{}
+	if m != nil {
+		merged = *m
+	}
+	if override.From != "" {
+		merged.From = override.From
+	}
+	if len(override.ReplyTo) > 0 {
+		merged.ReplyTo = override.ReplyTo
+	}
+	if override.Region != "" {
+		merged.Region = override.Region
+	}
+	if override.ConfigurationSet != "" {
+		merged.ConfigurationSet = override.ConfigurationSet
+	}
+	if override.SubjectPrefix != "" {
+		merged.SubjectPrefix = override.SubjectPrefix
+	}
+	if len(override.LifecycleTo) > 0 {
+		merged.LifecycleTo = override.LifecycleTo
+	}
+	return &merged
+}
+
+// validateMailConfig validates the sender and recipients of the emails, and the
+// environments overriding them
+func (c *OrganizationConfig) validateMailConfig() error {
+	if err := validateMail("mail", c.LandingZoneConfig.Mail, true); err != nil {
+		return err
+	}
+	for _, name := range c.EnvironmentNames() {
+		if env := c.Environments[name]; env != nil {
+			err := validateMail(fmt.Sprintf("mail of environment %s", name), env.Mail, c.LandingZoneConfig.Mail == nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateMail validates a mail configuration. An environment may leave the sender to
+// the landing zone.
+func validateMail(section string, mail *MailConfig, requireFrom bool) error {
+	if mail == nil {
+		return nil
+	}
+
+	if mail.From == "" && requireFrom {
+		return fmt.Errorf("%s requires a sender", section)
+	}
+	if mail.From != "" && !attributeEmailRE.MatchString(mail.From) {
+		return fmt.Errorf("%s sender %q is not an email address", section, mail.From)
+	}
+	for _, list := range [][]string{mail.ReplyTo, mail.LifecycleTo} {
+		for _, address := range list {
+			if !attributeEmailRE.MatchString(address) {
+				return fmt.Errorf("%s recipient %q is not an email address", section, address)
+			}
+		}
+	}
+	return nil
+}
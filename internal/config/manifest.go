@@ -0,0 +1,82 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Backends the landing zone manifest is published to
+	ManifestBackendSSM       = "ssm"
+	ManifestBackendAppConfig = "appconfig"
+
+	// Manifest defaults
+	DefaultManifestApplication     = "landing-zone"
+	DefaultManifestEnvironment     = "production"
+	DefaultDeploymentDuration      = 10
+	DefaultDeploymentGrowthFactor  = 20
+	DefaultDeploymentFinalBakeTime = 10
+)
+
+// ManifestConfig defines where the landing zone manifest is published for consumers:
+// a single SecureString parameter, or AWS AppConfig with one configuration profile for
+// the landing zone and one per OU
+type ManifestConfig struct {
+	Backend       string                   `json:"backend"`
+	ParameterName string                   `json:"parameterName,omitempty"`
+	AppConfig     *AppConfigManifestConfig `json:"appConfig,omitempty"`
+}
+
+// AppConfigManifestConfig defines the AppConfig application the manifest is deployed
+// to. The deployment strategy is either a predefined one, such as
+// AppConfig.Linear50PercentEvery30Seconds, or a linear strategy built from the
+// duration, growth factor and bake time.
+type AppConfigManifestConfig struct {
+	Application               string  `json:"application,omitempty"`
+	Environment               string  `json:"environment,omitempty"`
+	DeploymentStrategy        string  `json:"deploymentStrategy,omitempty"`
+	DeploymentDurationMinutes int     `json:"deploymentDurationMinutes,omitempty"`
+	GrowthFactor              float64 `json:"growthFactor,omitempty"`
+	FinalBakeTimeMinutes      int     `json:"finalBakeTimeMinutes,omitempty"`
+}
+
+// validateManifestConfig validates the manifest publication
+func (c *OrganizationConfig) validateManifestConfig() error {
+	m := c.LandingZoneConfig.Manifest
+	if m == nil {
+		return nil
+	}
+
+	switch m.Backend {
+	case ManifestBackendSSM:
+		if m.ParameterName != "" && !strings.HasPrefix(m.ParameterName, "/") {
+			return fmt.Errorf("manifest parameter name must be a path starting with /")
+		}
+		if m.AppConfig != nil {
+			return fmt.Errorf("AppConfig settings require the %s manifest backend", ManifestBackendAppConfig)
+		}
+	case ManifestBackendAppConfig:
+		if a := m.AppConfig; a != nil {
+			if a.DeploymentStrategy != "" && (a.DeploymentDurationMinutes != 0 || a.GrowthFactor != 0 || a.FinalBakeTimeMinutes != 0) {
+				return fmt.Errorf("a predefined deployment strategy cannot be combined with a custom duration, growth factor or bake time")
+			}
+			if a.DeploymentDurationMinutes < 0 || a.DeploymentDurationMinutes > 1440 {
+				return fmt.Errorf("deployment duration must be between 0 and 1440 minutes")
+			}
+			if a.GrowthFactor < 0 || a.GrowthFactor > 100 {
+				return fmt.Errorf("deployment growth factor must be between 1 and 100")
+			}
+			if a.FinalBakeTimeMinutes < 0 || a.FinalBakeTimeMinutes > 1440 {
+				return fmt.Errorf("deployment bake time must be between 0 and 1440 minutes")
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported manifest backend %q, must be one of: %s, %s",
+			m.Backend, ManifestBackendSSM, ManifestBackendAppConfig)
+	}
+	return nil
+}
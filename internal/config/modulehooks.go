@@ -0,0 +1,138 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/selection"
+)
+
+// Phases of the module hooks
+const (
+	ModuleHookPre  = "pre"
+	ModuleHookPost = "post"
+)
+
+// Kinds of the module hooks
+const (
+	ModuleHookCommand = "command"
+	ModuleHookLambda  = "lambda"
+	ModuleHookWebhook = "webhook"
+)
+
+// Defaults of the module hooks
+const (
+	DefaultModuleHookTimeout = 300
+	MaxModuleHookTimeout     = 900
+)
+
+// ModuleHooksConfig defines the hooks run before and after a module of the landing
+// zone is applied
+type ModuleHooksConfig struct {
+	Pre  []ModuleHook `json:"pre,omitempty"`
+	Post []ModuleHook `json:"post,omitempty"`
+}
+
+// ModuleHook runs a local command, invokes a Lambda function or posts to a webhook
+// with the module, the phase, the run ID and, after the module, its outputs as JSON.
+// Commands read the JSON on standard input. A failed hook fails the update unless
+// ContinueOnError is set.
+type ModuleHook struct {
+	Name            string   `json:"name"`
+	Command         []string `json:"command,omitempty"`
+	FunctionName    string   `json:"functionName,omitempty"`
+	URL             string   `json:"url,omitempty"`
+	TimeoutSeconds  int      `json:"timeoutSeconds,omitempty"`
+	ContinueOnError bool     `json:"continueOnError,omitempty"`
+}
+
+// Kind returns the kind of the hook
+func (h *ModuleHook) Kind() string {
+	switch {
+	case len(h.Command) > 0:
+		return ModuleHookCommand
+	case h.FunctionName != "":
+		return ModuleHookLambda
+	default:
+		return ModuleHookWebhook
+	}
+}
+
+// Timeout returns the seconds the hook may run
+func (h *ModuleHook) Timeout() int {
+	if h.TimeoutSeconds == 0 {
+		return DefaultModuleHookTimeout
+	}
+	return h.TimeoutSeconds
+}
+
+// Phase returns the hooks of a phase of the module
+func (m *ModuleHooksConfig) Phase(phase string) []ModuleHook {
+	if m == nil {
+		return nil
+	}
+	if phase == ModuleHookPre {
+		return m.Pre
+	}
+	return m.Post
+}
+
+// validateModuleHooks validates the modules, targets and timeouts of the module hooks
+func (c *OrganizationConfig) validateModuleHooks() error {
+	modules := make(map[string]bool, len(selection.Modules))
+	for _, module := range selection.Modules {
+		modules[module] = true
+	}
+
+	for module, hooks := range c.LandingZoneConfig.ModuleHooks {
+		if !modules[module] {
+			return fmt.Errorf("module hooks of unknown module %q", module)
+		}
+		if hooks == nil {
+			continue
+		}
+
+		names := make(map[string]bool)
+		for _, phase := range []string{ModuleHookPre, ModuleHookPost} {
+			for _, hook := range hooks.Phase(phase) {
+				if !hookNameRE.MatchString(hook.Name) {
+					return fmt.Errorf("invalid %s hook name %q of module %s", phase, hook.Name, module)
+				}
+				if names[hook.Name] {
+					return fmt.Errorf("duplicate hook %s of module %s", hook.Name, module)
+				}
+				names[hook.Name] = true
+
+				targets := 0
+				if len(hook.Command) > 0 {
+					targets++
+				}
+				if hook.FunctionName != "" {
+					targets++
+				}
+				if hook.URL != "" {
+					targets++
+					if u, err := url.Parse(hook.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+						return fmt.Errorf("hook %s of module %s requires an https URL", hook.Name, module)
+					}
+				}
+				if targets != 1 {
+					return fmt.Errorf("hook %s of module %s must have exactly one of command, functionName or url",
+						hook.Name, module)
+				}
+				if len(hook.Command) > 0 && hook.Command[0] == "" {
+					return fmt.Errorf("hook %s of module %s has an empty command", hook.Name, module)
+				}
+				if hook.TimeoutSeconds < 0 || hook.TimeoutSeconds > MaxModuleHookTimeout {
+					return fmt.Errorf("timeout of hook %s of module %s must be between 1 and %d seconds",
+						hook.Name, module, MaxModuleHookTimeout)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kinds of the resources named by the naming template
+const (
+	ResourceRole     = "role"
+	ResourceBucket   = "bucket"
+	ResourceLogGroup = "logGroup"
+	ResourceKeyAlias = "keyAlias"
+)
+
+// Placeholders of the naming template. {name} is shared with the parameter template.
+const (
+	placeholderOrg     = "{org}"
+	placeholderEnv     = "{env}"
+	placeholderService = "{service}"
+	placeholderRegion  = "{region}"
+)
+
+// KeyAliasPrefix starts the name of every KMS key alias
+const KeyAliasPrefix = "alias/"
+
+var namingTemplateRE = regexp.MustCompile(`^[A-Za-z0-9_./{}-]+$`)
+
+// namingRule limits the length and characters of the names of a kind of resource
+type namingRule struct {
+	min     int
+	max     int
+	pattern *regexp.Regexp
+}
+
+// namingRules are the naming limits of each kind of resource
+var namingRules = map[string]namingRule{
+	ResourceRole:     {min: 1, max: 64, pattern: regexp.MustCompile(`^[A-Za-z0-9+=,.@_-]+$`)},
+	ResourceBucket:   {min: 3, max: 63, pattern: regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*[a-z0-9]$`)},
+	ResourceLogGroup: {min: 1, max: 512, pattern: regexp.MustCompile(`^[A-Za-z0-9_./#-]+$`)},
+	ResourceKeyAlias: {min: 1, max: 256 - len(KeyAliasPrefix), pattern: regexp.MustCompile(`^[A-Za-z0-9/_-]+$`)},
+}
+
+// emptyPlaceholders match a placeholder along with one adjacent separator, dropped
+// when the placeholder has no value so names do not end up with doubled separators
+var emptyPlaceholders = map[string]*regexp.Regexp{}
+
+func init() {
+	for _, placeholder := range []string{placeholderOrg, placeholderEnv, placeholderService, placeholderRegion} {
+		quoted := regexp.QuoteMeta(placeholder)
+		emptyPlaceholders[placeholder] = regexp.MustCompile(`[-_./]` + quoted + `|` + quoted + `[-_./]?`)
+	}
+}
+
+// NamingConfig names the resources listed in namedResources from Template, such as
+// {org}-{env}-{service}-{name}, where {org} is replaced by Organization, {env} by the
+// selected environment, {service} by the module creating the resource, {region} by
+// its region and {name} by its own name. Placeholders without a value are dropped with
+// their separator. Without a template, roles get names generated by Pulumi, keys get
+// no alias and buckets must be named in the configuration. Other resources, such as
+// the state table and bucket, keep their configured or default names.
+type NamingConfig struct {
+	Template     string `json:"template"`
+	Organization string `json:"organization,omitempty"`
+}
+
+// NamedResource is a resource whose name the landing zone builds from the naming
+// template
+type NamedResource struct {
+	Kind    string
+	Service string
+	Name    string
+
+	// Regional resources have the region in their name when the template has one
+	Regional bool
+}
+
+// The resources named by the naming template
+var (
+	RoleVendingStateMachine    = NamedResource{Kind: ResourceRole, Service: "vending", Name: "state-machine"}
+	RoleGrafanaWorkspace       = NamedResource{Kind: ResourceRole, Service: "observability", Name: "grafana"}
+	RoleStackSetAdministration = NamedResource{Kind: ResourceRole, Service: "baseline", Name: "stackset-administration"}
+	RoleReportCrawler          = NamedResource{Kind: ResourceRole, Service: "billing", Name: "report-crawler", Regional: true}
+	RoleHealthForwarder        = NamedResource{Kind: ResourceRole, Service: "health", Name: "forwarder", Regional: true}
+	RoleLoggingDelivery        = NamedResource{Kind: ResourceRole, Service: "logging", Name: "delivery", Regional: true}
+	RoleLoggingSubscription    = NamedResource{Kind: ResourceRole, Service: "logging", Name: "subscription", Regional: true}
+	RoleSIEMDelivery           = NamedResource{Kind: ResourceRole, Service: "siem", Name: "delivery", Regional: true}
+	RoleSIEMTransform          = NamedResource{Kind: ResourceRole, Service: "siem", Name: "transform", Regional: true}
+	RoleSIEMEvents             = NamedResource{Kind: ResourceRole, Service: "siem", Name: "events", Regional: true}
+	RoleSIEMSubscription       = NamedResource{Kind: ResourceRole, Service: "siem", Name: "subscription", Regional: true}
+	BucketGuardDutyExport      = NamedResource{Kind: ResourceBucket, Service: "guardduty", Name: "findings", Regional: true}
+	KeyGuardDutyExport         = NamedResource{Kind: ResourceKeyAlias, Service: "guardduty", Name: "findings", Regional: true}
+	KeyParameterSharing        = NamedResource{Kind: ResourceKeyAlias, Service: "parameters"}
+)
+
+// namedResources lists the resources named by the naming template, validated
+// together so their names neither break the limits of their kind nor collide. The
+// parameter sharing keys are named after the kinds of parameters they encrypt.
+var namedResources = []NamedResource{
+	RoleVendingStateMachine,
+	RoleGrafanaWorkspace,
+	RoleStackSetAdministration,
+	RoleReportCrawler,
+	RoleHealthForwarder,
+	RoleLoggingDelivery,
+	RoleLoggingSubscription,
+	RoleSIEMDelivery,
+	RoleSIEMTransform,
+	RoleSIEMEvents,
+	RoleSIEMSubscription,
+	BucketGuardDutyExport,
+	KeyGuardDutyExport,
+	KeyParameterSharing.With(ParameterKindAccounts),
+	KeyParameterSharing.With(ParameterKindHooks),
+	KeyParameterSharing.With(ParameterKindBackups),
+}
+
+// With returns the resource named after one of several instances of it
+func (r NamedResource) With(name string) NamedResource {
+	if r.Name != "" {
+		name = fmt.Sprintf("%s-%s", r.Name, name)
+	}
+	r.Name = name
+	return r
+}
+
+// ResourceNames builds the names of the resources of the landing zone. The zero value
+// builds no names.
+type ResourceNames struct {
+	template     string
+	organization string
+	environment  string
+}
+
+// ResourceNames returns the names of the resources of the landing zone
+func (c *LandingZoneConfig) ResourceNames() ResourceNames {
+	if c == nil || c.Naming == nil {
+		return ResourceNames{}
+	}
+	return ResourceNames{
+		template:     c.Naming.Template,
+		organization: c.Naming.Organization,
+		environment:  c.environment,
+	}
+}
+
+// Configured reports whether a naming template is configured
+func (n ResourceNames) Configured() bool {
+	return n.template != ""
+}
+
+// Name returns the name of a resource in a region, or an empty name without a
+// template. Global resources ignore the region.
+func (n ResourceNames) Name(r NamedResource, region string) string {
+	if !n.Configured() {
+		return ""
+	}
+	if !r.Regional {
+		region = ""
+	}
+
+	template := n.template
+	values := map[string]string{
+		placeholderOrg:     n.organization,
+		placeholderEnv:     n.environment,
+		placeholderService: r.Service,
+		placeholderRegion:  region,
+	}
+	for placeholder, value := range values {
+		if value == "" {
+			template = emptyPlaceholders[placeholder].ReplaceAllString(template, "")
+		}
+	}
+	return strings.NewReplacer(
+		placeholderOrg, n.organization,
+		placeholderEnv, n.environment,
+		placeholderService, r.Service,
+		placeholderRegion, region,
+		placeholderName, r.Name,
+	).Replace(template)
+}
+
+// KeyAlias returns the alias of a KMS key, or an empty alias without a template
+func (n ResourceNames) KeyAlias(r NamedResource, region string) string {
+	name := n.Name(r, region)
+	if name == "" {
+		return ""
+	}
+	return KeyAliasPrefix + name
+}
+
+// checkResourceName checks a name against the limits of its kind of resource
+func checkResourceName(kind, name string) error {
+	rule := namingRules[kind]
+	if len(name) < rule.min || len(name) > rule.max {
+		return fmt.Errorf("%s name %q must be %d to %d characters long", kind, name, rule.min, rule.max)
+	}
+	if !rule.pattern.MatchString(name) {
+		return fmt.Errorf("%s name %q contains characters that are not allowed", kind, name)
+	}
+	return nil
+}
+
+// validateNamingConfig validates the names of the configured log groups, the naming
+// template and every name built from it
+func (c *OrganizationConfig) validateNamingConfig() error {
+	lz := c.LandingZoneConfig
+	for _, logGroup := range []string{lz.CloudWatchLogGroup, lz.CloudTrailLogGroup} {
+		if logGroup == "" {
+			continue
+		}
+		if err := checkResourceName(ResourceLogGroup, logGroup); err != nil {
+			return err
+		}
+	}
+
+	n := lz.Naming
+	if n == nil {
+		return nil
+	}
+	if !namingTemplateRE.MatchString(n.Template) {
+		return fmt.Errorf("invalid naming template %q", n.Template)
+	}
+	if !strings.Contains(n.Template, placeholderName) {
+		return fmt.Errorf("naming template must contain %s", placeholderName)
+	}
+	rest := strings.NewReplacer(placeholderOrg, "", placeholderEnv, "", placeholderService, "",
+		placeholderRegion, "", placeholderName, "").Replace(n.Template)
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("naming template %q has an unknown placeholder", n.Template)
+	}
+	if strings.Contains(n.Template, placeholderOrg) && n.Organization == "" {
+		return fmt.Errorf("naming template uses %s but no organization is configured", placeholderOrg)
+	}
+
+	// Names are checked in the region with the longest name, the tightest limit
+	region := lz.LogBucketRegion()
+	for _, r := range lz.GovernedRegions {
+		if len(r) > len(region) {
+			region = r
+		}
+	}
+
+	names := lz.ResourceNames()
+	seen := make(map[string]bool)
+	for _, r := range namedResources {
+		name := names.Name(r, region)
+		if err := checkResourceName(r.Kind, name); err != nil {
+			return fmt.Errorf("naming template: %w", err)
+		}
+		key := r.Kind + "/" + name
+		if seen[key] {
+			return fmt.Errorf("naming template gives several resources the %s name %q", r.Kind, name)
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Defaults of the cross-account observability
+const (
+	DefaultObservabilitySinkName = "landing-zone-observability"
+	DefaultGrafanaWorkspaceName  = "landing-zone"
+	DefaultPrometheusAlias       = "landing-zone"
+)
+
+// Authentication providers of the Grafana workspace
+const (
+	GrafanaAuthSSO  = "AWS_SSO"
+	GrafanaAuthSAML = "SAML"
+)
+
+// Telemetry shared by the source accounts with the monitoring account
+const (
+	ObservabilityMetrics      = "AWS::CloudWatch::Metric"
+	ObservabilityLogGroups    = "AWS::Logs::LogGroup"
+	ObservabilityTraces       = "AWS::XRay::Trace"
+	ObservabilityApplications = "AWS::ApplicationInsights::Application"
+	ObservabilityMonitors     = "AWS::InternetMonitor::Monitor"
+)
+
+var (
+	observabilityResourceTypes = map[string]bool{
+		ObservabilityMetrics:      true,
+		ObservabilityLogGroups:    true,
+		ObservabilityTraces:       true,
+		ObservabilityApplications: true,
+		ObservabilityMonitors:     true,
+	}
+
+	// DefaultObservabilityResourceTypes are shared when no resource type is configured
+	DefaultObservabilityResourceTypes = []string{ObservabilityMetrics, ObservabilityLogGroups, ObservabilityTraces}
+
+	sinkNameRE      = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,255}$`)
+	workspaceNameRE = regexp.MustCompile(`^[A-Za-z0-9_-]{1,255}$`)
+)
+
+// ObservabilityConfig enables CloudWatch cross-account observability. An Observability
+// Access Manager sink in the monitoring account receives the telemetry of every other
+// active account of the organization, linked to it in each of Regions, the governed
+// regions by default. ExcludedAccounts are not linked. Grafana and Prometheus add
+// Amazon Managed Grafana and Amazon Managed Service for Prometheus workspaces to the
+// monitoring account, in its first region.
+type ObservabilityConfig struct {
+	MonitoringAccountId string            `json:"monitoringAccountId"`
+	SinkName            string            `json:"sinkName,omitempty"`
+	Regions             []string          `json:"regions,omitempty"`
+	ResourceTypes       []string          `json:"resourceTypes,omitempty"`
+	ExcludedAccounts    []string          `json:"excludedAccounts,omitempty"`
+	Grafana             *GrafanaConfig    `json:"grafana,omitempty"`
+	Prometheus          *PrometheusConfig `json:"prometheus,omitempty"`
+}
+
+// GrafanaConfig defines the Managed Grafana workspace, whose users sign in through the
+// authentication providers, IAM Identity Center by default
+type GrafanaConfig struct {
+	WorkspaceName           string   `json:"workspaceName,omitempty"`
+	AuthenticationProviders []string `json:"authenticationProviders,omitempty"`
+	GrafanaVersion          string   `json:"grafanaVersion,omitempty"`
+}
+
+// PrometheusConfig defines the Managed Service for Prometheus workspace
+type PrometheusConfig struct {
+	Alias string `json:"alias,omitempty"`
+}
+
+// Name returns the name of the Grafana workspace
+func (g *GrafanaConfig) Name() string {
+	if g.WorkspaceName == "" {
+		return DefaultGrafanaWorkspaceName
+	}
+	return g.WorkspaceName
+}
+
+// Providers returns the authentication providers of the Grafana workspace
+func (g *GrafanaConfig) Providers() []string {
+	if len(g.AuthenticationProviders) == 0 {
+		return []string{GrafanaAuthSSO}
+	}
+	return g.AuthenticationProviders
+}
+
+// Name returns the alias of the Prometheus workspace
+func (p *PrometheusConfig) Name() string {
+	if p.Alias == "" {
+		return DefaultPrometheusAlias
+	}
+	return p.Alias
+}
+
+// Name returns the name of the sink
+func (o *ObservabilityConfig) Name() string {
+	if o.SinkName == "" {
+		return DefaultObservabilitySinkName
+	}
+	return o.SinkName
+}
+
+// Types returns the telemetry shared with the monitoring account
+func (o *ObservabilityConfig) Types() []string {
+	if len(o.ResourceTypes) == 0 {
+		return DefaultObservabilityResourceTypes
+	}
+	return o.ResourceTypes
+}
+
+// Excluded reports whether an account is left out of the cross-account observability
+func (o *ObservabilityConfig) Excluded(accountId string) bool {
+	for _, excluded := range o.ExcludedAccounts {
+		if excluded == accountId {
+			return true
+		}
+	}
+	return false
+}
+
+// ObservabilityRegions returns the regions the accounts are linked to the sink in
+func (c *LandingZoneConfig) ObservabilityRegions() []string {
+	if o := c.Observability; o != nil && len(o.Regions) > 0 {
+		return o.Regions
+	}
+	return c.GovernedRegions
+}
+
+// validateObservabilityConfig validates the cross-account observability
+func (c *OrganizationConfig) validateObservabilityConfig() error {
+	o := c.LandingZoneConfig.Observability
+	if o == nil {
+		return nil
+	}
+
+	if !isValidAccountId(o.MonitoringAccountId) {
+		return fmt.Errorf("invalid observability monitoring account ID %q", o.MonitoringAccountId)
+	}
+	if o.SinkName != "" && !sinkNameRE.MatchString(o.SinkName) {
+		return fmt.Errorf("invalid observability sink name %q", o.SinkName)
+	}
+
+	governed := make(map[string]bool)
+	for _, region := range c.LandingZoneConfig.GovernedRegions {
+		governed[region] = true
+	}
+	for _, region := range o.Regions {
+		if !governed[region] {
+			return fmt.Errorf("observability region %s is not a governed region", region)
+		}
+	}
+	if len(c.LandingZoneConfig.ObservabilityRegions()) == 0 {
+		return fmt.Errorf("observability requires regions or governed regions")
+	}
+
+	for _, resourceType := range o.ResourceTypes {
+		if !observabilityResourceTypes[resourceType] {
+			return fmt.Errorf("unsupported observability resource type %q, must be one of: %s",
+				resourceType, strings.Join([]string{ObservabilityMetrics, ObservabilityLogGroups, ObservabilityTraces,
+					ObservabilityApplications, ObservabilityMonitors}, ", "))
+		}
+	}
+	for _, accountId := range o.ExcludedAccounts {
+		if !isValidAccountId(accountId) {
+			return fmt.Errorf("invalid observability excluded account ID %q", accountId)
+		}
+		if accountId == o.MonitoringAccountId {
+			return fmt.Errorf("observability monitoring account %s cannot be excluded", accountId)
+		}
+	}
+
+	if g := o.Grafana; g != nil {
+		if g.WorkspaceName != "" && !workspaceNameRE.MatchString(g.WorkspaceName) {
+			return fmt.Errorf("invalid Grafana workspace name %q", g.WorkspaceName)
+		}
+		for _, provider := range g.AuthenticationProviders {
+			if provider != GrafanaAuthSSO && provider != GrafanaAuthSAML {
+				return fmt.Errorf("unsupported Grafana authentication provider %q, must be %s or %s",
+					provider, GrafanaAuthSSO, GrafanaAuthSAML)
+			}
+		}
+	}
+	if p := o.Prometheus; p != nil && len(p.Alias) > 100 {
+		return fmt.Errorf("alias of the Prometheus workspace cannot exceed 100 characters")
+	}
+	return nil
+}
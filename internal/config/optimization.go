@@ -0,0 +1,28 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import "fmt"
+
+// OptimizationConfig defines the organization-wide enrollment of the cost and
+// performance advisors. Trusted Advisor requires a Business, Enterprise On-Ramp or
+// Enterprise support plan; without one its enrollment is skipped with a warning.
+type OptimizationConfig struct {
+	ComputeOptimizer bool `json:"computeOptimizer"`
+	TrustedAdvisor   bool `json:"trustedAdvisor"`
+}
+
+// validateOptimizationConfig validates the advisor enrollment
+func (c *OrganizationConfig) validateOptimizationConfig() error {
+	o := c.LandingZoneConfig.Optimization
+	if o == nil {
+		return nil
+	}
+
+	if !o.ComputeOptimizer && !o.TrustedAdvisor {
+		return fmt.Errorf("optimization requires Compute Optimizer or Trusted Advisor")
+	}
+	return nil
+}
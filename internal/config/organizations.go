@@ -0,0 +1,205 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AWS partitions an organization can live in
+const (
+	PartitionAWS      = "aws"
+	PartitionGovCloud = "aws-us-gov"
+	PartitionChina    = "aws-cn"
+)
+
+// OrganizationTarget is one organization managed from a multi-organization configuration
+type OrganizationTarget struct {
+	AWSProfile        string             `json:"awsProfile,omitempty"`
+	Partition         string             `json:"partition,omitempty"`
+	Region            string             `json:"region,omitempty"`
+	LandingZoneConfig *LandingZoneConfig `json:"LandingZoneConfig"`
+}
+
+// PartitionOf returns the partition a region belongs to
+func PartitionOf(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return PartitionGovCloud
+	case strings.HasPrefix(region, "cn-"):
+		return PartitionChina
+	default:
+		return PartitionAWS
+	}
+}
+
+// OrganizationNames returns the sorted names of the configured organizations
+func (c *OrganizationConfig) OrganizationNames() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	names := make([]string, 0, len(c.Organizations))
+	for name := range c.Organizations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select makes the named organization the current configuration. An empty name selects
+// the only organization when exactly one is configured, and is a no-op for single
+// organization configurations.
+func (c *OrganizationConfig) Select(name string) error {
+	names := c.OrganizationNames()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(names) == 0 {
+		if name != "" {
+			return fmt.Errorf("organization %q is not configured, the configuration manages a single organization", name)
+		}
+		return nil
+	}
+
+	if name == "" {
+		if len(names) > 1 {
+			return fmt.Errorf("an organization must be selected, one of: %s", strings.Join(names, ", "))
+		}
+		name = names[0]
+	}
+
+	target, ok := c.Organizations[name]
+	if !ok {
+		return fmt.Errorf("unknown organization %q, must be one of: %s", name, strings.Join(names, ", "))
+	}
+	if target.LandingZoneConfig == nil {
+		return fmt.Errorf("organization %q has no landing zone configuration", name)
+	}
+
+	c.LandingZoneConfig = target.LandingZoneConfig
+	if target.AWSProfile != "" {
+		c.AWSProfile = target.AWSProfile
+	}
+	c.Region = target.Region
+	c.Partition = target.Partition
+	c.Selected = name
+	return nil
+}
+
+// validateOrganizations validates every configured organization, prefixing the
+// sections of its errors with the organization name
+func (c *OrganizationConfig) validateOrganizations() []*ValidationError {
+	names := make([]string, 0, len(c.Organizations))
+	for name := range c.Organizations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []*ValidationError
+	for _, name := range names {
+		target := c.Organizations[name]
+		section := "organization " + name
+		if target == nil || target.LandingZoneConfig == nil {
+			errs = append(errs, &ValidationError{Section: section, Err: fmt.Errorf("landing zone configuration is required")})
+			continue
+		}
+
+		org := &OrganizationConfig{
+			Version:           c.Version,
+			AWSProfile:        target.AWSProfile,
+			Partition:         target.Partition,
+			Region:            target.Region,
+			LandingZoneConfig: target.LandingZoneConfig,
+			logger:            c.logger,
+			metrics:           c.metrics,
+		}
+		for _, err := range org.validateSections() {
+			errs = append(errs, &ValidationError{Section: section + " " + err.Section, Err: err.Err})
+		}
+	}
+	return errs
+}
+
+// partitionServices lists the features of the landing zone that are not available in
+// every partition
+var partitionServices = []struct {
+	name        string
+	unavailable []string
+	enabled     func(*LandingZoneConfig) bool
+}{
+	{"Control Tower", []string{PartitionChina}, func(lz *LandingZoneConfig) bool { return len(lz.EnabledGuardrails) > 0 }},
+	{"Macie", []string{PartitionChina}, func(lz *LandingZoneConfig) bool { return lz.EnableMacie }},
+	{"Detective", []string{PartitionChina}, func(lz *LandingZoneConfig) bool { return lz.EnableDetective }},
+	{"Cost Categories", []string{PartitionGovCloud}, func(lz *LandingZoneConfig) bool {
+		return lz.Billing != nil && len(lz.Billing.CostCategories) > 0
+	}},
+	{"Cost and Usage Report", []string{PartitionGovCloud}, func(lz *LandingZoneConfig) bool {
+		return lz.Billing != nil && lz.Billing.CostAndUsageReport != nil
+	}},
+}
+
+// PartitionName returns the partition of the organization: the configured one, or the
+// partition of its region, home region or first governed region. It is empty when the
+// configuration names none of them.
+func (c *OrganizationConfig) PartitionName() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.partition()
+}
+
+// partition implements PartitionName without locking
+func (c *OrganizationConfig) partition() string {
+	switch {
+	case c.Partition != "":
+		return c.Partition
+	case c.Region != "":
+		return PartitionOf(c.Region)
+	case c.LandingZoneConfig == nil:
+		return ""
+	case c.LandingZoneConfig.HomeRegion != "":
+		return PartitionOf(c.LandingZoneConfig.HomeRegion)
+	case len(c.LandingZoneConfig.GovernedRegions) > 0:
+		return PartitionOf(c.LandingZoneConfig.GovernedRegions[0])
+	}
+	return ""
+}
+
+// validatePartitionConfig validates the partition, that every region of the
+// configuration belongs to it and that the enabled services are available in it
+func (c *OrganizationConfig) validatePartitionConfig() error {
+	switch c.Partition {
+	case "", PartitionAWS, PartitionGovCloud, PartitionChina:
+	default:
+		return fmt.Errorf("unsupported partition %q, must be one of: %s, %s, %s",
+			c.Partition, PartitionAWS, PartitionGovCloud, PartitionChina)
+	}
+
+	partition := c.partition()
+	if partition == "" {
+		return nil
+	}
+	lz := c.LandingZoneConfig
+	regions := append([]string{c.Region, lz.HomeRegion, lz.CloudTrailBucketRegion}, lz.GovernedRegions...)
+	for _, region := range regions {
+		if region != "" && PartitionOf(region) != partition {
+			return fmt.Errorf("region %s is not in partition %s", region, partition)
+		}
+	}
+
+	for _, service := range partitionServices {
+		if !service.enabled(lz) {
+			continue
+		}
+		for _, unavailable := range service.unavailable {
+			if unavailable == partition {
+				return fmt.Errorf("%s is not available in partition %s", service.name, partition)
+			}
+		}
+	}
+	return nil
+}
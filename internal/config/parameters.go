@@ -0,0 +1,123 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kinds of the SSM parameters created by the landing zone
+const (
+	ParameterKindAccounts = "accounts"
+	ParameterKindBackups  = "backups"
+	ParameterKindHooks    = "hooks"
+	ParameterKindManifest = "manifest"
+	ParameterKindSecrets  = "secrets"
+	ParameterKindInfo     = "info"
+	ParameterKindOU       = "ou"
+)
+
+// Defaults of the parameter names
+const (
+	DefaultParameterNamespace = "/organization"
+	DefaultParameterTemplate  = "{namespace}/{kind}/{name}"
+
+	// Placeholders of the parameter name template
+	placeholderNamespace = "{namespace}"
+	placeholderKind      = "{kind}"
+	placeholderName      = "{name}"
+)
+
+var (
+	parameterNamespaceRE = regexp.MustCompile(`^(/[A-Za-z0-9_.-]+)+$`)
+	parameterTemplateRE  = regexp.MustCompile(`^[A-Za-z0-9_./{}-]+$`)
+)
+
+// ParametersConfig defines the names of the SSM parameters of the landing zone, so
+// several instances can share a management account. Every name is built from
+// Template, where {namespace} is replaced by Namespace, {kind} by the kind of
+// parameter, such as accounts or hooks, and {name} by the name of the parameter.
+// The template starts with {namespace} and ends with /{name}, so the parameters of
+// a kind stay under a common path.
+type ParametersConfig struct {
+	Namespace string `json:"namespace,omitempty"`
+	Template  string `json:"template,omitempty"`
+}
+
+// ParameterNames builds the names of the SSM parameters. The zero value builds the
+// default names.
+type ParameterNames struct {
+	namespace string
+	template  string
+}
+
+// ParameterNames returns the names of the SSM parameters of the landing zone
+func (c *LandingZoneConfig) ParameterNames() ParameterNames {
+	if c == nil || c.Parameters == nil {
+		return ParameterNames{}
+	}
+	return ParameterNames{namespace: c.Parameters.Namespace, template: c.Parameters.Template}
+}
+
+// Namespace returns the path every parameter is under
+func (n ParameterNames) Namespace() string {
+	if n.namespace == "" {
+		return DefaultParameterNamespace
+	}
+	return n.namespace
+}
+
+// Name returns the name of a parameter of a kind. Without a name it returns the path
+// of the kind, which names the parameters a kind has only one of, such as the
+// manifest.
+func (n ParameterNames) Name(kind, name string) string {
+	template := n.template
+	if template == "" {
+		template = DefaultParameterTemplate
+	}
+	if name == "" {
+		template = strings.TrimSuffix(template, "/"+placeholderName)
+	}
+	return strings.NewReplacer(
+		placeholderNamespace, n.Namespace(),
+		placeholderKind, kind,
+		placeholderName, name,
+	).Replace(template)
+}
+
+// Path returns the path the parameters of a kind are under
+func (n ParameterNames) Path(kind string) string {
+	return n.Name(kind, "")
+}
+
+// validateParametersConfig validates the names of the SSM parameters
+func (c *OrganizationConfig) validateParametersConfig() error {
+	p := c.LandingZoneConfig.Parameters
+	if p == nil {
+		return nil
+	}
+
+	if p.Namespace != "" && !parameterNamespaceRE.MatchString(p.Namespace) {
+		return fmt.Errorf("invalid parameter namespace %q, must start with / and not end with /", p.Namespace)
+	}
+	if t := p.Template; t != "" {
+		if !parameterTemplateRE.MatchString(t) {
+			return fmt.Errorf("invalid parameter template %q", t)
+		}
+		if !strings.HasPrefix(t, placeholderNamespace) || !strings.HasSuffix(t, "/"+placeholderName) {
+			return fmt.Errorf("parameter template must start with %s and end with /%s", placeholderNamespace, placeholderName)
+		}
+		if !strings.Contains(t, placeholderKind) {
+			return fmt.Errorf("parameter template must contain %s", placeholderKind)
+		}
+		rest := strings.NewReplacer(placeholderNamespace, "", placeholderKind, "", placeholderName, "").Replace(t)
+		if strings.ContainsAny(rest, "{}") {
+			return fmt.Errorf("parameter template %q has an unknown placeholder", t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+const (
+	// Organization policy types managed by the landing zone
+	PolicyTypeSCP = "SERVICE_CONTROL_POLICY"
+	PolicyTypeTag = "TAG_POLICY"
+
+	// PolicyTargetRoot attaches a policy to the root of the organization
+	PolicyTargetRoot = "Root"
+
+	// Document size limits of the Organizations API, in characters
+	MaxSCPSize       = 5120
+	MaxTagPolicySize = 10000
+)
+
+// PolicyConfig defines an organization policy attached to the root, OUs or accounts.
+// The document is either a template of the built-in library or inline content. Targets
+// are Root, keys or names of OUs, or account IDs.
+type PolicyConfig struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Template    string   `json:"template,omitempty"`
+	Content     string   `json:"content,omitempty"`
+	Targets     []string `json:"targets"`
+
+	// ImportID is the ID of the existing policy, set by config import, the policy and
+	// its attachments are imported into the stack with instead of being created
+	ImportID string `json:"importId,omitempty"`
+}
+
+// PolicyTemplate is a policy document of the built-in library
+type PolicyTemplate struct {
+	Type        string
+	Description string
+	Document    string
+}
+
+// PolicyTemplates is the built-in library of guardrail policies referenced by name
+var PolicyTemplates = map[string]PolicyTemplate{
+	"deny-leave-organization": {
+		Type:        PolicyTypeSCP,
+		Description: "Prevents member accounts from leaving the organization",
+		Document: `{"Version":"2012-10-17","Statement":[{"Sid":"DenyLeaveOrganization","Effect":"Deny",` +
+			`"Action":"organizations:LeaveOrganization","Resource":"*"}]}`,
+	},
+	"deny-root-user": {
+		Type:        PolicyTypeSCP,
+		Description: "Denies every action to the root user of member accounts",
+		Document: `{"Version":"2012-10-17","Statement":[{"Sid":"DenyRootUser","Effect":"Deny","Action":"*",` +
+			`"Resource":"*","Condition":{"StringLike":{"aws:PrincipalArn":"arn:*:iam::*:root"}}}]}`,
+	},
+	"protect-cloudtrail": {
+		Type:        PolicyTypeSCP,
+		Description: "Prevents trails from being stopped, deleted or changed",
+		Document: `{"Version":"2012-10-17","Statement":[{"Sid":"ProtectCloudTrail","Effect":"Deny",` +
+			`"Action":["cloudtrail:StopLogging","cloudtrail:DeleteTrail","cloudtrail:UpdateTrail",` +
+			`"cloudtrail:PutEventSelectors"],"Resource":"*"}]}`,
+	},
+	"protect-guardduty": {
+		Type:        PolicyTypeSCP,
+		Description: "Prevents member accounts from disabling GuardDuty or leaving its administrator",
+		Document: `{"Version":"2012-10-17","Statement":[{"Sid":"ProtectGuardDuty","Effect":"Deny",` +
+			`"Action":["guardduty:DeleteDetector","guardduty:DisassociateFromAdministratorAccount",` +
+			`"guardduty:DisassociateFromMasterAccount","guardduty:UpdateDetector"],"Resource":"*"}]}`,
+	},
+	"protect-s3-public-access-block": {
+		Type:        PolicyTypeSCP,
+		Description: "Prevents the account-level S3 public access block from being changed",
+		Document: `{"Version":"2012-10-17","Statement":[{"Sid":"ProtectS3PublicAccessBlock","Effect":"Deny",` +
+			`"Action":"s3:PutAccountPublicAccessBlock","Resource":"*"}]}`,
+	},
+}
+
+// PolicyTemplateNames returns the names of the built-in policy templates
+func PolicyTemplateNames() []string {
+	names := make([]string, 0, len(PolicyTemplates))
+	for name := range PolicyTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Document returns the content of the policy, from its template or inline
+func (p *PolicyConfig) Document() string {
+	if p.Template != "" {
+		return PolicyTemplates[p.Template].Document
+	}
+	return p.Content
+}
+
+// MatchPolicyTemplate returns the name of the template of the given type whose document
+// is equivalent to the content, ignoring formatting and key order, or an empty string
+func MatchPolicyTemplate(policyType, content string) string {
+	normalized, err := normalizePolicy(content)
+	if err != nil {
+		return ""
+	}
+	for _, name := range PolicyTemplateNames() {
+		template := PolicyTemplates[name]
+		if template.Type != policyType {
+			continue
+		}
+		if document, err := normalizePolicy(template.Document); err == nil && bytes.Equal(document, normalized) {
+			return name
+		}
+	}
+	return ""
+}
+
+// normalizePolicy re-encodes a policy document with sorted keys and no whitespace
+func normalizePolicy(content string) ([]byte, error) {
+	var document interface{}
+	if err := json.Unmarshal([]byte(content), &document); err != nil {
+		return nil, err
+	}
+	return json.Marshal(document)
+}
+
+// validatePolicyConfig validates the organization policies
+func (c *OrganizationConfig) validatePolicyConfig() error {
+	lz := c.LandingZoneConfig
+	names := make(map[string]bool)
+	for _, policy := range lz.Policies {
+		if policy.Name == "" {
+			return fmt.Errorf("organization policies require a name")
+		}
+		if names[policy.Name] {
+			return fmt.Errorf("organization policy %s is configured more than once", policy.Name)
+		}
+		names[policy.Name] = true
+
+		limit := MaxSCPSize
+		switch policy.Type {
+		case PolicyTypeSCP:
+		case PolicyTypeTag:
+			limit = MaxTagPolicySize
+		default:
+			return fmt.Errorf("invalid type %q of policy %s, must be %s or %s", policy.Type, policy.Name, PolicyTypeSCP, PolicyTypeTag)
+		}
+
+		if (policy.Template == "") == (policy.Content == "") {
+			return fmt.Errorf("policy %s requires either a template or content", policy.Name)
+		}
+		if policy.Template != "" {
+			template, ok := PolicyTemplates[policy.Template]
+			if !ok {
+				return fmt.Errorf("unknown template %q of policy %s, available templates: %v", policy.Template, policy.Name, PolicyTemplateNames())
+			}
+			if template.Type != policy.Type {
+				return fmt.Errorf("template %s of policy %s is a %s", policy.Template, policy.Name, template.Type)
+			}
+		}
+		if !json.Valid([]byte(policy.Document())) {
+			return fmt.Errorf("content of policy %s is not valid JSON", policy.Name)
+		}
+		if len(policy.Document()) > limit {
+			return fmt.Errorf("policy %s exceeds the %d characters allowed for its type", policy.Name, limit)
+		}
+
+		if len(policy.Targets) == 0 {
+			return fmt.Errorf("policy %s requires at least one target", policy.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// Landing zone presets
+	PresetSmallBusiness = "small-business"
+	PresetEnterprise    = "enterprise"
+	PresetRegulated     = "regulated"
+)
+
+// presets builds the landing zone configuration of every preset
+var presets = map[string]func() *LandingZoneConfig{
+	PresetSmallBusiness: smallBusinessPreset,
+	PresetEnterprise:    enterprisePreset,
+	PresetRegulated:     regulatedPreset,
+}
+
+// Presets returns the names of the available presets
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PresetConfig returns the landing zone configuration a preset expands into. The
+// configuration file is applied on top of it.
+func PresetConfig(name string) (*LandingZoneConfig, error) {
+	preset, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q, available presets: %v", name, Presets())
+	}
+
+	lz := preset()
+	lz.Preset = name
+	return lz, nil
+}
+
+// baseControls are the controls enabled by every preset
+var baseControls = []string{
+	"AWS-GR_RESTRICT_ROOT_USER",
+	"AWS-GR_RESTRICT_ROOT_USER_ACCESS_KEYS",
+	"AWS-GR_ROOT_ACCOUNT_MFA_ENABLED",
+	"AWS-GR_ENCRYPTED_VOLUMES",
+	"AWS-GR_S3_BUCKET_PUBLIC_READ_PROHIBITED",
+	"AWS-GR_S3_BUCKET_PUBLIC_WRITE_PROHIBITED",
+}
+
+// presetBase returns the defaults shared by the presets
+func presetBase() *LandingZoneConfig {
+	return &LandingZoneConfig{
+		GovernedRegions:   []string{"us-east-1"},
+		DefaultOUName:     "Sandbox",
+		OrganizationUnits: map[string]*OUConfig{},
+		LogRetentionDays:  365,
+		Tags: map[string]string{
+			"ManagedBy": "Pulumi",
+			"Project":   "ControlTower",
+		},
+		EnabledGuardrails: append([]string{}, baseControls...),
+		RequireMFA:        true,
+		EnableSSLRequests: true,
+		EnableSecurityHub: true,
+		EnableGuardDuty:   true,
+		EnableConfig:      true,
+		EnableCloudTrail:  true,
+		VPCSettings: &VPCConfig{
+			CIDR:               "10.0.0.0/16",
+			EnableTransitGW:    true,
+			EnableVPCFlowLogs:  true,
+			EnableDNSHostnames: true,
+			EnableDNSSupport:   true,
+		},
+		Baseline: &BaselineConfig{
+			PasswordPolicy: &PasswordPolicyConfig{
+				MinimumPasswordLength:      14,
+				RequireSymbols:             true,
+				RequireNumbers:             true,
+				RequireUppercaseCharacters: true,
+				RequireLowercaseCharacters: true,
+				AllowUsersToChangePassword: true,
+				PasswordReusePrevention:    24,
+			},
+			EBSEncryptionByDefault: true,
+			S3BlockPublicAccess:    true,
+		},
+	}
+}
+
+// ou returns an organizational unit of a preset
+func ou(name, description string) *OUConfig {
+	return &OUConfig{Name: name, Description: description}
+}
+
+// smallBusinessPreset is a single-region landing zone with a minimal OU structure
+func smallBusinessPreset() *LandingZoneConfig {
+	lz := presetBase()
+	lz.OrganizationUnits = map[string]*OUConfig{
+		"Security":  ou("Security", "Log archive and audit accounts"),
+		"Workloads": ou("Workloads", "Production and development workloads"),
+		"Sandbox":   ou("Sandbox", "Experimentation accounts"),
+	}
+	return lz
+}
+
+// enterprisePreset follows the recommended multi-account OU structure, with workloads
+// split by environment and delegated security services
+func enterprisePreset() *LandingZoneConfig {
+	lz := presetBase()
+	lz.GovernedRegions = []string{"us-east-1", "us-west-2", "eu-west-1"}
+	lz.OrganizationUnits = map[string]*OUConfig{
+		"Security":       ou("Security", "Log archive, audit and security tooling accounts"),
+		"Infrastructure": ou("Infrastructure", "Shared networking and services"),
+		"Workloads-Prod": ou("Workloads-Prod", "Production workloads"),
+		"Workloads-Dev":  ou("Workloads-Dev", "Development and test workloads"),
+		"Sandbox":        ou("Sandbox", "Experimentation accounts"),
+		"PolicyStaging":  ou("PolicyStaging", "Accounts testing policy changes"),
+		"Suspended":      ou("Suspended", "Closed and quarantined accounts"),
+	}
+	lz.EnabledGuardrails = append(lz.EnabledGuardrails,
+		"AWS-GR_MFA_ENABLED_FOR_IAM_CONSOLE_ACCESS",
+		"AWS-GR_RESTRICTED_SSH",
+		"AWS-GR_RESTRICTED_COMMON_PORTS",
+		"AWS-GR_RDS_INSTANCE_PUBLIC_ACCESS_CHECK",
+		"AWS-GR_RDS_SNAPSHOTS_PUBLIC_PROHIBITED",
+	)
+	lz.EnableDetective = true
+	lz.EnableInspector = true
+	lz.InspectorScanTypes = []string{"EC2", "ECR", "LAMBDA"}
+	return lz
+}
+
+// regulatedPreset extends the enterprise preset for regulated workloads: seven-year
+// immutable log retention and sensitive data discovery. LogBucketName must be set.
+func regulatedPreset() *LandingZoneConfig {
+	lz := enterprisePreset()
+	lz.LogRetentionDays = 2555
+	lz.EnabledGuardrails = append(lz.EnabledGuardrails,
+		"AWS-GR_RDS_STORAGE_ENCRYPTED",
+		"AWS-GR_IAM_USER_MFA_ENABLED",
+	)
+	lz.LogArchive = &LogArchiveConfig{
+		ObjectLockEnabled:         true,
+		ObjectLockMode:            "COMPLIANCE",
+		ObjectLockRetentionDays:   2555,
+		DeepArchiveTransitionDays: 365,
+	}
+	lz.EnableMacie = true
+	lz.Macie = &MacieConfig{
+		Schedule:             "WEEKLY",
+		WeeklyDay:            "MONDAY",
+		PublishToSecurityHub: true,
+	}
+	lz.GuardDuty = &GuardDutyConfig{
+		FindingPublishingFrequency: "FIFTEEN_MINUTES",
+	}
+	lz.Baseline.PasswordPolicy.MaxPasswordAge = 90
+	return lz
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// ProgressStreamConfig defines the server of the serve command the deploy command
+// publishes the progress of its updates to, so portals can follow a deployment live.
+// When the environment variable named by TokenEnv holds a token, the server requires
+// it from publishers and subscribers alike.
+type ProgressStreamConfig struct {
+	URL      string `json:"url,omitempty"`
+	TokenEnv string `json:"tokenEnv,omitempty"`
+}
+
+// Token returns the token of the progress stream, empty when none is configured
+func (p *ProgressStreamConfig) Token() string {
+	if p == nil || p.TokenEnv == "" {
+		return ""
+	}
+	return os.Getenv(p.TokenEnv)
+}
+
+// validateProgressStreamConfig validates the URL and token variable of the progress
+// stream
+func (c *OrganizationConfig) validateProgressStreamConfig() error {
+	p := c.LandingZoneConfig.ProgressStream
+	if p == nil {
+		return nil
+	}
+
+	if p.URL != "" {
+		u, err := url.Parse(p.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("progress stream requires an http or https URL")
+		}
+	}
+	if p.TokenEnv != "" && !envNameRE.MatchString(p.TokenEnv) {
+		return fmt.Errorf("progress stream has an invalid token environment variable %q", p.TokenEnv)
+	}
+	return nil
+}
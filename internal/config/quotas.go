@@ -0,0 +1,130 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	serviceCodeRE = regexp.MustCompile(`^[A-Za-z0-9-]{1,63}$`)
+	quotaCodeRE   = regexp.MustCompile(`^L-[0-9A-F]{8}$`)
+)
+
+// ServiceQuotaTarget is the minimum value of a service quota in the accounts it
+// targets: Accounts, the accounts of OUs, or every account when both are empty. The
+// increase is requested through Service Quotas in each of Regions, the governed regions
+// by default, where the applied value of the quota is lower. Global quotas are
+// requested in the first region only.
+type ServiceQuotaTarget struct {
+	ServiceCode string   `json:"serviceCode"`
+	QuotaCode   string   `json:"quotaCode"`
+	Value       float64  `json:"value"`
+	Regions     []string `json:"regions,omitempty"`
+	Accounts    []string `json:"accounts,omitempty"`
+	OUs         []string `json:"ous,omitempty"`
+
+	// Description of the quota in the reports, e.g. "VPCs per region"
+	Description string `json:"description,omitempty"`
+}
+
+// Key identifies the quota of a target
+func (t ServiceQuotaTarget) Key() string {
+	return t.ServiceCode + "/" + t.QuotaCode
+}
+
+// ServiceQuotaTargets returns the service quota targets of an account of an OU, given
+// by its configured key or name
+func (c *LandingZoneConfig) ServiceQuotaTargets(accountId, ou string) []ServiceQuotaTarget {
+	if c.Baseline == nil {
+		return nil
+	}
+
+	var targets []ServiceQuotaTarget
+	for _, target := range c.Baseline.ServiceQuotas {
+		if c.targetsAccount(target, accountId, ou) {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// ServiceQuotaRegions returns the regions the increases of a target are requested in
+func (c *LandingZoneConfig) ServiceQuotaRegions(target ServiceQuotaTarget) []string {
+	if len(target.Regions) > 0 {
+		return target.Regions
+	}
+	return c.GovernedRegions
+}
+
+// targetsAccount reports whether a target applies to an account of an OU
+func (c *LandingZoneConfig) targetsAccount(target ServiceQuotaTarget, accountId, ou string) bool {
+	if len(target.Accounts) == 0 && len(target.OUs) == 0 {
+		return true
+	}
+	for _, id := range target.Accounts {
+		if id == accountId {
+			return true
+		}
+	}
+	for _, key := range target.OUs {
+		if key == ou {
+			return true
+		}
+		if configured := c.OrganizationUnits[key]; configured != nil && configured.Name == ou {
+			return true
+		}
+	}
+	return false
+}
+
+// validateServiceQuotas validates the service quota targets of the baseline
+func (c *OrganizationConfig) validateServiceQuotas() error {
+	lz := c.LandingZoneConfig
+	governed := make(map[string]bool)
+	for _, region := range lz.GovernedRegions {
+		governed[region] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, target := range lz.Baseline.ServiceQuotas {
+		if !serviceCodeRE.MatchString(target.ServiceCode) {
+			return fmt.Errorf("invalid service code %q of service quota target", target.ServiceCode)
+		}
+		if !quotaCodeRE.MatchString(target.QuotaCode) {
+			return fmt.Errorf("invalid quota code %q of service quota target %s", target.QuotaCode, target.ServiceCode)
+		}
+		if target.Value <= 0 {
+			return fmt.Errorf("service quota target %s must have a positive value", target.Key())
+		}
+		if len(lz.ServiceQuotaRegions(target)) == 0 {
+			return fmt.Errorf("service quota target %s requires regions or governed regions", target.Key())
+		}
+		for _, region := range target.Regions {
+			if !governed[region] {
+				return fmt.Errorf("region %s of service quota target %s is not a governed region", region, target.Key())
+			}
+		}
+		for _, id := range target.Accounts {
+			if !isValidAccountId(id) {
+				return fmt.Errorf("invalid account ID %s in service quota target %s", id, target.Key())
+			}
+		}
+		for _, ou := range target.OUs {
+			if _, ok := lz.OrganizationUnits[ou]; !ok {
+				return fmt.Errorf("OU %q of service quota target %s is not configured", ou, target.Key())
+			}
+		}
+
+		// Overlapping targets of a quota would request competing values
+		scope := fmt.Sprintf("%s %v %v", target.Key(), target.Accounts, target.OUs)
+		if seen[scope] {
+			return fmt.Errorf("duplicate service quota target %s", target.Key())
+		}
+		seen[scope] = true
+	}
+	return nil
+}
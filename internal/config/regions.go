@@ -0,0 +1,93 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// optInRegions are the commercial regions disabled by default in new accounts
+var optInRegions = map[string]bool{
+	"af-south-1":     true,
+	"ap-east-1":      true,
+	"ap-south-2":     true,
+	"ap-southeast-3": true,
+	"ap-southeast-4": true,
+	"ap-southeast-5": true,
+	"ca-west-1":      true,
+	"eu-central-2":   true,
+	"eu-south-1":     true,
+	"eu-south-2":     true,
+	"il-central-1":   true,
+	"me-central-1":   true,
+	"me-south-1":     true,
+}
+
+// IsOptInRegion reports whether a region must be enabled before it can be used
+func IsOptInRegion(region string) bool {
+	return optInRegions[region]
+}
+
+// EnabledOptInRegions returns the opt-in regions enabled in every account: the
+// configured ones and the governed opt-in regions. It is empty when opt-in regions
+// are not managed.
+func (c *LandingZoneConfig) EnabledOptInRegions() []string {
+	if c.Baseline == nil || c.Baseline.OptInRegions == nil {
+		return nil
+	}
+
+	enabled := make(map[string]bool)
+	for _, region := range c.Baseline.OptInRegions.Enabled {
+		enabled[region] = true
+	}
+	for _, region := range c.GovernedRegions {
+		if IsOptInRegion(region) {
+			enabled[region] = true
+		}
+	}
+
+	regions := make([]string, 0, len(enabled))
+	for region := range enabled {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// validateOptInRegions validates that the managed opt-in regions are opt-in regions and
+// that no governed region is disabled
+func (c *OrganizationConfig) validateOptInRegions() error {
+	o := c.LandingZoneConfig.Baseline.OptInRegions
+	if o == nil {
+		return nil
+	}
+
+	enabled := make(map[string]bool)
+	for _, region := range o.Enabled {
+		if !IsOptInRegion(region) {
+			return fmt.Errorf("region %s is not an opt-in region", region)
+		}
+		enabled[region] = true
+	}
+
+	governed := make(map[string]bool)
+	for _, region := range c.LandingZoneConfig.GovernedRegions {
+		governed[region] = true
+	}
+
+	for _, region := range o.Disabled {
+		switch {
+		case !IsOptInRegion(region):
+			return fmt.Errorf("region %s is not an opt-in region and cannot be disabled", region)
+		case enabled[region]:
+			return fmt.Errorf("region %s is both enabled and disabled", region)
+		case governed[region]:
+			return fmt.Errorf("governed region %s cannot be disabled", region)
+		}
+	}
+
+	return nil
+}
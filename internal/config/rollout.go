@@ -0,0 +1,122 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Defaults of the staged rollout
+const (
+	DefaultRolloutBakeMinutes = 30
+	MaxRolloutBakeMinutes     = 1440
+)
+
+// RolloutConfig defines the staged rollout of the policies and account baselines: the
+// changes of a deployment reach the OUs and accounts of the waves one wave at a time,
+// each wave baking before the next starts, and the rest of the organization after the
+// last wave. The rollout halts when one of Alarms is in alarm or when the drift of the
+// baseline StackSets grows by more than MaxDriftIncrease during a bake.
+type RolloutConfig struct {
+	Waves []RolloutWaveConfig `json:"waves"`
+
+	// Bake time of the waves without their own
+	BakeMinutes int `json:"bakeMinutes,omitempty"`
+
+	// CloudWatch alarms of the management account, in the home region
+	Alarms []string `json:"alarms,omitempty"`
+
+	// Drifted stack instances a bake may add, drift is not watched when unset
+	MaxDriftIncrease *int `json:"maxDriftIncrease,omitempty"`
+}
+
+// RolloutWaveConfig defines a wave of the staged rollout
+type RolloutWaveConfig struct {
+	Name string `json:"name"`
+
+	// OU names or account IDs the wave reaches, in addition to those of the waves
+	// before it
+	Targets []string `json:"targets"`
+
+	BakeMinutes int `json:"bakeMinutes,omitempty"`
+}
+
+// Bake returns the time a wave is watched before the next one starts
+func (r *RolloutConfig) Bake(wave RolloutWaveConfig) time.Duration {
+	switch {
+	case wave.BakeMinutes > 0:
+		return time.Duration(wave.BakeMinutes) * time.Minute
+	case r.BakeMinutes > 0:
+		return time.Duration(r.BakeMinutes) * time.Minute
+	default:
+		return DefaultRolloutBakeMinutes * time.Minute
+	}
+}
+
+// TotalBake returns the time the waves of a rollout bake
+func (r *RolloutConfig) TotalBake() time.Duration {
+	if r == nil {
+		return 0
+	}
+	var total time.Duration
+	for _, wave := range r.Waves {
+		total += r.Bake(wave)
+	}
+	return total
+}
+
+// validateRolloutConfig validates the waves, bake times and halting conditions of the
+// staged rollout
+func (c *OrganizationConfig) validateRolloutConfig() error {
+	rollout := c.LandingZoneConfig.Rollout
+	if rollout == nil {
+		return nil
+	}
+
+	if len(rollout.Waves) == 0 {
+		return fmt.Errorf("staged rollout requires at least one wave")
+	}
+	if rollout.BakeMinutes < 0 || rollout.BakeMinutes > MaxRolloutBakeMinutes {
+		return fmt.Errorf("rollout bake time must be between 0 and %d minutes", MaxRolloutBakeMinutes)
+	}
+	if rollout.MaxDriftIncrease != nil && *rollout.MaxDriftIncrease < 0 {
+		return fmt.Errorf("rollout maxDriftIncrease must not be negative")
+	}
+	for _, alarm := range rollout.Alarms {
+		if alarm == "" {
+			return fmt.Errorf("rollout alarm names must not be empty")
+		}
+	}
+
+	names := make(map[string]bool)
+	targets := make(map[string]string)
+	for i, wave := range rollout.Waves {
+		if wave.Name == "" {
+			return fmt.Errorf("rollout wave %d requires a name", i+1)
+		}
+		if names[wave.Name] {
+			return fmt.Errorf("rollout wave %s is defined twice", wave.Name)
+		}
+		names[wave.Name] = true
+
+		if wave.BakeMinutes < 0 || wave.BakeMinutes > MaxRolloutBakeMinutes {
+			return fmt.Errorf("bake time of rollout wave %s must be between 0 and %d minutes", wave.Name, MaxRolloutBakeMinutes)
+		}
+		if len(wave.Targets) == 0 {
+			return fmt.Errorf("rollout wave %s requires at least one target", wave.Name)
+		}
+		for _, target := range wave.Targets {
+			if target == PolicyTargetRoot {
+				return fmt.Errorf("rollout wave %s cannot target the root, the organization is reached after the last wave", wave.Name)
+			}
+			if previous, ok := targets[target]; ok {
+				return fmt.Errorf("target %s is in rollout waves %s and %s", target, previous, wave.Name)
+			}
+			targets[target] = wave.Name
+		}
+	}
+	return nil
+}
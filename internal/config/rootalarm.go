@@ -0,0 +1,96 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultRootActivityAlarmName names the EventBridge rules and the SNS topic of the
+// root activity alarm
+const DefaultRootActivityAlarmName = "landing-zone-root-activity"
+
+var (
+	alarmNameRE  = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	alarmEmailRE = regexp.MustCompile(EmailRegexPattern)
+)
+
+// RootActivityAlarmConfig defines the alarm raised on any API call or console sign-in
+// of the root user of an account. An EventBridge rule in each baseline region of the
+// account forwards the CloudTrail events of the root user to the default event bus of
+// the management account in the alarm region, where a single SNS topic notifies
+// Emails. The topic is encrypted with KmsKeyId when set, a key whose policy lets
+// EventBridge use it.
+type RootActivityAlarmConfig struct {
+	Name     string   `json:"name,omitempty"`
+	Emails   []string `json:"emails"`
+	KmsKeyId string   `json:"kmsKeyId,omitempty"`
+}
+
+// AlarmName returns the name of the rule and topic of the alarm
+func (a *RootActivityAlarmConfig) AlarmName() string {
+	if a.Name == "" {
+		return DefaultRootActivityAlarmName
+	}
+	return a.Name
+}
+
+// RootActivityAlarmRegion returns the region of the topic of the root activity alarm:
+// the home region or the first governed region
+func (c *LandingZoneConfig) RootActivityAlarmRegion() string {
+	if c.HomeRegion != "" {
+		return c.HomeRegion
+	}
+	if len(c.GovernedRegions) > 0 {
+		return c.GovernedRegions[0]
+	}
+	return ""
+}
+
+// BaselineRegions returns the regions the resource baseline of an account is applied
+// in: those of its override, the baseline regions or the governed regions
+func (c *LandingZoneConfig) BaselineRegions(accountId string) []string {
+	if c.Baseline == nil {
+		return c.GovernedRegions
+	}
+	if override, ok := c.Baseline.AccountOverrides[accountId]; ok && override != nil && len(override.Regions) > 0 {
+		return override.Regions
+	}
+	if len(c.Baseline.Regions) > 0 {
+		return c.Baseline.Regions
+	}
+	return c.GovernedRegions
+}
+
+// RootActivityAlarmFor returns whether the root activity alarm is deployed to an account
+func (c *LandingZoneConfig) RootActivityAlarmFor(accountId string) bool {
+	if c.Baseline == nil || c.Baseline.RootActivityAlarm == nil {
+		return false
+	}
+	if override, ok := c.Baseline.AccountOverrides[accountId]; ok && override != nil && override.RootActivityAlarm != nil {
+		return *override.RootActivityAlarm
+	}
+	return true
+}
+
+// validateRootActivityAlarm validates the name and recipients of the root activity alarm
+func validateRootActivityAlarm(a *RootActivityAlarmConfig) error {
+	if a == nil {
+		return nil
+	}
+	if !alarmNameRE.MatchString(a.AlarmName()) {
+		return fmt.Errorf("invalid root activity alarm name %q", a.Name)
+	}
+	if len(a.Emails) == 0 {
+		return fmt.Errorf("root activity alarm requires at least one email")
+	}
+	for _, email := range a.Emails {
+		if !alarmEmailRE.MatchString(email) {
+			return fmt.Errorf("invalid root activity alarm email: %s", email)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import "fmt"
+
+// Defaults of the run history
+const (
+	DefaultRunRetentionDays = 365
+	MaxRunRetentionDays     = 3650
+)
+
+// RunHistoryConfig defines the history of the deploy, drift and import runs kept in the
+// state table for audit. Runs are recorded with the DynamoDB state backend unless
+// Disabled is set, and deleted by the TTL of the table after RetentionDays.
+type RunHistoryConfig struct {
+	Disabled      bool `json:"disabled,omitempty"`
+	RetentionDays int  `json:"retentionDays,omitempty"`
+}
+
+// Enabled reports whether runs are recorded
+func (r *RunHistoryConfig) Enabled() bool {
+	return r == nil || !r.Disabled
+}
+
+// Retention returns the number of days runs are kept
+func (r *RunHistoryConfig) Retention() int {
+	if r == nil || r.RetentionDays == 0 {
+		return DefaultRunRetentionDays
+	}
+	return r.RetentionDays
+}
+
+// validateRunHistoryConfig validates the retention of the run history
+func (c *OrganizationConfig) validateRunHistoryConfig() error {
+	r := c.LandingZoneConfig.RunHistory
+	if r == nil {
+		return nil
+	}
+
+	if r.RetentionDays < 0 || r.RetentionDays > MaxRunRetentionDays {
+		return fmt.Errorf("run history retention must be between 0 and %d days", MaxRunRetentionDays)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Stores of the generated secrets
+const (
+	SecretStoreSSM            = "ssm"
+	SecretStoreSecretsManager = "secretsmanager"
+)
+
+// Defaults of the generated secrets
+const (
+	DefaultBreakGlassUserName   = "break-glass"
+	DefaultSecretRecoveryWindow = 30
+
+	// Rotation interval limits of Secrets Manager, in days
+	MinSecretRotationDays = 1
+	MaxSecretRotationDays = 1000
+)
+
+var (
+	secretStores = map[string]bool{
+		SecretStoreSSM:            true,
+		SecretStoreSecretsManager: true,
+	}
+
+	secretPrefixRE = regexp.MustCompile(`^(/[A-Za-z0-9_.-]+)+$`)
+	iamUserNameRE  = regexp.MustCompile(`^[A-Za-z0-9+=,.@_-]{1,64}$`)
+)
+
+// SecretsConfig selects where the secrets generated by the landing zone are stored:
+// SecureString SSM parameters, the default, or Secrets Manager secrets, which can be
+// rotated. Secrets are named Prefix/<name>, or like the secrets kind of SSM parameter
+// without a prefix, and encrypted with KmsKeyId, or the AWS managed key of the store
+// when it is empty. RecoveryWindowDays applies to Secrets Manager, where a deleted
+// secret can be restored during that window.
+type SecretsConfig struct {
+	Store              string                `json:"store,omitempty"`
+	Prefix             string                `json:"prefix,omitempty"`
+	KmsKeyId           string                `json:"kmsKeyId,omitempty"`
+	RecoveryWindowDays int                   `json:"recoveryWindowDays,omitempty"`
+	Rotation           *SecretRotationConfig `json:"rotation,omitempty"`
+	BreakGlass         *BreakGlassConfig     `json:"breakGlass,omitempty"`
+}
+
+// SecretRotationConfig rotates the Secrets Manager secrets every Days days with the
+// rotation Lambda function LambdaArn
+type SecretRotationConfig struct {
+	LambdaArn string `json:"lambdaArn"`
+	Days      int    `json:"days"`
+}
+
+// BreakGlassConfig creates an IAM user with administrator access in the management
+// account for emergencies, granted only when signed in with MFA. Its console password
+// is generated by IAM and kept in the secret store.
+type BreakGlassConfig struct {
+	UserName string `json:"userName,omitempty"`
+}
+
+// StoreName returns the store of the secrets
+func (s *SecretsConfig) StoreName() string {
+	if s == nil || s.Store == "" {
+		return SecretStoreSSM
+	}
+	return s.Store
+}
+
+// SecretName returns the full name of a secret
+func (c *LandingZoneConfig) SecretName(name string) string {
+	if s := c.Secrets; s != nil && s.Prefix != "" {
+		return fmt.Sprintf("%s/%s", s.Prefix, name)
+	}
+	return c.ParameterNames().Name(ParameterKindSecrets, name)
+}
+
+// RecoveryWindow returns the days a deleted Secrets Manager secret can be restored
+func (s *SecretsConfig) RecoveryWindow() int {
+	if s == nil || s.RecoveryWindowDays == 0 {
+		return DefaultSecretRecoveryWindow
+	}
+	return s.RecoveryWindowDays
+}
+
+// Name returns the name of the break-glass user
+func (b *BreakGlassConfig) Name() string {
+	if b.UserName == "" {
+		return DefaultBreakGlassUserName
+	}
+	return b.UserName
+}
+
+// validateSecretsConfig validates the store of the generated secrets
+func (c *OrganizationConfig) validateSecretsConfig() error {
+	s := c.LandingZoneConfig.Secrets
+	if s == nil {
+		return nil
+	}
+
+	if s.Store != "" && !secretStores[s.Store] {
+		return fmt.Errorf("invalid secret store %q, must be %s or %s",
+			s.Store, SecretStoreSSM, SecretStoreSecretsManager)
+	}
+	if s.Prefix != "" && !secretPrefixRE.MatchString(s.Prefix) {
+		return fmt.Errorf("invalid secret prefix %q, must start with / and not end with /", s.Prefix)
+	}
+	if s.RecoveryWindowDays != 0 {
+		if s.StoreName() != SecretStoreSecretsManager {
+			return fmt.Errorf("secret recovery window requires the %s store", SecretStoreSecretsManager)
+		}
+		if s.RecoveryWindowDays < 7 || s.RecoveryWindowDays > 30 {
+			return fmt.Errorf("secret recovery window must be between 7 and 30 days")
+		}
+	}
+
+	if r := s.Rotation; r != nil {
+		if s.StoreName() != SecretStoreSecretsManager {
+			return fmt.Errorf("secret rotation requires the %s store", SecretStoreSecretsManager)
+		}
+		if !strings.HasPrefix(r.LambdaArn, "arn:") || !strings.Contains(r.LambdaArn, ":lambda:") {
+			return fmt.Errorf("invalid secret rotation Lambda ARN %q", r.LambdaArn)
+		}
+		if r.Days < MinSecretRotationDays || r.Days > MaxSecretRotationDays {
+			return fmt.Errorf("secret rotation interval must be between %d and %d days",
+				MinSecretRotationDays, MaxSecretRotationDays)
+		}
+	}
+
+	if b := s.BreakGlass; b != nil && b.UserName != "" && !iamUserNameRE.MatchString(b.UserName) {
+		return fmt.Errorf("invalid break-glass user name %q", b.UserName)
+	}
+	return nil
+}
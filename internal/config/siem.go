@@ -0,0 +1,196 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// SIEM destinations
+const (
+	SIEMSplunk     = "splunk"
+	SIEMOpenSearch = "opensearch"
+	SIEMHTTP       = "http"
+)
+
+// Sources forwarded to the SIEM
+const (
+	SIEMSourceCloudTrail  = "cloudtrail"
+	SIEMSourceGuardDuty   = "guardduty"
+	SIEMSourceSecurityHub = "securityhub"
+)
+
+// Defaults of the SIEM forwarding
+const (
+	DefaultSIEMName      = "landing-zone-siem"
+	DefaultSIEMIndexName = "landing-zone"
+)
+
+var (
+	// DefaultSIEMSources are forwarded when no source is configured
+	DefaultSIEMSources = []string{SIEMSourceCloudTrail, SIEMSourceGuardDuty, SIEMSourceSecurityHub}
+
+	siemIndexRE = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,79}$`)
+)
+
+// SIEMConfig forwards the organization trail and the GuardDuty and Security Hub findings
+// to an external SIEM: a Splunk HTTP Event Collector, an OpenSearch Service domain or a
+// generic HTTPS endpoint. A Kinesis Data Firehose delivery stream of AccountId, the
+// security account by default, delivers them in each of Regions, the governed regions by
+// default, through a Lambda function shaping them for the SIEM. Findings reach it
+// through EventBridge rules of the account, where GuardDuty and Security Hub aggregate
+// them; the trail is read from the CloudTrail log group of the management account,
+// subscribed in the home region. Records the SIEM rejects are kept in BackupBucket, a
+// bucket of AccountId. The Splunk HEC token or the access key of the HTTPS endpoint is
+// read from the Secrets Manager secret TokenSecretArn.
+type SIEMConfig struct {
+	Type           string   `json:"type"`
+	Name           string   `json:"name,omitempty"`
+	Endpoint       string   `json:"endpoint,omitempty"`
+	DomainArn      string   `json:"domainArn,omitempty"`
+	IndexName      string   `json:"indexName,omitempty"`
+	TokenSecretArn string   `json:"tokenSecretArn,omitempty"`
+	Sources        []string `json:"sources,omitempty"`
+	AccountId      string   `json:"accountId,omitempty"`
+	Regions        []string `json:"regions,omitempty"`
+	BackupBucket   string   `json:"backupBucket"`
+}
+
+// StreamName returns the name of the delivery stream, function and rules of each region
+func (s *SIEMConfig) StreamName() string {
+	if s.Name == "" {
+		return DefaultSIEMName
+	}
+	return s.Name
+}
+
+// Index returns the OpenSearch index the events are written to
+func (s *SIEMConfig) Index() string {
+	if s.IndexName == "" {
+		return DefaultSIEMIndexName
+	}
+	return s.IndexName
+}
+
+// Forwards reports whether a source is forwarded to the SIEM
+func (s *SIEMConfig) Forwards(source string) bool {
+	sources := s.Sources
+	if len(sources) == 0 {
+		sources = DefaultSIEMSources
+	}
+	for _, forwarded := range sources {
+		if forwarded == source {
+			return true
+		}
+	}
+	return false
+}
+
+// SIEMAccountId returns the account the SIEM forwarding is deployed to
+func (c *LandingZoneConfig) SIEMAccountId() string {
+	if s := c.SIEM; s != nil && s.AccountId != "" {
+		return s.AccountId
+	}
+	return c.SecurityAccountId
+}
+
+// SIEMRegions returns the regions the findings are forwarded from
+func (c *LandingZoneConfig) SIEMRegions() []string {
+	if s := c.SIEM; s != nil && len(s.Regions) > 0 {
+		return s.Regions
+	}
+	return c.GovernedRegions
+}
+
+// validateSIEMConfig validates the SIEM forwarding
+func (c *OrganizationConfig) validateSIEMConfig() error {
+	lz := c.LandingZoneConfig
+	s := lz.SIEM
+	if s == nil {
+		return nil
+	}
+
+	switch s.Type {
+	case SIEMSplunk, SIEMHTTP:
+		endpoint, err := url.Parse(s.Endpoint)
+		if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+			return fmt.Errorf("SIEM endpoint must be an HTTPS URL, got %q", s.Endpoint)
+		}
+		if s.Type == SIEMSplunk && s.TokenSecretArn == "" {
+			return fmt.Errorf("forwarding to Splunk requires the ARN of the secret holding the HEC token")
+		}
+	case SIEMOpenSearch:
+		domain, err := arn.Parse(s.DomainArn)
+		if err != nil || domain.Service != "es" {
+			return fmt.Errorf("invalid OpenSearch domain ARN: %s", s.DomainArn)
+		}
+		if !siemIndexRE.MatchString(s.Index()) {
+			return fmt.Errorf("invalid OpenSearch index name %q", s.IndexName)
+		}
+	default:
+		return fmt.Errorf("unsupported SIEM type %q, must be one of: %s", s.Type,
+			strings.Join([]string{SIEMSplunk, SIEMOpenSearch, SIEMHTTP}, ", "))
+	}
+
+	if s.TokenSecretArn != "" {
+		secret, err := arn.Parse(s.TokenSecretArn)
+		if err != nil || secret.Service != "secretsmanager" {
+			return fmt.Errorf("invalid SIEM token secret ARN: %s", s.TokenSecretArn)
+		}
+	}
+	if !destinationNameRE.MatchString(s.StreamName()) {
+		return fmt.Errorf("invalid SIEM name %q", s.Name)
+	}
+	if !isValidAccountId(lz.SIEMAccountId()) {
+		return fmt.Errorf("SIEM requires a valid account ID or security account ID")
+	}
+	if s.BackupBucket == "" {
+		return fmt.Errorf("SIEM requires a backup bucket for the records the SIEM rejects")
+	}
+
+	for _, source := range s.Sources {
+		switch source {
+		case SIEMSourceCloudTrail, SIEMSourceGuardDuty, SIEMSourceSecurityHub:
+		default:
+			return fmt.Errorf("unsupported SIEM source %q, must be one of: %s", source,
+				strings.Join(DefaultSIEMSources, ", "))
+		}
+	}
+
+	governed := make(map[string]bool)
+	for _, region := range lz.GovernedRegions {
+		governed[region] = true
+	}
+	for _, region := range s.Regions {
+		if !governed[region] {
+			return fmt.Errorf("SIEM region %s is not a governed region", region)
+		}
+	}
+	if len(lz.SIEMRegions()) == 0 {
+		return fmt.Errorf("SIEM requires regions or governed regions")
+	}
+
+	if s.Forwards(SIEMSourceCloudTrail) {
+		if lz.CloudTrailLogGroup == "" {
+			return fmt.Errorf("forwarding the trail to the SIEM requires the CloudTrail log group")
+		}
+		if !isValidAccountId(lz.ManagementAccountId) {
+			return fmt.Errorf("forwarding the trail to the SIEM requires a valid management account ID")
+		}
+		home := false
+		for _, region := range lz.SIEMRegions() {
+			home = home || region == lz.HomeRegion
+		}
+		if !home {
+			return fmt.Errorf("forwarding the trail to the SIEM requires the home region %q among the SIEM regions", lz.HomeRegion)
+		}
+	}
+	return nil
+}
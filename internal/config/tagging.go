@@ -0,0 +1,107 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+)
+
+// Computed tags added by tag propagation
+const (
+	TagCostCenter = "CostCenter"
+	TagOwner      = "Owner"
+	TagRunID      = "RunID"
+)
+
+// Limits of the tags of an AWS resource
+const (
+	MaxResourceTags    = 50
+	maxTagKeyLength    = 128
+	maxTagValueLength  = 256
+	reservedTagsPrefix = "aws:"
+)
+
+var resourceTypeRE = regexp.MustCompile(`^[a-z0-9-]+:[A-Za-z0-9/_-]+:[A-Za-z0-9]+$`)
+
+// TagPropagationConfig adds the standard tags to every resource of the landing zone
+// that takes tags, whether or not its module tags it. The standard tags are the tags
+// of the landing zone, CostCenter and Owner when configured, and with RunID the run
+// ID of the update, which changes the tags of every resource on each run. Tags set by
+// a module win over the standard tags. ExcludedTypes lists the Pulumi types of the
+// resources left untouched, such as aws:ec2/vpc:Vpc.
+type TagPropagationConfig struct {
+	CostCenter    string   `json:"costCenter,omitempty"`
+	Owner         string   `json:"owner,omitempty"`
+	RunID         bool     `json:"runId,omitempty"`
+	ExcludedTypes []string `json:"excludedTypes,omitempty"`
+}
+
+// StandardTags returns the tags propagated to every resource
+func (c *LandingZoneConfig) StandardTags() map[string]string {
+	tags := make(map[string]string, len(c.Tags)+3)
+	for key, value := range c.Tags {
+		tags[key] = value
+	}
+
+	t := c.TagPropagation
+	if t == nil {
+		return tags
+	}
+	if t.CostCenter != "" {
+		tags[TagCostCenter] = t.CostCenter
+	}
+	if t.Owner != "" {
+		tags[TagOwner] = t.Owner
+	}
+	if t.RunID {
+		tags[TagRunID] = runid.ID()
+	}
+	return tags
+}
+
+// Excluded returns the set of resource types tag propagation leaves untouched
+func (t *TagPropagationConfig) Excluded() map[string]bool {
+	excluded := make(map[string]bool, len(t.ExcludedTypes))
+	for _, resourceType := range t.ExcludedTypes {
+		excluded[resourceType] = true
+	}
+	return excluded
+}
+
+// validateTagPropagationConfig validates the standard tags against the limits of the
+// tags of AWS resources
+func (c *OrganizationConfig) validateTagPropagationConfig() error {
+	t := c.LandingZoneConfig.TagPropagation
+	if t == nil {
+		return nil
+	}
+
+	tags := c.LandingZoneConfig.StandardTags()
+	if len(tags) > MaxResourceTags {
+		return fmt.Errorf("at most %d standard tags are supported, got %d", MaxResourceTags, len(tags))
+	}
+	for key, value := range tags {
+		if key == "" || len(key) > maxTagKeyLength {
+			return fmt.Errorf("tag key %q must be 1 to %d characters long", key, maxTagKeyLength)
+		}
+		if strings.HasPrefix(strings.ToLower(key), reservedTagsPrefix) {
+			return fmt.Errorf("tag key %q uses the reserved prefix %s", key, reservedTagsPrefix)
+		}
+		if len(value) > maxTagValueLength {
+			return fmt.Errorf("value of tag %s must be at most %d characters long", key, maxTagValueLength)
+		}
+	}
+
+	for _, resourceType := range t.ExcludedTypes {
+		if !resourceTypeRE.MatchString(resourceType) {
+			return fmt.Errorf("invalid excluded resource type %q, must be a Pulumi type such as aws:ec2/vpc:Vpc", resourceType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Ticket systems tracking the changes applied to the landing zone
+const (
+	TicketSystemServiceNow = "servicenow"
+	TicketSystemJira       = "jira"
+)
+
+// Time an apply waits for its ServiceNow change request to be approved by default
+const DefaultApprovalMinutes = 240
+
+// Environment variables holding the credentials of a ticket system by default
+var defaultTicketCredentials = map[string][2]string{
+	TicketSystemServiceNow: {"SERVICENOW_USER", "SERVICENOW_PASSWORD"},
+	TicketSystemJira:       {"JIRA_USER", "JIRA_API_TOKEN"},
+}
+
+// ChangeTicketConfig defines the ticket opened in a ServiceNow or Jira instance for every
+// apply of the listed stacks, or of every stack when Stacks is empty. Credentials are
+// read from the environment variables named by UserEnv and SecretEnv.
+type ChangeTicketConfig struct {
+	System    string   `json:"system"`
+	URL       string   `json:"url"`
+	Stacks    []string `json:"stacks,omitempty"`
+	UserEnv   string   `json:"userEnv,omitempty"`
+	SecretEnv string   `json:"secretEnv,omitempty"`
+
+	// Change requests of ServiceNow are assigned to AssignmentGroup when set, and the
+	// apply waits up to ApprovalMinutes for them to be approved
+	AssignmentGroup string `json:"assignmentGroup,omitempty"`
+	ApprovalMinutes int    `json:"approvalMinutes,omitempty"`
+
+	// Issues of Jira are created in Project and closed with DoneTransition
+	Project        string `json:"project,omitempty"`
+	IssueType      string `json:"issueType,omitempty"`
+	DoneTransition string `json:"doneTransition,omitempty"`
+}
+
+// Credentials returns the environment variables holding the user and secret of the
+// ticket system
+func (t *ChangeTicketConfig) Credentials() (string, string) {
+	user, secret := t.UserEnv, t.SecretEnv
+	if user == "" {
+		user = defaultTicketCredentials[t.System][0]
+	}
+	if secret == "" {
+		secret = defaultTicketCredentials[t.System][1]
+	}
+	return user, secret
+}
+
+// ApprovalTimeout returns the time an apply waits for its change request to be approved
+func (t *ChangeTicketConfig) ApprovalTimeout() time.Duration {
+	if t.ApprovalMinutes == 0 {
+		return DefaultApprovalMinutes * time.Minute
+	}
+	return time.Duration(t.ApprovalMinutes) * time.Minute
+}
+
+// Tracks reports whether the applies of a stack open a ticket
+func (t *ChangeTicketConfig) Tracks(stack string) bool {
+	if len(t.Stacks) == 0 {
+		return true
+	}
+	for _, s := range t.Stacks {
+		if s == stack {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeTicketsFor returns the ticket systems tracking the applies of a stack
+func (c *LandingZoneConfig) ChangeTicketsFor(stack string) []ChangeTicketConfig {
+	var tickets []ChangeTicketConfig
+	for _, ticket := range c.ChangeTickets {
+		if ticket.Tracks(stack) {
+			tickets = append(tickets, ticket)
+		}
+	}
+	return tickets
+}
+
+// validateChangeTickets validates the ticket systems tracking the applies
+func (c *OrganizationConfig) validateChangeTickets() error {
+	for i, ticket := range c.LandingZoneConfig.ChangeTickets {
+		switch ticket.System {
+		case TicketSystemServiceNow:
+		case TicketSystemJira:
+			if ticket.Project == "" {
+				return fmt.Errorf("change ticket %d requires a Jira project", i)
+			}
+		default:
+			return fmt.Errorf("change ticket %d has an unsupported system %q, must be %s or %s",
+				i, ticket.System, TicketSystemServiceNow, TicketSystemJira)
+		}
+
+		if ticket.ApprovalMinutes < 0 {
+			return fmt.Errorf("change ticket %d has a negative approvalMinutes", i)
+		}
+		if u, err := url.Parse(ticket.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("change ticket %d requires an https URL", i)
+		}
+		for _, env := range []string{ticket.UserEnv, ticket.SecretEnv} {
+			if env != "" && !envNameRE.MatchString(env) {
+				return fmt.Errorf("change ticket %d has an invalid environment variable %q", i, env)
+			}
+		}
+	}
+	return nil
+}
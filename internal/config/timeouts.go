@@ -0,0 +1,92 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/selection"
+)
+
+// Defaults of the deployment timeouts
+const (
+	DefaultGraceSeconds = 120
+	MaxGraceSeconds     = 900
+)
+
+// TimeoutsConfig bounds the time of a run. DeadlineMinutes bounds the whole run in
+// place of the 30 minutes default. When ModuleMinutes sets a timeout, the deploy
+// command applies the selected modules one update at a time, each bounded by its own
+// timeout within the deadline. An update running out of time is interrupted as with
+// Ctrl-C: the engine starts no new resource operation and is given GraceSeconds to
+// finish those in flight and save the stack before it is stopped.
+type TimeoutsConfig struct {
+	DeadlineMinutes int            `json:"deadlineMinutes,omitempty"`
+	ModuleMinutes   map[string]int `json:"moduleMinutes,omitempty"`
+	GraceSeconds    int            `json:"graceSeconds,omitempty"`
+}
+
+// Deadline returns the time a run may take, or fallback when no deadline is set
+func (t *TimeoutsConfig) Deadline(fallback time.Duration) time.Duration {
+	if t == nil || t.DeadlineMinutes == 0 {
+		return fallback
+	}
+	return time.Duration(t.DeadlineMinutes) * time.Minute
+}
+
+// ModuleTimeout returns the time the update of a module may take, or zero when the
+// module is only bounded by the deadline
+func (t *TimeoutsConfig) ModuleTimeout(module string) time.Duration {
+	if t == nil {
+		return 0
+	}
+	return time.Duration(t.ModuleMinutes[module]) * time.Minute
+}
+
+// PerModule reports whether the modules are applied one update at a time
+func (t *TimeoutsConfig) PerModule() bool {
+	return t != nil && len(t.ModuleMinutes) > 0
+}
+
+// Grace returns the time an interrupted update is given to stop
+func (t *TimeoutsConfig) Grace() time.Duration {
+	if t == nil || t.GraceSeconds == 0 {
+		return DefaultGraceSeconds * time.Second
+	}
+	return time.Duration(t.GraceSeconds) * time.Second
+}
+
+// validateTimeoutsConfig validates the deadline, module timeouts and grace period
+func (c *OrganizationConfig) validateTimeoutsConfig() error {
+	t := c.LandingZoneConfig.Timeouts
+	if t == nil {
+		return nil
+	}
+
+	if t.DeadlineMinutes < 0 {
+		return fmt.Errorf("deployment deadline must not be negative")
+	}
+	if t.GraceSeconds < 0 || t.GraceSeconds > MaxGraceSeconds {
+		return fmt.Errorf("grace period must be between 0 and %d seconds", MaxGraceSeconds)
+	}
+
+	modules := make(map[string]bool, len(selection.Modules))
+	for _, module := range selection.Modules {
+		modules[module] = true
+	}
+	for module, minutes := range t.ModuleMinutes {
+		if !modules[module] {
+			return fmt.Errorf("timeout of unknown module %q", module)
+		}
+		if minutes <= 0 {
+			return fmt.Errorf("timeout of module %s must be positive", module)
+		}
+		if t.DeadlineMinutes > 0 && minutes > t.DeadlineMinutes {
+			return fmt.Errorf("timeout of module %s exceeds the deployment deadline", module)
+		}
+	}
+	return nil
+}
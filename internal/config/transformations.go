@@ -0,0 +1,108 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PluginSuffix ends the path of every transformation plugin
+const PluginSuffix = ".so"
+
+// TransformationsConfig customizes the resources of the landing zone without changing
+// its code. Rules change the options of the resources they match, in order. Plugins
+// are Go plugins built against the same version of this module, each exporting a
+// function Transformations of type func() []pulumi.ResourceTransformation, which are
+// registered after the rules.
+type TransformationsConfig struct {
+	Rules   []TransformationRule `json:"rules,omitempty"`
+	Plugins []string             `json:"plugins,omitempty"`
+}
+
+// TransformationRule changes the resources whose Pulumi type and name match Type and
+// Name, patterns where * matches any characters, such as aws:s3/* for every S3
+// resource. Protect and RetainOnDelete keep the resource from being deleted,
+// IgnoreChanges lists properties whose changes are ignored, and NamePrefix prefixes
+// the physical name of the resources given one by their module.
+type TransformationRule struct {
+	Type           string   `json:"type,omitempty"`
+	Name           string   `json:"name,omitempty"`
+	Protect        bool     `json:"protect,omitempty"`
+	RetainOnDelete bool     `json:"retainOnDelete,omitempty"`
+	IgnoreChanges  []string `json:"ignoreChanges,omitempty"`
+	NamePrefix     string   `json:"namePrefix,omitempty"`
+}
+
+var rulePatternRE = regexp.MustCompile(`^[A-Za-z0-9:/_.*-]+$`)
+
+// Matches reports whether the rule applies to a resource
+func (r *TransformationRule) Matches(resourceType, name string) bool {
+	return matchPattern(r.Type, resourceType) && matchPattern(r.Name, name)
+}
+
+// matchPattern matches a value against a pattern where * matches any characters. An
+// empty pattern matches every value.
+func matchPattern(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
+
+// validateTransformationsConfig validates the transformation rules and plugins
+func (c *OrganizationConfig) validateTransformationsConfig() error {
+	t := c.LandingZoneConfig.Transformations
+	if t == nil {
+		return nil
+	}
+
+	for i, rule := range t.Rules {
+		if rule.Type == "" && rule.Name == "" {
+			return fmt.Errorf("transformation rule %d must match a type or a name", i)
+		}
+		for _, pattern := range []string{rule.Type, rule.Name} {
+			if pattern != "" && !rulePatternRE.MatchString(pattern) {
+				return fmt.Errorf("invalid pattern %q of transformation rule %d", pattern, i)
+			}
+		}
+		if !rule.Protect && !rule.RetainOnDelete && len(rule.IgnoreChanges) == 0 && rule.NamePrefix == "" {
+			return fmt.Errorf("transformation rule %d changes nothing", i)
+		}
+		for _, property := range rule.IgnoreChanges {
+			if property == "" {
+				return fmt.Errorf("transformation rule %d ignores changes of an empty property", i)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, plugin := range t.Plugins {
+		if !strings.HasSuffix(plugin, PluginSuffix) {
+			return fmt.Errorf("transformation plugin %q must be a %s file", plugin, PluginSuffix)
+		}
+		if seen[plugin] {
+			return fmt.Errorf("duplicate transformation plugin %q", plugin)
+		}
+		seen[plugin] = true
+	}
+	return nil
+}
@@ -7,13 +7,19 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
+	"text/template"
 	"time"
 
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
 	"go.uber.org/zap"
 )
@@ -37,6 +43,29 @@ const (
 	SkAttribute      = "sk"
 	StateAttribute   = "state"
 	VersionAttribute = "version"
+
+	// Attributes of large states, referencing the chunk items or the S3 object holding
+	// the state attribute
+	ChunksAttribute     = "chunks"
+	PayloadKeyAttribute = "payloadKey"
+
+	// Backends storing the state
+	StateBackendDynamoDB = "dynamodb"
+	StateBackendS3       = "s3"
+	StateBackendLocal    = "local"
+	StateBackendNone     = "none"
+
+	// DefaultStateFilePath is the state file of the local backend
+	DefaultStateFilePath = "organization-state.json"
+
+	// TTL attribute, holding the epoch second after which DynamoDB deletes the item
+	ExpiresAtAttribute = "expiresAt"
+
+	// Attributes of encrypted states, holding the KMS key and encrypted data key the
+	// state attribute is decrypted with
+	KeyArnAttribute  = "keyArn"
+	DataKeyAttribute = "dataKey"
+	NonceAttribute   = "nonce"
 )
 
 // StateData represents the structure of stored state
@@ -56,6 +85,12 @@ type StateData struct {
 	MaxRetries        int                    `json:"maxRetries,omitempty"`
 	InitialBackoff    time.Duration          `json:"initialBackoff,omitempty"`
 	BackupFilePrefix  string                 `json:"backupFilePrefix,omitempty"`
+
+	// Run that wrote the document, matching the runId of its logs and notifications
+	RunID string `json:"runId,omitempty"`
+
+	// Records written by commands next to the configuration, keyed by name
+	Records map[string]json.RawMessage `json:"records,omitempty"`
 }
 
 // StateError represents a state operation error
@@ -72,6 +107,11 @@ func (e *StateError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Operation, e.Message)
 }
 
+// Unwrap returns the underlying state error
+func (e *StateError) Unwrap() error {
+	return e.Err
+}
+
 // Version information
 const (
 	ConfigVersion = "1.0.0"
@@ -84,8 +124,80 @@ const (
 	MinNameLength       = 3
 	MaxNameLength       = 128
 	EmailRegexPattern   = `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
+
+	// Objects deleted earlier from Glacier Deep Archive are billed for the remainder
+	MinDeepArchiveStorageDays = 180
+
+	// Longest retention of the state and account backups
+	MaxBackupRetentionDays = 3650
+
+	// IAM password policy limits
+	MinPasswordLength          = 6
+	MaxPasswordLength          = 128
+	MaxPasswordAgeDays         = 1095
+	MaxPasswordReusePrevention = 24
+
+	// Post-provision hook types
+	HookTypeSSMAutomation = "ssm-automation"
+	HookTypeStepFunctions = "step-functions"
+
+	// GuardDuty suppression rule limits
+	MaxGuardDutyFilterRank = 100
+)
+
+// hookNameRE matches hook names, which are used in SSM parameter paths
+var hookNameRE = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// Names of the state table and bucket
+var (
+	stateTableNameRE  = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,255}$`)
+	stateBucketNameRE = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
 )
 
+// inspectorScanTypes lists the scan types accepted by Inspector
+var inspectorScanTypes = map[string]bool{
+	"EC2":         true,
+	"ECR":         true,
+	"LAMBDA":      true,
+	"LAMBDA_CODE": true,
+}
+
+// weekdays lists the days accepted by weekly schedules
+var weekdays = map[string]bool{
+	"MONDAY":    true,
+	"TUESDAY":   true,
+	"WEDNESDAY": true,
+	"THURSDAY":  true,
+	"FRIDAY":    true,
+	"SATURDAY":  true,
+	"SUNDAY":    true,
+}
+
+// guardDutyFilterNameRE matches the names accepted for GuardDuty filters
+var guardDutyFilterNameRE = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,64}$`)
+
+// guardDutyFilterActions lists the actions accepted by GuardDuty filters
+var guardDutyFilterActions = map[string]bool{
+	"ARCHIVE": true,
+	"NOOP":    true,
+}
+
+// guardDutyPublishingFrequencies lists the finding publishing frequencies accepted by GuardDuty
+var guardDutyPublishingFrequencies = map[string]bool{
+	"FIFTEEN_MINUTES": true,
+	"ONE_HOUR":        true,
+	"SIX_HOURS":       true,
+}
+
+// securityHubSeverities lists the severity labels accepted by Security Hub
+var securityHubSeverities = map[string]bool{
+	"INFORMATIONAL": true,
+	"LOW":           true,
+	"MEDIUM":        true,
+	"HIGH":          true,
+	"CRITICAL":      true,
+}
+
 // ConfigurationManager handles configuration operations
 type ConfigurationManager interface {
 	Load() (*OrganizationConfig, error)
@@ -99,14 +211,30 @@ type ConfigurationManager interface {
 type OrganizationConfig struct {
 	Version           string             `json:"version"`
 	AWSProfile        string             `json:"awsProfile"`
+	Partition         string             `json:"partition,omitempty"`
+	Region            string             `json:"region,omitempty"`
 	LandingZoneConfig *LandingZoneConfig `json:"LandingZoneConfig"`
-	logger            *zap.Logger
-	metrics           *metrics.Collector
-	mutex             sync.RWMutex
+
+	// Organizations managed from the same configuration, keyed by name. Select makes one
+	// of them the current configuration.
+	Organizations map[string]*OrganizationTarget `json:"organizations,omitempty"`
+	Selected      string                         `json:"-"`
+
+	// Environments of the landing zone, keyed by name. SelectEnvironment applies one of
+	// them to the current configuration.
+	Environments map[string]*EnvironmentConfig `json:"environments,omitempty"`
+	Environment  string                        `json:"-"`
+
+	logger  *zap.Logger
+	metrics *metrics.Collector
+	mutex   sync.RWMutex
 }
 
 // LandingZoneConfig defines the complete AWS Control Tower Landing Zone configuration
 type LandingZoneConfig struct {
+	// Preset the configuration expands from; the other fields override it
+	Preset string `json:"preset,omitempty"`
+
 	// Basic configurations
 	GovernedRegions   []string             `json:"governedRegions"`
 	DefaultOUName     string               `json:"defaultOUName"`
@@ -120,6 +248,22 @@ type LandingZoneConfig struct {
 	KMSKeyArn   string `json:"kmsKeyArn"`
 	KMSKeyId    string `json:"kmsKeyId"`
 
+	// Administrators and organization-wide grants of the customer managed keys
+	KeyPolicy *KeyPolicyConfig `json:"keyPolicy,omitempty"`
+
+	// State storage: the backend (dynamodb, s3, local or none), the state file of the
+	// local backend, the customer managed KMS key the state and its backups are
+	// encrypted with, and the table and bucket holding them
+	StateBackend    string `json:"stateBackend,omitempty"`
+	StateFilePath   string `json:"stateFilePath,omitempty"`
+	StateKMSKeyArn  string `json:"stateKmsKeyArn,omitempty"`
+	StateTableName  string `json:"stateTableName,omitempty"`
+	StateBucketName string `json:"stateBucketName,omitempty"`
+
+	// Days the state backups and the account backups are kept before they are pruned,
+	// BackupRetentionDays by default
+	BackupRetentionDays int `json:"backupRetentionDays,omitempty"`
+
 	// Account configurations
 	AccountEmailDomain  string `json:"accountEmailDomain"`
 	ManagementAccountId string `json:"managementAccountId"`
@@ -145,6 +289,9 @@ type LandingZoneConfig struct {
 	AccessLogBucketName    string `json:"accessLogBucketName"`
 	FlowLogBucketName      string `json:"flowLogBucketName"`
 
+	// Log archival configurations for the log bucket
+	LogArchive *LogArchiveConfig `json:"logArchive,omitempty"`
+
 	// Network configurations
 	VPCSettings *VPCConfig `json:"vpcSettings,omitempty"`
 
@@ -155,15 +302,165 @@ type LandingZoneConfig struct {
 	EnableGuardDuty    bool     `json:"enableGuardDuty"`
 	EnableConfig       bool     `json:"enableConfig"`
 	EnableCloudTrail   bool     `json:"enableCloudTrail"`
+	EnableDetective    bool     `json:"enableDetective"`
+	EnableInspector    bool     `json:"enableInspector"`
+	InspectorScanTypes []string `json:"inspectorScanTypes,omitempty"`
+	EnableMacie        bool     `json:"enableMacie"`
 	AllowedIPRanges    []string `json:"allowedIPRanges"`
 	RestrictedServices []string `json:"restrictedServices"`
+
+	// Macie configurations, used when EnableMacie is set
+	Macie *MacieConfig `json:"macie,omitempty"`
+
+	// GuardDuty configurations, used when EnableGuardDuty is set
+	GuardDuty *GuardDutyConfig `json:"guardDuty,omitempty"`
+
+	// Teardown configurations. Resources listed here are never deleted by the destroy
+	// workflow; when unset the log buckets are retained.
+	RetainOnDestroy []string `json:"retainOnDestroy,omitempty"`
+
+	// Conformance configurations
+	Quarantine *QuarantineConfig `json:"quarantine,omitempty"`
+	Compliance *ComplianceConfig `json:"compliance,omitempty"`
+
+	// IAM baseline applied to every member account
+	Baseline *BaselineConfig `json:"baseline,omitempty"`
+
+	// Drift detection of the baseline StackSets
+	Drift *DriftConfig `json:"drift,omitempty"`
+
+	// Existing accounts invited to join the organization
+	Invitations []InvitationConfig `json:"invitations,omitempty"`
+
+	// Cost categories and Cost and Usage Report delivery
+	Billing *BillingConfig `json:"billing,omitempty"`
+
+	// AWS Health organizational view and event routing
+	Health *HealthConfig `json:"health,omitempty"`
+
+	// Accounts allowed to read the /organization parameters
+	ParameterSharing *ParameterSharingConfig `json:"parameterSharing,omitempty"`
+
+	// Publication of the landing zone manifest to its consumers
+	Manifest *ManifestConfig `json:"manifest,omitempty"`
+
+	// Cache of the organization listings read from the Organizations API
+	Cache *CacheConfig `json:"cache,omitempty"`
+
+	// Service control and tag policies attached to the root, OUs and accounts
+	Policies []PolicyConfig `json:"policies,omitempty"`
+
+	// Deploys the deny SCPs sharing the same targets as consolidated documents
+	MergeSCPs bool `json:"mergeScps,omitempty"`
+
+	// Canary the new and changed policies are validated on before their rollout
+	Canary *CanaryConfig `json:"canary,omitempty"`
+
+	// Waves the changes of the policies and baselines reach one after the other
+	Rollout *RolloutConfig `json:"rollout,omitempty"`
+
+	// Service Catalog blueprints of Account Factory Customization
+	AccountFactoryCustomization *AccountFactoryCustomizationConfig `json:"accountFactoryCustomization,omitempty"`
+
+	// Service Catalog portfolios of platform products shared with the organization
+	ServiceCatalog *ServiceCatalogConfig `json:"serviceCatalog,omitempty"`
+
+	// Organization-wide enrollment of Compute Optimizer and Trusted Advisor
+	Optimization *OptimizationConfig `json:"optimization,omitempty"`
+
+	// Endpoints notified of account lifecycle transitions
+	Webhooks []WebhookConfig `json:"webhooks,omitempty"`
+
+	// ServiceNow or Jira tickets tracking every apply
+	ChangeTickets []ChangeTicketConfig `json:"changeTickets,omitempty"`
+
+	// Step Functions orchestration of account vending
+	Vending *VendingConfig `json:"vending,omitempty"`
+
+	// Level and format of the logs
+	Logging *LoggingConfig `json:"logging,omitempty"`
+
+	// Namespace and template of the names of the SSM parameters
+	Parameters *ParametersConfig `json:"parameters,omitempty"`
+
+	// Store of the generated secrets, such as break-glass credentials and webhook secrets
+	Secrets *SecretsConfig `json:"secrets,omitempty"`
+
+	// CloudWatch cross-account observability sink and the links of the accounts to it
+	Observability *ObservabilityConfig `json:"observability,omitempty"`
+
+	// Firehose delivery of the log groups of every account to the log archive account
+	CentralLogging *CentralLoggingConfig `json:"centralLogging,omitempty"`
+
+	// Glue tables and saved Athena queries of the trail and the VPC flow logs
+	LogAnalytics *LogAnalyticsConfig `json:"logAnalytics,omitempty"`
+
+	// Forwarding of the trail and the security findings to an external SIEM
+	SIEM *SIEMConfig `json:"siem,omitempty"`
+
+	// Template of the names of the roles, buckets, log groups and keys of the landing zone
+	Naming *NamingConfig `json:"naming,omitempty"`
+
+	// Standard tags added to every resource by a stack transformation
+	TagPropagation *TagPropagationConfig `json:"tagPropagation,omitempty"`
+
+	// Customer rules and plugins transforming the resources of the landing zone
+	Transformations *TransformationsConfig `json:"transformations,omitempty"`
+
+	// Commands, Lambda functions and webhooks run before and after each module
+	ModuleHooks map[string]*ModuleHooksConfig `json:"moduleHooks,omitempty"`
+
+	// Custom guardrails and baselines loaded from plugins
+	Extensions *ExtensionsConfig `json:"extensions,omitempty"`
+
+	// Deadline of a run and timeouts of the updates of the modules
+	Timeouts *TimeoutsConfig `json:"timeouts,omitempty"`
+
+	// Server of the serve command the progress of updates is published to
+	ProgressStream *ProgressStreamConfig `json:"progressStream,omitempty"`
+
+	// History of the deploy, drift and import runs kept for audit
+	RunHistory *RunHistoryConfig `json:"runHistory,omitempty"`
+
+	// Custom attributes of the accounts, such as a cost center or owner email
+	AccountAttributes map[string]*AccountAttributeConfig `json:"accountAttributes,omitempty"`
+
+	// Periodic review of the accounts of each owner, emailed or posted to Slack
+	AccessReview *AccessReviewConfig `json:"accessReview,omitempty"`
+
+	// Sender of the emails of the reports, approvals and notifications
+	Mail *MailConfig `json:"mail,omitempty"`
+
+	// Pre-flight verification of the email addresses of the accounts
+	EmailVerification *EmailVerificationConfig `json:"emailVerification,omitempty"`
+
+	// Creates every resource instead of adopting the OUs and roles left behind by a
+	// partially failed run
+	DisableAdoption bool `json:"disableAdoption,omitempty"`
+
+	// Environment selected with SelectEnvironment, filling {env} in the naming template
+	environment string
+}
+
+// LogBucketRegion returns the region hosting the log archive buckets
+func (c *LandingZoneConfig) LogBucketRegion() string {
+	if c.CloudTrailBucketRegion != "" {
+		return c.CloudTrailBucketRegion
+	}
+	if c.HomeRegion != "" {
+		return c.HomeRegion
+	}
+	if len(c.GovernedRegions) > 0 {
+		return c.GovernedRegions[0]
+	}
+	return ""
 }
 
 // NewOrganizationConfig creates a new configuration instance
 func NewOrganizationConfig() (*OrganizationConfig, error) {
-	logger, err := zap.NewProduction()
+	logger, err := logging.NewLogger("config")
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+		return nil, err
 	}
 
 	metrics, err := metrics.NewCollector("config")
@@ -178,8 +475,33 @@ func NewOrganizationConfig() (*OrganizationConfig, error) {
 	}, nil
 }
 
+// ValidationError reports a section of the configuration that failed validation
+type ValidationError struct {
+	Section string
+	Err     error
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s configuration validation failed: %v", e.Section, e.Err)
+}
+
+// Unwrap returns the underlying validation error
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
 // Validate performs comprehensive configuration validation
 func (c *OrganizationConfig) Validate() error {
+	if errs := c.ValidateAll(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// ValidateAll validates every section of the configuration and returns all the
+// sections that failed, in validation order
+func (c *OrganizationConfig) ValidateAll() []*ValidationError {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
@@ -189,28 +511,99 @@ func (c *OrganizationConfig) Validate() error {
 		c.metrics.RecordDuration("config_validation", time.Since(start))
 	}()
 
-	if c.LandingZoneConfig == nil {
-		return fmt.Errorf("landing zone configuration is required")
+	var errs []*ValidationError
+	if len(c.Organizations) > 0 && c.Selected == "" {
+		errs = append(errs, c.validateOrganizations()...)
+	} else if c.LandingZoneConfig == nil {
+		errs = append(errs, &ValidationError{Section: "landing zone", Err: fmt.Errorf("landing zone configuration is required")})
 	}
-
-	if err := c.validateBasicConfig(); err != nil {
-		return fmt.Errorf("basic configuration validation failed: %w", err)
+	if c.LandingZoneConfig != nil {
+		errs = append(errs, c.validateSections()...)
 	}
-
-	if err := c.validateAccountConfig(); err != nil {
-		return fmt.Errorf("account configuration validation failed: %w", err)
+	if err := c.validateEnvironments(); err != nil {
+		errs = append(errs, &ValidationError{Section: "environments", Err: err})
 	}
 
-	if err := c.validateNetworkConfig(); err != nil {
-		return fmt.Errorf("network configuration validation failed: %w", err)
+	if len(errs) > 0 {
+		c.logger.Warn("configuration validation failed", zap.Int("errors", len(errs)))
+		return errs
 	}
 
 	c.logger.Info("configuration validation completed successfully")
 	return nil
 }
 
+// validateSections validates every section of the landing zone configuration
+func (c *OrganizationConfig) validateSections() []*ValidationError {
+	sections := []struct {
+		name     string
+		validate func() error
+	}{
+		{"basic", c.validateBasicConfig},
+		{"account", c.validateAccountConfig},
+		{"network", c.validateNetworkConfig},
+		{"log archive", c.validateLogArchiveConfig},
+		{"security", c.validateSecurityConfig},
+		{"quarantine", c.validateQuarantineConfig},
+		{"invitation", c.validateInvitationConfig},
+		{"hook", c.validateHookConfig},
+		{"baseline", c.validateBaselineConfig},
+		{"drift", c.validateDriftConfig},
+		{"billing", c.validateBillingConfig},
+		{"health", c.validateHealthConfig},
+		{"optimization", c.validateOptimizationConfig},
+		{"webhooks", c.validateWebhooks},
+		{"changeTickets", c.validateChangeTickets},
+		{"vending", c.validateVendingConfig},
+		{"logging", c.validateLoggingConfig},
+		{"parameters", c.validateParametersConfig},
+		{"secrets", c.validateSecretsConfig},
+		{"observability", c.validateObservabilityConfig},
+		{"central logging", c.validateCentralLoggingConfig},
+		{"log analytics", c.validateLogAnalyticsConfig},
+		{"siem", c.validateSIEMConfig},
+		{"naming", c.validateNamingConfig},
+		{"tag propagation", c.validateTagPropagationConfig},
+		{"transformations", c.validateTransformationsConfig},
+		{"module hooks", c.validateModuleHooks},
+		{"extensions", c.validateExtensionsConfig},
+		{"timeouts", c.validateTimeoutsConfig},
+		{"progress stream", c.validateProgressStreamConfig},
+		{"run history", c.validateRunHistoryConfig},
+		{"account attributes", c.validateAccountAttributes},
+		{"access review", c.validateAccessReview},
+		{"mail", c.validateMailConfig},
+		{"email verification", c.validateEmailVerificationConfig},
+		{"parameter sharing", c.validateParameterSharingConfig},
+		{"key policy", c.validateKeyPolicyConfig},
+		{"manifest", c.validateManifestConfig},
+		{"cache", c.validateCacheConfig},
+		{"policy", c.validatePolicyConfig},
+		{"canary", c.validateCanaryConfig},
+		{"rollout", c.validateRolloutConfig},
+		{"blueprint", c.validateBlueprintConfig},
+		{"service catalog", c.validateServiceCatalogConfig},
+		{"state", c.validateStateConfig},
+		{"partition", c.validatePartitionConfig},
+	}
+
+	var errs []*ValidationError
+	for _, section := range sections {
+		if err := section.validate(); err != nil {
+			errs = append(errs, &ValidationError{Section: section.name, Err: err})
+		}
+	}
+	return errs
+}
+
 // validateBasicConfig validates basic configuration settings
 func (c *OrganizationConfig) validateBasicConfig() error {
+	if preset := c.LandingZoneConfig.Preset; preset != "" {
+		if _, ok := presets[preset]; !ok {
+			return fmt.Errorf("unknown preset %q, available presets: %v", preset, Presets())
+		}
+	}
+
 	if len(c.LandingZoneConfig.GovernedRegions) == 0 {
 		return fmt.Errorf("at least one governed region is required")
 	}
@@ -274,6 +667,476 @@ func (c *OrganizationConfig) validateNetworkConfig() error {
 	return nil
 }
 
+// validateLogArchiveConfig validates that the archival settings of the log bucket
+// keep logs for exactly LogRetentionDays
+func (c *OrganizationConfig) validateLogArchiveConfig() error {
+	lz := c.LandingZoneConfig
+	archive := lz.LogArchive
+	if archive == nil {
+		return nil
+	}
+
+	if lz.LogBucketName == "" {
+		return fmt.Errorf("a log bucket name is required to configure log archival")
+	}
+
+	if archive.ObjectLockEnabled {
+		switch archive.ObjectLockMode {
+		case "", "COMPLIANCE", "GOVERNANCE":
+		default:
+			return fmt.Errorf("invalid Object Lock mode: %s", archive.ObjectLockMode)
+		}
+
+		// Locked objects cannot be expired, so a longer lock would keep logs past their retention
+		if archive.ObjectLockRetentionDays < 0 || archive.ObjectLockRetentionDays > lz.LogRetentionDays {
+			return fmt.Errorf("Object Lock retention must be between 0 and %d days", lz.LogRetentionDays)
+		}
+	}
+
+	if archive.DeepArchiveTransitionDays < 0 ||
+		(archive.DeepArchiveTransitionDays > 0 && archive.DeepArchiveTransitionDays >= lz.LogRetentionDays) {
+		return fmt.Errorf("Deep Archive transition must happen before logs expire after %d days",
+			lz.LogRetentionDays)
+	}
+
+	if archive.DeepArchiveTransitionDays > 0 &&
+		lz.LogRetentionDays-archive.DeepArchiveTransitionDays < MinDeepArchiveStorageDays {
+		c.logger.Warn("logs expire before the Deep Archive minimum storage duration, early deletion fees apply",
+			zap.Int("transitionDays", archive.DeepArchiveTransitionDays),
+			zap.Int("logRetentionDays", lz.LogRetentionDays))
+	}
+
+	return nil
+}
+
+// validateSecurityConfig validates the optional security services settings
+func (c *OrganizationConfig) validateSecurityConfig() error {
+	lz := c.LandingZoneConfig
+	if (lz.EnableDetective || lz.EnableInspector || lz.EnableMacie) && !isValidAccountId(lz.SecurityAccountId) {
+		return fmt.Errorf("a valid security account ID is required to delegate Detective, Inspector or Macie")
+	}
+
+	for _, scanType := range lz.InspectorScanTypes {
+		if !inspectorScanTypes[scanType] {
+			return fmt.Errorf("invalid Inspector scan type: %s", scanType)
+		}
+	}
+
+	if lz.EnableGuardDuty && lz.GuardDuty != nil {
+		if err := validateGuardDutyConfig(lz); err != nil {
+			return err
+		}
+	}
+
+	if lz.EnableMacie && lz.Macie != nil {
+		switch lz.Macie.Schedule {
+		case "", "DAILY":
+		case "WEEKLY":
+			if !weekdays[lz.Macie.WeeklyDay] {
+				return fmt.Errorf("invalid Macie weekly day: %s", lz.Macie.WeeklyDay)
+			}
+		case "MONTHLY":
+			if lz.Macie.MonthlyDay < 1 || lz.Macie.MonthlyDay > 31 {
+				return fmt.Errorf("Macie monthly day must be between 1 and 31")
+			}
+		default:
+			return fmt.Errorf("invalid Macie schedule: %s", lz.Macie.Schedule)
+		}
+
+		if lz.Macie.SamplingPercentage < 0 || lz.Macie.SamplingPercentage > 100 {
+			return fmt.Errorf("Macie sampling percentage must be between 0 and 100")
+		}
+	}
+
+	return nil
+}
+
+// validateGuardDutyConfig validates the findings export and suppression rules of GuardDuty
+func validateGuardDutyConfig(lz *LandingZoneConfig) error {
+	g := lz.GuardDuty
+	if !isValidAccountId(lz.SecurityAccountId) {
+		return fmt.Errorf("a valid security account ID is required to delegate GuardDuty")
+	}
+
+	if g.FindingPublishingFrequency != "" && !guardDutyPublishingFrequencies[g.FindingPublishingFrequency] {
+		return fmt.Errorf("invalid GuardDuty finding publishing frequency: %s", g.FindingPublishingFrequency)
+	}
+
+	if g.Export != nil {
+		if g.Export.BucketName == "" && !lz.ResourceNames().Configured() {
+			return fmt.Errorf("a bucket name or naming template is required to export GuardDuty findings")
+		}
+		if !isValidAccountId(lz.LogArchiveAccountId) {
+			return fmt.Errorf("a valid log archive account ID is required to export GuardDuty findings")
+		}
+	}
+
+	if len(g.SuppressionRules) > MaxGuardDutyFilterRank {
+		return fmt.Errorf("at most %d GuardDuty suppression rules are supported", MaxGuardDutyFilterRank)
+	}
+
+	names := make(map[string]bool)
+	for _, rule := range g.SuppressionRules {
+		if !guardDutyFilterNameRE.MatchString(rule.Name) {
+			return fmt.Errorf("invalid GuardDuty suppression rule name: %q", rule.Name)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate GuardDuty suppression rule: %s", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if rule.Action != "" && !guardDutyFilterActions[rule.Action] {
+			return fmt.Errorf("invalid action %s for GuardDuty suppression rule %s", rule.Action, rule.Name)
+		}
+		if rule.Rank < 0 || rule.Rank > MaxGuardDutyFilterRank {
+			return fmt.Errorf("rank of GuardDuty suppression rule %s must be between 1 and %d", rule.Name, MaxGuardDutyFilterRank)
+		}
+
+		if len(rule.Criteria) == 0 {
+			return fmt.Errorf("GuardDuty suppression rule %s requires at least one criterion", rule.Name)
+		}
+		for _, criterion := range rule.Criteria {
+			if criterion.Field == "" {
+				return fmt.Errorf("GuardDuty suppression rule %s has a criterion without field", rule.Name)
+			}
+			if len(criterion.Equals) == 0 && len(criterion.NotEquals) == 0 && criterion.GreaterThan == "" &&
+				criterion.GreaterThanOrEqual == "" && criterion.LessThan == "" && criterion.LessThanOrEqual == "" {
+				return fmt.Errorf("criterion %s of GuardDuty suppression rule %s has no condition", criterion.Field, rule.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateQuarantineConfig validates the quarantine workflow settings
+func (c *OrganizationConfig) validateQuarantineConfig() error {
+	q := c.LandingZoneConfig.Quarantine
+	if q == nil || !q.Enabled {
+		return nil
+	}
+
+	for _, severity := range q.Severities {
+		if !securityHubSeverities[severity] {
+			return fmt.Errorf("invalid severity: %s", severity)
+		}
+	}
+
+	if len(q.CriticalConfigRules) > 0 && q.ConfigAggregatorName == "" {
+		return fmt.Errorf("a Config aggregator is required to evaluate critical Config rules")
+	}
+
+	for _, id := range q.ExemptAccountIds {
+		if !isValidAccountId(id) {
+			return fmt.Errorf("invalid exempt account ID: %s", id)
+		}
+	}
+
+	return nil
+}
+
+// validateInvitationConfig validates the invitations of existing accounts
+func (c *OrganizationConfig) validateInvitationConfig() error {
+	emailRegex := regexp.MustCompile(EmailRegexPattern)
+	seen := make(map[string]bool)
+
+	for _, invitation := range c.LandingZoneConfig.Invitations {
+		target := invitation.AccountId
+		switch {
+		case invitation.AccountId != "" && invitation.Email != "":
+			return fmt.Errorf("invitation of %s must set either an account ID or an email", invitation.AccountId)
+		case invitation.AccountId != "":
+			if !isValidAccountId(invitation.AccountId) {
+				return fmt.Errorf("invalid invited account ID: %s", invitation.AccountId)
+			}
+		case invitation.Email != "":
+			if !emailRegex.MatchString(invitation.Email) {
+				return fmt.Errorf("invalid invited account email: %s", invitation.Email)
+			}
+			target = invitation.Email
+		default:
+			return fmt.Errorf("an invitation requires an account ID or an email")
+		}
+
+		if seen[target] {
+			return fmt.Errorf("account %s is invited more than once", target)
+		}
+		seen[target] = true
+
+		if _, ok := c.LandingZoneConfig.OrganizationUnits[invitation.TargetOU]; !ok {
+			return fmt.Errorf("target OU %q of invited account %s is not configured", invitation.TargetOU, target)
+		}
+	}
+
+	return nil
+}
+
+// validateParameterSharingConfig validates the accounts the /organization parameters
+// are shared with
+func (c *OrganizationConfig) validateParameterSharingConfig() error {
+	sharing := c.LandingZoneConfig.ParameterSharing
+	if sharing == nil {
+		return nil
+	}
+
+	if len(sharing.AccountIds) == 0 {
+		return fmt.Errorf("at least one account is required to share the organization parameters with")
+	}
+	if !isValidAccountId(c.LandingZoneConfig.ManagementAccountId) {
+		return fmt.Errorf("a valid management account ID is required to share the organization parameters")
+	}
+
+	seen := make(map[string]bool)
+	for _, id := range sharing.AccountIds {
+		if !isValidAccountId(id) {
+			return fmt.Errorf("invalid account ID %s to share the organization parameters with", id)
+		}
+		if id == c.LandingZoneConfig.ManagementAccountId {
+			return fmt.Errorf("the organization parameters cannot be shared with the management account owning them")
+		}
+		if seen[id] {
+			return fmt.Errorf("organization parameters are shared with account %s more than once", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// validateHookConfig validates the post-provision hooks of every OU
+func (c *OrganizationConfig) validateHookConfig() error {
+	for ouName, ou := range c.LandingZoneConfig.OrganizationUnits {
+		if ou == nil {
+			continue
+		}
+
+		names := make(map[string]bool)
+		for _, hook := range ou.Hooks {
+			if !hookNameRE.MatchString(hook.Name) {
+				return fmt.Errorf("invalid hook name %q in OU %s", hook.Name, ouName)
+			}
+			if names[hook.Name] {
+				return fmt.Errorf("duplicate hook %s in OU %s", hook.Name, ouName)
+			}
+			names[hook.Name] = true
+
+			switch hook.Type {
+			case HookTypeSSMAutomation:
+				if hook.DocumentName == "" {
+					return fmt.Errorf("hook %s in OU %s requires a document name", hook.Name, ouName)
+				}
+			case HookTypeStepFunctions:
+				if hook.StateMachineArn == "" {
+					return fmt.Errorf("hook %s in OU %s requires a state machine ARN", hook.Name, ouName)
+				}
+			default:
+				return fmt.Errorf("invalid type %q of hook %s in OU %s", hook.Type, hook.Name, ouName)
+			}
+
+			for key, value := range hook.Parameters {
+				if _, err := template.New(key).Parse(value); err != nil {
+					return fmt.Errorf("invalid template of parameter %s of hook %s: %w", key, hook.Name, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateDriftConfig validates the StackSet drift detection settings
+func (c *OrganizationConfig) validateDriftConfig() error {
+	d := c.LandingZoneConfig.Drift
+	if d == nil {
+		return nil
+	}
+
+	for _, prefix := range d.StackSetPrefixes {
+		if prefix == "" {
+			return fmt.Errorf("StackSet prefixes must not be empty")
+		}
+	}
+
+	if d.TimeoutMinutes < 0 {
+		return fmt.Errorf("drift detection timeout must not be negative")
+	}
+
+	for _, rule := range d.PlacementRules {
+		if rule.TagKey == "" {
+			return fmt.Errorf("placement rules require a tag key")
+		}
+		if _, ok := c.LandingZoneConfig.OrganizationUnits[rule.OU]; !ok {
+			return fmt.Errorf("OU %q of the placement rule for tag %s is not configured", rule.OU, rule.TagKey)
+		}
+	}
+
+	if len(d.ResetApprovers) > 0 && c.LandingZoneConfig.Mail == nil {
+		return fmt.Errorf("landing zone reset approvers require the mail configuration")
+	}
+	if len(d.ResetApprovers) > 0 && (d.ResetApprovalPublicKey == "" || d.ResetApprovalKeySecret == "") {
+		return fmt.Errorf("landing zone reset approvers require the approval public key and key secret")
+	}
+	if d.ResetApprovalPublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(d.ResetApprovalPublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("landing zone reset approval public key is not a base64 Ed25519 public key")
+		}
+	}
+	emailRegex := regexp.MustCompile(EmailRegexPattern)
+	for _, approver := range d.ResetApprovers {
+		if !emailRegex.MatchString(approver) {
+			return fmt.Errorf("landing zone reset approver %q is not an email address", approver)
+		}
+	}
+
+	return nil
+}
+
+// BackupRetention returns the number of days backups are kept
+func (c *LandingZoneConfig) BackupRetention() int {
+	if c.BackupRetentionDays == 0 {
+		return BackupRetentionDays
+	}
+	return c.BackupRetentionDays
+}
+
+// validateStateConfig validates the state backend and the KMS key the state is
+// encrypted with
+func (c *OrganizationConfig) validateStateConfig() error {
+	lz := c.LandingZoneConfig
+	switch lz.StateBackend {
+	case "", StateBackendDynamoDB, StateBackendS3, StateBackendLocal, StateBackendNone:
+	default:
+		return fmt.Errorf("invalid state backend %q", lz.StateBackend)
+	}
+
+	if lz.StateFilePath != "" && lz.StateBackend != StateBackendLocal {
+		return fmt.Errorf("a state file path is only used by the %s backend", StateBackendLocal)
+	}
+	if lz.StateTableName != "" && !stateTableNameRE.MatchString(lz.StateTableName) {
+		return fmt.Errorf("invalid state table name %q", lz.StateTableName)
+	}
+	if lz.StateBucketName != "" && !stateBucketNameRE.MatchString(lz.StateBucketName) {
+		return fmt.Errorf("invalid state bucket name %q", lz.StateBucketName)
+	}
+	if lz.BackupRetentionDays < 0 || lz.BackupRetentionDays > MaxBackupRetentionDays {
+		return fmt.Errorf("backup retention must be between 0 and %d days", MaxBackupRetentionDays)
+	}
+
+	keyArn := lz.StateKMSKeyArn
+	if keyArn == "" {
+		return nil
+	}
+
+	parts := strings.SplitN(keyArn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "kms" ||
+		!(strings.HasPrefix(parts[5], "key/") || strings.HasPrefix(parts[5], "alias/")) {
+		return fmt.Errorf("invalid state KMS key ARN: %s", keyArn)
+	}
+
+	return nil
+}
+
+// validateBaselineConfig validates the account alias template and password policy
+func (c *OrganizationConfig) validateBaselineConfig() error {
+	b := c.LandingZoneConfig.Baseline
+	if b == nil {
+		return nil
+	}
+
+	if b.AccountAliasTemplate != "" {
+		if _, err := template.New("alias").Parse(b.AccountAliasTemplate); err != nil {
+			return fmt.Errorf("invalid account alias template: %w", err)
+		}
+	}
+
+	if err := validateContacts(b.Contacts); err != nil {
+		return err
+	}
+
+	if err := c.validateOptInRegions(); err != nil {
+		return err
+	}
+
+	if err := c.validateBaselineDelivery(); err != nil {
+		return err
+	}
+
+	if err := validateRootActivityAlarm(b.RootActivityAlarm); err != nil {
+		return err
+	}
+
+	if err := c.validateServiceQuotas(); err != nil {
+		return err
+	}
+
+	for id, override := range b.AccountOverrides {
+		if !isValidAccountId(id) {
+			return fmt.Errorf("invalid baseline override account ID: %s", id)
+		}
+		if override == nil {
+			continue
+		}
+		if err := validateContacts(override.Contacts); err != nil {
+			return fmt.Errorf("account %s: %w", id, err)
+		}
+	}
+
+	if p := b.PasswordPolicy; p != nil {
+		if p.MinimumPasswordLength < MinPasswordLength || p.MinimumPasswordLength > MaxPasswordLength {
+			return fmt.Errorf("minimum password length must be between %d and %d",
+				MinPasswordLength, MaxPasswordLength)
+		}
+		if p.MaxPasswordAge < 0 || p.MaxPasswordAge > MaxPasswordAgeDays {
+			return fmt.Errorf("maximum password age must be between 0 and %d days", MaxPasswordAgeDays)
+		}
+		if p.PasswordReusePrevention < 0 || p.PasswordReusePrevention > MaxPasswordReusePrevention {
+			return fmt.Errorf("password reuse prevention must be between 0 and %d",
+				MaxPasswordReusePrevention)
+		}
+	}
+
+	return nil
+}
+
+// validateContacts validates the primary and alternate contacts of an account
+func validateContacts(c *ContactsConfig) error {
+	if c == nil {
+		return nil
+	}
+
+	if p := c.Primary; p != nil {
+		if p.FullName == "" || p.AddressLine1 == "" || p.City == "" || p.PostalCode == "" || p.PhoneNumber == "" {
+			return fmt.Errorf("the primary contact requires a full name, address, city, postal code and phone number")
+		}
+		if len(p.CountryCode) != 2 {
+			return fmt.Errorf("invalid primary contact country code %q, must be an ISO-3166 two-letter code", p.CountryCode)
+		}
+	}
+
+	emailRegex := regexp.MustCompile(EmailRegexPattern)
+	alternates := []struct {
+		kind    string
+		contact *AlternateContactConfig
+	}{
+		{"billing", c.Billing},
+		{"operations", c.Operations},
+		{"security", c.Security},
+	}
+	for _, alternate := range alternates {
+		if alternate.contact == nil {
+			continue
+		}
+		if alternate.contact.Name == "" || alternate.contact.Title == "" || alternate.contact.PhoneNumber == "" {
+			return fmt.Errorf("the %s contact requires a name, title and phone number", alternate.kind)
+		}
+		if !emailRegex.MatchString(alternate.contact.Email) {
+			return fmt.Errorf("invalid %s contact email: %s", alternate.kind, alternate.contact.Email)
+		}
+	}
+
+	return nil
+}
+
 // isValidAccountId validates AWS account ID format
 func isValidAccountId(id string) bool {
 	if len(id) != 12 {
@@ -311,6 +1174,29 @@ func (c *OrganizationConfig) Restore(version string) error {
 	return nil
 }
 
+// Replace replaces the contents of the configuration with those of other, such as the
+// configuration of a state version rolled back to. The logger and metrics are kept.
+func (c *OrganizationConfig) Replace(other *OrganizationConfig) {
+	if c == other {
+		return
+	}
+
+	other.mutex.RLock()
+	defer other.mutex.RUnlock()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.Version = other.Version
+	c.AWSProfile = other.AWSProfile
+	c.Partition = other.Partition
+	c.Region = other.Region
+	c.LandingZoneConfig = other.LandingZoneConfig
+	c.Organizations = other.Organizations
+	c.Selected = other.Selected
+	c.Environments = other.Environments
+	c.Environment = other.Environment
+}
+
 // DefaultConfig provides default configuration values
 var DefaultConfig = OrganizationConfig{
 	Version: ConfigVersion,
@@ -353,6 +1239,7 @@ type OUConfig struct {
 	Description string            `json:"description,omitempty"`
 	Tags        map[string]string `json:"tags,omitempty"`
 	Accounts    []AccountConfig   `json:"accounts,omitempty"`
+	Hooks       []HookConfig      `json:"hooks,omitempty"`
 }
 
 type AccountConfig struct {
@@ -360,6 +1247,237 @@ type AccountConfig struct {
 	Email   string            `json:"email"`
 	Tags    map[string]string `json:"tags,omitempty"`
 	RoleArn string            `json:"roleArn,omitempty"`
+
+	// Attributes are the values of the custom account attributes
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// HookConfig defines an SSM Automation document or Step Functions state machine run once
+// after an account is created in an OU. Parameter values are Go templates rendered with
+// the details of the new account.
+type HookConfig struct {
+	Name            string            `json:"name"`
+	Type            string            `json:"type"`
+	DocumentName    string            `json:"documentName,omitempty"`
+	StateMachineArn string            `json:"stateMachineArn,omitempty"`
+	Parameters      map[string]string `json:"parameters,omitempty"`
+	Region          string            `json:"region,omitempty"`
+	RunInAccount    bool              `json:"runInAccount,omitempty"`
+}
+
+// BaselineConfig defines the baseline of member accounts. The account alias template is
+// rendered with AccountId and AccountName. EBS default encryption is enabled in Regions,
+// or in every governed region when Regions is empty.
+type BaselineConfig struct {
+	AccountAliasTemplate string                `json:"accountAliasTemplate,omitempty"`
+	PasswordPolicy       *PasswordPolicyConfig `json:"passwordPolicy,omitempty"`
+
+	EBSEncryptionByDefault bool                                `json:"ebsEncryptionByDefault"`
+	S3BlockPublicAccess    bool                                `json:"s3BlockPublicAccess"`
+	Regions                []string                            `json:"regions,omitempty"`
+	Contacts               *ContactsConfig                     `json:"contacts,omitempty"`
+	OptInRegions           *OptInRegionsConfig                 `json:"optInRegions,omitempty"`
+	AccountOverrides       map[string]*AccountBaselineOverride `json:"accountOverrides,omitempty"`
+
+	// Alarm on any activity of the root user of each account
+	RootActivityAlarm *RootActivityAlarmConfig `json:"rootActivityAlarm,omitempty"`
+
+	// Delivery mechanism of each resource baseline, pulumi or stackset
+	Delivery map[string]string `json:"delivery,omitempty"`
+
+	// Service quotas increased in the accounts when they are vended
+	ServiceQuotas []ServiceQuotaTarget `json:"serviceQuotas,omitempty"`
+}
+
+// OptInRegionsConfig defines the opt-in regions enabled and disabled in every account.
+// Governed opt-in regions are always enabled.
+type OptInRegionsConfig struct {
+	Enabled  []string `json:"enabled,omitempty"`
+	Disabled []string `json:"disabled,omitempty"`
+}
+
+// AccountBaselineOverride overrides the resource baseline toggles for a single account.
+// Unset fields keep the organization-wide value; each contact set here replaces the
+// organization-wide contact of the same type.
+type AccountBaselineOverride struct {
+	EBSEncryptionByDefault *bool           `json:"ebsEncryptionByDefault,omitempty"`
+	S3BlockPublicAccess    *bool           `json:"s3BlockPublicAccess,omitempty"`
+	Regions                []string        `json:"regions,omitempty"`
+	Contacts               *ContactsConfig `json:"contacts,omitempty"`
+	RootActivityAlarm      *bool           `json:"rootActivityAlarm,omitempty"`
+}
+
+// ContactsConfig defines the primary contact and the alternate contacts of an account,
+// managed through the Account Management API
+type ContactsConfig struct {
+	Primary    *PrimaryContactConfig   `json:"primary,omitempty"`
+	Billing    *AlternateContactConfig `json:"billing,omitempty"`
+	Operations *AlternateContactConfig `json:"operations,omitempty"`
+	Security   *AlternateContactConfig `json:"security,omitempty"`
+}
+
+// PrimaryContactConfig defines the primary contact information of an account
+type PrimaryContactConfig struct {
+	FullName         string `json:"fullName"`
+	CompanyName      string `json:"companyName,omitempty"`
+	AddressLine1     string `json:"addressLine1"`
+	AddressLine2     string `json:"addressLine2,omitempty"`
+	AddressLine3     string `json:"addressLine3,omitempty"`
+	City             string `json:"city"`
+	StateOrRegion    string `json:"stateOrRegion,omitempty"`
+	DistrictOrCounty string `json:"districtOrCounty,omitempty"`
+	PostalCode       string `json:"postalCode"`
+	CountryCode      string `json:"countryCode"`
+	PhoneNumber      string `json:"phoneNumber"`
+	WebsiteUrl       string `json:"websiteUrl,omitempty"`
+}
+
+// AlternateContactConfig defines a billing, operations or security contact of an account
+type AlternateContactConfig struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phoneNumber"`
+}
+
+// PasswordPolicyConfig defines the IAM password policy of member accounts
+type PasswordPolicyConfig struct {
+	MinimumPasswordLength      int  `json:"minimumPasswordLength"`
+	RequireSymbols             bool `json:"requireSymbols"`
+	RequireNumbers             bool `json:"requireNumbers"`
+	RequireUppercaseCharacters bool `json:"requireUppercaseCharacters"`
+	RequireLowercaseCharacters bool `json:"requireLowercaseCharacters"`
+	AllowUsersToChangePassword bool `json:"allowUsersToChangePassword"`
+	MaxPasswordAge             int  `json:"maxPasswordAge,omitempty"`
+	PasswordReusePrevention    int  `json:"passwordReusePrevention,omitempty"`
+	HardExpiry                 bool `json:"hardExpiry"`
+}
+
+// InvitationConfig defines an existing account invited to join the organization, by
+// account ID or by the email address of its root user, and the OU it is placed in once
+// it accepts the invitation
+type InvitationConfig struct {
+	AccountId string `json:"accountId,omitempty"`
+	Email     string `json:"email,omitempty"`
+	TargetOU  string `json:"targetOu"`
+	Notes     string `json:"notes,omitempty"`
+}
+
+// ParameterSharingConfig defines the tooling accounts allowed to read the /organization
+// parameters of the management account. Shared parameters use the advanced tier and a
+// dedicated KMS key the accounts may decrypt with.
+type ParameterSharingConfig struct {
+	AccountIds []string `json:"accountIds"`
+}
+
+// QuarantineConfig defines the automated quarantine of non-compliant accounts
+type QuarantineConfig struct {
+	Enabled              bool     `json:"enabled"`
+	OUName               string   `json:"ouName,omitempty"`
+	Severities           []string `json:"severities,omitempty"`
+	ConfigAggregatorName string   `json:"configAggregatorName,omitempty"`
+	CriticalConfigRules  []string `json:"criticalConfigRules,omitempty"`
+	NotificationTopicArn string   `json:"notificationTopicArn,omitempty"`
+	ExemptAccountIds     []string `json:"exemptAccountIds,omitempty"`
+}
+
+// LogArchiveConfig defines Object Lock and Glacier archival of the log archive bucket
+type LogArchiveConfig struct {
+	ObjectLockEnabled         bool   `json:"objectLockEnabled"`
+	ObjectLockMode            string `json:"objectLockMode,omitempty"`
+	ObjectLockRetentionDays   int    `json:"objectLockRetentionDays,omitempty"`
+	DeepArchiveTransitionDays int    `json:"deepArchiveTransitionDays,omitempty"`
+}
+
+// MacieConfig defines the sensitive data discovery of the log archive buckets
+type MacieConfig struct {
+	Schedule                   string `json:"schedule,omitempty"`
+	WeeklyDay                  string `json:"weeklyDay,omitempty"`
+	MonthlyDay                 int    `json:"monthlyDay,omitempty"`
+	SamplingPercentage         int    `json:"samplingPercentage,omitempty"`
+	FindingPublishingFrequency string `json:"findingPublishingFrequency,omitempty"`
+	PublishToSecurityHub       bool   `json:"publishToSecurityHub"`
+}
+
+// GuardDutyConfig defines the organization-wide GuardDuty settings applied from the
+// security account
+type GuardDutyConfig struct {
+	FindingPublishingFrequency string                  `json:"findingPublishingFrequency,omitempty"`
+	Export                     *GuardDutyExportConfig  `json:"export,omitempty"`
+	SuppressionRules           []GuardDutyFilterConfig `json:"suppressionRules,omitempty"`
+}
+
+// GuardDutyExportConfig defines the bucket of the log archive account GuardDuty findings
+// are exported to. Findings are encrypted with a dedicated KMS key.
+type GuardDutyExportConfig struct {
+	BucketName string `json:"bucketName"`
+	Prefix     string `json:"prefix,omitempty"`
+}
+
+// GuardDutyExportBucket returns the bucket GuardDuty findings are exported to, named by
+// the naming template when the configuration leaves it out
+func (c *LandingZoneConfig) GuardDutyExportBucket() string {
+	if c.GuardDuty == nil || c.GuardDuty.Export == nil {
+		return ""
+	}
+	if name := c.GuardDuty.Export.BucketName; name != "" {
+		return name
+	}
+	return c.ResourceNames().Name(BucketGuardDutyExport, c.LogBucketRegion())
+}
+
+// GuardDutyFilterConfig defines a filter applied to the findings of every account. Rules
+// with the ARCHIVE action suppress the matching findings.
+type GuardDutyFilterConfig struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description,omitempty"`
+	Action      string                     `json:"action,omitempty"`
+	Rank        int                        `json:"rank,omitempty"`
+	Criteria    []GuardDutyCriterionConfig `json:"criteria"`
+}
+
+// GuardDutyCriterionConfig defines a condition on a finding field, for example
+// {"field": "type", "equals": ["Recon:EC2/PortProbeUnprotectedPort"]}
+type GuardDutyCriterionConfig struct {
+	Field              string   `json:"field"`
+	Equals             []string `json:"equals,omitempty"`
+	NotEquals          []string `json:"notEquals,omitempty"`
+	GreaterThan        string   `json:"greaterThan,omitempty"`
+	GreaterThanOrEqual string   `json:"greaterThanOrEqual,omitempty"`
+	LessThan           string   `json:"lessThan,omitempty"`
+	LessThanOrEqual    string   `json:"lessThanOrEqual,omitempty"`
+}
+
+// DriftConfig defines the StackSets checked for drift. When no prefix is configured the
+// baseline StackSets of Control Tower are checked. Accounts missing from the
+// configuration are reported with the OU suggested by the placement tag, whose value
+// names an OU, or by the first matching placement rule. ResetApprovers are emailed an
+// approval request before a drifted Control Tower landing zone is reset, and approve it
+// by signing its drift with the private key stored in ResetApprovalKeySecret, which
+// ResetApprovalPublicKey verifies.
+type DriftConfig struct {
+	StackSetPrefixes       []string        `json:"stackSetPrefixes,omitempty"`
+	TimeoutMinutes         int             `json:"timeoutMinutes,omitempty"`
+	PlacementTagKey        string          `json:"placementTagKey,omitempty"`
+	PlacementRules         []PlacementRule `json:"placementRules,omitempty"`
+	ResetApprovers         []string        `json:"resetApprovers,omitempty"`
+	ResetApprovalPublicKey string          `json:"resetApprovalPublicKey,omitempty"`
+	ResetApprovalKeySecret string          `json:"resetApprovalKeySecret,omitempty"`
+}
+
+// PlacementRule suggests an OU for accounts carrying a tag. An empty value matches any
+// value of the tag.
+type PlacementRule struct {
+	TagKey   string `json:"tagKey"`
+	TagValue string `json:"tagValue,omitempty"`
+	OU       string `json:"ou"`
+}
+
+// ComplianceConfig defines the settings of the compliance checks run against member accounts
+type ComplianceConfig struct {
+	MemberRoleName   string `json:"memberRoleName,omitempty"`
+	ReadOnlyRoleName string `json:"readOnlyRoleName,omitempty"`
+	StaleUserDays    int    `json:"staleUserDays,omitempty"`
 }
 
 type Subnet struct {
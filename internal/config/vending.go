@@ -0,0 +1,73 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Defaults of the account vending state machine
+const (
+	DefaultVendingStateMachineName = "landing-zone-account-vending"
+	DefaultVendingHeartbeatSeconds = 300
+	DefaultVendingTimeoutMinutes   = 120
+)
+
+var stateMachineNameRE = regexp.MustCompile(`^[A-Za-z0-9_-]{1,60}$`)
+
+// VendingConfig enables the Step Functions orchestration of account vending. The
+// state machine runs the create, enroll, baseline and verify steps of each account as
+// activities, which the vending worker of this tool performs. HeartbeatSeconds is the
+// time a step may go without a heartbeat from the worker, and TimeoutMinutes the time
+// a step may take.
+type VendingConfig struct {
+	StateMachineName string `json:"stateMachineName,omitempty"`
+	HeartbeatSeconds int    `json:"heartbeatSeconds,omitempty"`
+	TimeoutMinutes   int    `json:"timeoutMinutes,omitempty"`
+}
+
+// Name returns the name of the state machine, prefixing its activities
+func (v *VendingConfig) Name() string {
+	if v.StateMachineName == "" {
+		return DefaultVendingStateMachineName
+	}
+	return v.StateMachineName
+}
+
+// Heartbeat returns the heartbeat interval of a step in seconds
+func (v *VendingConfig) Heartbeat() int {
+	if v.HeartbeatSeconds == 0 {
+		return DefaultVendingHeartbeatSeconds
+	}
+	return v.HeartbeatSeconds
+}
+
+// Timeout returns the timeout of a step in seconds
+func (v *VendingConfig) Timeout() int {
+	if v.TimeoutMinutes == 0 {
+		return DefaultVendingTimeoutMinutes * 60
+	}
+	return v.TimeoutMinutes * 60
+}
+
+// validateVendingConfig validates the account vending state machine
+func (c *OrganizationConfig) validateVendingConfig() error {
+	v := c.LandingZoneConfig.Vending
+	if v == nil {
+		return nil
+	}
+
+	if v.StateMachineName != "" && !stateMachineNameRE.MatchString(v.StateMachineName) {
+		return fmt.Errorf("invalid vending state machine name %q", v.StateMachineName)
+	}
+	if v.HeartbeatSeconds < 0 || v.TimeoutMinutes < 0 {
+		return fmt.Errorf("vending heartbeat and timeout cannot be negative")
+	}
+	if v.Heartbeat() >= v.Timeout() {
+		return fmt.Errorf("vending heartbeat must be shorter than the step timeout")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Lifecycle states of an account, in the order an account goes through them
+const (
+	AccountStateRequested = "requested"
+	AccountStateCreating  = "creating"
+	AccountStateActive    = "active"
+	AccountStateSuspended = "suspended"
+	AccountStateClosed    = "closed"
+)
+
+// AccountStates lists the lifecycle states of an account
+var AccountStates = []string{
+	AccountStateRequested,
+	AccountStateCreating,
+	AccountStateActive,
+	AccountStateSuspended,
+	AccountStateClosed,
+}
+
+var (
+	envNameRE    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	secretNameRE = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// WebhookConfig defines an endpoint notified when an account changes lifecycle state.
+// Deliveries are signed with HMAC-SHA256 when the environment variable named by
+// SecretEnv holds a secret, or with a secret generated in the secret store when
+// GenerateSecret is set. States limits the notifications to transitions into the
+// listed states.
+type WebhookConfig struct {
+	Name           string   `json:"name"`
+	URL            string   `json:"url"`
+	SecretEnv      string   `json:"secretEnv,omitempty"`
+	GenerateSecret bool     `json:"generateSecret,omitempty"`
+	States         []string `json:"states,omitempty"`
+}
+
+// SecretName returns the name of the generated secret of the webhook in the secret store
+func (w WebhookConfig) SecretName() string {
+	return fmt.Sprintf("webhooks/%s", w.Name)
+}
+
+// validateWebhooks validates the account lifecycle webhooks
+func (c *OrganizationConfig) validateWebhooks() error {
+	names := make(map[string]bool)
+	for _, webhook := range c.LandingZoneConfig.Webhooks {
+		if webhook.Name == "" {
+			return fmt.Errorf("webhook name cannot be empty")
+		}
+		if names[webhook.Name] {
+			return fmt.Errorf("duplicate webhook %s", webhook.Name)
+		}
+		names[webhook.Name] = true
+
+		u, err := url.Parse(webhook.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("webhook %s requires an http or https URL", webhook.Name)
+		}
+		if webhook.SecretEnv != "" && !envNameRE.MatchString(webhook.SecretEnv) {
+			return fmt.Errorf("webhook %s has an invalid secret environment variable %q", webhook.Name, webhook.SecretEnv)
+		}
+		if webhook.SecretEnv != "" && webhook.GenerateSecret {
+			return fmt.Errorf("webhook %s cannot both read its secret from %s and generate it", webhook.Name, webhook.SecretEnv)
+		}
+		if webhook.GenerateSecret && !secretNameRE.MatchString(webhook.Name) {
+			return fmt.Errorf("webhook %s generating its secret must be named with letters, digits, '.', '_' and '-'", webhook.Name)
+		}
+
+		for _, state := range webhook.States {
+			switch state {
+			case AccountStateRequested, AccountStateCreating, AccountStateActive, AccountStateSuspended, AccountStateClosed:
+			default:
+				return fmt.Errorf("webhook %s has an unsupported state %q, must be one of: %s",
+					webhook.Name, state, strings.Join(AccountStates, ", "))
+			}
+		}
+	}
+	return nil
+}
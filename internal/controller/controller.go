@@ -0,0 +1,430 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package controller renders the manifests running the vending worker as a controller in the management account.
+// Version: 1.0.0
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/probe"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/vending"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the manifests
+const (
+	FormatKubernetes = "kubernetes"
+	FormatECS        = "ecs"
+)
+
+// Defaults of the manifests
+const (
+	DefaultName      = "aws-organization-worker"
+	DefaultNamespace = "landing-zone"
+	DefaultProbePort = 8080
+	DefaultCPU       = 512
+	DefaultMemory    = 1024
+)
+
+const (
+	// Annotation binding a Kubernetes service account to an IAM role (IRSA)
+	roleArnAnnotation = "eks.amazonaws.com/role-arn"
+
+	// Time the orchestrator gives the worker on top of the drain timeout before it
+	// kills it
+	stopMargin = 15 * time.Second
+
+	// ECS kills a container at most two minutes after stopping it
+	maxECSStopTimeout = 120 * time.Second
+
+	// Liveness probe settings: a stalled poller is reported after three minutes,
+	// so the probe does not need to be aggressive
+	probePeriodSeconds    = 30
+	probeFailureThreshold = 3
+)
+
+// Options describe the deployment of the worker
+type Options struct {
+	Format    string
+	Image     string
+	Name      string
+	Namespace string
+
+	// RoleArn is the role of the worker: the IRSA role of the service account, or the
+	// task role. ExecutionRoleArn pulls the image and writes the logs of an ECS task.
+	RoleArn          string
+	ExecutionRoleArn string
+
+	// LogGroup receives the logs of an ECS task, in Region
+	LogGroup string
+	Region   string
+
+	ProbePort    int
+	DrainTimeout time.Duration
+
+	// Env is passed to the worker, such as the selected organization and environment
+	Env map[string]string
+}
+
+// Validate checks the options and fills in the defaults
+func (o *Options) Validate() error {
+	if o.Format != FormatKubernetes && o.Format != FormatECS {
+		return fmt.Errorf("unknown manifest format %q, use %s or %s", o.Format, FormatKubernetes, FormatECS)
+	}
+	if o.Image == "" {
+		return fmt.Errorf("no container image specified")
+	}
+	if o.Name == "" {
+		o.Name = DefaultName
+	}
+	if o.Namespace == "" {
+		o.Namespace = DefaultNamespace
+	}
+	if o.ProbePort == 0 {
+		o.ProbePort = DefaultProbePort
+	}
+	if o.ProbePort < 1 || o.ProbePort > 65535 {
+		return fmt.Errorf("invalid probe port %d", o.ProbePort)
+	}
+	if o.DrainTimeout <= 0 {
+		o.DrainTimeout = vending.DefaultDrainTimeout
+	}
+	if o.LogGroup == "" {
+		o.LogGroup = "/ecs/" + o.Name
+	}
+
+	if o.Format == FormatECS {
+		if o.RoleArn == "" || o.ExecutionRoleArn == "" {
+			return fmt.Errorf("an ECS task definition needs a task role and an execution role")
+		}
+		if o.Region == "" {
+			return fmt.Errorf("an ECS task definition needs the region of its log group")
+		}
+		if o.DrainTimeout+stopMargin > maxECSStopTimeout {
+			return fmt.Errorf("drain timeout %s leaves no time to stop within the %s ECS allows",
+				o.DrainTimeout, maxECSStopTimeout)
+		}
+	}
+	return nil
+}
+
+// args returns the command line of the worker
+func (o *Options) args() []string {
+	return []string{
+		"vending", "worker",
+		"--probe-addr", fmt.Sprintf(":%d", o.ProbePort),
+		"--drain-timeout", o.DrainTimeout.String(),
+	}
+}
+
+// envNames returns the names of the environment variables of the worker, sorted
+func (o *Options) envNames() []string {
+	names := make([]string, 0, len(o.Env))
+	for name, value := range o.Env {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stopTimeout returns the time the worker is given to stop
+func (o *Options) stopTimeout() int {
+	return int((o.DrainTimeout + stopMargin).Seconds())
+}
+
+// Render writes the manifests of the worker in the format of the options
+func Render(w io.Writer, o *Options) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if o.Format == FormatECS {
+		return renderECS(w, o)
+	}
+	return renderKubernetes(w, o)
+}
+
+// renderKubernetes writes the namespace, service account and deployment of the
+// worker. One replica is enough: a step abandoned by a stopped worker is retried once
+// its heartbeat times out.
+func renderKubernetes(w io.Writer, o *Options) error {
+	labels := map[string]interface{}{"app.kubernetes.io/name": o.Name}
+
+	serviceAccount := map[string]interface{}{
+		"name":      o.Name,
+		"namespace": o.Namespace,
+		"labels":    labels,
+	}
+	if o.RoleArn != "" {
+		serviceAccount["annotations"] = map[string]interface{}{roleArnAnnotation: o.RoleArn}
+	}
+
+	env := make([]map[string]interface{}, 0, len(o.Env))
+	for _, name := range o.envNames() {
+		env = append(env, map[string]interface{}{"name": name, "value": o.Env[name]})
+	}
+
+	probeFor := func(path string) map[string]interface{} {
+		return map[string]interface{}{
+			"httpGet":          map[string]interface{}{"path": path, "port": "probes"},
+			"periodSeconds":    probePeriodSeconds,
+			"failureThreshold": probeFailureThreshold,
+		}
+	}
+
+	container := map[string]interface{}{
+		"name":           "worker",
+		"image":          o.Image,
+		"args":           o.args(),
+		"ports":          []map[string]interface{}{{"name": "probes", "containerPort": o.ProbePort}},
+		"livenessProbe":  probeFor(probe.PathHealth),
+		"readinessProbe": probeFor(probe.PathReady),
+	}
+	if len(env) > 0 {
+		container["env"] = env
+	}
+
+	documents := []map[string]interface{}{
+		{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   map[string]interface{}{"name": o.Namespace},
+		},
+		{
+			"apiVersion": "v1",
+			"kind":       "ServiceAccount",
+			"metadata":   serviceAccount,
+		},
+		{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":      o.Name,
+				"namespace": o.Namespace,
+				"labels":    labels,
+			},
+			"spec": map[string]interface{}{
+				"replicas": 1,
+				"selector": map[string]interface{}{"matchLabels": labels},
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{"labels": labels},
+					"spec": map[string]interface{}{
+						"serviceAccountName":            o.Name,
+						"terminationGracePeriodSeconds": o.stopTimeout(),
+						"containers":                    []map[string]interface{}{container},
+					},
+				},
+			},
+		},
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	for _, document := range documents {
+		if err := encoder.Encode(document); err != nil {
+			return fmt.Errorf("failed to render kubernetes manifests: %w", err)
+		}
+	}
+	return encoder.Close()
+}
+
+// renderECS writes the Fargate task definition of the worker. The container health
+// check calls the liveness probe with wget, which the image must provide.
+func renderECS(w io.Writer, o *Options) error {
+	env := make([]map[string]interface{}, 0, len(o.Env))
+	for _, name := range o.envNames() {
+		env = append(env, map[string]interface{}{"name": name, "value": o.Env[name]})
+	}
+
+	healthURL := fmt.Sprintf("http://localhost:%d%s", o.ProbePort, probe.PathHealth)
+	taskDefinition := map[string]interface{}{
+		"family":                  o.Name,
+		"taskRoleArn":             o.RoleArn,
+		"executionRoleArn":        o.ExecutionRoleArn,
+		"networkMode":             "awsvpc",
+		"requiresCompatibilities": []string{"FARGATE"},
+		"cpu":                     strconv.Itoa(DefaultCPU),
+		"memory":                  strconv.Itoa(DefaultMemory),
+		"containerDefinitions": []map[string]interface{}{{
+			"name":         "worker",
+			"image":        o.Image,
+			"essential":    true,
+			"command":      o.args(),
+			"environment":  env,
+			"stopTimeout":  o.stopTimeout(),
+			"portMappings": []map[string]interface{}{{"containerPort": o.ProbePort, "protocol": "tcp"}},
+			"healthCheck": map[string]interface{}{
+				"command":  []string{"CMD-SHELL", "wget -q -O /dev/null " + healthURL + " || exit 1"},
+				"interval": probePeriodSeconds,
+				"retries":  probeFailureThreshold,
+				"timeout":  5,
+			},
+			"logConfiguration": map[string]interface{}{
+				"logDriver": "awslogs",
+				"options": map[string]string{
+					"awslogs-group":         o.LogGroup,
+					"awslogs-region":        o.Region,
+					"awslogs-stream-prefix": "worker",
+				},
+			},
+		}},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(taskDefinition); err != nil {
+		return fmt.Errorf("failed to render ECS task definition: %w", err)
+	}
+	return nil
+}
+
+// PermissionsPolicy returns the IAM policy the role of the worker needs: performing
+// the vending activities, creating and moving accounts, and assuming the member and
+// read-only roles of the new accounts to baseline and verify them
+func PermissionsPolicy(cfg *config.LandingZoneConfig) map[string]interface{} {
+	partition := awsclient.Partition()
+
+	activities := make([]string, 0, len(vending.Steps))
+	for _, step := range vending.Steps {
+		activities = append(activities,
+			fmt.Sprintf("arn:%s:states:*:*:activity:%s", partition, vending.ActivityName(cfg.Vending, step)))
+	}
+
+	roles := []string{awsclient.MemberRoleName(cfg)}
+	if readOnly := awsclient.ReadOnlyRoleName(cfg); readOnly != roles[0] {
+		roles = append(roles, readOnly)
+	}
+	roleArns := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleArns = append(roleArns, fmt.Sprintf("arn:%s:iam::*:role/%s", partition, role))
+	}
+
+	return map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Sid":      "DiscoverVending",
+				"Effect":   "Allow",
+				"Action":   []string{"states:ListActivities", "states:ListStateMachines", "sts:GetCallerIdentity"},
+				"Resource": "*",
+			},
+			{
+				"Sid":    "PerformVendingActivities",
+				"Effect": "Allow",
+				"Action": []string{
+					"states:GetActivityTask",
+					"states:SendTaskFailure",
+					"states:SendTaskHeartbeat",
+					"states:SendTaskSuccess",
+				},
+				"Resource": activities,
+			},
+			{
+				"Sid":    "StartVending",
+				"Effect": "Allow",
+				"Action": "states:StartExecution",
+				"Resource": fmt.Sprintf("arn:%s:states:*:*:stateMachine:%s",
+					partition, cfg.Vending.Name()),
+			},
+			{
+				"Sid":    "VendAccounts",
+				"Effect": "Allow",
+				"Action": []string{
+					"organizations:CreateAccount",
+					"organizations:DescribeCreateAccountStatus",
+					"organizations:DescribeOrganizationalUnit",
+					"organizations:ListAccounts",
+					"organizations:ListOrganizationalUnitsForParent",
+					"organizations:ListParents",
+					"organizations:ListRoots",
+					"organizations:MoveAccount",
+				},
+				"Resource": "*",
+			},
+			{
+				"Sid":    "EnrollAccounts",
+				"Effect": "Allow",
+				"Action": []string{
+					"controltower:GetBaselineOperation",
+					"controltower:ListEnabledBaselines",
+					"controltower:ResetEnabledBaseline",
+				},
+				"Resource": "*",
+			},
+			{
+				"Sid":      "CreateOrganizationsServiceLinkedRole",
+				"Effect":   "Allow",
+				"Action":   "iam:CreateServiceLinkedRole",
+				"Resource": "*",
+				"Condition": map[string]interface{}{
+					"StringEquals": map[string]string{"iam:AWSServiceName": "organizations.amazonaws.com"},
+				},
+			},
+			{
+				"Sid":      "AssumeMemberRoles",
+				"Effect":   "Allow",
+				"Action":   "sts:AssumeRole",
+				"Resource": roleArns,
+			},
+		},
+	}
+}
+
+// TrustPolicy returns the trust policy of the role of the worker. Kubernetes
+// service accounts assume it through the OIDC provider of the EKS cluster, such as
+// oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE, in the management account.
+func TrustPolicy(o *Options, accountID, oidcProvider string) (map[string]interface{}, error) {
+	if o.Format == FormatECS {
+		return map[string]interface{}{
+			"Version": "2012-10-17",
+			"Statement": []map[string]interface{}{{
+				"Effect":    "Allow",
+				"Principal": map[string]string{"Service": "ecs-tasks.amazonaws.com"},
+				"Action":    "sts:AssumeRole",
+			}},
+		}, nil
+	}
+
+	if oidcProvider == "" || accountID == "" {
+		return nil, fmt.Errorf("the trust policy of an IRSA role needs the OIDC provider of the cluster and the management account")
+	}
+	oidcProvider = strings.TrimPrefix(oidcProvider, "https://")
+	return map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect": "Allow",
+			"Principal": map[string]string{
+				"Federated": fmt.Sprintf("arn:%s:iam::%s:oidc-provider/%s", awsclient.Partition(), accountID, oidcProvider),
+			},
+			"Action": "sts:AssumeRoleWithWebIdentity",
+			"Condition": map[string]interface{}{
+				"StringEquals": map[string]string{
+					oidcProvider + ":sub": fmt.Sprintf("system:serviceaccount:%s:%s", o.Namespace, o.Name),
+					oidcProvider + ":aud": "sts.amazonaws.com",
+				},
+			},
+		}},
+	}, nil
+}
+
+// WritePolicy writes a policy document as indented JSON
+func WritePolicy(w io.Writer, policy map[string]interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(policy); err != nil {
+		return fmt.Errorf("failed to render policy: %w", err)
+	}
+	return nil
+}
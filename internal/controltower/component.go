@@ -0,0 +1,68 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package controltower
+
+import (
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// LandingZoneComponentType is the type token of the landing zone component
+var LandingZoneComponentType = component.Type("controltower", "LandingZone")
+
+// LandingZoneComponentArgs defines the inputs of the landing zone component
+type LandingZoneComponentArgs struct {
+	// Config describes the landing zone
+	Config *config.LandingZoneConfig
+}
+
+// LandingZoneComponent groups the Control Tower roles and log archive under a single
+// component resource
+type LandingZoneComponent struct {
+	pulumi.ResourceState
+
+	RoleArns            pulumi.StringMapOutput `pulumi:"roleArns"`
+	LogArchiveBucketArn pulumi.StringOutput    `pulumi:"logArchiveBucketArn"`
+}
+
+// NewLandingZoneComponent creates the landing zone as a component resource
+func NewLandingZoneComponent(ctx *pulumi.Context, name string, args *LandingZoneComponentArgs, opts ...pulumi.ResourceOption) (*LandingZoneComponent, error) {
+	if args == nil {
+		return nil, fmt.Errorf("landing zone component arguments cannot be nil")
+	}
+
+	c := &LandingZoneComponent{}
+	if err := ctx.RegisterComponentResource(LandingZoneComponentType, name, c, opts...); err != nil {
+		return nil, fmt.Errorf("failed to register landing zone component: %w", err)
+	}
+
+	lz, err := setupLandingZone(ctx, args.Config, component.ChildOptions(c)...)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := pulumi.StringMap{}
+	for name, role := range lz.roles {
+		roles[name] = role.Arn
+	}
+	c.RoleArns = roles.ToStringMapOutput()
+
+	c.LogArchiveBucketArn = pulumi.String("").ToStringOutput()
+	if lz.logArchive != nil {
+		c.LogArchiveBucketArn = lz.logArchive.Arn
+	}
+
+	if err := ctx.RegisterResourceOutputs(c, pulumi.Map{
+		"roleArns":            c.RoleArns,
+		"logArchiveBucketArn": c.LogArchiveBucketArn,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to register landing zone outputs: %w", err)
+	}
+
+	return c, nil
+}
@@ -13,12 +13,18 @@ import (
 	"sync"
 	"time"
 
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/adopt"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudtrail"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kms"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ssm"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"go.uber.org/zap"
@@ -60,20 +66,23 @@ type LandingZoneService interface {
 
 // LandingZone represents a Control Tower landing zone configuration
 type LandingZone struct {
-	logger   *zap.Logger
-	metrics  *metrics.Collector
-	limiter  *rate.Limiter
-	mutex    sync.RWMutex
-	manifest *config.LandingZoneConfig
-	roles    map[string]*iam.Role
-	kmsKey   *kms.Key
+	logger     *zap.Logger
+	metrics    *metrics.Collector
+	limiter    *rate.Limiter
+	mutex      sync.RWMutex
+	manifest   *config.LandingZoneConfig
+	roles      map[string]*iam.Role
+	kmsKey     *kms.Key
+	logArchive *s3.BucketV2
+	opts       []pulumi.ResourceOption
+	adopter    *adopt.Adopter
 }
 
 // NewLandingZone creates a new landing zone instance
 func NewLandingZone(ctx context.Context) (*LandingZone, error) {
-	logger, err := zap.NewProduction()
+	logger, err := logging.NewLogger("landing-zone")
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+		return nil, err
 	}
 
 	metrics, err := metrics.NewCollector("landing-zone")
@@ -91,10 +100,20 @@ func NewLandingZone(ctx context.Context) (*LandingZone, error) {
 
 // SetupLandingZone configures the Control Tower landing zone
 func SetupLandingZone(ctx *pulumi.Context, org *config.OrganizationSetup, cfg *config.LandingZoneConfig) error {
+	_, err := setupLandingZone(ctx, cfg)
+	return err
+}
+
+// setupLandingZone configures the landing zone, applying the options to every resource
+func setupLandingZone(ctx *pulumi.Context, cfg *config.LandingZoneConfig, opts ...pulumi.ResourceOption) (*LandingZone, error) {
 	start := time.Now()
 	lz, err := NewLandingZone(ctx.Context())
 	if err != nil {
-		return fmt.Errorf("failed to create landing zone: %w", err)
+		return nil, fmt.Errorf("failed to create landing zone: %w", err)
+	}
+	lz.opts = opts
+	if lz.adopter, err = adopt.New(ctx.Context(), cfg); err != nil {
+		return nil, err
 	}
 	defer func() {
 		lz.metrics.RecordDuration("landing_zone_setup", time.Since(start))
@@ -102,9 +121,13 @@ func SetupLandingZone(ctx *pulumi.Context, org *config.OrganizationSetup, cfg *c
 
 	lz.logger.Info("starting landing zone setup")
 
+	if err := readonly.Guard(ctx, "setup landing zone"); err != nil {
+		return nil, err
+	}
+
 	// Validate configuration
 	if err := lz.validateConfig(cfg); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Setup components concurrently
@@ -139,12 +162,12 @@ func SetupLandingZone(ctx *pulumi.Context, org *config.OrganizationSetup, cfg *c
 	// Check for errors
 	for err := range errChan {
 		if err != nil {
-			return fmt.Errorf("landing zone setup failed: %w", err)
+			return nil, fmt.Errorf("landing zone setup failed: %w", err)
 		}
 	}
 
 	lz.logger.Info("landing zone setup completed successfully")
-	return nil
+	return lz, nil
 }
 
 // validateConfig validates the landing zone configuration
@@ -175,8 +198,8 @@ func (lz *LandingZone) setupRoles(ctx *pulumi.Context, cfg *config.LandingZoneCo
 		{
 			name:        RoleNameControlTowerAdmin,
 			description: "Role for AWS Control Tower administration",
-			service:     "controltower.amazonaws.com",
-			policy:      "arn:aws:iam::aws:policy/service-role/AWSControlTowerServiceRolePolicy",
+			service:     awsclient.ServicePrincipal("controltower"),
+			policy:      awsclient.PolicyArn("service-role/AWSControlTowerServiceRolePolicy"),
 		},
 		// Add other roles here
 	}
@@ -190,29 +213,33 @@ func (lz *LandingZone) setupRoles(ctx *pulumi.Context, cfg *config.LandingZoneCo
 	return nil
 }
 
-// createRoleWithRetry creates an IAM role with retry logic
+// createRoleWithRetry creates an IAM role with retry logic. A role left behind by a
+// partially failed run is adopted when it matches.
 func (lz *LandingZone) createRoleWithRetry(ctx *pulumi.Context, name, description, service, policy string, tags map[string]string) error {
+	assumeRolePolicy := fmt.Sprintf(`{
+		"Version": "2012-10-17",
+		"Statement": [{
+			"Effect": "Allow",
+			"Principal": {
+				"Service": "%s"
+			},
+			"Action": "sts:AssumeRole"
+		}]
+	}`, service)
+	adoption := lz.adopter.Role(ctx.Context(), name, ServiceRolePath, description, assumeRolePolicy, tags)
+
 	operation := func() error {
 		if err := lz.limiter.Wait(ctx.Context()); err != nil {
 			return err
 		}
 
 		role, err := iam.NewRole(ctx, name, &iam.RoleArgs{
-			Name:        pulumi.String(name),
-			Path:        pulumi.String(ServiceRolePath),
-			Description: pulumi.String(description),
-			AssumeRolePolicy: pulumi.String(fmt.Sprintf(`{
-				"Version": "2012-10-17",
-				"Statement": [{
-					"Effect": "Allow",
-					"Principal": {
-						"Service": "%s"
-					},
-					"Action": "sts:AssumeRole"
-				}]
-			}`, service)),
-			Tags: pulumi.ToStringMap(tags),
-		})
+			Name:             pulumi.String(name),
+			Path:             pulumi.String(ServiceRolePath),
+			Description:      pulumi.String(description),
+			AssumeRolePolicy: pulumi.String(assumeRolePolicy),
+			Tags:             pulumi.ToStringMap(tags),
+		}, component.Options(lz.opts, adoption...)...)
 		if err != nil {
 			return err
 		}
@@ -233,7 +260,7 @@ func (lz *LandingZone) setupKMS(ctx *pulumi.Context, cfg *config.LandingZoneConf
 // setupLogging configures CloudWatch and CloudTrail logging
 func (lz *LandingZone) setupLogging(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
 	// Logging setup implementation
-	return nil
+	return lz.setupLogArchive(ctx, cfg)
 }
 
 // setupGuardrails configures Control Tower guardrails
@@ -249,6 +276,10 @@ func retryWithBackoff(operation func() error, maxAttempts int, baseDelay time.Du
 		if err := operation(); err == nil {
 			return nil
 		} else {
+			// Retrying cannot fix missing permissions, existing resources or quotas
+			if awsclient.Permanent(err) {
+				return err
+			}
 			lastErr = err
 			if attempt < maxAttempts {
 				delay := time.Duration(float64(baseDelay) * float64(attempt))
@@ -0,0 +1,143 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package controltower
+
+import (
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Object Lock defaults
+	DefaultObjectLockMode = "COMPLIANCE"
+
+	// Storage class logs are archived to
+	storageClassDeepArchive = "DEEP_ARCHIVE"
+)
+
+// setupLogArchive creates the log archive bucket with Object Lock and archival to
+// Glacier Deep Archive. Logs expire after LogRetentionDays.
+func (lz *LandingZone) setupLogArchive(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	archive := cfg.LogArchive
+	if archive == nil {
+		return nil
+	}
+
+	opts, err := lz.logArchiveOptions(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	bucket, err := s3.NewBucketV2(ctx, "log-archive-bucket", &s3.BucketV2Args{
+		Bucket:            pulumi.String(cfg.LogBucketName),
+		ObjectLockEnabled: pulumi.Bool(archive.ObjectLockEnabled),
+		Tags:              pulumi.ToStringMap(cfg.Tags),
+	}, component.Options(opts, pulumi.Protect(true))...)
+	if err != nil {
+		return fmt.Errorf("failed to create log archive bucket: %w", err)
+	}
+
+	lz.mutex.Lock()
+	lz.logArchive = bucket
+	lz.mutex.Unlock()
+
+	// Object Lock requires versioning
+	versioning, err := s3.NewBucketVersioningV2(ctx, "log-archive-versioning", &s3.BucketVersioningV2Args{
+		Bucket: bucket.ID(),
+		VersioningConfiguration: &s3.BucketVersioningV2VersioningConfigurationArgs{
+			Status: pulumi.String("Enabled"),
+		},
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to enable log archive versioning: %w", err)
+	}
+
+	if archive.ObjectLockEnabled {
+		mode := archive.ObjectLockMode
+		if mode == "" {
+			mode = DefaultObjectLockMode
+		}
+		days := archive.ObjectLockRetentionDays
+		if days == 0 {
+			days = cfg.LogRetentionDays
+		}
+
+		if _, err := s3.NewBucketObjectLockConfigurationV2(ctx, "log-archive-object-lock", &s3.BucketObjectLockConfigurationV2Args{
+			Bucket: bucket.ID(),
+			Rule: &s3.BucketObjectLockConfigurationV2RuleArgs{
+				DefaultRetention: &s3.BucketObjectLockConfigurationV2RuleDefaultRetentionArgs{
+					Mode: pulumi.String(mode),
+					Days: pulumi.Int(days),
+				},
+			},
+		}, component.Options(opts, pulumi.DependsOn([]pulumi.Resource{versioning}))...); err != nil {
+			return fmt.Errorf("failed to configure log archive Object Lock: %w", err)
+		}
+	}
+
+	rule := &s3.BucketLifecycleConfigurationV2RuleArgs{
+		Id:     pulumi.String("log-archival"),
+		Status: pulumi.String("Enabled"),
+		Filter: &s3.BucketLifecycleConfigurationV2RuleFilterArgs{},
+		Expiration: &s3.BucketLifecycleConfigurationV2RuleExpirationArgs{
+			Days: pulumi.Int(cfg.LogRetentionDays),
+		},
+		// Versions left behind by the expiration are removed once their lock has expired
+		NoncurrentVersionExpiration: &s3.BucketLifecycleConfigurationV2RuleNoncurrentVersionExpirationArgs{
+			NoncurrentDays: pulumi.Int(1),
+		},
+	}
+	if archive.DeepArchiveTransitionDays > 0 {
+		rule.Transitions = s3.BucketLifecycleConfigurationV2RuleTransitionArray{
+			&s3.BucketLifecycleConfigurationV2RuleTransitionArgs{
+				Days:         pulumi.Int(archive.DeepArchiveTransitionDays),
+				StorageClass: pulumi.String(storageClassDeepArchive),
+			},
+		}
+	}
+
+	if _, err := s3.NewBucketLifecycleConfigurationV2(ctx, "log-archive-lifecycle", &s3.BucketLifecycleConfigurationV2Args{
+		Bucket: bucket.ID(),
+		Rules:  s3.BucketLifecycleConfigurationV2RuleArray{rule},
+	}, component.Options(opts, pulumi.DependsOn([]pulumi.Resource{versioning}))...); err != nil {
+		return fmt.Errorf("failed to configure log archive lifecycle: %w", err)
+	}
+
+	lz.metrics.IncrementCounter("log_archive_configured")
+	lz.logger.Info("log archive bucket configured",
+		zap.String("bucket", cfg.LogBucketName),
+		zap.Bool("objectLock", archive.ObjectLockEnabled),
+		zap.Int("deepArchiveTransitionDays", archive.DeepArchiveTransitionDays),
+		zap.Int("retentionDays", cfg.LogRetentionDays))
+	return nil
+}
+
+// logArchiveOptions returns the resource options placing resources in the log archive
+// account, or in the current account when no log archive account is configured
+func (lz *LandingZone) logArchiveOptions(ctx *pulumi.Context, cfg *config.LandingZoneConfig) ([]pulumi.ResourceOption, error) {
+	if cfg.LogArchiveAccountId == "" {
+		return lz.opts, nil
+	}
+
+	provider, err := aws.NewProvider(ctx, "log-archive", &aws.ProviderArgs{
+		Region: pulumi.String(cfg.LogBucketRegion()),
+		AssumeRole: &aws.ProviderAssumeRoleArgs{
+			RoleArn:     pulumi.String(awsclient.RoleArn(cfg.LogArchiveAccountId, awsclient.MemberRoleName(cfg))),
+			SessionName: pulumi.String(awsclient.SessionName),
+		},
+	}, lz.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log archive provider: %w", err)
+	}
+
+	return component.Options(lz.opts, pulumi.Provider(provider)), nil
+}
@@ -0,0 +1,340 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package ctdrift provides the drift detection and reset of the Control Tower landing
+// zone and its enabled controls.
+// Version: 1.0.0
+package ctdrift
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ct "github.com/aws/aws-sdk-go-v2/service/controltower"
+	cttypes "github.com/aws/aws-sdk-go-v2/service/controltower/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"go.uber.org/zap"
+)
+
+const (
+	// Check names of the drift findings in the report
+	CheckLandingZoneDrift = "landing-zone-drift"
+	CheckControlDrift     = "control-drift"
+	CheckDriftError       = "controltower-drift-error"
+
+	// Reset defaults
+	DefaultResetTimeout = 60 * time.Minute
+	pollInterval        = 30 * time.Second
+
+	// Hexadecimal digits of the digest approving a reset
+	confirmationLength = 12
+)
+
+// LandingZone is a Control Tower landing zone with its drift status
+type LandingZone struct {
+	ARN           string `json:"arn"`
+	Version       string `json:"version"`
+	LatestVersion string `json:"latestVersion,omitempty"`
+	Status        string `json:"status"`
+	Drifted       bool   `json:"drifted"`
+	Digest        string `json:"-"`
+}
+
+// ID returns the identifier of the landing zone, the last segment of its ARN
+func (lz LandingZone) ID() string {
+	return lz.ARN[strings.LastIndex(lz.ARN, "/")+1:]
+}
+
+// Confirmation returns the token an operator types to confirm the reset of the landing
+// zone, the start of its digest. A confirmation given for a drift no longer applies once
+// the landing zone changes.
+func (lz LandingZone) Confirmation() string {
+	return strings.ToUpper(lz.Digest[:confirmationLength])
+}
+
+// ApprovalToken returns the token approving the reset of the landing zone, the
+// signature of its digest by the private key of the reset approvers
+func (lz LandingZone) ApprovalToken(key ed25519.PrivateKey) string {
+	return base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(lz.Digest)))
+}
+
+// Approved reports whether token is the signature of the current drift of the landing
+// zone by the reset approvers. Only the holders of the private key can approve a reset.
+func (lz LandingZone) Approved(key ed25519.PublicKey, token string) bool {
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(token))
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(key, []byte(lz.Digest), signature)
+}
+
+// PublicKey decodes the base64 public key of the reset approvers
+func PublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("reset approval public key is not a base64 Ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Detector reads the drift status Control Tower keeps for the landing zone and the
+// enabled controls. Control Tower is administered from the management account.
+type Detector struct {
+	logger       *zap.Logger
+	metrics      *metrics.Collector
+	client       *ct.Client
+	secrets      *secretsmanager.Client
+	managementId string
+	timeout      time.Duration
+}
+
+// NewDetector creates a Control Tower drift detector for the home region
+func NewDetector(ctx context.Context, cfg *config.LandingZoneConfig) (*Detector, error) {
+	logger, err := logging.NewLogger("controltower-drift")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("controltower-drift")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HomeRegion != "" {
+		base.Region = cfg.HomeRegion
+	}
+
+	timeout := DefaultResetTimeout
+	if cfg.Drift != nil && cfg.Drift.TimeoutMinutes > 0 {
+		timeout = time.Duration(cfg.Drift.TimeoutMinutes) * time.Minute
+	}
+
+	return &Detector{
+		logger:       logger,
+		metrics:      metrics,
+		client:       ct.NewFromConfig(base),
+		secrets:      secretsmanager.NewFromConfig(base),
+		managementId: cfg.ManagementAccountId,
+		timeout:      timeout,
+	}, nil
+}
+
+// LandingZones returns the landing zones of the organization, usually a single one
+func (d *Detector) LandingZones(ctx context.Context) ([]LandingZone, error) {
+	var zones []LandingZone
+
+	paginator := ct.NewListLandingZonesPaginator(d.client, &ct.ListLandingZonesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list landing zones: %w", err)
+		}
+		for _, summary := range page.LandingZones {
+			out, err := d.client.GetLandingZone(ctx, &ct.GetLandingZoneInput{
+				LandingZoneIdentifier: summary.Arn,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get landing zone %s: %w", aws.ToString(summary.Arn), err)
+			}
+
+			detail := out.LandingZone
+			zone := LandingZone{
+				ARN:           aws.ToString(summary.Arn),
+				Version:       aws.ToString(detail.Version),
+				LatestVersion: aws.ToString(detail.LatestAvailableVersion),
+				Status:        string(detail.Status),
+			}
+			var driftStatus cttypes.LandingZoneDriftStatus
+			if detail.DriftStatus != nil {
+				driftStatus = detail.DriftStatus.Status
+				zone.Drifted = driftStatus == cttypes.LandingZoneDriftStatusDrifted
+			}
+			var manifest []byte
+			if detail.Manifest != nil {
+				if manifest, err = detail.Manifest.MarshalSmithyDocument(); err != nil {
+					return nil, fmt.Errorf("failed to read manifest of landing zone %s: %w", zone.ARN, err)
+				}
+			}
+			zone.Digest = digest(zone, driftStatus, manifest)
+			zones = append(zones, zone)
+		}
+	}
+	return zones, nil
+}
+
+// ApprovalKey reads the private key of the reset approvers, the base64 Ed25519 seed
+// stored in a Secrets Manager secret only the approvers can read
+func (d *Detector) ApprovalKey(ctx context.Context, secretId string) (ed25519.PrivateKey, error) {
+	out, err := d.secrets.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reset approval key %s: %w", secretId, err)
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(aws.ToString(out.SecretString)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("reset approval key %s is not a base64 Ed25519 seed", secretId)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// digest returns the digest of the drift of a landing zone: its version, drift status
+// and the manifest a reset redeploys
+func digest(zone LandingZone, driftStatus cttypes.LandingZoneDriftStatus, manifest []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", zone.ARN, zone.Version, driftStatus)
+	h.Write(manifest)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Collect adds the drifted landing zones and enabled controls to the report. Control
+// Tower checks for drift itself, so the status is current without starting a detection.
+// Drift that cannot be read is reported as a finding rather than failing the report.
+func (d *Detector) Collect(ctx context.Context, r *report.Report) error {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDuration("drift_collection", time.Since(start))
+	}()
+
+	zones, err := d.LandingZones(ctx)
+	if err != nil {
+		d.collectError(r, "landing-zone", err)
+	}
+	for _, zone := range zones {
+		if !zone.Drifted {
+			continue
+		}
+		r.Add(report.Finding{
+			AccountID: d.managementId,
+			Check:     CheckLandingZoneDrift,
+			Severity:  report.SeverityHigh,
+			Resource:  zone.ARN,
+			Message: fmt.Sprintf("landing zone %s drifted from its expected configuration, reset it with drift --reset-landing-zone",
+				zone.Version),
+		})
+		d.metrics.IncrementCounter("drifted_landing_zones")
+	}
+
+	controls := 0
+	paginator := ct.NewListEnabledControlsPaginator(d.client, &ct.ListEnabledControlsInput{
+		Filter: &cttypes.EnabledControlFilter{
+			DriftStatuses: []cttypes.DriftStatus{cttypes.DriftStatusDrifted},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			d.collectError(r, "enabled-controls", err)
+			break
+		}
+		for _, control := range page.EnabledControls {
+			identifier := aws.ToString(control.ControlIdentifier)
+			r.Add(report.Finding{
+				AccountID: d.managementId,
+				Check:     CheckControlDrift,
+				Severity:  report.SeverityMedium,
+				Resource:  aws.ToString(control.TargetIdentifier),
+				Message: fmt.Sprintf("control %s drifted from its expected configuration",
+					identifier[strings.LastIndex(identifier, "/")+1:]),
+			})
+			d.metrics.IncrementCounter("drifted_controls")
+			controls++
+		}
+	}
+
+	d.logger.Info("Control Tower drift collected",
+		zap.Int("landingZones", len(zones)),
+		zap.Int("driftedControls", controls))
+	return nil
+}
+
+// collectError reports drift of a resource that could not be read
+func (d *Detector) collectError(r *report.Report, resource string, err error) {
+	d.logger.Error("failed to read Control Tower drift",
+		zap.String("resource", resource),
+		zap.Error(err))
+	d.metrics.IncrementCounter("drift_errors")
+	r.Add(report.Finding{
+		AccountID: d.managementId,
+		Check:     CheckDriftError,
+		Severity:  report.SeverityHigh,
+		Resource:  resource,
+		Message:   err.Error(),
+	})
+}
+
+// Reset resets a landing zone to its expected configuration and waits for the
+// operation to complete
+func (d *Detector) Reset(ctx context.Context, zone LandingZone) error {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDuration("landing_zone_reset", time.Since(start))
+	}()
+
+	out, err := d.client.ResetLandingZone(ctx, &ct.ResetLandingZoneInput{
+		LandingZoneIdentifier: aws.String(zone.ARN),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset landing zone %s: %w", zone.ID(), err)
+	}
+	operationId := aws.ToString(out.OperationIdentifier)
+	d.logger.Info("landing zone reset started",
+		zap.String("landingZone", zone.ARN),
+		zap.String("operationId", operationId))
+
+	waitCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	if err := d.wait(waitCtx, operationId); err != nil {
+		return err
+	}
+
+	d.metrics.IncrementCounter("landing_zone_resets")
+	d.logger.Info("landing zone reset", zap.String("landingZone", zone.ARN))
+	return nil
+}
+
+// wait polls a landing zone operation until it completes
+func (d *Detector) wait(ctx context.Context, operationId string) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		out, err := d.client.GetLandingZoneOperation(ctx, &ct.GetLandingZoneOperationInput{
+			OperationIdentifier: aws.String(operationId),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get landing zone operation %s: %w", operationId, err)
+		}
+
+		switch out.OperationDetails.Status {
+		case cttypes.LandingZoneOperationStatusSucceeded:
+			return nil
+		case cttypes.LandingZoneOperationStatusFailed:
+			return fmt.Errorf("landing zone reset %s failed: %s", operationId,
+				aws.ToString(out.OperationDetails.StatusMessage))
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for landing zone reset %s: %w", operationId, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
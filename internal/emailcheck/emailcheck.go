@@ -0,0 +1,342 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package emailcheck provides the pre-flight verification of the email addresses of the
+// accounts, so an undeliverable address fails the run before any account is created
+// rather than part way through it.
+// Version: 1.0.0
+package emailcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/accounts"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	sestypes "github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	"go.uber.org/zap"
+)
+
+// Outcomes of the verification of an address
+const (
+	StatusValid   = "valid"
+	StatusInvalid = "invalid"
+
+	// The address could not be verified, such as when the DNS lookup timed out
+	StatusUnknown = "unknown"
+)
+
+// Address is the email address of a configured account
+type Address struct {
+	Account string `json:"account"`
+	OU      string `json:"ou,omitempty"`
+	Email   string `json:"email"`
+}
+
+// Result is the outcome of the verification of an address
+type Result struct {
+	Address
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Addresses returns the addresses of the default accounts and of the accounts of the
+// organizational units, ordered by email
+func Addresses(lz *config.LandingZoneConfig) []Address {
+	var addresses []Address
+	for name, email := range accounts.DefaultAccountEmails(lz) {
+		addresses = append(addresses, Address{Account: name, Email: email})
+	}
+	for ouName, ou := range lz.OrganizationUnits {
+		if ou == nil {
+			continue
+		}
+		for _, account := range ou.Accounts {
+			addresses = append(addresses, Address{Account: account.Name, OU: ouName, Email: account.Email})
+		}
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		if addresses[i].Email != addresses[j].Email {
+			return addresses[i].Email < addresses[j].Email
+		}
+		return addresses[i].Account < addresses[j].Account
+	})
+	return addresses
+}
+
+// ToCreate returns the addresses of the accounts not yet in the organization, given its
+// accounts. Addresses are compared without case, as AWS does.
+func ToCreate(addresses []Address, existing []orgtypes.Account) []Address {
+	emails := make(map[string]bool, len(existing))
+	for _, account := range existing {
+		emails[strings.ToLower(aws.ToString(account.Email))] = true
+	}
+
+	var created []Address
+	for _, address := range addresses {
+		if !emails[strings.ToLower(address.Email)] {
+			created = append(created, address)
+		}
+	}
+	return created
+}
+
+// Verifier verifies the deliverability of email addresses
+type Verifier struct {
+	logger   *zap.Logger
+	metrics  *metrics.Collector
+	cfg      *config.EmailVerificationConfig
+	resolver *net.Resolver
+	ses      *sesv2.Client
+	emailRE  *regexp.Regexp
+
+	// Outcome of the lookup of each domain, shared by its addresses
+	mutex   sync.Mutex
+	domains map[string]*lookup
+}
+
+// lookup is the pending or completed DNS lookup of a domain
+type lookup struct {
+	done   chan struct{}
+	status string
+	reason string
+}
+
+// NewVerifier creates a verifier using the credentials of the management account for
+// the SES lookups
+func NewVerifier(ctx context.Context, lz *config.LandingZoneConfig) (*Verifier, error) {
+	logger, err := logging.NewLogger("emailcheck")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("emailcheck")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	v := &Verifier{
+		logger:   logger,
+		metrics:  metrics,
+		cfg:      lz.EmailVerification,
+		resolver: net.DefaultResolver,
+		emailRE:  regexp.MustCompile(config.EmailRegexPattern),
+		domains:  make(map[string]*lookup),
+	}
+
+	if lz.EmailVerification != nil && lz.EmailVerification.SES {
+		base, err := awsclient.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if lz.Mail != nil && lz.Mail.Region != "" {
+			base.Region = lz.Mail.Region
+		}
+		v.ses = sesv2.NewFromConfig(base)
+	}
+	return v, nil
+}
+
+// Verify verifies the addresses concurrently and returns their results in the order of
+// the addresses
+func (v *Verifier) Verify(ctx context.Context, addresses []Address) []Result {
+	start := time.Now()
+	defer func() {
+		v.metrics.RecordDuration("verification_duration", time.Since(start))
+	}()
+
+	results := make([]Result, len(addresses))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, v.cfg.Workers())
+	for i, address := range addresses {
+		wg.Add(1)
+		go func(i int, address Address) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = v.verify(ctx, address)
+		}(i, address)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		v.metrics.IncrementCounter("addresses_" + result.Status)
+		if result.Status != StatusValid {
+			v.logger.Warn("email address not verified",
+				zap.String("account", result.Account),
+				zap.String("email", result.Email),
+				zap.String("status", result.Status),
+				zap.String("reason", result.Reason))
+		}
+	}
+	v.logger.Info("email addresses verified",
+		zap.Int("addresses", len(results)),
+		zap.Int("invalid", len(Invalid(results))))
+	return results
+}
+
+// verify verifies a single address: its syntax, the mail servers of its domain and,
+// with SES, its absence from the suppression list
+func (v *Verifier) verify(ctx context.Context, address Address) Result {
+	result := Result{Address: address, Status: StatusValid}
+	if !v.emailRE.MatchString(address.Email) {
+		result.Status, result.Reason = StatusInvalid, "not an email address"
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, v.cfg.Timeout())
+	defer cancel()
+
+	domain := strings.ToLower(address.Email[strings.LastIndex(address.Email, "@")+1:])
+	result.Status, result.Reason = v.lookupDomain(ctx, domain)
+	if result.Status != StatusValid || v.ses == nil {
+		return result
+	}
+
+	result.Status, result.Reason = v.checkSuppressed(ctx, address.Email)
+	return result
+}
+
+// lookupDomain checks that a domain receives mail. Domains without MX records receive
+// mail on their address records, as with SMTP. Each domain is looked up once, except
+// when the lookup could not complete: the time left to another address may suffice.
+func (v *Verifier) lookupDomain(ctx context.Context, domain string) (string, string) {
+	for {
+		v.mutex.Lock()
+		l, ok := v.domains[domain]
+		if !ok {
+			l = &lookup{done: make(chan struct{})}
+			v.domains[domain] = l
+		}
+		v.mutex.Unlock()
+
+		if !ok {
+			v.resolve(ctx, domain, l)
+			return l.status, l.reason
+		}
+
+		select {
+		case <-l.done:
+			if l.status != StatusUnknown {
+				return l.status, l.reason
+			}
+		case <-ctx.Done():
+			return StatusUnknown, fmt.Sprintf("lookup of %s did not complete: %v", domain, ctx.Err())
+		}
+	}
+}
+
+// resolve looks up the mail servers of a domain into l. A lookup that could not
+// complete is dropped, so the next address of the domain looks it up again.
+func (v *Verifier) resolve(ctx context.Context, domain string, l *lookup) {
+	defer close(l.done)
+	defer func() {
+		if l.status == StatusUnknown {
+			v.mutex.Lock()
+			delete(v.domains, domain)
+			v.mutex.Unlock()
+		}
+	}()
+	l.status, l.reason = StatusValid, ""
+
+	records, err := v.resolver.LookupMX(ctx, domain)
+	if err == nil {
+		// A single null MX record declares that the domain accepts no mail
+		if len(records) == 1 && records[0].Host == "." {
+			l.status, l.reason = StatusInvalid, fmt.Sprintf("domain %s accepts no mail", domain)
+		}
+		return
+	}
+	if !notFound(err) {
+		l.status, l.reason = StatusUnknown, fmt.Sprintf("failed to look up MX records of %s: %v", domain, err)
+		return
+	}
+
+	if _, err := v.resolver.LookupHost(ctx, domain); err != nil {
+		if notFound(err) {
+			l.status, l.reason = StatusInvalid, fmt.Sprintf("domain %s has no MX or address records", domain)
+		} else {
+			l.status, l.reason = StatusUnknown, fmt.Sprintf("failed to look up %s: %v", domain, err)
+		}
+	}
+}
+
+// checkSuppressed looks an address up in the SES suppression list of the account
+func (v *Verifier) checkSuppressed(ctx context.Context, email string) (string, string) {
+	out, err := v.ses.GetSuppressedDestination(ctx, &sesv2.GetSuppressedDestinationInput{
+		EmailAddress: aws.String(email),
+	})
+	var missing *sestypes.NotFoundException
+	switch {
+	case errors.As(err, &missing):
+		return StatusValid, ""
+	case err != nil:
+		return StatusUnknown, fmt.Sprintf("failed to read SES suppression list: %v", err)
+	}
+
+	reason := "suppressed by SES"
+	if out.SuppressedDestination != nil && out.SuppressedDestination.Reason != "" {
+		reason = fmt.Sprintf("suppressed by SES after a %s", strings.ToLower(string(out.SuppressedDestination.Reason)))
+	}
+	return StatusInvalid, reason
+}
+
+// notFound reports whether a DNS lookup failed because the name has no such records
+func notFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// Invalid returns the results of the addresses found undeliverable
+func Invalid(results []Result) []Result {
+	var invalid []Result
+	for _, result := range results {
+		if result.Status == StatusInvalid {
+			invalid = append(invalid, result)
+		}
+	}
+	return invalid
+}
+
+// Write writes the results as a table or as JSON
+func Write(w io.Writer, format string, results []Result) error {
+	switch format {
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return fmt.Errorf("failed to encode email verification: %w", err)
+		}
+		return nil
+	case report.FormatText:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "EMAIL\tACCOUNT\tOU\tSTATUS\tREASON")
+		for _, result := range results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				result.Email, result.Account, report.OrDash(result.OU), result.Status, report.OrDash(result.Reason))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
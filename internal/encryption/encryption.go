@@ -0,0 +1,403 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package encryption provides the scan of the encryption of the resources created by the
+// landing zone.
+// Version: 1.0.0
+package encryption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/secrets"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// Check names of the encryption findings in the report
+	CheckEncryptionAtRest    = "encryption-at-rest"
+	CheckEncryptionInTransit = "encryption-in-transit"
+	CheckScanError           = "encryption-scan-error"
+
+	// Resources scanned at once
+	maxConcurrentScans = 10
+)
+
+// Kinds of scanned resources
+const (
+	KindBucket    = "bucket"
+	KindLogGroup  = "log-group"
+	KindParameter = "parameter"
+	KindSecret    = "secret"
+	KindTable     = "table"
+	KindTopic     = "topic"
+)
+
+// Resource is a resource created by the landing zone with the encryption its
+// configuration gives it. KeyId is the KMS key it must be encrypted with, any key when
+// empty; KMS requires a KMS key rather than a key managed by the service, and
+// SecureTransport a policy denying requests not made over TLS.
+type Resource struct {
+	Kind            string
+	Name            string
+	AccountID       string
+	Region          string
+	KeyId           string
+	KMS             bool
+	SecureTransport bool
+}
+
+// Scanner compares the encryption of the resources created by the landing zone with
+// the configuration. Member accounts are read through the read-only role.
+type Scanner struct {
+	logger       *zap.Logger
+	metrics      *metrics.Collector
+	cfg          *config.LandingZoneConfig
+	cache        *orgcache.Cache
+	base         aws.Config
+	roleName     string
+	managementId string
+
+	// Key IDs of the expected keys given as aliases, by account, region and alias
+	mutex sync.Mutex
+	keys  map[string]string
+}
+
+// NewScanner creates an encryption scanner using the credentials of the management account
+func NewScanner(ctx context.Context, cfg *config.LandingZoneConfig, cache *orgcache.Cache) (*Scanner, error) {
+	logger, err := logging.NewLogger("encryption_scan")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("encryption_scan")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HomeRegion != "" {
+		base.Region = cfg.HomeRegion
+	}
+
+	return &Scanner{
+		logger:       logger,
+		metrics:      metrics,
+		cfg:          cfg,
+		cache:        cache,
+		base:         base,
+		roleName:     awsclient.ReadOnlyRoleName(cfg),
+		managementId: cfg.ManagementAccountId,
+		keys:         make(map[string]string),
+	}, nil
+}
+
+// Resources returns the resources of the configuration whose encryption is scanned:
+// the log archive, GuardDuty export and Cost and Usage Report buckets, the Control
+// Tower log groups, the generated secrets, the state table and the topic of the root
+// activity alarm
+func (s *Scanner) Resources(ctx context.Context) ([]Resource, error) {
+	cfg := s.cfg
+	logArchive := cfg.LogArchiveAccountId
+	if logArchive == "" {
+		logArchive = s.managementId
+	}
+	home := s.base.Region
+
+	var resources []Resource
+	if cfg.LogArchive != nil && cfg.LogBucketName != "" {
+		resources = append(resources, Resource{
+			Kind:      KindBucket,
+			Name:      cfg.LogBucketName,
+			AccountID: logArchive,
+			Region:    cfg.LogBucketRegion(),
+			KeyId:     cfg.KMSKeyArn,
+			KMS:       cfg.KMSKeyArn != "",
+		})
+	}
+	if bucket := cfg.GuardDutyExportBucket(); bucket != "" && cfg.EnableGuardDuty {
+		resources = append(resources, Resource{
+			Kind:            KindBucket,
+			Name:            bucket,
+			AccountID:       logArchive,
+			Region:          cfg.LogBucketRegion(),
+			KMS:             true,
+			SecureTransport: true,
+		})
+	}
+	if cfg.Billing != nil && cfg.Billing.CostAndUsageReport != nil {
+		resources = append(resources, Resource{
+			Kind:            KindBucket,
+			Name:            cfg.Billing.CostAndUsageReport.BucketName,
+			AccountID:       logArchive,
+			Region:          cfg.LogBucketRegion(),
+			SecureTransport: true,
+		})
+	}
+
+	// Log groups are always encrypted, the configured key only changes which key
+	if cfg.KMSKeyArn != "" {
+		for _, logGroup := range []string{cfg.CloudWatchLogGroup, cfg.CloudTrailLogGroup} {
+			if logGroup == "" {
+				continue
+			}
+			resources = append(resources, Resource{
+				Kind:      KindLogGroup,
+				Name:      logGroup,
+				AccountID: s.managementId,
+				Region:    home,
+				KeyId:     cfg.KMSKeyArn,
+				KMS:       true,
+			})
+		}
+	}
+
+	kind, keyId := KindParameter, ""
+	if cfg.Secrets != nil {
+		keyId = cfg.Secrets.KmsKeyId
+		if cfg.Secrets.StoreName() == config.SecretStoreSecretsManager {
+			kind = KindSecret
+		}
+	}
+	for _, name := range secrets.Names(cfg) {
+		resources = append(resources, Resource{
+			Kind:      kind,
+			Name:      name,
+			AccountID: s.managementId,
+			Region:    home,
+			KeyId:     keyId,
+			KMS:       true,
+		})
+	}
+
+	if cfg.StateBackend == "" || cfg.StateBackend == config.StateBackendDynamoDB {
+		table := cfg.StateTableName
+		if table == "" {
+			table = config.StateTableName
+		}
+		resources = append(resources, Resource{
+			Kind:      KindTable,
+			Name:      table,
+			AccountID: s.managementId,
+			Region:    home,
+			KMS:       true,
+		})
+	}
+
+	if cfg.Baseline != nil && cfg.Baseline.RootActivityAlarm != nil && cfg.Baseline.RootActivityAlarm.KmsKeyId != "" {
+		alarm := cfg.Baseline.RootActivityAlarm
+		region := cfg.RootActivityAlarmRegion()
+		resources = append(resources, Resource{
+			Kind:      KindTopic,
+			Name:      fmt.Sprintf("arn:%s:sns:%s:%s:%s", awsclient.Partition(), region, s.managementId, alarm.AlarmName()),
+			AccountID: s.managementId,
+			Region:    region,
+			KeyId:     alarm.KmsKeyId,
+			KMS:       true,
+		})
+	}
+
+	return resources, nil
+}
+
+// Collect scans every resource and adds the gaps between its encryption and the
+// configuration to the report. Resources that cannot be read are reported as scan
+// errors; resources that no longer exist are skipped.
+func (s *Scanner) Collect(ctx context.Context, r *report.Report) error {
+	start := time.Now()
+	defer func() {
+		s.metrics.RecordDuration("encryption_scan", time.Since(start))
+	}()
+
+	resources, err := s.Resources(ctx)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentScans)
+	for _, resource := range resources {
+		wg.Add(1)
+		go func(resource Resource) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			findings, err := s.scan(ctx, resource)
+			if err != nil {
+				s.logger.Error("encryption scan failed",
+					zap.String("accountId", resource.AccountID),
+					zap.String("resource", resource.Name),
+					zap.Error(err))
+				s.metrics.IncrementCounter("scan_errors")
+				r.Add(report.Finding{
+					AccountID: resource.AccountID,
+					Check:     CheckScanError,
+					Severity:  report.SeverityHigh,
+					Resource:  resource.Name,
+					Message:   err.Error(),
+				})
+				return
+			}
+			r.Add(findings...)
+			s.metrics.IncrementCounter("resources_scanned")
+		}(resource)
+	}
+	wg.Wait()
+
+	s.logger.Info("encryption scanned", zap.Int("resources", len(resources)))
+	return nil
+}
+
+// scan reads the encryption of a resource in its account and region
+func (s *Scanner) scan(ctx context.Context, resource Resource) ([]report.Finding, error) {
+	cfg := s.base.Copy()
+	if resource.AccountID != s.managementId {
+		cfg = awsclient.AssumeRole(s.base, resource.AccountID, s.roleName)
+	}
+	cfg.Region = resource.Region
+
+	var gaps []gap
+	var err error
+	switch resource.Kind {
+	case KindBucket:
+		gaps, err = s.scanBucket(ctx, cfg, resource)
+	case KindLogGroup:
+		gaps, err = s.scanLogGroup(ctx, cfg, resource)
+	case KindParameter:
+		gaps, err = s.scanParameter(ctx, cfg, resource)
+	case KindSecret:
+		gaps, err = s.scanSecret(ctx, cfg, resource)
+	case KindTable:
+		gaps, err = s.scanTable(ctx, cfg, resource)
+	case KindTopic:
+		gaps, err = s.scanTopic(ctx, cfg, resource)
+	default:
+		return nil, fmt.Errorf("unknown resource kind %q", resource.Kind)
+	}
+	if err != nil || len(gaps) == 0 {
+		return nil, err
+	}
+
+	findings := make([]report.Finding, 0, len(gaps))
+	for _, g := range gaps {
+		findings = append(findings, report.Finding{
+			AccountID: resource.AccountID,
+			Check:     g.check,
+			Severity:  g.severity,
+			Resource:  resource.Name,
+			Message:   fmt.Sprintf("%s %s: %s", resource.Kind, resource.Name, g.message),
+		})
+	}
+	return findings, nil
+}
+
+// gap is a difference between the encryption of a resource and the configuration
+type gap struct {
+	check    string
+	severity report.Severity
+	message  string
+}
+
+// unencrypted returns the gap of a resource not encrypted at rest
+func unencrypted(message string) gap {
+	return gap{check: CheckEncryptionAtRest, severity: report.SeverityHigh, message: message}
+}
+
+// weaklyEncrypted returns the gap of a resource encrypted otherwise than configured
+func weaklyEncrypted(format string, args ...interface{}) gap {
+	return gap{check: CheckEncryptionAtRest, severity: report.SeverityMedium, message: fmt.Sprintf(format, args...)}
+}
+
+// checkKey returns the gap of a resource encrypted with another key than the expected
+// one, or nil
+func (s *Scanner) checkKey(ctx context.Context, cfg aws.Config, resource Resource, actual string) (*gap, error) {
+	if resource.KeyId == "" {
+		return nil, nil
+	}
+	if actual == "" {
+		g := weaklyEncrypted("encrypted with the AWS managed key rather than %s", resource.KeyId)
+		return &g, nil
+	}
+
+	expected, err := s.keyId(ctx, cfg, resource.AccountID, resource.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	got, err := s.keyId(ctx, cfg, resource.AccountID, actual)
+	if err != nil {
+		return nil, err
+	}
+	if got != expected {
+		g := weaklyEncrypted("encrypted with %s rather than %s", actual, resource.KeyId)
+		return &g, nil
+	}
+	return nil, nil
+}
+
+// keyId returns the ID of a key given by ID, ARN, alias or alias ARN. Aliases are
+// resolved through KMS in the account and region of the resource.
+func (s *Scanner) keyId(ctx context.Context, cfg aws.Config, accountId, key string) (string, error) {
+	id := key[strings.LastIndex(key, ":")+1:]
+	if strings.HasPrefix(id, "key/") {
+		return strings.TrimPrefix(id, "key/"), nil
+	}
+	if !strings.HasPrefix(id, "alias/") {
+		return id, nil
+	}
+
+	// Alias ARNs name the account and region the alias is resolved in
+	cacheKey := key
+	if !strings.HasPrefix(key, "arn:") {
+		cacheKey = fmt.Sprintf("%s/%s/%s", accountId, cfg.Region, key)
+	}
+	s.mutex.Lock()
+	cached, ok := s.keys[cacheKey]
+	s.mutex.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	out, err := kms.NewFromConfig(cfg).DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(key)})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe key %s: %w", key, err)
+	}
+	resolved := aws.ToString(out.KeyMetadata.KeyId)
+
+	s.mutex.Lock()
+	s.keys[cacheKey] = resolved
+	s.mutex.Unlock()
+	return resolved, nil
+}
+
+// notFound reports whether an error is one of the given API error codes
+func notFound(err error, codes ...string) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.ErrorCode() == code {
+			return true
+		}
+	}
+	return false
+}
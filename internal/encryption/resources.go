@@ -0,0 +1,257 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package encryption
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// scanBucket checks the default encryption of a bucket and, when required, that its
+// policy denies requests not made over TLS
+func (s *Scanner) scanBucket(ctx context.Context, cfg aws.Config, resource Resource) ([]gap, error) {
+	client := s3.NewFromConfig(cfg)
+
+	var gaps []gap
+	out, err := client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(resource.Name)})
+	switch {
+	case notFound(err, "NoSuchBucket"):
+		return nil, nil
+	case notFound(err, "ServerSideEncryptionConfigurationNotFoundError"):
+		gaps = append(gaps, unencrypted("no default encryption"))
+	case err != nil:
+		return nil, fmt.Errorf("failed to read encryption of bucket %s: %w", resource.Name, err)
+	default:
+		var rule *s3types.ServerSideEncryptionByDefault
+		if out.ServerSideEncryptionConfiguration != nil && len(out.ServerSideEncryptionConfiguration.Rules) > 0 {
+			rule = out.ServerSideEncryptionConfiguration.Rules[0].ApplyServerSideEncryptionByDefault
+		}
+		switch {
+		case rule == nil:
+			gaps = append(gaps, unencrypted("no default encryption"))
+		case rule.SSEAlgorithm != s3types.ServerSideEncryptionAwsKms && rule.SSEAlgorithm != s3types.ServerSideEncryptionAwsKmsDsse:
+			if resource.KMS {
+				gaps = append(gaps, weaklyEncrypted("encrypted with %s rather than a KMS key", rule.SSEAlgorithm))
+			}
+		default:
+			g, err := s.checkKey(ctx, cfg, resource, aws.ToString(rule.KMSMasterKeyID))
+			if err != nil {
+				return nil, err
+			}
+			if g != nil {
+				gaps = append(gaps, *g)
+			}
+		}
+	}
+
+	if !resource.SecureTransport {
+		return gaps, nil
+	}
+	policy, err := client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: aws.String(resource.Name)})
+	switch {
+	case notFound(err, "NoSuchBucketPolicy"):
+		gaps = append(gaps, insecureTransport())
+	case err != nil:
+		return nil, fmt.Errorf("failed to read policy of bucket %s: %w", resource.Name, err)
+	default:
+		denies, err := deniesInsecureTransport(aws.ToString(policy.Policy))
+		if err != nil {
+			return nil, fmt.Errorf("bucket %s: %w", resource.Name, err)
+		}
+		if !denies {
+			gaps = append(gaps, insecureTransport())
+		}
+	}
+	return gaps, nil
+}
+
+// insecureTransport returns the gap of a bucket accepting requests not made over TLS
+func insecureTransport() gap {
+	return gap{
+		check:    CheckEncryptionInTransit,
+		severity: report.SeverityMedium,
+		message:  "bucket policy does not deny requests not made over TLS",
+	}
+}
+
+// policyStatement holds the parts of a bucket policy statement the TLS check reads
+type policyStatement struct {
+	Effect    string                            `json:"Effect"`
+	Condition map[string]map[string]interface{} `json:"Condition"`
+}
+
+// deniesInsecureTransport reports whether a bucket policy denies requests whose
+// aws:SecureTransport is false
+func deniesInsecureTransport(document string) (bool, error) {
+	var policy struct {
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal([]byte(document), &policy); err != nil {
+		return false, fmt.Errorf("failed to parse bucket policy: %w", err)
+	}
+
+	var statements []policyStatement
+	if err := json.Unmarshal(policy.Statement, &statements); err != nil {
+		var single policyStatement
+		if err := json.Unmarshal(policy.Statement, &single); err != nil {
+			return false, fmt.Errorf("failed to parse bucket policy statements: %w", err)
+		}
+		statements = []policyStatement{single}
+	}
+
+	for _, statement := range statements {
+		if statement.Effect != "Deny" {
+			continue
+		}
+		for operator, keys := range statement.Condition {
+			if !strings.EqualFold(operator, "Bool") {
+				continue
+			}
+			for key, value := range keys {
+				if strings.EqualFold(key, "aws:SecureTransport") && fmt.Sprint(value) == "false" {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// scanLogGroup checks the key a log group is encrypted with
+func (s *Scanner) scanLogGroup(ctx context.Context, cfg aws.Config, resource Resource) ([]gap, error) {
+	paginator := cloudwatchlogs.NewDescribeLogGroupsPaginator(cloudwatchlogs.NewFromConfig(cfg),
+		&cloudwatchlogs.DescribeLogGroupsInput{LogGroupNamePrefix: aws.String(resource.Name)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe log group %s: %w", resource.Name, err)
+		}
+		for _, logGroup := range page.LogGroups {
+			if aws.ToString(logGroup.LogGroupName) != resource.Name {
+				continue
+			}
+			g, err := s.checkKey(ctx, cfg, resource, aws.ToString(logGroup.KmsKeyId))
+			if err != nil || g == nil {
+				return nil, err
+			}
+			return []gap{*g}, nil
+		}
+	}
+	return nil, nil
+}
+
+// scanParameter checks that a secret parameter is a SecureString encrypted with the
+// configured key
+func (s *Scanner) scanParameter(ctx context.Context, cfg aws.Config, resource Resource) ([]gap, error) {
+	out, err := ssm.NewFromConfig(cfg).DescribeParameters(ctx, &ssm.DescribeParametersInput{
+		ParameterFilters: []ssmtypes.ParameterStringFilter{{
+			Key:    aws.String("Name"),
+			Option: aws.String("Equals"),
+			Values: []string{resource.Name},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe parameter %s: %w", resource.Name, err)
+	}
+	if len(out.Parameters) == 0 {
+		return nil, nil
+	}
+
+	parameter := out.Parameters[0]
+	if parameter.Type != ssmtypes.ParameterTypeSecureString {
+		return []gap{unencrypted(fmt.Sprintf("stored as a %s rather than a SecureString", parameter.Type))}, nil
+	}
+	g, err := s.checkKey(ctx, cfg, resource, aws.ToString(parameter.KeyId))
+	if err != nil || g == nil {
+		return nil, err
+	}
+	return []gap{*g}, nil
+}
+
+// scanSecret checks the key a Secrets Manager secret is encrypted with
+func (s *Scanner) scanSecret(ctx context.Context, cfg aws.Config, resource Resource) ([]gap, error) {
+	out, err := secretsmanager.NewFromConfig(cfg).DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(resource.Name),
+	})
+	switch {
+	case notFound(err, "ResourceNotFoundException"):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to describe secret %s: %w", resource.Name, err)
+	}
+
+	g, err := s.checkKey(ctx, cfg, resource, aws.ToString(out.KmsKeyId))
+	if err != nil || g == nil {
+		return nil, err
+	}
+	return []gap{*g}, nil
+}
+
+// scanTable checks that a table is encrypted with a KMS key rather than a key owned by
+// DynamoDB
+func (s *Scanner) scanTable(ctx context.Context, cfg aws.Config, resource Resource) ([]gap, error) {
+	out, err := dynamodb.NewFromConfig(cfg).DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(resource.Name),
+	})
+	switch {
+	case notFound(err, "ResourceNotFoundException"):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to describe table %s: %w", resource.Name, err)
+	}
+
+	sse := out.Table.SSEDescription
+	if sse == nil || sse.Status != ddbtypes.SSEStatusEnabled {
+		if resource.KMS {
+			return []gap{weaklyEncrypted("encrypted with a key owned by DynamoDB rather than a KMS key")}, nil
+		}
+		return nil, nil
+	}
+	g, err := s.checkKey(ctx, cfg, resource, aws.ToString(sse.KMSMasterKeyArn))
+	if err != nil || g == nil {
+		return nil, err
+	}
+	return []gap{*g}, nil
+}
+
+// scanTopic checks the key a topic is encrypted with
+func (s *Scanner) scanTopic(ctx context.Context, cfg aws.Config, resource Resource) ([]gap, error) {
+	out, err := sns.NewFromConfig(cfg).GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{
+		TopicArn: aws.String(resource.Name),
+	})
+	switch {
+	case notFound(err, "NotFound"):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read topic %s: %w", resource.Name, err)
+	}
+
+	key := out.Attributes["KmsMasterKeyId"]
+	if key == "" {
+		if resource.KMS {
+			return []gap{unencrypted("not encrypted")}, nil
+		}
+		return nil, nil
+	}
+	g, err := s.checkKey(ctx, cfg, resource, key)
+	if err != nil || g == nil {
+		return nil, err
+	}
+	return []gap{*g}, nil
+}
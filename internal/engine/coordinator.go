@@ -0,0 +1,248 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/budget"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/progress"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/stacks"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/stream"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
+	"go.uber.org/zap"
+)
+
+// Coordinator deploys the components of the landing zone, each in its own stack. The
+// stacks of the dependencies of a component are passed to it as stack references.
+type Coordinator struct {
+	logger     *zap.Logger
+	metrics    *metrics.Collector
+	components []stacks.Component
+	stackNames map[string]string
+	stacks     map[string]*auto.Stack
+	guard      *ouGuard
+	output     io.Writer
+	progress   *progress.Display
+	budget     *budget.Budget
+	publisher  *stream.Publisher
+}
+
+// NewCoordinator creates a coordinator for the given components of the environment
+// stack. Component stacks are named <component>-<stack> and live in the Pulumi
+// organization org.
+func NewCoordinator(ctx context.Context, org, stack, workDir string, components []stacks.Component, output io.Writer) (*Coordinator, error) {
+	logger, err := logging.NewLogger("coordinator")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("coordinator")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	ws, err := auto.NewLocalWorkspace(ctx, auto.WorkDir(workDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workspace %s: %w", workDir, err)
+	}
+	project, err := ws.ProjectSettings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project settings: %w", err)
+	}
+
+	// Dependencies are referenced even when they are not part of this run
+	stackNames := make(map[string]string)
+	for _, component := range stacks.Components {
+		stackNames[component.Name] = auto.FullyQualifiedStackName(org, string(project.Name), stacks.StackName(component.Name, stack))
+	}
+
+	guard, err := newOUGuard(ctx, config.DefaultConfig.LandingZoneConfig.DefaultOUName)
+	if err != nil {
+		return nil, err
+	}
+
+	command, err := newInterruptible(config.DefaultConfig.LandingZoneConfig.Timeouts.Grace())
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Coordinator{
+		logger:     logger,
+		metrics:    metrics,
+		components: components,
+		stackNames: stackNames,
+		stacks:     make(map[string]*auto.Stack),
+		guard:      guard,
+		output:     output,
+	}
+
+	for _, component := range components {
+		refs := make(map[string]string)
+		for _, dependency := range component.DependsOn {
+			refs[dependency] = stackNames[dependency]
+		}
+
+		env := map[string]string{
+			stacks.EnvComponent:  component.Name,
+			stacks.EnvReferences: stacks.EncodeReferences(refs),
+			runid.Env:            runid.ID(),
+		}
+
+		s, err := auto.UpsertStackLocalSource(ctx, stackNames[component.Name], workDir, auto.EnvVars(env), auto.Pulumi(command))
+		if err != nil {
+			return nil, fmt.Errorf("failed to select stack %s: %w", stackNames[component.Name], err)
+		}
+		c.stacks[component.Name] = &s
+	}
+
+	return c, nil
+}
+
+// UseProgress shows the progress of updates on the display instead of the raw Pulumi
+// output
+func (c *Coordinator) UseProgress(display *progress.Display) {
+	c.progress = display
+}
+
+// UsePublisher publishes the progress of the updates of the components to the progress
+// server of the serve command
+func (c *Coordinator) UsePublisher(publisher *stream.Publisher) {
+	c.publisher = publisher
+}
+
+// UseBudget bounds the update of each component by the timeouts of its modules and the
+// deadline of the run
+func (c *Coordinator) UseBudget(b *budget.Budget) {
+	c.budget = b
+}
+
+// Preview previews every component in dependency order
+func (c *Coordinator) Preview(ctx context.Context) error {
+	start := time.Now()
+	defer func() {
+		c.metrics.RecordDuration("preview_duration", time.Since(start))
+	}()
+
+	for _, component := range c.components {
+		c.logger.Info("previewing component",
+			zap.String("component", component.Name),
+			zap.String("stack", c.stackNames[component.Name]))
+
+		_, steps, err := previewSteps(ctx, c.stacks[component.Name], optpreview.ProgressStreams(c.output))
+		if err != nil {
+			return fmt.Errorf("preview of component %s failed: %w", component.Name, err)
+		}
+		if err := c.guard.check(ctx, steps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PreviewChanges previews every component in dependency order without progress output
+// and returns the resources that would change in any of their stacks
+func (c *Coordinator) PreviewChanges(ctx context.Context) ([]plan.Change, error) {
+	start := time.Now()
+	defer func() {
+		c.metrics.RecordDuration("preview_duration", time.Since(start))
+	}()
+
+	var planned []plan.Change
+	for _, component := range c.components {
+		_, steps, err := previewSteps(ctx, c.stacks[component.Name], optpreview.SuppressProgress())
+		if err != nil {
+			return planned, fmt.Errorf("preview of component %s failed: %w", component.Name, err)
+		}
+		planned = append(planned, changes(c.stackNames[component.Name], steps)...)
+		if err := c.guard.check(ctx, steps); err != nil {
+			return planned, err
+		}
+	}
+	return planned, nil
+}
+
+// Up updates every component in dependency order, stopping at the first failure so
+// dependent components never read outputs of a failed update. Components deleting
+// organizational units that are not empty are refused. With a budget, the components
+// left once the deadline has passed are not updated.
+func (c *Coordinator) Up(ctx context.Context) error {
+	if err := readonly.Check("deploy"); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer func() {
+		c.metrics.RecordDuration("up_duration", time.Since(start))
+	}()
+
+	for _, component := range c.components {
+		c.logger.Info("updating component",
+			zap.String("component", component.Name),
+			zap.String("stack", c.stackNames[component.Name]))
+
+		update := func(ctx context.Context) error {
+			_, steps, err := previewSteps(ctx, c.stacks[component.Name], optpreview.SuppressProgress())
+			if err != nil {
+				return fmt.Errorf("preview of component %s failed: %w", component.Name, err)
+			}
+			if err := c.guard.check(ctx, steps); err != nil {
+				return err
+			}
+			return c.up(ctx, component.Name)
+		}
+
+		var err error
+		if c.budget != nil {
+			err = c.budget.Run(ctx, component.Name, component.Modules, update)
+		} else {
+			err = update(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		c.metrics.IncrementCounter("components_updated")
+	}
+	return nil
+}
+
+// up updates the stack of a component
+func (c *Coordinator) up(ctx context.Context, name string) error {
+	output := c.output
+	var opts []optup.Option
+	var eventChs []chan<- events.EngineEvent
+	if c.progress != nil {
+		eventCh, wait := c.progress.Track(fmt.Sprintf("update of %s", name))
+		defer wait()
+		eventChs = append(eventChs, eventCh)
+		output = c.progress.Output(c.output)
+	}
+	if c.publisher != nil {
+		eventCh, wait := c.publisher.Track(ctx, c.stackNames[name])
+		defer wait()
+		eventChs = append(eventChs, eventCh)
+	}
+	if len(eventChs) > 0 {
+		opts = append(opts, optup.EventStreams(eventChs...))
+	}
+	opts = append(opts, optup.ProgressStreams(output))
+
+	if _, err := c.stacks[name].Up(ctx, opts...); err != nil {
+		return fmt.Errorf("update of component %s failed: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package engine drives the Pulumi program through the Automation API.
+// Version: 1.0.0
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/budget"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/progress"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/selection"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/stream"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"go.uber.org/zap"
+)
+
+// Runner runs previews and updates of a stack of this project
+type Runner struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	stack     auto.Stack
+	selection *selection.Selection
+	guard     *ouGuard
+	output    io.Writer
+	progress  *progress.Display
+	budget    *budget.Budget
+	publisher *stream.Publisher
+	hold      func(urn, resourceType string) bool
+}
+
+// NewRunner creates a runner for the given stack of the project in workDir. The
+// program is executed by the Pulumi CLI, so the selection is passed through the
+// environment. Updates whose context ends are interrupted rather than killed, so the
+// engine saves the stack before it stops.
+func NewRunner(ctx context.Context, stackName, workDir string, sel *selection.Selection, output io.Writer) (*Runner, error) {
+	logger, err := logging.NewLogger("engine")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("engine")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	env := sel.Env()
+	env[selection.EnvTargeted] = "true"
+	env[runid.Env] = runid.ID()
+
+	command, err := newInterruptible(config.DefaultConfig.LandingZoneConfig.Timeouts.Grace())
+	if err != nil {
+		return nil, err
+	}
+
+	stack, err := auto.UpsertStackLocalSource(ctx, stackName, workDir, auto.EnvVars(env), auto.Pulumi(command))
+	if err != nil {
+		return nil, fmt.Errorf("failed to select stack %s: %w", stackName, err)
+	}
+
+	guard, err := newOUGuard(ctx, config.DefaultConfig.LandingZoneConfig.DefaultOUName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runner{
+		logger:    logger,
+		metrics:   metrics,
+		stack:     stack,
+		selection: sel,
+		guard:     guard,
+		output:    output,
+	}, nil
+}
+
+// UseProgress shows the progress of updates on the display instead of the raw Pulumi
+// output
+func (r *Runner) UseProgress(display *progress.Display) {
+	r.progress = display
+}
+
+// UsePublisher publishes the progress of updates to the progress server of the serve
+// command
+func (r *Runner) UsePublisher(publisher *stream.Publisher) {
+	r.publisher = publisher
+}
+
+// UseBudget bounds updates by the deadline of the run and applies the selected modules
+// one update at a time when module timeouts are configured
+func (r *Runner) UseBudget(b *budget.Budget) {
+	r.budget = b
+}
+
+// SetEnv sets environment variables of the program for the previews and updates that
+// follow
+func (r *Runner) SetEnv(env map[string]string) error {
+	if err := r.stack.Workspace().SetEnvVars(env); err != nil {
+		return fmt.Errorf("failed to set program environment: %w", err)
+	}
+	return nil
+}
+
+// UnsetEnv removes an environment variable set with SetEnv
+func (r *Runner) UnsetEnv(key string) {
+	r.stack.Workspace().UnsetEnvVar(key)
+}
+
+// HoldBack leaves the changes of the resources hold reports untouched by the updates
+// that follow, applying the others as a targeted update. A nil hold applies every
+// change again.
+func (r *Runner) HoldBack(hold func(urn, resourceType string) bool) {
+	r.hold = hold
+}
+
+// ResourceIDs returns the IDs of the resources of a type the stack manages, such as
+// the names of its SSM parameters
+func (r *Runner) ResourceIDs(ctx context.Context, resourceType string) (map[string]bool, error) {
+	exported, err := r.stack.Export(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export stack %s: %w", r.stack.Name(), err)
+	}
+
+	var deployment apitype.DeploymentV3
+	if len(exported.Deployment) > 0 {
+		if err := json.Unmarshal(exported.Deployment, &deployment); err != nil {
+			return nil, fmt.Errorf("failed to decode stack %s: %w", r.stack.Name(), err)
+		}
+	}
+
+	ids := make(map[string]bool)
+	for _, resource := range deployment.Resources {
+		if string(resource.Type) == resourceType && resource.ID != "" {
+			ids[string(resource.ID)] = true
+		}
+	}
+	return ids, nil
+}
+
+// Preview runs a preview of the selected modules. The preview fails when it deletes
+// organizational units that are not empty.
+func (r *Runner) Preview(ctx context.Context) (auto.PreviewResult, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordDuration("preview_duration", time.Since(start))
+	}()
+
+	result, steps, err := previewSteps(ctx, &r.stack, optpreview.ProgressStreams(r.output))
+	if err != nil {
+		return result, fmt.Errorf("preview failed: %w", err)
+	}
+	if err := r.guard.check(ctx, steps); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// PreviewChanges previews the selected modules without progress output and returns the
+// resources that would change
+func (r *Runner) PreviewChanges(ctx context.Context) ([]plan.Change, error) {
+	return r.ObservePreview(ctx, nil)
+}
+
+// ObservePreview previews the selected modules without progress output like
+// PreviewChanges, passing every engine event of the preview to observe as it arrives
+func (r *Runner) ObservePreview(ctx context.Context, observe func(events.EngineEvent)) ([]plan.Change, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordDuration("preview_duration", time.Since(start))
+	}()
+
+	_, steps, err := observeSteps(ctx, &r.stack, observe, optpreview.SuppressProgress())
+	if err != nil {
+		return nil, fmt.Errorf("preview failed: %w", err)
+	}
+
+	planned := changes(r.stack.Name(), steps)
+	if err := r.guard.check(ctx, steps); err != nil {
+		return planned, err
+	}
+	return planned, nil
+}
+
+// Up applies the selected modules. Partial selections are applied as a targeted
+// update restricted to the resources the selected modules register, so resources
+// owned by skipped modules are neither updated nor deleted. The update is refused
+// when it would delete organizational units that are not empty. With a budget using
+// module timeouts, each selected module is applied as its own targeted update, and
+// the modules after one that fails or runs out of time are not applied.
+func (r *Runner) Up(ctx context.Context) (auto.UpResult, error) {
+	if err := readonly.Check("deploy"); err != nil {
+		return auto.UpResult{}, err
+	}
+
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordDuration("up_duration", time.Since(start))
+	}()
+
+	if r.budget == nil {
+		return r.up(ctx, r.selection)
+	}
+
+	var result auto.UpResult
+	if !r.budget.PerModule() {
+		err := r.budget.Run(ctx, "update", r.selection.EnabledModules(), func(ctx context.Context) error {
+			var err error
+			result, err = r.up(ctx, r.selection)
+			return err
+		})
+		return result, err
+	}
+
+	// The selection of the whole run is restored for the commands that follow
+	defer func() {
+		if err := r.stack.Workspace().SetEnvVars(r.selection.Env()); err != nil {
+			r.logger.Warn("failed to restore module selection", zap.Error(err))
+		}
+	}()
+	for _, module := range r.selection.EnabledModules() {
+		sel, err := selection.New([]string{module}, nil)
+		if err != nil {
+			return result, err
+		}
+		if err := r.budget.Run(ctx, module, []string{module}, func(ctx context.Context) error {
+			var err error
+			result, err = r.up(ctx, sel)
+			return err
+		}); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// up applies the modules of a selection
+func (r *Runner) up(ctx context.Context, sel *selection.Selection) (auto.UpResult, error) {
+	if err := r.stack.Workspace().SetEnvVars(sel.Env()); err != nil {
+		return auto.UpResult{}, fmt.Errorf("failed to select modules %s: %w", sel, err)
+	}
+
+	_, steps, err := previewSteps(ctx, &r.stack, optpreview.SuppressProgress())
+	if err != nil {
+		return auto.UpResult{}, fmt.Errorf("failed to preview selected modules: %w", err)
+	}
+	if err := r.guard.check(ctx, steps); err != nil {
+		return auto.UpResult{}, err
+	}
+
+	var opts []optup.Option
+	if sel.Partial() {
+		urns := targets(steps)
+		if r.hold != nil && len(urns) > 0 {
+			urns = released(steps, urns, r.hold)
+		}
+		if len(urns) == 0 {
+			r.logger.Info("selected modules register no resources, nothing to apply",
+				zap.String("modules", sel.String()))
+			return auto.UpResult{}, nil
+		}
+
+		r.logger.Info("applying partial deployment",
+			zap.String("modules", sel.String()),
+			zap.Int("targets", len(urns)))
+		opts = append(opts, optup.Target(urns))
+	} else if r.hold != nil {
+		urns := released(steps, nil, r.hold)
+		if len(urns) == 0 {
+			r.logger.Info("every change is held back, nothing to apply")
+			return auto.UpResult{}, nil
+		}
+		opts = append(opts, optup.Target(urns))
+	}
+
+	output := r.output
+	var eventChs []chan<- events.EngineEvent
+	if r.progress != nil {
+		eventCh, wait := r.progress.Track("update")
+		defer wait()
+		eventChs = append(eventChs, eventCh)
+		output = r.progress.Output(r.output)
+	}
+	if r.publisher != nil {
+		eventCh, wait := r.publisher.Track(ctx, r.stack.Name())
+		defer wait()
+		eventChs = append(eventChs, eventCh)
+	}
+	if len(eventChs) > 0 {
+		opts = append(opts, optup.EventStreams(eventChs...))
+	}
+	opts = append(opts, optup.ProgressStreams(output))
+
+	result, err := r.stack.Up(ctx, opts...)
+	if err != nil {
+		return result, fmt.Errorf("update failed: %w", err)
+	}
+
+	r.metrics.IncrementCounter("updates_applied")
+	return result, nil
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+)
+
+const (
+	// Pulumi CLI found in the PATH, as the Automation API runs it by default
+	pulumiBinary = "pulumi"
+
+	// Flag the Automation API adds to every command so none of them prompts
+	nonInteractiveFlag = "--non-interactive"
+
+	// Exit code of a command that could not be run
+	unknownExitCode = -2
+)
+
+// interruptible runs the Pulumi CLI as the Automation API does, except when the
+// context of a command ends: the Automation API kills the CLI, leaving the resource
+// operations in flight pending in the stack, while interruptible interrupts it as
+// Ctrl-C does. The engine then starts no new operation, waits for those in flight
+// and saves the stack. The CLI is killed if it has not exited after the grace period.
+type interruptible struct {
+	auto.PulumiCommand
+	grace time.Duration
+}
+
+// newInterruptible returns the Pulumi CLI of the PATH, interrupted with the given
+// grace period
+func newInterruptible(grace time.Duration) (auto.PulumiCommand, error) {
+	command, err := auto.NewPulumiCommand(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the Pulumi CLI: %w", err)
+	}
+	return &interruptible{PulumiCommand: command, grace: grace}, nil
+}
+
+// Run implements auto.PulumiCommand
+func (p *interruptible) Run(ctx context.Context, workdir string, stdin io.Reader,
+	additionalOutput []io.Writer, additionalErrorOutput []io.Writer, additionalEnv []string,
+	args ...string) (string, string, int, error) {
+	interactive := true
+	for _, arg := range args {
+		interactive = interactive && arg != nonInteractiveFlag
+	}
+	if interactive {
+		args = append(args, nonInteractiveFlag)
+	}
+
+	cmd := exec.CommandContext(ctx, pulumiBinary, args...)
+	cmd.Dir = workdir
+	cmd.Env = append(os.Environ(), additionalEnv...)
+	cmd.Stdin = stdin
+	interruptOnCancel(cmd)
+	cmd.WaitDelay = p.grace
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = io.MultiWriter(append(additionalOutput, &stdout)...)
+	cmd.Stderr = io.MultiWriter(append(additionalErrorOutput, &stderr)...)
+
+	code := unknownExitCode
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.ExitCode()
+	} else if err == nil {
+		code = 0
+	}
+	return stdout.String(), stderr.String(), code, err
+}
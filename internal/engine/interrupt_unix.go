@@ -0,0 +1,20 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+//go:build !windows
+
+package engine
+
+import (
+	"os"
+	"os/exec"
+)
+
+// interruptOnCancel interrupts the command with SIGINT, as Ctrl-C does, when its
+// context ends
+func interruptOnCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+}
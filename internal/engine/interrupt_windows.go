@@ -0,0 +1,28 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+//go:build windows
+
+package engine
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+var generateConsoleCtrlEvent = syscall.NewLazyDLL("kernel32.dll").NewProc("GenerateConsoleCtrlEvent")
+
+// interruptOnCancel interrupts the command with a Ctrl-Break event when its context
+// ends. Windows cannot deliver SIGINT to another process, and Ctrl-C events are
+// ignored by new process groups, so the command runs in a group of its own that
+// receives Ctrl-Break, which Go programs also read as an interrupt.
+func interruptOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	cmd.Cancel = func() error {
+		if ok, _, err := generateConsoleCtrlEvent.Call(syscall.CTRL_BREAK_EVENT, uintptr(cmd.Process.Pid)); ok == 0 {
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+)
+
+// ouType is the Pulumi type of organizational units
+const ouType = "aws:organizations/organizationalUnit:OrganizationalUnit"
+
+// StrandedOU is an organizational unit the plan deletes although it is not empty
+type StrandedOU struct {
+	Id       string
+	Name     string
+	Accounts []string
+	ChildOUs []string
+}
+
+// NonEmptyOUError is returned when a plan deletes organizational units that still
+// contain accounts or child OUs, which would strand them
+type NonEmptyOUError struct {
+	OUs      []StrandedOU
+	TargetOU string
+	TargetId string
+}
+
+// Error lists the contained accounts and the commands moving them to the target OU
+func (e *NonEmptyOUError) Error() string {
+	var b strings.Builder
+	b.WriteString("refusing to delete organizational units that are not empty:")
+	for _, ou := range e.OUs {
+		fmt.Fprintf(&b, "\n  %s (%s)", ou.Name, ou.Id)
+		if len(ou.Accounts) > 0 {
+			fmt.Fprintf(&b, ": accounts %s", strings.Join(ou.Accounts, ", "))
+		}
+		if len(ou.ChildOUs) > 0 {
+			fmt.Fprintf(&b, ": child OUs %s", strings.Join(ou.ChildOUs, ", "))
+		}
+	}
+
+	target := e.TargetId
+	if target == "" {
+		target = "<target-ou-id>"
+	}
+	fmt.Fprintf(&b, "\nmove the accounts to %s or another OU kept in the configuration first:", e.TargetOU)
+	for _, ou := range e.OUs {
+		for _, account := range ou.Accounts {
+			fmt.Fprintf(&b, "\n  aws organizations move-account --account-id %s --source-parent-id %s --destination-parent-id %s",
+				account, ou.Id, target)
+		}
+	}
+	return b.String()
+}
+
+// ouGuard refuses plans deleting organizational units that are not empty
+type ouGuard struct {
+	client   *organizations.Client
+	targetOU string
+}
+
+// newOUGuard creates a guard suggesting targetOU as the destination of stranded accounts
+func newOUGuard(ctx context.Context, targetOU string) (*ouGuard, error) {
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ouGuard{
+		client:   organizations.NewFromConfig(base),
+		targetOU: targetOU,
+	}, nil
+}
+
+// check returns a NonEmptyOUError when the steps delete an organizational unit that
+// still contains accounts or child OUs
+func (g *ouGuard) check(ctx context.Context, steps []apitype.StepEventMetadata) error {
+	var stranded []StrandedOU
+	for _, step := range steps {
+		if step.Type != ouType || step.Old == nil || step.Old.ID == "" {
+			continue
+		}
+		if step.Op != apitype.OpDelete && step.Op != apitype.OpDeleteReplaced && step.Op != apitype.OpReplace {
+			continue
+		}
+
+		ou, err := g.contents(ctx, step.Old.ID)
+		if err != nil {
+			return err
+		}
+		if len(ou.Accounts) == 0 && len(ou.ChildOUs) == 0 {
+			continue
+		}
+		if name, ok := step.Old.Outputs["name"].(string); ok {
+			ou.Name = name
+		}
+		stranded = append(stranded, ou)
+	}
+
+	if len(stranded) == 0 {
+		return nil
+	}
+
+	sort.Slice(stranded, func(i, j int) bool { return stranded[i].Name < stranded[j].Name })
+	return &NonEmptyOUError{
+		OUs:      stranded,
+		TargetOU: g.targetOU,
+		TargetId: g.targetId(ctx),
+	}
+}
+
+// contents lists the accounts and child OUs of an organizational unit
+func (g *ouGuard) contents(ctx context.Context, id string) (StrandedOU, error) {
+	ou := StrandedOU{Id: id, Name: id}
+
+	accounts := organizations.NewListAccountsForParentPaginator(g.client, &organizations.ListAccountsForParentInput{
+		ParentId: aws.String(id),
+	})
+	for accounts.HasMorePages() {
+		page, err := accounts.NextPage(ctx)
+		if err != nil {
+			return ou, fmt.Errorf("failed to list accounts of OU %s: %w", id, err)
+		}
+		for _, account := range page.Accounts {
+			ou.Accounts = append(ou.Accounts, aws.ToString(account.Id))
+		}
+	}
+
+	children := organizations.NewListOrganizationalUnitsForParentPaginator(g.client, &organizations.ListOrganizationalUnitsForParentInput{
+		ParentId: aws.String(id),
+	})
+	for children.HasMorePages() {
+		page, err := children.NextPage(ctx)
+		if err != nil {
+			return ou, fmt.Errorf("failed to list child OUs of %s: %w", id, err)
+		}
+		for _, child := range page.OrganizationalUnits {
+			ou.ChildOUs = append(ou.ChildOUs, aws.ToString(child.Name))
+		}
+	}
+
+	return ou, nil
+}
+
+// targetId returns the ID of the target OU under the root, or an empty string when it
+// cannot be found. The suggestion is best effort and never fails the check.
+func (g *ouGuard) targetId(ctx context.Context) string {
+	roots, err := g.client.ListRoots(ctx, &organizations.ListRootsInput{})
+	if err != nil || len(roots.Roots) == 0 {
+		return ""
+	}
+
+	paginator := organizations.NewListOrganizationalUnitsForParentPaginator(g.client, &organizations.ListOrganizationalUnitsForParentInput{
+		ParentId: roots.Roots[0].Id,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return ""
+		}
+		for _, ou := range page.OrganizationalUnits {
+			if aws.ToString(ou.Name) == g.targetOU {
+				return aws.ToString(ou.Id)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package engine
+
+import (
+	"context"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+)
+
+// previewSteps previews a stack and returns the resource steps announced by the engine
+func previewSteps(ctx context.Context, stack *auto.Stack, opts ...optpreview.Option) (auto.PreviewResult, []apitype.StepEventMetadata, error) {
+	return observeSteps(ctx, stack, nil, opts...)
+}
+
+// observeSteps previews a stack like previewSteps, passing every engine event to
+// observe as it arrives when observe is set. The engine does not close the channel when
+// the preview fails before it starts, so the events stop being received once the
+// preview returned, whatever its outcome.
+func observeSteps(ctx context.Context, stack *auto.Stack, observe func(events.EngineEvent), opts ...optpreview.Option) (auto.PreviewResult, []apitype.StepEventMetadata, error) {
+	eventCh := make(chan events.EngineEvent)
+	returned := make(chan struct{})
+	done := make(chan []apitype.StepEventMetadata, 1)
+
+	go func() {
+		var steps []apitype.StepEventMetadata
+		defer func() { done <- steps }()
+		for {
+			// The channel is unbuffered, so every event was received once the preview
+			// returned
+			select {
+			case event, ok := <-eventCh:
+				if !ok {
+					return
+				}
+				if observe != nil {
+					observe(event)
+				}
+				if event.ResourcePreEvent != nil {
+					steps = append(steps, event.ResourcePreEvent.Metadata)
+				}
+			case <-returned:
+				return
+			}
+		}
+	}()
+
+	opts = append(opts, optpreview.EventStreams(eventCh))
+	result, err := stack.Preview(ctx, opts...)
+	close(returned)
+	steps := <-done
+	if err != nil {
+		return result, nil, err
+	}
+	return result, steps, nil
+}
+
+// changes returns the steps of a stack whose operation is not a no-op
+func changes(stack string, steps []apitype.StepEventMetadata) []plan.Change {
+	var changes []plan.Change
+	for _, step := range steps {
+		if step.Op == apitype.OpSame || step.Op == apitype.OpRead {
+			continue
+		}
+		changes = append(changes, plan.Change{
+			URN:       step.URN,
+			Type:      step.Type,
+			Operation: string(step.Op),
+			Stack:     stack,
+			Diffs:     step.Diffs,
+		})
+	}
+	return changes
+}
+
+// targets returns the URNs of every resource registered by the program. Resources the
+// preview would delete are no longer registered.
+func targets(steps []apitype.StepEventMetadata) []string {
+	var urns []string
+	seen := make(map[string]bool)
+	for _, step := range steps {
+		if step.Op == apitype.OpDelete || step.Op == apitype.OpDeleteReplaced || seen[step.URN] {
+			continue
+		}
+		seen[step.URN] = true
+		urns = append(urns, step.URN)
+	}
+	return urns
+}
+
+// released returns the URNs of the steps whose changes hold does not hold back,
+// restricted to urns when it is set. Resources without changes are kept, so a
+// targeted update still registers them.
+func released(steps []apitype.StepEventMetadata, urns []string, hold func(urn, resourceType string) bool) []string {
+	allowed := make(map[string]bool, len(urns))
+	for _, urn := range urns {
+		allowed[urn] = true
+	}
+
+	var released []string
+	seen := make(map[string]bool)
+	for _, step := range steps {
+		if seen[step.URN] || (urns != nil && !allowed[step.URN]) {
+			continue
+		}
+		seen[step.URN] = true
+		if step.Op != apitype.OpSame && step.Op != apitype.OpRead && hold(step.URN, step.Type) {
+			continue
+		}
+		released = append(released, step.URN)
+	}
+	return released
+}
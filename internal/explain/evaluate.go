@@ -0,0 +1,296 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package explain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Match is the outcome of matching a request against a statement
+type Match string
+
+const (
+	// MatchYes applies the statement to the request
+	MatchYes Match = "yes"
+
+	// MatchNo leaves the statement out
+	MatchNo Match = "no"
+
+	// MatchMaybe applies the statement to some resources or under conditions the
+	// request does not settle
+	MatchMaybe Match = "maybe"
+)
+
+// Request is the API call explained. Context holds the known condition keys, such as
+// aws:RequestedRegion; other keys make the statements using them inconclusive.
+type Request struct {
+	Action  string            `json:"action"`
+	Context map[string]string `json:"context,omitempty"`
+}
+
+// document is an IAM policy document
+type document struct {
+	Statement statements `json:"Statement"`
+}
+
+// statements accepts a single statement as well as a list
+type statements []statement
+
+func (s *statements) UnmarshalJSON(data []byte) error {
+	var list []statement
+	if err := json.Unmarshal(data, &list); err == nil {
+		*s = list
+		return nil
+	}
+	var single statement
+	if err := json.Unmarshal(data, &single); err != nil {
+		return err
+	}
+	*s = statements{single}
+	return nil
+}
+
+// statement is a statement of an SCP. SCPs have no principals.
+type statement struct {
+	Sid         string                                `json:"Sid"`
+	Effect      string                                `json:"Effect"`
+	Action      values                                `json:"Action"`
+	NotAction   values                                `json:"NotAction"`
+	Resource    values                                `json:"Resource"`
+	NotResource values                                `json:"NotResource"`
+	Condition   map[string]map[string]json.RawMessage `json:"Condition"`
+}
+
+// values accepts a single string as well as a list
+type values []string
+
+func (v *values) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*v = list
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(data, &single); err != nil {
+		return err
+	}
+	*v = values{single}
+	return nil
+}
+
+// StatementMatch is a statement of a policy applying to the request
+type StatementMatch struct {
+	Sid    string `json:"sid"`
+	Effect string `json:"effect"`
+	Match  Match  `json:"match"`
+
+	// Reason explains an inconclusive match
+	Reason string `json:"reason,omitempty"`
+}
+
+// parseDocument parses the content of an SCP
+func parseDocument(content string) (*document, error) {
+	var d document
+	if err := json.Unmarshal([]byte(content), &d); err != nil {
+		return nil, fmt.Errorf("failed to parse policy document: %w", err)
+	}
+	return &d, nil
+}
+
+// evaluate returns the statements of a document applying to the request, possibly
+// or certainly
+func (d *document) evaluate(request Request) []StatementMatch {
+	var matches []StatementMatch
+	for i, s := range d.Statement {
+		sid := s.Sid
+		if sid == "" {
+			sid = fmt.Sprintf("statement %d", i+1)
+		}
+
+		match, reason := s.evaluate(request)
+		if match == MatchNo {
+			continue
+		}
+		matches = append(matches, StatementMatch{Sid: sid, Effect: s.Effect, Match: match, Reason: reason})
+	}
+	return matches
+}
+
+// evaluate matches a statement against the request. The resource of the request is
+// unknown, so a statement limited to some resources matches only maybe.
+func (s *statement) evaluate(request Request) (Match, string) {
+	switch {
+	case len(s.Action) > 0 && !matchesAny(s.Action, request.Action):
+		return MatchNo, ""
+	case len(s.NotAction) > 0 && matchesAny(s.NotAction, request.Action):
+		return MatchNo, ""
+	}
+
+	var reasons []string
+	if len(s.NotResource) > 0 || (len(s.Resource) > 0 && !contains(s.Resource, "*")) {
+		reasons = append(reasons, "applies to some resources only")
+	}
+
+	operators := make([]string, 0, len(s.Condition))
+	for operator := range s.Condition {
+		operators = append(operators, operator)
+	}
+	sort.Strings(operators)
+	for _, operator := range operators {
+		keys := make([]string, 0, len(s.Condition[operator]))
+		for key := range s.Condition[operator] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			match, reason := evaluateCondition(operator, key, s.Condition[operator][key], request.Context)
+			switch match {
+			case MatchNo:
+				return MatchNo, ""
+			case MatchMaybe:
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+
+	if len(reasons) > 0 {
+		return MatchMaybe, strings.Join(reasons, "; ")
+	}
+	return MatchYes, ""
+}
+
+// evaluateCondition evaluates a condition on a single key. Only the string, ARN and
+// boolean operators on single-valued keys are evaluated; the others are inconclusive.
+func evaluateCondition(operator, key string, raw json.RawMessage, context map[string]string) (Match, string) {
+	var expected values
+	if err := json.Unmarshal(raw, &expected); err != nil {
+		var flag bool
+		if err := json.Unmarshal(raw, &flag); err != nil {
+			return MatchMaybe, fmt.Sprintf("condition %s on %s is not understood", operator, key)
+		}
+		expected = values{fmt.Sprint(flag)}
+	}
+
+	// A key missing from the context may still be in the real request, so IfExists
+	// operators are inconclusive on it too
+	base := strings.TrimSuffix(operator, "IfExists")
+	value, known := lookup(context, key)
+	if !known {
+		return MatchMaybe, fmt.Sprintf("depends on %s", key)
+	}
+
+	var equal func(pattern, value string) bool
+	negated := false
+	switch base {
+	case "StringEquals", "ArnEquals":
+		equal = func(pattern, value string) bool { return pattern == value }
+	case "StringNotEquals", "ArnNotEquals":
+		equal, negated = func(pattern, value string) bool { return pattern == value }, true
+	case "StringEqualsIgnoreCase":
+		equal = strings.EqualFold
+	case "StringNotEqualsIgnoreCase":
+		equal, negated = strings.EqualFold, true
+	case "StringLike", "ArnLike":
+		equal = wildcard
+	case "StringNotLike", "ArnNotLike":
+		equal, negated = wildcard, true
+	case "Bool":
+		equal = strings.EqualFold
+	default:
+		return MatchMaybe, fmt.Sprintf("condition %s on %s is not evaluated", operator, key)
+	}
+
+	matched := false
+	for _, pattern := range expected {
+		if equal(pattern, value) {
+			matched = true
+			break
+		}
+	}
+	if matched != negated {
+		return MatchYes, ""
+	}
+	return MatchNo, ""
+}
+
+// lookup returns the value of a condition key, whose names are case-insensitive
+func lookup(context map[string]string, key string) (string, bool) {
+	for name, value := range context {
+		if strings.EqualFold(name, key) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// matchesAny reports whether an action matches one of the patterns, ignoring case
+func matchesAny(patterns []string, action string) bool {
+	for _, pattern := range patterns {
+		if wildcard(strings.ToLower(pattern), strings.ToLower(action)) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcard matches a value against a pattern with the * and ? wildcards of IAM
+func wildcard(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return wildcardPart(pattern, value)
+	}
+	return wildcardSplit(parts, value)
+}
+
+// wildcardSplit matches a value against the parts of a pattern between its *
+func wildcardSplit(parts []string, value string) bool {
+	first, last := parts[0], parts[len(parts)-1]
+	if len(value) < len(first) || !wildcardPart(first, value[:len(first)]) {
+		return false
+	}
+	value = value[len(first):]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		found := false
+		for i := 0; i+len(part) <= len(value); i++ {
+			if wildcardPart(part, value[i:i+len(part)]) {
+				value = value[i+len(part):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return len(value) >= len(last) && wildcardPart(last, value[len(value)-len(last):])
+}
+
+// wildcardPart matches a value of the same length against a pattern with ?
+func wildcardPart(pattern, value string) bool {
+	if len(pattern) != len(value) {
+		return false
+	}
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '?' && pattern[i] != value[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// contains reports whether a list holds a value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,367 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package explain provides the evaluation of the SCPs applying an action to an account.
+// Version: 1.0.0
+package explain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"go.uber.org/zap"
+)
+
+// Decision is the verdict of the SCPs on an action
+type Decision string
+
+const (
+	// DecisionAllowed lets the action through the SCPs; IAM policies still apply
+	DecisionAllowed Decision = "allowed"
+
+	// DecisionDenied blocks the action by an explicit deny, or by a level of the
+	// hierarchy none of whose SCPs allows it
+	DecisionDenied Decision = "denied"
+
+	// DecisionConditional depends on the resource or on condition keys the request
+	// does not settle
+	DecisionConditional Decision = "conditional"
+)
+
+// ConditionKeyRegion is the condition key of the region of a request
+const ConditionKeyRegion = "aws:RequestedRegion"
+
+// Policy is an SCP attached to a level of the hierarchy with its statements applying
+// to the request
+type Policy struct {
+	ID         string           `json:"id"`
+	Name       string           `json:"name"`
+	Statements []StatementMatch `json:"statements,omitempty"`
+}
+
+// Level is the root, an OU or the account, with the SCPs attached to it
+type Level struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Policies []Policy `json:"policies"`
+
+	// Allow is whether an SCP of the level allows the action
+	Allow Match `json:"allow"`
+}
+
+// Denial is a statement denying the action, certainly or possibly
+type Denial struct {
+	Level  string `json:"level"`
+	Policy string `json:"policy"`
+	Sid    string `json:"sid"`
+	Match  Match  `json:"match"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Result explains the decision of the SCPs on an action in an account. Levels run
+// from the root to the account.
+type Result struct {
+	AccountID string   `json:"accountId"`
+	Request   Request  `json:"request"`
+	Decision  Decision `json:"decision"`
+	Reason    string   `json:"reason"`
+	Levels    []Level  `json:"levels"`
+	Denials   []Denial `json:"denials,omitempty"`
+}
+
+// Explainer reads the SCPs of the live organization
+type Explainer struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	orgClient *organizations.Client
+	documents map[string]*document
+	attached  map[string][]attachedPolicy
+}
+
+// attachedPolicy is an SCP attached to a level with its parsed document
+type attachedPolicy struct {
+	id       string
+	name     string
+	document *document
+}
+
+// NewExplainer creates an explainer using the credentials of the management account
+func NewExplainer(ctx context.Context) (*Explainer, error) {
+	logger, err := logging.NewLogger("explain")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("explain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Explainer{
+		logger:    logger,
+		metrics:   metrics,
+		orgClient: organizations.NewFromConfig(base),
+		documents: make(map[string]*document),
+		attached:  make(map[string][]attachedPolicy),
+	}, nil
+}
+
+// Explain walks the SCPs attached from the root of the organization down to an
+// account and evaluates the request against them. An action goes through when no
+// statement denies it and an SCP of every level allows it. SCPs do not apply to the
+// management account.
+func (e *Explainer) Explain(ctx context.Context, accountID string, request Request) (*Result, error) {
+	start := time.Now()
+	defer func() {
+		e.metrics.RecordDuration("explain", time.Since(start))
+	}()
+
+	result := &Result{AccountID: accountID, Request: request}
+
+	org, err := e.orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe organization: %w", err)
+	}
+	if aws.ToString(org.Organization.MasterAccountId) == accountID {
+		result.Decision = DecisionAllowed
+		result.Reason = "SCPs do not apply to the management account"
+		return result, nil
+	}
+
+	account, err := e.orgClient.DescribeAccount(ctx, &organizations.DescribeAccountInput{AccountId: aws.String(accountID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe account %s: %w", accountID, err)
+	}
+
+	levels, err := e.chain(ctx, accountID, aws.ToString(account.Account.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range levels {
+		policies, err := e.policies(ctx, levels[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		evaluateLevel(&levels[i], policies, request, result)
+	}
+	result.Levels = levels
+	decide(result)
+
+	e.metrics.IncrementCounter("explanations")
+	e.logger.Info("action explained",
+		zap.String("accountId", accountID),
+		zap.String("action", request.Action),
+		zap.String("decision", string(result.Decision)))
+	return result, nil
+}
+
+// chain returns the levels of the hierarchy from the root down to the account
+func (e *Explainer) chain(ctx context.Context, accountID, accountName string) ([]Level, error) {
+	levels := []Level{{ID: accountID, Name: accountName, Type: "account"}}
+
+	childID := accountID
+	for {
+		parents, err := e.orgClient.ListParents(ctx, &organizations.ListParentsInput{ChildId: aws.String(childID)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list parents of %s: %w", childID, err)
+		}
+		if len(parents.Parents) == 0 {
+			return nil, fmt.Errorf("%s has no parent", childID)
+		}
+
+		parent := parents.Parents[0]
+		parentID := aws.ToString(parent.Id)
+		if parent.Type == orgtypes.ParentTypeRoot {
+			levels = append(levels, Level{ID: parentID, Name: "Root", Type: "root"})
+			break
+		}
+
+		ou, err := e.orgClient.DescribeOrganizationalUnit(ctx, &organizations.DescribeOrganizationalUnitInput{
+			OrganizationalUnitId: aws.String(parentID),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe OU %s: %w", parentID, err)
+		}
+		levels = append(levels, Level{ID: parentID, Name: aws.ToString(ou.OrganizationalUnit.Name), Type: "ou"})
+		childID = parentID
+	}
+
+	// Root first
+	for i, j := 0, len(levels)-1; i < j; i, j = i+1, j-1 {
+		levels[i], levels[j] = levels[j], levels[i]
+	}
+	return levels, nil
+}
+
+// policies returns the SCPs attached to a target sorted by name, read once
+func (e *Explainer) policies(ctx context.Context, targetID string) ([]attachedPolicy, error) {
+	if policies, ok := e.attached[targetID]; ok {
+		return policies, nil
+	}
+
+	var policies []attachedPolicy
+	paginator := organizations.NewListPoliciesForTargetPaginator(e.orgClient, &organizations.ListPoliciesForTargetInput{
+		TargetId: aws.String(targetID),
+		Filter:   orgtypes.PolicyTypeServiceControlPolicy,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list policies of %s: %w", targetID, err)
+		}
+		for _, summary := range page.Policies {
+			d, err := e.document(ctx, aws.ToString(summary.Id))
+			if err != nil {
+				return nil, err
+			}
+			policies = append(policies, attachedPolicy{id: aws.ToString(summary.Id), name: aws.ToString(summary.Name), document: d})
+		}
+	}
+
+	sort.Slice(policies, func(i, j int) bool { return policies[i].name < policies[j].name })
+	e.attached[targetID] = policies
+	return policies, nil
+}
+
+// evaluateLevel evaluates the request against the SCPs attached to a level, records
+// whether one of them allows it and the statements denying it
+func evaluateLevel(level *Level, policies []attachedPolicy, request Request, result *Result) {
+	level.Allow = MatchNo
+	for _, attached := range policies {
+		policy := Policy{ID: attached.id, Name: attached.name}
+		policy.Statements = attached.document.evaluate(request)
+		for _, s := range policy.Statements {
+			if strings.EqualFold(s.Effect, "Deny") {
+				result.Denials = append(result.Denials, Denial{
+					Level:  level.Name,
+					Policy: policy.Name,
+					Sid:    s.Sid,
+					Match:  s.Match,
+					Reason: s.Reason,
+				})
+				continue
+			}
+			if s.Match == MatchYes || level.Allow == MatchNo {
+				level.Allow = s.Match
+			}
+		}
+		level.Policies = append(level.Policies, policy)
+	}
+}
+
+// document returns the parsed content of an SCP, read once
+func (e *Explainer) document(ctx context.Context, policyID string) (*document, error) {
+	if d, ok := e.documents[policyID]; ok {
+		return d, nil
+	}
+
+	out, err := e.orgClient.DescribePolicy(ctx, &organizations.DescribePolicyInput{PolicyId: aws.String(policyID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe policy %s: %w", policyID, err)
+	}
+	d, err := parseDocument(aws.ToString(out.Policy.Content))
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", policyID, err)
+	}
+	e.documents[policyID] = d
+	return d, nil
+}
+
+// decide settles the decision from the denials and the allows of the levels
+func decide(result *Result) {
+	for _, denial := range result.Denials {
+		if denial.Match == MatchYes {
+			result.Decision = DecisionDenied
+			result.Reason = fmt.Sprintf("denied by statement %s of policy %s attached to %s",
+				denial.Sid, denial.Policy, denial.Level)
+			return
+		}
+	}
+	for _, level := range result.Levels {
+		if level.Allow == MatchNo {
+			result.Decision = DecisionDenied
+			result.Reason = fmt.Sprintf("no policy attached to %s allows the action", level.Name)
+			return
+		}
+	}
+
+	if len(result.Denials) > 0 {
+		denial := result.Denials[0]
+		result.Decision = DecisionConditional
+		result.Reason = fmt.Sprintf("statement %s of policy %s attached to %s may deny the action: %s",
+			denial.Sid, denial.Policy, denial.Level, denial.Reason)
+		return
+	}
+	for _, level := range result.Levels {
+		if level.Allow == MatchMaybe {
+			result.Decision = DecisionConditional
+			result.Reason = fmt.Sprintf("the policies attached to %s allow the action only conditionally", level.Name)
+			return
+		}
+	}
+
+	result.Decision = DecisionAllowed
+	result.Reason = "every level allows the action and no statement denies it"
+}
+
+// Write renders a result as text or JSON
+func Write(w io.Writer, format string, result *Result) error {
+	switch format {
+	case report.FormatText:
+		writeText(w, result)
+		return nil
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("failed to encode explanation: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// writeText writes the decision, then the policies of each level
+func writeText(w io.Writer, result *Result) {
+	request := result.Request.Action
+	if region := result.Request.Context[ConditionKeyRegion]; region != "" {
+		request += " in " + region
+	}
+	fmt.Fprintf(w, "%s for account %s: %s\n", request, result.AccountID, strings.ToUpper(string(result.Decision)))
+	fmt.Fprintf(w, "  %s\n", result.Reason)
+
+	for _, level := range result.Levels {
+		fmt.Fprintf(w, "\n%s %s (%s): allow %s\n", level.Type, level.Name, level.ID, level.Allow)
+		for _, policy := range level.Policies {
+			fmt.Fprintf(w, "  %s (%s)\n", policy.Name, policy.ID)
+			for _, s := range policy.Statements {
+				line := fmt.Sprintf("    %s %s: %s", s.Effect, s.Sid, s.Match)
+				if s.Reason != "" {
+					line += " (" + s.Reason + ")"
+				}
+				fmt.Fprintln(w, line)
+			}
+		}
+	}
+}
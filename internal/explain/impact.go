@@ -0,0 +1,189 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package explain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"go.uber.org/zap"
+)
+
+// Condition keys set from the calls whose impact is analyzed
+const (
+	ConditionKeyPrincipalArn     = "aws:PrincipalArn"
+	ConditionKeyPrincipalAccount = "aws:PrincipalAccount"
+)
+
+// proposedPolicyID identifies the proposed SCP among the attached ones
+const proposedPolicyID = "proposed"
+
+// Proposal is an SCP change analyzed before it is made: the policy Content attached
+// to Target, the root, an OU or an account, in place of the policy Replaces when set
+type Proposal struct {
+	Name     string `json:"name"`
+	Target   string `json:"target"`
+	Replaces string `json:"replaces,omitempty"`
+	Content  string `json:"-"`
+}
+
+// Call is an API call observed in an account, with the number of times it was made
+type Call struct {
+	AccountID string `json:"accountId"`
+	Principal string `json:"principal"`
+	Action    string `json:"action"`
+	Region    string `json:"region"`
+	Count     int64  `json:"count"`
+}
+
+// ImpactedCall is an observed call the SCPs let through that the proposal denies,
+// certainly or conditionally
+type ImpactedCall struct {
+	Call
+	Decision Decision `json:"decision"`
+	Reason   string   `json:"reason"`
+}
+
+// Impact evaluates calls against the SCPs of their account as attached now and as
+// they would be with the proposal, and returns the calls allowed now that the
+// proposal denies, most frequent first. Calls of accounts below another target, of
+// the management account or already denied are left out.
+func (e *Explainer) Impact(ctx context.Context, proposal Proposal, calls []Call) ([]ImpactedCall, error) {
+	start := time.Now()
+	defer func() {
+		e.metrics.RecordDuration("impact", time.Since(start))
+	}()
+
+	proposed, err := parseDocument(proposal.Content)
+	if err != nil {
+		return nil, fmt.Errorf("proposed policy %s: %w", proposal.Name, err)
+	}
+	target, err := e.policies(ctx, proposal.Target)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := replacePolicy(target, proposal, proposed)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := e.orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe organization: %w", err)
+	}
+	managementID := aws.ToString(org.Organization.MasterAccountId)
+
+	// Levels of the accounts below the target, nil for the others
+	chains := make(map[string][]Level)
+	var impacted []ImpactedCall
+	for _, call := range calls {
+		if call.AccountID == managementID {
+			continue
+		}
+		levels, ok := chains[call.AccountID]
+		if !ok {
+			if levels, err = e.targetChain(ctx, call.AccountID, proposal.Target); err != nil {
+				return nil, err
+			}
+			chains[call.AccountID] = levels
+		}
+		if levels == nil {
+			continue
+		}
+
+		request := Request{
+			Action: call.Action,
+			Context: map[string]string{
+				ConditionKeyRegion:           call.Region,
+				ConditionKeyPrincipalArn:     call.Principal,
+				ConditionKeyPrincipalAccount: call.AccountID,
+			},
+		}
+		before, err := e.evaluateChain(ctx, levels, request, proposal.Target, nil)
+		if err != nil {
+			return nil, err
+		}
+		after, err := e.evaluateChain(ctx, levels, request, proposal.Target, changed)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case before.Decision == DecisionDenied:
+		case after.Decision == DecisionDenied,
+			after.Decision == DecisionConditional && before.Decision == DecisionAllowed:
+			impacted = append(impacted, ImpactedCall{Call: call, Decision: after.Decision, Reason: after.Reason})
+		}
+	}
+
+	sort.SliceStable(impacted, func(i, j int) bool { return impacted[i].Count > impacted[j].Count })
+
+	e.metrics.IncrementCounter("impact_analyses")
+	e.logger.Info("SCP change impact analyzed",
+		zap.String("policy", proposal.Name),
+		zap.String("target", proposal.Target),
+		zap.Int("calls", len(calls)),
+		zap.Int("impacted", len(impacted)))
+	return impacted, nil
+}
+
+// replacePolicy returns the policies attached to the target of a proposal once it is
+// made
+func replacePolicy(attached []attachedPolicy, proposal Proposal, proposed *document) ([]attachedPolicy, error) {
+	changed := []attachedPolicy{{id: proposedPolicyID, name: proposal.Name, document: proposed}}
+	replaced := proposal.Replaces == ""
+	for _, policy := range attached {
+		if policy.id == proposal.Replaces || policy.name == proposal.Replaces {
+			replaced = true
+			continue
+		}
+		changed = append(changed, policy)
+	}
+	if !replaced {
+		return nil, fmt.Errorf("policy %s is not attached to %s", proposal.Replaces, proposal.Target)
+	}
+
+	sort.Slice(changed, func(i, j int) bool { return changed[i].name < changed[j].name })
+	return changed, nil
+}
+
+// targetChain returns the levels from the root down to an account when the target is
+// one of them, and nil otherwise
+func (e *Explainer) targetChain(ctx context.Context, accountID, targetID string) ([]Level, error) {
+	levels, err := e.chain(ctx, accountID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	for _, level := range levels {
+		if level.ID == targetID {
+			return levels, nil
+		}
+	}
+	return nil, nil
+}
+
+// evaluateChain decides a request against the SCPs of the levels, with the policies of
+// the target replaced by target when set
+func (e *Explainer) evaluateChain(ctx context.Context, levels []Level, request Request, targetID string, target []attachedPolicy) (*Result, error) {
+	result := &Result{Request: request}
+	for _, chainLevel := range levels {
+		level := Level{ID: chainLevel.ID, Name: chainLevel.Name, Type: chainLevel.Type}
+		policies := target
+		if target == nil || level.ID != targetID {
+			var err error
+			if policies, err = e.policies(ctx, level.ID); err != nil {
+				return nil, err
+			}
+		}
+		evaluateLevel(&level, policies, request, result)
+		result.Levels = append(result.Levels, level)
+	}
+	decide(result)
+	return result, nil
+}
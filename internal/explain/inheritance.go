@@ -0,0 +1,300 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package explain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"go.uber.org/zap"
+)
+
+// FormatMarkdown renders the inheritance chains as Markdown tables
+const FormatMarkdown = "markdown"
+
+// InheritedStatement is a statement of an SCP in the inheritance chain of an account
+type InheritedStatement struct {
+	Sid          string   `json:"sid"`
+	Effect       string   `json:"effect"`
+	Actions      []string `json:"actions,omitempty"`
+	NotActions   []string `json:"notActions,omitempty"`
+	Resources    []string `json:"resources,omitempty"`
+	NotResources []string `json:"notResources,omitempty"`
+
+	// Conditions lists the condition operators and keys, as Operator:key
+	Conditions []string `json:"conditions,omitempty"`
+}
+
+// InheritedPolicy is an SCP attached to a level of the hierarchy with its statements
+type InheritedPolicy struct {
+	ID         string               `json:"id"`
+	Name       string               `json:"name"`
+	Statements []InheritedStatement `json:"statements"`
+}
+
+// InheritedLevel is the root, an OU or the account, with the SCPs attached to it
+type InheritedLevel struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Policies []InheritedPolicy `json:"policies"`
+}
+
+// Inheritance is the chain of SCPs applying to an account, from the root down to the
+// account. The management account has no chain, SCPs do not apply to it.
+type Inheritance struct {
+	AccountID   string           `json:"accountId"`
+	AccountName string           `json:"accountName"`
+	Management  bool             `json:"management,omitempty"`
+	Levels      []InheritedLevel `json:"levels,omitempty"`
+}
+
+// Inheritance resolves the SCP inheritance chain of each account, or of every account
+// of the organization when none is given, sorted by account name. Every statement is
+// listed with the level and policy it originates from.
+func (e *Explainer) Inheritance(ctx context.Context, accountIDs ...string) ([]*Inheritance, error) {
+	start := time.Now()
+	defer func() {
+		e.metrics.RecordDuration("inheritance", time.Since(start))
+	}()
+
+	org, err := e.orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe organization: %w", err)
+	}
+	managementID := aws.ToString(org.Organization.MasterAccountId)
+
+	names, err := e.accountNames(ctx, accountIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var chains []*Inheritance
+	for accountID, name := range names {
+		chain := &Inheritance{AccountID: accountID, AccountName: name}
+		chains = append(chains, chain)
+		if accountID == managementID {
+			chain.Management = true
+			continue
+		}
+
+		levels, err := e.chain(ctx, accountID, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, level := range levels {
+			policies, err := e.inheritedPolicies(ctx, level.ID)
+			if err != nil {
+				return nil, err
+			}
+			chain.Levels = append(chain.Levels, InheritedLevel{
+				ID:       level.ID,
+				Name:     level.Name,
+				Type:     level.Type,
+				Policies: policies,
+			})
+		}
+	}
+
+	sort.Slice(chains, func(i, j int) bool {
+		if chains[i].AccountName != chains[j].AccountName {
+			return chains[i].AccountName < chains[j].AccountName
+		}
+		return chains[i].AccountID < chains[j].AccountID
+	})
+
+	e.metrics.IncrementCounter("inheritance_reports")
+	e.logger.Info("SCP inheritance resolved", zap.Int("accounts", len(chains)))
+	return chains, nil
+}
+
+// accountNames returns the names of the given accounts, or of every account of the
+// organization when none is given, keyed by account ID
+func (e *Explainer) accountNames(ctx context.Context, accountIDs []string) (map[string]string, error) {
+	names := make(map[string]string)
+	if len(accountIDs) == 0 {
+		paginator := organizations.NewListAccountsPaginator(e.orgClient, &organizations.ListAccountsInput{})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list accounts: %w", err)
+			}
+			for _, account := range page.Accounts {
+				names[aws.ToString(account.Id)] = aws.ToString(account.Name)
+			}
+		}
+		return names, nil
+	}
+
+	for _, accountID := range accountIDs {
+		account, err := e.orgClient.DescribeAccount(ctx, &organizations.DescribeAccountInput{AccountId: aws.String(accountID)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe account %s: %w", accountID, err)
+		}
+		names[accountID] = aws.ToString(account.Account.Name)
+	}
+	return names, nil
+}
+
+// inheritedPolicies returns the SCPs attached to a target with every statement,
+// sorted by name
+func (e *Explainer) inheritedPolicies(ctx context.Context, targetID string) ([]InheritedPolicy, error) {
+	attached, err := e.policies(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]InheritedPolicy, 0, len(attached))
+	for _, a := range attached {
+		policy := InheritedPolicy{ID: a.id, Name: a.name}
+		for i, s := range a.document.Statement {
+			policy.Statements = append(policy.Statements, s.inherited(i))
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// inherited describes the i-th statement of a document
+func (s *statement) inherited(i int) InheritedStatement {
+	sid := s.Sid
+	if sid == "" {
+		sid = fmt.Sprintf("statement %d", i+1)
+	}
+
+	var conditions []string
+	for operator, keys := range s.Condition {
+		for key := range keys {
+			conditions = append(conditions, operator+":"+key)
+		}
+	}
+	sort.Strings(conditions)
+
+	return InheritedStatement{
+		Sid:          sid,
+		Effect:       s.Effect,
+		Actions:      s.Action,
+		NotActions:   s.NotAction,
+		Resources:    s.Resource,
+		NotResources: s.NotResource,
+		Conditions:   conditions,
+	}
+}
+
+// WriteInheritance renders the inheritance chains as text, JSON or Markdown
+func WriteInheritance(w io.Writer, format string, chains []*Inheritance) error {
+	switch format {
+	case report.FormatText:
+		writeInheritanceText(w, chains)
+		return nil
+	case report.FormatJSON:
+		if chains == nil {
+			chains = []*Inheritance{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(chains); err != nil {
+			return fmt.Errorf("failed to encode SCP inheritance: %w", err)
+		}
+		return nil
+	case FormatMarkdown:
+		writeInheritanceMarkdown(w, chains)
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// writeInheritanceText writes the levels of each account, then the statements of
+// their policies
+func writeInheritanceText(w io.Writer, chains []*Inheritance) {
+	for i, chain := range chains {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s)\n", chain.AccountName, chain.AccountID)
+		if chain.Management {
+			fmt.Fprintln(w, "  management account, SCPs do not apply")
+			continue
+		}
+		for _, level := range chain.Levels {
+			fmt.Fprintf(w, "  %s %s (%s)\n", level.Type, level.Name, level.ID)
+			for _, policy := range level.Policies {
+				fmt.Fprintf(w, "    %s (%s)\n", policy.Name, policy.ID)
+				for _, s := range policy.Statements {
+					fmt.Fprintf(w, "      %s %s: %s\n", s.Effect, s.Sid, statementScope(s))
+				}
+			}
+		}
+	}
+}
+
+// writeInheritanceMarkdown writes a table per account, with a row per statement and
+// the level and policy it originates from
+func writeInheritanceMarkdown(w io.Writer, chains []*Inheritance) {
+	fmt.Fprintln(w, "# SCP Inheritance")
+	for _, chain := range chains {
+		fmt.Fprintf(w, "\n## %s (%s)\n\n", markdownEscape(chain.AccountName), chain.AccountID)
+		if chain.Management {
+			fmt.Fprintln(w, "Management account, SCPs do not apply.")
+			continue
+		}
+
+		path := make([]string, 0, len(chain.Levels))
+		for _, level := range chain.Levels {
+			path = append(path, markdownEscape(level.Name))
+		}
+		fmt.Fprintf(w, "%s\n\n", strings.Join(path, " → "))
+
+		fmt.Fprintln(w, "| Level | Policy | Statement | Effect | Scope |")
+		fmt.Fprintln(w, "|-------|--------|-----------|--------|-------|")
+		for _, level := range chain.Levels {
+			if len(level.Policies) == 0 {
+				fmt.Fprintf(w, "| %s %s | none | | | |\n", level.Type, markdownEscape(level.Name))
+			}
+			for _, policy := range level.Policies {
+				for _, s := range policy.Statements {
+					fmt.Fprintf(w, "| %s %s | %s | %s | %s | %s |\n", level.Type, markdownEscape(level.Name),
+						markdownEscape(policy.Name), markdownEscape(s.Sid), s.Effect, markdownEscape(statementScope(s)))
+				}
+			}
+		}
+	}
+}
+
+// statementScope summarizes the actions, resources and conditions of a statement
+func statementScope(s InheritedStatement) string {
+	var parts []string
+	if len(s.Actions) > 0 {
+		parts = append(parts, strings.Join(s.Actions, ", "))
+	}
+	if len(s.NotActions) > 0 {
+		parts = append(parts, "all but "+strings.Join(s.NotActions, ", "))
+	}
+	if len(s.Resources) > 0 && !(len(s.Resources) == 1 && s.Resources[0] == "*") {
+		parts = append(parts, "on "+strings.Join(s.Resources, ", "))
+	}
+	if len(s.NotResources) > 0 {
+		parts = append(parts, "except on "+strings.Join(s.NotResources, ", "))
+	}
+	if len(s.Conditions) > 0 {
+		parts = append(parts, "when "+strings.Join(s.Conditions, ", "))
+	}
+	return strings.Join(parts, " ")
+}
+
+// markdownEscape keeps a value from breaking the Markdown table it is rendered in
+func markdownEscape(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	return strings.ReplaceAll(value, "\n", " ")
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package export
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/invitations"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+)
+
+// Live holds the identifiers of the resources of the live organization the exported
+// model is matched with: OUs below the root by name, accounts by email and policies
+// by type and name. The service principals and policy types enabled in the
+// organization are kept, so adopting it changes neither.
+type Live struct {
+	OrganizationID    string
+	RootID            string
+	ServicePrincipals []string
+	PolicyTypes       []string
+	OUs               map[string]string
+	Accounts          map[string]string
+	Policies          map[string]string
+}
+
+// ReadLive reads the identifiers of the live organization through the cache
+func ReadLive(ctx context.Context, cache *orgcache.Cache) (*Live, error) {
+	client := organizations.NewFromConfig(cache.OrganizationConfig())
+
+	org, err := client.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe organization: %w", err)
+	}
+
+	live := &Live{
+		OrganizationID: aws.ToString(org.Organization.Id),
+		OUs:            make(map[string]string),
+		Accounts:       make(map[string]string),
+		Policies:       make(map[string]string),
+	}
+
+	if live.RootID, err = cache.RootID(ctx); err != nil {
+		return nil, err
+	}
+
+	services := organizations.NewListAWSServiceAccessForOrganizationPaginator(client,
+		&organizations.ListAWSServiceAccessForOrganizationInput{})
+	for services.HasMorePages() {
+		page, err := services.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list trusted services: %w", err)
+		}
+		for _, service := range page.EnabledServicePrincipals {
+			live.ServicePrincipals = append(live.ServicePrincipals, aws.ToString(service.ServicePrincipal))
+		}
+	}
+	sort.Strings(live.ServicePrincipals)
+
+	roots, err := client.ListRoots(ctx, &organizations.ListRootsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roots: %w", err)
+	}
+	for _, root := range roots.Roots {
+		for _, policyType := range root.PolicyTypes {
+			if policyType.Status != orgtypes.PolicyTypeStatusPendingDisable {
+				live.PolicyTypes = append(live.PolicyTypes, string(policyType.Type))
+			}
+		}
+	}
+	sort.Strings(live.PolicyTypes)
+
+	ous, err := cache.OUs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, ou := range ous {
+		if ou.ParentID == live.RootID {
+			live.OUs[ou.Name] = ou.ID
+		}
+	}
+
+	accounts, err := cache.Accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		live.Accounts[aws.ToString(account.Email)] = aws.ToString(account.Id)
+	}
+
+	for _, policyType := range []orgtypes.PolicyType{orgtypes.PolicyTypeServiceControlPolicy, orgtypes.PolicyTypeTagPolicy} {
+		policies, err := cache.Policies(ctx, policyType)
+		if err != nil {
+			return nil, err
+		}
+		for _, policy := range policies {
+			if policy.AwsManaged {
+				continue
+			}
+			live.Policies[policyKey(string(policyType), aws.ToString(policy.Name))] = aws.ToString(policy.Id)
+		}
+	}
+
+	return live, nil
+}
+
+// ou returns the ID of the OU of the given name below the root
+func (l *Live) ou(name string) string {
+	if l == nil {
+		return ""
+	}
+	return l.OUs[name]
+}
+
+// account returns the ID of the account of the given email
+func (l *Live) account(email string) string {
+	if l == nil {
+		return ""
+	}
+	return l.Accounts[email]
+}
+
+// policy returns the ID of the policy of the given type and name
+func (l *Live) policy(policyType, name string) string {
+	if l == nil {
+		return ""
+	}
+	return l.Policies[policyKey(policyType, name)]
+}
+
+// target returns the ID of a policy target
+func (l *Live) target(cfg *config.LandingZoneConfig, target string) string {
+	switch {
+	case l == nil:
+		return ""
+	case target == config.PolicyTargetRoot:
+		return l.RootID
+	case accountIdRE.MatchString(target):
+		return target
+	default:
+		return l.OUs[invitations.OUName(cfg, target)]
+	}
+}
+
+// policyKey returns the key of a policy in the live identifiers
+func policyKey(policyType, name string) string {
+	return policyType + "/" + name
+}
@@ -0,0 +1,355 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package export provides the rendering of the organization model for other infrastructure as code tools.
+// Version: 1.0.0
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/invitations"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/quarantine"
+)
+
+const (
+	// Terraform resource types of the organization model
+	typeOrganization = "aws_organizations_organization"
+	typeOU           = "aws_organizations_organizational_unit"
+	typeAccount      = "aws_organizations_account"
+	typePolicy       = "aws_organizations_policy"
+	typeAttachment   = "aws_organizations_policy_attachment"
+
+	// Expression of the root of the organization
+	rootExpression = typeOrganization + ".this.roots[0].id"
+
+	// OU holding the core accounts, created by the organization module
+	securityOUName = "Security"
+
+	// Role created in the accounts the landing zone vends
+	accountRoleName = "OrganizationAccountAccessRole"
+)
+
+var (
+	accountIdRE  = regexp.MustCompile(`^\d{12}$`)
+	identifierRE = regexp.MustCompile(`[^a-z0-9_]+`)
+)
+
+// ou is an OU of the model and the name of its resource
+type ou struct {
+	resource string
+	name     string
+}
+
+// account is an account of the model
+type account struct {
+	resource string
+	config   config.AccountConfig
+	parent   string
+}
+
+// policy is a policy of the model with its resolved document
+type policy struct {
+	resource    string
+	name        string
+	policyType  string
+	description string
+	content     string
+	targets     []string
+}
+
+// model is the organization the configuration deploys, with unique resource names
+type model struct {
+	tags     map[string]string
+	ous      []ou
+	ouByName map[string]string
+	accounts []account
+	policies []policy
+	names    map[string]bool
+}
+
+// Terraform renders the organization the configuration deploys, its OUs, the accounts
+// of its OUs, its SCPs and tag policies and their attachments, as HCL of the aws
+// provider, readable by Terraform and OpenTofu. With the identifiers of the live
+// organization, import blocks adopt the existing resources into the new state.
+func Terraform(w io.Writer, cfg *config.LandingZoneConfig, live *Live) error {
+	m, err := newModel(cfg)
+	if err != nil {
+		return err
+	}
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "# Organization model exported from the landing zone configuration.")
+	fmt.Fprintln(b, "# Review the plan before applying: resources without an import block are created.")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, `terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = ">= 5.0"
+    }
+  }
+}`)
+
+	// The organization adopted keeps every trusted service and policy type enabled in
+	// it, by the landing zone or not
+	attributes := [][2]string{{"feature_set", quote("ALL")}}
+	if live != nil && live.OrganizationID != "" {
+		attributes = append(attributes,
+			[2]string{"aws_service_access_principals", list(live.ServicePrincipals...)},
+			[2]string{"enabled_policy_types", list(live.PolicyTypes...)})
+	} else {
+		attributes = append(attributes, [2]string{"enabled_policy_types", list(config.PolicyTypeSCP, config.PolicyTypeTag)})
+	}
+	writeBlock(b, typeOrganization, "this", attributes, nil)
+	if live != nil && live.OrganizationID != "" {
+		writeImport(b, typeOrganization+".this", live.OrganizationID)
+	}
+
+	for _, o := range m.ous {
+		writeBlock(b, typeOU, o.resource, [][2]string{
+			{"name", quote(o.name)},
+			{"parent_id", rootExpression},
+		}, m.tags)
+		if id := live.ou(o.name); id != "" {
+			writeImport(b, typeOU+"."+o.resource, id)
+		}
+	}
+
+	for _, a := range m.accounts {
+		writeBlock(b, typeAccount, a.resource, [][2]string{
+			{"name", quote(a.config.Name)},
+			{"email", quote(a.config.Email)},
+			{"parent_id", typeOU + "." + a.parent + ".id"},
+			{"role_name", quote(accountRoleName)},
+			{"close_on_deletion", "false"},
+		}, a.config.Tags)
+		if id := live.account(a.config.Email); id != "" {
+			writeImport(b, typeAccount+"."+a.resource, id)
+		}
+	}
+
+	for _, p := range m.policies {
+		attributes := [][2]string{
+			{"name", quote(p.name)},
+			{"type", quote(p.policyType)},
+		}
+		if p.description != "" {
+			attributes = append(attributes, [2]string{"description", quote(p.description)})
+		}
+		attributes = append(attributes, [2]string{"content", quote(p.content)})
+		writeBlock(b, typePolicy, p.resource, attributes, m.tags)
+
+		policyId := live.policy(p.policyType, p.name)
+		if policyId != "" {
+			writeImport(b, typePolicy+"."+p.resource, policyId)
+		}
+
+		for _, target := range p.targets {
+			expression, name, err := m.target(cfg, target)
+			if err != nil {
+				return fmt.Errorf("policy %s: %w", p.name, err)
+			}
+			resource := m.resourceName(p.resource + "_" + name)
+			writeBlock(b, typeAttachment, resource, [][2]string{
+				{"policy_id", typePolicy + "." + p.resource + ".id"},
+				{"target_id", expression},
+			}, nil)
+			if targetId := live.target(cfg, target); policyId != "" && targetId != "" {
+				writeImport(b, typeAttachment+"."+resource, targetId+":"+policyId)
+			}
+		}
+	}
+
+	return b.Flush()
+}
+
+// newModel resolves the OUs, accounts and policies of the configuration
+func newModel(cfg *config.LandingZoneConfig) (*model, error) {
+	m := &model{
+		tags:     cfg.Tags,
+		ouByName: make(map[string]string),
+		names:    make(map[string]bool),
+	}
+
+	m.addOU(securityOUName)
+	if cfg.DefaultOUName != "" {
+		m.addOU(cfg.DefaultOUName)
+	}
+
+	keys := make([]string, 0, len(cfg.OrganizationUnits))
+	for key := range cfg.OrganizationUnits {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parent := m.addOU(invitations.OUName(cfg, key))
+		configured := cfg.OrganizationUnits[key]
+		if configured == nil {
+			continue
+		}
+		for _, a := range configured.Accounts {
+			m.accounts = append(m.accounts, account{resource: m.resourceName(a.Name), config: a, parent: parent})
+		}
+	}
+
+	if q := cfg.Quarantine; q != nil && q.Enabled {
+		name := quarantine.OUName(q)
+		m.addOU(name)
+
+		document, err := quarantine.PolicyDocument()
+		if err != nil {
+			return nil, err
+		}
+		m.policies = append(m.policies, policy{
+			resource:    m.resourceName(name + "-scp"),
+			name:        name + "-scp",
+			policyType:  config.PolicyTypeSCP,
+			description: quarantine.PolicyDescription,
+			content:     document,
+			targets:     []string{name},
+		})
+	}
+
+	for i := range cfg.Policies {
+		p := &cfg.Policies[i]
+		description := p.Description
+		if description == "" && p.Template != "" {
+			description = config.PolicyTemplates[p.Template].Description
+		}
+		m.policies = append(m.policies, policy{
+			resource:    m.resourceName(p.Name),
+			name:        p.Name,
+			policyType:  p.Type,
+			description: description,
+			content:     p.Document(),
+			targets:     p.Targets,
+		})
+	}
+
+	return m, nil
+}
+
+// addOU adds an OU below the root unless an OU of the same name exists, and returns
+// the name of its resource
+func (m *model) addOU(name string) string {
+	if resource, ok := m.ouByName[name]; ok {
+		return resource
+	}
+	resource := m.resourceName(name)
+	m.ous = append(m.ous, ou{resource: resource, name: name})
+	m.ouByName[name] = resource
+	return resource
+}
+
+// resourceName returns a unique Terraform identifier derived from a name
+func (m *model) resourceName(name string) string {
+	base := strings.Trim(identifierRE.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if base == "" || (base[0] >= '0' && base[0] <= '9') {
+		base = "r_" + base
+	}
+
+	resource := base
+	for i := 2; m.names[resource]; i++ {
+		resource = fmt.Sprintf("%s_%d", base, i)
+	}
+	m.names[resource] = true
+	return resource
+}
+
+// target returns the expression of a policy target and a name for its attachment
+func (m *model) target(cfg *config.LandingZoneConfig, target string) (string, string, error) {
+	if target == config.PolicyTargetRoot {
+		return rootExpression, "root", nil
+	}
+	if accountIdRE.MatchString(target) {
+		return quote(target), target, nil
+	}
+	if resource, ok := m.ouByName[invitations.OUName(cfg, target)]; ok {
+		return typeOU + "." + resource + ".id", resource, nil
+	}
+	return "", "", fmt.Errorf("target %s is not an OU of the configuration", target)
+}
+
+// writeBlock writes a resource block with aligned attributes and optional tags
+func writeBlock(w io.Writer, resourceType, name string, attributes [][2]string, tags map[string]string) {
+	width := 0
+	for _, attribute := range attributes {
+		if len(attribute[0]) > width {
+			width = len(attribute[0])
+		}
+	}
+
+	fmt.Fprintf(w, "\nresource %q %q {\n", resourceType, name)
+	for _, attribute := range attributes {
+		fmt.Fprintf(w, "  %-*s = %s\n", width, attribute[0], attribute[1])
+	}
+	if len(tags) > 0 {
+		keys := make([]string, 0, len(tags))
+		width = 0
+		for key := range tags {
+			keys = append(keys, key)
+			if len(quote(key)) > width {
+				width = len(quote(key))
+			}
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintln(w, "\n  tags = {")
+		for _, key := range keys {
+			fmt.Fprintf(w, "    %-*s = %s\n", width, quote(key), quote(tags[key]))
+		}
+		fmt.Fprintln(w, "  }")
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// writeImport writes the import block adopting an existing resource
+func writeImport(w io.Writer, address, id string) {
+	fmt.Fprintf(w, "\nimport {\n  to = %s\n  id = %s\n}\n", address, quote(id))
+}
+
+// quote returns an HCL string literal. Template sequences are escaped so policy
+// variables such as ${aws:username} are passed through literally.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '$', '%':
+			b.WriteByte(c)
+			if i+1 < len(s) && s[i+1] == '{' {
+				b.WriteByte(c)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// list returns an HCL list of string literals
+func list(values ...string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = quote(value)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,310 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package extensions provides the custom guardrails and baselines deployed with the
+// landing zone. Extensions register themselves, either compiled into a build of the
+// landing zone or from Go plugins opened at runtime, so company-specific controls do
+// not require changes to the modules.
+// Version: 1.0.0
+package extensions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+// Kinds of extensions, deployed with the controltower and baseline modules
+const (
+	KindGuardrail = "guardrail"
+	KindBaseline  = "baseline"
+)
+
+// SourceBuiltIn is the source of the extensions compiled into the landing zone
+const SourceBuiltIn = "built-in"
+
+// Extension is a custom guardrail or baseline. Deploy registers its resources with
+// the options of env, which make them children of the component of the extension.
+type Extension interface {
+	Name() string
+	Deploy(ctx *pulumi.Context, env *Environment) error
+}
+
+// Environment is what an extension is deployed with: the configuration of the landing
+// zone, the settings of the extension and the options of its resources
+type Environment struct {
+	Config   *config.LandingZoneConfig
+	Settings map[string]string
+	Options  []pulumi.ResourceOption
+}
+
+// Registered is an extension with its kind and the plugin that registered it
+type Registered struct {
+	Extension Extension `json:"-"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Source    string    `json:"source"`
+	Enabled   bool      `json:"enabled"`
+}
+
+// registry holds the registered extensions. The plugin being opened is the source of
+// the extensions its init functions register.
+var registry = struct {
+	sync.Mutex
+	extensions []*Registered
+	loaded     map[string]bool
+	source     string
+	errs       []error
+}{loaded: make(map[string]bool)}
+
+// RegisterGuardrail registers a guardrail, deployed with the controltower module
+func RegisterGuardrail(extension Extension) {
+	register(KindGuardrail, extension)
+}
+
+// RegisterBaseline registers a baseline, deployed with the baseline module
+func RegisterBaseline(extension Extension) {
+	register(KindBaseline, extension)
+}
+
+// register adds an extension to the registry. Registrations happen in init functions,
+// so a duplicate name is recorded and reported when the extensions are loaded.
+func register(kind string, extension Extension) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	source := registry.source
+	if source == "" {
+		source = SourceBuiltIn
+	}
+	for _, registered := range registry.extensions {
+		if registered.Name == extension.Name() {
+			registry.errs = append(registry.errs, fmt.Errorf("extension %s of %s is already registered by %s",
+				extension.Name(), source, registered.Source))
+			return
+		}
+	}
+	registry.extensions = append(registry.extensions, &Registered{
+		Extension: extension,
+		Name:      extension.Name(),
+		Kind:      kind,
+		Source:    source,
+	})
+}
+
+// Load opens the configured plugins and returns every registered extension, ordered
+// by kind and name
+func Load(cfg *config.LandingZoneConfig) ([]*Registered, error) {
+	paths, err := pluginPaths(cfg.Extensions)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if err := open(path); err != nil {
+			return nil, err
+		}
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	if len(registry.errs) > 0 {
+		return nil, registry.errs[0]
+	}
+
+	extensions := make([]*Registered, 0, len(registry.extensions))
+	for _, registered := range registry.extensions {
+		extension := *registered
+		extension.Enabled = cfg.Extensions.ExtensionEnabled(extension.Name)
+		extensions = append(extensions, &extension)
+	}
+	sort.Slice(extensions, func(i, j int) bool {
+		if extensions[i].Kind != extensions[j].Kind {
+			return extensions[i].Kind > extensions[j].Kind
+		}
+		return extensions[i].Name < extensions[j].Name
+	})
+	return extensions, nil
+}
+
+// pluginPaths returns the configured plugins followed by those of the directory
+func pluginPaths(cfg *config.ExtensionsConfig) ([]string, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	paths := append([]string(nil), cfg.Plugins...)
+	if cfg.Directory != "" {
+		if _, err := os.Stat(cfg.Directory); err != nil {
+			return nil, fmt.Errorf("failed to read extension directory: %w", err)
+		}
+		found, err := filepath.Glob(filepath.Join(cfg.Directory, "*"+config.PluginSuffix))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list extension plugins of %s: %w", cfg.Directory, err)
+		}
+		sort.Strings(found)
+		paths = append(paths, found...)
+	}
+	return paths, nil
+}
+
+// open opens a plugin once, recording it as the source of the extensions it registers
+func open(path string) error {
+	registry.Lock()
+	if registry.loaded[path] {
+		registry.Unlock()
+		return nil
+	}
+	registry.loaded[path] = true
+	registry.source = path
+	registry.Unlock()
+
+	// The init functions of the plugin register its extensions
+	_, err := plugin.Open(path)
+
+	registry.Lock()
+	registry.source = ""
+	registry.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to open extension plugin %s: %w", path, err)
+	}
+	return nil
+}
+
+// Runner deploys the enabled extensions of the landing zone
+type Runner struct {
+	logger     *zap.Logger
+	metrics    *metrics.Collector
+	cfg        *config.LandingZoneConfig
+	extensions []*Registered
+}
+
+// NewRunner loads the extensions of a landing zone
+func NewRunner(cfg *config.LandingZoneConfig) (*Runner, error) {
+	logger, err := logging.NewLogger("extensions")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("extensions")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	extensions, err := Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, extension := range extensions {
+		logger.Info("extension registered",
+			zap.String("extension", extension.Name),
+			zap.String("kind", extension.Kind),
+			zap.String("source", extension.Source),
+			zap.Bool("enabled", extension.Enabled))
+	}
+
+	return &Runner{logger: logger, metrics: metrics, cfg: cfg, extensions: extensions}, nil
+}
+
+// Guardrails deploys the enabled guardrails
+func (r *Runner) Guardrails(ctx *pulumi.Context) error {
+	return r.deploy(ctx, KindGuardrail)
+}
+
+// Baselines deploys the enabled baselines
+func (r *Runner) Baselines(ctx *pulumi.Context) error {
+	return r.deploy(ctx, KindBaseline)
+}
+
+// deploy deploys the enabled extensions of a kind, each in a component resource of its
+// own
+func (r *Runner) deploy(ctx *pulumi.Context, kind string) error {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordDuration(kind+"_extensions_duration", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "deploy extensions"); err != nil {
+		return err
+	}
+
+	for _, extension := range r.extensions {
+		if extension.Kind != kind || !extension.Enabled {
+			continue
+		}
+
+		parent := &extensionComponent{}
+		if err := ctx.RegisterComponentResource(component.Type("extensions", kindType(kind)), extension.Name, parent); err != nil {
+			return fmt.Errorf("failed to register %s %s: %w", kind, extension.Name, err)
+		}
+
+		env := &Environment{
+			Config:   r.cfg,
+			Settings: r.cfg.Extensions.ExtensionSettings(extension.Name),
+			Options:  []pulumi.ResourceOption{pulumi.Parent(parent)},
+		}
+		if err := extension.Extension.Deploy(ctx, env); err != nil {
+			return fmt.Errorf("failed to deploy %s %s of %s: %w", kind, extension.Name, extension.Source, err)
+		}
+		if err := ctx.RegisterResourceOutputs(parent, pulumi.Map{}); err != nil {
+			return fmt.Errorf("failed to register outputs of %s %s: %w", kind, extension.Name, err)
+		}
+
+		r.metrics.IncrementCounter(kind + "_extensions_deployed")
+		r.logger.Info("extension deployed",
+			zap.String("extension", extension.Name),
+			zap.String("kind", kind))
+	}
+	return nil
+}
+
+// extensionComponent is the component resource grouping the resources of an extension
+type extensionComponent struct {
+	pulumi.ResourceState
+}
+
+// kindType returns the component type name of a kind of extension
+func kindType(kind string) string {
+	if kind == KindGuardrail {
+		return "Guardrail"
+	}
+	return "Baseline"
+}
+
+// Write writes the registered extensions as a table or as JSON
+func Write(w io.Writer, format string, extensions []*Registered) error {
+	switch format {
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(extensions); err != nil {
+			return fmt.Errorf("failed to encode extensions: %w", err)
+		}
+		return nil
+	case report.FormatText:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tKIND\tSOURCE\tENABLED")
+		for _, extension := range extensions {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%t\n", extension.Name, extension.Kind, extension.Source, extension.Enabled)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
@@ -0,0 +1,536 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package grpcapi provides the gRPC organization service of the serve command, exposing
+// the accounts, organizational units and policies of the organization and streamed
+// previews and the run history to platform integrations.
+// Version: 1.0.0
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/accounts"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/engine"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/progress"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runs"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/selection"
+	landingzonev1 "github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/pkg/api/landingzone/v1"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// healthMethodPrefix prefixes the methods of the health service, served without the
+// bearer token so load balancers can probe the server
+const healthMethodPrefix = "/grpc.health.v1.Health/"
+
+// Statuses of the progress of a resource
+const (
+	StatusPending = "pending"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Server implements the organization service. Listings are served from an organization
+// cache living as long as the server. Previews run one at a time, since they share the
+// workspace of the project. The run history is only available with the DynamoDB state
+// backend.
+type Server struct {
+	landingzonev1.UnimplementedOrganizationServiceServer
+
+	logger  *zap.Logger
+	metrics *metrics.Collector
+	cache   *orgcache.Cache
+	reader  accounts.AccountReader
+	runs    *runs.Store
+	stack   string
+	workDir string
+	timeout time.Duration
+
+	// Bearer token required from callers when set
+	token string
+	// Stacks Plan requests may preview
+	stacks map[string]bool
+
+	previews sync.Mutex
+}
+
+// WithToken requires callers to present token as a bearer token
+func WithToken(token string) func(*Server) error {
+	return func(s *Server) error {
+		s.token = token
+		return nil
+	}
+}
+
+// WithStacks allows Plan requests to preview the stacks in addition to the default one
+func WithStacks(stacks ...string) func(*Server) error {
+	return func(s *Server) error {
+		for _, stack := range stacks {
+			if stack == "" {
+				return fmt.Errorf("an allowed stack name cannot be empty")
+			}
+			s.stacks[stack] = true
+		}
+		return nil
+	}
+}
+
+// NewServer creates the organization service of a landing zone. Previews run against
+// stack of the project in workDir unless a request names another allowed stack, and
+// are bounded by timeout.
+func NewServer(ctx context.Context, cfg *config.LandingZoneConfig, stack, workDir string, timeout time.Duration,
+	opts ...func(*Server) error) (*Server, error) {
+	logger, err := logging.NewLogger("grpcapi")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("grpcapi")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	cache, err := orgcache.New(ctx, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := accounts.NewAccountManager(ctx, accounts.WithOrganizationCache(cache))
+	if err != nil {
+		return nil, err
+	}
+
+	var store *runs.Store
+	if cfg.StateBackend == "" || cfg.StateBackend == config.StateBackendDynamoDB {
+		if store, err = runs.NewStore(ctx, runs.OptionsFor(cfg)...); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Server{
+		logger:  logger,
+		metrics: metrics,
+		cache:   cache,
+		reader:  reader,
+		runs:    store,
+		stack:   stack,
+		workDir: workDir,
+		timeout: timeout,
+		stacks:  map[string]bool{stack: true},
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Serve serves the organization service and the gRPC health service on listener until
+// ctx ends, then stops gracefully, letting the calls in progress finish
+func (s *Server) Serve(ctx context.Context, listener net.Listener, opts ...grpc.ServerOption) error {
+	opts = append(opts, grpc.ChainUnaryInterceptor(s.unaryInterceptor), grpc.ChainStreamInterceptor(s.streamInterceptor))
+	server := grpc.NewServer(opts...)
+	landingzonev1.RegisterOrganizationServiceServer(server, s)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(landingzonev1.OrganizationService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		healthServer.Shutdown()
+		server.GracefulStop()
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("failed to serve organization service: %w", err)
+	}
+	<-stopped
+	return nil
+}
+
+// ListAccounts returns the accounts of the organization sorted by ID
+func (s *Server) ListAccounts(ctx context.Context, _ *landingzonev1.ListAccountsRequest) (*landingzonev1.ListAccountsResponse, error) {
+	accounts, err := s.cache.Accounts(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to list accounts: %v", err)
+	}
+
+	response := &landingzonev1.ListAccountsResponse{Accounts: make([]*landingzonev1.Account, 0, len(accounts))}
+	for _, account := range accounts {
+		response.Accounts = append(response.Accounts, toAccount(account))
+	}
+	return response, nil
+}
+
+// GetAccount returns an account of the organization, with its status read from the
+// Organizations API rather than the cache
+func (s *Server) GetAccount(ctx context.Context, req *landingzonev1.GetAccountRequest) (*landingzonev1.Account, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "an account ID is required")
+	}
+
+	listed, err := s.cache.Accounts(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to list accounts: %v", err)
+	}
+	for _, account := range listed {
+		if aws.ToString(account.Id) == req.GetId() {
+			converted := toAccount(account)
+			if converted.Status, err = s.reader.GetAccountStatus(ctx, req.GetId()); err != nil {
+				return nil, status.Errorf(codes.Unavailable, "failed to read account status: %v", err)
+			}
+			return converted, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "account %s is not a member of the organization", req.GetId())
+}
+
+// ListOrganizationalUnits returns the organizational units of the organization
+func (s *Server) ListOrganizationalUnits(ctx context.Context, _ *landingzonev1.ListOrganizationalUnitsRequest) (*landingzonev1.ListOrganizationalUnitsResponse, error) {
+	rootID, err := s.cache.RootID(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to read organization root: %v", err)
+	}
+	ous, err := s.cache.OUs(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to list organizational units: %v", err)
+	}
+
+	response := &landingzonev1.ListOrganizationalUnitsResponse{
+		RootId:              rootID,
+		OrganizationalUnits: make([]*landingzonev1.OrganizationalUnit, 0, len(ous)),
+	}
+	for _, ou := range ous {
+		response.OrganizationalUnits = append(response.OrganizationalUnits, &landingzonev1.OrganizationalUnit{
+			Id:       ou.ID,
+			Name:     ou.Name,
+			ParentId: ou.ParentID,
+		})
+	}
+	return response, nil
+}
+
+// ListPolicies returns the policies of a type, service control policies by default
+func (s *Server) ListPolicies(ctx context.Context, req *landingzonev1.ListPoliciesRequest) (*landingzonev1.ListPoliciesResponse, error) {
+	policyType := orgtypes.PolicyTypeServiceControlPolicy
+	if req.GetType() != "" {
+		policyType = orgtypes.PolicyType(req.GetType())
+		if !validPolicyType(policyType) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown policy type %q", req.GetType())
+		}
+	}
+
+	policies, err := s.cache.Policies(ctx, policyType)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to list policies: %v", err)
+	}
+
+	response := &landingzonev1.ListPoliciesResponse{Policies: make([]*landingzonev1.Policy, 0, len(policies))}
+	for _, policy := range policies {
+		response.Policies = append(response.Policies, &landingzonev1.Policy{
+			Id:          aws.ToString(policy.Id),
+			Arn:         aws.ToString(policy.Arn),
+			Name:        aws.ToString(policy.Name),
+			Type:        string(policy.Type),
+			Description: aws.ToString(policy.Description),
+			AwsManaged:  policy.AwsManaged,
+		})
+	}
+	return response, nil
+}
+
+// Plan previews the selected modules, streaming the progress of every resource and
+// then the plan. A failed preview is reported in the plan and returned.
+func (s *Server) Plan(req *landingzonev1.PlanRequest, stream landingzonev1.OrganizationService_PlanServer) error {
+	sel, err := selection.New(req.GetOnly(), req.GetSkip())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	stack := req.GetStack()
+	if stack == "" {
+		stack = s.stack
+	}
+	if !s.stacks[stack] {
+		return status.Errorf(codes.PermissionDenied, "stack %s may not be previewed", stack)
+	}
+
+	s.previews.Lock()
+	defer s.previews.Unlock()
+
+	ctx, cancel := context.WithTimeout(stream.Context(), s.timeout)
+	defer cancel()
+
+	runner, err := engine.NewRunner(ctx, stack, s.workDir, sel, io.Discard)
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "failed to prepare preview of %s: %v", stack, err)
+	}
+
+	// Events may still arrive from the engine after a failed preview returns, so sends
+	// are serialized and stop with the preview
+	var sendMutex sync.Mutex
+	var sendErr error
+	finished := false
+	changes, err := runner.ObservePreview(ctx, func(event events.EngineEvent) {
+		resource := resourceProgress(event)
+		if resource == nil {
+			return
+		}
+		sendMutex.Lock()
+		defer sendMutex.Unlock()
+		if finished || sendErr != nil {
+			return
+		}
+		sendErr = stream.Send(&landingzonev1.PlanEvent{Event: &landingzonev1.PlanEvent_Progress{Progress: resource}})
+	})
+
+	sendMutex.Lock()
+	finished = true
+	defer sendMutex.Unlock()
+	if sendErr != nil {
+		return sendErr
+	}
+
+	doc := plan.New("preview")
+	if err != nil {
+		doc.Fail(err)
+	}
+	doc.AddChanges(changes...)
+	if sendErr := stream.Send(&landingzonev1.PlanEvent{Event: &landingzonev1.PlanEvent_Plan{Plan: toPlan(doc)}}); sendErr != nil {
+		return sendErr
+	}
+
+	s.metrics.IncrementCounter("plans_streamed")
+	if err != nil {
+		return status.Errorf(codes.Aborted, "preview of %s failed: %v", stack, err)
+	}
+	return nil
+}
+
+// ListRuns returns the most recent runs, newest first, without their changes
+func (s *Server) ListRuns(ctx context.Context, req *landingzonev1.ListRunsRequest) (*landingzonev1.ListRunsResponse, error) {
+	if s.runs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the run history requires the DynamoDB state backend")
+	}
+	if req.GetLimit() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "the limit cannot be negative")
+	}
+
+	list, err := s.runs.List(ctx, req.GetCommand(), int(req.GetLimit()))
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to list runs: %v", err)
+	}
+
+	response := &landingzonev1.ListRunsResponse{Runs: make([]*landingzonev1.Run, 0, len(list))}
+	for _, run := range list {
+		converted := toRun(run)
+		converted.Changes = nil
+		response.Runs = append(response.Runs, converted)
+	}
+	return response, nil
+}
+
+// GetRun returns a run of the history with the changes it planned
+func (s *Server) GetRun(ctx context.Context, req *landingzonev1.GetRunRequest) (*landingzonev1.Run, error) {
+	if s.runs == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the run history requires the DynamoDB state backend")
+	}
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "a run ID is required")
+	}
+
+	run, err := s.runs.Get(ctx, req.GetId())
+	if errors.Is(err, runs.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "run %s is not in the history", req.GetId())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to read run: %v", err)
+	}
+	return toRun(run), nil
+}
+
+// unaryInterceptor authorizes and logs every call with its duration and outcome
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	if err := s.authorize(ctx, info.FullMethod); err != nil {
+		s.record(info.FullMethod, start, err)
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	s.record(info.FullMethod, start, err)
+	return resp, err
+}
+
+// streamInterceptor authorizes and logs every streaming call with its duration and
+// outcome
+func (s *Server) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := s.authorize(stream.Context(), info.FullMethod)
+	if err == nil {
+		err = handler(srv, stream)
+	}
+	s.record(info.FullMethod, start, err)
+	return err
+}
+
+// authorize checks the bearer token of a call when the server requires one. The health
+// service is served to every caller.
+func (s *Server) authorize(ctx context.Context, method string) error {
+	if s.token == "" || strings.HasPrefix(method, healthMethodPrefix) {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1 {
+			return nil
+		}
+	}
+	s.metrics.IncrementCounter("calls_unauthenticated")
+	return status.Error(codes.Unauthenticated, "a valid bearer token is required")
+}
+
+// record logs a call and records its duration
+func (s *Server) record(method string, start time.Time, err error) {
+	s.metrics.RecordDuration("call_duration", time.Since(start))
+	fields := []zap.Field{
+		zap.String("method", method),
+		zap.Duration("duration", time.Since(start)),
+		zap.String("code", status.Code(err).String()),
+	}
+	if err != nil {
+		s.metrics.IncrementCounter("calls_failed")
+		s.logger.Warn("call failed", append(fields, zap.Error(err))...)
+		return
+	}
+	s.logger.Info("call served", fields...)
+}
+
+// resourceProgress returns the progress of a resource an engine event reports, if any
+func resourceProgress(event events.EngineEvent) *landingzonev1.ResourceProgress {
+	var resource *landingzonev1.ResourceProgress
+	switch {
+	case event.ResourcePreEvent != nil:
+		meta := event.ResourcePreEvent.Metadata
+		resource = &landingzonev1.ResourceProgress{Urn: meta.URN, Type: meta.Type, Operation: string(meta.Op), Status: StatusPending}
+	case event.ResOutputsEvent != nil:
+		meta := event.ResOutputsEvent.Metadata
+		resource = &landingzonev1.ResourceProgress{Urn: meta.URN, Type: meta.Type, Operation: string(meta.Op), Status: StatusDone}
+	case event.ResOpFailedEvent != nil:
+		meta := event.ResOpFailedEvent.Metadata
+		resource = &landingzonev1.ResourceProgress{Urn: meta.URN, Type: meta.Type, Operation: string(meta.Op), Status: StatusFailed}
+	default:
+		return nil
+	}
+	resource.Module = progress.ModuleOf(resource.Type)
+	return resource
+}
+
+// toAccount converts an account of the Organizations API
+func toAccount(account orgtypes.Account) *landingzonev1.Account {
+	converted := &landingzonev1.Account{
+		Id:           aws.ToString(account.Id),
+		Arn:          aws.ToString(account.Arn),
+		Name:         aws.ToString(account.Name),
+		Email:        aws.ToString(account.Email),
+		Status:       string(account.Status),
+		JoinedMethod: string(account.JoinedMethod),
+	}
+	if account.JoinedTimestamp != nil {
+		converted.JoinedTimestamp = account.JoinedTimestamp.Unix()
+	}
+	return converted
+}
+
+// toPlan converts a plan document
+func toPlan(doc *plan.Document) *landingzonev1.Plan {
+	converted := &landingzonev1.Plan{
+		SchemaVersion: doc.SchemaVersion,
+		RunId:         doc.RunID,
+		Status:        string(doc.Status),
+		Summary:       make(map[string]int32, len(doc.Summary)),
+		Changes:       make([]*landingzonev1.Change, 0, len(doc.Changes)),
+		Error:         doc.Error,
+	}
+	for operation, count := range doc.Summary {
+		converted.Summary[operation] = int32(count)
+	}
+	for _, change := range doc.Changes {
+		converted.Changes = append(converted.Changes, toChange(change))
+	}
+	return converted
+}
+
+// toRun converts a run of the history
+func toRun(run *runs.Run) *landingzonev1.Run {
+	converted := &landingzonev1.Run{
+		Id:               run.ID,
+		Command:          run.Command,
+		Parameters:       run.Parameters,
+		GitSha:           run.GitSHA,
+		Operator:         run.Operator,
+		Status:           run.Status,
+		StartedTimestamp: run.StartedAt.Unix(),
+		Summary:          make(map[string]int32, len(run.Summary)),
+		Changes:          make([]*landingzonev1.Change, 0, len(run.Changes)),
+		Truncated:        run.Truncated,
+		Error:            run.Error,
+		Logs:             run.Logs,
+	}
+	if run.FinishedAt != nil {
+		converted.FinishedTimestamp = run.FinishedAt.Unix()
+	}
+	for key, count := range run.Summary {
+		converted.Summary[key] = int32(count)
+	}
+	for _, change := range run.Changes {
+		converted.Changes = append(converted.Changes, toChange(change))
+	}
+	return converted
+}
+
+// toChange converts a planned change
+func toChange(change plan.Change) *landingzonev1.Change {
+	return &landingzonev1.Change{
+		Urn:       change.URN,
+		Type:      change.Type,
+		Operation: change.Operation,
+		Stack:     change.Stack,
+		Diffs:     change.Diffs,
+	}
+}
+
+// validPolicyType reports whether a policy type is known to the Organizations API
+func validPolicyType(policyType orgtypes.PolicyType) bool {
+	for _, known := range policyType.Values() {
+		if known == policyType {
+			return true
+		}
+	}
+	return false
+}
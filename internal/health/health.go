@@ -0,0 +1,299 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package health provides the AWS Health organizational view and the routing of health events.
+// Version: 1.0.0
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	sdkhealth "github.com/aws/aws-sdk-go-v2/service/health"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Status of the organizational view
+	statusEnabled = "ENABLED"
+
+	// Source of the events emitted by AWS Health
+	eventSource = "aws.health"
+
+	// Service forwarding events between regions
+	eventsService = "events"
+)
+
+// Health manages the organizational view and the event rules of the management account
+type Health struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	providers map[string]*aws.Provider
+}
+
+// SetupHealth enables the AWS Health organizational view and routes the health events
+// of every governed region to the notification topic. Events of regions other than
+// the region of the topic are forwarded to its default event bus first.
+func SetupHealth(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("health")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("health")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("health_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup health"); err != nil {
+		return err
+	}
+
+	if cfg.Health == nil {
+		logger.Info("no health configured")
+		return nil
+	}
+
+	h := &Health{
+		logger:    logger,
+		metrics:   metrics,
+		providers: make(map[string]*aws.Provider),
+	}
+
+	// The organizational view has no Pulumi resource, so it is enabled through the SDK
+	if cfg.Health.OrganizationalView && !ctx.DryRun() {
+		if err := h.enableOrganizationalView(ctx.Context()); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Health.NotificationTopicArn != "" {
+		if err := h.routeEvents(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("health setup completed successfully",
+		zap.Bool("organizationalView", cfg.Health.OrganizationalView),
+		zap.String("topic", cfg.Health.NotificationTopicArn))
+	return nil
+}
+
+// enableOrganizationalView enables the organizational view unless it is already enabled
+func (h *Health) enableOrganizationalView(ctx context.Context) error {
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return err
+	}
+	base.Region = globalRegion(awsclient.Partition())
+	client := sdkhealth.NewFromConfig(base)
+
+	status, err := client.DescribeHealthServiceStatusForOrganization(ctx, &sdkhealth.DescribeHealthServiceStatusForOrganizationInput{})
+	if err != nil {
+		return fmt.Errorf("failed to read health organizational view status: %w", err)
+	}
+	if status.HealthServiceAccessStatusForOrganization != nil && *status.HealthServiceAccessStatusForOrganization == statusEnabled {
+		return nil
+	}
+
+	if _, err := client.EnableHealthServiceAccessForOrganization(ctx, &sdkhealth.EnableHealthServiceAccessForOrganizationInput{}); err != nil {
+		return fmt.Errorf("failed to enable health organizational view: %w", err)
+	}
+
+	h.metrics.IncrementCounter("health_organizational_view_enabled")
+	h.logger.Info("health organizational view enabled")
+	return nil
+}
+
+// routeEvents creates a rule matching the health events of each region. The rule of
+// the topic region publishes to the topic, the others forward to its event bus.
+func (h *Health) routeEvents(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	topic, err := arn.Parse(cfg.Health.NotificationTopicArn)
+	if err != nil {
+		return fmt.Errorf("invalid health notification topic ARN: %w", err)
+	}
+
+	pattern, err := eventPattern(cfg.Health.EventTypeCategories)
+	if err != nil {
+		return err
+	}
+
+	home, err := h.provider(ctx, topic.Region)
+	if err != nil {
+		return err
+	}
+
+	var forwarder *iam.Role
+	for _, region := range Regions(cfg) {
+		provider, err := h.provider(ctx, region)
+		if err != nil {
+			return err
+		}
+
+		rule, err := cloudwatch.NewEventRule(ctx, fmt.Sprintf("health-events-%s", region), &cloudwatch.EventRuleArgs{
+			Description:  pulumi.String("Health events of every account of the organization"),
+			EventPattern: pulumi.String(pattern),
+			Tags:         pulumi.ToStringMap(cfg.Tags),
+		}, pulumi.Provider(provider))
+		if err != nil {
+			return fmt.Errorf("failed to create health event rule in %s: %w", region, err)
+		}
+
+		args := &cloudwatch.EventTargetArgs{
+			Rule: rule.Name,
+			Arn:  pulumi.String(cfg.Health.NotificationTopicArn),
+		}
+		if region != topic.Region {
+			if forwarder == nil {
+				if forwarder, err = h.forwarderRole(ctx, cfg, topic.Region, eventBusArn(topic.Region, topic.AccountID), home); err != nil {
+					return err
+				}
+			}
+			args.Arn = pulumi.String(eventBusArn(topic.Region, topic.AccountID))
+			args.RoleArn = forwarder.Arn
+		}
+
+		if _, err := cloudwatch.NewEventTarget(ctx, fmt.Sprintf("health-events-target-%s", region), args,
+			pulumi.Provider(provider)); err != nil {
+			return fmt.Errorf("failed to route health events in %s: %w", region, err)
+		}
+		h.metrics.IncrementCounter("health_event_rules_created")
+	}
+	return nil
+}
+
+// forwarderRole creates the role EventBridge assumes to forward health events to the
+// default event bus of the topic region
+func (h *Health) forwarderRole(ctx *pulumi.Context, cfg *config.LandingZoneConfig, region, busArn string, provider *aws.Provider) (*iam.Role, error) {
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect":   "Allow",
+				"Action":   "events:PutEvents",
+				"Resource": busArn,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal health event forwarding policy: %w", err)
+	}
+
+	role, err := iam.NewRole(ctx, "health-events-forwarder", &iam.RoleArgs{
+		Name:        component.Name(cfg.ResourceNames().Name(config.RoleHealthForwarder, region)),
+		Description: pulumi.String("Forwards health events to the region of the notification topic"),
+		AssumeRolePolicy: pulumi.String(fmt.Sprintf(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Allow",
+				"Principal": {
+					"Service": "%s"
+				},
+				"Action": "sts:AssumeRole"
+			}]
+		}`, awsclient.ServicePrincipal(eventsService))),
+		Tags: pulumi.ToStringMap(cfg.Tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create health event forwarding role: %w", err)
+	}
+
+	if _, err := iam.NewRolePolicy(ctx, "health-events-forwarder-put-events", &iam.RolePolicyArgs{
+		Role:   role.ID(),
+		Policy: pulumi.String(string(policy)),
+	}, pulumi.Provider(provider)); err != nil {
+		return nil, fmt.Errorf("failed to attach health event forwarding policy: %w", err)
+	}
+	return role, nil
+}
+
+// provider returns the provider of the management account for a region
+func (h *Health) provider(ctx *pulumi.Context, region string) (*aws.Provider, error) {
+	if provider, ok := h.providers[region]; ok {
+		return provider, nil
+	}
+
+	provider, err := aws.NewProvider(ctx, fmt.Sprintf("health-mgmt-%s", region), &aws.ProviderArgs{
+		Region: pulumi.String(region),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create management provider for %s: %w", region, err)
+	}
+
+	h.providers[region] = provider
+	return provider, nil
+}
+
+// Regions returns the regions health events are routed from: the governed regions, the
+// region global events are emitted in and the region of the notification topic
+func Regions(cfg *config.LandingZoneConfig) []string {
+	seen := map[string]bool{globalRegion(awsclient.Partition()): true}
+	for _, region := range cfg.GovernedRegions {
+		seen[region] = true
+	}
+	if topic, err := arn.Parse(cfg.Health.NotificationTopicArn); err == nil {
+		seen[topic.Region] = true
+	}
+
+	regions := make([]string, 0, len(seen))
+	for region := range seen {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// globalRegion returns the region serving the Health API and emitting the global
+// events of a partition
+func globalRegion(partition string) string {
+	switch partition {
+	case config.PartitionGovCloud:
+		return "us-gov-west-1"
+	case config.PartitionChina:
+		return "cn-north-1"
+	default:
+		return "us-east-1"
+	}
+}
+
+// eventPattern returns the pattern matching the health events of the given categories,
+// or every health event when none is given
+func eventPattern(categories []string) (string, error) {
+	pattern := map[string]interface{}{
+		"source": []string{eventSource},
+	}
+	if len(categories) > 0 {
+		pattern["detail"] = map[string]interface{}{"eventTypeCategory": categories}
+	}
+
+	data, err := json.Marshal(pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal health event pattern: %w", err)
+	}
+	return string(data), nil
+}
+
+// eventBusArn returns the ARN of the default event bus of an account in a region
+func eventBusArn(region, accountId string) string {
+	return fmt.Sprintf("arn:%s:events:%s:%s:event-bus/default", awsclient.Partition(), region, accountId)
+}
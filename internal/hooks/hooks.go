@@ -0,0 +1,258 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package hooks provides post-provision hooks run once for every new account.
+// Version: 1.0.0
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	sfntypes "github.com/aws/aws-sdk-go-v2/service/sfn/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// PendingExecution is recorded during previews for hooks that have not run yet
+	PendingExecution = "pending"
+)
+
+// Input holds the details of a new account available to parameter templates, for
+// example {{.AccountId}}
+type Input struct {
+	AccountId           string
+	AccountName         string
+	Email               string
+	OUName              string
+	Region              string
+	ManagementAccountId string
+}
+
+// Runner starts the post-provision hooks of new accounts
+type Runner struct {
+	logger              *zap.Logger
+	metrics             *metrics.Collector
+	base                aws.Config
+	roleName            string
+	defaultRegion       string
+	managementAccountId string
+	names               config.ParameterNames
+}
+
+// NewRunner creates a hook runner using the credentials of the management account
+func NewRunner(ctx context.Context, lz *config.LandingZoneConfig) (*Runner, error) {
+	logger, err := logging.NewLogger("hooks")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("hooks")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	region := lz.HomeRegion
+	if region == "" && len(lz.GovernedRegions) > 0 {
+		region = lz.GovernedRegions[0]
+	}
+
+	return &Runner{
+		logger:              logger,
+		metrics:             metrics,
+		base:                base,
+		roleName:            awsclient.MemberRoleName(lz),
+		defaultRegion:       region,
+		managementAccountId: lz.ManagementAccountId,
+		names:               lz.ParameterNames(),
+	}, nil
+}
+
+// Region returns the region hooks run in by default, which is also the region holding
+// the recorded executions
+func (r *Runner) Region() string {
+	return r.defaultRegion
+}
+
+// ExecutionPath returns the SSM parameter recording the execution of a hook for an account
+func (r *Runner) ExecutionPath(accountId, hookName string) string {
+	return r.names.Name(config.ParameterKindHooks, fmt.Sprintf("%s/%s", accountId, hookName))
+}
+
+// ForOU returns the hooks configured for an OU
+func ForOU(lz *config.LandingZoneConfig, ouName string) []config.HookConfig {
+	if lz == nil {
+		return nil
+	}
+	if ou, ok := lz.OrganizationUnits[ouName]; ok && ou != nil {
+		return ou.Hooks
+	}
+	return nil
+}
+
+// Start starts a hook for an account and returns the ID of its execution. Hooks already
+// recorded under ExecutionPath are not started again and return the recorded ID.
+// During previews nothing is started and PendingExecution is returned.
+func (r *Runner) Start(ctx context.Context, hook config.HookConfig, input Input, dryRun bool) (string, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordDuration("hook_start", time.Since(start))
+	}()
+
+	path := r.ExecutionPath(input.AccountId, hook.Name)
+	recorded, err := r.recorded(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if recorded != "" {
+		return recorded, nil
+	}
+
+	if dryRun {
+		return PendingExecution, nil
+	}
+
+	if err := readonly.Check("run post-provision hook"); err != nil {
+		return "", err
+	}
+
+	region := hook.Region
+	if region == "" {
+		region = r.defaultRegion
+	}
+	input.Region = region
+	if input.ManagementAccountId == "" {
+		input.ManagementAccountId = r.managementAccountId
+	}
+
+	params, err := Render(hook, input)
+	if err != nil {
+		return "", err
+	}
+
+	cfg := r.base.Copy()
+	cfg.Region = region
+	if hook.RunInAccount {
+		cfg = awsclient.AssumeRole(cfg, input.AccountId, r.roleName)
+	}
+
+	var id string
+	switch hook.Type {
+	case config.HookTypeSSMAutomation:
+		id, err = r.startAutomation(ctx, cfg, hook, params)
+	case config.HookTypeStepFunctions:
+		id, err = r.startExecution(ctx, cfg, hook, input, params)
+	default:
+		err = fmt.Errorf("unsupported hook type: %s", hook.Type)
+	}
+	if err != nil {
+		r.logger.Error("failed to start hook",
+			zap.String("hook", hook.Name),
+			zap.String("accountId", input.AccountId),
+			zap.Error(err))
+		return "", err
+	}
+
+	r.metrics.IncrementCounter("hooks_started")
+	r.logger.Info("post-provision hook started",
+		zap.String("hook", hook.Name),
+		zap.String("accountId", input.AccountId),
+		zap.String("execution", id))
+	return id, nil
+}
+
+// Render renders the parameter templates of a hook for an account
+func Render(hook config.HookConfig, input Input) (map[string]string, error) {
+	params := make(map[string]string, len(hook.Parameters))
+	for key, value := range hook.Parameters {
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse parameter %s of hook %s: %w", key, hook.Name, err)
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, input); err != nil {
+			return nil, fmt.Errorf("failed to render parameter %s of hook %s: %w", key, hook.Name, err)
+		}
+		params[key] = buf.String()
+	}
+	return params, nil
+}
+
+// recorded returns the execution recorded for a hook, or an empty string
+func (r *Runner) recorded(ctx context.Context, path string) (string, error) {
+	cfg := r.base.Copy()
+	cfg.Region = r.defaultRegion
+
+	out, err := ssm.NewFromConfig(cfg).GetParameter(ctx, &ssm.GetParameterInput{
+		Name: aws.String(path),
+	})
+	var notFound *ssmtypes.ParameterNotFound
+	if errors.As(err, &notFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read hook execution %s: %w", path, err)
+	}
+	return aws.ToString(out.Parameter.Value), nil
+}
+
+// startAutomation starts an SSM Automation document
+func (r *Runner) startAutomation(ctx context.Context, cfg aws.Config, hook config.HookConfig, params map[string]string) (string, error) {
+	parameters := make(map[string][]string, len(params))
+	for key, value := range params {
+		parameters[key] = []string{value}
+	}
+
+	out, err := ssm.NewFromConfig(cfg).StartAutomationExecution(ctx, &ssm.StartAutomationExecutionInput{
+		DocumentName: aws.String(hook.DocumentName),
+		Parameters:   parameters,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start automation %s: %w", hook.DocumentName, err)
+	}
+	return aws.ToString(out.AutomationExecutionId), nil
+}
+
+// startExecution starts a Step Functions state machine with the parameters as its JSON
+// input. The execution is named after the hook and account, so it is started at most once.
+func (r *Runner) startExecution(ctx context.Context, cfg aws.Config, hook config.HookConfig, input Input, params map[string]string) (string, error) {
+	document, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal input of hook %s: %w", hook.Name, err)
+	}
+
+	name := fmt.Sprintf("%s-%s", hook.Name, input.AccountId)
+	_, err = sfn.NewFromConfig(cfg).StartExecution(ctx, &sfn.StartExecutionInput{
+		StateMachineArn: aws.String(hook.StateMachineArn),
+		Name:            aws.String(name),
+		Input:           aws.String(string(document)),
+	})
+	var exists *sfntypes.ExecutionAlreadyExists
+	if err != nil && !errors.As(err, &exists) {
+		return "", fmt.Errorf("failed to start state machine %s: %w", hook.StateMachineArn, err)
+	}
+
+	return fmt.Sprintf("%s:%s", strings.Replace(hook.StateMachineArn, ":stateMachine:", ":execution:", 1), name), nil
+}
@@ -0,0 +1,309 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package impact provides the analysis of the API calls an SCP change would deny,
+// replaying the calls recorded by the organization trail, queried with Athena, against
+// the SCPs with the change made.
+// Version: 1.0.0
+package impact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/explain"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/loganalytics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	athenatypes "github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultDays of CloudTrail history analyzed
+	DefaultDays = 30
+
+	// MaxDays of CloudTrail history analyzed, bounding the partitions scanned
+	MaxDays = 90
+
+	// Largest number of distinct calls read from the history, most frequent first
+	maxCalls = 10000
+
+	// Interval between the polls of a running query
+	pollInterval = 2 * time.Second
+)
+
+var accountIdRE = regexp.MustCompile(`^\d{12}$`)
+
+// Event sources whose IAM service prefix differs from their host name
+var servicePrefixes = map[string]string{
+	"monitoring":       "cloudwatch",
+	"email":            "ses",
+	"tagging":          "tag",
+	"streams.dynamodb": "dynamodb",
+}
+
+// Report is the impact of an SCP change on the calls of the accounts below its target
+type Report struct {
+	Proposal explain.Proposal       `json:"proposal"`
+	Since    string                 `json:"since"`
+	Accounts []string               `json:"accounts"`
+	Observed int                    `json:"observed"`
+	Impacted []explain.ImpactedCall `json:"impacted"`
+}
+
+// Analyzer queries the CloudTrail table of the log analytics in the log archive account
+type Analyzer struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	athena    *athena.Client
+	explainer *explain.Explainer
+	cfg       *config.LandingZoneConfig
+}
+
+// NewAnalyzer creates an analyzer. It requires the log analytics, whose Athena
+// workgroup and CloudTrail table it queries with the member role of the log archive
+// account.
+func NewAnalyzer(ctx context.Context, cfg *config.LandingZoneConfig) (*Analyzer, error) {
+	if cfg.LogAnalytics == nil {
+		return nil, fmt.Errorf("impact analysis requires the log analytics, see LogAnalyticsConfig")
+	}
+
+	logger, err := logging.NewLogger("impact")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("impact")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logArchive := awsclient.AssumeRole(base, cfg.LogArchiveAccountId, awsclient.MemberRoleName(cfg))
+	logArchive.Region = cfg.LogBucketRegion()
+
+	explainer, err := explain.NewExplainer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Analyzer{
+		logger:    logger,
+		metrics:   metrics,
+		athena:    athena.NewFromConfig(logArchive),
+		explainer: explainer,
+		cfg:       cfg,
+	}, nil
+}
+
+// Analyze reads the calls made by the principals of the accounts over the last days
+// and returns those the proposal would deny. Calls that already failed with an access
+// error and calls of AWS services and service-linked roles, which SCPs do not
+// restrict, are left out.
+func (a *Analyzer) Analyze(ctx context.Context, proposal explain.Proposal, accountIDs []string, days int) (*Report, error) {
+	if days < 1 || days > MaxDays {
+		return nil, fmt.Errorf("days must be between 1 and %d", MaxDays)
+	}
+	for _, accountID := range accountIDs {
+		if !accountIdRE.MatchString(accountID) {
+			return nil, fmt.Errorf("invalid account ID %q", accountID)
+		}
+	}
+
+	start := time.Now()
+	defer func() {
+		a.metrics.RecordDuration("impact_analysis", time.Since(start))
+	}()
+
+	since := time.Now().UTC().AddDate(0, 0, -days).Format(config.LogAnalyticsDateFormat)
+	r := &Report{Proposal: proposal, Since: since, Accounts: accountIDs, Impacted: []explain.ImpactedCall{}}
+	if len(accountIDs) == 0 {
+		return r, nil
+	}
+
+	calls, err := a.calls(ctx, accountIDs, since)
+	if err != nil {
+		return nil, err
+	}
+	r.Observed = len(calls)
+
+	impacted, err := a.explainer.Impact(ctx, proposal, calls)
+	if err != nil {
+		return nil, err
+	}
+	if impacted != nil {
+		r.Impacted = impacted
+	}
+
+	a.metrics.RecordValue("impacted_calls", float64(len(r.Impacted)))
+	a.logger.Info("SCP change impact report generated",
+		zap.String("policy", proposal.Name),
+		zap.Int("accounts", len(accountIDs)),
+		zap.Int("observed", r.Observed),
+		zap.Int("impacted", len(r.Impacted)))
+	return r, nil
+}
+
+// calls queries the distinct calls made by the principals of the accounts since a day
+func (a *Analyzer) calls(ctx context.Context, accountIDs []string, since string) ([]explain.Call, error) {
+	quoted := make([]string, len(accountIDs))
+	for i, accountID := range accountIDs {
+		quoted[i] = "'" + accountID + "'"
+	}
+
+	query := fmt.Sprintf(`SELECT account, eventsource, eventname, awsregion,
+  coalesce(useridentity.sessioncontext.sessionissuer.arn, useridentity.arn) AS principal,
+  count(*) AS calls
+FROM "%s"
+WHERE day >= '%s' AND account IN (%s) AND useridentity.accountid = account
+  AND useridentity.type <> 'AWSService'
+  AND coalesce(useridentity.sessioncontext.sessionissuer.arn, useridentity.arn, '') NOT LIKE '%%:role/aws-service-role/%%'
+  AND coalesce(errorcode, '') NOT IN ('AccessDenied', 'AccessDeniedException', 'UnauthorizedOperation')
+GROUP BY 1, 2, 3, 4, 5
+ORDER BY calls DESC
+LIMIT %d`, loganalytics.TableCloudTrail, since, strings.Join(quoted, ", "), maxCalls)
+
+	rows, err := a.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	calls := make([]explain.Call, 0, len(rows))
+	for _, row := range rows {
+		if len(row) != 6 {
+			continue
+		}
+		count, err := strconv.ParseInt(row[5], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid call count %q: %w", row[5], err)
+		}
+		calls = append(calls, explain.Call{
+			AccountID: row[0],
+			Action:    Action(row[1], row[2]),
+			Region:    row[3],
+			Principal: row[4],
+			Count:     count,
+		})
+	}
+	if len(calls) == maxCalls {
+		a.logger.Warn("call history truncated to the most frequent calls", zap.Int("calls", maxCalls))
+	}
+	return calls, nil
+}
+
+// query runs a query in the workgroup of the log analytics and returns the rows of its
+// results, without the header row
+func (a *Analyzer) query(ctx context.Context, query string) ([][]string, error) {
+	started, err := a.athena.StartQueryExecution(ctx, &athena.StartQueryExecutionInput{
+		QueryString:           aws.String(query),
+		WorkGroup:             aws.String(a.cfg.LogAnalytics.WorkgroupName()),
+		QueryExecutionContext: &athenatypes.QueryExecutionContext{Database: aws.String(a.cfg.LogAnalytics.Database())},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start CloudTrail query: %w", err)
+	}
+	id := started.QueryExecutionId
+
+	for {
+		out, err := a.athena.GetQueryExecution(ctx, &athena.GetQueryExecutionInput{QueryExecutionId: id})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CloudTrail query %s: %w", aws.ToString(id), err)
+		}
+		status := out.QueryExecution.Status
+		if status.State == athenatypes.QueryExecutionStateSucceeded {
+			break
+		}
+		if status.State == athenatypes.QueryExecutionStateFailed || status.State == athenatypes.QueryExecutionStateCancelled {
+			return nil, fmt.Errorf("CloudTrail query %s %s: %s", aws.ToString(id),
+				strings.ToLower(string(status.State)), aws.ToString(status.StateChangeReason))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+
+	var rows [][]string
+	paginator := athena.NewGetQueryResultsPaginator(a.athena, &athena.GetQueryResultsInput{QueryExecutionId: id})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read results of CloudTrail query %s: %w", aws.ToString(id), err)
+		}
+		for _, row := range page.ResultSet.Rows {
+			values := make([]string, len(row.Data))
+			for i, datum := range row.Data {
+				values[i] = aws.ToString(datum.VarCharValue)
+			}
+			rows = append(rows, values)
+		}
+	}
+	if len(rows) > 0 {
+		rows = rows[1:]
+	}
+	return rows, nil
+}
+
+// Action returns the IAM action of a CloudTrail event, such as ec2:RunInstances for
+// the RunInstances event of ec2.amazonaws.com
+func Action(eventSource, eventName string) string {
+	service := strings.TrimSuffix(eventSource, ".amazonaws.com")
+	if prefix, ok := servicePrefixes[service]; ok {
+		service = prefix
+	}
+	return service + ":" + eventName
+}
+
+// Write renders a report as text or JSON
+func Write(w io.Writer, format string, r *Report) error {
+	switch format {
+	case report.FormatText:
+		return writeText(w, r)
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(r); err != nil {
+			return fmt.Errorf("failed to encode impact report: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// writeText writes a summary, then a table of the impacted calls
+func writeText(w io.Writer, r *Report) error {
+	fmt.Fprintf(w, "%s on %s: %d of %d calls observed in %d accounts since %s would be denied\n",
+		r.Proposal.Name, r.Proposal.Target, len(r.Impacted), r.Observed, len(r.Accounts), r.Since)
+	if len(r.Impacted) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tACTION\tREGION\tPRINCIPAL\tCALLS\tDECISION\tREASON")
+	for _, call := range r.Impacted {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", call.AccountID, call.Action, call.Region,
+			call.Principal, call.Count, call.Decision, call.Reason)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Resource type of the Control Tower landing zone in CloudFormation
+const landingZoneResourceType = "AWS::ControlTower::LandingZone"
+
+var accountIdRE = regexp.MustCompile(`^\d{12}$`)
+
+// controlTowerManifest is the landing zone manifest of the Control Tower API
+type controlTowerManifest struct {
+	GovernedRegions       []string `yaml:"governedRegions"`
+	OrganizationStructure struct {
+		Security *struct {
+			Name string `yaml:"name"`
+		} `yaml:"security"`
+		Sandbox *struct {
+			Name string `yaml:"name"`
+		} `yaml:"sandbox"`
+	} `yaml:"organizationStructure"`
+	CentralizedLogging struct {
+		AccountId      scalar `yaml:"accountId"`
+		Enabled        *bool  `yaml:"enabled"`
+		Configurations struct {
+			LoggingBucket struct {
+				RetentionDays scalar `yaml:"retentionDays"`
+			} `yaml:"loggingBucket"`
+			KmsKeyArn scalar `yaml:"kmsKeyArn"`
+		} `yaml:"configurations"`
+	} `yaml:"centralizedLogging"`
+	SecurityRoles struct {
+		AccountId scalar `yaml:"accountId"`
+	} `yaml:"securityRoles"`
+}
+
+// cloudFormationTemplate holds the parameters and resources of a template
+type cloudFormationTemplate struct {
+	Parameters map[string]struct {
+		Default scalar `yaml:"Default"`
+	} `yaml:"Parameters"`
+	Resources map[string]struct {
+		Type       string    `yaml:"Type"`
+		Properties yaml.Node `yaml:"Properties"`
+	} `yaml:"Resources"`
+}
+
+// convertControlTower converts a landing zone manifest, or the manifest of the
+// landing zone resource of a CloudFormation template. Refs are resolved to the
+// defaults of the template parameters.
+func (c *Converter) convertControlTower(path string) (*config.LandingZoneConfig, error) {
+	var template cloudFormationTemplate
+	if err := readYAML(path, &template); err != nil {
+		return nil, err
+	}
+
+	var manifest controlTowerManifest
+	if len(template.Resources) == 0 {
+		if err := readYAML(path, &manifest); err != nil {
+			return nil, err
+		}
+	} else {
+		found := false
+		for name, resource := range template.Resources {
+			if resource.Type != landingZoneResourceType {
+				continue
+			}
+			var properties struct {
+				Manifest yaml.Node `yaml:"Manifest"`
+			}
+			if err := resource.Properties.Decode(&properties); err != nil {
+				return nil, fmt.Errorf("failed to parse properties of %s: %w", name, err)
+			}
+			if err := properties.Manifest.Decode(&manifest); err != nil {
+				return nil, fmt.Errorf("failed to parse manifest of %s: %w", name, err)
+			}
+			found = true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("%s holds no %s resource", path, landingZoneResourceType)
+		}
+	}
+
+	resolve := func(field string, s scalar) string {
+		if !s.ref {
+			return s.value
+		}
+		if parameter, ok := template.Parameters[s.value]; ok && !parameter.Default.ref {
+			return parameter.Default.value
+		}
+		if s.value != "" {
+			c.logger.Warn("reference without a default value not converted",
+				zap.String("field", field), zap.String("ref", s.value))
+		}
+		return ""
+	}
+	accountId := func(field string, s scalar) string {
+		id := resolve(field, s)
+		if id != "" && !accountIdRE.MatchString(id) {
+			c.logger.Warn("invalid account ID not converted", zap.String("field", field), zap.String("value", id))
+			return ""
+		}
+		return id
+	}
+
+	logging := manifest.CentralizedLogging
+	lz := &config.LandingZoneConfig{
+		GovernedRegions:     manifest.GovernedRegions,
+		OrganizationUnits:   make(map[string]*config.OUConfig),
+		LogArchiveAccountId: accountId("centralizedLogging.accountId", logging.AccountId),
+		AuditAccountId:      accountId("securityRoles.accountId", manifest.SecurityRoles.AccountId),
+		KMSKeyArn:           resolve("centralizedLogging.configurations.kmsKeyArn", logging.Configurations.KmsKeyArn),
+		EnableCloudTrail:    logging.Enabled == nil || *logging.Enabled,
+	}
+
+	if days := resolve("retentionDays", logging.Configurations.LoggingBucket.RetentionDays); days != "" {
+		retention, err := strconv.Atoi(days)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log retention days %q", days)
+		}
+		lz.LogRetentionDays = retention
+	}
+
+	if sandbox := manifest.OrganizationStructure.Sandbox; sandbox != nil {
+		lz.DefaultOUName = sandbox.Name
+	}
+	if security := manifest.OrganizationStructure.Security; security != nil && security.Name != securityOU {
+		c.logger.Warn("security OU is always named "+securityOU, zap.String("name", security.Name))
+	}
+
+	return lz, nil
+}
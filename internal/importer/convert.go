@@ -0,0 +1,155 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package importer
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Validation section of the organization policies, the converted policies must pass
+const policySection = "policy"
+
+// Converter converts the configuration of an AWS-native landing zone: a Control Tower
+// landing zone manifest, a CloudFormation template deploying one, or the configuration
+// directory of the Landing Zone Accelerator
+type Converter struct {
+	logger  *zap.Logger
+	metrics *metrics.Collector
+}
+
+// NewConverter creates a converter. Conversion reads files only.
+func NewConverter() (*Converter, error) {
+	logger, err := logging.NewLogger("converter")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("converter")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	return &Converter{logger: logger, metrics: metrics}, nil
+}
+
+// Convert converts the landing zone at path: a directory is read as a Landing Zone
+// Accelerator configuration, a file as a Control Tower manifest or CloudFormation
+// template, in JSON or YAML. Settings without an equivalent are reported and dropped.
+func (c *Converter) Convert(path string) (*config.LandingZoneConfig, error) {
+	start := time.Now()
+	defer func() {
+		c.metrics.RecordDuration("landing_zone_conversion", time.Since(start))
+	}()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var lz *config.LandingZoneConfig
+	if info.IsDir() {
+		lz, err = c.convertLZA(path)
+	} else {
+		lz, err = c.convertControlTower(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := c.validate(lz); err != nil {
+		return nil, err
+	}
+
+	c.logger.Info("landing zone converted",
+		zap.String("source", path),
+		zap.Int("ous", len(lz.OrganizationUnits)),
+		zap.Int("policies", len(lz.Policies)))
+	return lz, nil
+}
+
+// validate runs the configuration validation on a converted landing zone. Invalid
+// converted policies fail the conversion. Settings the source has no equivalent for,
+// such as the account email domain, are left to complete, so the other failing
+// sections are reported only.
+func (c *Converter) validate(lz *config.LandingZoneConfig) error {
+	cfg, err := config.NewOrganizationConfig()
+	if err != nil {
+		return err
+	}
+	cfg.LandingZoneConfig = lz
+
+	for _, verr := range cfg.ValidateAll() {
+		if verr.Section == policySection {
+			return fmt.Errorf("converted configuration is invalid: %w", verr)
+		}
+		c.logger.Warn("converted configuration to complete before deploying",
+			zap.String("section", verr.Section),
+			zap.Error(verr.Err))
+		c.metrics.IncrementCounter("conversion_validation_errors")
+	}
+	return nil
+}
+
+// scalar is a string value of a template: a literal, or the name of the parameter a
+// Ref points to. Other intrinsic functions cannot be resolved and leave it empty.
+type scalar struct {
+	value string
+	ref   bool
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler for literals, !Ref tags and Ref objects
+func (s *scalar) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		s.value = node.Value
+		s.ref = node.Tag == "!Ref"
+	case yaml.MappingNode:
+		var ref struct {
+			Ref string `yaml:"Ref"`
+		}
+		if err := node.Decode(&ref); err == nil && ref.Ref != "" {
+			s.value, s.ref = ref.Ref, true
+		}
+	}
+	return nil
+}
+
+// readYAML decodes a JSON or YAML file
+func readYAML(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// policy returns a policy of the given content, referencing the template of the
+// built-in library it matches
+func (c *Converter) policy(name, policyType, description, content string, targets []string) config.PolicyConfig {
+	policy := config.PolicyConfig{
+		Name:        name,
+		Type:        policyType,
+		Description: description,
+		Targets:     targets,
+	}
+	if template := config.MatchPolicyTemplate(policyType, content); template != "" {
+		policy.Template = template
+		c.metrics.IncrementCounter("policies_matched_template")
+	} else {
+		policy.Content = content
+	}
+	c.metrics.IncrementCounter("policies_converted")
+	return policy
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package importer provides the generation of a configuration from an existing organization.
+// Version: 1.0.0
+package importer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// OU created by the landing zone itself and therefore not imported
+	securityOU = "Security"
+
+	// Prefix of the SCPs Control Tower manages for its controls, left to Control Tower
+	controlTowerPolicyPrefix = "aws-guardrails-"
+)
+
+// Importer reads the OUs, accounts and policies of an existing organization
+type Importer struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	orgClient *organizations.Client
+	cache     *orgcache.Cache
+
+	// Configuration keys of the imported OUs by ID
+	ouKeys map[string]string
+}
+
+// NewImporter creates an importer reading through the organization cache
+func NewImporter(ctx context.Context, cache *orgcache.Cache) (*Importer, error) {
+	logger, err := logging.NewLogger("importer")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("importer")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Importer{
+		logger:    logger,
+		metrics:   metrics,
+		orgClient: organizations.NewFromConfig(base),
+		cache:     cache,
+		ouKeys:    make(map[string]string),
+	}, nil
+}
+
+// Import generates a configuration holding the management account, the OUs with their
+// accounts and the customer managed SCPs and tag policies of the organization. Nested
+// OUs are flattened, since OUs are configured below the root only.
+func (i *Importer) Import(ctx context.Context) (*config.LandingZoneConfig, error) {
+	start := time.Now()
+	defer func() {
+		i.metrics.RecordDuration("organization_import", time.Since(start))
+	}()
+
+	organization, err := i.orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe organization: %w", err)
+	}
+
+	lz := &config.LandingZoneConfig{
+		ManagementAccountId: aws.ToString(organization.Organization.MasterAccountId),
+		OrganizationUnits:   make(map[string]*config.OUConfig),
+	}
+
+	if err := i.importOUs(ctx, lz); err != nil {
+		return nil, err
+	}
+
+	for _, policyType := range []string{config.PolicyTypeSCP, config.PolicyTypeTag} {
+		policies, err := i.importPolicies(ctx, policyType)
+		if err != nil {
+			return nil, err
+		}
+		lz.Policies = append(lz.Policies, policies...)
+	}
+
+	i.logger.Info("organization imported",
+		zap.Int("ous", len(lz.OrganizationUnits)),
+		zap.Int("policies", len(lz.Policies)))
+	return lz, nil
+}
+
+// importOUs adds every OU other than the Security OU with the accounts it holds
+func (i *Importer) importOUs(ctx context.Context, lz *config.LandingZoneConfig) error {
+	rootId, err := i.cache.RootID(ctx)
+	if err != nil {
+		return err
+	}
+
+	ous, err := i.cache.OUs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, ou := range ous {
+		if ou.Name == securityOU && ou.ParentID == rootId {
+			i.ouKeys[ou.ID] = securityOU
+			continue
+		}
+		if ou.ParentID != rootId {
+			i.logger.Warn("nested OU imported below the root", zap.String("ou", ou.Name), zap.String("id", ou.ID))
+		}
+
+		// OUs of the same name in different branches are keyed by their ID
+		key := ou.Name
+		if _, ok := lz.OrganizationUnits[key]; ok {
+			key = fmt.Sprintf("%s-%s", ou.Name, ou.ID)
+		}
+		i.ouKeys[ou.ID] = key
+
+		accounts, err := i.accounts(ctx, ou.ID)
+		if err != nil {
+			return err
+		}
+		lz.OrganizationUnits[key] = &config.OUConfig{Name: ou.Name, Accounts: accounts}
+	}
+	return nil
+}
+
+// accounts returns the active accounts of an OU sorted by name
+func (i *Importer) accounts(ctx context.Context, ouId string) ([]config.AccountConfig, error) {
+	var accounts []config.AccountConfig
+	paginator := organizations.NewListAccountsForParentPaginator(i.orgClient, &organizations.ListAccountsForParentInput{
+		ParentId: aws.String(ouId),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list accounts of OU %s: %w", ouId, err)
+		}
+		for _, account := range page.Accounts {
+			if account.Status != orgtypes.AccountStatusActive {
+				continue
+			}
+			accounts = append(accounts, config.AccountConfig{
+				Name:  aws.ToString(account.Name),
+				Email: aws.ToString(account.Email),
+			})
+		}
+	}
+
+	sort.Slice(accounts, func(a, b int) bool {
+		return accounts[a].Name < accounts[b].Name
+	})
+	return accounts, nil
+}
+
+// importPolicies returns the attached customer managed policies of a type with their
+// IDs, so they are imported into the stack rather than created again. Policies
+// matching a template of the built-in library reference the template instead of
+// carrying their content, and the SCPs of the Control Tower controls are skipped.
+func (i *Importer) importPolicies(ctx context.Context, policyType string) ([]config.PolicyConfig, error) {
+	summaries, err := i.cache.Policies(ctx, orgtypes.PolicyType(policyType))
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []config.PolicyConfig
+	for _, summary := range summaries {
+		if summary.AwsManaged {
+			continue
+		}
+		if strings.HasPrefix(aws.ToString(summary.Name), controlTowerPolicyPrefix) {
+			i.logger.Info("skipping Control Tower managed policy", zap.String("policy", aws.ToString(summary.Name)))
+			continue
+		}
+
+		targets, err := i.targets(ctx, aws.ToString(summary.Id))
+		if err != nil {
+			return nil, err
+		}
+		if len(targets) == 0 {
+			i.logger.Info("skipping unattached policy", zap.String("policy", aws.ToString(summary.Name)))
+			continue
+		}
+
+		described, err := i.orgClient.DescribePolicy(ctx, &organizations.DescribePolicyInput{
+			PolicyId: summary.Id,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe policy %s: %w", aws.ToString(summary.Name), err)
+		}
+
+		policy := config.PolicyConfig{
+			Name:        aws.ToString(summary.Name),
+			Type:        policyType,
+			Description: aws.ToString(summary.Description),
+			Targets:     targets,
+			ImportID:    aws.ToString(summary.Id),
+		}
+		content := aws.ToString(described.Policy.Content)
+		if template := config.MatchPolicyTemplate(policyType, content); template != "" {
+			policy.Template = template
+			i.metrics.IncrementCounter("policies_matched_template")
+		} else {
+			policy.Content = content
+		}
+
+		policies = append(policies, policy)
+		i.metrics.IncrementCounter("policies_imported")
+	}
+
+	sort.Slice(policies, func(a, b int) bool {
+		return policies[a].Name < policies[b].Name
+	})
+	return policies, nil
+}
+
+// targets returns the targets of a policy as the root, OU keys or account IDs
+func (i *Importer) targets(ctx context.Context, policyId string) ([]string, error) {
+	var targets []string
+	paginator := organizations.NewListTargetsForPolicyPaginator(i.orgClient, &organizations.ListTargetsForPolicyInput{
+		PolicyId: aws.String(policyId),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list targets of policy %s: %w", policyId, err)
+		}
+		for _, target := range page.Targets {
+			switch target.Type {
+			case orgtypes.TargetTypeRoot:
+				targets = append(targets, config.PolicyTargetRoot)
+			case orgtypes.TargetTypeOrganizationalUnit:
+				key, ok := i.ouKeys[aws.ToString(target.TargetId)]
+				if !ok {
+					key = aws.ToString(target.Name)
+				}
+				targets = append(targets, key)
+			default:
+				targets = append(targets, aws.ToString(target.TargetId))
+			}
+		}
+	}
+
+	sort.Strings(targets)
+	return targets, nil
+}
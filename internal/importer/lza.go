@@ -0,0 +1,266 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"go.uber.org/zap"
+)
+
+// Configuration files of the Landing Zone Accelerator
+const (
+	lzaGlobalConfig       = "global-config.yaml"
+	lzaOrganizationConfig = "organization-config.yaml"
+	lzaAccountsConfig     = "accounts-config.yaml"
+	lzaSecurityConfig     = "security-config.yaml"
+
+	// Root of the organization in deployment targets
+	lzaRoot = "Root"
+
+	// Mandatory accounts of the Landing Zone Accelerator
+	lzaManagementAccount = "Management"
+	lzaLogArchiveAccount = "LogArchive"
+	lzaAuditAccount      = "Audit"
+
+	// Type of the policies managed by AWS
+	lzaAWSManaged = "awsManaged"
+)
+
+// lzaGlobal holds the settings of global-config.yaml converted
+type lzaGlobal struct {
+	HomeRegion     string   `yaml:"homeRegion"`
+	EnabledRegions []string `yaml:"enabledRegions"`
+	ControlTower   struct {
+		LandingZone struct {
+			Logging struct {
+				LoggingBucketRetentionDays int  `yaml:"loggingBucketRetentionDays"`
+				OrganizationTrail          bool `yaml:"organizationTrail"`
+			} `yaml:"logging"`
+		} `yaml:"landingZone"`
+	} `yaml:"controlTower"`
+	Logging struct {
+		CloudTrail struct {
+			Enable bool `yaml:"enable"`
+		} `yaml:"cloudtrail"`
+	} `yaml:"logging"`
+}
+
+// lzaOrganization holds the OUs and policies of organization-config.yaml
+type lzaOrganization struct {
+	OrganizationalUnits []struct {
+		Name   string `yaml:"name"`
+		Ignore bool   `yaml:"ignore"`
+	} `yaml:"organizationalUnits"`
+	ServiceControlPolicies []lzaPolicy `yaml:"serviceControlPolicies"`
+	TaggingPolicies        []lzaPolicy `yaml:"taggingPolicies"`
+}
+
+// lzaPolicy is an SCP or tag policy and its deployment targets
+type lzaPolicy struct {
+	Name              string `yaml:"name"`
+	Description       string `yaml:"description"`
+	Policy            string `yaml:"policy"`
+	Type              string `yaml:"type"`
+	DeploymentTargets struct {
+		OrganizationalUnits []string `yaml:"organizationalUnits"`
+		Accounts            []string `yaml:"accounts"`
+	} `yaml:"deploymentTargets"`
+}
+
+// lzaAccount is a mandatory or workload account of accounts-config.yaml
+type lzaAccount struct {
+	Name               string `yaml:"name"`
+	Email              string `yaml:"email"`
+	OrganizationalUnit string `yaml:"organizationalUnit"`
+}
+
+// lzaAccounts holds the accounts of accounts-config.yaml and their IDs by email
+type lzaAccounts struct {
+	MandatoryAccounts []lzaAccount `yaml:"mandatoryAccounts"`
+	WorkloadAccounts  []lzaAccount `yaml:"workloadAccounts"`
+	AccountIds        []struct {
+		Email     string `yaml:"email"`
+		AccountId string `yaml:"accountId"`
+	} `yaml:"accountIds"`
+}
+
+// lzaSecurity holds the central security services of security-config.yaml
+type lzaSecurity struct {
+	CentralSecurityServices struct {
+		GuardDuty struct {
+			Enable bool `yaml:"enable"`
+		} `yaml:"guardduty"`
+		SecurityHub struct {
+			Enable bool `yaml:"enable"`
+		} `yaml:"securityHub"`
+		Macie struct {
+			Enable bool `yaml:"enable"`
+		} `yaml:"macie"`
+	} `yaml:"centralSecurityServices"`
+}
+
+// convertLZA converts the configuration directory of the Landing Zone Accelerator.
+// Nested OUs are flattened below the root and keyed by their path; the security
+// configuration is optional.
+func (c *Converter) convertLZA(dir string) (*config.LandingZoneConfig, error) {
+	var global lzaGlobal
+	var organization lzaOrganization
+	var accounts lzaAccounts
+	for file, v := range map[string]interface{}{
+		lzaGlobalConfig:       &global,
+		lzaOrganizationConfig: &organization,
+		lzaAccountsConfig:     &accounts,
+	} {
+		if err := readYAML(filepath.Join(dir, file), v); err != nil {
+			return nil, err
+		}
+	}
+
+	lz := &config.LandingZoneConfig{
+		HomeRegion:        global.HomeRegion,
+		GovernedRegions:   global.EnabledRegions,
+		LogRetentionDays:  global.ControlTower.LandingZone.Logging.LoggingBucketRetentionDays,
+		EnableCloudTrail:  global.ControlTower.LandingZone.Logging.OrganizationTrail || global.Logging.CloudTrail.Enable,
+		OrganizationUnits: make(map[string]*config.OUConfig),
+	}
+
+	var security lzaSecurity
+	switch err := readYAML(filepath.Join(dir, lzaSecurityConfig), &security); {
+	case err == nil:
+		lz.EnableGuardDuty = security.CentralSecurityServices.GuardDuty.Enable
+		lz.EnableSecurityHub = security.CentralSecurityServices.SecurityHub.Enable
+		lz.EnableMacie = security.CentralSecurityServices.Macie.Enable
+	case errors.Is(err, fs.ErrNotExist):
+	default:
+		return nil, err
+	}
+
+	for _, ou := range organization.OrganizationalUnits {
+		if ou.Ignore || ou.Name == securityOU {
+			continue
+		}
+		if strings.Contains(ou.Name, "/") {
+			c.logger.Warn("nested OU converted below the root", zap.String("ou", ou.Name))
+		}
+		lz.OrganizationUnits[lzaOUKey(ou.Name)] = &config.OUConfig{Name: lzaOUName(ou.Name)}
+	}
+
+	ids := make(map[string]string, len(accounts.AccountIds))
+	for _, account := range accounts.AccountIds {
+		ids[strings.ToLower(account.Email)] = account.AccountId
+	}
+	accountIds := make(map[string]string)
+	for _, account := range append(accounts.MandatoryAccounts, accounts.WorkloadAccounts...) {
+		if id, ok := ids[strings.ToLower(account.Email)]; ok {
+			accountIds[account.Name] = id
+		}
+	}
+	lz.ManagementAccountId = accountIds[lzaManagementAccount]
+	lz.LogArchiveAccountId = accountIds[lzaLogArchiveAccount]
+	lz.AuditAccountId = accountIds[lzaAuditAccount]
+
+	// Policies may only target the mandatory accounts and the accounts converted
+	converted := map[string]string{
+		lzaManagementAccount: lz.ManagementAccountId,
+		lzaLogArchiveAccount: lz.LogArchiveAccountId,
+		lzaAuditAccount:      lz.AuditAccountId,
+	}
+	for _, account := range accounts.WorkloadAccounts {
+		ou, ok := lz.OrganizationUnits[lzaOUKey(account.OrganizationalUnit)]
+		if !ok {
+			c.logger.Warn("account of an OU without an equivalent not converted",
+				zap.String("account", account.Name), zap.String("ou", account.OrganizationalUnit))
+			continue
+		}
+		ou.Accounts = append(ou.Accounts, config.AccountConfig{Name: account.Name, Email: account.Email})
+		converted[account.Name] = accountIds[account.Name]
+	}
+
+	for _, set := range []struct {
+		policyType string
+		policies   []lzaPolicy
+	}{
+		{config.PolicyTypeSCP, organization.ServiceControlPolicies},
+		{config.PolicyTypeTag, organization.TaggingPolicies},
+	} {
+		for _, p := range set.policies {
+			if p.Type == lzaAWSManaged {
+				continue
+			}
+
+			content, err := os.ReadFile(filepath.Join(dir, p.Policy))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read policy %s: %w", p.Name, err)
+			}
+
+			targets, err := c.lzaTargets(lz, p, converted)
+			if err != nil {
+				return nil, err
+			}
+			if len(targets) == 0 {
+				c.logger.Info("skipping policy without targets", zap.String("policy", p.Name))
+				continue
+			}
+			lz.Policies = append(lz.Policies, c.policy(p.Name, set.policyType, p.Description, string(content), targets))
+		}
+	}
+
+	sort.Slice(lz.Policies, func(a, b int) bool {
+		return lz.Policies[a].Name < lz.Policies[b].Name
+	})
+	return lz, nil
+}
+
+// lzaTargets returns the targets of a policy as the root, OU keys or account IDs.
+// Targeting an OU or account that was not converted fails, as the policy would be
+// attached to less than it was; converted accounts without an ID are dropped.
+func (c *Converter) lzaTargets(lz *config.LandingZoneConfig, p lzaPolicy, accounts map[string]string) ([]string, error) {
+	var targets []string
+	for _, ou := range p.DeploymentTargets.OrganizationalUnits {
+		switch key := lzaOUKey(ou); {
+		case ou == lzaRoot:
+			targets = append(targets, config.PolicyTargetRoot)
+		case ou == securityOU:
+			targets = append(targets, securityOU)
+		case lz.OrganizationUnits[key] != nil:
+			targets = append(targets, key)
+		default:
+			return nil, fmt.Errorf("policy %s targets OU %s, which is not converted", p.Name, ou)
+		}
+	}
+	for _, account := range p.DeploymentTargets.Accounts {
+		id, ok := accounts[account]
+		if !ok {
+			return nil, fmt.Errorf("policy %s targets account %s, which is not converted", p.Name, account)
+		}
+		if id == "" {
+			c.logger.Warn("policy target account without an ID not converted",
+				zap.String("policy", p.Name), zap.String("account", account))
+			continue
+		}
+		targets = append(targets, id)
+	}
+
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// lzaOUKey returns the configuration key of an OU path
+func lzaOUKey(path string) string {
+	return strings.ReplaceAll(path, "/", "-")
+}
+
+// lzaOUName returns the name of the OU at the end of a path
+func lzaOUName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
@@ -0,0 +1,478 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package invitations provides the invitation of existing accounts into the organization.
+// Version: 1.0.0
+package invitations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"go.uber.org/zap"
+)
+
+// Status describes where an invited account is in the invitation flow
+type Status string
+
+const (
+	// StatusNotInvited means no open invitation exists, or the last one was declined,
+	// canceled or expired
+	StatusNotInvited Status = "NOT_INVITED"
+
+	// StatusPending means the invitation waits for the account to accept it
+	StatusPending Status = "PENDING"
+
+	// StatusJoined means the account accepted and is not yet in its target OU
+	StatusJoined Status = "JOINED"
+
+	// StatusPlaced means the account is a member of its target OU
+	StatusPlaced Status = "PLACED"
+)
+
+// Action is the step applied to move an invitation forward
+type Action string
+
+const (
+	ActionNone   Action = "none"
+	ActionInvite Action = "invite"
+	ActionPlace  Action = "place"
+)
+
+// Invitation is the tracked state of an invited account
+type Invitation struct {
+	Target         string     `json:"target"`
+	AccountID      string     `json:"accountId,omitempty"`
+	TargetOU       string     `json:"targetOu"`
+	Status         Status     `json:"status"`
+	Action         Action     `json:"action"`
+	HandshakeID    string     `json:"handshakeId,omitempty"`
+	HandshakeState string     `json:"handshakeState,omitempty"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+
+	config   config.InvitationConfig
+	parentID string
+	ouID     string
+}
+
+// Manager tracks invitation handshakes and places accounts once they join
+type Manager struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	orgClient *organizations.Client
+	cache     *orgcache.Cache
+}
+
+// NewManager creates a new invitation manager instance
+func NewManager(ctx context.Context) (*Manager, error) {
+	logger, err := logging.NewLogger("invitations")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("invitations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	cfg, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manager{
+		logger:    logger,
+		metrics:   metrics,
+		orgClient: organizations.NewFromConfig(cfg),
+	}, nil
+}
+
+// UseCache lists the accounts and OUs of the organization through the organization cache
+func (m *Manager) UseCache(cache *orgcache.Cache) {
+	m.cache = cache
+}
+
+// Evaluate returns the status of every configured invitation and the action that
+// moves it forward
+func (m *Manager) Evaluate(ctx context.Context, cfg *config.LandingZoneConfig) ([]*Invitation, error) {
+	start := time.Now()
+	defer func() {
+		m.metrics.RecordDuration("evaluation_duration", time.Since(start))
+	}()
+
+	if len(cfg.Invitations) == 0 {
+		return nil, nil
+	}
+
+	handshakes, err := m.listHandshakes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	members, err := m.listMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Accounts that accepted since the accounts were cached are missing from the
+	// listing, so it is read again rather than inviting them a second time
+	if m.cache != nil && accepted(handshakes, members) {
+		m.cache.Invalidate(orgcache.KindAccounts)
+		if members, err = m.listMembers(ctx); err != nil {
+			return nil, err
+		}
+	}
+	ous, err := m.listOUs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var invitations []*Invitation
+	for _, invitationCfg := range cfg.Invitations {
+		target := invitationCfg.AccountId
+		if target == "" {
+			target = strings.ToLower(invitationCfg.Email)
+		}
+
+		ouName := OUName(cfg, invitationCfg.TargetOU)
+		ouID, ok := ous[ouName]
+		if !ok {
+			return nil, fmt.Errorf("target OU %s of invited account %s not found, deploy the organization module first", ouName, target)
+		}
+
+		invitation := &Invitation{
+			Target:   target,
+			TargetOU: ouName,
+			Status:   StatusNotInvited,
+			Action:   ActionInvite,
+			config:   invitationCfg,
+			ouID:     ouID,
+		}
+		if handshake, ok := handshakes[target]; ok {
+			invitation.HandshakeID = aws.ToString(handshake.Id)
+			invitation.HandshakeState = string(handshake.State)
+			invitation.ExpiresAt = handshake.ExpirationTimestamp
+			if pending(handshake.State) {
+				invitation.Status = StatusPending
+				invitation.Action = ActionNone
+			}
+		}
+
+		if accountID, ok := members[target]; ok {
+			parentID, err := m.parentOf(ctx, accountID)
+			if err != nil {
+				return nil, err
+			}
+			invitation.AccountID = accountID
+			invitation.parentID = parentID
+			invitation.Status = StatusJoined
+			invitation.Action = ActionPlace
+			if parentID == ouID {
+				invitation.Status = StatusPlaced
+				invitation.Action = ActionNone
+			}
+		}
+
+		invitations = append(invitations, invitation)
+	}
+
+	m.logger.Info("invitation evaluation completed", zap.Int("invitations", len(invitations)))
+	return invitations, nil
+}
+
+// Apply sends the missing invitations and moves accounts that joined into their target OU
+func (m *Manager) Apply(ctx context.Context, cfg *config.LandingZoneConfig, invitations []*Invitation) error {
+	if err := readonly.Check("invite accounts"); err != nil {
+		return err
+	}
+
+	for _, invitation := range invitations {
+		var err error
+		switch invitation.Action {
+		case ActionNone:
+			continue
+		case ActionInvite:
+			err = m.invite(ctx, cfg, invitation)
+		case ActionPlace:
+			err = m.place(ctx, invitation)
+		default:
+			err = fmt.Errorf("unknown invitation action %q", invitation.Action)
+		}
+		if err != nil {
+			return err
+		}
+
+		m.metrics.IncrementCounter(fmt.Sprintf("invitations_%s", invitation.Action))
+		m.logger.Info("invitation action applied",
+			zap.String("target", invitation.Target),
+			zap.String("action", string(invitation.Action)),
+			zap.String("targetOu", invitation.TargetOU))
+	}
+
+	return nil
+}
+
+// invite sends a handshake invitation to the account. The tags of the landing zone are
+// applied to the account once it accepts.
+func (m *Manager) invite(ctx context.Context, cfg *config.LandingZoneConfig, invitation *Invitation) error {
+	party := &orgtypes.HandshakeParty{
+		Id:   aws.String(invitation.config.AccountId),
+		Type: orgtypes.HandshakePartyTypeAccount,
+	}
+	if invitation.config.AccountId == "" {
+		party = &orgtypes.HandshakeParty{
+			Id:   aws.String(invitation.config.Email),
+			Type: orgtypes.HandshakePartyTypeEmail,
+		}
+	}
+
+	input := &organizations.InviteAccountToOrganizationInput{Target: party}
+	if invitation.config.Notes != "" {
+		input.Notes = aws.String(invitation.config.Notes)
+	}
+	for key, value := range cfg.Tags {
+		input.Tags = append(input.Tags, orgtypes.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	out, err := m.orgClient.InviteAccountToOrganization(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to invite account %s: %w", invitation.Target, err)
+	}
+
+	invitation.Status = StatusPending
+	invitation.HandshakeID = aws.ToString(out.Handshake.Id)
+	invitation.HandshakeState = string(out.Handshake.State)
+	invitation.ExpiresAt = out.Handshake.ExpirationTimestamp
+	return nil
+}
+
+// place moves a joined account from its current parent into the target OU
+func (m *Manager) place(ctx context.Context, invitation *Invitation) error {
+	if _, err := m.orgClient.MoveAccount(ctx, &organizations.MoveAccountInput{
+		AccountId:           aws.String(invitation.AccountID),
+		SourceParentId:      aws.String(invitation.parentID),
+		DestinationParentId: aws.String(invitation.ouID),
+	}); err != nil {
+		return fmt.Errorf("failed to move account %s to %s: %w", invitation.AccountID, invitation.TargetOU, err)
+	}
+
+	invitation.Status = StatusPlaced
+	invitation.parentID = invitation.ouID
+	return nil
+}
+
+// listHandshakes returns the latest invitation handshake of every invited party, keyed
+// by account ID or lower-cased email
+func (m *Manager) listHandshakes(ctx context.Context) (map[string]orgtypes.Handshake, error) {
+	handshakes := make(map[string]orgtypes.Handshake)
+
+	paginator := organizations.NewListHandshakesForOrganizationPaginator(m.orgClient,
+		&organizations.ListHandshakesForOrganizationInput{
+			Filter: &orgtypes.HandshakeFilter{ActionType: orgtypes.ActionTypeInviteAccountToOrganization},
+		})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list handshakes: %w", err)
+		}
+		for _, handshake := range page.Handshakes {
+			for _, party := range handshake.Parties {
+				if party.Type != orgtypes.HandshakePartyTypeAccount && party.Type != orgtypes.HandshakePartyTypeEmail {
+					continue
+				}
+				target := strings.ToLower(aws.ToString(party.Id))
+				if latest, ok := handshakes[target]; ok && newer(latest, handshake) {
+					continue
+				}
+				handshakes[target] = handshake
+			}
+		}
+	}
+
+	return handshakes, nil
+}
+
+// listMembers returns the IDs of the member accounts keyed by account ID and by
+// lower-cased email
+func (m *Manager) listMembers(ctx context.Context) (map[string]string, error) {
+	members := make(map[string]string)
+
+	if m.cache != nil {
+		accounts, err := m.cache.Accounts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, account := range accounts {
+			id := aws.ToString(account.Id)
+			members[id] = id
+			members[strings.ToLower(aws.ToString(account.Email))] = id
+		}
+		return members, nil
+	}
+
+	paginator := organizations.NewListAccountsPaginator(m.orgClient, &organizations.ListAccountsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list accounts: %w", err)
+		}
+		for _, account := range page.Accounts {
+			id := aws.ToString(account.Id)
+			members[id] = id
+			members[strings.ToLower(aws.ToString(account.Email))] = id
+		}
+	}
+
+	return members, nil
+}
+
+// listOUs returns the IDs of every OU of the organization keyed by their path below the
+// root, so OUs of the same name under different parents keep apart. Configured OUs are
+// below the root, so their path is their name.
+func (m *Manager) listOUs(ctx context.Context) (map[string]string, error) {
+	if m.cache != nil {
+		cached, err := m.cache.OUs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[string]orgcache.OU, len(cached))
+		for _, ou := range cached {
+			byID[ou.ID] = ou
+		}
+		ous := make(map[string]string, len(cached))
+		for _, ou := range cached {
+			ous[ouPath(byID, ou)] = ou.ID
+		}
+		return ous, nil
+	}
+
+	roots, err := m.orgClient.ListRoots(ctx, &organizations.ListRootsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roots: %w", err)
+	}
+	if len(roots.Roots) == 0 {
+		return nil, fmt.Errorf("organization has no root")
+	}
+
+	type parent struct {
+		id   string
+		path string
+	}
+	ous := make(map[string]string)
+	parents := []parent{{id: aws.ToString(roots.Roots[0].Id)}}
+	for len(parents) > 0 {
+		current := parents[0]
+		parents = parents[1:]
+
+		paginator := organizations.NewListOrganizationalUnitsForParentPaginator(m.orgClient,
+			&organizations.ListOrganizationalUnitsForParentInput{ParentId: aws.String(current.id)})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list organizational units: %w", err)
+			}
+			for _, ou := range page.OrganizationalUnits {
+				path := aws.ToString(ou.Name)
+				if current.path != "" {
+					path = current.path + "/" + path
+				}
+				ous[path] = aws.ToString(ou.Id)
+				parents = append(parents, parent{id: aws.ToString(ou.Id), path: path})
+			}
+		}
+	}
+
+	return ous, nil
+}
+
+// ouPath returns the path of a cached OU below the root
+func ouPath(byID map[string]orgcache.OU, ou orgcache.OU) string {
+	path := ou.Name
+	for parent, ok := byID[ou.ParentID]; ok; parent, ok = byID[parent.ParentID] {
+		path = parent.Name + "/" + path
+	}
+	return path
+}
+
+// parentOf returns the ID of the current parent of an account
+func (m *Manager) parentOf(ctx context.Context, accountID string) (string, error) {
+	out, err := m.orgClient.ListParents(ctx, &organizations.ListParentsInput{
+		ChildId: aws.String(accountID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get parent of account %s: %w", accountID, err)
+	}
+	if len(out.Parents) == 0 {
+		return "", fmt.Errorf("account %s has no parent", accountID)
+	}
+	return aws.ToString(out.Parents[0].Id), nil
+}
+
+// OUName returns the name of the configured OU an invited account is placed in
+func OUName(cfg *config.LandingZoneConfig, key string) string {
+	if ou, ok := cfg.OrganizationUnits[key]; ok && ou != nil && ou.Name != "" {
+		return ou.Name
+	}
+	return key
+}
+
+// Write writes the invitations as a table or a JSON document
+func Write(w io.Writer, format string, invitations []*Invitation) error {
+	switch format {
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(invitations); err != nil {
+			return fmt.Errorf("failed to encode invitations: %w", err)
+		}
+		return nil
+	case report.FormatText:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TARGET\tACCOUNT\tTARGET OU\tSTATUS\tHANDSHAKE\tACTION")
+		for _, invitation := range invitations {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				invitation.Target, report.OrDash(invitation.AccountID), invitation.TargetOU,
+				invitation.Status, report.OrDash(invitation.HandshakeState), invitation.Action)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// pending reports whether a handshake still waits for an answer
+func pending(state orgtypes.HandshakeState) bool {
+	return state == orgtypes.HandshakeStateOpen || state == orgtypes.HandshakeStateRequested
+}
+
+// accepted reports whether a handshake was accepted by an account missing from the members
+func accepted(handshakes map[string]orgtypes.Handshake, members map[string]string) bool {
+	for target, handshake := range handshakes {
+		if _, ok := members[target]; !ok && handshake.State == orgtypes.HandshakeStateAccepted {
+			return true
+		}
+	}
+	return false
+}
+
+// newer reports whether handshake a was requested after handshake b
+func newer(a, b orgtypes.Handshake) bool {
+	if a.RequestedTimestamp == nil || b.RequestedTimestamp == nil {
+		return a.RequestedTimestamp != nil
+	}
+	return a.RequestedTimestamp.After(*b.RequestedTimestamp)
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package keypolicy provides the builder of the policies of the customer managed KMS keys.
+// Version: 1.0.0
+package keypolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+)
+
+const (
+	policyVersion = "2012-10-17"
+
+	// actionAll grants every KMS action on the key
+	actionAll = "kms:*"
+
+	// Statement IDs of the administration statements
+	sidAccountAdministration = "EnableAccountAdministration"
+	sidRoleAdministration    = "AllowKeyAdministration"
+)
+
+// AdminActions are the actions of the key administrator roles. They manage the key
+// but cannot use it to encrypt or decrypt.
+var AdminActions = []string{
+	"kms:Create*",
+	"kms:Describe*",
+	"kms:Enable*",
+	"kms:List*",
+	"kms:Put*",
+	"kms:Update*",
+	"kms:Revoke*",
+	"kms:Disable*",
+	"kms:Get*",
+	"kms:Delete*",
+	"kms:TagResource",
+	"kms:UntagResource",
+	"kms:ScheduleKeyDeletion",
+	"kms:CancelKeyDeletion",
+}
+
+// Statement is a statement of a key policy
+type Statement struct {
+	Sid       string                            `json:"Sid"`
+	Effect    string                            `json:"Effect"`
+	Principal interface{}                       `json:"Principal"`
+	Action    interface{}                       `json:"Action"`
+	Resource  string                            `json:"Resource"`
+	Condition map[string]map[string]interface{} `json:"Condition,omitempty"`
+}
+
+// Document is a key policy
+type Document struct {
+	Version   string      `json:"Version"`
+	Statement []Statement `json:"Statement"`
+}
+
+// Builder builds the policy of a key. The root of the management account administers
+// every key; the grants of the key are added statement by statement.
+type Builder struct {
+	managementId string
+	accounts     []string
+	roles        []string
+	grants       []Statement
+}
+
+// New creates a builder for a key administered by the root of the management account
+func New(managementId string) *Builder {
+	return &Builder{managementId: managementId}
+}
+
+// ForConfig creates a builder for a key administered by the root of the management
+// account and the key administrator roles of the configuration
+func ForConfig(cfg *config.LandingZoneConfig) *Builder {
+	return New(cfg.ManagementAccountId).AdminRoles(cfg.KeyAdminRoles()...)
+}
+
+// Administrators lets the roots of other accounts administer the key, such as the
+// account owning it
+func (b *Builder) Administrators(accountIds ...string) *Builder {
+	b.accounts = append(b.accounts, accountIds...)
+	return b
+}
+
+// AdminRoles lets roles manage the key without using it
+func (b *Builder) AdminRoles(roleArns ...string) *Builder {
+	b.roles = append(b.roles, roleArns...)
+	return b
+}
+
+// GrantService lets a service principal use the key on behalf of an account
+func (b *Builder) GrantService(sid, service, sourceAccount string, actions ...string) *Builder {
+	statement := Statement{
+		Sid:       sid,
+		Effect:    "Allow",
+		Principal: map[string]interface{}{"Service": awsclient.ServicePrincipal(service)},
+		Action:    action(actions),
+		Resource:  "*",
+	}
+	if sourceAccount != "" {
+		statement.Condition = map[string]map[string]interface{}{
+			"StringEquals": {"aws:SourceAccount": sourceAccount},
+		}
+	}
+	b.grants = append(b.grants, statement)
+	return b
+}
+
+// GrantAccounts lets the principals of accounts use the key, through viaService only
+// when set
+func (b *Builder) GrantAccounts(sid string, accountIds []string, viaService string, actions ...string) *Builder {
+	principals := make([]string, 0, len(accountIds))
+	for _, id := range accountIds {
+		principals = append(principals, awsclient.AccountRootArn(id))
+	}
+
+	b.grants = append(b.grants, Statement{
+		Sid:       sid,
+		Effect:    "Allow",
+		Principal: map[string]interface{}{"AWS": principals},
+		Action:    action(actions),
+		Resource:  "*",
+		Condition: viaCondition(viaService),
+	})
+	return b
+}
+
+// GrantOrganization lets the principals of every account of the organization use the
+// key through the aws:PrincipalOrgID condition, through viaService only when set
+func (b *Builder) GrantOrganization(sid, orgId, viaService string, actions ...string) *Builder {
+	condition := viaCondition(viaService)
+	if condition == nil {
+		condition = make(map[string]map[string]interface{})
+	}
+	condition["StringEquals"] = map[string]interface{}{"aws:PrincipalOrgID": orgId}
+
+	b.grants = append(b.grants, Statement{
+		Sid:       sid,
+		Effect:    "Allow",
+		Principal: map[string]interface{}{"AWS": "*"},
+		Action:    action(actions),
+		Resource:  "*",
+		Condition: condition,
+	})
+	return b
+}
+
+// Document returns the key policy: the administration statements, then the grants
+func (b *Builder) Document() Document {
+	roots := []string{awsclient.AccountRootArn(b.managementId)}
+	seen := map[string]bool{b.managementId: true}
+	for _, id := range b.accounts {
+		if !seen[id] {
+			seen[id] = true
+			roots = append(roots, awsclient.AccountRootArn(id))
+		}
+	}
+	sort.Strings(roots[1:])
+
+	var principal interface{} = roots
+	if len(roots) == 1 {
+		principal = roots[0]
+	}
+
+	statements := []Statement{{
+		Sid:       sidAccountAdministration,
+		Effect:    "Allow",
+		Principal: map[string]interface{}{"AWS": principal},
+		Action:    actionAll,
+		Resource:  "*",
+	}}
+	if len(b.roles) > 0 {
+		statements = append(statements, Statement{
+			Sid:       sidRoleAdministration,
+			Effect:    "Allow",
+			Principal: map[string]interface{}{"AWS": b.roles},
+			Action:    AdminActions,
+			Resource:  "*",
+		})
+	}
+	statements = append(statements, b.grants...)
+
+	return Document{Version: policyVersion, Statement: statements}
+}
+
+// Build validates the key policy and returns it as JSON
+func (b *Builder) Build() (string, error) {
+	if b.managementId == "" {
+		return "", fmt.Errorf("key policy requires the management account ID")
+	}
+
+	document := b.Document()
+	if err := Validate(document, b.managementId); err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal key policy: %w", err)
+	}
+	return string(data), nil
+}
+
+// Validate checks that the root of the management account keeps administrative access
+// to the key: an unconditional statement allows it every action, and no statement
+// denies it any. A key it cannot administer can only be recovered through AWS support.
+func Validate(document Document, managementId string) error {
+	root := awsclient.AccountRootArn(managementId)
+
+	administered := false
+	for _, statement := range document.Statement {
+		if !hasPrincipal(statement.Principal, root) {
+			continue
+		}
+		switch statement.Effect {
+		case "Deny":
+			return fmt.Errorf("key policy statement %s denies the management account root", statement.Sid)
+		case "Allow":
+			if len(statement.Condition) == 0 && hasAction(statement.Action, actionAll) {
+				administered = true
+			}
+		}
+	}
+
+	if !administered {
+		return fmt.Errorf("key policy does not let the management account root %s administer the key", root)
+	}
+	return nil
+}
+
+// hasPrincipal reports whether a statement principal includes an ARN or everyone
+func hasPrincipal(principal interface{}, principalArn string) bool {
+	if principal == "*" {
+		return true
+	}
+	principals, ok := principal.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	switch arns := principals["AWS"].(type) {
+	case string:
+		return arns == principalArn || arns == "*"
+	case []string:
+		for _, p := range arns {
+			if p == principalArn || p == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasAction reports whether a statement action includes an action
+func hasAction(actions interface{}, want string) bool {
+	switch a := actions.(type) {
+	case string:
+		return a == want
+	case []string:
+		for _, action := range a {
+			if action == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// action returns a single action as a string and several as a list
+func action(actions []string) interface{} {
+	if len(actions) == 1 {
+		return actions[0]
+	}
+	return actions
+}
+
+// viaCondition restricts a grant to the requests made through a service
+func viaCondition(service string) map[string]map[string]interface{} {
+	if service == "" {
+		return nil
+	}
+	return map[string]map[string]interface{}{
+		"StringLike": {"kms:ViaService": service + ".*.amazonaws.com"},
+	}
+}
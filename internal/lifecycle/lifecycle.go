@@ -0,0 +1,297 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package lifecycle provides the tracking of account lifecycle states and the webhooks notified of their transitions.
+// Version: 1.0.0
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"go.uber.org/zap"
+)
+
+// RecordName is the name of the lifecycle record in the state
+const RecordName = "account-lifecycle"
+
+// Record holds the lifecycle state of every known account, keyed by lower-cased email,
+// and the deliveries of the transitions that failed on some of their channels
+type Record struct {
+	ObservedAt time.Time               `json:"observedAt"`
+	Accounts   map[string]AccountState `json:"accounts"`
+	Pending    []Delivery              `json:"pending,omitempty"`
+}
+
+// AccountState is the lifecycle state of an account and when it was first observed
+type AccountState struct {
+	State     string    `json:"state"`
+	Since     time.Time `json:"since"`
+	AccountID string    `json:"accountId,omitempty"`
+	Name      string    `json:"name"`
+}
+
+// Account holds the metadata of an account carried by its transitions
+type Account struct {
+	ID     string            `json:"id,omitempty"`
+	Name   string            `json:"name"`
+	Email  string            `json:"email"`
+	OU     string            `json:"ou,omitempty"`
+	Status string            `json:"status,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"`
+}
+
+// Transition is a change of the lifecycle state of an account between two observations
+type Transition struct {
+	Account Account   `json:"account"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Since   time.Time `json:"since"`
+
+	// key identifies the account in the record
+	key string
+}
+
+// Tracker observes the lifecycle state of the configured and member accounts
+type Tracker struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	orgClient *organizations.Client
+	cache     *orgcache.Cache
+	cfg       *config.LandingZoneConfig
+}
+
+// NewTracker creates a new lifecycle tracker instance
+func NewTracker(ctx context.Context, cfg *config.LandingZoneConfig) (*Tracker, error) {
+	logger, err := logging.NewLogger("lifecycle")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("lifecycle")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tracker{
+		logger:    logger,
+		metrics:   metrics,
+		orgClient: organizations.NewFromConfig(base),
+		cfg:       cfg,
+	}, nil
+}
+
+// UseCache lists the member accounts through the organization cache
+func (t *Tracker) UseCache(cache *orgcache.Cache) {
+	t.cache = cache
+}
+
+// Observe reads the current state of every account and returns its transitions since
+// the previous record, with the record to store. Configured accounts that are not in
+// the organization are requested, or creating while a creation request is in progress;
+// known accounts that left the organization are closed. Without a previous record no
+// transitions are returned.
+func (t *Tracker) Observe(ctx context.Context, previous *Record) ([]Transition, Record, error) {
+	start := time.Now()
+	defer func() {
+		t.metrics.RecordDuration("lifecycle_observation", time.Since(start))
+	}()
+
+	now := time.Now().UTC()
+	current := make(map[string]Account)
+
+	members, err := t.listMembers(ctx)
+	if err != nil {
+		return nil, Record{}, err
+	}
+	for _, member := range members {
+		account := Account{
+			ID:     aws.ToString(member.Id),
+			Name:   aws.ToString(member.Name),
+			Email:  aws.ToString(member.Email),
+			Status: string(member.Status),
+		}
+		current[strings.ToLower(account.Email)] = account
+	}
+
+	creating, err := t.creating(ctx)
+	if err != nil {
+		return nil, Record{}, err
+	}
+
+	states := make(map[string]string)
+	for key, account := range current {
+		states[key] = memberState(account.Status)
+	}
+	for _, name := range report.SortedKeys(t.cfg.OrganizationUnits) {
+		ou := t.cfg.OrganizationUnits[name]
+		if ou == nil {
+			continue
+		}
+		for _, configured := range ou.Accounts {
+			key := strings.ToLower(configured.Email)
+			account, ok := current[key]
+			if !ok {
+				account = Account{Name: configured.Name, Email: configured.Email}
+				states[key] = config.AccountStateRequested
+				if creating[configured.Name] {
+					states[key] = config.AccountStateCreating
+				}
+			}
+			account.OU = ou.Name
+			current[key] = account
+		}
+	}
+
+	if previous != nil {
+		for key, state := range previous.Accounts {
+			if _, ok := current[key]; ok {
+				continue
+			}
+			// Accounts removed from the configuration before they were created are
+			// forgotten rather than closed
+			if state.AccountID == "" {
+				continue
+			}
+			current[key] = Account{ID: state.AccountID, Name: state.Name, Email: key}
+			states[key] = config.AccountStateClosed
+		}
+	}
+
+	record := Record{ObservedAt: now, Accounts: make(map[string]AccountState, len(current))}
+	var transitions []Transition
+	for _, key := range report.SortedKeys(current) {
+		account := current[key]
+		state := AccountState{State: states[key], Since: now, AccountID: account.ID, Name: account.Name}
+
+		if previous != nil {
+			before, known := previous.Accounts[key]
+			switch {
+			case known && before.State == state.State:
+				state.Since = before.Since
+			case known || state.State != config.AccountStateClosed:
+				transitions = append(transitions, Transition{
+					Account: account,
+					From:    before.State,
+					To:      state.State,
+					Since:   before.Since,
+					key:     key,
+				})
+			}
+		}
+
+		// Accounts that left the organization are recorded as closed for a single
+		// observation, then forgotten
+		if account.Status == "" && state.State == config.AccountStateClosed && previous != nil &&
+			previous.Accounts[key].State == config.AccountStateClosed {
+			continue
+		}
+		record.Accounts[key] = state
+	}
+
+	for i := range transitions {
+		if transitions[i].Account.ID == "" || transitions[i].To == config.AccountStateClosed {
+			continue
+		}
+		tags, err := t.accountTags(ctx, transitions[i].Account.ID)
+		if err != nil {
+			return nil, Record{}, err
+		}
+		transitions[i].Account.Tags = tags
+	}
+
+	t.logger.Info("account lifecycle observed",
+		zap.Int("accounts", len(record.Accounts)),
+		zap.Int("transitions", len(transitions)))
+	t.metrics.IncrementCounter("lifecycle_observations")
+
+	return transitions, record, nil
+}
+
+// memberState returns the lifecycle state of a member account of the given status
+func memberState(status string) string {
+	switch orgtypes.AccountStatus(status) {
+	case orgtypes.AccountStatusSuspended:
+		return config.AccountStateSuspended
+	case orgtypes.AccountStatusPendingClosure:
+		return config.AccountStateClosed
+	default:
+		return config.AccountStateActive
+	}
+}
+
+// creating returns the names of the accounts whose creation is in progress
+func (t *Tracker) creating(ctx context.Context) (map[string]bool, error) {
+	names := make(map[string]bool)
+
+	paginator := organizations.NewListCreateAccountStatusPaginator(t.orgClient, &organizations.ListCreateAccountStatusInput{
+		States: []orgtypes.CreateAccountState{orgtypes.CreateAccountStateInProgress},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list account creation requests: %w", err)
+		}
+		for _, status := range page.CreateAccountStatuses {
+			names[aws.ToString(status.AccountName)] = true
+		}
+	}
+
+	return names, nil
+}
+
+// listMembers returns the accounts of the organization
+func (t *Tracker) listMembers(ctx context.Context) ([]orgtypes.Account, error) {
+	if t.cache != nil {
+		return t.cache.Accounts(ctx)
+	}
+
+	var members []orgtypes.Account
+
+	paginator := organizations.NewListAccountsPaginator(t.orgClient, &organizations.ListAccountsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list accounts: %w", err)
+		}
+		members = append(members, page.Accounts...)
+	}
+
+	return members, nil
+}
+
+// accountTags returns the tags of an account
+func (t *Tracker) accountTags(ctx context.Context, accountID string) (map[string]string, error) {
+	tags := make(map[string]string)
+
+	paginator := organizations.NewListTagsForResourcePaginator(t.orgClient,
+		&organizations.ListTagsForResourceInput{ResourceId: aws.String(accountID)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of account %s: %w", accountID, err)
+		}
+		for _, tag := range page.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,369 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/mail"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/secrets"
+	"go.uber.org/zap"
+)
+
+const (
+	// EventType is the type of the events delivered to the webhooks
+	EventType = "account.lifecycle"
+
+	// Headers of a delivery. The signature is the hex HMAC-SHA256 of the timestamp, a
+	// dot and the body, prefixed with "sha256=".
+	HeaderEvent     = "X-Landing-Zone-Event"
+	HeaderDelivery  = "X-Landing-Zone-Delivery"
+	HeaderTimestamp = "X-Landing-Zone-Timestamp"
+	HeaderSignature = "X-Landing-Zone-Signature"
+
+	// Delivery attempts of an event to a webhook
+	deliveryTimeout  = 10 * time.Second
+	deliveryAttempts = 3
+	deliveryDelay    = 2 * time.Second
+)
+
+// Event is the payload delivered to the webhooks for a transition. ID identifies the
+// transition, so receivers can discard a redelivery.
+type Event struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	RunID      string    `json:"runId"`
+	ObservedAt time.Time `json:"observedAt"`
+	From       string    `json:"from,omitempty"`
+	To         string    `json:"to"`
+	Account    Account   `json:"account"`
+}
+
+// Notifier delivers the transitions to the configured webhooks
+type Notifier struct {
+	logger   *zap.Logger
+	metrics  *metrics.Collector
+	client   *http.Client
+	webhooks []config.WebhookConfig
+	secrets  map[string][]byte
+
+	// Emails the transitions to the lifecycle recipients of the mail configuration
+	mailer      *mail.Mailer
+	lifecycleTo []string
+}
+
+// NewNotifier creates a new webhook notifier instance. The secrets of the webhooks are
+// read once, from their environment variables or the secret store.
+func NewNotifier(ctx context.Context, cfg *config.LandingZoneConfig) (*Notifier, error) {
+	logger, err := logging.NewLogger("webhooks")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("webhooks")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	webhookSecrets := make(map[string][]byte)
+	var reader *secrets.Reader
+	for _, webhook := range cfg.Webhooks {
+		switch {
+		case webhook.SecretEnv != "":
+			secret := os.Getenv(webhook.SecretEnv)
+			if secret == "" {
+				return nil, fmt.Errorf("secret of webhook %s is not set in %s", webhook.Name, webhook.SecretEnv)
+			}
+			webhookSecrets[webhook.Name] = []byte(secret)
+		case webhook.GenerateSecret:
+			if reader == nil {
+				if reader, err = secrets.NewReader(ctx, cfg); err != nil {
+					return nil, err
+				}
+			}
+			secret, err := reader.Get(ctx, webhook.SecretName())
+			if err != nil {
+				return nil, fmt.Errorf("failed to read secret of webhook %s: %w", webhook.Name, err)
+			}
+			webhookSecrets[webhook.Name] = []byte(secret)
+		}
+	}
+
+	notifier := &Notifier{
+		logger:   logger,
+		metrics:  metrics,
+		client:   &http.Client{Timeout: deliveryTimeout},
+		webhooks: cfg.Webhooks,
+		secrets:  webhookSecrets,
+	}
+	if cfg.Mail.NotifiesLifecycle() {
+		if notifier.mailer, err = mail.NewMailer(ctx, cfg); err != nil {
+			return nil, err
+		}
+		notifier.lifecycleTo = cfg.Mail.LifecycleTo
+	}
+	return notifier, nil
+}
+
+// Delivery is a transition with the channels it is still to be delivered to: email,
+// for the lifecycle recipients, and webhook:<name> for each subscribed webhook.
+// Deliveries that failed are kept in the record, so the next run only delivers them
+// to the channels that failed.
+type Delivery struct {
+	ID         string     `json:"id"`
+	ObservedAt time.Time  `json:"observedAt"`
+	Transition Transition `json:"transition"`
+	Channels   []string   `json:"channels"`
+}
+
+// channelEmail is the channel of the lifecycle recipients of the mail configuration
+const channelEmail = "email"
+
+// webhookChannel returns the channel of a webhook
+func webhookChannel(name string) string {
+	return "webhook:" + name
+}
+
+// Notify delivers every transition to the webhooks subscribed to its state and emails
+// them to the lifecycle recipients, then delivers the pending deliveries of previous
+// runs to the channels they failed on. Channels are delivered independently; it
+// returns the deliveries with the channels that failed.
+func (n *Notifier) Notify(ctx context.Context, observedAt time.Time, transitions []Transition, pending []Delivery) []Delivery {
+	deliveries := append([]Delivery{}, pending...)
+	for _, transition := range transitions {
+		deliveries = append(deliveries, Delivery{
+			ID:         eventID(transition),
+			ObservedAt: observedAt,
+			Transition: transition,
+			Channels:   n.channels(transition),
+		})
+	}
+
+	var emailed []Transition
+	for _, delivery := range deliveries {
+		if contains(delivery.Channels, channelEmail) {
+			emailed = append(emailed, delivery.Transition)
+		}
+	}
+	emailFailed := false
+	if n.mailer != nil && len(emailed) > 0 {
+		if err := n.email(ctx, observedAt, emailed); err != nil {
+			n.logger.Error("failed to email account lifecycle transitions", zap.Error(err))
+			n.metrics.IncrementCounter("email_failures")
+			emailFailed = true
+		}
+	}
+
+	var failed []Delivery
+	for _, delivery := range deliveries {
+		var remaining []string
+		for _, channel := range delivery.Channels {
+			if channel == channelEmail {
+				// Email to recipients no longer configured is dropped
+				if emailFailed && n.mailer != nil {
+					remaining = append(remaining, channel)
+				}
+				continue
+			}
+			if !n.deliverTo(ctx, channel, delivery) {
+				remaining = append(remaining, channel)
+			}
+		}
+		if len(remaining) > 0 {
+			delivery.Channels = remaining
+			failed = append(failed, delivery)
+		}
+	}
+	return failed
+}
+
+// channels returns the channels a transition is delivered to
+func (n *Notifier) channels(transition Transition) []string {
+	var channels []string
+	if n.mailer != nil {
+		channels = append(channels, channelEmail)
+	}
+	for _, webhook := range n.webhooks {
+		if subscribed(webhook, transition.To) {
+			channels = append(channels, webhookChannel(webhook.Name))
+		}
+	}
+	return channels
+}
+
+// deliverTo posts a delivery to the webhook of a channel and reports whether it was
+// delivered. Deliveries to webhooks no longer configured are dropped.
+func (n *Notifier) deliverTo(ctx context.Context, channel string, delivery Delivery) bool {
+	transition := delivery.Transition
+	for _, webhook := range n.webhooks {
+		if webhookChannel(webhook.Name) != channel {
+			continue
+		}
+		event := Event{
+			ID:         delivery.ID,
+			Type:       EventType,
+			RunID:      runid.ID(),
+			ObservedAt: delivery.ObservedAt,
+			From:       transition.From,
+			To:         transition.To,
+			Account:    transition.Account,
+		}
+		if err := n.deliver(ctx, webhook, event); err != nil {
+			n.logger.Error("failed to deliver account lifecycle event",
+				zap.String("webhook", webhook.Name),
+				zap.String("account", transition.Account.Name),
+				zap.String("to", transition.To),
+				zap.Error(err))
+			n.metrics.IncrementCounter("webhook_failures")
+			return false
+		}
+		n.metrics.IncrementCounter("webhook_deliveries")
+		return true
+	}
+	return true
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// email sends the transitions in a single notification
+func (n *Notifier) email(ctx context.Context, observedAt time.Time, transitions []Transition) error {
+	notification := mail.Notification{
+		Title:   "Account lifecycle changes",
+		Message: fmt.Sprintf("%d accounts changed lifecycle state, observed at %s.", len(transitions), observedAt.Format(time.RFC3339)),
+	}
+	var text strings.Builder
+	for _, transition := range transitions {
+		from := transition.From
+		if from == "" {
+			from = "-"
+		}
+		account := transition.Account
+		fields := []mail.Field{
+			{Label: "Account ID", Value: account.ID},
+			{Label: "Email", Value: account.Email},
+			{Label: "OU", Value: account.OU},
+			{Label: "State", Value: from + " → " + transition.To},
+		}
+		notification.Items = append(notification.Items, mail.NotificationItem{Name: account.Name, Fields: fields})
+		fmt.Fprintf(&text, "%s (%s): %s -> %s\n", account.Name, account.ID, from, transition.To)
+	}
+
+	return n.mailer.Send(ctx, mail.Message{
+		To:       n.lifecycleTo,
+		Subject:  notification.Title,
+		Template: mail.TemplateNotification,
+		Data:     notification,
+		Text:     text.String(),
+	})
+}
+
+// deliver posts an event to a webhook, retrying network failures and server errors
+func (n *Notifier) deliver(ctx context.Context, webhook config.WebhookConfig, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= deliveryAttempts; attempt++ {
+		retry, err := n.post(ctx, webhook, event.ID, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || attempt == deliveryAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(deliveryDelay * time.Duration(attempt)):
+		}
+	}
+	return lastErr
+}
+
+// post sends a single delivery and reports whether a failure is worth retrying
+func (n *Notifier) post(ctx context.Context, webhook config.WebhookConfig, id string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, EventType)
+	req.Header.Set(HeaderDelivery, id)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if secret, ok := n.secrets[webhook.Name]; ok {
+		req.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post to %s: %w", webhook.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook %s answered %s", webhook.Name, resp.Status)
+}
+
+// Sign returns the signature header of a delivery
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// subscribed reports whether a webhook is notified of transitions into a state
+func subscribed(webhook config.WebhookConfig, state string) bool {
+	if len(webhook.States) == 0 {
+		return true
+	}
+	for _, s := range webhook.States {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// eventID derives the ID of a transition from the account, both states and the time
+// the previous state was first observed, so a redelivery keeps its ID
+func eventID(transition Transition) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%d",
+		transition.key, transition.From, transition.To, transition.Since.UnixNano())))
+	return hex.EncodeToString(sum[:16])
+}
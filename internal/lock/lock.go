@@ -0,0 +1,398 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package lock provides the distributed lock preventing concurrent deployments of the organization.
+// Version: 1.0.0
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultLease is how long a lock is held without a heartbeat
+	DefaultLease = 5 * time.Minute
+
+	// Key of the lock item in the state table
+	lockPartition = "lock"
+	lockSortKey   = "deployment"
+
+	// Attributes of the lock item. The lease expiry is the TTL attribute of the table,
+	// so abandoned locks are also deleted by DynamoDB.
+	idAttribute        = "lockId"
+	ownerAttribute     = "owner"
+	operationAttribute = "operation"
+	acquiredAttribute  = "acquiredAt"
+)
+
+// Holder describes the holder of the lock
+type Holder struct {
+	ID         string    `json:"id"`
+	Owner      string    `json:"owner"`
+	Operation  string    `json:"operation"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// LockedError is returned when the lock is held by another run
+type LockedError struct {
+	Holder *Holder
+}
+
+// Error implements the error interface
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("organization is locked by %s (%s) since %s, lock ID %s; "+
+		"if that run is no longer active, release the lock with: force-unlock %s",
+		e.Holder.Owner, e.Holder.Operation, e.Holder.AcquiredAt.Format(time.RFC3339),
+		e.Holder.ID, e.Holder.ID)
+}
+
+// Lock is a lease-based lock stored in the state table. A held lock is renewed by a
+// heartbeat until it is released.
+type Lock struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	client    *dynamodb.Client
+	tableName string
+	lease     time.Duration
+	owner     string
+
+	mutex  sync.Mutex
+	id     string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewLock creates a lock in the state table with the provided options
+func NewLock(ctx context.Context, opts ...func(*Lock) error) (*Lock, error) {
+	logger, err := logging.NewLogger("lock")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("lock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	cfg, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lock{
+		logger:    logger,
+		metrics:   metrics,
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: config.StateTableName,
+		lease:     DefaultLease,
+		owner:     owner(),
+	}
+
+	for _, opt := range opts {
+		if err := opt(l); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// OptionsFor returns the lock options set by the landing zone configuration
+func OptionsFor(cfg *config.LandingZoneConfig) []func(*Lock) error {
+	var opts []func(*Lock) error
+	if cfg != nil && cfg.StateTableName != "" {
+		opts = append(opts, WithTable(cfg.StateTableName))
+	}
+	return opts
+}
+
+// WithTable sets the state table the lock is kept in
+func WithTable(name string) func(*Lock) error {
+	return func(l *Lock) error {
+		if name == "" {
+			return fmt.Errorf("a lock table name is required")
+		}
+		l.tableName = name
+		return nil
+	}
+}
+
+// WithLease sets how long the lock is held without a heartbeat
+func WithLease(lease time.Duration) func(*Lock) error {
+	return func(l *Lock) error {
+		if lease < 3*time.Second {
+			return fmt.Errorf("lock lease must be at least 3 seconds")
+		}
+		l.lease = lease
+		return nil
+	}
+}
+
+// Acquire takes the lock for an operation, failing with a LockedError when another
+// unexpired run holds it
+func (l *Lock) Acquire(ctx context.Context, operation string) error {
+	if err := readonly.Check("acquire deployment lock"); err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.id != "" {
+		return fmt.Errorf("lock is already held")
+	}
+
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = l.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(l.tableName),
+		Item: map[string]types.AttributeValue{
+			config.PkAttribute:        &types.AttributeValueMemberS{Value: lockPartition},
+			config.SkAttribute:        &types.AttributeValueMemberS{Value: lockSortKey},
+			idAttribute:               &types.AttributeValueMemberS{Value: id},
+			ownerAttribute:            &types.AttributeValueMemberS{Value: l.owner},
+			operationAttribute:        &types.AttributeValueMemberS{Value: operation},
+			acquiredAttribute:         &types.AttributeValueMemberS{Value: now.Format(time.RFC3339)},
+			config.ExpiresAtAttribute: l.expiry(now),
+		},
+		ConditionExpression:      aws.String("attribute_not_exists(#pk) OR #expires < :now"),
+		ExpressionAttributeNames: map[string]string{"#pk": config.PkAttribute, "#expires": config.ExpiresAtAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": epoch(now),
+		},
+	})
+	if err != nil {
+		var conflict *types.ConditionalCheckFailedException
+		if !errors.As(err, &conflict) {
+			return fmt.Errorf("failed to acquire lock: %w", err)
+		}
+
+		l.metrics.IncrementCounter("lock_conflicts")
+		holder, err := l.Holder(ctx)
+		if err != nil {
+			return err
+		}
+		if holder == nil {
+			return fmt.Errorf("failed to acquire lock, released concurrently; retry")
+		}
+		return &LockedError{Holder: holder}
+	}
+
+	heartbeatCtx, cancel := context.WithCancel(context.Background())
+	l.id, l.cancel, l.done = id, cancel, make(chan struct{})
+	go l.heartbeat(heartbeatCtx, id, l.done)
+
+	l.metrics.IncrementCounter("locks_acquired")
+	l.logger.Info("deployment lock acquired",
+		zap.String("lockId", id),
+		zap.String("owner", l.owner),
+		zap.String("operation", operation))
+	return nil
+}
+
+// Release stops the heartbeat and deletes the lock when it is still held by this run
+func (l *Lock) Release(ctx context.Context) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.id == "" {
+		return nil
+	}
+
+	l.cancel()
+	<-l.done
+	id := l.id
+	l.id = ""
+
+	_, err := l.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(l.tableName),
+		Key:                       key(),
+		ConditionExpression:       aws.String("#id = :id"),
+		ExpressionAttributeNames:  map[string]string{"#id": idAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":id": &types.AttributeValueMemberS{Value: id}},
+	})
+	if err != nil {
+		var conflict *types.ConditionalCheckFailedException
+		if errors.As(err, &conflict) {
+			l.logger.Warn("deployment lock was taken over before release", zap.String("lockId", id))
+			return nil
+		}
+		return fmt.Errorf("failed to release lock %s: %w", id, err)
+	}
+
+	l.logger.Info("deployment lock released", zap.String("lockId", id))
+	return nil
+}
+
+// Holder returns the current holder of the lock, or nil when it is free
+func (l *Lock) Holder(ctx context.Context) (*Holder, error) {
+	out, err := l.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(l.tableName),
+		Key:            key(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock: %w", err)
+	}
+	if out.Item == nil {
+		return nil, nil
+	}
+
+	holder := &Holder{
+		ID:        stringAttribute(out.Item, idAttribute),
+		Owner:     stringAttribute(out.Item, ownerAttribute),
+		Operation: stringAttribute(out.Item, operationAttribute),
+	}
+	holder.AcquiredAt, _ = time.Parse(time.RFC3339, stringAttribute(out.Item, acquiredAttribute))
+	if n, ok := out.Item[config.ExpiresAtAttribute].(*types.AttributeValueMemberN); ok {
+		if seconds, err := strconv.ParseInt(n.Value, 10, 64); err == nil {
+			holder.ExpiresAt = time.Unix(seconds, 0)
+		}
+	}
+
+	if !holder.ExpiresAt.IsZero() && holder.ExpiresAt.Before(time.Now()) {
+		return nil, nil
+	}
+	return holder, nil
+}
+
+// ForceUnlock deletes the lock with the given ID regardless of its lease, for runs
+// that died without releasing it
+func (l *Lock) ForceUnlock(ctx context.Context, id string) error {
+	if err := readonly.Check("force unlock"); err != nil {
+		return err
+	}
+
+	_, err := l.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(l.tableName),
+		Key:                       key(),
+		ConditionExpression:       aws.String("#id = :id"),
+		ExpressionAttributeNames:  map[string]string{"#id": idAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":id": &types.AttributeValueMemberS{Value: id}},
+	})
+	if err != nil {
+		var conflict *types.ConditionalCheckFailedException
+		if errors.As(err, &conflict) {
+			return fmt.Errorf("lock %s is not held", id)
+		}
+		return fmt.Errorf("failed to force unlock %s: %w", id, err)
+	}
+
+	l.metrics.IncrementCounter("locks_forced")
+	l.logger.Warn("deployment lock forcibly released", zap.String("lockId", id))
+	return nil
+}
+
+// heartbeat renews the lease of a held lock until it is released
+func (l *Lock) heartbeat(ctx context.Context, id string, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(l.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, err := l.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                aws.String(l.tableName),
+			Key:                      key(),
+			UpdateExpression:         aws.String("SET #expires = :expires"),
+			ConditionExpression:      aws.String("#id = :id"),
+			ExpressionAttributeNames: map[string]string{"#id": idAttribute, "#expires": config.ExpiresAtAttribute},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":id":      &types.AttributeValueMemberS{Value: id},
+				":expires": l.expiry(time.Now()),
+			},
+		})
+		if err == nil {
+			continue
+		}
+
+		var conflict *types.ConditionalCheckFailedException
+		if errors.As(err, &conflict) {
+			l.metrics.IncrementCounter("locks_lost")
+			l.logger.Error("deployment lock lost, another run may now deploy concurrently",
+				zap.String("lockId", id))
+			return
+		}
+		if ctx.Err() == nil {
+			l.logger.Warn("failed to renew deployment lock", zap.String("lockId", id), zap.Error(err))
+		}
+	}
+}
+
+// expiry returns the lease expiry of a lock renewed at the given time
+func (l *Lock) expiry(now time.Time) types.AttributeValue {
+	return epoch(now.Add(l.lease))
+}
+
+// key returns the key of the lock item
+func key() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		config.PkAttribute: &types.AttributeValueMemberS{Value: lockPartition},
+		config.SkAttribute: &types.AttributeValueMemberS{Value: lockSortKey},
+	}
+}
+
+// epoch returns a time as a number of seconds, the format of the TTL attribute
+func epoch(t time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}
+}
+
+// stringAttribute returns a string attribute of an item, empty when missing
+func stringAttribute(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+// owner identifies the user and host running the tool
+func owner() string {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = "unknown"
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s@%s:%d", user, host, os.Getpid())
+}
+
+// newID returns a random lock ID
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate lock ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
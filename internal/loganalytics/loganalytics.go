@@ -0,0 +1,233 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package loganalytics provides the Glue tables and saved Athena queries of the logs
+// delivered to the log archive account, so they can be queried as soon as the landing
+// zone is set up.
+// Version: 1.0.0
+package loganalytics
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/athena"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/glue"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/organizations"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// OutputDatabase is the stack output holding the Glue database of the log tables
+	OutputDatabase = "logAnalyticsDatabase"
+
+	// OutputWorkgroup is the stack output holding the Athena workgroup of the queries
+	OutputWorkgroup = "logAnalyticsWorkgroup"
+
+	// Prefix of the query results in the results bucket
+	athenaResultsPrefix = "athena-results"
+)
+
+// LogAnalytics holds the state of the log analytics setup
+type LogAnalytics struct {
+	logger   *zap.Logger
+	metrics  *metrics.Collector
+	cfg      *config.LogAnalyticsConfig
+	lz       *config.LandingZoneConfig
+	provider *aws.Provider
+	orgId    string
+	accounts []string
+}
+
+// SetupLogAnalytics creates in the log archive account the Glue database and tables of
+// the organization trail and the VPC flow logs, the Athena workgroup they are queried
+// in and the saved queries of the security team. The partitions of the tables are
+// projected for every account of the organization; accounts created by an update are
+// added by the next one.
+func SetupLogAnalytics(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("loganalytics")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("loganalytics")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("log_analytics_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup log analytics"); err != nil {
+		return err
+	}
+
+	if cfg.LogAnalytics == nil {
+		logger.Info("no log analytics configured")
+		return nil
+	}
+
+	org, err := organizations.LookupOrganization(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look up organization accounts: %w", err)
+	}
+	accounts := make([]string, 0, len(org.Accounts))
+	for _, account := range org.Accounts {
+		accounts = append(accounts, account.Id)
+	}
+	sort.Strings(accounts)
+
+	provider, err := aws.NewProvider(ctx, "log-analytics-log-archive", &aws.ProviderArgs{
+		Region: pulumi.String(cfg.LogBucketRegion()),
+		AssumeRole: &aws.ProviderAssumeRoleArgs{
+			RoleArn:     pulumi.String(awsclient.RoleArn(cfg.LogArchiveAccountId, awsclient.MemberRoleName(cfg))),
+			SessionName: pulumi.String(awsclient.SessionName),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create log archive provider for log analytics: %w", err)
+	}
+
+	a := &LogAnalytics{
+		logger:   logger,
+		metrics:  metrics,
+		cfg:      cfg.LogAnalytics,
+		lz:       cfg,
+		provider: provider,
+		orgId:    org.Id,
+		accounts: accounts,
+	}
+
+	database, err := glue.NewCatalogDatabase(ctx, "log-analytics-database", &glue.CatalogDatabaseArgs{
+		Name:        pulumi.String(a.cfg.Database()),
+		Description: pulumi.String("Logs delivered to the log archive account"),
+		Tags:        pulumi.ToStringMap(cfg.Tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return fmt.Errorf("failed to create log analytics database: %w", err)
+	}
+
+	workgroup, err := athena.NewWorkgroup(ctx, "log-analytics-workgroup", &athena.WorkgroupArgs{
+		Name:        pulumi.String(a.cfg.WorkgroupName()),
+		Description: pulumi.String("Queries of the logs of the landing zone"),
+		Configuration: &athena.WorkgroupConfigurationArgs{
+			EnforceWorkgroupConfiguration: pulumi.Bool(true),
+			ResultConfiguration: &athena.WorkgroupConfigurationResultConfigurationArgs{
+				OutputLocation: pulumi.String(fmt.Sprintf("s3://%s/%s/", cfg.LogAnalyticsResultsBucket(), athenaResultsPrefix)),
+				EncryptionConfiguration: &athena.WorkgroupConfigurationResultConfigurationEncryptionConfigurationArgs{
+					EncryptionOption: pulumi.String("SSE_S3"),
+				},
+			},
+		},
+		Tags: pulumi.ToStringMap(cfg.Tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return fmt.Errorf("failed to create log analytics workgroup %s: %w", a.cfg.WorkgroupName(), err)
+	}
+
+	tables := []*table{a.cloudTrailTable()}
+	if cfg.FlowLogBucketName != "" {
+		tables = append(tables, a.flowLogTable())
+	}
+	for _, t := range tables {
+		if err := a.createTable(ctx, t, database, workgroup); err != nil {
+			return err
+		}
+	}
+
+	ctx.Export(OutputDatabase, database.Name)
+	ctx.Export(OutputWorkgroup, workgroup.Name)
+
+	logger.Info("log analytics setup completed successfully",
+		zap.String("database", a.cfg.Database()),
+		zap.String("workgroup", a.cfg.WorkgroupName()),
+		zap.Int("tables", len(tables)),
+		zap.Int("accounts", len(accounts)))
+	return nil
+}
+
+// createTable creates a log table and its saved queries
+func (a *LogAnalytics) createTable(ctx *pulumi.Context, t *table, database *glue.CatalogDatabase, workgroup *athena.Workgroup) error {
+	columns := glue.CatalogTableStorageDescriptorColumnArray{}
+	for _, column := range t.columns {
+		columns = append(columns, &glue.CatalogTableStorageDescriptorColumnArgs{
+			Name: pulumi.String(column.name),
+			Type: pulumi.String(column.kind),
+		})
+	}
+
+	parameters := a.projection(t.location)
+	parameters["EXTERNAL"] = "TRUE"
+	for key, value := range t.parameters {
+		parameters[key] = value
+	}
+
+	glueTable, err := glue.NewCatalogTable(ctx, fmt.Sprintf("log-analytics-%s", t.name), &glue.CatalogTableArgs{
+		Name:         pulumi.String(t.name),
+		DatabaseName: database.Name,
+		Description:  pulumi.String(t.description),
+		TableType:    pulumi.String("EXTERNAL_TABLE"),
+		Parameters:   pulumi.ToStringMap(parameters),
+		PartitionKeys: glue.CatalogTablePartitionKeyArray{
+			&glue.CatalogTablePartitionKeyArgs{Name: pulumi.String(partitionAccount), Type: pulumi.String("string")},
+			&glue.CatalogTablePartitionKeyArgs{Name: pulumi.String(partitionRegion), Type: pulumi.String("string")},
+			&glue.CatalogTablePartitionKeyArgs{Name: pulumi.String(partitionDay), Type: pulumi.String("string")},
+		},
+		StorageDescriptor: &glue.CatalogTableStorageDescriptorArgs{
+			Location:     pulumi.String(t.root),
+			InputFormat:  pulumi.String(t.inputFormat),
+			OutputFormat: pulumi.String(hiveOutputFormat),
+			Columns:      columns,
+			SerDeInfo: &glue.CatalogTableStorageDescriptorSerDeInfoArgs{
+				SerializationLibrary: pulumi.String(t.serde),
+				Parameters:           pulumi.ToStringMap(t.serdeParameters),
+			},
+		},
+	}, pulumi.Provider(a.provider))
+	if err != nil {
+		return fmt.Errorf("failed to create log analytics table %s: %w", t.name, err)
+	}
+
+	for _, q := range t.queries {
+		if _, err := athena.NewNamedQuery(ctx, fmt.Sprintf("log-analytics-%s-%s", t.name, q.id), &athena.NamedQueryArgs{
+			Name:        pulumi.String(q.name),
+			Description: pulumi.String(q.description),
+			Database:    database.Name,
+			Workgroup:   workgroup.Name,
+			Query:       pulumi.String(fmt.Sprintf(q.sql, t.name)),
+		}, pulumi.Provider(a.provider), pulumi.DependsOn([]pulumi.Resource{glueTable})); err != nil {
+			return fmt.Errorf("failed to save log analytics query %s: %w", q.name, err)
+		}
+		a.metrics.IncrementCounter("log_analytics_queries_saved")
+	}
+	return nil
+}
+
+// projection returns the parameters projecting the partitions of a table by account,
+// region and date onto its location template
+func (a *LogAnalytics) projection(location string) map[string]string {
+	return map[string]string{
+		"projection.enabled":                            "true",
+		"projection." + partitionAccount + ".type":      "enum",
+		"projection." + partitionAccount + ".values":    joinValues(a.accounts),
+		"projection." + partitionRegion + ".type":       "enum",
+		"projection." + partitionRegion + ".values":     joinValues(a.lz.LogAnalyticsRegions()),
+		"projection." + partitionDay + ".type":          "date",
+		"projection." + partitionDay + ".range":         a.cfg.Since() + ",NOW",
+		"projection." + partitionDay + ".format":        "yyyy/MM/dd",
+		"projection." + partitionDay + ".interval":      "1",
+		"projection." + partitionDay + ".interval.unit": "DAYS",
+		"storage.location.template":                     location,
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package loganalytics
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// Partitions projected onto the log tables
+	partitionAccount = "account"
+	partitionRegion  = "region"
+	partitionDay     = "day"
+
+	// Names of the log tables
+	TableCloudTrail = "cloudtrail"
+	tableFlowLogs   = "vpc_flow_logs"
+
+	// Formats and serializers of the log tables
+	hiveOutputFormat      = "org.apache.hadoop.hive.ql.io.HiveIgnoreKeyTextOutputFormat"
+	textInputFormat       = "org.apache.hadoop.mapred.TextInputFormat"
+	cloudTrailInputFormat = "com.amazon.emr.cloudtrail.CloudTrailInputFormat"
+	cloudTrailSerde       = "org.apache.hive.hcatalog.data.JsonSerDe"
+	lazySimpleSerde       = "org.apache.hadoop.hive.serde2.lazy.LazySimpleSerDe"
+
+	// Recent partitions scanned by the saved queries
+	recentDays = "day >= date_format(current_date - interval '7' day, '%%Y/%%m/%%d')"
+)
+
+// column is a column of a log table
+type column struct {
+	name string
+	kind string
+}
+
+// query is a saved query of a log table, whose SQL takes the table name as its only
+// argument
+type query struct {
+	id          string
+	name        string
+	description string
+	sql         string
+}
+
+// table is a log table of the Glue database. Its partitions are found under root at
+// location, a template of the account, region and day partitions.
+type table struct {
+	name            string
+	description     string
+	root            string
+	location        string
+	inputFormat     string
+	serde           string
+	serdeParameters map[string]string
+	parameters      map[string]string
+	columns         []column
+	queries         []query
+}
+
+// cloudTrailTable returns the table of the organization trail, delivered under
+// <prefix>/AWSLogs/<organization>/<account>/CloudTrail/<region>/<day>
+func (a *LogAnalytics) cloudTrailTable() *table {
+	prefix := a.cfg.CloudTrailPrefix
+	if prefix == "" {
+		prefix = a.orgId
+	}
+	root := location(a.lz.LogBucketName, prefix, "AWSLogs", a.orgId)
+
+	return &table{
+		name:        TableCloudTrail,
+		description: "Organization trail delivered to the log archive bucket",
+		root:        root,
+		location:    root + "${account}/CloudTrail/${region}/${day}",
+		inputFormat: cloudTrailInputFormat,
+		serde:       cloudTrailSerde,
+		parameters:  map[string]string{"classification": "cloudtrail"},
+		columns: []column{
+			{"eventversion", "string"},
+			{"useridentity", "struct<type:string,principalid:string,arn:string,accountid:string,invokedby:string,accesskeyid:string,username:string," +
+				"sessioncontext:struct<attributes:struct<mfaauthenticated:string,creationdate:string>," +
+				"sessionissuer:struct<type:string,principalid:string,arn:string,accountid:string,username:string>,sourceidentity:string>>"},
+			{"eventtime", "string"},
+			{"eventsource", "string"},
+			{"eventname", "string"},
+			{"awsregion", "string"},
+			{"sourceipaddress", "string"},
+			{"useragent", "string"},
+			{"errorcode", "string"},
+			{"errormessage", "string"},
+			{"requestparameters", "string"},
+			{"responseelements", "string"},
+			{"additionaleventdata", "string"},
+			{"requestid", "string"},
+			{"eventid", "string"},
+			{"readonly", "string"},
+			{"resources", "array<struct<arn:string,accountid:string,type:string>>"},
+			{"eventtype", "string"},
+			{"apiversion", "string"},
+			{"recipientaccountid", "string"},
+			{"serviceeventdetails", "string"},
+			{"sharedeventid", "string"},
+			{"vpcendpointid", "string"},
+			{"tlsdetails", "struct<tlsversion:string,ciphersuite:string,clienthostheader:string>"},
+		},
+		queries: []query{
+			{
+				id:          "root-activity",
+				name:        "CloudTrail: root user activity",
+				description: "API calls and console sign-ins of the root users of the last 7 days",
+				sql: "SELECT eventtime, account, region, eventsource, eventname, sourceipaddress, useragent\n" +
+					"FROM %s\nWHERE useridentity.type = 'Root' AND " + recentDays + "\nORDER BY eventtime DESC",
+			},
+			{
+				id:          "access-denied",
+				name:        "CloudTrail: denied API calls",
+				description: "Principals whose API calls were denied in the last 7 days",
+				sql: "SELECT account, useridentity.arn AS principal, eventsource, eventname, errorcode, count(*) AS calls\n" +
+					"FROM %s\nWHERE errorcode IN ('AccessDenied', 'AccessDeniedException', 'UnauthorizedOperation') AND " + recentDays + "\n" +
+					"GROUP BY 1, 2, 3, 4, 5\nORDER BY calls DESC\nLIMIT 100",
+			},
+			{
+				id:          "console-sign-in-failures",
+				name:        "CloudTrail: failed console sign-ins",
+				description: "Failed console sign-ins of the last 7 days",
+				sql: "SELECT eventtime, account, useridentity.arn AS principal, sourceipaddress, errormessage\n" +
+					"FROM %s\nWHERE eventname = 'ConsoleLogin' AND json_extract_scalar(responseelements, '$.ConsoleLogin') = 'Failure' AND " + recentDays + "\n" +
+					"ORDER BY eventtime DESC",
+			},
+			{
+				id:          "iam-changes",
+				name:        "CloudTrail: IAM changes",
+				description: "Changes to IAM users, roles and policies of the last 7 days",
+				sql: "SELECT eventtime, account, useridentity.arn AS principal, eventname, requestparameters\n" +
+					"FROM %s\nWHERE eventsource = 'iam.amazonaws.com' AND readonly = 'false' AND " + recentDays + "\n" +
+					"ORDER BY eventtime DESC",
+			},
+		},
+	}
+}
+
+// flowLogTable returns the table of the VPC flow logs in their default format, delivered
+// under <prefix>/AWSLogs/<account>/vpcflowlogs/<region>/<day>
+func (a *LogAnalytics) flowLogTable() *table {
+	root := location(a.lz.FlowLogBucketName, a.cfg.FlowLogPrefix, "AWSLogs")
+
+	return &table{
+		name:            tableFlowLogs,
+		description:     "VPC flow logs delivered to the flow log bucket",
+		root:            root,
+		location:        root + "${account}/vpcflowlogs/${region}/${day}",
+		inputFormat:     textInputFormat,
+		serde:           lazySimpleSerde,
+		serdeParameters: map[string]string{"field.delim": " ", "serialization.format": " "},
+		parameters:      map[string]string{"skip.header.line.count": "1"},
+		columns: []column{
+			{"version", "int"},
+			{"account_id", "string"},
+			{"interface_id", "string"},
+			{"srcaddr", "string"},
+			{"dstaddr", "string"},
+			{"srcport", "int"},
+			{"dstport", "int"},
+			{"protocol", "bigint"},
+			{"packets", "bigint"},
+			{"bytes", "bigint"},
+			{"start", "bigint"},
+			{"end", "bigint"},
+			{"action", "string"},
+			{"log_status", "string"},
+		},
+		queries: []query{
+			{
+				id:          "rejected-traffic",
+				name:        "Flow logs: rejected traffic",
+				description: "Connections rejected by security groups and network ACLs in the last 7 days",
+				sql: "SELECT account, region, srcaddr, dstaddr, dstport, protocol, count(*) AS flows\n" +
+					"FROM %s\nWHERE action = 'REJECT' AND " + recentDays + "\n" +
+					"GROUP BY 1, 2, 3, 4, 5, 6\nORDER BY flows DESC\nLIMIT 100",
+			},
+			{
+				id:          "top-talkers",
+				name:        "Flow logs: top talkers",
+				description: "Address pairs exchanging the most bytes in the last 7 days",
+				sql: "SELECT account, srcaddr, dstaddr, sum(bytes) AS total_bytes\n" +
+					"FROM %s\nWHERE action = 'ACCEPT' AND " + recentDays + "\n" +
+					"GROUP BY 1, 2, 3\nORDER BY total_bytes DESC\nLIMIT 25",
+			},
+		},
+	}
+}
+
+// location returns the S3 location of the given path of a bucket, ending with a slash
+func location(bucket string, elements ...string) string {
+	return fmt.Sprintf("s3://%s/", path.Join(append([]string{bucket}, elements...)...))
+}
+
+// joinValues returns the values of an enum projection
+func joinValues(values []string) string {
+	return strings.Join(values, ",")
+}
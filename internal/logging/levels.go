@@ -0,0 +1,241 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package logging
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// Formats of the console output. The log files are always JSON.
+	FormatJSON    = "json"
+	FormatConsole = "console"
+
+	// Environment variables overriding the logging configuration. EnvLogLevels holds
+	// component=level pairs separated by commas, EnvLogFile false disables the log
+	// files.
+	EnvLogLevel  = "AWS_ORG_LOG_LEVEL"
+	EnvLogFormat = "AWS_ORG_LOG_FORMAT"
+	EnvLogLevels = "AWS_ORG_LOG_LEVELS"
+	EnvLogDir    = "AWS_ORG_LOG_DIR"
+	EnvLogFile   = "AWS_ORG_LOG_FILE"
+)
+
+// Settings holds the level and format of the logs. Components overrides the level of
+// the loggers of single components, named as in NewLogger. Directory replaces the
+// default directory of the log files, DisableFile logs to the console only.
+type Settings struct {
+	Level       string
+	Format      string
+	Components  map[string]string
+	Directory   string
+	DisableFile bool
+}
+
+// WithEnv returns the settings overridden by the environment variables
+func (s Settings) WithEnv() Settings {
+	if level := os.Getenv(EnvLogLevel); level != "" {
+		s.Level = level
+	}
+	if format := os.Getenv(EnvLogFormat); format != "" {
+		s.Format = format
+	}
+	if dir := os.Getenv(EnvLogDir); dir != "" {
+		s.Directory = dir
+	}
+	if enabled, err := strconv.ParseBool(os.Getenv(EnvLogFile)); err == nil {
+		s.DisableFile = !enabled
+	}
+	if pairs := os.Getenv(EnvLogLevels); pairs != "" {
+		components := make(map[string]string, len(s.Components))
+		for name, level := range s.Components {
+			components[name] = level
+		}
+		for _, pair := range strings.Split(pairs, ",") {
+			name, level, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			if name != "" {
+				components[strings.TrimSpace(name)] = strings.TrimSpace(level)
+			}
+		}
+		s.Components = components
+	}
+	return s
+}
+
+// levelSet holds the levels every logger is filtered with, changeable at runtime
+type levelSet struct {
+	mutex      sync.RWMutex
+	base       zapcore.Level
+	configured zapcore.Level
+	components map[string]zapcore.Level
+	console    *zapcore.Level
+}
+
+var (
+	// Levels of the loggers
+	levels = &levelSet{
+		base:       zapcore.InfoLevel,
+		configured: zapcore.InfoLevel,
+		components: map[string]zapcore.Level{},
+	}
+
+	// Format of the console output, fixed once the logger is created
+	consoleFormat = FormatConsole
+
+	// Directory of the log files and whether they are written, fixed once the logger
+	// is created. An empty directory selects the default of the platform.
+	logDirectory string
+	fileLogging  = true
+)
+
+// Configure applies the level, format and files of the settings. The format and files
+// only apply when the logger has not been created yet.
+func Configure(settings Settings) error {
+	base := zapcore.InfoLevel
+	if settings.Level != "" {
+		if err := base.Set(settings.Level); err != nil {
+			return fmt.Errorf("invalid log level %q", settings.Level)
+		}
+	}
+
+	components := make(map[string]zapcore.Level, len(settings.Components))
+	for name, value := range settings.Components {
+		var level zapcore.Level
+		if err := level.Set(value); err != nil {
+			return fmt.Errorf("invalid log level %q of component %s", value, name)
+		}
+		components[name] = level
+	}
+
+	switch settings.Format {
+	case "":
+	case FormatJSON, FormatConsole:
+		consoleFormat = settings.Format
+	default:
+		return fmt.Errorf("invalid log format %q", settings.Format)
+	}
+	logDirectory = settings.Directory
+	fileLogging = !settings.DisableFile
+
+	levels.mutex.Lock()
+	defer levels.mutex.Unlock()
+	levels.base = base
+	levels.configured = base
+	levels.components = components
+	return nil
+}
+
+// SetConsoleLevel sets the level of the entries written to the console, replacing the
+// configured levels there. The log files keep the configured levels.
+func SetConsoleLevel(level zapcore.Level) {
+	levels.mutex.Lock()
+	defer levels.mutex.Unlock()
+	levels.console = &level
+}
+
+// Levels describes the current levels, for example "info,engine=debug"
+func Levels() string {
+	levels.mutex.RLock()
+	defer levels.mutex.RUnlock()
+
+	parts := []string{levels.base.String()}
+	names := make([]string, 0, len(levels.components))
+	for name := range levels.components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%s", name, levels.components[name]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// HandleSIGHUP toggles debug logging of the loggers without a component override
+// every time the process receives SIGHUP, until the context ends. Long-running
+// commands call it so their verbosity can be raised without a restart.
+func HandleSIGHUP(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				levels.mutex.Lock()
+				if levels.base == zapcore.DebugLevel {
+					levels.base = levels.configured
+				} else {
+					levels.base = zapcore.DebugLevel
+				}
+				levels.mutex.Unlock()
+
+				if globalLogger != nil {
+					globalLogger.Warn("log level changed by SIGHUP", zap.String("levels", Levels()))
+				}
+			}
+		}
+	}()
+}
+
+// threshold returns the level of the entries of a logger written to a core
+func (l *levelSet) threshold(name string, console bool) zapcore.Level {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if console && l.console != nil {
+		return *l.console
+	}
+
+	// Sub-loggers are named component.child and follow their component
+	for name != "" {
+		if level, ok := l.components[name]; ok {
+			return level
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return l.base
+}
+
+// filterCore drops the entries below the level of their logger
+type filterCore struct {
+	zapcore.Core
+	console bool
+}
+
+// Enabled lets every level through to Check, which knows the logger of the entry
+func (c *filterCore) Enabled(level zapcore.Level) bool {
+	return c.Core.Enabled(level)
+}
+
+// With adds structured context to the core
+func (c *filterCore) With(fields []zapcore.Field) zapcore.Core {
+	return &filterCore{Core: c.Core.With(fields), console: c.console}
+}
+
+// Check adds the core to the entry when its level is enabled for its logger
+func (c *filterCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.Level < levels.threshold(entry.LoggerName, c.console) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
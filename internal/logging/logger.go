@@ -7,14 +7,17 @@
 package logging
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"runtime/debug"
 	"sync"
 	"time"
 
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
 	"github.com/go-chi/chi/v5/middleware"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -23,7 +26,6 @@ import (
 
 const (
 	// Default logging configurations
-	defaultLogPath      = "/var/log/aws-organization"
 	defaultMaxSize      = 100 // megabytes
 	defaultMaxBackups   = 5
 	defaultMaxAge       = 30 // days
@@ -35,9 +37,14 @@ var (
 	// Global logger instance
 	globalLogger *zap.Logger
 	once         sync.Once
+
+	// File the logs of every level are written to, empty without log files
+	logFile string
 )
 
-// LoggerConfig represents the configuration for the logger
+// LoggerConfig represents the configuration for the logger. Without LogPath, the
+// logs are written to the default directory of the platform, or of the user when the
+// former is not writable.
 type LoggerConfig struct {
 	LogPath       string
 	MaxSize       int // megabytes
@@ -46,60 +53,83 @@ type LoggerConfig struct {
 	Compress      bool
 	Development   bool
 	EnableConsole bool
+	DisableFile   bool
 }
 
-// NewLogger creates or returns the singleton logger instance
+// NewLogger returns the logger of a component, named after it, from the singleton
+// logger instance. Commands and modules both get their loggers here, so the
+// component levels and the SIGHUP toggle apply to every one of them.
 func NewLogger(component string) (*zap.Logger, error) {
 	var err error
 	once.Do(func() {
-		globalLogger, err = initLogger(component, getDefaultConfig())
+		globalLogger, err = initLogger(getDefaultConfig())
 	})
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize logger: %w", err)
 	}
 
-	return globalLogger.Named(component), nil
+	return globalLogger.Named(component).With(zap.String("component", component)), nil
 }
 
 // getDefaultConfig returns the default logging configuration
 func getDefaultConfig() *LoggerConfig {
 	return &LoggerConfig{
-		LogPath:       defaultLogPath,
+		LogPath:       logDirectory,
 		MaxSize:       defaultMaxSize,
 		MaxBackups:    defaultMaxBackups,
 		MaxAge:        defaultMaxAge,
 		Compress:      true,
 		Development:   false,
 		EnableConsole: true,
+		DisableFile:   !fileLogging,
 	}
 }
 
-// initLogger initializes the logger with the given configuration
-func initLogger(component string, config *LoggerConfig) (*zap.Logger, error) {
-	// Create log directory if it doesn't exist
-	if err := os.MkdirAll(config.LogPath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create log directory: %w", err)
+// defaultLogDirs returns the directories tried for the log files: the system log
+// directory of the platform, then the cache directory of the user
+func defaultLogDirs() []string {
+	var dirs []string
+	if runtime.GOOS == "windows" {
+		if programData := os.Getenv("ProgramData"); programData != "" {
+			dirs = append(dirs, filepath.Join(programData, "aws-organization", "logs"))
+		}
+	} else {
+		dirs = append(dirs, "/var/log/aws-organization")
 	}
-
-	// Configure main log file
-	mainLog := &lumberjack.Logger{
-		Filename:   filepath.Join(config.LogPath, defaultLogFileName),
-		MaxSize:    config.MaxSize,
-		MaxBackups: config.MaxBackups,
-		MaxAge:     config.MaxAge,
-		Compress:   config.Compress,
+	if cache, err := os.UserCacheDir(); err == nil {
+		dirs = append(dirs, filepath.Join(cache, "aws-organization", "logs"))
 	}
+	return dirs
+}
 
-	// Configure error log file
-	errorLog := &lumberjack.Logger{
-		Filename:   filepath.Join(config.LogPath, defaultErrorLogName),
-		MaxSize:    config.MaxSize,
-		MaxBackups: config.MaxBackups,
-		MaxAge:     config.MaxAge,
-		Compress:   config.Compress,
+// openLogDir returns the first of the directories the log files can be written to.
+// The log file is opened up front, so a read-only file system is detected before
+// entries are lost.
+func openLogDir(dirs []string) (string, error) {
+	var errs []error
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			errs = append(errs, fmt.Errorf("failed to create log directory: %w", err))
+			continue
+		}
+		file, err := os.OpenFile(filepath.Join(dir, defaultLogFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to open log file: %w", err))
+			continue
+		}
+		file.Close()
+		return dir, nil
+	}
+	if len(errs) == 0 {
+		return "", fmt.Errorf("no log directory available")
 	}
+	return "", errors.Join(errs...)
+}
 
+// initLogger initializes the logger with the given configuration. When no log
+// directory is writable, the logs are written to the console only.
+func initLogger(config *LoggerConfig) (*zap.Logger, error) {
 	// Create encoder configuration
 	encoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "timestamp",
@@ -116,27 +146,43 @@ func initLogger(component string, config *LoggerConfig) (*zap.Logger, error) {
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
-	// Create cores
-	cores := []zapcore.Core{
-		zapcore.NewCore(
-			zapcore.NewJSONEncoder(encoderConfig),
-			zapcore.AddSync(mainLog),
-			zapcore.InfoLevel,
-		),
-		zapcore.NewCore(
-			zapcore.NewJSONEncoder(encoderConfig),
-			zapcore.AddSync(errorLog),
-			zapcore.ErrorLevel,
-		),
+	// Create cores, filtered by the levels of their loggers
+	var cores []zapcore.Core
+	var fileErr error
+	if !config.DisableFile {
+		dirs := []string{config.LogPath}
+		if config.LogPath == "" {
+			dirs = defaultLogDirs()
+		}
+		var dir string
+		if dir, fileErr = openLogDir(dirs); fileErr == nil {
+			logFile = filepath.Join(dir, defaultLogFileName)
+			cores = append(cores,
+				&filterCore{Core: zapcore.NewCore(
+					zapcore.NewJSONEncoder(encoderConfig),
+					zapcore.AddSync(rotated(config, logFile)),
+					zapcore.DebugLevel,
+				)},
+				zapcore.NewCore(
+					zapcore.NewJSONEncoder(encoderConfig),
+					zapcore.AddSync(rotated(config, filepath.Join(dir, defaultErrorLogName))),
+					zapcore.ErrorLevel,
+				),
+			)
+		}
 	}
 
-	// Add console logging if enabled
-	if config.EnableConsole {
-		cores = append(cores, zapcore.NewCore(
-			zapcore.NewConsoleEncoder(encoderConfig),
+	// Add console logging if enabled, or when it is the only output left
+	if config.EnableConsole || len(cores) == 0 {
+		encoder := zapcore.NewConsoleEncoder(encoderConfig)
+		if consoleFormat == FormatJSON {
+			encoder = zapcore.NewJSONEncoder(encoderConfig)
+		}
+		cores = append(cores, &filterCore{Core: zapcore.NewCore(
+			encoder,
 			zapcore.AddSync(os.Stdout),
-			zapcore.InfoLevel,
-		))
+			zapcore.DebugLevel,
+		), console: true})
 	}
 
 	// Create options
@@ -144,8 +190,8 @@ func initLogger(component string, config *LoggerConfig) (*zap.Logger, error) {
 		zap.AddCaller(),
 		zap.AddStacktrace(zapcore.ErrorLevel),
 		zap.Fields(
-			zap.String("component", component),
 			zap.String("version", "1.0.0"),
+			runid.Field(),
 		),
 	}
 
@@ -153,9 +199,29 @@ func initLogger(component string, config *LoggerConfig) (*zap.Logger, error) {
 	core := zapcore.NewTee(cores...)
 	logger := zap.New(core, opts...)
 
+	if fileErr != nil {
+		logger.Warn("log files unavailable, logging to the console only", zap.Error(fileErr))
+	}
 	return logger, nil
 }
 
+// rotated returns the writer of a log file rotated as configured
+func rotated(config *LoggerConfig, filename string) *lumberjack.Logger {
+	return &lumberjack.Logger{
+		Filename:   filename,
+		MaxSize:    config.MaxSize,
+		MaxBackups: config.MaxBackups,
+		MaxAge:     config.MaxAge,
+		Compress:   config.Compress,
+	}
+}
+
+// LogFile returns the file the logs of every level are written to, or an empty string
+// when the logs are not written to files
+func LogFile() string {
+	return logFile
+}
+
 // WithContext adds context fields to the logger
 func WithContext(logger *zap.Logger, fields map[string]interface{}) *zap.Logger {
 	if len(fields) == 0 {
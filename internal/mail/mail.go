@@ -0,0 +1,141 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package mail provides the HTML emails sent through SES by the reports, approvals
+// and notifications.
+// Version: 1.0.0
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	sestypes "github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	"go.uber.org/zap"
+)
+
+// Message is an email rendered from one of the templates. Data is the data of the
+// template, such as a Report for TemplateReport. Text is the plain text alternative
+// of the HTML body.
+type Message struct {
+	To       []string
+	Subject  string
+	Template string
+	Data     any
+	Text     string
+}
+
+// Mailer sends the emails of the landing zone from its configured sender
+type Mailer struct {
+	logger  *zap.Logger
+	metrics *metrics.Collector
+	client  *sesv2.Client
+	cfg     *config.MailConfig
+}
+
+// NewMailer creates a mailer using the credentials of the management account. The
+// configuration must declare the mail sender.
+func NewMailer(ctx context.Context, lz *config.LandingZoneConfig) (*Mailer, error) {
+	if lz.Mail == nil || lz.Mail.From == "" {
+		return nil, fmt.Errorf("no mail sender is configured, see mail")
+	}
+
+	logger, err := logging.NewLogger("mail")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("mail")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if lz.Mail.Region != "" {
+		base.Region = lz.Mail.Region
+	}
+
+	return &Mailer{
+		logger:  logger,
+		metrics: metrics,
+		client:  sesv2.NewFromConfig(base),
+		cfg:     lz.Mail,
+	}, nil
+}
+
+// Send renders a message and sends it to its recipients
+func (m *Mailer) Send(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("email %q has no recipients", msg.Subject)
+	}
+
+	subject := m.cfg.SubjectPrefix + msg.Subject
+	html, err := Render(msg.Template, subject, msg.Data)
+	if err != nil {
+		return err
+	}
+
+	body := &sestypes.Body{Html: &sestypes.Content{Data: aws.String(html), Charset: aws.String("UTF-8")}}
+	if msg.Text != "" {
+		body.Text = &sestypes.Content{Data: aws.String(msg.Text), Charset: aws.String("UTF-8")}
+	}
+	input := &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(m.cfg.From),
+		Destination:      &sestypes.Destination{ToAddresses: msg.To},
+		ReplyToAddresses: m.cfg.ReplyTo,
+		Content: &sestypes.EmailContent{
+			Simple: &sestypes.Message{
+				Subject: &sestypes.Content{Data: aws.String(subject), Charset: aws.String("UTF-8")},
+				Body:    body,
+			},
+		},
+	}
+	if m.cfg.ConfigurationSet != "" {
+		input.ConfigurationSetName = aws.String(m.cfg.ConfigurationSet)
+	}
+
+	if _, err := m.client.SendEmail(ctx, input); err != nil {
+		m.metrics.IncrementCounter("send_failures")
+		return fmt.Errorf("failed to email %q to %s: %w", subject, strings.Join(msg.To, ", "), err)
+	}
+
+	m.metrics.IncrementCounter("emails_sent")
+	m.logger.Info("email sent",
+		zap.String("template", msg.Template),
+		zap.Strings("to", msg.To))
+	return nil
+}
+
+// Render renders the HTML body of a message from a template
+func Render(name, subject string, data any) (string, error) {
+	switch name {
+	case TemplateReport, TemplateApproval, TemplateNotification:
+	default:
+		return "", fmt.Errorf("unknown mail template %q", name)
+	}
+
+	var b bytes.Buffer
+	if err := templates.ExecuteTemplate(&b, name, envelope{
+		Subject:     subject,
+		RunID:       runid.ID(),
+		GeneratedAt: time.Now().UTC(),
+		Data:        data,
+	}); err != nil {
+		return "", fmt.Errorf("failed to render mail template %s: %w", name, err)
+	}
+	return b.String(), nil
+}
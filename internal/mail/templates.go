@@ -0,0 +1,104 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package mail
+
+import (
+	"html/template"
+	"time"
+)
+
+// Templates of the HTML bodies
+const (
+	TemplateReport       = "report"
+	TemplateApproval     = "approval"
+	TemplateNotification = "notification"
+)
+
+// Report is the data of the report template: a summary and the preformatted body of a
+// report, such as the text rendering of the compliance report
+type Report struct {
+	Title   string
+	Summary string
+	Body    string
+}
+
+// Field is a labelled value listed by the approval and notification templates
+type Field struct {
+	Label string
+	Value string
+}
+
+// Approval is the data of the approval template, asking a reviewer to approve an
+// action by running Command before Expires
+type Approval struct {
+	Title       string
+	Description string
+	Fields      []Field
+	Command     string
+	Expires     time.Time
+}
+
+// Notification is the data of the notification template: a message and the items it
+// is about, each with its fields
+type Notification struct {
+	Title   string
+	Message string
+	Items   []NotificationItem
+}
+
+// NotificationItem is an item of a notification
+type NotificationItem struct {
+	Name   string
+	Fields []Field
+}
+
+// envelope is passed to the templates, with the data of the message under Data
+type envelope struct {
+	Subject     string
+	RunID       string
+	GeneratedAt time.Time
+	Data        any
+}
+
+var templates = template.Must(template.New("mail").Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family: Arial, Helvetica, sans-serif; color: #232f3e; max-width: 720px;">
+<h2 style="border-bottom: 2px solid #ff9900; padding-bottom: 4px;">{{.Data.Title}}</h2>
+{{end}}
+
+{{define "footer"}}<p style="color: #687078; font-size: 12px; margin-top: 24px;">
+Sent by the landing zone, run {{.RunID}} at {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}.
+</p>
+</body>
+</html>
+{{end}}
+
+{{define "fields"}}<table style="border-collapse: collapse;">
+{{range .}}<tr><td style="padding: 2px 12px 2px 0; color: #687078;">{{.Label}}</td><td style="padding: 2px 0;">{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{define "report"}}{{template "header" .}}
+{{with .Data.Summary}}<p>{{.}}</p>{{end}}
+<pre style="background: #f2f3f3; padding: 12px; font-size: 12px; overflow-x: auto;">{{.Data.Body}}</pre>
+{{template "footer" .}}{{end}}
+
+{{define "approval"}}{{template "header" .}}
+<p>{{.Data.Description}}</p>
+{{template "fields" .Data.Fields}}
+<p>To approve, run:</p>
+<pre style="background: #f2f3f3; padding: 12px;">{{.Data.Command}}</pre>
+{{if not .Data.Expires.IsZero}}<p>The request expires at {{.Data.Expires.Format "2006-01-02 15:04 MST"}}.</p>{{end}}
+{{template "footer" .}}{{end}}
+
+{{define "notification"}}{{template "header" .}}
+<p>{{.Data.Message}}</p>
+{{range .Data.Items}}<h4 style="margin-bottom: 4px;">{{.Name}}</h4>
+{{template "fields" .Fields}}
+{{end}}
+{{template "footer" .}}{{end}}
+`))
@@ -0,0 +1,369 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package manifest provides the publication of the landing zone manifest to its consumers.
+// Version: 1.0.0
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/paramstore"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/appconfig"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ssm"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Profile holding the landing zone manifest
+	landingZoneProfile = "landing-zone"
+
+	// AppConfig settings of hosted JSON configurations
+	locationHosted      = "hosted"
+	profileTypeFreeform = "AWS.Freeform"
+	validatorJSONSchema = "JSON_SCHEMA"
+	contentTypeJSON     = "application/json"
+	growthTypeLinear    = "LINEAR"
+	replicateToNone     = "NONE"
+
+	// Parameter tier holding manifests larger than the standard tier allows
+	parameterTierIntelligent = "Intelligent-Tiering"
+)
+
+// Schemas validating the published configurations before they are deployed
+const (
+	landingZoneSchema = `{
+  "$schema": "http://json-schema.org/draft-04/schema#",
+  "type": "object",
+  "required": ["governedRegions"],
+  "properties": {
+    "governedRegions": {"type": "array", "minItems": 1, "items": {"type": "string"}},
+    "organizationUnits": {"type": "object"}
+  }
+}`
+	ouSchema = `{
+  "$schema": "http://json-schema.org/draft-04/schema#",
+  "type": "object",
+  "required": ["name"],
+  "properties": {
+    "name": {"type": "string", "minLength": 1},
+    "accounts": {"type": "array"}
+  }
+}`
+)
+
+// Manifest is the part of the landing zone configuration its consumers read: the
+// regions, the core accounts, the tags and the OUs with their accounts. Settings of
+// the deployment itself, such as role ARNs, hooks and webhooks, are left out.
+type Manifest struct {
+	HomeRegion          string            `json:"homeRegion,omitempty"`
+	GovernedRegions     []string          `json:"governedRegions"`
+	ManagementAccountId string            `json:"managementAccountId,omitempty"`
+	LogArchiveAccountId string            `json:"logArchiveAccountId,omitempty"`
+	AuditAccountId      string            `json:"auditAccountId,omitempty"`
+	SecurityAccountId   string            `json:"securityAccountId,omitempty"`
+	Tags                map[string]string `json:"tags,omitempty"`
+	OrganizationUnits   map[string]OU     `json:"organizationUnits"`
+}
+
+// OU is the published configuration of an OU
+type OU struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Accounts    []Account         `json:"accounts,omitempty"`
+}
+
+// Account is the published configuration of an account
+type Account struct {
+	Name       string            `json:"name"`
+	Email      string            `json:"email"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// NewManifest returns the manifest of the landing zone configuration
+func NewManifest(cfg *config.LandingZoneConfig) Manifest {
+	m := Manifest{
+		HomeRegion:          cfg.HomeRegion,
+		GovernedRegions:     cfg.GovernedRegions,
+		ManagementAccountId: cfg.ManagementAccountId,
+		LogArchiveAccountId: cfg.LogArchiveAccountId,
+		AuditAccountId:      cfg.AuditAccountId,
+		SecurityAccountId:   cfg.SecurityAccountId,
+		Tags:                cfg.Tags,
+		OrganizationUnits:   make(map[string]OU, len(cfg.OrganizationUnits)),
+	}
+	for key, ou := range cfg.OrganizationUnits {
+		m.OrganizationUnits[key] = newOU(key, ou)
+	}
+	return m
+}
+
+// newOU returns the published configuration of the OU of key
+func newOU(key string, ou *config.OUConfig) OU {
+	published := OU{Name: key}
+	if ou == nil {
+		return published
+	}
+	if ou.Name != "" {
+		published.Name = ou.Name
+	}
+	published.Description = ou.Description
+	published.Tags = ou.Tags
+	for _, account := range ou.Accounts {
+		published.Accounts = append(published.Accounts, Account{
+			Name:       account.Name,
+			Email:      account.Email,
+			Tags:       account.Tags,
+			Attributes: account.Attributes,
+		})
+	}
+	return published
+}
+
+// Publisher publishes the manifest through the default provider
+type Publisher struct {
+	logger  *zap.Logger
+	metrics *metrics.Collector
+}
+
+// Publish publishes the landing zone manifest to the configured backend
+func Publish(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("manifest")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("manifest")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("manifest_publish", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "publish manifest"); err != nil {
+		return err
+	}
+
+	if cfg.Manifest == nil {
+		return nil
+	}
+
+	p := &Publisher{logger: logger, metrics: metrics}
+	switch cfg.Manifest.Backend {
+	case config.ManifestBackendAppConfig:
+		return p.publishAppConfig(ctx, cfg)
+	default:
+		return p.publishParameter(ctx, cfg)
+	}
+}
+
+// publishParameter stores the manifest in a SecureString parameter, split across chunk
+// parameters when it exceeds the advanced tier
+func (p *Publisher) publishParameter(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	manifest := NewManifest(cfg)
+	content, err := marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	name := cfg.Manifest.ParameterName
+	if name == "" {
+		name = cfg.ParameterNames().Path(config.ParameterKindManifest)
+	}
+
+	parameters, err := paramstore.NewParameter(ctx, "landing-zone-manifest", name, content, &ssm.ParameterArgs{
+		Type:        pulumi.String("SecureString"),
+		Tier:        pulumi.String(parameterTierIntelligent),
+		Description: pulumi.String("Landing zone manifest"),
+		Tags:        pulumi.ToStringMap(cfg.Tags),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish manifest to %s: %w", name, err)
+	}
+
+	p.metrics.IncrementCounter("manifest_published")
+	p.logger.Info("manifest published to parameter store",
+		zap.String("parameter", name),
+		zap.Int("size", len(content)),
+		zap.Int("chunks", len(parameters)-1))
+	return nil
+}
+
+// publishAppConfig deploys the manifest to the environment of the application and the
+// configuration of every OU to an environment of its own, since an environment accepts
+// a single deployment at a time
+func (p *Publisher) publishAppConfig(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	settings := cfg.Manifest.AppConfig
+	if settings == nil {
+		settings = &config.AppConfigManifestConfig{}
+	}
+
+	name := settings.Application
+	if name == "" {
+		name = config.DefaultManifestApplication
+	}
+	environment := settings.Environment
+	if environment == "" {
+		environment = config.DefaultManifestEnvironment
+	}
+
+	application, err := appconfig.NewApplication(ctx, "manifest-application", &appconfig.ApplicationArgs{
+		Name:        pulumi.String(name),
+		Description: pulumi.String("Landing zone manifest and OU configurations"),
+		Tags:        pulumi.ToStringMap(cfg.Tags),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create AppConfig application %s: %w", name, err)
+	}
+
+	strategyId, err := p.deploymentStrategy(ctx, name, settings, cfg)
+	if err != nil {
+		return err
+	}
+
+	manifest := NewManifest(cfg)
+	content, err := marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := p.deploy(ctx, application, strategyId, environment, landingZoneProfile, landingZoneSchema, content, cfg); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(manifest.OrganizationUnits))
+	for key := range manifest.OrganizationUnits {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		content, err := marshal(manifest.OrganizationUnits[key])
+		if err != nil {
+			return err
+		}
+		profile := fmt.Sprintf("ou-%s", key)
+		if err := p.deploy(ctx, application, strategyId, profile, profile, ouSchema, content, cfg); err != nil {
+			return err
+		}
+	}
+
+	p.metrics.IncrementCounter("manifest_published")
+	p.logger.Info("manifest published to AppConfig",
+		zap.String("application", name),
+		zap.String("environment", environment),
+		zap.Int("ous", len(keys)))
+	return nil
+}
+
+// deploymentStrategy returns the ID of the predefined strategy, or creates a linear
+// strategy from the configured duration, growth factor and bake time
+func (p *Publisher) deploymentStrategy(ctx *pulumi.Context, application string, settings *config.AppConfigManifestConfig, cfg *config.LandingZoneConfig) (pulumi.StringInput, error) {
+	if settings.DeploymentStrategy != "" {
+		return pulumi.String(settings.DeploymentStrategy), nil
+	}
+
+	duration := settings.DeploymentDurationMinutes
+	if duration == 0 {
+		duration = config.DefaultDeploymentDuration
+	}
+	growth := settings.GrowthFactor
+	if growth == 0 {
+		growth = config.DefaultDeploymentGrowthFactor
+	}
+	bake := settings.FinalBakeTimeMinutes
+	if bake == 0 {
+		bake = config.DefaultDeploymentFinalBakeTime
+	}
+
+	strategy, err := appconfig.NewDeploymentStrategy(ctx, "manifest-deployment-strategy", &appconfig.DeploymentStrategyArgs{
+		Name:                        pulumi.Sprintf("%s-linear", application),
+		Description:                 pulumi.String("Gradual rollout of the landing zone manifest"),
+		DeploymentDurationInMinutes: pulumi.Int(duration),
+		GrowthFactor:                pulumi.Float64(growth),
+		GrowthType:                  pulumi.String(growthTypeLinear),
+		FinalBakeTimeInMinutes:      pulumi.Int(bake),
+		ReplicateTo:                 pulumi.String(replicateToNone),
+		Tags:                        pulumi.ToStringMap(cfg.Tags),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create manifest deployment strategy: %w", err)
+	}
+	return strategy.ID().ToStringOutput(), nil
+}
+
+// deploy creates a validated configuration profile, hosts the content as a new version
+// and deploys it to the environment. A changed content creates a new version and with
+// it a new deployment.
+func (p *Publisher) deploy(ctx *pulumi.Context, application *appconfig.Application, strategyId pulumi.StringInput, environmentName, profileName, schema, content string, cfg *config.LandingZoneConfig) error {
+	environment, err := appconfig.NewEnvironment(ctx, fmt.Sprintf("manifest-environment-%s", environmentName), &appconfig.EnvironmentArgs{
+		ApplicationId: application.ID().ToStringOutput(),
+		Name:          pulumi.String(environmentName),
+		Tags:          pulumi.ToStringMap(cfg.Tags),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create AppConfig environment %s: %w", environmentName, err)
+	}
+
+	profile, err := appconfig.NewConfigurationProfile(ctx, fmt.Sprintf("manifest-profile-%s", profileName), &appconfig.ConfigurationProfileArgs{
+		ApplicationId: application.ID().ToStringOutput(),
+		Name:          pulumi.String(profileName),
+		LocationUri:   pulumi.String(locationHosted),
+		Type:          pulumi.String(profileTypeFreeform),
+		Validators: appconfig.ConfigurationProfileValidatorArray{
+			&appconfig.ConfigurationProfileValidatorArgs{
+				Type:    pulumi.String(validatorJSONSchema),
+				Content: pulumi.String(schema),
+			},
+		},
+		Tags: pulumi.ToStringMap(cfg.Tags),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create AppConfig configuration profile %s: %w", profileName, err)
+	}
+
+	version, err := appconfig.NewHostedConfigurationVersion(ctx, fmt.Sprintf("manifest-version-%s", profileName), &appconfig.HostedConfigurationVersionArgs{
+		ApplicationId:          application.ID().ToStringOutput(),
+		ConfigurationProfileId: profile.ConfigurationProfileId,
+		Content:                pulumi.String(content),
+		ContentType:            pulumi.String(contentTypeJSON),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to host configuration %s: %w", profileName, err)
+	}
+
+	if _, err := appconfig.NewDeployment(ctx, fmt.Sprintf("manifest-deployment-%s", profileName), &appconfig.DeploymentArgs{
+		ApplicationId:          application.ID().ToStringOutput(),
+		ConfigurationProfileId: profile.ConfigurationProfileId,
+		ConfigurationVersion:   version.VersionNumber.ApplyT(strconv.Itoa).(pulumi.StringOutput),
+		DeploymentStrategyId:   strategyId,
+		EnvironmentId:          environment.EnvironmentId,
+		Tags:                   pulumi.ToStringMap(cfg.Tags),
+	}); err != nil {
+		return fmt.Errorf("failed to deploy configuration %s: %w", profileName, err)
+	}
+	return nil
+}
+
+// marshal encodes a published configuration
+func marshal(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	return string(data), nil
+}
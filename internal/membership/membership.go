@@ -0,0 +1,379 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package membership provides the reconciliation of organization membership changes made outside the tool.
+// Version: 1.0.0
+package membership
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// RecordName is the name of the membership record in the state
+	RecordName = "membership"
+
+	// DefaultPlacementTagKey is the account tag naming the OU of an account when none is configured
+	DefaultPlacementTagKey = "OU"
+
+	// Checks reported in drift reports
+	CheckUntrackedAccount = "untracked-account"
+	CheckAccountJoined    = "account-joined"
+	CheckAccountLeft      = "account-left"
+	CheckPendingHandshake = "pending-handshake"
+)
+
+// Record is the membership of the organization recorded at the last reconciliation
+type Record struct {
+	ReconciledAt time.Time         `json:"reconciledAt"`
+	Accounts     map[string]string `json:"accounts"`
+}
+
+// Account is a member account with the OU suggested for it
+type Account struct {
+	AccountID    string `json:"accountId"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	SuggestedOU  string `json:"suggestedOu,omitempty"`
+	SuggestedBy  string `json:"suggestedBy,omitempty"`
+	JoinedMethod string `json:"joinedMethod,omitempty"`
+}
+
+// Handshake is a handshake of the organization still waiting for an answer
+type Handshake struct {
+	ID        string     `json:"id"`
+	Action    string     `json:"action"`
+	State     string     `json:"state"`
+	Target    string     `json:"target"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Result is the outcome of a reconciliation
+type Result struct {
+	PendingHandshakes []Handshake `json:"pendingHandshakes"`
+	Joined            []Account   `json:"joined"`
+	Left              []Account   `json:"left"`
+	Untracked         []Account   `json:"untracked"`
+}
+
+// Reconciler compares the membership of the organization with the configuration and
+// the membership recorded at the last reconciliation
+type Reconciler struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	orgClient *organizations.Client
+	cache     *orgcache.Cache
+	cfg       *config.LandingZoneConfig
+}
+
+// NewReconciler creates a new membership reconciler instance
+func NewReconciler(ctx context.Context, cfg *config.LandingZoneConfig) (*Reconciler, error) {
+	logger, err := logging.NewLogger("membership")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("membership")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reconciler{
+		logger:    logger,
+		metrics:   metrics,
+		orgClient: organizations.NewFromConfig(base),
+		cfg:       cfg,
+	}, nil
+}
+
+// UseCache lists the member accounts through the organization cache
+func (r *Reconciler) UseCache(cache *orgcache.Cache) {
+	r.cache = cache
+}
+
+// Reconcile lists the pending handshakes and the member accounts, and compares them
+// with the previous record. Without a previous record no joined or left accounts are
+// reported. The record to store is returned with the result.
+func (r *Reconciler) Reconcile(ctx context.Context, previous *Record) (*Result, Record, error) {
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordDuration("reconciliation_duration", time.Since(start))
+	}()
+
+	result := &Result{
+		PendingHandshakes: []Handshake{},
+		Joined:            []Account{},
+		Left:              []Account{},
+		Untracked:         []Account{},
+	}
+
+	handshakes, err := r.pendingHandshakes(ctx)
+	if err != nil {
+		return nil, Record{}, err
+	}
+	result.PendingHandshakes = handshakes
+
+	members, err := r.listMembers(ctx)
+	if err != nil {
+		return nil, Record{}, err
+	}
+
+	record := Record{ReconciledAt: time.Now().UTC(), Accounts: make(map[string]string)}
+	tracked := r.trackedAccounts()
+	for _, member := range members {
+		id := aws.ToString(member.Id)
+		record.Accounts[id] = aws.ToString(member.Name)
+
+		account := Account{
+			AccountID:    id,
+			Name:         aws.ToString(member.Name),
+			Email:        aws.ToString(member.Email),
+			JoinedMethod: string(member.JoinedMethod),
+		}
+		if previous != nil {
+			if _, ok := previous.Accounts[id]; !ok {
+				result.Joined = append(result.Joined, account)
+			}
+		}
+		if tracked[id] || tracked[strings.ToLower(account.Email)] {
+			continue
+		}
+
+		tags, err := r.accountTags(ctx, id)
+		if err != nil {
+			return nil, Record{}, err
+		}
+		account.SuggestedOU, account.SuggestedBy = r.SuggestOU(tags)
+		result.Untracked = append(result.Untracked, account)
+	}
+
+	if previous != nil {
+		for _, id := range report.SortedKeys(previous.Accounts) {
+			if _, ok := record.Accounts[id]; !ok {
+				result.Left = append(result.Left, Account{AccountID: id, Name: previous.Accounts[id]})
+			}
+		}
+	}
+
+	r.logger.Info("membership reconciliation completed",
+		zap.Int("members", len(members)),
+		zap.Int("pendingHandshakes", len(result.PendingHandshakes)),
+		zap.Int("joined", len(result.Joined)),
+		zap.Int("left", len(result.Left)),
+		zap.Int("untracked", len(result.Untracked)))
+
+	return result, record, nil
+}
+
+// Collect adds the findings of a reconciliation to a drift report
+func (r *Reconciler) Collect(ctx context.Context, previous *Record, rep *report.Report) error {
+	result, _, err := r.Reconcile(ctx, previous)
+	if err != nil {
+		return err
+	}
+	rep.Add(result.Findings(r.cfg.ManagementAccountId)...)
+	return nil
+}
+
+// Findings returns the result as drift findings. Handshakes are reported against the
+// management account.
+func (res *Result) Findings(managementAccountID string) []report.Finding {
+	var findings []report.Finding
+	for _, account := range res.Untracked {
+		message := fmt.Sprintf("account %s is not in the configuration", account.Name)
+		if account.SuggestedOU != "" {
+			message = fmt.Sprintf("%s, suggested OU %s (%s)", message, account.SuggestedOU, account.SuggestedBy)
+		}
+		findings = append(findings, report.Finding{
+			AccountID: account.AccountID,
+			Check:     CheckUntrackedAccount,
+			Severity:  report.SeverityMedium,
+			Resource:  account.Email,
+			Message:   message,
+		})
+	}
+	for _, account := range res.Joined {
+		findings = append(findings, report.Finding{
+			AccountID: account.AccountID,
+			Check:     CheckAccountJoined,
+			Severity:  report.SeverityLow,
+			Resource:  account.Email,
+			Message:   fmt.Sprintf("account %s joined the organization (%s) since the last reconciliation", account.Name, strings.ToLower(account.JoinedMethod)),
+		})
+	}
+	for _, account := range res.Left {
+		findings = append(findings, report.Finding{
+			AccountID: account.AccountID,
+			Check:     CheckAccountLeft,
+			Severity:  report.SeverityHigh,
+			Message:   fmt.Sprintf("account %s left the organization since the last reconciliation", account.Name),
+		})
+	}
+	for _, handshake := range res.PendingHandshakes {
+		findings = append(findings, report.Finding{
+			AccountID: managementAccountID,
+			Check:     CheckPendingHandshake,
+			Severity:  report.SeverityInfo,
+			Resource:  handshake.ID,
+			Message:   fmt.Sprintf("%s handshake with %s is %s", strings.ToLower(handshake.Action), handshake.Target, strings.ToLower(handshake.State)),
+		})
+	}
+	return findings
+}
+
+// SuggestOU returns the configured OU suggested for an account with the given tags and
+// the tag it was derived from. The placement tag takes precedence over the placement
+// rules, and the default OU is suggested when nothing matches.
+func (r *Reconciler) SuggestOU(tags map[string]string) (string, string) {
+	key := DefaultPlacementTagKey
+	var rules []config.PlacementRule
+	if r.cfg.Drift != nil {
+		if r.cfg.Drift.PlacementTagKey != "" {
+			key = r.cfg.Drift.PlacementTagKey
+		}
+		rules = r.cfg.Drift.PlacementRules
+	}
+
+	if value, ok := tags[key]; ok {
+		for _, name := range report.SortedKeys(r.cfg.OrganizationUnits) {
+			ou := r.cfg.OrganizationUnits[name]
+			if strings.EqualFold(value, name) || (ou != nil && strings.EqualFold(value, ou.Name)) {
+				return name, fmt.Sprintf("tag %s=%s", key, value)
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		value, ok := tags[rule.TagKey]
+		if ok && (rule.TagValue == "" || rule.TagValue == value) {
+			return rule.OU, fmt.Sprintf("tag %s=%s", rule.TagKey, value)
+		}
+	}
+
+	if r.cfg.DefaultOUName != "" {
+		return r.cfg.DefaultOUName, "default OU"
+	}
+	return "", ""
+}
+
+// trackedAccounts returns the account IDs and lower-cased emails the configuration knows
+func (r *Reconciler) trackedAccounts() map[string]bool {
+	tracked := map[string]bool{
+		r.cfg.ManagementAccountId: true,
+		r.cfg.LogArchiveAccountId: true,
+		r.cfg.AuditAccountId:      true,
+		r.cfg.SecurityAccountId:   true,
+	}
+	for _, ou := range r.cfg.OrganizationUnits {
+		if ou == nil {
+			continue
+		}
+		for _, account := range ou.Accounts {
+			tracked[strings.ToLower(account.Email)] = true
+		}
+	}
+	for _, invitation := range r.cfg.Invitations {
+		tracked[invitation.AccountId] = true
+		tracked[strings.ToLower(invitation.Email)] = true
+	}
+	delete(tracked, "")
+	return tracked
+}
+
+// pendingHandshakes returns the handshakes of the organization waiting for an answer
+func (r *Reconciler) pendingHandshakes(ctx context.Context) ([]Handshake, error) {
+	handshakes := []Handshake{}
+
+	paginator := organizations.NewListHandshakesForOrganizationPaginator(r.orgClient,
+		&organizations.ListHandshakesForOrganizationInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list handshakes: %w", err)
+		}
+		for _, handshake := range page.Handshakes {
+			if handshake.State != orgtypes.HandshakeStateOpen && handshake.State != orgtypes.HandshakeStateRequested {
+				continue
+			}
+
+			var target string
+			for _, party := range handshake.Parties {
+				if party.Type != orgtypes.HandshakePartyTypeOrganization {
+					target = aws.ToString(party.Id)
+				}
+			}
+			handshakes = append(handshakes, Handshake{
+				ID:        aws.ToString(handshake.Id),
+				Action:    string(handshake.Action),
+				State:     string(handshake.State),
+				Target:    target,
+				ExpiresAt: handshake.ExpirationTimestamp,
+			})
+		}
+	}
+
+	return handshakes, nil
+}
+
+// listMembers returns the accounts of the organization sorted by ID
+func (r *Reconciler) listMembers(ctx context.Context) ([]orgtypes.Account, error) {
+	if r.cache != nil {
+		return r.cache.Accounts(ctx)
+	}
+
+	var members []orgtypes.Account
+
+	paginator := organizations.NewListAccountsPaginator(r.orgClient, &organizations.ListAccountsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list accounts: %w", err)
+		}
+		members = append(members, page.Accounts...)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return aws.ToString(members[i].Id) < aws.ToString(members[j].Id)
+	})
+	return members, nil
+}
+
+// accountTags returns the tags of an account
+func (r *Reconciler) accountTags(ctx context.Context, accountID string) (map[string]string, error) {
+	tags := make(map[string]string)
+
+	paginator := organizations.NewListTagsForResourcePaginator(r.orgClient,
+		&organizations.ListTagsForResourceInput{ResourceId: aws.String(accountID)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of account %s: %w", accountID, err)
+		}
+		for _, tag := range page.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	return tags, nil
+}
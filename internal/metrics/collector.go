@@ -7,10 +7,11 @@
 package metrics
 
 import (
-	"fmt"
 	"sync"
 	"time"
 
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.uber.org/zap"
@@ -29,6 +30,9 @@ const (
 	// Default metric configurations
 	defaultNamespace = "aws_organization"
 	defaultSubsystem = "operations"
+
+	// Label of every metric holding the run ID
+	runIDLabel = "run_id"
 )
 
 // Collector handles metrics collection and reporting
@@ -37,6 +41,7 @@ type Collector struct {
 	namespace  string
 	subsystem  string
 	registry   *prometheus.Registry
+	labels     prometheus.Labels
 	counters   map[string]prometheus.Counter
 	gauges     map[string]prometheus.Gauge
 	histograms map[string]prometheus.Histogram
@@ -46,9 +51,9 @@ type Collector struct {
 
 // NewCollector creates a new metrics collector
 func NewCollector(component string) (*Collector, error) {
-	logger, err := zap.NewProduction()
+	logger, err := logging.NewLogger("metrics")
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+		return nil, err
 	}
 
 	registry := prometheus.NewRegistry()
@@ -58,6 +63,7 @@ func NewCollector(component string) (*Collector, error) {
 		namespace:  defaultNamespace,
 		subsystem:  component,
 		registry:   registry,
+		labels:     prometheus.Labels{runIDLabel: runid.ID()},
 		counters:   make(map[string]prometheus.Counter),
 		gauges:     make(map[string]prometheus.Gauge),
 		histograms: make(map[string]prometheus.Histogram),
@@ -73,9 +79,10 @@ func (c *Collector) IncrementCounter(name string) {
 	counter, exists := c.counters[name]
 	if !exists {
 		counter = promauto.NewCounter(prometheus.CounterOpts{
-			Namespace: c.namespace,
-			Subsystem: c.subsystem,
-			Name:      name,
+			Namespace:   c.namespace,
+			Subsystem:   c.subsystem,
+			Name:        name,
+			ConstLabels: c.labels,
 		})
 		c.counters[name] = counter
 	}
@@ -92,9 +99,10 @@ func (c *Collector) SetGauge(name string, value float64) {
 	gauge, exists := c.gauges[name]
 	if !exists {
 		gauge = promauto.NewGauge(prometheus.GaugeOpts{
-			Namespace: c.namespace,
-			Subsystem: c.subsystem,
-			Name:      name,
+			Namespace:   c.namespace,
+			Subsystem:   c.subsystem,
+			Name:        name,
+			ConstLabels: c.labels,
 		})
 		c.gauges[name] = gauge
 	}
@@ -113,10 +121,11 @@ func (c *Collector) RecordDuration(name string, duration time.Duration) {
 	histogram, exists := c.histograms[name]
 	if !exists {
 		histogram = promauto.NewHistogram(prometheus.HistogramOpts{
-			Namespace: c.namespace,
-			Subsystem: c.subsystem,
-			Name:      name,
-			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15), // From 1ms to ~16s
+			Namespace:   c.namespace,
+			Subsystem:   c.subsystem,
+			Name:        name,
+			ConstLabels: c.labels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 2, 15), // From 1ms to ~16s
 		})
 		c.histograms[name] = histogram
 	}
@@ -135,10 +144,11 @@ func (c *Collector) RecordValue(name string, value float64) {
 	summary, exists := c.summaries[name]
 	if !exists {
 		summary = promauto.NewSummary(prometheus.SummaryOpts{
-			Namespace:  c.namespace,
-			Subsystem:  c.subsystem,
-			Name:       name,
-			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+			Namespace:   c.namespace,
+			Subsystem:   c.subsystem,
+			Name:        name,
+			ConstLabels: c.labels,
+			Objectives:  map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
 		})
 		c.summaries[name] = summary
 	}
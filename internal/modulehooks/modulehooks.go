@@ -0,0 +1,333 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package modulehooks runs the configured hooks before and after each module of the landing zone.
+// Version: 1.0.0
+package modulehooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// OutputModuleHooks is the stack output the post hooks complete through
+	OutputModuleHooks = "moduleHooks"
+
+	// Environment of the hook commands
+	EnvModule = "AWS_ORG_HOOK_MODULE"
+	EnvPhase  = "AWS_ORG_HOOK_PHASE"
+
+	// Headers of the webhook requests
+	HeaderModule = "X-Landing-Zone-Module"
+	HeaderPhase  = "X-Landing-Zone-Phase"
+	HeaderRunID  = "X-Landing-Zone-Run-Id"
+
+	// completed is the value of the output of a module whose post hooks ran
+	completed = "completed"
+)
+
+// Payload is the JSON document passed to every hook
+type Payload struct {
+	Module  string                 `json:"module"`
+	Phase   string                 `json:"phase"`
+	RunID   string                 `json:"runId"`
+	Project string                 `json:"project"`
+	Stack   string                 `json:"stack"`
+	Outputs map[string]interface{} `json:"outputs,omitempty"`
+}
+
+// Runner runs the hooks of the modules. It tracks the resources each module
+// registers, so the post hooks of a module run once all of them are created.
+type Runner struct {
+	logger  *zap.Logger
+	metrics *metrics.Collector
+	hooks   map[string]*config.ModuleHooksConfig
+	project string
+	stack   string
+	enabled bool
+	client  *http.Client
+
+	mutex     sync.Mutex
+	current   string
+	resources map[string][]pulumi.CustomResource
+	completed pulumi.StringMap
+	lambda    *lambda.Client
+}
+
+// NewRunner creates the runner of the module hooks of a landing zone. Hooks only run
+// in updates: previews and read-only runs skip them.
+func NewRunner(ctx *pulumi.Context, cfg *config.LandingZoneConfig) (*Runner, error) {
+	logger, err := logging.NewLogger("modulehooks")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("modulehooks")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	r := &Runner{
+		logger:    logger,
+		metrics:   metrics,
+		hooks:     cfg.ModuleHooks,
+		project:   ctx.Project(),
+		stack:     ctx.Stack(),
+		enabled:   len(cfg.ModuleHooks) > 0 && !ctx.DryRun() && !readonly.Enabled(),
+		client:    &http.Client{},
+		resources: make(map[string][]pulumi.CustomResource),
+		completed: pulumi.StringMap{},
+	}
+	if !r.enabled {
+		return r, nil
+	}
+
+	if err := ctx.RegisterStackTransformation(r.track); err != nil {
+		return nil, fmt.Errorf("failed to register module resource tracking: %w", err)
+	}
+	return r, nil
+}
+
+// track records the resources registered while a module is applied
+func (r *Runner) track(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+	resource, ok := args.Resource.(pulumi.CustomResource)
+	if !ok {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.current != "" {
+		r.resources[r.current] = append(r.resources[r.current], resource)
+	}
+	return nil
+}
+
+// Pre runs the pre hooks of a module and starts tracking its resources
+func (r *Runner) Pre(ctx *pulumi.Context, module string) error {
+	if !r.enabled {
+		return nil
+	}
+
+	r.mutex.Lock()
+	r.current = module
+	r.mutex.Unlock()
+
+	return r.run(ctx.Context(), module, config.ModuleHookPre, nil)
+}
+
+// Post runs the post hooks of a module once its resources are created and its
+// outputs known, passing the outputs to the hooks
+func (r *Runner) Post(ctx *pulumi.Context, module string, outputs map[string]pulumi.Input) {
+	if !r.enabled {
+		return
+	}
+
+	r.mutex.Lock()
+	r.current = ""
+	resources := r.resources[module]
+	r.mutex.Unlock()
+
+	if len(r.hooks[module].Phase(config.ModuleHookPost)) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(outputs))
+	for key := range outputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// The IDs of the resources resolve once they are created
+	inputs := make([]interface{}, 0, len(keys)+len(resources))
+	for _, key := range keys {
+		inputs = append(inputs, outputs[key])
+	}
+	for _, resource := range resources {
+		inputs = append(inputs, resource.ID())
+	}
+
+	done := pulumi.All(inputs...).ApplyT(func(values []interface{}) (string, error) {
+		payloadOutputs := make(map[string]interface{}, len(keys))
+		for i, key := range keys {
+			payloadOutputs[key] = values[i]
+		}
+		if err := r.run(ctx.Context(), module, config.ModuleHookPost, payloadOutputs); err != nil {
+			return "", err
+		}
+		return completed, nil
+	}).(pulumi.StringOutput)
+
+	r.mutex.Lock()
+	r.completed[module] = done
+	r.mutex.Unlock()
+}
+
+// Export exports the completion of the post hooks, so the update waits for them and
+// fails with them
+func (r *Runner) Export(ctx *pulumi.Context) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.completed) > 0 {
+		ctx.Export(OutputModuleHooks, r.completed)
+	}
+}
+
+// run runs the hooks of a phase of a module in order
+func (r *Runner) run(ctx context.Context, module, phase string, outputs map[string]interface{}) error {
+	hooks := r.hooks[module].Phase(phase)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(Payload{
+		Module:  module,
+		Phase:   phase,
+		RunID:   runid.ID(),
+		Project: r.project,
+		Stack:   r.stack,
+		Outputs: outputs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s hook payload of module %s: %w", phase, module, err)
+	}
+
+	for _, hook := range hooks {
+		start := time.Now()
+		err := r.runHook(ctx, module, phase, hook, payload)
+		r.metrics.RecordDuration("module_hook_duration", time.Since(start))
+
+		fields := []zap.Field{
+			zap.String("module", module),
+			zap.String("phase", phase),
+			zap.String("hook", hook.Name),
+			zap.String("kind", hook.Kind()),
+		}
+		if err == nil {
+			r.metrics.IncrementCounter("module_hooks_succeeded")
+			r.logger.Info("module hook completed", fields...)
+			continue
+		}
+
+		r.metrics.IncrementCounter("module_hooks_failed")
+		if hook.ContinueOnError {
+			r.logger.Warn("module hook failed, continuing", append(fields, zap.Error(err))...)
+			continue
+		}
+		return fmt.Errorf("%s hook %s of module %s failed: %w", phase, hook.Name, module, err)
+	}
+	return nil
+}
+
+// runHook runs a single hook within its timeout
+func (r *Runner) runHook(ctx context.Context, module, phase string, hook config.ModuleHook, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(hook.Timeout())*time.Second)
+	defer cancel()
+
+	switch hook.Kind() {
+	case config.ModuleHookCommand:
+		return r.runCommand(ctx, module, phase, hook, payload)
+	case config.ModuleHookLambda:
+		return r.invokeFunction(ctx, hook, payload)
+	default:
+		return r.postWebhook(ctx, module, phase, hook, payload)
+	}
+}
+
+// runCommand runs a local command with the payload on its standard input
+func (r *Runner) runCommand(ctx context.Context, module, phase string, hook config.ModuleHook, payload []byte) error {
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(), EnvModule+"="+module, EnvPhase+"="+phase, runid.Env+"="+runid.ID())
+
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		r.logger.Info("module hook output",
+			zap.String("hook", hook.Name),
+			zap.ByteString("output", output))
+	}
+	if err != nil {
+		return fmt.Errorf("command failed: %w", err)
+	}
+	return nil
+}
+
+// invokeFunction invokes a Lambda function with the payload and waits for its result
+func (r *Runner) invokeFunction(ctx context.Context, hook config.ModuleHook, payload []byte) error {
+	client, err := r.lambdaClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	output, err := client.Invoke(ctx, &lambda.InvokeInput{
+		FunctionName: aws.String(hook.FunctionName),
+		Payload:      payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to invoke %s: %w", hook.FunctionName, err)
+	}
+	if output.FunctionError != nil {
+		return fmt.Errorf("function %s failed: %s: %s", hook.FunctionName, aws.ToString(output.FunctionError), output.Payload)
+	}
+	return nil
+}
+
+// lambdaClient returns the Lambda client, created on first use
+func (r *Runner) lambdaClient(ctx context.Context) (*lambda.Client, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.lambda == nil {
+		cfg, err := awsclient.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r.lambda = lambda.NewFromConfig(cfg)
+	}
+	return r.lambda, nil
+}
+
+// postWebhook posts the payload to a webhook
+func (r *Runner) postWebhook(ctx context.Context, module, phase string, hook config.ModuleHook, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderModule, module)
+	req.Header.Set(HeaderPhase, phase)
+	req.Header.Set(HeaderRunID, runid.ID())
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %w", hook.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s answered %s", hook.Name, resp.Status)
+	}
+	return nil
+}
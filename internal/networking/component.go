@@ -0,0 +1,68 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package networking
+
+import (
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// NetworkComponentType is the type token of the network component
+var NetworkComponentType = component.Type("networking", "Network")
+
+// NetworkComponentArgs defines the inputs of the network component
+type NetworkComponentArgs struct {
+	// Config describes the shared VPC in VPCSettings
+	Config *config.LandingZoneConfig
+
+	// OrganizationArn is the organization the subnets are shared with. The subnets are
+	// not shared when it is nil.
+	OrganizationArn pulumi.StringInput
+}
+
+// NetworkComponent groups the shared VPC, its subnets and their RAM share under a single
+// component resource
+type NetworkComponent struct {
+	pulumi.ResourceState
+
+	VpcId     pulumi.StringOutput      `pulumi:"vpcId"`
+	SubnetIds pulumi.StringArrayOutput `pulumi:"subnetIds"`
+}
+
+// NewNetworkComponent creates the shared network as a component resource
+func NewNetworkComponent(ctx *pulumi.Context, name string, args *NetworkComponentArgs, opts ...pulumi.ResourceOption) (*NetworkComponent, error) {
+	if args == nil || args.Config == nil {
+		return nil, fmt.Errorf("network component configuration cannot be nil")
+	}
+
+	c := &NetworkComponent{}
+	if err := ctx.RegisterComponentResource(NetworkComponentType, name, c, opts...); err != nil {
+		return nil, fmt.Errorf("failed to register network component: %w", err)
+	}
+
+	net, err := setupNetwork(ctx, args.Config, args.OrganizationArn, component.ChildOptions(c)...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.VpcId = pulumi.String("").ToStringOutput()
+	c.SubnetIds = pulumi.StringArray{}.ToStringArrayOutput()
+	if net != nil {
+		c.VpcId = net.vpc.ID().ToStringOutput()
+		c.SubnetIds = net.subnetIDs.ToStringArrayOutput()
+	}
+
+	if err := ctx.RegisterResourceOutputs(c, pulumi.Map{
+		OutputVPCID:     c.VpcId,
+		OutputSubnetIDs: c.SubnetIds,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to register network outputs: %w", err)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package networking provides the shared network of the landing zone.
+// Version: 1.0.0
+package networking
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ram"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Outputs exported by the networking module
+	OutputVPCID     = "vpcId"
+	OutputSubnetIDs = "subnetIds"
+
+	// Name of the RAM share publishing the subnets to the organization
+	subnetShareName = "landing-zone-subnets"
+)
+
+// network holds the resources of the shared network
+type network struct {
+	vpc       *ec2.Vpc
+	subnetIDs pulumi.StringArray
+}
+
+// SetupNetworking creates the shared VPC described by VPCSettings. When the organization
+// ARN is known the subnets are shared with every account of the organization.
+func SetupNetworking(ctx *pulumi.Context, cfg *config.LandingZoneConfig, organizationArn pulumi.StringInput) error {
+	net, err := setupNetwork(ctx, cfg, organizationArn)
+	if err != nil || net == nil {
+		return err
+	}
+
+	ctx.Export(OutputVPCID, net.vpc.ID())
+	ctx.Export(OutputSubnetIDs, net.subnetIDs)
+	return nil
+}
+
+// setupNetwork creates the shared network, applying the options to every resource. No
+// network is returned when VPCSettings is not configured.
+func setupNetwork(ctx *pulumi.Context, cfg *config.LandingZoneConfig, organizationArn pulumi.StringInput, opts ...pulumi.ResourceOption) (*network, error) {
+	logger, err := logging.NewLogger("networking")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("networking")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("networking_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup networking"); err != nil {
+		return nil, err
+	}
+
+	settings := cfg.VPCSettings
+	if settings == nil {
+		logger.Info("no VPC settings configured, skipping networking")
+		return nil, nil
+	}
+
+	vpc, err := ec2.NewVpc(ctx, "landing-zone-vpc", &ec2.VpcArgs{
+		CidrBlock:          pulumi.String(settings.CIDR),
+		EnableDnsHostnames: pulumi.Bool(settings.EnableDNSHostnames),
+		EnableDnsSupport:   pulumi.Bool(settings.EnableDNSSupport),
+		Tags:               pulumi.ToStringMap(cfg.Tags),
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create VPC: %w", err)
+	}
+
+	var subnetIDs pulumi.StringArray
+	subnets := make(map[string]*ec2.Subnet)
+	for _, spec := range settings.Subnets {
+		tags := make(map[string]string)
+		for key, value := range cfg.Tags {
+			tags[key] = value
+		}
+		for key, value := range spec.Tags {
+			tags[key] = value
+		}
+		tags["Name"] = spec.Name
+
+		subnet, err := ec2.NewSubnet(ctx, fmt.Sprintf("subnet-%s", spec.Name), &ec2.SubnetArgs{
+			VpcId:            vpc.ID(),
+			CidrBlock:        pulumi.String(spec.CIDR),
+			AvailabilityZone: pulumi.String(spec.AvailabilityZone),
+			Tags:             pulumi.ToStringMap(tags),
+		}, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create subnet %s: %w", spec.Name, err)
+		}
+
+		subnets[spec.Name] = subnet
+		subnetIDs = append(subnetIDs, subnet.ID())
+	}
+
+	if organizationArn != nil && len(subnets) > 0 {
+		if err := shareSubnets(ctx, cfg, subnets, organizationArn, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	metrics.IncrementCounter("networking_configured")
+	logger.Info("networking setup completed successfully", zap.Int("subnets", len(subnets)))
+	return &network{vpc: vpc, subnetIDs: subnetIDs}, nil
+}
+
+// shareSubnets shares the subnets with the organization through RAM
+func shareSubnets(ctx *pulumi.Context, cfg *config.LandingZoneConfig, subnets map[string]*ec2.Subnet, organizationArn pulumi.StringInput, opts []pulumi.ResourceOption) error {
+	share, err := ram.NewResourceShare(ctx, subnetShareName, &ram.ResourceShareArgs{
+		Name:                    pulumi.String(subnetShareName),
+		AllowExternalPrincipals: pulumi.Bool(false),
+		Tags:                    pulumi.ToStringMap(cfg.Tags),
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create subnet share: %w", err)
+	}
+
+	if _, err := ram.NewPrincipalAssociation(ctx, fmt.Sprintf("%s-organization", subnetShareName), &ram.PrincipalAssociationArgs{
+		Principal:        organizationArn,
+		ResourceShareArn: share.Arn,
+	}, opts...); err != nil {
+		return fmt.Errorf("failed to share subnets with the organization: %w", err)
+	}
+
+	for name, subnet := range subnets {
+		if _, err := ram.NewResourceAssociation(ctx, fmt.Sprintf("%s-%s", subnetShareName, name), &ram.ResourceAssociationArgs{
+			ResourceArn:      subnet.Arn,
+			ResourceShareArn: share.Arn,
+		}, opts...); err != nil {
+			return fmt.Errorf("failed to add subnet %s to share: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package observability provides the CloudWatch cross-account observability of the
+// organization, linking every account to a sink in the monitoring account.
+// Version: 1.0.0
+package observability
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/oam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/organizations"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// OutputSinkArns is the stack output holding the ARN of the sink of each region
+	OutputSinkArns = "observabilitySinkArns"
+
+	// Label of the source accounts in the monitoring account
+	labelTemplate = "$AccountName"
+
+	// Status of the accounts linked to the sink
+	accountStatusActive = "ACTIVE"
+)
+
+// Observability holds the state of the cross-account observability setup
+type Observability struct {
+	logger    *zap.Logger
+	cfg       *config.ObservabilityConfig
+	tags      map[string]string
+	names     config.ResourceNames
+	providers *awsclient.Providers
+}
+
+// SetupObservability creates the sink of the monitoring account in every observability
+// region, with a policy letting the accounts of the organization link to it, and links
+// every other active account to it. Accounts created by the same update are linked by
+// the next one. The Grafana and Prometheus workspaces go to the first region.
+func SetupObservability(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("observability")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("observability")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("observability_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup cross-account observability"); err != nil {
+		return err
+	}
+
+	if cfg.Observability == nil {
+		logger.Info("no cross-account observability configured")
+		return nil
+	}
+
+	org, err := organizations.LookupOrganization(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look up organization accounts: %w", err)
+	}
+
+	o := &Observability{
+		logger:    logger,
+		cfg:       cfg.Observability,
+		tags:      cfg.Tags,
+		names:     cfg.ResourceNames(),
+		providers: awsclient.NewProviders("observability", org.MasterAccountId, awsclient.MemberRoleName(cfg)),
+	}
+
+	sinkArns := pulumi.StringMap{}
+	linked := 0
+	for i, region := range cfg.ObservabilityRegions() {
+		sink, policy, err := o.createSink(ctx, org.Id, region)
+		if err != nil {
+			return err
+		}
+		sinkArns[region] = sink.Arn
+
+		if i == 0 {
+			if err := o.setupWorkspaces(ctx, region, sink); err != nil {
+				return err
+			}
+		}
+
+		for _, account := range org.Accounts {
+			if account.Status != accountStatusActive || account.Id == o.cfg.MonitoringAccountId || o.cfg.Excluded(account.Id) {
+				continue
+			}
+			if err := o.link(ctx, account.Id, region, sink, policy); err != nil {
+				return err
+			}
+			linked++
+		}
+	}
+	metrics.SetGauge("observability_links", float64(linked))
+	ctx.Export(OutputSinkArns, sinkArns)
+
+	logger.Info("cross-account observability setup completed successfully",
+		zap.String("monitoringAccount", o.cfg.MonitoringAccountId),
+		zap.Strings("regions", cfg.ObservabilityRegions()))
+	return nil
+}
+
+// createSink creates the sink of a region in the monitoring account and its policy
+func (o *Observability) createSink(ctx *pulumi.Context, orgId, region string) (*oam.Sink, *oam.SinkPolicy, error) {
+	provider, err := o.providers.Get(ctx, o.cfg.MonitoringAccountId, region)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sink, err := oam.NewSink(ctx, fmt.Sprintf("observability-sink-%s", region), &oam.SinkArgs{
+		Name: pulumi.String(o.cfg.Name()),
+		Tags: pulumi.ToStringMap(o.tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create observability sink in %s: %w", region, err)
+	}
+
+	document, err := sinkPolicy(orgId, o.cfg.Types())
+	if err != nil {
+		return nil, nil, err
+	}
+	policy, err := oam.NewSinkPolicy(ctx, fmt.Sprintf("observability-sink-%s", region), &oam.SinkPolicyArgs{
+		SinkIdentifier: sink.Arn,
+		Policy:         pulumi.String(document),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create observability sink policy in %s: %w", region, err)
+	}
+	return sink, policy, nil
+}
+
+// link links an account to the sink of a region
+func (o *Observability) link(ctx *pulumi.Context, accountId, region string, sink *oam.Sink, policy *oam.SinkPolicy) error {
+	provider, err := o.providers.Get(ctx, accountId, region)
+	if err != nil {
+		return err
+	}
+
+	_, err = oam.NewLink(ctx, fmt.Sprintf("observability-link-%s-%s", accountId, region), &oam.LinkArgs{
+		SinkIdentifier: sink.Arn,
+		LabelTemplate:  pulumi.String(labelTemplate),
+		ResourceTypes:  pulumi.ToStringArray(o.cfg.Types()),
+		Tags:           pulumi.ToStringMap(o.tags),
+	}, pulumi.Provider(provider), pulumi.DependsOn([]pulumi.Resource{policy}))
+	if err != nil {
+		return fmt.Errorf("failed to link %s to the observability sink in %s: %w", accountId, region, err)
+	}
+	return nil
+}
+
+// sinkPolicy returns the policy letting the accounts of the organization share the
+// given telemetry with the sink
+func sinkPolicy(orgId string, resourceTypes []string) (string, error) {
+	document, err := awsclient.PolicyDocument([]map[string]interface{}{{
+		"Effect":    "Allow",
+		"Principal": "*",
+		"Action":    []string{"oam:CreateLink", "oam:UpdateLink"},
+		"Resource":  "*",
+		"Condition": map[string]interface{}{
+			"StringEquals": map[string]string{
+				"aws:PrincipalOrgID": orgId,
+			},
+			"ForAllValues:StringEquals": map[string][]string{
+				"oam:ResourceTypes": resourceTypes,
+			},
+		},
+	}})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal observability sink policy: %w", err)
+	}
+	return document, nil
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package observability
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/amp"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/grafana"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// Stack outputs of the workspaces
+	OutputGrafanaEndpoint = "grafanaWorkspaceEndpoint"
+	OutputPrometheusWrite = "prometheusRemoteWriteUrl"
+	OutputPrometheusQuery = "prometheusQueryUrl"
+
+	// Paths of the Prometheus endpoint
+	prometheusWritePath = "api/v1/remote_write"
+	prometheusQueryPath = "api/v1/query"
+
+	// The Grafana workspace reads the monitoring account with a role of its own
+	grafanaAccountAccess  = "CURRENT_ACCOUNT"
+	grafanaPermissionType = "CUSTOMER_MANAGED"
+
+	// Data sources of the Grafana workspace
+	dataSourceCloudWatch = "CLOUDWATCH"
+	dataSourcePrometheus = "PROMETHEUS"
+	dataSourceXRay       = "XRAY"
+)
+
+// grafanaPolicies are the managed policies letting the Grafana workspace read its data
+// sources. In the monitoring account, CloudWatch and X-Ray include the telemetry of
+// the linked accounts.
+var grafanaPolicies = map[string]string{
+	dataSourceCloudWatch: "service-role/AmazonGrafanaCloudWatchAccess",
+	dataSourcePrometheus: "AmazonPrometheusQueryAccess",
+	dataSourceXRay:       "AWSXrayReadOnlyAccess",
+}
+
+// setupWorkspaces creates the Prometheus and Grafana workspaces in the monitoring
+// account, in the region of the given sink
+func (o *Observability) setupWorkspaces(ctx *pulumi.Context, region string, sink pulumi.Resource) error {
+	if o.cfg.Grafana == nil && o.cfg.Prometheus == nil {
+		return nil
+	}
+
+	provider, err := o.providers.Get(ctx, o.cfg.MonitoringAccountId, region)
+	if err != nil {
+		return err
+	}
+	opts := []pulumi.ResourceOption{pulumi.Provider(provider), pulumi.DependsOn([]pulumi.Resource{sink})}
+
+	if p := o.cfg.Prometheus; p != nil {
+		workspace, err := amp.NewWorkspace(ctx, "observability-prometheus", &amp.WorkspaceArgs{
+			Alias: pulumi.String(p.Name()),
+			Tags:  pulumi.ToStringMap(o.tags),
+		}, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to create Prometheus workspace: %w", err)
+		}
+		ctx.Export(OutputPrometheusWrite, pulumi.Sprintf("%s%s", workspace.PrometheusEndpoint, prometheusWritePath))
+		ctx.Export(OutputPrometheusQuery, pulumi.Sprintf("%s%s", workspace.PrometheusEndpoint, prometheusQueryPath))
+	}
+
+	g := o.cfg.Grafana
+	if g == nil {
+		return nil
+	}
+
+	role, err := iam.NewRole(ctx, "observability-grafana", &iam.RoleArgs{
+		Name:        component.Name(o.names.Name(config.RoleGrafanaWorkspace, region)),
+		Description: pulumi.String("Reads the data sources of the landing zone Grafana workspace"),
+		AssumeRolePolicy: pulumi.String(fmt.Sprintf(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Allow",
+				"Principal": {
+					"Service": "%s"
+				},
+				"Action": "sts:AssumeRole",
+				"Condition": {
+					"StringEquals": {
+						"aws:SourceAccount": "%s"
+					}
+				}
+			}]
+		}`, awsclient.ServicePrincipal("grafana"), o.cfg.MonitoringAccountId)),
+		Tags: pulumi.ToStringMap(o.tags),
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create Grafana workspace role: %w", err)
+	}
+
+	dataSources := []string{dataSourceCloudWatch, dataSourceXRay}
+	if o.cfg.Prometheus != nil {
+		dataSources = append(dataSources, dataSourcePrometheus)
+	}
+	for _, source := range dataSources {
+		name := fmt.Sprintf("observability-grafana-%s", strings.ToLower(source))
+		_, err := iam.NewRolePolicyAttachment(ctx, name, &iam.RolePolicyAttachmentArgs{
+			Role:      role.Name,
+			PolicyArn: pulumi.String(awsclient.PolicyArn(grafanaPolicies[source])),
+		}, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to grant %s access to Grafana workspace: %w", source, err)
+		}
+	}
+
+	args := &grafana.WorkspaceArgs{
+		Name:                    pulumi.String(g.Name()),
+		Description:             pulumi.String("Landing zone observability"),
+		AccountAccessType:       pulumi.String(grafanaAccountAccess),
+		PermissionType:          pulumi.String(grafanaPermissionType),
+		AuthenticationProviders: pulumi.ToStringArray(g.Providers()),
+		DataSources:             pulumi.ToStringArray(dataSources),
+		RoleArn:                 role.Arn,
+		Tags:                    pulumi.ToStringMap(o.tags),
+	}
+	if g.GrafanaVersion != "" {
+		args.GrafanaVersion = pulumi.String(g.GrafanaVersion)
+	}
+	workspace, err := grafana.NewWorkspace(ctx, "observability-grafana", args, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create Grafana workspace: %w", err)
+	}
+	ctx.Export(OutputGrafanaEndpoint, pulumi.Sprintf("https://%s", workspace.Endpoint))
+	return nil
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package optimization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/computeoptimizer"
+	cotypes "github.com/aws/aws-sdk-go-v2/service/computeoptimizer/types"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/trustedadvisor"
+	tatypes "github.com/aws/aws-sdk-go-v2/service/trustedadvisor/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// Check names of the advisor findings in the report
+	CheckComputeOptimizer = "compute-optimizer"
+	CheckTrustedAdvisor   = "trusted-advisor"
+)
+
+// Collector summarizes the findings of the advisors the organization is enrolled in
+// for the compliance report
+type Collector struct {
+	logger       *zap.Logger
+	metrics      *metrics.Collector
+	cfg          *config.OptimizationConfig
+	base         aws.Config
+	cache        *orgcache.Cache
+	managementId string
+}
+
+// NewCollector creates a collector reading the advisors from the management account
+func NewCollector(ctx context.Context, cfg *config.LandingZoneConfig, cache *orgcache.Cache) (*Collector, error) {
+	logger, err := logging.NewLogger("optimization-findings")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("optimization-findings")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HomeRegion != "" {
+		base.Region = cfg.HomeRegion
+	}
+
+	return &Collector{
+		logger:       logger,
+		metrics:      metrics,
+		cfg:          cfg.Optimization,
+		base:         base,
+		cache:        cache,
+		managementId: cfg.ManagementAccountId,
+	}, nil
+}
+
+// Collect adds a summary of the Compute Optimizer recommendations of every active
+// account and the Trusted Advisor checks in warning or error to the report
+func (c *Collector) Collect(ctx context.Context, r *report.Report) error {
+	if c.cfg == nil {
+		return nil
+	}
+
+	if c.cfg.ComputeOptimizer {
+		if err := c.collectComputeOptimizer(ctx, r); err != nil {
+			return err
+		}
+	}
+
+	if c.cfg.TrustedAdvisor {
+		supported, err := SupportsTrustedAdvisor(ctx, c.base)
+		if err != nil {
+			return err
+		}
+		if !supported {
+			c.logger.Warn("trusted advisor not available with the support plan, skipping its findings")
+			return nil
+		}
+		if err := c.collectTrustedAdvisor(ctx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectComputeOptimizer reports the resources of each account Compute Optimizer
+// finds over-provisioned, under-provisioned or not optimized
+func (c *Collector) collectComputeOptimizer(ctx context.Context, r *report.Report) error {
+	accounts, err := c.cache.Accounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	client := computeoptimizer.NewFromConfig(c.base)
+	for _, account := range accounts {
+		if account.Status != orgtypes.AccountStatusActive {
+			continue
+		}
+		accountId := aws.ToString(account.Id)
+
+		// Summaries are read one account at a time, the API accepts a single account ID
+		paginator := computeoptimizer.NewGetRecommendationSummariesPaginator(client,
+			&computeoptimizer.GetRecommendationSummariesInput{AccountIds: []string{accountId}})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			var optIn *cotypes.OptInRequiredException
+			if errors.As(err, &optIn) {
+				r.Add(report.Finding{
+					AccountID: c.managementId,
+					Check:     CheckComputeOptimizer,
+					Severity:  report.SeverityMedium,
+					Resource:  "enrollment",
+					Message:   "the organization is not enrolled in compute optimizer, no recommendation was read",
+				})
+				c.logger.Warn("compute optimizer not enrolled, skipping its findings")
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read compute optimizer summaries of %s: %w", accountId, err)
+			}
+			for _, summary := range page.RecommendationSummaries {
+				if finding, ok := computeOptimizerFinding(accountId, summary); ok {
+					r.Add(finding)
+					c.metrics.IncrementCounter("compute_optimizer_findings")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// collectTrustedAdvisor reports the organization recommendations in warning or error
+// against the management account
+func (c *Collector) collectTrustedAdvisor(ctx context.Context, r *report.Report) error {
+	client := trustedadvisor.NewFromConfig(c.base)
+
+	for _, status := range []tatypes.RecommendationStatus{tatypes.RecommendationStatusError, tatypes.RecommendationStatusWarning} {
+		paginator := trustedadvisor.NewListOrganizationRecommendationsPaginator(client,
+			&trustedadvisor.ListOrganizationRecommendationsInput{Status: status})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list trusted advisor recommendations: %w", err)
+			}
+			for _, recommendation := range page.OrganizationRecommendationSummaries {
+				r.Add(c.trustedAdvisorFinding(recommendation))
+				c.metrics.IncrementCounter("trusted_advisor_findings")
+			}
+		}
+	}
+	return nil
+}
+
+// computeOptimizerFinding summarizes the recommendations of one resource type
+func computeOptimizerFinding(accountId string, summary cotypes.RecommendationSummary) (report.Finding, bool) {
+	counts := make(map[cotypes.Finding]int)
+	for _, s := range summary.Summaries {
+		counts[s.Name] += int(s.Value)
+	}
+
+	var parts []string
+	for _, f := range []struct {
+		finding cotypes.Finding
+		label   string
+	}{
+		{cotypes.FindingOverProvisioned, "over-provisioned"},
+		{cotypes.FindingUnderProvisioned, "under-provisioned"},
+		{cotypes.FindingNotOptimized, "not optimized"},
+	} {
+		if counts[f.finding] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[f.finding], f.label))
+		}
+	}
+	if len(parts) == 0 {
+		return report.Finding{}, false
+	}
+
+	message := strings.Join(parts, ", ")
+	if savings := summary.SavingsOpportunity; savings != nil && savings.EstimatedMonthlySavings != nil &&
+		savings.EstimatedMonthlySavings.Value > 0 {
+		message += fmt.Sprintf("; estimated savings of %.2f %s per month",
+			savings.EstimatedMonthlySavings.Value, savings.EstimatedMonthlySavings.Currency)
+	}
+
+	// Under-provisioned resources are a performance risk, the others a cost
+	severity := report.SeverityLow
+	if counts[cotypes.FindingUnderProvisioned] > 0 {
+		severity = report.SeverityMedium
+	}
+
+	return report.Finding{
+		AccountID: accountId,
+		Check:     CheckComputeOptimizer,
+		Severity:  severity,
+		Resource:  string(summary.RecommendationResourceType),
+		Message:   message,
+	}, true
+}
+
+// trustedAdvisorFinding converts an organization recommendation into a finding
+func (c *Collector) trustedAdvisorFinding(recommendation tatypes.OrganizationRecommendationSummary) report.Finding {
+	severity := report.SeverityMedium
+	if recommendation.Status == tatypes.RecommendationStatusError {
+		severity = report.SeverityHigh
+	}
+
+	message := aws.ToString(recommendation.Name)
+	if agg := recommendation.ResourcesAggregates; agg != nil {
+		message += fmt.Sprintf(" (%d resources in error, %d in warning)",
+			aws.ToInt64(agg.ErrorCount), aws.ToInt64(agg.WarningCount))
+	}
+
+	return report.Finding{
+		AccountID: c.managementId,
+		Check:     CheckTrustedAdvisor,
+		Severity:  severity,
+		Resource:  aws.ToString(recommendation.Id),
+		Message:   message,
+	}
+}
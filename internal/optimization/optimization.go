@@ -0,0 +1,184 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package optimization provides the organization-wide enrollment of Compute Optimizer and Trusted Advisor.
+// Version: 1.0.0
+package optimization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/computeoptimizer"
+	cotypes "github.com/aws/aws-sdk-go-v2/service/computeoptimizer/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/trustedadvisor"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+// Service of the Trusted Advisor organizational view
+const trustedAdvisorService = "reporting.trustedadvisor"
+
+// Optimization enrolls the organization in the advisors of the management account
+type Optimization struct {
+	logger  *zap.Logger
+	metrics *metrics.Collector
+	base    aws.Config
+}
+
+// SetupOptimization enrolls the management account and every member account in
+// Compute Optimizer and enables the Trusted Advisor organizational view. Neither has
+// a Pulumi resource, so both are enabled through the SDK outside previews. Trusted
+// Advisor is skipped with a warning when the support plan does not include its API.
+func SetupOptimization(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("optimization")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("optimization")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("optimization_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup optimization"); err != nil {
+		return err
+	}
+
+	if cfg.Optimization == nil {
+		logger.Info("no optimization configured")
+		return nil
+	}
+	if ctx.DryRun() {
+		return nil
+	}
+
+	base, err := awsclient.Load(ctx.Context())
+	if err != nil {
+		return err
+	}
+	if cfg.HomeRegion != "" {
+		base.Region = cfg.HomeRegion
+	}
+
+	o := &Optimization{
+		logger:  logger,
+		metrics: metrics,
+		base:    base,
+	}
+
+	if cfg.Optimization.ComputeOptimizer {
+		if err := o.enrollComputeOptimizer(ctx.Context()); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Optimization.TrustedAdvisor {
+		if err := o.enableTrustedAdvisor(ctx.Context()); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("optimization setup completed successfully",
+		zap.Bool("computeOptimizer", cfg.Optimization.ComputeOptimizer),
+		zap.Bool("trustedAdvisor", cfg.Optimization.TrustedAdvisor))
+	return nil
+}
+
+// enrollComputeOptimizer opts the organization in unless every member account already
+// is. Including the member accounts enables the trusted access of Compute Optimizer.
+func (o *Optimization) enrollComputeOptimizer(ctx context.Context) error {
+	client := computeoptimizer.NewFromConfig(o.base)
+
+	status, err := client.GetEnrollmentStatus(ctx, &computeoptimizer.GetEnrollmentStatusInput{})
+	if err != nil {
+		return fmt.Errorf("failed to read compute optimizer enrollment status: %w", err)
+	}
+	if status.Status == cotypes.StatusActive && status.MemberAccountsEnrolled {
+		return nil
+	}
+
+	if _, err := client.UpdateEnrollmentStatus(ctx, &computeoptimizer.UpdateEnrollmentStatusInput{
+		Status:                cotypes.StatusActive,
+		IncludeMemberAccounts: true,
+	}); err != nil {
+		return fmt.Errorf("failed to enroll organization in compute optimizer: %w", err)
+	}
+
+	o.metrics.IncrementCounter("compute_optimizer_enrolled")
+	o.logger.Info("organization enrolled in compute optimizer")
+	return nil
+}
+
+// enableTrustedAdvisor enables the trusted access of the Trusted Advisor organizational
+// view when the support plan of the management account supports it
+func (o *Optimization) enableTrustedAdvisor(ctx context.Context) error {
+	supported, err := SupportsTrustedAdvisor(ctx, o.base)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		o.logger.Warn("trusted advisor organizational view requires a Business, Enterprise On-Ramp " +
+			"or Enterprise support plan, skipping")
+		return nil
+	}
+
+	principal := awsclient.ServicePrincipal(trustedAdvisorService)
+	client := organizations.NewFromConfig(o.base)
+
+	paginator := organizations.NewListAWSServiceAccessForOrganizationPaginator(client,
+		&organizations.ListAWSServiceAccessForOrganizationInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list trusted services: %w", err)
+		}
+		for _, service := range page.EnabledServicePrincipals {
+			if aws.ToString(service.ServicePrincipal) == principal {
+				return nil
+			}
+		}
+	}
+
+	if _, err := client.EnableAWSServiceAccess(ctx, &organizations.EnableAWSServiceAccessInput{
+		ServicePrincipal: aws.String(principal),
+	}); err != nil {
+		return fmt.Errorf("failed to enable trusted advisor organizational view: %w", err)
+	}
+
+	o.metrics.IncrementCounter("trusted_advisor_enabled")
+	o.logger.Info("trusted advisor organizational view enabled")
+	return nil
+}
+
+// SupportsTrustedAdvisor reports whether the support plan of the account of the
+// credentials gives access to the Trusted Advisor API. Plans without it are denied.
+func SupportsTrustedAdvisor(ctx context.Context, base aws.Config) (bool, error) {
+	client := trustedadvisor.NewFromConfig(base)
+
+	_, err := client.ListRecommendations(ctx, &trustedadvisor.ListRecommendationsInput{
+		MaxResults: aws.Int32(1),
+	})
+	switch {
+	case errors.Is(err, awsclient.ErrAccessDenied):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to read trusted advisor recommendations: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package organization
+
+import (
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/quarantine"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// OrganizationComponentType is the type token of the organization component
+var OrganizationComponentType = component.Type("organization", "Organization")
+
+// OrganizationComponentArgs defines the inputs of the organization component
+type OrganizationComponentArgs struct {
+	// Config describes the organization and its OUs
+	Config *config.OrganizationConfig
+}
+
+// OrganizationComponent groups the organization, its OUs and policies under a single
+// component resource
+type OrganizationComponent struct {
+	pulumi.ResourceState
+
+	OrganizationId        pulumi.StringOutput    `pulumi:"organizationId"`
+	OrganizationArn       pulumi.StringOutput    `pulumi:"organizationArn"`
+	RootId                pulumi.StringOutput    `pulumi:"rootId"`
+	SecurityOUId          pulumi.StringOutput    `pulumi:"securityOuId"`
+	DefaultOUId           pulumi.StringOutput    `pulumi:"defaultOuId"`
+	OrganizationalUnitIds pulumi.StringMapOutput `pulumi:"organizationalUnitIds"`
+
+	org *Organization
+}
+
+// NewOrganizationComponent creates the organization as a component resource
+func NewOrganizationComponent(ctx *pulumi.Context, name string, args *OrganizationComponentArgs, opts ...pulumi.ResourceOption) (*OrganizationComponent, error) {
+	if args == nil {
+		return nil, fmt.Errorf("organization component arguments cannot be nil")
+	}
+
+	c := &OrganizationComponent{}
+	if err := ctx.RegisterComponentResource(OrganizationComponentType, name, c, opts...); err != nil {
+		return nil, fmt.Errorf("failed to register organization component: %w", err)
+	}
+
+	org, err := NewOrganization(ctx, args.Config, component.ChildOptions(c)...)
+	if err != nil {
+		return nil, err
+	}
+
+	lz := args.Config.LandingZoneConfig
+	ous := pulumi.StringMap{
+		securityOUName:   org.securityOU.ID(),
+		lz.DefaultOUName: org.defaultOU.ID(),
+	}
+	for name, ou := range org.additionalOUs {
+		ous[name] = ou.ID()
+	}
+	if org.quarantineOU != nil {
+		ous[quarantine.OUName(lz.Quarantine)] = org.quarantineOU.ID()
+	}
+
+	c.org = org
+	c.OrganizationId = org.org.ID().ToStringOutput()
+	c.OrganizationArn = org.org.Arn
+	c.RootId = org.rootId
+	c.SecurityOUId = org.securityOU.ID().ToStringOutput()
+	c.DefaultOUId = org.defaultOU.ID().ToStringOutput()
+	c.OrganizationalUnitIds = ous.ToStringMapOutput()
+
+	if err := ctx.RegisterResourceOutputs(c, pulumi.Map{
+		"organizationId":        c.OrganizationId,
+		"organizationArn":       c.OrganizationArn,
+		"rootId":                c.RootId,
+		"securityOuId":          c.SecurityOUId,
+		"defaultOuId":           c.DefaultOUId,
+		"organizationalUnitIds": c.OrganizationalUnitIds,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to register organization outputs: %w", err)
+	}
+
+	return c, nil
+}
+
+// Organization returns the organization managed by the component
+func (c *OrganizationComponent) Organization() *Organization {
+	return c.org
+}
@@ -12,8 +12,16 @@ import (
 	"sync"
 	"time"
 
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/adopt"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/policies"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/quarantine"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/stacks"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/organizations"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"go.uber.org/zap"
@@ -45,7 +53,11 @@ type Organization struct {
 	securityOU    *organizations.OrganizationalUnit
 	defaultOU     *organizations.OrganizationalUnit
 	additionalOUs map[string]*organizations.OrganizationalUnit
+	quarantineOU  *organizations.OrganizationalUnit
 	rootId        pulumi.StringOutput
+	opts          []pulumi.ResourceOption
+	adopter       *adopt.Adopter
+	tags          map[string]string
 	cleanup       []func() error
 }
 
@@ -54,6 +66,9 @@ const (
 	ssmOrgInfoPath   = "/organization/info"
 	ssmOUInfoPathFmt = "/organization/ou/%s"
 
+	// Name of the OU holding the security accounts
+	securityOUName = "Security"
+
 	// Feature sets and policy types
 	featureSetAll = "ALL"
 	policyTypeSCP = "SERVICE_CONTROL_POLICY"
@@ -69,11 +84,12 @@ const (
 	rateBurst = 20
 )
 
-// NewOrganization creates a new AWS Organization with the specified configuration
-func NewOrganization(ctx *pulumi.Context, cfg *config.OrganizationConfig) (*Organization, error) {
-	logger, err := zap.NewProduction()
+// NewOrganization creates a new AWS Organization with the specified configuration. The
+// options are applied to every resource of the organization.
+func NewOrganization(ctx *pulumi.Context, cfg *config.OrganizationConfig, opts ...pulumi.ResourceOption) (*Organization, error) {
+	logger, err := logging.NewLogger("organization")
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+		return nil, err
 	}
 
 	metrics, err := metrics.NewCollector("organization")
@@ -86,6 +102,7 @@ func NewOrganization(ctx *pulumi.Context, cfg *config.OrganizationConfig) (*Orga
 		metrics:       metrics,
 		limiter:       rate.NewLimiter(rate.Limit(rateLimit), rateBurst),
 		additionalOUs: make(map[string]*organizations.OrganizationalUnit),
+		opts:          opts,
 	}
 
 	if err := org.initialize(ctx, cfg); err != nil {
@@ -104,10 +121,22 @@ func (o *Organization) initialize(ctx *pulumi.Context, cfg *config.OrganizationC
 
 	o.logger.Info("initializing organization")
 
+	if err := readonly.Guard(ctx, "initialize organization"); err != nil {
+		return err
+	}
+
 	if err := o.validateConfig(cfg); err != nil {
 		return err
 	}
 
+	// OUs left behind by a partially failed run are adopted instead of failing the run
+	adopter, err := adopt.New(ctx.Context(), cfg.LandingZoneConfig)
+	if err != nil {
+		return err
+	}
+	o.adopter = adopter
+	o.tags = cfg.LandingZoneConfig.Tags
+
 	if err := o.createOrganization(ctx, cfg); err != nil {
 		return err
 	}
@@ -141,7 +170,7 @@ func (o *Organization) createOrganization(ctx *pulumi.Context, cfg *config.Organ
 			pulumi.String(policyTypeSCP),
 			pulumi.String(policyTypeTag),
 		},
-	})
+	}, o.opts...)
 	if err != nil {
 		o.logger.Error("failed to create organization", zap.Error(err))
 		return fmt.Errorf("failed to create organization: %w", err)
@@ -161,7 +190,7 @@ func (o *Organization) createOUs(ctx *pulumi.Context, cfg *config.OrganizationCo
 	var err error
 
 	// Create Security OU
-	o.securityOU, err = o.createOU(ctx, "Security", o.rootId, pulumi.ToStringMap(cfg.LandingZoneConfig.Tags))
+	o.securityOU, err = o.createOU(ctx, securityOUName, o.rootId, pulumi.ToStringMap(cfg.LandingZoneConfig.Tags))
 	if err != nil {
 		return err
 	}
@@ -183,6 +212,50 @@ func (o *Organization) createOUs(ctx *pulumi.Context, cfg *config.OrganizationCo
 		}
 	}
 
+	// Create Quarantine OU if the conformance workflow is enabled
+	if q := cfg.LandingZoneConfig.Quarantine; q != nil && q.Enabled {
+		if err := o.createQuarantineOU(ctx, q, pulumi.ToStringMap(cfg.LandingZoneConfig.Tags)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createQuarantineOU creates the Quarantine OU and attaches the restrictive SCP to it
+func (o *Organization) createQuarantineOU(ctx *pulumi.Context, cfg *config.QuarantineConfig, tags pulumi.StringMap) error {
+	name := quarantine.OUName(cfg)
+
+	ou, err := o.createOU(ctx, name, o.rootId, tags)
+	if err != nil {
+		return err
+	}
+
+	document, err := quarantine.PolicyDocument()
+	if err != nil {
+		return err
+	}
+
+	policy, err := organizations.NewPolicy(ctx, "quarantine-scp", &organizations.PolicyArgs{
+		Name:        pulumi.String(fmt.Sprintf("%s-scp", name)),
+		Description: pulumi.String(quarantine.PolicyDescription),
+		Type:        pulumi.String(policyTypeSCP),
+		Content:     pulumi.String(document),
+		Tags:        tags,
+	}, component.Options(o.opts, pulumi.DependsOn([]pulumi.Resource{o.org}))...)
+	if err != nil {
+		return fmt.Errorf("failed to create quarantine policy: %w", err)
+	}
+
+	if _, err := organizations.NewPolicyAttachment(ctx, "quarantine-scp-attachment", &organizations.PolicyAttachmentArgs{
+		PolicyId: policy.ID(),
+		TargetId: ou.ID(),
+	}, o.opts...); err != nil {
+		return fmt.Errorf("failed to attach quarantine policy: %w", err)
+	}
+
+	o.quarantineOU = ou
+	o.logger.Info("quarantine OU created successfully", zap.String("name", name))
 	return nil
 }
 
@@ -192,6 +265,9 @@ func (o *Organization) createOU(ctx *pulumi.Context, name string, parentId pulum
 		return nil, fmt.Errorf("rate limit exceeded: %w", err)
 	}
 
+	// OUs are created below the root, where an existing OU of the same name is looked up
+	adoption := o.adopter.OU(ctx.Context(), name, o.tags)
+
 	var ou *organizations.OrganizationalUnit
 	operation := func() error {
 		var err error
@@ -199,7 +275,7 @@ func (o *Organization) createOU(ctx *pulumi.Context, name string, parentId pulum
 			Name:     pulumi.String(name),
 			ParentId: parentId,
 			Tags:     tags,
-		})
+		}, component.Options(o.opts, adoption...)...)
 		return err
 	}
 
@@ -217,6 +293,33 @@ func (o *Organization) createOU(ctx *pulumi.Context, name string, parentId pulum
 	return ou, nil
 }
 
+// PolicyTargets returns the root and the OUs created by the organization, keyed by name
+func (o *Organization) PolicyTargets(lz *config.LandingZoneConfig) policies.Targets {
+	ous := map[string]pulumi.StringInput{
+		securityOUName:   o.securityOU.ID(),
+		lz.DefaultOUName: o.defaultOU.ID(),
+	}
+	for name, ou := range o.additionalOUs {
+		ous[name] = ou.ID()
+	}
+	if o.quarantineOU != nil {
+		ous[quarantine.OUName(lz.Quarantine)] = o.quarantineOU.ID()
+	}
+	return policies.Targets{RootID: o.rootId, OUs: ous}
+}
+
+// Arn returns the ARN of the organization
+func (o *Organization) Arn() pulumi.StringOutput {
+	return o.org.Arn
+}
+
+// Export exports the identifiers other stacks reference
+func (o *Organization) Export(ctx *pulumi.Context) {
+	ctx.Export(stacks.OutputOrganizationID, o.org.ID())
+	ctx.Export(stacks.OutputOrganizationArn, o.org.Arn)
+	ctx.Export(stacks.OutputRootID, o.rootId)
+}
+
 // Backup creates a backup of the organization state
 func (o *Organization) Backup(ctx context.Context) error {
 	o.backupMutex.Lock()
@@ -242,6 +345,10 @@ func RetryWithBackoff(operation func() error, config RetryConfig) error {
 		if err := operation(); err == nil {
 			return nil
 		} else {
+			// Retrying cannot fix missing permissions, existing resources or quotas
+			if awsclient.Permanent(err) {
+				return err
+			}
 			lastErr = err
 			if attempt < config.MaxAttempts {
 				delay := time.Duration(float64(config.Delay) * float64(attempt))
@@ -0,0 +1,319 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package orgcache provides a read-through cache of the organization listings.
+// Version: 1.0.0
+package orgcache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"go.uber.org/zap"
+)
+
+// RecordName is the name of the state record holding the cached listings
+const RecordName = "organization-cache"
+
+// Kind identifies a cached listing
+type Kind string
+
+const (
+	KindAccounts Kind = "accounts"
+	KindOUs      Kind = "ous"
+	KindPolicies Kind = "policies"
+)
+
+// Store persists the cached listings between runs. It is implemented by the state
+// manager.
+type Store interface {
+	SaveRecord(ctx context.Context, name string, record interface{}) error
+	LoadRecord(ctx context.Context, name string, record interface{}) (bool, error)
+}
+
+// OU is an organizational unit with the ID of its parent
+type OU struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	ParentID string `json:"parentId"`
+}
+
+// Record holds the cached listings and the time each was read
+type Record struct {
+	Accounts   []orgtypes.Account                  `json:"accounts,omitempty"`
+	AccountsAt time.Time                           `json:"accountsAt,omitempty"`
+	RootID     string                              `json:"rootId,omitempty"`
+	OUs        []OU                                `json:"ous,omitempty"`
+	OUsAt      time.Time                           `json:"ousAt,omitempty"`
+	Policies   map[string][]orgtypes.PolicySummary `json:"policies,omitempty"`
+	PoliciesAt map[string]time.Time                `json:"policiesAt,omitempty"`
+}
+
+// Cache serves the accounts, OUs and policies of the organization from the record
+// while they are younger than the TTL, and reads them from the Organizations API
+// otherwise. Callers mutating the organization invalidate the affected listings.
+type Cache struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	base      aws.Config
+	org       aws.Config
+	orgClient *organizations.Client
+	store     Store
+	ttl       time.Duration
+	disabled  bool
+
+	mutex  sync.Mutex
+	record Record
+	dirty  bool
+}
+
+// New creates a cache of the organization listings and loads the record of the store.
+// Without a store, or when its record cannot be read, the listings are cached for the
+// lifetime of the cache only.
+func New(ctx context.Context, cfg *config.LandingZoneConfig, store Store) (*Cache, error) {
+	logger, err := logging.NewLogger("orgcache")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("orgcache")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	org := awsclient.OrganizationConfig(base, cfg)
+	if cfg.OrganizationRoleArn != "" {
+		logger.Info("reading the organization with the organization role",
+			zap.String("role", cfg.OrganizationRoleArn))
+	}
+
+	c := &Cache{
+		logger:    logger,
+		metrics:   metrics,
+		base:      base,
+		org:       org,
+		orgClient: organizations.NewFromConfig(org),
+		store:     store,
+		ttl:       time.Duration(cfg.Cache.TTL()) * time.Minute,
+		disabled:  cfg.Cache != nil && cfg.Cache.Disabled,
+	}
+
+	if store != nil && !c.disabled {
+		// The cache only spares API calls, so an unreadable record is read again
+		if _, err := store.LoadRecord(ctx, RecordName, &c.record); err != nil {
+			logger.Warn("failed to load organization cache, listings are read from the API", zap.Error(err))
+			c.record = Record{}
+		}
+	}
+	return c, nil
+}
+
+// AWSConfig returns the SDK configuration of the caller, for the services other than
+// Organizations
+func (c *Cache) AWSConfig() aws.Config {
+	return c.base
+}
+
+// OrganizationConfig returns the SDK configuration the cache reads the organization
+// with, assuming OrganizationRoleArn when it is set
+func (c *Cache) OrganizationConfig() aws.Config {
+	return c.org
+}
+
+// Accounts returns the accounts of the organization sorted by ID
+func (c *Cache) Accounts(ctx context.Context) ([]orgtypes.Account, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.fresh(KindAccounts, c.record.AccountsAt) {
+		var accounts []orgtypes.Account
+		paginator := organizations.NewListAccountsPaginator(c.orgClient, &organizations.ListAccountsInput{})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list accounts: %w", err)
+			}
+			accounts = append(accounts, page.Accounts...)
+		}
+
+		sort.Slice(accounts, func(i, j int) bool {
+			return aws.ToString(accounts[i].Id) < aws.ToString(accounts[j].Id)
+		})
+		c.record.Accounts = accounts
+		c.record.AccountsAt = time.Now()
+		c.dirty = true
+	}
+
+	return append([]orgtypes.Account(nil), c.record.Accounts...), nil
+}
+
+// OUs returns every OU of the organization, parents before their children
+func (c *Cache) OUs(ctx context.Context) ([]OU, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.loadOUs(ctx); err != nil {
+		return nil, err
+	}
+	return append([]OU(nil), c.record.OUs...), nil
+}
+
+// RootID returns the ID of the root of the organization
+func (c *Cache) RootID(ctx context.Context) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.loadOUs(ctx); err != nil {
+		return "", err
+	}
+	return c.record.RootID, nil
+}
+
+// loadOUs walks the OU tree from the root unless the cached tree is fresh
+func (c *Cache) loadOUs(ctx context.Context) error {
+	if c.fresh(KindOUs, c.record.OUsAt) {
+		return nil
+	}
+
+	roots, err := c.orgClient.ListRoots(ctx, &organizations.ListRootsInput{})
+	if err != nil {
+		return fmt.Errorf("failed to list roots: %w", err)
+	}
+	if len(roots.Roots) == 0 {
+		return fmt.Errorf("organization has no root")
+	}
+	rootID := aws.ToString(roots.Roots[0].Id)
+
+	var ous []OU
+	parents := []string{rootID}
+	for len(parents) > 0 {
+		parent := parents[0]
+		parents = parents[1:]
+
+		paginator := organizations.NewListOrganizationalUnitsForParentPaginator(c.orgClient,
+			&organizations.ListOrganizationalUnitsForParentInput{ParentId: aws.String(parent)})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list organizational units: %w", err)
+			}
+			for _, ou := range page.OrganizationalUnits {
+				ous = append(ous, OU{
+					ID:       aws.ToString(ou.Id),
+					Name:     aws.ToString(ou.Name),
+					ParentID: parent,
+				})
+				parents = append(parents, aws.ToString(ou.Id))
+			}
+		}
+	}
+
+	c.record.RootID = rootID
+	c.record.OUs = ous
+	c.record.OUsAt = time.Now()
+	c.dirty = true
+	return nil
+}
+
+// Policies returns the policies of a type, such as SERVICE_CONTROL_POLICY, sorted by name
+func (c *Cache) Policies(ctx context.Context, policyType orgtypes.PolicyType) ([]orgtypes.PolicySummary, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := string(policyType)
+	if !c.fresh(KindPolicies, c.record.PoliciesAt[key]) {
+		var policies []orgtypes.PolicySummary
+		paginator := organizations.NewListPoliciesPaginator(c.orgClient,
+			&organizations.ListPoliciesInput{Filter: policyType})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s policies: %w", key, err)
+			}
+			policies = append(policies, page.Policies...)
+		}
+
+		sort.Slice(policies, func(i, j int) bool {
+			return aws.ToString(policies[i].Name) < aws.ToString(policies[j].Name)
+		})
+		if c.record.Policies == nil {
+			c.record.Policies = make(map[string][]orgtypes.PolicySummary)
+			c.record.PoliciesAt = make(map[string]time.Time)
+		}
+		c.record.Policies[key] = policies
+		c.record.PoliciesAt[key] = time.Now()
+		c.dirty = true
+	}
+
+	return append([]orgtypes.PolicySummary(nil), c.record.Policies[key]...), nil
+}
+
+// Invalidate drops the given listings, or every listing when none is given, so they
+// are read from the API on next use
+func (c *Cache) Invalidate(kinds ...Kind) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(kinds) == 0 {
+		kinds = []Kind{KindAccounts, KindOUs, KindPolicies}
+	}
+	for _, kind := range kinds {
+		switch kind {
+		case KindAccounts:
+			c.record.Accounts = nil
+			c.record.AccountsAt = time.Time{}
+		case KindOUs:
+			c.record.RootID = ""
+			c.record.OUs = nil
+			c.record.OUsAt = time.Time{}
+		case KindPolicies:
+			c.record.Policies = nil
+			c.record.PoliciesAt = nil
+		}
+		c.metrics.IncrementCounter(fmt.Sprintf("cache_%s_invalidated", kind))
+	}
+	c.dirty = true
+}
+
+// Save persists the listings read since the cache was created. Read-only runs keep
+// the stored record unchanged.
+func (c *Cache) Save(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.store == nil || c.disabled || !c.dirty || readonly.Enabled() {
+		return nil
+	}
+
+	if err := c.store.SaveRecord(ctx, RecordName, c.record); err != nil {
+		return fmt.Errorf("failed to save organization cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// fresh reports whether a listing read at the given time may be served from the cache
+func (c *Cache) fresh(kind Kind, readAt time.Time) bool {
+	if c.disabled || readAt.IsZero() || time.Since(readAt) > c.ttl {
+		c.metrics.IncrementCounter(fmt.Sprintf("cache_%s_misses", kind))
+		return false
+	}
+	c.metrics.IncrementCounter(fmt.Sprintf("cache_%s_hits", kind))
+	return true
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package orgtree provides the OU and account hierarchy of the organization and its rendering.
+// Version: 1.0.0
+package orgtree
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/invitations"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/quarantine"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ct "github.com/aws/aws-sdk-go-v2/service/controltower"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"go.uber.org/zap"
+)
+
+// NodeType is the kind of a node of the tree
+type NodeType string
+
+const (
+	NodeRoot    NodeType = "root"
+	NodeOU      NodeType = "ou"
+	NodeAccount NodeType = "account"
+)
+
+const (
+	// Name the root is shown under
+	rootName = "Root"
+
+	// OU holding the core accounts
+	securityOUName = "Security"
+)
+
+// Node is the root, an OU or an account with the SCPs attached to it and, for OUs, the
+// Control Tower controls enabled on it. Planned nodes carry no ID.
+type Node struct {
+	ID       string   `json:"id,omitempty"`
+	Name     string   `json:"name"`
+	Type     NodeType `json:"type"`
+	Policies []string `json:"policies,omitempty"`
+	Controls []string `json:"controls,omitempty"`
+	Children []*Node  `json:"children,omitempty"`
+}
+
+// Builder reads the hierarchy of the live organization
+type Builder struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	orgClient *organizations.Client
+	ctClient  *ct.Client
+	cache     *orgcache.Cache
+}
+
+// NewBuilder creates a builder walking the OU tree of the cache
+func NewBuilder(ctx context.Context, cache *orgcache.Cache) (*Builder, error) {
+	logger, err := logging.NewLogger("orgtree")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("orgtree")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Builder{
+		logger:    logger,
+		metrics:   metrics,
+		orgClient: organizations.NewFromConfig(base),
+		ctClient:  ct.NewFromConfig(base),
+		cache:     cache,
+	}, nil
+}
+
+// Live returns the current hierarchy of the organization. The OU tree is served from
+// the cache, while accounts, attached SCPs and enabled controls are read live.
+func (b *Builder) Live(ctx context.Context) (*Node, error) {
+	start := time.Now()
+	defer func() {
+		b.metrics.RecordDuration("tree_build", time.Since(start))
+	}()
+
+	org, err := b.orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe organization: %w", err)
+	}
+
+	rootID, err := b.cache.RootID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ous, err := b.cache.OUs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &Node{ID: rootID, Name: rootName, Type: NodeRoot}
+	nodes := map[string]*Node{rootID: root}
+	parents := []*Node{root}
+	for _, ou := range ous {
+		node := &Node{ID: ou.ID, Name: ou.Name, Type: NodeOU}
+		parent, ok := nodes[ou.ParentID]
+		if !ok {
+			return nil, fmt.Errorf("parent %s of OU %s not found", ou.ParentID, ou.ID)
+		}
+		parent.Children = append(parent.Children, node)
+		nodes[ou.ID] = node
+		parents = append(parents, node)
+	}
+
+	var accounts []*Node
+	for _, parent := range parents {
+		paginator := organizations.NewListAccountsForParentPaginator(b.orgClient,
+			&organizations.ListAccountsForParentInput{ParentId: aws.String(parent.ID)})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list accounts of %s: %w", parent.Name, err)
+			}
+			for _, account := range page.Accounts {
+				node := &Node{ID: aws.ToString(account.Id), Name: aws.ToString(account.Name), Type: NodeAccount}
+				parent.Children = append(parent.Children, node)
+				accounts = append(accounts, node)
+			}
+		}
+	}
+
+	for _, node := range append(parents, accounts...) {
+		if node.Policies, err = b.policies(ctx, node.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, node := range parents[1:] {
+		arn := fmt.Sprintf("arn:%s:organizations::%s:ou/%s/%s", awsclient.Partition(),
+			aws.ToString(org.Organization.MasterAccountId), aws.ToString(org.Organization.Id), node.ID)
+		if node.Controls, err = b.controls(ctx, arn); err != nil {
+			return nil, err
+		}
+	}
+
+	sortChildren(root)
+	b.metrics.IncrementCounter("trees_built")
+	b.logger.Info("organization tree built",
+		zap.Int("ous", len(ous)),
+		zap.Int("accounts", len(accounts)))
+	return root, nil
+}
+
+// policies returns the names of the SCPs attached to a target
+func (b *Builder) policies(ctx context.Context, targetID string) ([]string, error) {
+	var names []string
+
+	paginator := organizations.NewListPoliciesForTargetPaginator(b.orgClient, &organizations.ListPoliciesForTargetInput{
+		TargetId: aws.String(targetID),
+		Filter:   orgtypes.PolicyTypeServiceControlPolicy,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list policies of %s: %w", targetID, err)
+		}
+		for _, policy := range page.Policies {
+			names = append(names, aws.ToString(policy.Name))
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// controls returns the identifiers of the controls enabled on an OU, such as
+// AWS-GR_RESTRICT_ROOT_USER
+func (b *Builder) controls(ctx context.Context, ouArn string) ([]string, error) {
+	var identifiers []string
+
+	paginator := ct.NewListEnabledControlsPaginator(b.ctClient, &ct.ListEnabledControlsInput{
+		TargetIdentifier: aws.String(ouArn),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list enabled controls of %s: %w", ouArn, err)
+		}
+		for _, control := range page.EnabledControls {
+			identifier := aws.ToString(control.ControlIdentifier)
+			identifiers = append(identifiers, identifier[strings.LastIndex(identifier, "/")+1:])
+		}
+	}
+
+	sort.Strings(identifiers)
+	return identifiers, nil
+}
+
+// Planned returns the hierarchy the configuration deploys: the Security and default
+// OUs, the configured OUs with their accounts and invited accounts, and the Quarantine
+// OU with its SCP. The configuration does not assign guardrails per OU, so they are
+// shown on the root.
+func Planned(cfg *config.LandingZoneConfig) *Node {
+	root := &Node{Name: rootName, Type: NodeRoot, Controls: append([]string(nil), cfg.EnabledGuardrails...)}
+	ous := make(map[string]*Node)
+	ou := func(name string) *Node {
+		if node, ok := ous[name]; ok {
+			return node
+		}
+		node := &Node{Name: name, Type: NodeOU}
+		root.Children = append(root.Children, node)
+		ous[name] = node
+		return node
+	}
+
+	ou(securityOUName)
+	if cfg.DefaultOUName != "" {
+		ou(cfg.DefaultOUName)
+	}
+	for key, configured := range cfg.OrganizationUnits {
+		node := ou(invitations.OUName(cfg, key))
+		if configured == nil {
+			continue
+		}
+		for _, account := range configured.Accounts {
+			node.Children = append(node.Children, &Node{Name: account.Name, Type: NodeAccount})
+		}
+	}
+	for _, invitation := range cfg.Invitations {
+		name := invitation.AccountId
+		if name == "" {
+			name = invitation.Email
+		}
+		node := ou(invitations.OUName(cfg, invitation.TargetOU))
+		node.Children = append(node.Children, &Node{Name: name, Type: NodeAccount})
+	}
+	if q := cfg.Quarantine; q != nil && q.Enabled {
+		name := quarantine.OUName(q)
+		ou(name).Policies = []string{fmt.Sprintf("%s-scp", name)}
+	}
+
+	sortChildren(root)
+	return root
+}
+
+// sortChildren orders the children of every node: OUs before accounts, then by name
+func sortChildren(node *Node) {
+	sort.SliceStable(node.Children, func(i, j int) bool {
+		a, b := node.Children[i], node.Children[j]
+		if a.Type != b.Type {
+			return a.Type == NodeOU
+		}
+		return a.Name < b.Name
+	})
+	for _, child := range node.Children {
+		sortChildren(child)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package orgtree
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+)
+
+// Output formats of the tree besides text and JSON
+const (
+	FormatDOT     = "dot"
+	FormatMermaid = "mermaid"
+)
+
+// Write renders the tree as an ASCII tree (text), a Graphviz DOT graph, a Mermaid
+// flowchart or a JSON document
+func Write(w io.Writer, format string, root *Node) error {
+	switch format {
+	case report.FormatText:
+		fmt.Fprintln(w, label(root, " "))
+		writeText(w, root, "")
+		return nil
+	case FormatDOT:
+		return writeDOT(w, root)
+	case FormatMermaid:
+		return writeMermaid(w, root)
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(root); err != nil {
+			return fmt.Errorf("failed to encode organization tree: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// writeText writes the children of a node with ASCII branches
+func writeText(w io.Writer, node *Node, indent string) {
+	for i, child := range node.Children {
+		branch, next := "|-- ", "|   "
+		if i == len(node.Children)-1 {
+			branch, next = "`-- ", "    "
+		}
+		fmt.Fprintf(w, "%s%s%s\n", indent, branch, label(child, " "))
+		writeText(w, child, indent+next)
+	}
+}
+
+// writeDOT writes the tree as a Graphviz digraph. Accounts are drawn as ellipses.
+func writeDOT(w io.Writer, root *Node) error {
+	fmt.Fprintln(w, "digraph organization {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	walk(root, func(id string, node *Node, parentID string) {
+		shape := ""
+		if node.Type == NodeAccount {
+			shape = ", shape=ellipse"
+		}
+		fmt.Fprintf(w, "  %s [label=%q%s];\n", id, label(node, "\n"), shape)
+		if parentID != "" {
+			fmt.Fprintf(w, "  %s -> %s;\n", parentID, id)
+		}
+	})
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// writeMermaid writes the tree as a Mermaid flowchart. Accounts are drawn rounded.
+func writeMermaid(w io.Writer, root *Node) error {
+	fmt.Fprintln(w, "graph TD")
+	walk(root, func(id string, node *Node, parentID string) {
+		text := strings.ReplaceAll(label(node, "<br/>"), `"`, "#quot;")
+		if node.Type == NodeAccount {
+			fmt.Fprintf(w, "  %s(\"%s\")\n", id, text)
+		} else {
+			fmt.Fprintf(w, "  %s[\"%s\"]\n", id, text)
+		}
+		if parentID != "" {
+			fmt.Fprintf(w, "  %s --> %s\n", parentID, id)
+		}
+	})
+	return nil
+}
+
+// walk visits the nodes depth-first with generated identifiers, as planned nodes have
+// no ID and names are not unique
+func walk(root *Node, visit func(id string, node *Node, parentID string)) {
+	count := 0
+	var visitNode func(node *Node, parentID string)
+	visitNode = func(node *Node, parentID string) {
+		id := fmt.Sprintf("n%d", count)
+		count++
+		visit(id, node, parentID)
+		for _, child := range node.Children {
+			visitNode(child, id)
+		}
+	}
+	visitNode(root, "")
+}
+
+// label describes a node with its ID, SCPs and controls joined by the separator
+func label(node *Node, separator string) string {
+	parts := []string{node.Name}
+	if node.ID != "" {
+		parts[0] = fmt.Sprintf("%s (%s)", node.Name, node.ID)
+	}
+	if len(node.Policies) > 0 {
+		parts = append(parts, fmt.Sprintf("[SCP: %s]", strings.Join(node.Policies, ", ")))
+	}
+	if len(node.Controls) > 0 {
+		parts = append(parts, fmt.Sprintf("[controls: %s]", strings.Join(node.Controls, ", ")))
+	}
+	return strings.Join(parts, separator)
+}
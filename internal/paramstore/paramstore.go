@@ -0,0 +1,192 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package paramstore stores values of any size in SSM Parameter Store. Values use the
+// smallest tier that holds them, and values larger than the advanced tier allows are
+// split across chunk parameters listed by an index record stored under their name.
+// Version: 1.0.0
+package paramstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ssm"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// Largest values of the parameter tiers, in bytes
+	StandardLimit = 4 * 1024
+	AdvancedLimit = 8 * 1024
+
+	// Parameter tiers
+	TierStandard = "Standard"
+	TierAdvanced = "Advanced"
+
+	// IndexKind marks the index records of chunked values
+	IndexKind = "aws-organization/parameter-chunks"
+
+	// Suffix of the chunk parameters, followed by the position of the chunk
+	chunkSuffix = ".chunk-"
+)
+
+// Index is the record stored in place of a value split across chunk parameters
+type Index struct {
+	Kind   string   `json:"kind"`
+	Chunks []string `json:"chunks"`
+	Size   int      `json:"size"`
+	SHA256 string   `json:"sha256"`
+}
+
+// Tier returns the smallest tier holding a value
+func Tier(value string) string {
+	if len(value) <= StandardLimit {
+		return TierStandard
+	}
+	return TierAdvanced
+}
+
+// ChunkName returns the name of a chunk parameter of a value
+func ChunkName(name string, i int) string {
+	return fmt.Sprintf("%s%s%d", name, chunkSuffix, i)
+}
+
+// IsChunk reports whether a parameter holds a chunk of a value
+func IsChunk(name string) bool {
+	return strings.Contains(name, chunkSuffix)
+}
+
+// BaseName returns the name of the value a chunk parameter belongs to, and other
+// names unchanged
+func BaseName(name string) string {
+	if i := strings.LastIndex(name, chunkSuffix); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// Split returns the chunks of a value larger than the advanced tier allows and the
+// index record listing them. Values that fit a parameter are returned unchanged
+// without chunks. Chunks end on rune boundaries, so each is valid UTF-8.
+func Split(name, value string) (string, []string, error) {
+	if len(value) <= AdvancedLimit {
+		return value, nil, nil
+	}
+
+	sum := sha256.Sum256([]byte(value))
+	index := Index{Kind: IndexKind, Size: len(value), SHA256: hex.EncodeToString(sum[:])}
+	var chunks []string
+	for rest := value; rest != ""; {
+		end := len(rest)
+		if end > AdvancedLimit {
+			end = AdvancedLimit
+			for end > 0 && !utf8.RuneStart(rest[end]) {
+				end--
+			}
+		}
+		index.Chunks = append(index.Chunks, ChunkName(name, len(chunks)))
+		chunks = append(chunks, rest[:end])
+		rest = rest[end:]
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal chunk index of %s: %w", name, err)
+	}
+	return string(data), chunks, nil
+}
+
+// Join returns the value stored under a parameter, reading the chunks listed by an
+// index record with get
+func Join(value string, get func(name string) (string, error)) (string, error) {
+	var index Index
+	if !strings.HasPrefix(value, "{") || json.Unmarshal([]byte(value), &index) != nil || index.Kind != IndexKind {
+		return value, nil
+	}
+
+	var joined strings.Builder
+	joined.Grow(index.Size)
+	for _, name := range index.Chunks {
+		chunk, err := get(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read chunk %s: %w", name, err)
+		}
+		joined.WriteString(chunk)
+	}
+
+	sum := sha256.Sum256([]byte(joined.String()))
+	if hex.EncodeToString(sum[:]) != index.SHA256 {
+		return "", fmt.Errorf("chunks of %d bytes do not match their index of %d bytes", joined.Len(), index.Size)
+	}
+	return joined.String(), nil
+}
+
+// NewParameter creates the parameter of a value known when the program runs, with the
+// name and settings of args. A tier set in args is kept while the value fits it;
+// otherwise the value uses the tier it requires, split across chunk parameters created
+// with the same settings when it exceeds the advanced tier. The parameter holding the
+// value or its index comes first, followed by the chunk parameters.
+func NewParameter(ctx *pulumi.Context, resourceName, name, value string, args *ssm.ParameterArgs, opts ...pulumi.ResourceOption) ([]*ssm.Parameter, error) {
+	stored, chunks, err := Split(name, value)
+	if err != nil {
+		return nil, err
+	}
+
+	var parameters []*ssm.Parameter
+	for i, chunk := range chunks {
+		chunkArgs := *args
+		chunkArgs.Name = pulumi.String(ChunkName(name, i))
+		chunkArgs.Value = pulumi.String(chunk)
+		chunkArgs.Tier = tier(args.Tier, chunk)
+		chunkArgs.Description = pulumi.Sprintf("Chunk %d of %s", i, name)
+		parameter, err := ssm.NewParameter(ctx, fmt.Sprintf("%s%s%d", resourceName, chunkSuffix, i), &chunkArgs, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store chunk %d of %s: %w", i, name, err)
+		}
+		parameters = append(parameters, parameter)
+	}
+
+	args.Name = pulumi.String(name)
+	args.Value = pulumi.String(stored)
+	args.Tier = tier(args.Tier, stored)
+	parameter, err := ssm.NewParameter(ctx, resourceName, args, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*ssm.Parameter{parameter}, parameters...), nil
+}
+
+// tier returns the configured tier while a value fits it, the tier the value requires
+// otherwise
+func tier(configured pulumi.StringPtrInput, value string) pulumi.StringPtrInput {
+	if configured == nil || len(value) > StandardLimit {
+		return pulumi.String(Tier(value))
+	}
+	return configured
+}
+
+// Lookup reads a value stored by NewParameter, joining its chunks
+func Lookup(ctx *pulumi.Context, name string) (string, error) {
+	get := func(name string) (string, error) {
+		parameter, err := ssm.LookupParameter(ctx, &ssm.LookupParameterArgs{
+			Name:           name,
+			WithDecryption: pulumi.BoolRef(true),
+		})
+		if err != nil {
+			return "", err
+		}
+		return parameter.Value, nil
+	}
+
+	value, err := get(name)
+	if err != nil {
+		return "", err
+	}
+	return Join(value, get)
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package plan provides the machine-readable results of previews, drift detection and
+// configuration validation for CI pipelines.
+// Version: 1.0.0
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+)
+
+// SchemaVersion is the version of the document schema. Fields are only added within a
+// version; renaming or removing a field requires a new version.
+const SchemaVersion = "1"
+
+// Status summarizes a document so pipelines can gate on a single field
+type Status string
+
+const (
+	// Document statuses
+	StatusOK      Status = "ok"
+	StatusChanges Status = "changes"
+	StatusDrift   Status = "drift"
+	StatusInvalid Status = "invalid"
+	StatusError   Status = "error"
+)
+
+// Change represents a resource the preview would create, update, replace or delete
+type Change struct {
+	URN       string   `json:"urn"`
+	Type      string   `json:"type"`
+	Operation string   `json:"operation"`
+	Stack     string   `json:"stack,omitempty"`
+	Diffs     []string `json:"diffs"`
+}
+
+// ValidationError represents a section of the configuration that failed validation
+type ValidationError struct {
+	Section string `json:"section"`
+	Message string `json:"message"`
+}
+
+// Document is the result of a command in the stable JSON schema. Every list is always
+// present, empty when there is nothing to report.
+type Document struct {
+	SchemaVersion    string            `json:"schemaVersion"`
+	Command          string            `json:"command"`
+	RunID            string            `json:"runId"`
+	GeneratedAt      time.Time         `json:"generatedAt"`
+	Status           Status            `json:"status"`
+	Summary          map[string]int    `json:"summary"`
+	Changes          []Change          `json:"changes"`
+	ValidationErrors []ValidationError `json:"validationErrors"`
+	Drift            []report.Finding  `json:"drift"`
+	Error            string            `json:"error,omitempty"`
+}
+
+// New creates an empty document for a command
+func New(command string) *Document {
+	return &Document{
+		SchemaVersion:    SchemaVersion,
+		Command:          command,
+		RunID:            runid.ID(),
+		GeneratedAt:      time.Now().UTC(),
+		Status:           StatusOK,
+		Summary:          map[string]int{},
+		Changes:          []Change{},
+		ValidationErrors: []ValidationError{},
+		Drift:            []report.Finding{},
+	}
+}
+
+// AddChanges adds resource changes and updates the status
+func (d *Document) AddChanges(changes ...Change) {
+	for _, change := range changes {
+		if change.Diffs == nil {
+			change.Diffs = []string{}
+		}
+		d.Changes = append(d.Changes, change)
+		d.Summary[change.Operation]++
+	}
+	d.setStatus()
+}
+
+// AddValidationErrors adds validation errors and updates the status
+func (d *Document) AddValidationErrors(errs ...ValidationError) {
+	d.ValidationErrors = append(d.ValidationErrors, errs...)
+	d.setStatus()
+}
+
+// AddDrift adds drift findings and updates the status
+func (d *Document) AddDrift(findings ...report.Finding) {
+	d.Drift = append(d.Drift, findings...)
+	for _, finding := range findings {
+		d.Summary[string(finding.Severity)]++
+	}
+	d.setStatus()
+}
+
+// Fail records the error that stopped the command
+func (d *Document) Fail(err error) {
+	d.Error = err.Error()
+	d.setStatus()
+}
+
+// setStatus derives the status from the content, the most severe outcome first
+func (d *Document) setStatus() {
+	switch {
+	case d.Error != "":
+		d.Status = StatusError
+	case len(d.ValidationErrors) > 0:
+		d.Status = StatusInvalid
+	case len(d.Drift) > 0:
+		d.Status = StatusDrift
+	case len(d.Changes) > 0:
+		d.Status = StatusChanges
+	default:
+		d.Status = StatusOK
+	}
+}
+
+// Write renders the document as indented JSON
+func (d *Document) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(d); err != nil {
+		return fmt.Errorf("failed to encode %s result: %w", d.Command, err)
+	}
+	return nil
+}
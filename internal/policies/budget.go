@@ -0,0 +1,332 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package policies
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/invitations"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/quarantine"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+)
+
+const (
+	// MaxSCPsPerTarget is the number of SCPs Organizations allows to attach directly to
+	// the root, an OU or an account
+	MaxSCPsPerTarget = 5
+
+	// FullAWSAccess is attached by Organizations to every target and the landing zone
+	// keeps it, so it takes one of the attachments of each target
+	FullAWSAccess         = "FullAWSAccess"
+	fullAWSAccessDocument = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"*","Resource":"*"}]}`
+)
+
+// Kinds of policy targets
+const (
+	TargetRoot    = "root"
+	TargetOU      = "ou"
+	TargetAccount = "account"
+)
+
+// Attachment is an SCP attached to a target. Managed attachments are made by
+// Organizations or by the landing zone rather than by the policies of the configuration.
+type Attachment struct {
+	Policy  string `json:"policy"`
+	Size    int    `json:"size"`
+	Managed bool   `json:"managed,omitempty"`
+
+	document string
+}
+
+// TargetBudget is the use of the SCP limits by a target. Size is the length of the
+// documents attached to the target and EffectiveSize adds the documents it inherits
+// from the root. The OU of an account target is not known from the configuration, so
+// only the policies of the root are counted as inherited.
+type TargetBudget struct {
+	Target        string       `json:"target"`
+	Kind          string       `json:"kind"`
+	Attached      []Attachment `json:"attached"`
+	Inherited     []Attachment `json:"inherited"`
+	Size          int          `json:"size"`
+	EffectiveSize int          `json:"effectiveSize"`
+	Exceeded      bool         `json:"exceeded"`
+	Guidance      []string     `json:"guidance,omitempty"`
+}
+
+// SCPBudget returns the use of the SCP limits by the root, the OUs and the account
+// targets of the configuration, the root first, then the OUs and the accounts by name.
+// Merged SCPs are counted once merged.
+func SCPBudget(cfg *config.LandingZoneConfig) ([]*TargetBudget, error) {
+	fullAccess := Attachment{Policy: FullAWSAccess, Size: len(fullAWSAccessDocument), Managed: true, document: fullAWSAccessDocument}
+	root := &TargetBudget{Target: config.PolicyTargetRoot, Kind: TargetRoot, Attached: []Attachment{fullAccess}}
+	targets := map[string]*TargetBudget{}
+	target := func(name, kind string) *TargetBudget {
+		if name == config.PolicyTargetRoot {
+			return root
+		}
+		if targets[name] == nil {
+			targets[name] = &TargetBudget{Target: name, Kind: kind, Attached: []Attachment{fullAccess}}
+		}
+		return targets[name]
+	}
+
+	for key := range cfg.OrganizationUnits {
+		target(invitations.OUName(cfg, key), TargetOU)
+	}
+	if cfg.Quarantine != nil && cfg.Quarantine.Enabled {
+		document, err := quarantine.PolicyDocument()
+		if err != nil {
+			return nil, err
+		}
+		name := quarantine.OUName(cfg.Quarantine)
+		t := target(name, TargetOU)
+		t.Attached = append(t.Attached, Attachment{Policy: name + "-scp", Size: len(document), Managed: true, document: document})
+	}
+
+	policies, err := EffectivePolicies(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range policies {
+		if policy.Type != config.PolicyTypeSCP {
+			continue
+		}
+		attachment := Attachment{Policy: policy.Name, Size: len(policy.Document()), document: policy.Document()}
+		for _, name := range policy.Targets {
+			kind := TargetOU
+			if accountIdRE.MatchString(name) {
+				kind = TargetAccount
+			} else {
+				name = invitations.OUName(cfg, name)
+			}
+			t := target(name, kind)
+			if !t.attached(policy.Name) {
+				t.Attached = append(t.Attached, attachment)
+			}
+		}
+	}
+
+	budgets := []*TargetBudget{root}
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := targets[names[i]], targets[names[j]]
+		if a.Kind != b.Kind {
+			return a.Kind == TargetOU
+		}
+		return a.Target < b.Target
+	})
+	for _, name := range names {
+		t := targets[name]
+		for _, attachment := range root.Attached {
+			if !attachment.Managed {
+				t.Inherited = append(t.Inherited, attachment)
+			}
+		}
+		budgets = append(budgets, t)
+	}
+
+	// Policies attached to every OU, which one attachment to the root would replace
+	ous, attachments := 0, map[string]int{}
+	for _, t := range targets {
+		if t.Kind != TargetOU {
+			continue
+		}
+		ous++
+		for _, attachment := range t.Attached {
+			attachments[attachment.Policy]++
+		}
+	}
+	everyOU := map[string]bool{}
+	for policy, count := range attachments {
+		everyOU[policy] = ous > 1 && count == ous
+	}
+
+	for _, t := range budgets {
+		for _, attachment := range t.Attached {
+			t.Size += attachment.Size
+		}
+		t.EffectiveSize = t.Size
+		for _, attachment := range t.Inherited {
+			t.EffectiveSize += attachment.Size
+		}
+		t.Exceeded = len(t.Attached) > MaxSCPsPerTarget
+		if t.Exceeded {
+			t.Guidance = t.guidance(root, everyOU)
+		}
+	}
+	return budgets, nil
+}
+
+// attached reports whether a policy is attached to the target
+func (t *TargetBudget) attached(policy string) bool {
+	for _, attachment := range t.Attached {
+		if attachment.Policy == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// guidance suggests how a target exceeding the SCP limit can be brought back within it:
+// dropping attachments already inherited from the root, consolidating the smallest
+// policies into one document, and moving to the root the policies every OU carries
+func (t *TargetBudget) guidance(root *TargetBudget, everyOU map[string]bool) []string {
+	var guidance []string
+	var own []Attachment
+	for _, attachment := range t.Attached {
+		switch {
+		case attachment.Managed:
+		case t != root && root.attached(attachment.Policy):
+			guidance = append(guidance, fmt.Sprintf("remove %s from the targets of %s, it is inherited from %s",
+				t.Target, attachment.Policy, config.PolicyTargetRoot))
+		default:
+			own = append(own, attachment)
+		}
+	}
+
+	// Merging n policies into one frees n-1 attachments
+	excess := len(t.Attached) - len(guidance) - MaxSCPsPerTarget
+	if excess > 0 && len(own) > excess {
+		sort.SliceStable(own, func(i, j int) bool { return own[i].Size < own[j].Size })
+		merged := own[:excess+1]
+		names := make([]string, 0, len(merged))
+		documents := make([]string, 0, len(merged))
+		for _, attachment := range merged {
+			names = append(names, attachment.Policy)
+			documents = append(documents, attachment.document)
+		}
+		if document, err := mergeDocuments(documents); err == nil && len(document) <= config.MaxSCPSize {
+			guidance = append(guidance, fmt.Sprintf("merge the statements of %s into one policy of %d characters",
+				strings.Join(names, ", "), len(document)))
+		} else if err == nil {
+			guidance = append(guidance, fmt.Sprintf("the statements of %s take %d characters, more than the %d of one policy: "+
+				"shorten them with wildcard actions or move some of them to a parent OU", strings.Join(names, ", "),
+				len(document), config.MaxSCPSize))
+		}
+	}
+
+	// The root has room for as many policies as it has attachments left
+	if room := MaxSCPsPerTarget - len(root.Attached); t.Kind == TargetOU && room > 0 {
+		var shared []string
+		for _, attachment := range own {
+			if everyOU[attachment.Policy] && len(shared) < room {
+				shared = append(shared, attachment.Policy)
+			}
+		}
+		if len(shared) > 0 {
+			guidance = append(guidance, fmt.Sprintf("attach %s to %s instead of every OU",
+				strings.Join(shared, ", "), config.PolicyTargetRoot))
+		}
+	}
+
+	if len(guidance) == 0 {
+		guidance = append(guidance, fmt.Sprintf("reduce the SCPs attached to %s to %d, FullAWSAccess included",
+			t.Target, MaxSCPsPerTarget))
+	}
+	return guidance
+}
+
+// mergeDocuments returns a document holding the statements of every document
+func mergeDocuments(documents []string) (string, error) {
+	var statements []json.RawMessage
+	for _, document := range documents {
+		s, err := statementsOf(document)
+		if err != nil {
+			return "", err
+		}
+		statements = append(statements, s...)
+	}
+
+	merged, err := json.Marshal(struct {
+		Version   string            `json:"Version"`
+		Statement []json.RawMessage `json:"Statement"`
+	}{Version: "2012-10-17", Statement: statements})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode merged policy: %w", err)
+	}
+	return string(merged), nil
+}
+
+// statementsOf returns the statements of a policy document, whose Statement is either
+// a single statement or a list of statements
+func statementsOf(document string) ([]json.RawMessage, error) {
+	var parsed struct {
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal([]byte(document), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse policy document: %w", err)
+	}
+
+	var statements []json.RawMessage
+	if err := json.Unmarshal(parsed.Statement, &statements); err == nil {
+		return statements, nil
+	}
+	var statement json.RawMessage
+	if err := json.Unmarshal(parsed.Statement, &statement); err != nil {
+		return nil, fmt.Errorf("failed to parse policy statements: %w", err)
+	}
+	return []json.RawMessage{statement}, nil
+}
+
+// CheckSCPBudget returns an error describing every target exceeding the SCP limits,
+// with the guidance to bring it back within them
+func CheckSCPBudget(budgets []*TargetBudget) error {
+	var problems []string
+	for _, t := range budgets {
+		if !t.Exceeded {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("%s has %d SCPs attached, %d allowed: %s",
+			t.Target, len(t.Attached), MaxSCPsPerTarget, strings.Join(t.Guidance, "; ")))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("service control policies exceed the limits of Organizations: %s", strings.Join(problems, "; "))
+}
+
+// WriteSCPBudget writes the use of the SCP limits as a table or as JSON
+func WriteSCPBudget(w io.Writer, format string, budgets []*TargetBudget) error {
+	switch format {
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(budgets); err != nil {
+			return fmt.Errorf("failed to encode SCP budget: %w", err)
+		}
+		return nil
+	case report.FormatText:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TARGET\tKIND\tATTACHED\tSIZE\tINHERITED\tEFFECTIVE SIZE\tSTATUS")
+		for _, t := range budgets {
+			status := "ok"
+			if t.Exceeded {
+				status = "exceeded"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d/%d\t%d\t%d\t%d\t%s\n",
+				t.Target, t.Kind, len(t.Attached), MaxSCPsPerTarget, t.Size, len(t.Inherited), t.EffectiveSize, status)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		for _, t := range budgets {
+			for _, guidance := range t.Guidance {
+				fmt.Fprintf(w, "%s: %s\n", t.Target, guidance)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package policies
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/invitations"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+)
+
+// Merge is a policy consolidating the deny SCPs of the same targets
+type Merge struct {
+	Name       string   `json:"name"`
+	Targets    []string `json:"targets"`
+	Policies   []string `json:"policies"`
+	Statements int      `json:"statements"`
+	Duplicates int      `json:"duplicates"`
+	Size       int      `json:"size"`
+	Document   string   `json:"document"`
+}
+
+// EffectivePolicies returns the policies the landing zone deploys: the configured
+// policies, with the deny SCPs of the same targets merged when mergeScps is set
+func EffectivePolicies(cfg *config.LandingZoneConfig) ([]config.PolicyConfig, error) {
+	if !cfg.MergeSCPs {
+		return cfg.Policies, nil
+	}
+	policies, _, err := MergeSCPs(cfg)
+	return policies, err
+}
+
+// MergeSCPs merges the SCPs whose statements all deny and that are attached to the
+// same targets into as few documents as fit the size limit, dropping the statements
+// repeated across them. Other policies, and deny SCPs no other policy shares the
+// targets of, are returned unchanged. Each merged policy takes the place and the name
+// of the first policy it merges, so turning merging on updates those policies in place
+// and keeps their attachments: a target never holds the merged policies alongside the
+// policies they replace, which would exceed its SCP limit.
+func MergeSCPs(cfg *config.LandingZoneConfig) ([]config.PolicyConfig, []*Merge, error) {
+	groups := map[string][]config.PolicyConfig{}
+	keys := map[string]string{}
+	for _, policy := range cfg.Policies {
+		deny, err := denyOnly(policy)
+		if err != nil {
+			return nil, nil, err
+		}
+		if deny {
+			key := targetKey(cfg, policy.Targets)
+			groups[key] = append(groups[key], policy)
+			keys[policy.Name] = key
+		}
+	}
+
+	var result []config.PolicyConfig
+	var merges []*Merge
+	for _, policy := range cfg.Policies {
+		key, ok := keys[policy.Name]
+		switch {
+		case !ok || len(groups[key]) < 2:
+			result = append(result, policy)
+		case groups[key][0].Name == policy.Name:
+			merged, err := mergeGroup(groups[key])
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, merge := range merged {
+				result = append(result, config.PolicyConfig{
+					Name:        merge.Name,
+					Type:        config.PolicyTypeSCP,
+					Description: fmt.Sprintf("Merged from %s", strings.Join(merge.Policies, ", ")),
+					Content:     merge.Document,
+					Targets:     merge.Targets,
+				})
+			}
+			merges = append(merges, merged...)
+		}
+	}
+	return result, merges, nil
+}
+
+// denyOnly reports whether a policy is an SCP whose statements all deny
+func denyOnly(policy config.PolicyConfig) (bool, error) {
+	if policy.Type != config.PolicyTypeSCP {
+		return false, nil
+	}
+	statements, err := statementsOf(policy.Document())
+	if err != nil {
+		return false, fmt.Errorf("policy %s: %w", policy.Name, err)
+	}
+	for _, raw := range statements {
+		var statement struct {
+			Effect string `json:"Effect"`
+		}
+		if err := json.Unmarshal(raw, &statement); err != nil || statement.Effect != "Deny" {
+			return false, nil
+		}
+	}
+	return len(statements) > 0, nil
+}
+
+// targetKey identifies a set of targets, OUs given by key or name alike
+func targetKey(cfg *config.LandingZoneConfig, targets []string) string {
+	names := make([]string, 0, len(targets))
+	seen := map[string]bool{}
+	for _, target := range targets {
+		name := target
+		if target != config.PolicyTargetRoot && !accountIdRE.MatchString(target) {
+			name = invitations.OUName(cfg, target)
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// mergeGroup packs the statements of the policies of the same targets into documents of
+// at most the SCP size, keeping the statements of a policy in the same document. Each
+// document is named after the first policy it merges.
+func mergeGroup(group []config.PolicyConfig) ([]*Merge, error) {
+	var merges []*Merge
+	var current *Merge
+	var statements []json.RawMessage
+	seen := map[string]bool{}
+	sids := map[string]bool{}
+
+	flush := func() error {
+		if current == nil {
+			return nil
+		}
+		document, err := encodeDocument(statements)
+		if err != nil {
+			return err
+		}
+		current.Document, current.Size, current.Statements = document, len(document), len(statements)
+		current.Name = current.Policies[0]
+		merges = append(merges, current)
+		current, statements = nil, nil
+		seen, sids = map[string]bool{}, map[string]bool{}
+		return nil
+	}
+
+	for _, policy := range group {
+		raw, err := statementsOf(policy.Document())
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", policy.Name, err)
+		}
+
+		for attempt := 0; ; attempt++ {
+			if current == nil {
+				current = &Merge{Targets: policy.Targets}
+			}
+			added, duplicates, err := addStatements(statements, raw, seen, sids)
+			if err != nil {
+				return nil, fmt.Errorf("policy %s: %w", policy.Name, err)
+			}
+			document, err := encodeDocument(added)
+			if err != nil {
+				return nil, err
+			}
+
+			// A policy that does not fit with others starts a document of its own
+			if len(document) > config.MaxSCPSize && len(current.Policies) > 0 && attempt == 0 {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			statements = added
+			current.Policies = append(current.Policies, policy.Name)
+			current.Duplicates += duplicates
+			break
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return merges, nil
+}
+
+// addStatements appends to a document the statements it does not hold yet, ignoring
+// their Sid, and returns the statements along with the number of duplicates dropped.
+// Statements whose Sid is already taken are given a numbered Sid. The statements and
+// Sids of the document are recorded in seen and sids, which mergeGroup resets when it
+// starts another document instead.
+func addStatements(statements, raw []json.RawMessage, seen, sids map[string]bool) ([]json.RawMessage, int, error) {
+	added := append([]json.RawMessage(nil), statements...)
+	duplicates := 0
+	for _, r := range raw {
+		var statement map[string]interface{}
+		if err := json.Unmarshal(r, &statement); err != nil {
+			return nil, 0, fmt.Errorf("failed to parse policy statement: %w", err)
+		}
+
+		sid, _ := statement["Sid"].(string)
+		delete(statement, "Sid")
+		normalized, err := json.Marshal(statement)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to encode policy statement: %w", err)
+		}
+		if seen[string(normalized)] {
+			duplicates++
+			continue
+		}
+		seen[string(normalized)] = true
+
+		if sid != "" {
+			unique := sid
+			for n := 2; sids[unique]; n++ {
+				unique = sid + strconv.Itoa(n)
+			}
+			sids[unique] = true
+			statement["Sid"] = unique
+		}
+		encoded, err := json.Marshal(statement)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to encode policy statement: %w", err)
+		}
+		added = append(added, encoded)
+	}
+	return added, duplicates, nil
+}
+
+// encodeDocument returns the compact policy document of the statements
+func encodeDocument(statements []json.RawMessage) (string, error) {
+	document, err := json.Marshal(struct {
+		Version   string            `json:"Version"`
+		Statement []json.RawMessage `json:"Statement"`
+	}{Version: "2012-10-17", Statement: statements})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode merged policy: %w", err)
+	}
+	return string(document), nil
+}
+
+// WriteMerges writes the merged policies as a table, or as JSON with their documents.
+// With documents, the table is followed by the indented document of each policy.
+func WriteMerges(w io.Writer, format string, merges []*Merge, documents bool) error {
+	switch format {
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(merges); err != nil {
+			return fmt.Errorf("failed to encode merged policies: %w", err)
+		}
+		return nil
+	case report.FormatText:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "POLICY\tTARGETS\tMERGES\tSTATEMENTS\tDUPLICATES\tSIZE")
+		for _, merge := range merges {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\n",
+				merge.Name, strings.Join(merge.Targets, ","), strings.Join(merge.Policies, ","),
+				merge.Statements, merge.Duplicates, merge.Size)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if !documents {
+			return nil
+		}
+		for _, merge := range merges {
+			var indented interface{}
+			if err := json.Unmarshal([]byte(merge.Document), &indented); err != nil {
+				return fmt.Errorf("failed to parse merged policy %s: %w", merge.Name, err)
+			}
+			encoded, err := json.MarshalIndent(indented, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode merged policy %s: %w", merge.Name, err)
+			}
+			fmt.Fprintf(w, "\n%s:\n%s\n", merge.Name, encoded)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package policies
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+)
+
+func TestAddStatements(t *testing.T) {
+	tests := []struct {
+		name           string
+		seen           []string
+		sids           []string
+		raw            []json.RawMessage
+		wantSids       []string
+		wantDuplicates int
+		wantErr        bool
+	}{
+		{
+			name:     "appends new statements",
+			raw:      []json.RawMessage{statement("DenyS3", "s3:*"), statement("DenyEC2", "ec2:*")},
+			wantSids: []string{"DenyS3", "DenyEC2"},
+		},
+		{
+			name:           "drops duplicates ignoring their Sid",
+			seen:           []string{`{"Action":"s3:*","Effect":"Deny","Resource":"*"}`},
+			raw:            []json.RawMessage{statement("Other", "s3:*"), statement("DenyEC2", "ec2:*")},
+			wantSids:       []string{"DenyEC2"},
+			wantDuplicates: 1,
+		},
+		{
+			name:           "drops duplicates within the statements",
+			raw:            []json.RawMessage{statement("DenyS3", "s3:*"), statement("DenyS3Again", "s3:*")},
+			wantSids:       []string{"DenyS3"},
+			wantDuplicates: 1,
+		},
+		{
+			name:     "numbers Sids already taken",
+			sids:     []string{"Deny", "Deny2"},
+			raw:      []json.RawMessage{statement("Deny", "s3:*")},
+			wantSids: []string{"Deny3"},
+		},
+		{
+			name:     "keeps statements without Sid",
+			raw:      []json.RawMessage{statement("", "s3:*")},
+			wantSids: []string{""},
+		},
+		{
+			name:    "invalid statement",
+			raw:     []json.RawMessage{json.RawMessage(`"Deny"`)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := []json.RawMessage{statement("Existing", "iam:*")}
+			added, duplicates, err := addStatements(existing, tt.raw, set(tt.seen), set(tt.sids))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("addStatements() = %s, want an error", added)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("addStatements() error = %v", err)
+			}
+			if got := sidsOf(t, added); !reflect.DeepEqual(got, append([]string{"Existing"}, tt.wantSids...)) {
+				t.Errorf("addStatements() Sids = %q, want %q after Existing", got, tt.wantSids)
+			}
+			if duplicates != tt.wantDuplicates {
+				t.Errorf("addStatements() duplicates = %d, want %d", duplicates, tt.wantDuplicates)
+			}
+		})
+	}
+}
+
+func TestMergeGroup(t *testing.T) {
+	// Each large statement takes more than half of an SCP
+	large := strings.Repeat("a", config.MaxSCPSize/2)
+
+	tests := []struct {
+		name           string
+		group          []config.PolicyConfig
+		wantPolicies   [][]string
+		wantStatements []int
+		wantDuplicates int
+	}{
+		{
+			name: "merges into one document",
+			group: []config.PolicyConfig{
+				scp("deny-s3", statement("DenyS3", "s3:*")),
+				scp("deny-ec2", statement("DenyEC2", "ec2:*")),
+			},
+			wantPolicies:   [][]string{{"deny-s3", "deny-ec2"}},
+			wantStatements: []int{2},
+		},
+		{
+			name: "drops statements repeated across policies",
+			group: []config.PolicyConfig{
+				scp("deny-s3", statement("DenyS3", "s3:*")),
+				scp("deny-storage", statement("DenyStorage", "s3:*"), statement("DenyEBS", "ebs:*")),
+			},
+			wantPolicies:   [][]string{{"deny-s3", "deny-storage"}},
+			wantStatements: []int{2},
+			wantDuplicates: 1,
+		},
+		{
+			name: "starts a document at the size limit",
+			group: []config.PolicyConfig{
+				scp("deny-a", statement("DenyA", "s3:"+large)),
+				scp("deny-b", statement("DenyB", "ec2:"+large)),
+				scp("deny-c", statement("DenyC", "iam:*")),
+			},
+			wantPolicies:   [][]string{{"deny-a"}, {"deny-b", "deny-c"}},
+			wantStatements: []int{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merges, err := mergeGroup(tt.group)
+			if err != nil {
+				t.Fatalf("mergeGroup() error = %v", err)
+			}
+			if len(merges) != len(tt.wantPolicies) {
+				t.Fatalf("mergeGroup() = %d documents, want %d", len(merges), len(tt.wantPolicies))
+			}
+
+			duplicates := 0
+			for i, merge := range merges {
+				if !reflect.DeepEqual(merge.Policies, tt.wantPolicies[i]) {
+					t.Errorf("document %d merges %q, want %q", i, merge.Policies, tt.wantPolicies[i])
+				}
+				if merge.Statements != tt.wantStatements[i] {
+					t.Errorf("document %d has %d statements, want %d", i, merge.Statements, tt.wantStatements[i])
+				}
+				if merge.Size > config.MaxSCPSize || merge.Size != len(merge.Document) {
+					t.Errorf("document %d has size %d for %d characters", i, merge.Size, len(merge.Document))
+				}
+				if want := tt.wantPolicies[i][0]; merge.Name != want {
+					t.Errorf("document %d is named %s, want %s", i, merge.Name, want)
+				}
+				duplicates += merge.Duplicates
+			}
+			if duplicates != tt.wantDuplicates {
+				t.Errorf("mergeGroup() dropped %d duplicates, want %d", duplicates, tt.wantDuplicates)
+			}
+		})
+	}
+}
+
+func TestMergedNames(t *testing.T) {
+	// Merged documents keep the name of the first policy they merge, so the policies
+	// are updated in place when merging is turned on or a policy is added
+	first, err := mergeGroup([]config.PolicyConfig{scp("deny-s3", statement("DenyS3", "s3:*"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := mergeGroup([]config.PolicyConfig{
+		scp("deny-s3", statement("DenyS3", "s3:*")),
+		scp("deny-ec2", statement("DenyEC2", "ec2:*")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first[0].Name != "deny-s3" || second[0].Name != "deny-s3" {
+		t.Errorf("merged documents named %s and %s, want deny-s3", first[0].Name, second[0].Name)
+	}
+
+	large := strings.Repeat("a", config.MaxSCPSize/2)
+	merges, err := mergeGroup([]config.PolicyConfig{
+		scp("deny-a", statement("DenyA", "s3:"+large)),
+		scp("deny-b", statement("DenyB", "ec2:"+large)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, merge := range merges {
+		if names[merge.Name] {
+			t.Errorf("mergeGroup() named two documents %s", merge.Name)
+		}
+		names[merge.Name] = true
+	}
+}
+
+// statement returns a statement denying action, with sid when it is not empty
+func statement(sid, action string) json.RawMessage {
+	if sid == "" {
+		return json.RawMessage(fmt.Sprintf(`{"Effect":"Deny","Action":%q,"Resource":"*"}`, action))
+	}
+	return json.RawMessage(fmt.Sprintf(`{"Sid":%q,"Effect":"Deny","Action":%q,"Resource":"*"}`, sid, action))
+}
+
+// scp returns an SCP holding the statements
+func scp(name string, statements ...json.RawMessage) config.PolicyConfig {
+	document, _ := encodeDocument(statements)
+	return config.PolicyConfig{
+		Name:    name,
+		Type:    config.PolicyTypeSCP,
+		Content: document,
+		Targets: []string{"ou-a"},
+	}
+}
+
+func set(values []string) map[string]bool {
+	m := map[string]bool{}
+	for _, value := range values {
+		m[value] = true
+	}
+	return m
+}
+
+func sidsOf(t *testing.T, statements []json.RawMessage) []string {
+	t.Helper()
+	sids := make([]string, 0, len(statements))
+	for _, raw := range statements {
+		var statement struct {
+			Sid string `json:"Sid"`
+		}
+		if err := json.Unmarshal(raw, &statement); err != nil {
+			t.Fatalf("invalid statement %s: %v", raw, err)
+		}
+		sids = append(sids, statement.Sid)
+	}
+	return sids
+}
@@ -0,0 +1,383 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package policies provides the service control and tag policies of the organization.
+// Version: 1.0.0
+package policies
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/invitations"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	orgsdk "github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/organizations"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+var accountIdRE = regexp.MustCompile(`^\d{12}$`)
+
+// Targets holds the root and the OUs created by the organization module, so policies
+// can be attached to them before they exist
+type Targets struct {
+	RootID pulumi.StringInput
+	OUs    map[string]pulumi.StringInput
+}
+
+// Manager creates the policies and attaches them to their targets
+type Manager struct {
+	logger   *zap.Logger
+	metrics  *metrics.Collector
+	cfg      *config.LandingZoneConfig
+	resolver *Resolver
+	stage    *Stage
+}
+
+// Resolver resolves the targets of the configuration to the IDs of the root, OUs and
+// accounts
+type Resolver struct {
+	cfg     *config.LandingZoneConfig
+	targets Targets
+	cache   *orgcache.Cache
+}
+
+// NewResolver creates a resolver of the targets created by the organization module and
+// of the OUs of the live organization
+func NewResolver(cfg *config.LandingZoneConfig, targets Targets) *Resolver {
+	return &Resolver{cfg: cfg, targets: targets}
+}
+
+// SetupPolicies creates the configured policies and attaches them to their targets.
+// OUs the organization module does not create are looked up by name in the live
+// organization. The run fails before any policy is created when a target would
+// exceed the SCP limits of Organizations. With mergeScps, the deny SCPs sharing the
+// same targets are created as merged policies. During a staged rollout, the changes
+// of the policies only reach the targets of the stage.
+func SetupPolicies(ctx *pulumi.Context, cfg *config.LandingZoneConfig, targets Targets, opts ...pulumi.ResourceOption) error {
+	logger, err := logging.NewLogger("policies")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("policies")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("policies_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup policies"); err != nil {
+		return err
+	}
+
+	if len(cfg.Policies) == 0 {
+		logger.Info("no organization policies configured")
+		return nil
+	}
+
+	budgets, err := SCPBudget(cfg)
+	if err != nil {
+		return err
+	}
+	if err := CheckSCPBudget(budgets); err != nil {
+		metrics.IncrementCounter("policy_budget_exceeded")
+		return err
+	}
+
+	policies, err := EffectivePolicies(cfg)
+	if err != nil {
+		return err
+	}
+
+	stage, err := StageFromEnv()
+	if err != nil {
+		return err
+	}
+	if stage != nil {
+		logger.Info("staging policy changes",
+			zap.Strings("targets", stage.Targets),
+			zap.Int("created", len(stage.Created)),
+			zap.Int("updated", len(stage.Updated)),
+			zap.Int("attachments", len(stage.Attachments)))
+	}
+
+	m := &Manager{logger: logger, metrics: metrics, cfg: cfg, resolver: NewResolver(cfg, targets), stage: stage}
+	for _, policyCfg := range policies {
+		if err := m.createPolicy(ctx, policyCfg, opts); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("organization policies created successfully", zap.Int("policies", len(policies)))
+	return nil
+}
+
+// createPolicy creates a policy and its attachments. A staged policy only reaches the
+// targets of the stage its own targets cover: its new attachments to other targets are
+// held back and, when its content changes, the other targets keep the current content.
+func (m *Manager) createPolicy(ctx *pulumi.Context, policyCfg config.PolicyConfig, opts []pulumi.ResourceOption) error {
+	description := policyCfg.Description
+	if description == "" && policyCfg.Template != "" {
+		description = config.PolicyTemplates[policyCfg.Template].Description
+	}
+
+	kind := m.stage.kind(policyCfg)
+	policyOpts := opts
+	if kind == stagedUpdated {
+		policyOpts = append(append([]pulumi.ResourceOption{}, opts...), pulumi.IgnoreChanges([]string{"content"}))
+	}
+	if policyCfg.ImportID != "" {
+		policyOpts = append(append([]pulumi.ResourceOption{}, policyOpts...), pulumi.Import(pulumi.ID(policyCfg.ImportID)))
+	}
+
+	policy, err := organizations.NewPolicy(ctx, fmt.Sprintf("policy-%s", policyCfg.Name), &organizations.PolicyArgs{
+		Name:        pulumi.String(policyCfg.Name),
+		Description: pulumi.String(description),
+		Type:        pulumi.String(policyCfg.Type),
+		Content:     pulumi.String(policyCfg.Document()),
+		Tags:        pulumi.ToStringMap(m.cfg.Tags),
+	}, policyOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to create policy %s: %w", policyCfg.Name, err)
+	}
+
+	for _, target := range policyCfg.Targets {
+		if kind != "" && m.stage.reaches(target) {
+			// Attached below with the staged content
+			continue
+		}
+		if kind != "" && m.stage.newAttachment(policyCfg.Name, target) {
+			m.logger.Info("holding back policy attachment",
+				zap.String("policy", policyCfg.Name),
+				zap.String("target", target))
+			continue
+		}
+		attachOpts, err := m.importAttachment(ctx, policyCfg, target, opts)
+		if err != nil {
+			return err
+		}
+		if err := m.attach(ctx, policyCfg.Name, policy.ID(), target, attachOpts); err != nil {
+			return err
+		}
+	}
+	if kind == "" || len(m.stage.Targets) == 0 {
+		return nil
+	}
+
+	stagedId := policy.ID()
+	stagedName := policyCfg.Name
+	if kind == stagedUpdated {
+		stagedName = policyCfg.Name + stagedSuffix
+		staged, err := organizations.NewPolicy(ctx, fmt.Sprintf("policy-%s", stagedName), &organizations.PolicyArgs{
+			Name:        pulumi.String(stagedName),
+			Description: pulumi.String(description),
+			Type:        pulumi.String(policyCfg.Type),
+			Content:     pulumi.String(policyCfg.Document()),
+			Tags:        pulumi.ToStringMap(m.cfg.Tags),
+		}, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to create staged policy %s: %w", stagedName, err)
+		}
+		stagedId = staged.ID()
+	}
+	for _, target := range m.stage.Targets {
+		covered, err := m.resolver.Covers(ctx, policyCfg.Targets, target)
+		if err != nil {
+			return err
+		}
+		if !covered {
+			continue
+		}
+		if err := m.attach(ctx, stagedName, stagedId, target, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importAttachment returns the options of the attachment of an imported policy to a
+// target, importing the attachment when the target exists in the live organization.
+// Targets the organization module creates are attached once they exist.
+func (m *Manager) importAttachment(ctx *pulumi.Context, policyCfg config.PolicyConfig, target string, opts []pulumi.ResourceOption) ([]pulumi.ResourceOption, error) {
+	if policyCfg.ImportID == "" {
+		return opts, nil
+	}
+	targetId, err := m.resolver.LiveID(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if targetId == "" {
+		return opts, nil
+	}
+	id := pulumi.ID(fmt.Sprintf("%s:%s", targetId, policyCfg.ImportID))
+	return append(append([]pulumi.ResourceOption{}, opts...), pulumi.Import(id)), nil
+}
+
+// attach attaches a policy to a target
+func (m *Manager) attach(ctx *pulumi.Context, name string, policyId pulumi.IDOutput, target string, opts []pulumi.ResourceOption) error {
+	targetId, err := m.resolver.Resolve(ctx, target)
+	if err != nil {
+		return fmt.Errorf("failed to resolve target %s of policy %s: %w", target, name, err)
+	}
+
+	if _, err := organizations.NewPolicyAttachment(ctx, attachmentName(name, target),
+		&organizations.PolicyAttachmentArgs{
+			PolicyId: policyId,
+			TargetId: targetId,
+		}, opts...); err != nil {
+		return fmt.Errorf("failed to attach policy %s to %s: %w", name, target, err)
+	}
+	m.metrics.IncrementCounter("policy_attachments_created")
+	return nil
+}
+
+// Resolve returns the ID of a target: the root, an account ID, an OU of the organization
+// module, or an OU of the live organization with the name of the target
+func (r *Resolver) Resolve(ctx *pulumi.Context, target string) (pulumi.StringInput, error) {
+	if target == config.PolicyTargetRoot {
+		if r.targets.RootID == nil {
+			return nil, fmt.Errorf("the root is only known when the organization module is deployed")
+		}
+		return r.targets.RootID, nil
+	}
+	if accountIdRE.MatchString(target) {
+		return pulumi.String(target), nil
+	}
+
+	name := invitations.OUName(r.cfg, target)
+	if id, ok := r.targets.OUs[name]; ok {
+		return id, nil
+	}
+
+	ous, err := r.ous(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var id string
+	for _, ou := range ous {
+		if ou.Name != name {
+			continue
+		}
+		if id != "" {
+			return nil, fmt.Errorf("more than one OU is named %s, use the OU of a single parent", name)
+		}
+		id = ou.ID
+	}
+	if id == "" {
+		return nil, fmt.Errorf("OU %s not found", name)
+	}
+	return pulumi.String(id), nil
+}
+
+// Covers reports whether the targets of a policy cover a stage target: the stage
+// target is one of them, or the root or an OU it is below in the live organization.
+// A stage target missing from the live organization is only covered by itself.
+func (r *Resolver) Covers(ctx *pulumi.Context, policyTargets []string, stageTarget string) (bool, error) {
+	covering := make(map[string]bool)
+	for _, target := range policyTargets {
+		if target == stageTarget || target == config.PolicyTargetRoot {
+			return true, nil
+		}
+		if !accountIdRE.MatchString(target) {
+			covering[invitations.OUName(r.cfg, target)] = true
+		}
+	}
+	if len(covering) == 0 {
+		return false, nil
+	}
+
+	ous, err := r.ous(ctx)
+	if err != nil {
+		return false, err
+	}
+	parents := make(map[string]orgcache.OU, len(ous))
+	for _, ou := range ous {
+		parents[ou.ID] = ou
+	}
+
+	// Parents of the stage target: the OUs named after it, or the parent of the account
+	var parentIds []string
+	if accountIdRE.MatchString(stageTarget) {
+		out, err := r.orgClient().ListParents(ctx.Context(), &orgsdk.ListParentsInput{ChildId: aws.String(stageTarget)})
+		if err != nil {
+			return false, fmt.Errorf("failed to read parent of account %s: %w", stageTarget, err)
+		}
+		for _, parent := range out.Parents {
+			parentIds = append(parentIds, aws.ToString(parent.Id))
+		}
+	} else {
+		name := invitations.OUName(r.cfg, stageTarget)
+		for _, ou := range ous {
+			if ou.Name == name {
+				parentIds = append(parentIds, ou.ParentID)
+			}
+		}
+	}
+
+	for _, id := range parentIds {
+		for ou, ok := parents[id]; ok; ou, ok = parents[ou.ParentID] {
+			if covering[ou.Name] {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// LiveID returns the ID of a target in the live organization, or an empty string when
+// it does not exist yet
+func (r *Resolver) LiveID(ctx *pulumi.Context, target string) (string, error) {
+	if accountIdRE.MatchString(target) {
+		return target, nil
+	}
+	if _, err := r.ous(ctx); err != nil {
+		return "", err
+	}
+	if target == config.PolicyTargetRoot {
+		return r.cache.RootID(ctx.Context())
+	}
+
+	ous, err := r.cache.OUs(ctx.Context())
+	if err != nil {
+		return "", err
+	}
+	name := invitations.OUName(r.cfg, target)
+	for _, ou := range ous {
+		if ou.Name == name {
+			return ou.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// ous returns the OUs of the live organization, read through a cache kept for the
+// lifetime of the resolver
+func (r *Resolver) ous(ctx *pulumi.Context) ([]orgcache.OU, error) {
+	if r.cache == nil {
+		cache, err := orgcache.New(ctx.Context(), r.cfg, nil)
+		if err != nil {
+			return nil, err
+		}
+		r.cache = cache
+	}
+	return r.cache.OUs(ctx.Context())
+}
+
+// orgClient returns a client of the Organizations API with the organization credentials
+// of the cache
+func (r *Resolver) orgClient() *orgsdk.Client {
+	return orgsdk.NewFromConfig(r.cache.OrganizationConfig())
+}
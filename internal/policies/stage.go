@@ -0,0 +1,160 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package policies
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+)
+
+// EnvStage carries the stage of a staged rollout of the policies into the Pulumi
+// program. Without it, every policy is applied to all of its targets.
+const EnvStage = "AWS_ORG_GUARDRAIL_STAGE"
+
+const (
+	// Types of the resources of the policies
+	policyType           = "aws:organizations/policy:Policy"
+	policyAttachmentType = "aws:organizations/policyAttachment:PolicyAttachment"
+
+	// Suffix of the policies holding the new content of updated policies while staged
+	stagedSuffix = "-staged"
+)
+
+// Kinds of staged policies
+const (
+	stagedCreated  = "created"
+	stagedUpdated  = "updated"
+	stagedAttached = "attached"
+)
+
+// Stage is a step of a staged rollout of the policies a deployment creates, updates
+// or attaches to new targets. While staged, those changes reach the stage targets
+// only: created policies are attached to the stage targets alone, updated policies
+// keep their current content on their other targets while a copy with the new
+// content is attached to the stage targets, and new attachments to other targets are
+// held back. A stage without targets holds every change back.
+type Stage struct {
+	Targets     []string `json:"targets"`
+	Created     []string `json:"created,omitempty"`
+	Updated     []string `json:"updated,omitempty"`
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+// NewStage returns the stage reaching targets of the policy changes planned by a
+// preview of the whole rollout
+func NewStage(changes []plan.Change, targets []string) *Stage {
+	stage := &Stage{Targets: targets}
+	for _, change := range changes {
+		name := resourceName(change.URN)
+		switch {
+		case change.Type == policyType && change.Operation == "create":
+			stage.Created = append(stage.Created, strings.TrimPrefix(name, "policy-"))
+		case change.Type == policyType && change.Operation == "update":
+			stage.Updated = append(stage.Updated, strings.TrimPrefix(name, "policy-"))
+		case change.Type == policyAttachmentType && change.Operation == "create":
+			stage.Attachments = append(stage.Attachments, name)
+		}
+	}
+	return stage
+}
+
+// StageFromEnv returns the stage set by the deploy command, or nil outside a staged
+// rollout
+func StageFromEnv() (*Stage, error) {
+	value := os.Getenv(EnvStage)
+	if value == "" {
+		return nil, nil
+	}
+
+	var stage Stage
+	if err := json.Unmarshal([]byte(value), &stage); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", EnvStage, err)
+	}
+	return &stage, nil
+}
+
+// Empty reports whether the rollout stages no change
+func (s *Stage) Empty() bool {
+	return len(s.Created) == 0 && len(s.Updated) == 0 && len(s.Attachments) == 0
+}
+
+// Held returns the stage holding back every change of s
+func (s *Stage) Held() *Stage {
+	held := *s
+	held.Targets = nil
+	return &held
+}
+
+// Env returns the environment variables setting the stage in the Pulumi program
+func (s *Stage) Env() (map[string]string, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rollout stage: %w", err)
+	}
+	return map[string]string{EnvStage: string(data)}, nil
+}
+
+// kind returns how a policy is staged, or an empty string when it is not
+func (s *Stage) kind(policy config.PolicyConfig) string {
+	if s == nil {
+		return ""
+	}
+	for _, name := range s.Created {
+		if name == policy.Name {
+			return stagedCreated
+		}
+	}
+	for _, name := range s.Updated {
+		if name == policy.Name {
+			return stagedUpdated
+		}
+	}
+	for _, target := range policy.Targets {
+		if s.newAttachment(policy.Name, target) {
+			return stagedAttached
+		}
+	}
+	return ""
+}
+
+// reaches reports whether the staged changes reach a target
+func (s *Stage) reaches(target string) bool {
+	for _, t := range s.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// newAttachment reports whether the attachment of a policy to a target is created by
+// the rollout
+func (s *Stage) newAttachment(policy, target string) bool {
+	name := attachmentName(policy, target)
+	for _, attachment := range s.Attachments {
+		if attachment == name {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentName returns the resource name of the attachment of a policy to a target
+func attachmentName(policy, target string) string {
+	return fmt.Sprintf("policy-%s-%s", policy, target)
+}
+
+// resourceName returns the name of a resource from its URN
+func resourceName(urn string) string {
+	if i := strings.LastIndex(urn, "::"); i >= 0 {
+		return urn[i+2:]
+	}
+	return urn
+}
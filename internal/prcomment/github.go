@@ -0,0 +1,128 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package prcomment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// Default API of github.com, overridden by GITHUB_API_URL on GitHub Enterprise
+	defaultGitHubAPI = "https://api.github.com"
+
+	// Comments listed per page
+	githubPageSize = 100
+)
+
+// gitHub posts the comment on a GitHub pull request
+type gitHub struct {
+	client     *http.Client
+	api        string
+	repository string
+	number     int
+	token      string
+}
+
+// githubComment is an issue comment of the GitHub API
+type githubComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+// newGitHub reads the pull request from the event payload of a GitHub Actions job.
+// Jobs not triggered by a pull request have no poster.
+func newGitHub() (Poster, error) {
+	path := os.Getenv("GITHUB_EVENT_PATH")
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub event: %w", err)
+	}
+
+	var event struct {
+		PullRequest *struct {
+			Number int `json:"number"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub event: %w", err)
+	}
+	if event.PullRequest == nil {
+		return nil, nil
+	}
+
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("GITHUB_TOKEN is required to comment on pull requests")
+	}
+
+	api := os.Getenv("GITHUB_API_URL")
+	if api == "" {
+		api = defaultGitHubAPI
+	}
+
+	return &gitHub{
+		client:     &http.Client{Timeout: requestTimeout},
+		api:        strings.TrimSuffix(api, "/"),
+		repository: os.Getenv("GITHUB_REPOSITORY"),
+		number:     event.PullRequest.Number,
+		token:      token,
+	}, nil
+}
+
+// Name returns the code hosting service
+func (g *gitHub) Name() string {
+	return "github"
+}
+
+// Upsert updates the plan comment of the pull request, or creates it
+func (g *gitHub) Upsert(ctx context.Context, body string) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+g.token)
+	header.Set("Accept", "application/vnd.github+json")
+
+	existing, err := g.find(ctx, header)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]string{"body": body}
+	if existing != 0 {
+		endpoint := fmt.Sprintf("%s/repos/%s/issues/comments/%d", g.api, g.repository, existing)
+		return doJSON(ctx, g.client, http.MethodPatch, endpoint, header, payload, nil)
+	}
+
+	endpoint := fmt.Sprintf("%s/repos/%s/issues/%d/comments", g.api, g.repository, g.number)
+	return doJSON(ctx, g.client, http.MethodPost, endpoint, header, payload, nil)
+}
+
+// find returns the ID of the comment holding Marker, or 0
+func (g *gitHub) find(ctx context.Context, header http.Header) (int64, error) {
+	for page := 1; ; page++ {
+		endpoint := fmt.Sprintf("%s/repos/%s/issues/%d/comments?per_page=%d&page=%d",
+			g.api, g.repository, g.number, githubPageSize, page)
+
+		var comments []githubComment
+		if err := doJSON(ctx, g.client, http.MethodGet, endpoint, header, nil, &comments); err != nil {
+			return 0, err
+		}
+		for _, comment := range comments {
+			if strings.HasPrefix(comment.Body, Marker) {
+				return comment.ID, nil
+			}
+		}
+		if len(comments) < githubPageSize {
+			return 0, nil
+		}
+	}
+}
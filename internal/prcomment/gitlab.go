@@ -0,0 +1,104 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package prcomment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	// Notes listed per page
+	gitlabPageSize = 100
+)
+
+// gitLab posts the comment as a note of a GitLab merge request
+type gitLab struct {
+	client  *http.Client
+	api     string
+	project string
+	iid     string
+	token   string
+}
+
+// gitlabNote is a merge request note of the GitLab API
+type gitlabNote struct {
+	ID     int64  `json:"id"`
+	Body   string `json:"body"`
+	System bool   `json:"system"`
+}
+
+// newGitLab reads the merge request of a GitLab CI job from the predefined variables.
+// Jobs outside merge request pipelines have no poster. The job token cannot write
+// notes, so a project or personal access token is read from GITLAB_TOKEN.
+func newGitLab() (Poster, error) {
+	iid := os.Getenv("CI_MERGE_REQUEST_IID")
+	if iid == "" {
+		return nil, nil
+	}
+
+	token := os.Getenv("GITLAB_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("GITLAB_TOKEN is required to comment on merge requests")
+	}
+
+	return &gitLab{
+		client:  &http.Client{Timeout: requestTimeout},
+		api:     strings.TrimSuffix(os.Getenv("CI_API_V4_URL"), "/"),
+		project: url.PathEscape(os.Getenv("CI_PROJECT_ID")),
+		iid:     iid,
+		token:   token,
+	}, nil
+}
+
+// Name returns the code hosting service
+func (g *gitLab) Name() string {
+	return "gitlab"
+}
+
+// Upsert updates the plan note of the merge request, or creates it
+func (g *gitLab) Upsert(ctx context.Context, body string) error {
+	header := http.Header{}
+	header.Set("PRIVATE-TOKEN", g.token)
+
+	existing, err := g.find(ctx, header)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]string{"body": body}
+	if existing != 0 {
+		endpoint := fmt.Sprintf("%s/projects/%s/merge_requests/%s/notes/%d", g.api, g.project, g.iid, existing)
+		return doJSON(ctx, g.client, http.MethodPut, endpoint, header, payload, nil)
+	}
+
+	endpoint := fmt.Sprintf("%s/projects/%s/merge_requests/%s/notes", g.api, g.project, g.iid)
+	return doJSON(ctx, g.client, http.MethodPost, endpoint, header, payload, nil)
+}
+
+// find returns the ID of the note holding Marker, or 0
+func (g *gitLab) find(ctx context.Context, header http.Header) (int64, error) {
+	for page := 1; ; page++ {
+		endpoint := fmt.Sprintf("%s/projects/%s/merge_requests/%s/notes?per_page=%d&page=%d",
+			g.api, g.project, g.iid, gitlabPageSize, page)
+
+		var notes []gitlabNote
+		if err := doJSON(ctx, g.client, http.MethodGet, endpoint, header, nil, &notes); err != nil {
+			return 0, err
+		}
+		for _, note := range notes {
+			if !note.System && strings.HasPrefix(note.Body, Marker) {
+				return note.ID, nil
+			}
+		}
+		if len(notes) < gitlabPageSize {
+			return 0, nil
+		}
+	}
+}
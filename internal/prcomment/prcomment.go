@@ -0,0 +1,227 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package prcomment provides the pull request comment summarizing previews, drift and
+// compliance findings when running in CI.
+// Version: 1.0.0
+package prcomment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+)
+
+const (
+	// Marker identifies the comment so later runs update it instead of adding another
+	Marker = "<!-- aws-organization-plan -->"
+
+	// Comments are truncated below the 65536 characters accepted by GitHub
+	maxCommentLength = 60000
+
+	// Timeout of requests to the code hosting API
+	requestTimeout = 30 * time.Second
+)
+
+// Poster creates or updates the plan comment of a pull request
+type Poster interface {
+	// Name returns the code hosting service of the pull request
+	Name() string
+	// Upsert replaces the body of the comment holding Marker, or creates it
+	Upsert(ctx context.Context, body string) error
+}
+
+// Detect returns the poster for the pull request of the current CI job. It returns nil
+// when the job does not run for a pull request.
+func Detect() (Poster, error) {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return newGitHub()
+	case os.Getenv("GITLAB_CI") == "true":
+		return newGitLab()
+	default:
+		return nil, nil
+	}
+}
+
+// Render renders the comment for the given command results and compliance report,
+// either of which may be empty
+func Render(docs []*plan.Document, r *report.Report) string {
+	var b strings.Builder
+	b.WriteString(Marker + "\n")
+	b.WriteString("### AWS Organization plan\n\n")
+
+	if len(docs) > 0 {
+		b.WriteString("| Command | Status | Summary |\n|---|---|---|\n")
+		for _, doc := range docs {
+			fmt.Fprintf(&b, "| `%s` | **%s** | %s |\n", doc.Command, doc.Status, summary(doc.Summary))
+		}
+		b.WriteString("\n")
+	}
+
+	for _, doc := range docs {
+		if doc.Error != "" {
+			fmt.Fprintf(&b, "**`%s` failed:** %s\n\n", doc.Command, escape(doc.Error))
+		}
+
+		if len(doc.ValidationErrors) > 0 {
+			b.WriteString("#### Validation errors\n\n| Section | Message |\n|---|---|\n")
+			for _, verr := range doc.ValidationErrors {
+				fmt.Fprintf(&b, "| %s | %s |\n", verr.Section, escape(verr.Message))
+			}
+			b.WriteString("\n")
+		}
+
+		if len(doc.Changes) > 0 {
+			fmt.Fprintf(&b, "<details><summary>Resource changes (%d)</summary>\n\n", len(doc.Changes))
+			b.WriteString("| Operation | Type | Resource | Changed properties |\n|---|---|---|---|\n")
+			for _, change := range doc.Changes {
+				fmt.Fprintf(&b, "| %s | `%s` | `%s` | %s |\n",
+					change.Operation, change.Type, resourceName(change.URN), escape(strings.Join(change.Diffs, ", ")))
+			}
+			b.WriteString("\n</details>\n\n")
+		}
+
+		writeFindings(&b, "Drift findings", doc.Drift)
+	}
+
+	if r != nil {
+		writeFindings(&b, "Compliance findings", r.Findings)
+	}
+
+	fmt.Fprintf(&b, "<sub>Run `%s`</sub>\n", runid.ID())
+
+	body := b.String()
+	if len(body) > maxCommentLength {
+		// The comment is cut at the start of a character, never inside one
+		cut := maxCommentLength
+		for cut > 0 && !utf8.RuneStart(body[cut]) {
+			cut--
+		}
+		body = body[:cut] + "\n\n_Comment truncated, see the job output for the full results._\n"
+	}
+	return body
+}
+
+// writeFindings renders findings as a collapsible table, most severe first
+func writeFindings(b *strings.Builder, title string, findings []report.Finding) {
+	if len(findings) == 0 {
+		return
+	}
+
+	sorted := make([]report.Finding, len(findings))
+	copy(sorted, findings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return severityRank(sorted[i].Severity) < severityRank(sorted[j].Severity)
+	})
+
+	fmt.Fprintf(b, "<details><summary>%s (%d)</summary>\n\n", title, len(sorted))
+	b.WriteString("| Severity | Account | Check | Resource | Message |\n|---|---|---|---|---|\n")
+	for _, finding := range sorted {
+		fmt.Fprintf(b, "| %s | %s | %s | %s | %s |\n", finding.Severity, finding.AccountID, finding.Check,
+			escape(finding.Resource), escape(finding.Message))
+	}
+	b.WriteString("\n</details>\n\n")
+}
+
+// severityRank orders severities from the most to the least severe
+func severityRank(severity report.Severity) int {
+	switch severity {
+	case report.SeverityCritical:
+		return 0
+	case report.SeverityHigh:
+		return 1
+	case report.SeverityMedium:
+		return 2
+	case report.SeverityLow:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// summary renders the counters of a document in a stable order
+func summary(counters map[string]int) string {
+	if len(counters) == 0 {
+		return "-"
+	}
+
+	keys := make([]string, 0, len(counters))
+	for key := range counters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %d", key, counters[key]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// resourceName returns the type-qualified name at the end of a URN
+func resourceName(urn string) string {
+	if i := strings.LastIndex(urn, "::"); i >= 0 {
+		return urn[i+2:]
+	}
+	return urn
+}
+
+// escape keeps a value from breaking the markdown table it is rendered in
+func escape(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	return strings.ReplaceAll(value, "\n", " ")
+}
+
+// doJSON sends a JSON request to a code hosting API and decodes the response into out
+func doJSON(ctx context.Context, client *http.Client, method, url string, header http.Header, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s %s: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", method, url, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response of %s %s: %w", method, url, err)
+	}
+	return nil
+}
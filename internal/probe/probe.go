@@ -0,0 +1,162 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package probe provides the liveness and readiness endpoints of long-running modes.
+// Version: 1.0.0
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Paths of the probes
+	PathHealth = "/healthz"
+	PathReady  = "/readyz"
+
+	// EnvAddr sets the address the probes listen on
+	EnvAddr = "AWS_ORG_PROBE_ADDR"
+
+	// Time the server is given to answer the requests in flight when it stops
+	shutdownTimeout = 5 * time.Second
+
+	// Time a client may take to send the headers of a request
+	readHeaderTimeout = 5 * time.Second
+)
+
+// Probe reports whether a process is alive and ready for work. A process is alive
+// while every liveness check passes, and ready once marked ready, until it starts
+// draining.
+type Probe struct {
+	mutex    sync.RWMutex
+	ready    bool
+	draining bool
+	checks   map[string]func() error
+}
+
+// New creates a probe that is alive and not ready
+func New() *Probe {
+	return &Probe{checks: make(map[string]func() error)}
+}
+
+// AddCheck adds a liveness check. A failing check makes the process unhealthy, so
+// the orchestrator restarts it.
+func (p *Probe) AddCheck(name string, check func() error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.checks[name] = check
+}
+
+// SetReady marks the process ready or not for work
+func (p *Probe) SetReady(ready bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.ready = ready
+}
+
+// Drain marks the process as finishing its work before stopping, which makes it
+// unready for good
+func (p *Probe) Drain() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.draining = true
+}
+
+// Live returns the first failing liveness check, by name
+func (p *Probe) Live() error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	names := make([]string, 0, len(p.checks))
+	for name := range p.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := p.checks[name](); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Ready returns why the process is not ready for work, or nil
+func (p *Probe) Ready() error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	switch {
+	case p.draining:
+		return errors.New("draining")
+	case !p.ready:
+		return errors.New("starting")
+	}
+	return nil
+}
+
+// Handler returns the handler of the liveness and readiness endpoints
+func (p *Probe) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathHealth, func(w http.ResponseWriter, r *http.Request) {
+		respond(w, p.Live())
+	})
+	mux.HandleFunc(PathReady, func(w http.ResponseWriter, r *http.Request) {
+		respond(w, p.Ready())
+	})
+	return mux
+}
+
+// respond answers a probe with 200 when it passes, and 503 with the reason otherwise
+func respond(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// Serve answers the probes on addr until the context ends. It returns once the
+// listener is closed, or with the error that kept it from listening.
+func (p *Probe) Serve(ctx context.Context, addr string, logger *zap.Logger) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           p.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("failed to stop probe server", zap.Error(err))
+		}
+	}()
+
+	logger.Info("serving probes", zap.String("addr", listener.Addr().String()))
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("probe server failed: %w", err)
+	}
+	<-done
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package progress
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+)
+
+// Statuses of the resource events
+const (
+	StatusStarted = "started"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Event is the progress of a resource during an update. Duration is set once the
+// resource is done or failed.
+type Event struct {
+	RunID     string    `json:"runId"`
+	Stack     string    `json:"stack"`
+	Module    string    `json:"module"`
+	Resource  string    `json:"resource"`
+	Type      string    `json:"type"`
+	Operation string    `json:"operation"`
+	Status    string    `json:"status"`
+	Duration  int64     `json:"durationMs,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Timeline turns the engine events of the updates of a stack into resource events,
+// timing each resource from the start of its step to its outputs or its failure
+type Timeline struct {
+	stack   string
+	mutex   sync.Mutex
+	started map[string]time.Time
+}
+
+// NewTimeline creates the timeline of the updates of a stack
+func NewTimeline(stack string) *Timeline {
+	return &Timeline{stack: stack, started: make(map[string]time.Time)}
+}
+
+// Event returns the resource event of an engine event, and false for the engine events
+// that do not report the progress of a resource
+func (t *Timeline) Event(event events.EngineEvent) (Event, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now().UTC()
+	var e Event
+	switch {
+	case event.ResourcePreEvent != nil:
+		meta := event.ResourcePreEvent.Metadata
+		t.started[meta.URN] = now
+		e = Event{Resource: meta.URN, Type: meta.Type, Operation: string(meta.Op), Status: StatusStarted}
+	case event.ResOutputsEvent != nil:
+		meta := event.ResOutputsEvent.Metadata
+		e = Event{Resource: meta.URN, Type: meta.Type, Operation: string(meta.Op), Status: StatusDone}
+	case event.ResOpFailedEvent != nil:
+		meta := event.ResOpFailedEvent.Metadata
+		e = Event{Resource: meta.URN, Type: meta.Type, Operation: string(meta.Op), Status: StatusFailed}
+	default:
+		return Event{}, false
+	}
+
+	if e.Status != StatusStarted {
+		if start, ok := t.started[e.Resource]; ok {
+			e.Duration = now.Sub(start).Milliseconds()
+			delete(t.started, e.Resource)
+		}
+	}
+	e.RunID, e.Stack, e.Time = runid.ID(), t.stack, now
+	e.Module = ResourceModule(e.Resource, e.Type)
+	return e, true
+}
+
+// ResourceModule returns the module a resource belongs to: the module of the outermost
+// component of the landing zone among its parents, or the AWS service of its type for
+// the resources registered outside a component
+func ResourceModule(urn, resourceType string) string {
+	// urn:pulumi:<stack>::<project>::<parent types and type, separated by $>::<name>
+	parts := strings.SplitN(urn, "::", 4)
+	if len(parts) == 4 {
+		for _, token := range strings.Split(parts[2], "$") {
+			if module, ok := component.ModuleOf(token); ok {
+				return module
+			}
+		}
+	}
+	return ModuleOf(resourceType)
+}
@@ -0,0 +1,344 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package progress provides the user-facing progress of deployments, per module and per account.
+// Version: 1.0.0
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"go.uber.org/zap/zapcore"
+)
+
+// Mode selects how much a command prints
+type Mode int
+
+const (
+	// ModeNormal shows the progress of each module and account and informational logs
+	ModeNormal Mode = iota
+	// ModeQuiet shows warnings and errors only
+	ModeQuiet
+	// ModeVerbose shows the raw Pulumi output and debug logs
+	ModeVerbose
+)
+
+const (
+	// Interval between two frames of the spinner
+	frameInterval = 100 * time.Millisecond
+
+	// Type of the accounts, reported individually
+	accountType = "aws:organizations/account:Account"
+)
+
+// spinnerFrames are the frames of the spinner shown while resources are pending
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// ParseMode returns the mode selected by the --quiet and --verbose flags
+func ParseMode(quiet, verbose bool) (Mode, error) {
+	switch {
+	case quiet && verbose:
+		return ModeNormal, fmt.Errorf("--quiet and --verbose cannot be combined")
+	case quiet:
+		return ModeQuiet, nil
+	case verbose:
+		return ModeVerbose, nil
+	default:
+		return ModeNormal, nil
+	}
+}
+
+// Level returns the console log level of a mode
+func (m Mode) Level() zapcore.Level {
+	switch m {
+	case ModeQuiet:
+		return zapcore.WarnLevel
+	case ModeVerbose:
+		return zapcore.DebugLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+// module counts the resources of a module
+type module struct {
+	total  int
+	done   int
+	failed int
+}
+
+// Display renders the progress of a deployment from the events of the Pulumi engine.
+// On a terminal the status line is redrawn in place; otherwise only the completed
+// accounts and the summary are printed.
+type Display struct {
+	w       io.Writer
+	mode    Mode
+	tty     bool
+	mutex   sync.Mutex
+	modules map[string]*module
+	frame   int
+	width   int
+}
+
+// New creates a display writing to w in the given mode
+func New(w io.Writer, mode Mode) *Display {
+	return &Display{
+		w:    w,
+		mode: mode,
+		tty:  isTerminal(w),
+	}
+}
+
+// Mode returns the mode of the display
+func (d *Display) Mode() Mode {
+	return d.mode
+}
+
+// Output returns the writer the raw Pulumi output goes to, which is only shown in
+// verbose mode
+func (d *Display) Output(w io.Writer) io.Writer {
+	if d.mode == ModeVerbose {
+		return w
+	}
+	return io.Discard
+}
+
+// Track returns a channel receiving the engine events of an operation, and a function
+// to call once the operation returned, which stops reading and prints the summary. The
+// engine does not close the channel when the operation fails before it starts, so the
+// function does not wait for it. The channel is drained without output in quiet and
+// verbose modes.
+func (d *Display) Track(operation string) (chan events.EngineEvent, func()) {
+	eventCh := make(chan events.EngineEvent)
+	returned := make(chan struct{})
+	done := make(chan struct{})
+
+	d.mutex.Lock()
+	d.modules = make(map[string]*module)
+	d.frame = 0
+	d.mutex.Unlock()
+
+	show := d.mode == ModeNormal
+	stop := make(chan struct{})
+	if show && d.tty {
+		go d.spin(stop)
+	}
+
+	go func() {
+		defer close(done)
+		for {
+			// The channel is unbuffered, so every event was received once the
+			// operation returned
+			select {
+			case event, ok := <-eventCh:
+				if !ok {
+					return
+				}
+				if show {
+					d.handle(event)
+				}
+			case <-returned:
+				return
+			}
+		}
+	}()
+
+	return eventCh, func() {
+		close(returned)
+		<-done
+		close(stop)
+		if show {
+			d.summary(operation)
+		}
+	}
+}
+
+// handle counts a resource event, announcing completed accounts
+func (d *Display) handle(event events.EngineEvent) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	switch {
+	case event.ResourcePreEvent != nil:
+		meta := event.ResourcePreEvent.Metadata
+		if name := ModuleOf(meta.Type); name != "" {
+			d.module(name).total++
+		}
+	case event.ResOutputsEvent != nil:
+		meta := event.ResOutputsEvent.Metadata
+		name := ModuleOf(meta.Type)
+		if name == "" {
+			return
+		}
+		d.module(name).done++
+		if meta.Type == accountType && meta.Op != apitype.OpSame {
+			d.println(fmt.Sprintf("✓ account %s %s", resourceName(meta.URN), operationVerb(meta.Op)))
+		}
+	case event.ResOpFailedEvent != nil:
+		meta := event.ResOpFailedEvent.Metadata
+		name := ModuleOf(meta.Type)
+		if name == "" {
+			return
+		}
+		d.module(name).failed++
+		if meta.Type == accountType {
+			d.println(fmt.Sprintf("✗ account %s failed", resourceName(meta.URN)))
+		}
+	default:
+		return
+	}
+	d.render()
+}
+
+// spin redraws the status line until stop is closed, so the spinner turns while a
+// resource takes long
+func (d *Display) spin(stop <-chan struct{}) {
+	ticker := time.NewTicker(frameInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.mutex.Lock()
+			d.frame++
+			d.render()
+			d.mutex.Unlock()
+		}
+	}
+}
+
+// render redraws the status line on a terminal
+func (d *Display) render() {
+	if !d.tty || len(d.modules) == 0 {
+		return
+	}
+
+	parts := make([]string, 0, len(d.modules))
+	for _, name := range d.names() {
+		m := d.modules[name]
+		parts = append(parts, fmt.Sprintf("%s %d/%d", name, m.done, m.total))
+	}
+	line := fmt.Sprintf("%s %s", spinnerFrames[d.frame%len(spinnerFrames)], strings.Join(parts, " · "))
+	d.clear()
+	fmt.Fprint(d.w, line)
+	d.width = len([]rune(line))
+}
+
+// println prints a line above the status line
+func (d *Display) println(line string) {
+	d.clear()
+	fmt.Fprintln(d.w, line)
+}
+
+// clear erases the status line on a terminal
+func (d *Display) clear() {
+	if d.tty && d.width > 0 {
+		fmt.Fprintf(d.w, "\r%s\r", strings.Repeat(" ", d.width))
+		d.width = 0
+	}
+}
+
+// summary prints the outcome of every module
+func (d *Display) summary(operation string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.clear()
+	if len(d.modules) == 0 {
+		return
+	}
+
+	fmt.Fprintf(d.w, "%s:\n", operation)
+	for _, name := range d.names() {
+		m := d.modules[name]
+		mark := "✓"
+		if m.failed > 0 {
+			mark = "✗"
+		}
+		line := fmt.Sprintf("  %s %-20s %d/%d", mark, name, m.done, m.total)
+		if m.failed > 0 {
+			line += fmt.Sprintf(" (%d failed)", m.failed)
+		}
+		fmt.Fprintln(d.w, line)
+	}
+}
+
+// module returns the counts of a module, creating them on first use
+func (d *Display) module(name string) *module {
+	m, ok := d.modules[name]
+	if !ok {
+		m = &module{}
+		d.modules[name] = m
+	}
+	return m
+}
+
+// names returns the names of the modules in order
+func (d *Display) names() []string {
+	names := make([]string, 0, len(d.modules))
+	for name := range d.modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ModuleOf returns the module a resource type is reported under, which is the AWS
+// service of the type. Stacks, providers and component resources are not reported.
+func ModuleOf(resourceType string) string {
+	pkg, rest, ok := strings.Cut(resourceType, ":")
+	if !ok || pkg != "aws" {
+		return ""
+	}
+	service, _, _ := strings.Cut(rest, "/")
+	if service == "" || strings.Contains(service, ":") {
+		return ""
+	}
+	return service
+}
+
+// resourceName returns the name at the end of a URN
+func resourceName(urn string) string {
+	if i := strings.LastIndex(urn, "::"); i >= 0 {
+		return urn[i+2:]
+	}
+	return urn
+}
+
+// operationVerb returns the past tense of a resource operation
+func operationVerb(op apitype.OpType) string {
+	switch op {
+	case apitype.OpCreate:
+		return "created"
+	case apitype.OpDelete:
+		return "deleted"
+	case apitype.OpReplace, apitype.OpCreateReplacement:
+		return "replaced"
+	default:
+		return "updated"
+	}
+}
+
+// isTerminal reports whether w is a character device
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
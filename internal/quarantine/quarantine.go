@@ -0,0 +1,522 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package quarantine provides automated isolation of non-compliant accounts in a Quarantine OU.
+// Version: 1.0.0
+package quarantine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/configservice"
+	cstypes "github.com/aws/aws-sdk-go-v2/service/configservice/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+	shtypes "github.com/aws/aws-sdk-go-v2/service/securityhub/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/smithy-go/middleware"
+	"go.uber.org/zap"
+)
+
+// Action identifies the direction of a quarantine transition
+type Action string
+
+const (
+	// Transition actions
+	ActionQuarantine Action = "quarantine"
+	ActionRelease    Action = "release"
+
+	// Defaults applied when the configuration leaves them empty
+	DefaultOUName   = "Quarantine"
+	DefaultSeverity = "CRITICAL"
+
+	// OriginTagKey records the parent an account is released back to
+	OriginTagKey = "QuarantinedFrom"
+
+	// PolicyDescription describes the SCP attached to the Quarantine OU
+	PolicyDescription = "Denies all actions in quarantined accounts except for remediation roles"
+
+	// Roles still allowed to act in quarantined accounts, for remediation
+	controlTowerExecutionRole = "AWSControlTowerExecution"
+	organizationAccessRole    = "OrganizationAccountAccessRole"
+)
+
+// Transition represents a single move of an account into or out of quarantine
+type Transition struct {
+	AccountID   string   `json:"accountId"`
+	AccountName string   `json:"accountName"`
+	Action      Action   `json:"action"`
+	FromParent  string   `json:"fromParent"`
+	ToParent    string   `json:"toParent"`
+	Reasons     []string `json:"reasons,omitempty"`
+}
+
+// Manager evaluates compliance findings and moves accounts in and out of quarantine
+type Manager struct {
+	logger       *zap.Logger
+	metrics      *metrics.Collector
+	orgClient    *organizations.Client
+	hubClient    *securityhub.Client
+	configClient *configservice.Client
+	snsClient    *sns.Client
+}
+
+// NewManager creates a new quarantine manager instance
+func NewManager(ctx context.Context) (*Manager, error) {
+	logger, err := logging.NewLogger("quarantine")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("quarantine")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRetryMode(aws.RetryModeStandard),
+		awsconfig.WithRetryMaxAttempts(config.MaxRetries),
+		awsconfig.WithAPIOptions([]func(*middleware.Stack) error{awsclient.ClassifyErrors}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &Manager{
+		logger:       logger,
+		metrics:      metrics,
+		orgClient:    organizations.NewFromConfig(cfg),
+		hubClient:    securityhub.NewFromConfig(cfg),
+		configClient: configservice.NewFromConfig(cfg),
+		snsClient:    sns.NewFromConfig(cfg),
+	}, nil
+}
+
+// OUName returns the name of the Quarantine OU
+func OUName(cfg *config.QuarantineConfig) string {
+	if cfg == nil || cfg.OUName == "" {
+		return DefaultOUName
+	}
+	return cfg.OUName
+}
+
+// Severities returns the Security Hub severity labels that trigger a quarantine
+func Severities(cfg *config.QuarantineConfig) []string {
+	if cfg == nil || len(cfg.Severities) == 0 {
+		return []string{DefaultSeverity}
+	}
+	return cfg.Severities
+}
+
+// PolicyDocument returns the SCP attached to the Quarantine OU. Everything is denied
+// except for the roles used to remediate the account.
+func PolicyDocument() (string, error) {
+	document := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Sid":      "DenyAllExceptRemediation",
+				"Effect":   "Deny",
+				"Action":   "*",
+				"Resource": "*",
+				"Condition": map[string]interface{}{
+					"ArnNotLike": map[string]interface{}{
+						"aws:PrincipalArn": []string{
+							"arn:*:iam::*:role/" + controlTowerExecutionRole,
+							"arn:*:iam::*:role/" + organizationAccessRole,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal quarantine policy: %w", err)
+	}
+	return string(data), nil
+}
+
+// Evaluate compares the current findings with the content of the Quarantine OU and
+// returns the transitions needed to bring the organization in line
+func (m *Manager) Evaluate(ctx context.Context, cfg *config.LandingZoneConfig) ([]Transition, error) {
+	start := time.Now()
+	defer func() {
+		m.metrics.RecordDuration("evaluation_duration", time.Since(start))
+	}()
+
+	qcfg := cfg.Quarantine
+	if qcfg == nil || !qcfg.Enabled {
+		return nil, fmt.Errorf("quarantine is not enabled in the configuration")
+	}
+
+	quarantineOU, err := m.findQuarantineOU(ctx, OUName(qcfg))
+	if err != nil {
+		return nil, err
+	}
+
+	violations, err := m.collectViolations(ctx, qcfg)
+	if err != nil {
+		return nil, err
+	}
+
+	quarantined, err := m.listAccounts(ctx, quarantineOU)
+	if err != nil {
+		return nil, err
+	}
+
+	exempt := exemptAccounts(cfg)
+	var transitions []Transition
+
+	for _, accountID := range report.SortedKeys(violations) {
+		if _, ok := quarantined[accountID]; ok || exempt[accountID] {
+			continue
+		}
+
+		parent, err := m.parentOf(ctx, accountID)
+		if err != nil {
+			return nil, err
+		}
+		name, err := m.nameOf(ctx, accountID)
+		if err != nil {
+			return nil, err
+		}
+
+		transitions = append(transitions, Transition{
+			AccountID:   accountID,
+			AccountName: name,
+			Action:      ActionQuarantine,
+			FromParent:  parent,
+			ToParent:    quarantineOU,
+			Reasons:     violations[accountID],
+		})
+	}
+
+	for _, accountID := range report.SortedKeys(quarantined) {
+		if _, ok := violations[accountID]; ok {
+			continue
+		}
+
+		origin, err := m.originOf(ctx, accountID)
+		if err != nil {
+			return nil, err
+		}
+		if origin == "" {
+			m.logger.Warn("compliant account has no origin tag, leaving it in quarantine",
+				zap.String("accountId", accountID))
+			continue
+		}
+
+		transitions = append(transitions, Transition{
+			AccountID:   accountID,
+			AccountName: quarantined[accountID],
+			Action:      ActionRelease,
+			FromParent:  quarantineOU,
+			ToParent:    origin,
+		})
+	}
+
+	m.logger.Info("quarantine evaluation completed",
+		zap.Int("violatingAccounts", len(violations)),
+		zap.Int("quarantinedAccounts", len(quarantined)),
+		zap.Int("transitions", len(transitions)))
+
+	return transitions, nil
+}
+
+// Apply performs the transitions and sends a notification for each of them
+func (m *Manager) Apply(ctx context.Context, cfg *config.LandingZoneConfig, transitions []Transition) error {
+	if err := readonly.Check("quarantine accounts"); err != nil {
+		return err
+	}
+
+	for _, t := range transitions {
+		var err error
+		switch t.Action {
+		case ActionQuarantine:
+			err = m.quarantine(ctx, t)
+		case ActionRelease:
+			err = m.release(ctx, t)
+		default:
+			err = fmt.Errorf("unknown quarantine action %q", t.Action)
+		}
+		if err != nil {
+			return err
+		}
+
+		m.metrics.IncrementCounter(fmt.Sprintf("accounts_%s", t.Action))
+		m.logger.Info("account quarantine transition applied",
+			zap.String("accountId", t.AccountID),
+			zap.String("action", string(t.Action)),
+			zap.String("from", t.FromParent),
+			zap.String("to", t.ToParent))
+
+		if err := m.notify(ctx, cfg.Quarantine, t); err != nil {
+			// The move succeeded, so a failed notification must not abort the run
+			m.logger.Error("failed to send quarantine notification",
+				zap.String("accountId", t.AccountID),
+				zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// quarantine records the current parent of the account and moves it to the Quarantine OU
+func (m *Manager) quarantine(ctx context.Context, t Transition) error {
+	if _, err := m.orgClient.TagResource(ctx, &organizations.TagResourceInput{
+		ResourceId: aws.String(t.AccountID),
+		Tags: []orgtypes.Tag{
+			{Key: aws.String(OriginTagKey), Value: aws.String(t.FromParent)},
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to tag account %s: %w", t.AccountID, err)
+	}
+
+	if _, err := m.orgClient.MoveAccount(ctx, &organizations.MoveAccountInput{
+		AccountId:           aws.String(t.AccountID),
+		SourceParentId:      aws.String(t.FromParent),
+		DestinationParentId: aws.String(t.ToParent),
+	}); err != nil {
+		return fmt.Errorf("failed to move account %s to quarantine: %w", t.AccountID, err)
+	}
+
+	return nil
+}
+
+// release moves the account back to its original parent and removes the origin tag
+func (m *Manager) release(ctx context.Context, t Transition) error {
+	if _, err := m.orgClient.MoveAccount(ctx, &organizations.MoveAccountInput{
+		AccountId:           aws.String(t.AccountID),
+		SourceParentId:      aws.String(t.FromParent),
+		DestinationParentId: aws.String(t.ToParent),
+	}); err != nil {
+		return fmt.Errorf("failed to release account %s from quarantine: %w", t.AccountID, err)
+	}
+
+	if _, err := m.orgClient.UntagResource(ctx, &organizations.UntagResourceInput{
+		ResourceId: aws.String(t.AccountID),
+		TagKeys:    []string{OriginTagKey},
+	}); err != nil {
+		return fmt.Errorf("failed to untag account %s: %w", t.AccountID, err)
+	}
+
+	return nil
+}
+
+// notify publishes a transition to the configured SNS topic
+func (m *Manager) notify(ctx context.Context, cfg *config.QuarantineConfig, t Transition) error {
+	if cfg == nil || cfg.NotificationTopicArn == "" {
+		return nil
+	}
+
+	message, err := json.Marshal(struct {
+		Transition
+		RunID string `json:"runId"`
+	}{t, runid.ID()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	_, err = m.snsClient.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(cfg.NotificationTopicArn),
+		Subject:  aws.String(fmt.Sprintf("Account %s: %s", t.AccountID, t.Action)),
+		Message:  aws.String(string(message)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish notification: %w", err)
+	}
+	return nil
+}
+
+// collectViolations returns the reasons each account is non-compliant, keyed by account ID
+func (m *Manager) collectViolations(ctx context.Context, cfg *config.QuarantineConfig) (map[string][]string, error) {
+	violations := make(map[string][]string)
+
+	var severities []shtypes.StringFilter
+	for _, severity := range Severities(cfg) {
+		severities = append(severities, shtypes.StringFilter{
+			Comparison: shtypes.StringFilterComparisonEquals,
+			Value:      aws.String(severity),
+		})
+	}
+
+	findings := securityhub.NewGetFindingsPaginator(m.hubClient, &securityhub.GetFindingsInput{
+		Filters: &shtypes.AwsSecurityFindingFilters{
+			SeverityLabel: severities,
+			RecordState: []shtypes.StringFilter{
+				{Comparison: shtypes.StringFilterComparisonEquals, Value: aws.String("ACTIVE")},
+			},
+			WorkflowStatus: []shtypes.StringFilter{
+				{Comparison: shtypes.StringFilterComparisonEquals, Value: aws.String("NEW")},
+				{Comparison: shtypes.StringFilterComparisonEquals, Value: aws.String("NOTIFIED")},
+			},
+		},
+	})
+	for findings.HasMorePages() {
+		page, err := findings.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Security Hub findings: %w", err)
+		}
+		for _, finding := range page.Findings {
+			accountID := aws.ToString(finding.AwsAccountId)
+			violations[accountID] = append(violations[accountID],
+				fmt.Sprintf("securityhub: %s", aws.ToString(finding.Title)))
+		}
+	}
+
+	if len(cfg.CriticalConfigRules) == 0 {
+		return violations, nil
+	}
+
+	critical := make(map[string]bool)
+	for _, rule := range cfg.CriticalConfigRules {
+		critical[rule] = true
+	}
+
+	compliance := configservice.NewDescribeAggregateComplianceByConfigRulesPaginator(m.configClient,
+		&configservice.DescribeAggregateComplianceByConfigRulesInput{
+			ConfigurationAggregatorName: aws.String(cfg.ConfigAggregatorName),
+			Filters: &cstypes.ConfigRuleComplianceFilters{
+				ComplianceType: cstypes.ComplianceTypeNonCompliant,
+			},
+		})
+	for compliance.HasMorePages() {
+		page, err := compliance.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe Config rule compliance: %w", err)
+		}
+		for _, result := range page.AggregateComplianceByConfigRules {
+			rule := aws.ToString(result.ConfigRuleName)
+			if !critical[rule] {
+				continue
+			}
+			accountID := aws.ToString(result.AccountId)
+			violations[accountID] = append(violations[accountID],
+				fmt.Sprintf("config: %s (%s)", rule, aws.ToString(result.AwsRegion)))
+		}
+	}
+
+	return violations, nil
+}
+
+// findQuarantineOU returns the ID of the top-level OU with the given name
+func (m *Manager) findQuarantineOU(ctx context.Context, name string) (string, error) {
+	roots, err := m.orgClient.ListRoots(ctx, &organizations.ListRootsInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list roots: %w", err)
+	}
+	if len(roots.Roots) == 0 {
+		return "", fmt.Errorf("organization has no root")
+	}
+
+	paginator := organizations.NewListOrganizationalUnitsForParentPaginator(m.orgClient,
+		&organizations.ListOrganizationalUnitsForParentInput{ParentId: roots.Roots[0].Id})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list organizational units: %w", err)
+		}
+		for _, ou := range page.OrganizationalUnits {
+			if aws.ToString(ou.Name) == name {
+				return aws.ToString(ou.Id), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("quarantine OU %s not found, deploy the organization module first", name)
+}
+
+// listAccounts returns the names of the accounts directly under a parent, keyed by account ID
+func (m *Manager) listAccounts(ctx context.Context, parentID string) (map[string]string, error) {
+	accounts := make(map[string]string)
+
+	paginator := organizations.NewListAccountsForParentPaginator(m.orgClient,
+		&organizations.ListAccountsForParentInput{ParentId: aws.String(parentID)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list accounts for %s: %w", parentID, err)
+		}
+		for _, account := range page.Accounts {
+			accounts[aws.ToString(account.Id)] = aws.ToString(account.Name)
+		}
+	}
+
+	return accounts, nil
+}
+
+// parentOf returns the ID of the current parent of an account
+func (m *Manager) parentOf(ctx context.Context, accountID string) (string, error) {
+	out, err := m.orgClient.ListParents(ctx, &organizations.ListParentsInput{
+		ChildId: aws.String(accountID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get parent of account %s: %w", accountID, err)
+	}
+	if len(out.Parents) == 0 {
+		return "", fmt.Errorf("account %s has no parent", accountID)
+	}
+	return aws.ToString(out.Parents[0].Id), nil
+}
+
+// nameOf returns the name of an account
+func (m *Manager) nameOf(ctx context.Context, accountID string) (string, error) {
+	out, err := m.orgClient.DescribeAccount(ctx, &organizations.DescribeAccountInput{
+		AccountId: aws.String(accountID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe account %s: %w", accountID, err)
+	}
+	return aws.ToString(out.Account.Name), nil
+}
+
+// originOf returns the parent recorded when the account was quarantined
+func (m *Manager) originOf(ctx context.Context, accountID string) (string, error) {
+	paginator := organizations.NewListTagsForResourcePaginator(m.orgClient,
+		&organizations.ListTagsForResourceInput{ResourceId: aws.String(accountID)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list tags of account %s: %w", accountID, err)
+		}
+		for _, tag := range page.Tags {
+			if aws.ToString(tag.Key) == OriginTagKey {
+				return aws.ToString(tag.Value), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// exemptAccounts returns the accounts that are never quarantined. The core accounts
+// of the landing zone are always exempt.
+func exemptAccounts(cfg *config.LandingZoneConfig) map[string]bool {
+	exempt := map[string]bool{
+		cfg.ManagementAccountId: true,
+		cfg.LogArchiveAccountId: true,
+		cfg.AuditAccountId:      true,
+		cfg.SecurityAccountId:   true,
+	}
+	if cfg.Quarantine != nil {
+		for _, id := range cfg.Quarantine.ExemptAccountIds {
+			exempt[id] = true
+		}
+	}
+	return exempt
+}
@@ -0,0 +1,509 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package quotas provides the service quota increases requested in the accounts of the
+// organization and the tracking of their status in the state.
+// Version: 1.0.0
+package quotas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	sqtypes "github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// RecordName is the name of the record of the accounts with quota increase requests
+	// in the state. The requests of each account are recorded apart, so accounts vended
+	// at the same time do not overwrite each other's requests.
+	RecordName = "service-quota-requests"
+
+	// StatusSatisfied is recorded for quotas whose applied value already meets the target
+	StatusSatisfied = "SATISFIED"
+)
+
+// Store persists the quota increase requests
+type Store interface {
+	SaveRecord(ctx context.Context, name string, record interface{}) error
+	LoadRecord(ctx context.Context, name string, record interface{}) (bool, error)
+}
+
+// Index lists the accounts whose quota increase requests are recorded
+type Index struct {
+	Accounts []string `json:"accounts"`
+}
+
+// Record holds the quota increase requests of an account, keyed by account, region and
+// quota
+type Record struct {
+	UpdatedAt time.Time           `json:"updatedAt"`
+	Requests  map[string]*Request `json:"requests"`
+}
+
+// Request is the increase of a service quota requested in an account and region
+type Request struct {
+	AccountId    string    `json:"accountId"`
+	Region       string    `json:"region"`
+	ServiceCode  string    `json:"serviceCode"`
+	QuotaCode    string    `json:"quotaCode"`
+	Description  string    `json:"description,omitempty"`
+	CurrentValue float64   `json:"currentValue"`
+	DesiredValue float64   `json:"desiredValue"`
+	Global       bool      `json:"global,omitempty"`
+	RequestId    string    `json:"requestId,omitempty"`
+	CaseId       string    `json:"caseId,omitempty"`
+	Status       string    `json:"status"`
+	RequestedAt  time.Time `json:"requestedAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// Open reports whether Service Quotas has not decided on the request yet
+func (r *Request) Open() bool {
+	switch sqtypes.RequestStatus(r.Status) {
+	case sqtypes.RequestStatusPending, sqtypes.RequestStatusCaseOpened:
+		return true
+	default:
+		return false
+	}
+}
+
+// key returns the key of the request in the record
+func (r *Request) key() string {
+	return requestKey(r.AccountId, r.Region, r.ServiceCode, r.QuotaCode)
+}
+
+// requestKey returns the key of the request of a quota of an account and region
+func requestKey(accountId, region, serviceCode, quotaCode string) string {
+	return strings.Join([]string{accountId, region, serviceCode, quotaCode}, "/")
+}
+
+// Requester requests the increases of the service quota targets through the member role
+// of the accounts
+type Requester struct {
+	logger   *zap.Logger
+	metrics  *metrics.Collector
+	base     aws.Config
+	cfg      *config.LandingZoneConfig
+	roleName string
+	store    Store
+
+	// Credentials of the accounts, shared by the concurrent steps, and the index
+	mutex    sync.Mutex
+	accounts map[string]aws.Config
+}
+
+// NewRequester creates a requester using the credentials of the management account,
+// recording the requests in the given store
+func NewRequester(ctx context.Context, cfg *config.LandingZoneConfig, store Store) (*Requester, error) {
+	logger, err := logging.NewLogger("quotas")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("quotas")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Requester{
+		logger:   logger,
+		metrics:  metrics,
+		base:     base,
+		cfg:      cfg,
+		roleName: awsclient.MemberRoleName(cfg),
+		store:    store,
+		accounts: make(map[string]aws.Config),
+	}, nil
+}
+
+// Apply requests the increases of the quota targets of an account of an OU whose
+// applied value is below the target, and records them. A quota with an increase
+// already open, recorded or not, is not requested again, so Apply can be retried.
+func (r *Requester) Apply(ctx context.Context, accountId, ou string) ([]*Request, error) {
+	targets := r.cfg.ServiceQuotaTargets(accountId, ou)
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	if err := readonly.Check("request service quota increases"); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer func() {
+		r.metrics.RecordDuration("quota_requests", time.Since(start))
+	}()
+
+	record, err := r.load(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	var requests []*Request
+	var failures []string
+	for _, target := range targets {
+		for _, region := range r.cfg.ServiceQuotaRegions(target) {
+			request, global, err := r.apply(ctx, record, accountId, region, target)
+			if err != nil {
+				failures = append(failures, err.Error())
+				continue
+			}
+			record.Requests[request.key()] = request
+			requests = append(requests, request)
+
+			// Global quotas hold a single value for every region
+			if global {
+				break
+			}
+		}
+	}
+
+	if err := r.save(ctx, accountId, record); err != nil {
+		return nil, err
+	}
+	r.logger.Info("service quota targets applied",
+		zap.String("accountId", accountId),
+		zap.Int("requests", len(requests)),
+		zap.Int("failed", len(failures)))
+
+	if len(failures) > 0 {
+		return requests, errors.New(strings.Join(failures, "; "))
+	}
+	return requests, nil
+}
+
+// apply requests the increase of a quota target in an account and region when its
+// applied value is below the target. It reports whether the quota is global.
+func (r *Requester) apply(ctx context.Context, record *Record, accountId, region string, target config.ServiceQuotaTarget) (*Request, bool, error) {
+	key := requestKey(accountId, region, target.ServiceCode, target.QuotaCode)
+	if existing, ok := record.Requests[key]; ok && existing.Open() && existing.DesiredValue >= target.Value {
+		return existing, existing.Global, nil
+	}
+
+	client := r.client(accountId, region)
+	value, global, err := appliedValue(ctx, client, target)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read quota %s of account %s in %s: %w", target.Key(), accountId, region, err)
+	}
+
+	now := time.Now().UTC()
+	request := &Request{
+		AccountId:    accountId,
+		Region:       region,
+		ServiceCode:  target.ServiceCode,
+		QuotaCode:    target.QuotaCode,
+		Description:  target.Description,
+		CurrentValue: value,
+		DesiredValue: target.Value,
+		Global:       global,
+		Status:       StatusSatisfied,
+		UpdatedAt:    now,
+	}
+	if value >= target.Value {
+		return request, global, nil
+	}
+
+	open, err := openChange(ctx, client, target)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list the increases of quota %s of account %s in %s: %w", target.Key(), accountId, region, err)
+	}
+	if open == nil {
+		out, err := client.RequestServiceQuotaIncrease(ctx, &servicequotas.RequestServiceQuotaIncreaseInput{
+			ServiceCode:  aws.String(target.ServiceCode),
+			QuotaCode:    aws.String(target.QuotaCode),
+			DesiredValue: aws.Float64(target.Value),
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to request increase of quota %s of account %s in %s: %w", target.Key(), accountId, region, err)
+		}
+		open = out.RequestedQuota
+		r.metrics.IncrementCounter("quota_increases_requested")
+		r.logger.Info("service quota increase requested",
+			zap.String("accountId", accountId),
+			zap.String("region", region),
+			zap.String("quota", target.Key()),
+			zap.Float64("value", target.Value))
+	}
+
+	request.update(open)
+	if request.RequestedAt.IsZero() {
+		request.RequestedAt = now
+	}
+	return request, global, nil
+}
+
+// Refresh reads the status of the open requests from Service Quotas, records it and
+// returns every recorded request
+func (r *Requester) Refresh(ctx context.Context) ([]*Request, error) {
+	index, err := r.loadIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	all := &Record{Requests: make(map[string]*Request)}
+	var failures []string
+	for _, accountId := range index.Accounts {
+		record, err := r.load(ctx, accountId)
+		if err != nil {
+			return nil, err
+		}
+
+		changed := false
+		for key, request := range record.Requests {
+			all.Requests[key] = request
+			if !request.Open() || request.RequestId == "" {
+				continue
+			}
+			out, err := r.client(request.AccountId, request.Region).GetRequestedServiceQuotaChange(ctx,
+				&servicequotas.GetRequestedServiceQuotaChangeInput{RequestId: aws.String(request.RequestId)})
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("failed to read quota increase request %s of account %s: %s",
+					request.RequestId, request.AccountId, err))
+				continue
+			}
+			request.update(out.RequestedQuota)
+			changed = true
+		}
+
+		if changed {
+			if err := r.save(ctx, accountId, record); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	requests := Sorted(all)
+	if len(failures) > 0 {
+		return requests, errors.New(strings.Join(failures, "; "))
+	}
+	return requests, nil
+}
+
+// Load returns the recorded requests
+func (r *Requester) Load(ctx context.Context) ([]*Request, error) {
+	index, err := r.loadIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	all := &Record{Requests: make(map[string]*Request)}
+	for _, accountId := range index.Accounts {
+		record, err := r.load(ctx, accountId)
+		if err != nil {
+			return nil, err
+		}
+		for key, request := range record.Requests {
+			all.Requests[key] = request
+		}
+	}
+	return Sorted(all), nil
+}
+
+// update copies the status of a requested quota change into a request
+func (r *Request) update(change *sqtypes.RequestedServiceQuotaChange) {
+	if change == nil {
+		return
+	}
+	r.RequestId = aws.ToString(change.Id)
+	r.CaseId = aws.ToString(change.CaseId)
+	r.Status = string(change.Status)
+	r.UpdatedAt = time.Now().UTC()
+	if change.DesiredValue != nil {
+		r.DesiredValue = aws.ToFloat64(change.DesiredValue)
+	}
+	if change.Created != nil {
+		r.RequestedAt = change.Created.UTC()
+	}
+}
+
+// appliedValue returns the value of a quota in an account and region, or its default
+// value when it was never changed, and whether the quota is global
+func appliedValue(ctx context.Context, client *servicequotas.Client, target config.ServiceQuotaTarget) (float64, bool, error) {
+	out, err := client.GetServiceQuota(ctx, &servicequotas.GetServiceQuotaInput{
+		ServiceCode: aws.String(target.ServiceCode),
+		QuotaCode:   aws.String(target.QuotaCode),
+	})
+	if err == nil {
+		return aws.ToFloat64(out.Quota.Value), out.Quota.GlobalQuota, nil
+	}
+
+	var notFound *sqtypes.NoSuchResourceException
+	if !errors.As(err, &notFound) {
+		return 0, false, err
+	}
+	defaults, err := client.GetAWSDefaultServiceQuota(ctx, &servicequotas.GetAWSDefaultServiceQuotaInput{
+		ServiceCode: aws.String(target.ServiceCode),
+		QuotaCode:   aws.String(target.QuotaCode),
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return aws.ToFloat64(defaults.Quota.Value), defaults.Quota.GlobalQuota, nil
+}
+
+// openChange returns the increase of a quota still open in an account and region, or
+// nil when there is none
+func openChange(ctx context.Context, client *servicequotas.Client, target config.ServiceQuotaTarget) (*sqtypes.RequestedServiceQuotaChange, error) {
+	for _, status := range []sqtypes.RequestStatus{sqtypes.RequestStatusPending, sqtypes.RequestStatusCaseOpened} {
+		paginator := servicequotas.NewListRequestedServiceQuotaChangeHistoryByQuotaPaginator(client,
+			&servicequotas.ListRequestedServiceQuotaChangeHistoryByQuotaInput{
+				ServiceCode: aws.String(target.ServiceCode),
+				QuotaCode:   aws.String(target.QuotaCode),
+				Status:      status,
+			})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if len(page.RequestedQuotas) > 0 {
+				return &page.RequestedQuotas[0], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// client returns a Service Quotas client of an account and region, through the member
+// role of the account
+func (r *Requester) client(accountId, region string) *servicequotas.Client {
+	r.mutex.Lock()
+	cfg, ok := r.accounts[accountId]
+	if !ok {
+		cfg = awsclient.AssumeRole(r.base, accountId, r.roleName)
+		r.accounts[accountId] = cfg
+	}
+	r.mutex.Unlock()
+
+	return servicequotas.NewFromConfig(cfg, func(o *servicequotas.Options) {
+		o.Region = region
+	})
+}
+
+// accountRecordName returns the name of the record of the requests of an account
+func accountRecordName(accountId string) string {
+	return RecordName + "-" + accountId
+}
+
+// loadIndex returns the record of the accounts with requests
+func (r *Requester) loadIndex(ctx context.Context) (*Index, error) {
+	index := &Index{}
+	if _, err := r.store.LoadRecord(ctx, RecordName, index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// load returns the record of the requests of an account
+func (r *Requester) load(ctx context.Context, accountId string) (*Record, error) {
+	record := &Record{}
+	if _, err := r.store.LoadRecord(ctx, accountRecordName(accountId), record); err != nil {
+		return nil, err
+	}
+	if record.Requests == nil {
+		record.Requests = make(map[string]*Request)
+	}
+	return record, nil
+}
+
+// save records the requests of an account, and adds the account to the index the
+// first time
+func (r *Requester) save(ctx context.Context, accountId string, record *Record) error {
+	record.UpdatedAt = time.Now().UTC()
+	if err := r.store.SaveRecord(ctx, accountRecordName(accountId), record); err != nil {
+		return fmt.Errorf("failed to record service quota requests of account %s: %w", accountId, err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	index, err := r.loadIndex(ctx)
+	if err != nil {
+		return err
+	}
+	i := sort.SearchStrings(index.Accounts, accountId)
+	if i < len(index.Accounts) && index.Accounts[i] == accountId {
+		return nil
+	}
+	index.Accounts = append(index.Accounts, "")
+	copy(index.Accounts[i+1:], index.Accounts[i:])
+	index.Accounts[i] = accountId
+
+	if err := r.store.SaveRecord(ctx, RecordName, index); err != nil {
+		return fmt.Errorf("failed to record service quota accounts: %w", err)
+	}
+	return nil
+}
+
+// Sorted returns the requests of a record ordered by account, region and quota
+func Sorted(record *Record) []*Request {
+	keys := make([]string, 0, len(record.Requests))
+	for key := range record.Requests {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	requests := make([]*Request, 0, len(keys))
+	for _, key := range keys {
+		requests = append(requests, record.Requests[key])
+	}
+	return requests
+}
+
+// Write writes the requests as a table or as JSON
+func Write(w io.Writer, format string, requests []*Request) error {
+	switch format {
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(requests); err != nil {
+			return fmt.Errorf("failed to encode service quota requests: %w", err)
+		}
+		return nil
+	case report.FormatText:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ACCOUNT\tREGION\tQUOTA\tCURRENT\tDESIRED\tSTATUS\tCASE")
+		for _, request := range requests {
+			quota := request.ServiceCode + "/" + request.QuotaCode
+			if request.Description != "" {
+				quota += " (" + request.Description + ")"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				request.AccountId, request.Region, quota,
+				formatValue(request.CurrentValue), formatValue(request.DesiredValue),
+				request.Status, report.OrDash(request.CaseId))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// formatValue renders a quota value without trailing zeros
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package readonly provides a process-wide guard that blocks every mutating operation.
+// Version: 1.0.0
+package readonly
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ErrReadOnly is returned by every operation that would create or modify resources
+// while read-only mode is enabled
+var ErrReadOnly = errors.New("operation not permitted in read-only mode")
+
+var enabled atomic.Bool
+
+// Enable turns on read-only mode for the lifetime of the process
+func Enable() {
+	enabled.Store(true)
+}
+
+// Enabled reports whether read-only mode is active
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Check returns ErrReadOnly wrapped with the operation name when read-only mode is active
+func Check(operation string) error {
+	if !Enabled() {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", operation, ErrReadOnly)
+}
+
+// Guard behaves like Check for Pulumi programs. Registering resources during a preview
+// does not mutate anything, so the guard only trips when the engine is applying changes.
+func Guard(ctx *pulumi.Context, operation string) error {
+	if ctx != nil && ctx.DryRun() {
+		return nil
+	}
+	return Check(operation)
+}
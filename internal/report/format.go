@@ -0,0 +1,25 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package report
+
+import "sort"
+
+// OrDash returns a placeholder for empty table cells
+func OrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// SortedKeys returns the keys of a map in a stable order
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package report provides the drift and compliance report of the organization.
+// Version: 1.0.0
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+)
+
+// Severity represents the severity of a finding
+type Severity string
+
+const (
+	// Finding severities
+	SeverityCritical Severity = "CRITICAL"
+	SeverityHigh     Severity = "HIGH"
+	SeverityMedium   Severity = "MEDIUM"
+	SeverityLow      Severity = "LOW"
+	SeverityInfo     Severity = "INFO"
+
+	// Output formats
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Finding represents a single drift or compliance issue
+type Finding struct {
+	AccountID string   `json:"accountId"`
+	Check     string   `json:"check"`
+	Severity  Severity `json:"severity"`
+	Resource  string   `json:"resource,omitempty"`
+	Message   string   `json:"message"`
+}
+
+// Report collects findings from the checks of a run
+type Report struct {
+	RunID       string    `json:"runId"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	Findings    []Finding `json:"findings"`
+
+	// Attributes holds the custom attributes of the accounts, keyed by account ID
+	Attributes map[string]map[string]string `json:"accountAttributes,omitempty"`
+	mutex      sync.Mutex
+}
+
+// New creates an empty report
+func New() *Report {
+	return &Report{
+		RunID:       runid.ID(),
+		GeneratedAt: time.Now().UTC(),
+		Findings:    []Finding{},
+	}
+}
+
+// Add appends findings to the report. It is safe for concurrent use.
+func (r *Report) Add(findings ...Finding) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Findings = append(r.Findings, findings...)
+}
+
+// SetAttributes records the custom attributes of an account. It is safe for
+// concurrent use.
+func (r *Report) SetAttributes(accountID string, attributes map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.Attributes == nil {
+		r.Attributes = make(map[string]map[string]string)
+	}
+	r.Attributes[accountID] = attributes
+}
+
+// ByAccount returns the findings grouped by account ID
+func (r *Report) ByAccount() map[string][]Finding {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	grouped := make(map[string][]Finding)
+	for _, finding := range r.Findings {
+		grouped[finding.AccountID] = append(grouped[finding.AccountID], finding)
+	}
+	return grouped
+}
+
+// Write renders the report in the given format
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		return r.writeJSON(w)
+	case FormatText, "":
+		return r.writeText(w)
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+}
+
+// writeJSON renders the report as indented JSON
+func (r *Report) writeJSON(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	return nil
+}
+
+// writeText renders the report as a table grouped by account
+func (r *Report) writeText(w io.Writer) error {
+	grouped := r.ByAccount()
+	accounts := make([]string, 0, len(grouped))
+	for account := range grouped {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	total := 0
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, account := range accounts {
+		total += len(grouped[account])
+		fmt.Fprintf(tw, "Account %s%s\n", account, r.attributeSuffix(account))
+		for _, finding := range grouped[account] {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", finding.Severity, finding.Check, finding.Resource, finding.Message)
+		}
+	}
+	fmt.Fprintf(tw, "\n%d findings in %d accounts (run %s)\n", total, len(accounts), r.RunID)
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// attributeSuffix renders the attributes of an account after its ID
+func (r *Report) attributeSuffix(accountID string) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	attributes := r.Attributes[accountID]
+	if len(attributes) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(attributes))
+	for name, value := range attributes {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return " (" + strings.Join(pairs, ", ") + ")"
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package rollout provides the staged rollout of the policies and account baselines:
+// the accounts each wave reaches, the resources held back from the accounts the
+// rollout has not reached yet, and the watch of the alarms and drift halting the
+// rollout between waves.
+// Version: 1.0.0
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/invitations"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/stacksets"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"go.uber.org/zap"
+)
+
+// ErrHalted is returned when the alarms or the drift halt a rollout
+var ErrHalted = errors.New("rollout halted")
+
+// Prefix of the types of the resources of the organization, staged by the policies
+// module rather than held back per account
+const organizationsTypePrefix = "aws:organizations/"
+
+var accountIdRE = regexp.MustCompile(`^\d{12}$`)
+
+// Accounts resolves the targets of the waves to the accounts they reach
+type Accounts struct {
+	cfg       *config.LandingZoneConfig
+	orgClient *organizations.Client
+	cache     *orgcache.Cache
+}
+
+// NewAccounts creates a resolver of the accounts of the targets in the live
+// organization
+func NewAccounts(ctx context.Context, cfg *config.LandingZoneConfig) (*Accounts, error) {
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := orgcache.New(ctx, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Accounts{cfg: cfg, orgClient: organizations.NewFromConfig(base), cache: cache}, nil
+}
+
+// Reached returns the IDs of the accounts the targets reach: the account targets and
+// the accounts of the OUs and of the OUs nested under them
+func (a *Accounts) Reached(ctx context.Context, targets []string) (map[string]bool, error) {
+	reached := make(map[string]bool)
+	var names []string
+	for _, target := range targets {
+		if accountIdRE.MatchString(target) {
+			reached[target] = true
+			continue
+		}
+		names = append(names, invitations.OUName(a.cfg, target))
+	}
+	if len(names) == 0 {
+		return reached, nil
+	}
+
+	ous, err := a.cache.OUs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	within := make(map[string]bool)
+	for _, name := range names {
+		found := false
+		for _, ou := range ous {
+			if ou.Name == name {
+				within[ou.ID] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("OU %s not found", name)
+		}
+	}
+	// OUs are listed parents before their children
+	for _, ou := range ous {
+		if within[ou.ParentID] {
+			within[ou.ID] = true
+		}
+	}
+
+	for id := range within {
+		paginator := organizations.NewListAccountsForParentPaginator(a.orgClient,
+			&organizations.ListAccountsForParentInput{ParentId: aws.String(id)})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list accounts of OU %s: %w", id, err)
+			}
+			for _, account := range page.Accounts {
+				reached[aws.ToString(account.Id)] = true
+			}
+		}
+	}
+	return reached, nil
+}
+
+// Hold returns the function holding back the changes of the resources of the accounts
+// a stage does not reach. Resources are attributed to the account whose ID is part of
+// their name; the policies, staged by the policies module, are never held back.
+func Hold(reached map[string]bool) func(urn, resourceType string) bool {
+	return func(urn, resourceType string) bool {
+		if strings.HasPrefix(resourceType, organizationsTypePrefix) {
+			return false
+		}
+		account := AccountOf(urn)
+		return account != "" && !reached[account]
+	}
+}
+
+// AccountOf returns the account ID in the name of a resource, or an empty string when
+// the resource does not belong to a member account
+func AccountOf(urn string) string {
+	name := urn
+	if i := strings.LastIndex(urn, "::"); i >= 0 {
+		name = urn[i+2:]
+	}
+	for _, part := range strings.Split(name, "-") {
+		if accountIdRE.MatchString(part) {
+			return part
+		}
+	}
+	return ""
+}
+
+// Watcher watches the alarms and the drift of a rollout
+type Watcher struct {
+	logger   *zap.Logger
+	metrics  *metrics.Collector
+	cfg      *config.RolloutConfig
+	cw       *cloudwatch.Client
+	detector *stacksets.Detector
+	drift    int
+}
+
+// NewWatcher creates a watcher of the alarms and drift configured for the rollout
+func NewWatcher(ctx context.Context, lz *config.LandingZoneConfig) (*Watcher, error) {
+	logger, err := logging.NewLogger("rollout")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("rollout")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	w := &Watcher{logger: logger, metrics: metrics, cfg: lz.Rollout}
+	if len(w.cfg.Alarms) > 0 {
+		base, err := awsclient.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		w.cw = cloudwatch.NewFromConfig(base, func(o *cloudwatch.Options) {
+			if lz.HomeRegion != "" {
+				o.Region = lz.HomeRegion
+			}
+		})
+	}
+	if w.cfg.MaxDriftIncrease != nil {
+		if w.detector, err = stacksets.NewDetector(ctx, lz); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Start records the drift before the first wave, the reference of the drift increase
+func (w *Watcher) Start(ctx context.Context) error {
+	if w.detector == nil {
+		return nil
+	}
+	drift, err := w.detectDrift(ctx)
+	if err != nil {
+		return err
+	}
+	w.drift = drift
+	return nil
+}
+
+// Bake watches a wave for its bake time, checking the alarms every minute and the
+// drift at the end. It returns an error wrapping ErrHalted when the rollout must stop.
+func (w *Watcher) Bake(ctx context.Context, wave string, bake time.Duration) error {
+	w.logger.Info("baking rollout wave", zap.String("wave", wave), zap.Duration("bake", bake))
+
+	end := time.Now().Add(bake)
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		if err := w.checkAlarms(ctx, wave); err != nil {
+			return err
+		}
+		remaining := time.Until(end)
+		if remaining <= 0 {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-time.After(remaining):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return w.checkDrift(ctx, wave)
+}
+
+// checkAlarms halts the rollout when one of the alarms is in alarm
+func (w *Watcher) checkAlarms(ctx context.Context, wave string) error {
+	if w.cw == nil {
+		return nil
+	}
+
+	var firing []string
+	paginator := cloudwatch.NewDescribeAlarmsPaginator(w.cw, &cloudwatch.DescribeAlarmsInput{
+		AlarmNames: w.cfg.Alarms,
+		AlarmTypes: []cwtypes.AlarmType{cwtypes.AlarmTypeMetricAlarm, cwtypes.AlarmTypeCompositeAlarm},
+		StateValue: cwtypes.StateValueAlarm,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to describe rollout alarms: %w", err)
+		}
+		for _, alarm := range page.MetricAlarms {
+			firing = append(firing, aws.ToString(alarm.AlarmName))
+		}
+		for _, alarm := range page.CompositeAlarms {
+			firing = append(firing, aws.ToString(alarm.AlarmName))
+		}
+	}
+
+	if len(firing) > 0 {
+		w.metrics.IncrementCounter("rollout_halted")
+		return fmt.Errorf("%w after wave %s: alarms in alarm: %s", ErrHalted, wave, strings.Join(firing, ", "))
+	}
+	return nil
+}
+
+// checkDrift halts the rollout when the drift grew by more than the allowed increase
+// since the start of the rollout
+func (w *Watcher) checkDrift(ctx context.Context, wave string) error {
+	if w.detector == nil {
+		return nil
+	}
+
+	drift, err := w.detectDrift(ctx)
+	if err != nil {
+		return err
+	}
+	if increase := drift - w.drift; increase > *w.cfg.MaxDriftIncrease {
+		w.metrics.IncrementCounter("rollout_halted")
+		return fmt.Errorf("%w after wave %s: drift grew by %d findings, at most %d allowed",
+			ErrHalted, wave, increase, *w.cfg.MaxDriftIncrease)
+	}
+	return nil
+}
+
+// detectDrift runs a drift detection of the baseline StackSets and returns the number
+// of its findings
+func (w *Watcher) detectDrift(ctx context.Context) (int, error) {
+	r := report.New()
+	if err := w.detector.Detect(ctx, r); err != nil {
+		return 0, err
+	}
+	return len(r.Findings), nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package runid provides the identifier correlating the logs, metrics, state and
+// notifications of a single run.
+// Version: 1.0.0
+package runid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Env carries the run ID into the Pulumi program the engine launches, so the
+	// program shares the ID of the command that started it. Setting it also lets a CI
+	// pipeline use its own job ID.
+	Env = "AWS_ORG_RUN_ID"
+
+	// LogKey is the key of the run ID in log entries
+	LogKey = "runId"
+
+	// Bytes of randomness appended to the start time
+	randomBytes = 4
+)
+
+var (
+	id   string
+	once sync.Once
+)
+
+// ID returns the run ID of the process, taken from Env or generated from the start
+// time and a random suffix, for example 20240102T150405Z-1a2b3c4d
+func ID() string {
+	once.Do(func() {
+		if id = os.Getenv(Env); id != "" {
+			return
+		}
+
+		suffix := make([]byte, randomBytes)
+		if _, err := rand.Read(suffix); err != nil {
+			suffix = []byte{0, 0, 0, 0}
+		}
+		id = time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+	})
+	return id
+}
+
+// Field returns the log field of the run ID
+func Field() zap.Field {
+	return zap.String(LogKey, ID())
+}
+
+// LogOption returns the logger option adding the run ID to every entry
+func LogOption() zap.Option {
+	return zap.Fields(Field())
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package runs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+)
+
+// Write writes runs as a table or as JSON
+func Write(w io.Writer, format string, runs []*Run) error {
+	switch format {
+	case report.FormatJSON:
+		if runs == nil {
+			runs = []*Run{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(runs); err != nil {
+			return fmt.Errorf("failed to encode runs: %w", err)
+		}
+		return nil
+	case report.FormatText:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tCOMMAND\tSTATUS\tSTARTED\tDURATION\tOPERATOR\tGIT SHA\tSUMMARY")
+		for _, run := range runs {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				run.ID, run.Command, run.Status, run.StartedAt.Format(time.RFC3339),
+				run.Duration().Round(time.Second), run.Operator, report.OrDash(shortSHA(run.GitSHA)),
+				report.OrDash(summary(run.Summary)))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// WriteRun writes the details of a run as text or as JSON
+func WriteRun(w io.Writer, format string, run *Run) error {
+	switch format {
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(run); err != nil {
+			return fmt.Errorf("failed to encode run %s: %w", run.ID, err)
+		}
+		return nil
+	case report.FormatText:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Run:\t%s\n", run.ID)
+		fmt.Fprintf(tw, "Command:\t%s\n", run.Command)
+		fmt.Fprintf(tw, "Status:\t%s\n", run.Status)
+		fmt.Fprintf(tw, "Started:\t%s\n", run.StartedAt.Format(time.RFC3339))
+		if run.FinishedAt != nil {
+			fmt.Fprintf(tw, "Finished:\t%s (%s)\n", run.FinishedAt.Format(time.RFC3339), run.Duration().Round(time.Second))
+		}
+		fmt.Fprintf(tw, "Operator:\t%s\n", run.Operator)
+		fmt.Fprintf(tw, "Git SHA:\t%s\n", report.OrDash(run.GitSHA))
+		fmt.Fprintf(tw, "Logs:\t%s\n", report.OrDash(run.Logs))
+		for _, name := range report.SortedKeys(run.Parameters) {
+			fmt.Fprintf(tw, "Parameter:\t--%s=%s\n", name, run.Parameters[name])
+		}
+		fmt.Fprintf(tw, "Summary:\t%s\n", report.OrDash(summary(run.Summary)))
+		if run.Error != "" {
+			fmt.Fprintf(tw, "Error:\t%s\n", run.Error)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		if len(run.Changes) == 0 {
+			if run.Truncated {
+				fmt.Fprintln(w, "\nChanges too large to record, see the summary")
+			}
+			return nil
+		}
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "OPERATION\tTYPE\tRESOURCE")
+		for _, change := range run.Changes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", change.Operation, change.Type, change.URN)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// summary renders the entries of a summary in the order of their keys
+func summary(counts map[string]int) string {
+	parts := make([]string, 0, len(counts))
+	for _, key := range report.SortedKeys(counts) {
+		parts = append(parts, fmt.Sprintf("%s=%d", key, counts[key]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shortSHA returns the abbreviated form of a commit
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
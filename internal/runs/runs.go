@@ -0,0 +1,454 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package runs provides the history of the deploy, drift and import runs, recorded in
+// the state table for operational audit.
+// Version: 1.0.0
+package runs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/budget"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go/middleware"
+	"go.uber.org/zap"
+)
+
+// Statuses of a run
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const (
+	// Partition of the run items in the state table, sorted by start time
+	runPartition = "run"
+
+	// Start time at the head of the sort key of a run, fixed width so keys sort by time
+	keyTimeFormat = "20060102T150405.000000000Z"
+
+	// Attributes of the run items. The run itself is kept as JSON; the ID, command,
+	// status and start time are also kept as attributes to filter on.
+	runAttribute     = "run"
+	idAttribute      = "id"
+	commandAttribute = "command"
+	statusAttribute  = "status"
+	startedAttribute = "startedAt"
+
+	// Size of the JSON of a run above which its changes are dropped, keeping the item
+	// under the 400 KB limit of DynamoDB
+	maxRunSize = 300 * 1024
+
+	// Default number of runs listed
+	DefaultLimit = 20
+)
+
+// ErrNotFound is returned for a run that is not in the history
+var ErrNotFound = errors.New("run not found")
+
+// Run is a recorded execution of a command: who ran it with which parameters at which
+// commit, the changes it planned, its result, the time its updates consumed and where
+// its logs are
+type Run struct {
+	ID         string            `json:"id"`
+	Command    string            `json:"command"`
+	Parameters map[string]string `json:"parameters"`
+	GitSHA     string            `json:"gitSha,omitempty"`
+	Operator   string            `json:"operator"`
+	Status     string            `json:"status"`
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+	Summary    map[string]int    `json:"summary"`
+	Changes    []plan.Change     `json:"changes"`
+	Truncated  bool              `json:"truncated,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Logs       string            `json:"logs,omitempty"`
+
+	// Budget holds the time each update of a deploy consumed
+	Budget *budget.Record `json:"budget,omitempty"`
+}
+
+// New creates the run of a command in the current process
+func New(command string, parameters map[string]string) *Run {
+	if parameters == nil {
+		parameters = map[string]string{}
+	}
+	return &Run{
+		ID:         runid.ID(),
+		Command:    command,
+		Parameters: parameters,
+		GitSHA:     gitSHA(),
+		Operator:   operator(),
+		Status:     StatusRunning,
+		StartedAt:  time.Now().UTC(),
+		Summary:    map[string]int{},
+		Changes:    []plan.Change{},
+		Logs:       logs(),
+	}
+}
+
+// AddChanges records changes planned by the run and counts them by operation
+func (r *Run) AddChanges(changes ...plan.Change) {
+	for _, change := range changes {
+		r.Changes = append(r.Changes, change)
+		r.Summary[change.Operation]++
+	}
+}
+
+// Count adds n to an entry of the summary of the run
+func (r *Run) Count(key string, n int) {
+	r.Summary[key] += n
+}
+
+// Duration returns how long the run took, or has been running
+func (r *Run) Duration() time.Duration {
+	if r.FinishedAt == nil {
+		return time.Since(r.StartedAt)
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// Store keeps the history of the runs in the state table. Runs are deleted by the TTL
+// of the table once the retention of the history has passed.
+type Store struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	client    *dynamodb.Client
+	tableName string
+	retention time.Duration
+}
+
+// NewStore creates a run store in the state table with the provided options
+func NewStore(ctx context.Context, opts ...func(*Store) error) (*Store, error) {
+	logger, err := logging.NewLogger("runs")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("runs")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithAPIOptions([]func(*middleware.Stack) error{awsclient.ClassifyErrors}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	s := &Store{
+		logger:    logger,
+		metrics:   metrics,
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: config.StateTableName,
+		retention: config.DefaultRunRetentionDays * 24 * time.Hour,
+	}
+
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// OptionsFor returns the store options set by the landing zone configuration
+func OptionsFor(cfg *config.LandingZoneConfig) []func(*Store) error {
+	var opts []func(*Store) error
+	if cfg == nil {
+		return opts
+	}
+	if cfg.StateTableName != "" {
+		opts = append(opts, WithTable(cfg.StateTableName))
+	}
+	opts = append(opts, WithRetention(time.Duration(cfg.RunHistory.Retention())*24*time.Hour))
+	return opts
+}
+
+// WithTable sets the state table the runs are kept in
+func WithTable(name string) func(*Store) error {
+	return func(s *Store) error {
+		if name == "" {
+			return fmt.Errorf("a run table name is required")
+		}
+		s.tableName = name
+		return nil
+	}
+}
+
+// WithRetention sets how long runs are kept
+func WithRetention(retention time.Duration) func(*Store) error {
+	return func(s *Store) error {
+		if retention < 24*time.Hour {
+			return fmt.Errorf("run retention must be at least one day")
+		}
+		s.retention = retention
+		return nil
+	}
+}
+
+// Start records a run as running
+func (s *Store) Start(ctx context.Context, run *Run) error {
+	run.Status = StatusRunning
+	if err := s.Put(ctx, run); err != nil {
+		return err
+	}
+
+	s.metrics.IncrementCounter("runs_started")
+	s.logger.Info("run started",
+		zap.String("command", run.Command),
+		zap.String("gitSha", run.GitSHA),
+		zap.String("operator", run.Operator))
+	return nil
+}
+
+// Finish records the result of a run, failed when err is set
+func (s *Store) Finish(ctx context.Context, run *Run, err error) error {
+	finished := time.Now().UTC()
+	run.FinishedAt = &finished
+	run.Status = StatusSucceeded
+	if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+	}
+	if err := s.Put(ctx, run); err != nil {
+		return err
+	}
+
+	s.metrics.IncrementCounter("runs_" + run.Status)
+	s.metrics.RecordDuration("run_duration", run.Duration())
+	s.logger.Info("run finished",
+		zap.String("command", run.Command),
+		zap.String("status", run.Status),
+		zap.Duration("duration", run.Duration()))
+	return nil
+}
+
+// Put writes a run to the history. The changes of a run too large for an item are
+// dropped, keeping their summary. Runs are keyed by start time, command and ID, so
+// commands sharing a run ID, such as the jobs of a CI pipeline, keep their own items.
+func (s *Store) Put(ctx context.Context, run *Run) error {
+	if err := readonly.Check("record run"); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to encode run %s: %w", run.ID, err)
+	}
+	if len(data) > maxRunSize {
+		trimmed := *run
+		trimmed.Changes, trimmed.Truncated = []plan.Change{}, true
+		if data, err = json.Marshal(&trimmed); err != nil {
+			return fmt.Errorf("failed to encode run %s: %w", run.ID, err)
+		}
+		s.logger.Warn("run changes too large to record, keeping their summary",
+			zap.Int("changes", len(run.Changes)))
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]types.AttributeValue{
+			config.PkAttribute:        &types.AttributeValueMemberS{Value: runPartition},
+			config.SkAttribute:        &types.AttributeValueMemberS{Value: key(run)},
+			runAttribute:              &types.AttributeValueMemberS{Value: string(data)},
+			idAttribute:               &types.AttributeValueMemberS{Value: run.ID},
+			commandAttribute:          &types.AttributeValueMemberS{Value: run.Command},
+			statusAttribute:           &types.AttributeValueMemberS{Value: run.Status},
+			startedAttribute:          &types.AttributeValueMemberS{Value: run.StartedAt.Format(time.RFC3339)},
+			config.ExpiresAtAttribute: &types.AttributeValueMemberN{Value: strconv.FormatInt(run.StartedAt.Add(s.retention).Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record run %s: %w", run.ID, err)
+	}
+	return nil
+}
+
+// Get returns the latest run of the history with an ID, failing with ErrNotFound when
+// none is recorded
+func (s *Store) Get(ctx context.Context, id string) (*Run, error) {
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:                aws.String(s.tableName),
+		KeyConditionExpression:   aws.String("#pk = :pk"),
+		FilterExpression:         aws.String("#id = :id"),
+		ExpressionAttributeNames: map[string]string{"#pk": config.PkAttribute, "#id": idAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: runPartition},
+			":id": &types.AttributeValueMemberS{Value: id},
+		},
+		ScanIndexForward: aws.Bool(false),
+		ConsistentRead:   aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read run %s: %w", id, err)
+		}
+		if len(page.Items) > 0 {
+			return decode(page.Items[0])
+		}
+	}
+
+	// Runs recorded before they were keyed by start time are keyed by ID
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			config.PkAttribute: &types.AttributeValueMemberS{Value: runPartition},
+			config.SkAttribute: &types.AttributeValueMemberS{Value: id},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run %s: %w", id, err)
+	}
+	if out.Item == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return decode(out.Item)
+}
+
+// List returns the most recent runs, newest first, limited to a command when command
+// is set. A limit of zero or less returns DefaultLimit runs.
+func (s *Store) List(ctx context.Context, command string, limit int) ([]*Run, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(s.tableName),
+		KeyConditionExpression:   aws.String("#pk = :pk"),
+		ExpressionAttributeNames: map[string]string{"#pk": config.PkAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: runPartition},
+		},
+		// Keys start with the start time, so the newest come first
+		ScanIndexForward: aws.Bool(false),
+	}
+	if command != "" {
+		input.FilterExpression = aws.String("#command = :command")
+		input.ExpressionAttributeNames["#command"] = commandAttribute
+		input.ExpressionAttributeValues[":command"] = &types.AttributeValueMemberS{Value: command}
+	}
+
+	var runs []*Run
+	paginator := dynamodb.NewQueryPaginator(s.client, input)
+	for paginator.HasMorePages() && len(runs) < limit {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list runs: %w", err)
+		}
+		for _, item := range page.Items {
+			run, err := decode(item)
+			if err != nil {
+				s.logger.Warn("skipping unreadable run", zap.Error(err))
+				continue
+			}
+			runs = append(runs, run)
+		}
+	}
+
+	// Runs recorded before they were keyed by start time are keyed by ID, which does
+	// not sort by time when set by a CI pipeline
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// key returns the sort key of a run
+func key(run *Run) string {
+	return run.StartedAt.UTC().Format(keyTimeFormat) + "#" + run.Command + "#" + run.ID
+}
+
+// decode returns the run of an item
+func decode(item map[string]types.AttributeValue) (*Run, error) {
+	v, ok := item[runAttribute].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, fmt.Errorf("run item has no %s attribute", runAttribute)
+	}
+
+	var run Run
+	if err := json.Unmarshal([]byte(v.Value), &run); err != nil {
+		return nil, fmt.Errorf("failed to decode run: %w", err)
+	}
+	if run.Parameters == nil {
+		run.Parameters = map[string]string{}
+	}
+	if run.Summary == nil {
+		run.Summary = map[string]int{}
+	}
+	if run.Changes == nil {
+		run.Changes = []plan.Change{}
+	}
+	return &run, nil
+}
+
+// gitSHA returns the commit of the configuration, from the CI environment or the
+// repository of the working directory, empty when neither is available
+func gitSHA() string {
+	for _, env := range []string{"GITHUB_SHA", "CI_COMMIT_SHA"} {
+		if sha := os.Getenv(env); sha != "" {
+			return sha
+		}
+	}
+
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// operator identifies the user and host running the tool
+func operator() string {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = "unknown"
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return user + "@" + host
+}
+
+// logs returns where the logs of the run are: the GitHub Actions run when running in
+// a workflow, the log file otherwise, or the console output without log files
+func logs() string {
+	server, repository, id := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID")
+	if server != "" && repository != "" && id != "" {
+		return fmt.Sprintf("%s/%s/actions/runs/%s", server, repository, id)
+	}
+	if file := logging.LogFile(); file != "" {
+		return file
+	}
+	return "stdout"
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sdksecretsmanager "github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	sdkssm "github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// Reader reads the current value of the secrets from the configured store
+type Reader struct {
+	lz             *config.LandingZoneConfig
+	cfg            *config.SecretsConfig
+	ssm            *sdkssm.Client
+	secretsManager *sdksecretsmanager.Client
+}
+
+// NewReader creates a reader of the secrets of a landing zone
+func NewReader(ctx context.Context, cfg *config.LandingZoneConfig) (*Reader, error) {
+	awsCfg, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		lz:             cfg,
+		cfg:            cfg.Secrets,
+		ssm:            sdkssm.NewFromConfig(awsCfg),
+		secretsManager: sdksecretsmanager.NewFromConfig(awsCfg),
+	}, nil
+}
+
+// Get returns the current value of a secret, following its rotations
+func (r *Reader) Get(ctx context.Context, name string) (string, error) {
+	secretName := r.lz.SecretName(name)
+
+	if r.cfg.StoreName() == config.SecretStoreSSM {
+		output, err := r.ssm.GetParameter(ctx, &sdkssm.GetParameterInput{
+			Name:           aws.String(secretName),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret parameter %s: %w", secretName, err)
+		}
+		return aws.ToString(output.Parameter.Value), nil
+	}
+
+	output, err := r.secretsManager.GetSecretValue(ctx, &sdksecretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", secretName, err)
+	}
+	return aws.ToString(output.SecretString), nil
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package secrets provides the storage of the secrets generated by the landing zone in
+// SecureString SSM parameters or in Secrets Manager with rotation.
+// Version: 1.0.0
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/secretsmanager"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ssm"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Name of the break-glass password in the secret store
+	breakGlassSecretName = "break-glass/password"
+
+	// Length of the generated break-glass password and webhook secrets
+	passwordLength     = 32
+	webhookSecretBytes = 32
+)
+
+// Store creates the secrets in the configured store
+type Store struct {
+	lz   *config.LandingZoneConfig
+	cfg  *config.SecretsConfig
+	tags map[string]string
+}
+
+// NewStore creates a store of the secrets of a landing zone
+func NewStore(cfg *config.LandingZoneConfig) *Store {
+	return &Store{lz: cfg, cfg: cfg.Secrets, tags: cfg.Tags}
+}
+
+// Put stores a secret. Generated secrets keep their first value: later changes of the
+// value, such as a rotation, are left to the store.
+func (s *Store) Put(ctx *pulumi.Context, name, description string, value pulumi.StringInput) error {
+	secretName := s.lz.SecretName(name)
+	resourceName := fmt.Sprintf("secret-%s", strings.ReplaceAll(name, "/", "-"))
+
+	if s.cfg.StoreName() == config.SecretStoreSSM {
+		args := &ssm.ParameterArgs{
+			Name:        pulumi.String(secretName),
+			Type:        pulumi.String("SecureString"),
+			Value:       pulumi.ToSecret(value.ToStringOutput()).(pulumi.StringOutput),
+			Description: pulumi.String(description),
+			Tags:        pulumi.ToStringMap(s.tags),
+		}
+		if s.cfg != nil && s.cfg.KmsKeyId != "" {
+			args.KeyId = pulumi.String(s.cfg.KmsKeyId)
+		}
+		if _, err := ssm.NewParameter(ctx, resourceName, args, pulumi.IgnoreChanges([]string{"value"})); err != nil {
+			return fmt.Errorf("failed to create secret parameter %s: %w", secretName, err)
+		}
+		return nil
+	}
+
+	args := &secretsmanager.SecretArgs{
+		Name:                 pulumi.String(secretName),
+		Description:          pulumi.String(description),
+		RecoveryWindowInDays: pulumi.Int(s.cfg.RecoveryWindow()),
+		Tags:                 pulumi.ToStringMap(s.tags),
+	}
+	if s.cfg.KmsKeyId != "" {
+		args.KmsKeyId = pulumi.String(s.cfg.KmsKeyId)
+	}
+	secret, err := secretsmanager.NewSecret(ctx, resourceName, args)
+	if err != nil {
+		return fmt.Errorf("failed to create secret %s: %w", secretName, err)
+	}
+
+	// A rotation stores new versions and moves the AWSCURRENT stage to them
+	version, err := secretsmanager.NewSecretVersion(ctx, resourceName, &secretsmanager.SecretVersionArgs{
+		SecretId:     secret.ID(),
+		SecretString: pulumi.ToSecret(value.ToStringOutput()).(pulumi.StringOutput),
+	}, pulumi.IgnoreChanges([]string{"secretString", "versionStages"}))
+	if err != nil {
+		return fmt.Errorf("failed to store secret %s: %w", secretName, err)
+	}
+
+	if r := s.cfg.Rotation; r != nil {
+		_, err := secretsmanager.NewSecretRotation(ctx, resourceName, &secretsmanager.SecretRotationArgs{
+			SecretId:          secret.ID(),
+			RotationLambdaArn: pulumi.String(r.LambdaArn),
+			RotationRules: &secretsmanager.SecretRotationRotationRulesArgs{
+				AutomaticallyAfterDays: pulumi.Int(r.Days),
+			},
+		}, pulumi.DependsOn([]pulumi.Resource{version}))
+		if err != nil {
+			return fmt.Errorf("failed to configure rotation of secret %s: %w", secretName, err)
+		}
+	}
+	return nil
+}
+
+// Names returns the full names of the secrets generated for a landing zone
+func Names(cfg *config.LandingZoneConfig) []string {
+	var names []string
+	if cfg.Secrets != nil && cfg.Secrets.BreakGlass != nil {
+		names = append(names, cfg.SecretName(breakGlassSecretName))
+	}
+	for _, webhook := range cfg.Webhooks {
+		if webhook.GenerateSecret {
+			names = append(names, cfg.SecretName(webhook.SecretName()))
+		}
+	}
+	return names
+}
+
+// SetupSecrets creates the break-glass user and the generated webhook secrets, and
+// stores their credentials in the configured store
+func SetupSecrets(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("secrets")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("secrets")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("secrets_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup generated secrets"); err != nil {
+		return err
+	}
+
+	store := NewStore(cfg)
+	if cfg.Secrets != nil && cfg.Secrets.BreakGlass != nil {
+		if err := setupBreakGlass(ctx, cfg, store); err != nil {
+			return err
+		}
+		metrics.IncrementCounter("secrets_stored")
+	}
+
+	for _, webhook := range cfg.Webhooks {
+		if !webhook.GenerateSecret {
+			continue
+		}
+		secret, err := generateSecret()
+		if err != nil {
+			return err
+		}
+		description := fmt.Sprintf("Signing secret of the %s account lifecycle webhook", webhook.Name)
+		if err := store.Put(ctx, webhook.SecretName(), description, pulumi.String(secret)); err != nil {
+			return err
+		}
+		metrics.IncrementCounter("secrets_stored")
+	}
+
+	logger.Info("generated secrets configured",
+		zap.String("store", cfg.Secrets.StoreName()))
+	return nil
+}
+
+// setupBreakGlass creates the break-glass user with administrator access and a console
+// password generated by IAM. The access requires MFA; without it, the user can only
+// enroll its MFA device.
+func setupBreakGlass(ctx *pulumi.Context, cfg *config.LandingZoneConfig, store *Store) error {
+	name := cfg.Secrets.BreakGlass.Name()
+	user, err := iam.NewUser(ctx, "break-glass", &iam.UserArgs{
+		Name: pulumi.String(name),
+		Tags: pulumi.ToStringMap(cfg.Tags),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create break-glass user: %w", err)
+	}
+
+	policy, err := breakGlassPolicy()
+	if err != nil {
+		return err
+	}
+	_, err = iam.NewUserPolicy(ctx, "break-glass", &iam.UserPolicyArgs{
+		User:   user.Name,
+		Policy: pulumi.String(policy),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to grant access to break-glass user: %w", err)
+	}
+
+	// IAM returns the password on creation only, so the login profile keeps it
+	profile, err := iam.NewUserLoginProfile(ctx, "break-glass", &iam.UserLoginProfileArgs{
+		User:                  user.Name,
+		PasswordLength:        pulumi.Int(passwordLength),
+		PasswordResetRequired: pulumi.Bool(false),
+	}, pulumi.IgnoreChanges([]string{"passwordLength", "passwordResetRequired"}))
+	if err != nil {
+		return fmt.Errorf("failed to create break-glass login profile: %w", err)
+	}
+
+	return store.Put(ctx, breakGlassSecretName,
+		fmt.Sprintf("Console password of the %s break-glass user", name), profile.Password)
+}
+
+// breakGlassPolicy returns the policy of the break-glass user: administrator access
+// when signed in with MFA, and the management of its own MFA device
+func breakGlassPolicy() (string, error) {
+	partition := awsclient.Partition()
+	document, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Sid":      "AdministratorAccessWithMFA",
+				"Effect":   "Allow",
+				"Action":   "*",
+				"Resource": "*",
+				"Condition": map[string]interface{}{
+					"Bool": map[string]string{"aws:MultiFactorAuthPresent": "true"},
+				},
+			},
+			{
+				"Sid":    "EnrollOwnMFADevice",
+				"Effect": "Allow",
+				"Action": []string{
+					"iam:CreateVirtualMFADevice",
+					"iam:EnableMFADevice",
+					"iam:ResyncMFADevice",
+					"iam:ListMFADevices",
+					"iam:GetUser",
+				},
+				"Resource": []string{
+					fmt.Sprintf("arn:%s:iam::*:mfa/*", partition),
+					fmt.Sprintf("arn:%s:iam::*:user/${aws:username}", partition),
+				},
+			},
+			{
+				"Sid":      "ListMFADevices",
+				"Effect":   "Allow",
+				"Action":   "iam:ListVirtualMFADevices",
+				"Resource": "*",
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal break-glass policy: %w", err)
+	}
+	return string(document), nil
+}
+
+// generateSecret returns a random hex secret
+func generateSecret() (string, error) {
+	buf := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,327 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/keypolicy"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/guardduty"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kms"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// GuardDuty defaults
+	DefaultGuardDutyPublishingFrequency = "SIX_HOURS"
+	DefaultGuardDutyFilterAction        = "ARCHIVE"
+
+	// Member accounts enabled by the organization configuration
+	guardDutyAutoEnableAll = "ALL"
+
+	// Service whose principal writes exported findings
+	guardDutyService = "guardduty"
+)
+
+// guardDutyExport holds the destination of exported GuardDuty findings
+type guardDutyExport struct {
+	destinationArn string
+	key            *kms.Key
+	dependencies   []pulumi.Resource
+}
+
+// guardDutyEnabled reports whether GuardDuty is managed for the organization
+func guardDutyEnabled(cfg *config.LandingZoneConfig) bool {
+	return cfg.EnableGuardDuty && cfg.GuardDuty != nil
+}
+
+// setupGuardDuty delegates GuardDuty to the security account, enables it for every
+// member account and applies the findings export and suppression rules
+func (s *Services) setupGuardDuty(ctx *pulumi.Context, region string, cfg *config.LandingZoneConfig, export *guardDutyExport) error {
+	mgmt, err := s.managementProvider(ctx, region)
+	if err != nil {
+		return err
+	}
+	admin, err := s.adminProvider(ctx, region)
+	if err != nil {
+		return err
+	}
+
+	frequency := cfg.GuardDuty.FindingPublishingFrequency
+	if frequency == "" {
+		frequency = DefaultGuardDutyPublishingFrequency
+	}
+
+	detector, err := guardduty.NewDetector(ctx, fmt.Sprintf("guardduty-detector-%s", region), &guardduty.DetectorArgs{
+		Enable:                     pulumi.Bool(true),
+		FindingPublishingFrequency: pulumi.String(frequency),
+		Tags:                       pulumi.ToStringMap(cfg.Tags),
+	}, pulumi.Provider(admin))
+	if err != nil {
+		return fmt.Errorf("failed to enable GuardDuty in %s: %w", region, err)
+	}
+
+	delegation, err := guardduty.NewOrganizationAdminAccount(ctx, fmt.Sprintf("guardduty-admin-%s", region),
+		&guardduty.OrganizationAdminAccountArgs{
+			AdminAccountId: pulumi.String(s.adminAccountId),
+		}, pulumi.Provider(mgmt), pulumi.DependsOn([]pulumi.Resource{detector}))
+	if err != nil {
+		return fmt.Errorf("failed to delegate GuardDuty administration in %s: %w", region, err)
+	}
+
+	if _, err := guardduty.NewOrganizationConfiguration(ctx, fmt.Sprintf("guardduty-org-config-%s", region),
+		&guardduty.OrganizationConfigurationArgs{
+			AutoEnableOrganizationMembers: pulumi.String(guardDutyAutoEnableAll),
+			DetectorId:                    detector.ID(),
+		}, pulumi.Provider(admin), pulumi.DependsOn([]pulumi.Resource{delegation})); err != nil {
+		return fmt.Errorf("failed to configure GuardDuty organization in %s: %w", region, err)
+	}
+
+	if export != nil {
+		if _, err := guardduty.NewPublishingDestination(ctx, fmt.Sprintf("guardduty-export-%s", region),
+			&guardduty.PublishingDestinationArgs{
+				DetectorId:     detector.ID(),
+				DestinationArn: pulumi.String(export.destinationArn),
+				KmsKeyArn:      export.key.Arn,
+			}, pulumi.Provider(admin), pulumi.DependsOn(export.dependencies)); err != nil {
+			return fmt.Errorf("failed to export GuardDuty findings in %s: %w", region, err)
+		}
+	}
+
+	for i, rule := range cfg.GuardDuty.SuppressionRules {
+		if err := s.guardDutyFilter(ctx, region, detector, i, rule, cfg); err != nil {
+			return err
+		}
+	}
+
+	s.metrics.IncrementCounter("guardduty_regions_enabled")
+	s.logger.Info("GuardDuty enabled",
+		zap.String("region", region),
+		zap.Bool("export", export != nil),
+		zap.Int("suppressionRules", len(cfg.GuardDuty.SuppressionRules)))
+	return nil
+}
+
+// guardDutyFilter creates a filter on the detector of the delegated administrator, which
+// applies to the findings of every member account. Rules without rank are ranked in
+// configuration order.
+func (s *Services) guardDutyFilter(ctx *pulumi.Context, region string, detector *guardduty.Detector, index int, rule config.GuardDutyFilterConfig, cfg *config.LandingZoneConfig) error {
+	admin, err := s.adminProvider(ctx, region)
+	if err != nil {
+		return err
+	}
+
+	action := rule.Action
+	if action == "" {
+		action = DefaultGuardDutyFilterAction
+	}
+	rank := rule.Rank
+	if rank == 0 {
+		rank = index + 1
+	}
+
+	criteria := make(guardduty.FilterFindingCriteriaCriterionArray, 0, len(rule.Criteria))
+	for _, criterion := range rule.Criteria {
+		args := &guardduty.FilterFindingCriteriaCriterionArgs{
+			Field: pulumi.String(criterion.Field),
+		}
+		if len(criterion.Equals) > 0 {
+			args.Equals = pulumi.ToStringArray(criterion.Equals)
+		}
+		if len(criterion.NotEquals) > 0 {
+			args.NotEquals = pulumi.ToStringArray(criterion.NotEquals)
+		}
+		if criterion.GreaterThan != "" {
+			args.GreaterThan = pulumi.String(criterion.GreaterThan)
+		}
+		if criterion.GreaterThanOrEqual != "" {
+			args.GreaterThanOrEqual = pulumi.String(criterion.GreaterThanOrEqual)
+		}
+		if criterion.LessThan != "" {
+			args.LessThan = pulumi.String(criterion.LessThan)
+		}
+		if criterion.LessThanOrEqual != "" {
+			args.LessThanOrEqual = pulumi.String(criterion.LessThanOrEqual)
+		}
+		criteria = append(criteria, args)
+	}
+
+	args := &guardduty.FilterArgs{
+		Name:       pulumi.String(rule.Name),
+		Action:     pulumi.String(action),
+		Rank:       pulumi.Int(rank),
+		DetectorId: detector.ID(),
+		FindingCriteria: &guardduty.FilterFindingCriteriaArgs{
+			Criterions: criteria,
+		},
+		Tags: pulumi.ToStringMap(cfg.Tags),
+	}
+	if rule.Description != "" {
+		args.Description = pulumi.String(rule.Description)
+	}
+
+	if _, err := guardduty.NewFilter(ctx, fmt.Sprintf("guardduty-filter-%s-%s", rule.Name, region), args,
+		pulumi.Provider(admin)); err != nil {
+		return fmt.Errorf("failed to create GuardDuty suppression rule %s in %s: %w", rule.Name, region, err)
+	}
+
+	s.metrics.IncrementCounter("guardduty_filters_created")
+	return nil
+}
+
+// setupGuardDutyExport creates the bucket and KMS key of the log archive account the
+// findings of every region are exported to. Only the security account may write to them.
+func (s *Services) setupGuardDutyExport(ctx *pulumi.Context, cfg *config.LandingZoneConfig) (*guardDutyExport, error) {
+	exportCfg := cfg.GuardDuty.Export
+	bucketName := cfg.GuardDutyExportBucket()
+	region := cfg.LogBucketRegion()
+
+	provider, err := aws.NewProvider(ctx, "guardduty-export-log-archive", &aws.ProviderArgs{
+		Region: pulumi.String(region),
+		AssumeRole: &aws.ProviderAssumeRoleArgs{
+			RoleArn:     pulumi.String(awsclient.RoleArn(cfg.LogArchiveAccountId, s.roleName)),
+			SessionName: pulumi.String(awsclient.SessionName),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log archive provider for GuardDuty export: %w", err)
+	}
+
+	keyPolicy, err := s.guardDutyKeyPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := kms.NewKey(ctx, "guardduty-export-key", &kms.KeyArgs{
+		Description:       pulumi.String("Encryption of exported GuardDuty findings"),
+		EnableKeyRotation: pulumi.Bool(true),
+		Policy:            pulumi.String(keyPolicy),
+		Tags:              pulumi.ToStringMap(cfg.Tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GuardDuty export key: %w", err)
+	}
+
+	if alias := cfg.ResourceNames().KeyAlias(config.KeyGuardDutyExport, region); alias != "" {
+		if _, err := kms.NewAlias(ctx, "guardduty-export-key-alias", &kms.AliasArgs{
+			Name:        pulumi.String(alias),
+			TargetKeyId: key.KeyId,
+		}, pulumi.Provider(provider)); err != nil {
+			return nil, fmt.Errorf("failed to create GuardDuty export key alias: %w", err)
+		}
+	}
+
+	bucket, err := s3.NewBucketV2(ctx, "guardduty-export-bucket", &s3.BucketV2Args{
+		Bucket: pulumi.String(bucketName),
+		Tags:   pulumi.ToStringMap(cfg.Tags),
+	}, pulumi.Provider(provider), pulumi.Protect(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GuardDuty export bucket: %w", err)
+	}
+
+	if _, err := s3.NewBucketPublicAccessBlock(ctx, "guardduty-export-public-access", &s3.BucketPublicAccessBlockArgs{
+		Bucket:                bucket.ID(),
+		BlockPublicAcls:       pulumi.Bool(true),
+		BlockPublicPolicy:     pulumi.Bool(true),
+		IgnorePublicAcls:      pulumi.Bool(true),
+		RestrictPublicBuckets: pulumi.Bool(true),
+	}, pulumi.Provider(provider)); err != nil {
+		return nil, fmt.Errorf("failed to block public access to GuardDuty export bucket: %w", err)
+	}
+
+	bucketArn := awsclient.BucketArn(bucketName)
+	bucketPolicy, err := s.guardDutyBucketPolicy(bucketArn)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := s3.NewBucketPolicy(ctx, "guardduty-export-bucket-policy", &s3.BucketPolicyArgs{
+		Bucket: bucket.ID(),
+		Policy: pulumi.String(bucketPolicy),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach GuardDuty export bucket policy: %w", err)
+	}
+
+	destinationArn := bucketArn
+	if exportCfg.Prefix != "" {
+		destinationArn = fmt.Sprintf("%s/%s", bucketArn, exportCfg.Prefix)
+	}
+
+	s.logger.Info("GuardDuty findings export configured",
+		zap.String("bucket", bucketName),
+		zap.String("region", region))
+	return &guardDutyExport{
+		destinationArn: destinationArn,
+		key:            key,
+		dependencies:   []pulumi.Resource{key, policy},
+	}, nil
+}
+
+// guardDutyKeyPolicy returns the policy of the export key, administered by the
+// management and log archive accounts and usable by GuardDuty on behalf of the
+// security account
+func (s *Services) guardDutyKeyPolicy(cfg *config.LandingZoneConfig) (string, error) {
+	policy, err := keypolicy.ForConfig(cfg).
+		Administrators(cfg.LogArchiveAccountId).
+		GrantService("AllowGuardDutyEncryption", guardDutyService, s.adminAccountId, "kms:GenerateDataKey").
+		Build()
+	if err != nil {
+		return "", fmt.Errorf("invalid GuardDuty export key policy: %w", err)
+	}
+	return policy, nil
+}
+
+// guardDutyBucketPolicy returns the policy of the export bucket, allowing GuardDuty to
+// write the findings of the security account
+func (s *Services) guardDutyBucketPolicy(bucketArn string) (string, error) {
+	condition := map[string]interface{}{
+		"StringEquals": map[string]interface{}{"aws:SourceAccount": s.adminAccountId},
+	}
+
+	document := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Sid":       "AllowGuardDutyGetBucketLocation",
+				"Effect":    "Allow",
+				"Principal": map[string]interface{}{"Service": awsclient.ServicePrincipal(guardDutyService)},
+				"Action":    "s3:GetBucketLocation",
+				"Resource":  bucketArn,
+				"Condition": condition,
+			},
+			{
+				"Sid":       "AllowGuardDutyPutObject",
+				"Effect":    "Allow",
+				"Principal": map[string]interface{}{"Service": awsclient.ServicePrincipal(guardDutyService)},
+				"Action":    "s3:PutObject",
+				"Resource":  bucketArn + "/*",
+				"Condition": condition,
+			},
+			{
+				"Sid":       "DenyInsecureTransport",
+				"Effect":    "Deny",
+				"Principal": "*",
+				"Action":    "s3:*",
+				"Resource":  []string{bucketArn, bucketArn + "/*"},
+				"Condition": map[string]interface{}{
+					"Bool": map[string]interface{}{"aws:SecureTransport": "false"},
+				},
+			},
+		},
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal GuardDuty export bucket policy: %w", err)
+	}
+	return string(data), nil
+}
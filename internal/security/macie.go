@@ -0,0 +1,202 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	sdkaws "github.com/aws/aws-sdk-go-v2/aws"
+	sdkmacie "github.com/aws/aws-sdk-go-v2/service/macie2"
+	macietypes "github.com/aws/aws-sdk-go-v2/service/macie2/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/macie2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Macie defaults
+	DefaultMacieSamplingPercentage  = 100
+	DefaultMacieFindingsPublishFreq = "FIFTEEN_MINUTES"
+
+	// Classification job settings
+	macieJobTypeScheduled = "SCHEDULED"
+	macieJobName          = "log-archive-sensitive-data-discovery"
+)
+
+// setupMacie delegates Macie to the security account, enables it for the organization
+// and schedules a sensitive data discovery job over the log archive buckets
+func (s *Services) setupMacie(ctx *pulumi.Context, region string, cfg *config.LandingZoneConfig) error {
+	mgmt, err := s.managementProvider(ctx, region)
+	if err != nil {
+		return err
+	}
+	admin, err := s.adminProvider(ctx, region)
+	if err != nil {
+		return err
+	}
+
+	macieCfg := cfg.Macie
+	if macieCfg == nil {
+		macieCfg = &config.MacieConfig{}
+	}
+
+	publishingFrequency := macieCfg.FindingPublishingFrequency
+	if publishingFrequency == "" {
+		publishingFrequency = DefaultMacieFindingsPublishFreq
+	}
+
+	account, err := macie2.NewAccount(ctx, fmt.Sprintf("macie-account-%s", region), &macie2.AccountArgs{
+		FindingPublishingFrequency: pulumi.String(publishingFrequency),
+		Status:                     pulumi.String("ENABLED"),
+	}, pulumi.Provider(admin))
+	if err != nil {
+		return fmt.Errorf("failed to enable Macie in %s: %w", region, err)
+	}
+
+	delegation, err := macie2.NewOrganizationAdminAccount(ctx, fmt.Sprintf("macie-admin-%s", region),
+		&macie2.OrganizationAdminAccountArgs{
+			AdminAccountId: pulumi.String(s.adminAccountId),
+		}, pulumi.Provider(mgmt), pulumi.DependsOn([]pulumi.Resource{account}))
+	if err != nil {
+		return fmt.Errorf("failed to delegate Macie administration in %s: %w", region, err)
+	}
+
+	// Organization auto-enablement and Security Hub publishing have no Pulumi resource,
+	// so they are applied through the SDK once the delegation exists
+	configured := pulumi.All(delegation.ID(), account.ID()).ApplyT(func(args []interface{}) (string, error) {
+		if ctx.DryRun() {
+			return cfg.LogArchiveAccountId, nil
+		}
+		if err := s.configureMacieOrganization(ctx.Context(), region, cfg, macieCfg); err != nil {
+			return "", err
+		}
+		return cfg.LogArchiveAccountId, nil
+	}).(pulumi.StringOutput)
+
+	s.metrics.IncrementCounter("macie_regions_enabled")
+	if region != cfg.LogBucketRegion() {
+		s.logger.Info("Macie enabled", zap.String("region", region))
+		return nil
+	}
+
+	buckets := logBuckets(cfg)
+	if len(buckets) == 0 {
+		s.logger.Warn("no log buckets configured, skipping Macie discovery job")
+		return nil
+	}
+
+	if _, err := macie2.NewClassificationJob(ctx, fmt.Sprintf("macie-log-archive-job-%s", region), &macie2.ClassificationJobArgs{
+		Name:               pulumi.String(macieJobName),
+		Description:        pulumi.String("Sensitive data discovery over the log archive buckets"),
+		JobType:            pulumi.String(macieJobTypeScheduled),
+		SamplingPercentage: pulumi.Int(samplingPercentage(macieCfg)),
+		ScheduleFrequency:  scheduleFrequency(macieCfg),
+		S3JobDefinition: &macie2.ClassificationJobS3JobDefinitionArgs{
+			BucketDefinitions: macie2.ClassificationJobS3JobDefinitionBucketDefinitionArray{
+				&macie2.ClassificationJobS3JobDefinitionBucketDefinitionArgs{
+					AccountId: configured,
+					Buckets:   pulumi.ToStringArray(buckets),
+				},
+			},
+		},
+		Tags: pulumi.ToStringMap(cfg.Tags),
+	}, pulumi.Provider(admin)); err != nil {
+		return fmt.Errorf("failed to create Macie classification job in %s: %w", region, err)
+	}
+
+	s.logger.Info("Macie enabled with log archive discovery job",
+		zap.String("region", region),
+		zap.Strings("buckets", buckets))
+	return nil
+}
+
+// configureMacieOrganization enables Macie for new member accounts, associates the log
+// archive account and configures the publication of findings to Security Hub
+func (s *Services) configureMacieOrganization(ctx context.Context, region string, cfg *config.LandingZoneConfig, macieCfg *config.MacieConfig) error {
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return err
+	}
+	base.Region = region
+
+	client := sdkmacie.NewFromConfig(awsclient.AssumeRole(base, s.adminAccountId, s.roleName))
+
+	if _, err := client.UpdateOrganizationConfiguration(ctx, &sdkmacie.UpdateOrganizationConfigurationInput{
+		AutoEnable: sdkaws.Bool(true),
+	}); err != nil {
+		return fmt.Errorf("failed to enable Macie for new member accounts in %s: %w", region, err)
+	}
+
+	if _, err := client.PutFindingsPublicationConfiguration(ctx, &sdkmacie.PutFindingsPublicationConfigurationInput{
+		SecurityHubConfiguration: &macietypes.SecurityHubConfiguration{
+			PublishClassificationFindings: sdkaws.Bool(macieCfg.PublishToSecurityHub),
+			PublishPolicyFindings:         sdkaws.Bool(macieCfg.PublishToSecurityHub),
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to configure Macie findings publication in %s: %w", region, err)
+	}
+
+	if region != cfg.LogBucketRegion() || cfg.LogArchiveAccountId == "" {
+		return nil
+	}
+
+	// Existing accounts are not covered by auto-enablement, so the log archive account
+	// owning the scanned buckets is associated explicitly
+	account, err := organizations.NewFromConfig(base).DescribeAccount(ctx, &organizations.DescribeAccountInput{
+		AccountId: sdkaws.String(cfg.LogArchiveAccountId),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe log archive account: %w", err)
+	}
+
+	_, err = client.CreateMember(ctx, &sdkmacie.CreateMemberInput{
+		Account: &macietypes.AccountDetail{
+			AccountId: sdkaws.String(cfg.LogArchiveAccountId),
+			Email:     account.Account.Email,
+		},
+	})
+	var conflict *macietypes.ConflictException
+	if err != nil && !errors.As(err, &conflict) {
+		return fmt.Errorf("failed to associate log archive account with Macie: %w", err)
+	}
+
+	return nil
+}
+
+// scheduleFrequency converts the configured schedule to a job schedule
+func scheduleFrequency(cfg *config.MacieConfig) *macie2.ClassificationJobScheduleFrequencyArgs {
+	switch cfg.Schedule {
+	case "WEEKLY":
+		return &macie2.ClassificationJobScheduleFrequencyArgs{WeeklySchedule: pulumi.String(cfg.WeeklyDay)}
+	case "MONTHLY":
+		return &macie2.ClassificationJobScheduleFrequencyArgs{MonthlySchedule: pulumi.Int(cfg.MonthlyDay)}
+	default:
+		return &macie2.ClassificationJobScheduleFrequencyArgs{DailySchedule: pulumi.Bool(true)}
+	}
+}
+
+// samplingPercentage returns the share of objects analyzed by the discovery job
+func samplingPercentage(cfg *config.MacieConfig) int {
+	if cfg.SamplingPercentage == 0 {
+		return DefaultMacieSamplingPercentage
+	}
+	return cfg.SamplingPercentage
+}
+
+// logBuckets returns the configured log archive buckets
+func logBuckets(cfg *config.LandingZoneConfig) []string {
+	var buckets []string
+	for _, bucket := range []string{cfg.LogBucketName, cfg.AccessLogBucketName, cfg.FlowLogBucketName} {
+		if bucket != "" {
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package security provides organization-wide enablement of the AWS security services.
+// Version: 1.0.0
+package security
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/detective"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/inspector2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Inspector scan types
+	ScanTypeEC2        = "EC2"
+	ScanTypeECR        = "ECR"
+	ScanTypeLambda     = "LAMBDA"
+	ScanTypeLambdaCode = "LAMBDA_CODE"
+)
+
+// DefaultInspectorScanTypes are enabled when the configuration lists none
+var DefaultInspectorScanTypes = []string{ScanTypeEC2, ScanTypeECR}
+
+// Services manages the security services of the organization. Delegated administration
+// is registered from the management account, the organization configuration itself is
+// applied from the delegated administrator account.
+type Services struct {
+	logger         *zap.Logger
+	metrics        *metrics.Collector
+	mutex          sync.Mutex
+	adminAccountId string
+	roleName       string
+	adminProviders map[string]*aws.Provider
+	mgmtProviders  map[string]*aws.Provider
+}
+
+// NewServices creates a new security services instance
+func NewServices(cfg *config.LandingZoneConfig) (*Services, error) {
+	logger, err := logging.NewLogger("security")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("security")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	return &Services{
+		logger:         logger,
+		metrics:        metrics,
+		adminAccountId: cfg.SecurityAccountId,
+		roleName:       awsclient.MemberRoleName(cfg),
+		adminProviders: make(map[string]*aws.Provider),
+		mgmtProviders:  make(map[string]*aws.Provider),
+	}, nil
+}
+
+// SetupSecurityServices enables the configured security services in every governed region
+func SetupSecurityServices(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	start := time.Now()
+	s, err := NewServices(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create security services: %w", err)
+	}
+	defer func() {
+		s.metrics.RecordDuration("security_services_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup security services"); err != nil {
+		return err
+	}
+
+	if !cfg.EnableDetective && !cfg.EnableInspector && !cfg.EnableMacie && !guardDutyEnabled(cfg) {
+		s.logger.Info("no optional security services enabled")
+		return nil
+	}
+
+	if cfg.SecurityAccountId == "" {
+		return fmt.Errorf("a security account is required to delegate security services")
+	}
+
+	var export *guardDutyExport
+	if guardDutyEnabled(cfg) && cfg.GuardDuty.Export != nil {
+		if export, err = s.setupGuardDutyExport(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	for _, region := range cfg.GovernedRegions {
+		if guardDutyEnabled(cfg) {
+			if err := s.setupGuardDuty(ctx, region, cfg, export); err != nil {
+				return err
+			}
+		}
+
+		if cfg.EnableDetective {
+			if err := s.setupDetective(ctx, region, cfg); err != nil {
+				return err
+			}
+		}
+
+		if cfg.EnableInspector {
+			if err := s.setupInspector(ctx, region, cfg); err != nil {
+				return err
+			}
+		}
+
+		if cfg.EnableMacie {
+			if err := s.setupMacie(ctx, region, cfg); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.logger.Info("security services setup completed successfully")
+	return nil
+}
+
+// setupDetective delegates Detective to the security account and enables the
+// behavior graph for every member account
+func (s *Services) setupDetective(ctx *pulumi.Context, region string, cfg *config.LandingZoneConfig) error {
+	mgmt, err := s.managementProvider(ctx, region)
+	if err != nil {
+		return err
+	}
+	admin, err := s.adminProvider(ctx, region)
+	if err != nil {
+		return err
+	}
+
+	delegation, err := detective.NewOrganizationAdminAccount(ctx, fmt.Sprintf("detective-admin-%s", region),
+		&detective.OrganizationAdminAccountArgs{
+			AccountId: pulumi.String(s.adminAccountId),
+		}, pulumi.Provider(mgmt))
+	if err != nil {
+		return fmt.Errorf("failed to delegate Detective administration in %s: %w", region, err)
+	}
+
+	graph, err := detective.NewGraph(ctx, fmt.Sprintf("detective-graph-%s", region), &detective.GraphArgs{
+		Tags: pulumi.ToStringMap(cfg.Tags),
+	}, pulumi.Provider(admin), pulumi.DependsOn([]pulumi.Resource{delegation}))
+	if err != nil {
+		return fmt.Errorf("failed to create Detective behavior graph in %s: %w", region, err)
+	}
+
+	if _, err := detective.NewOrganizationConfiguration(ctx, fmt.Sprintf("detective-org-config-%s", region),
+		&detective.OrganizationConfigurationArgs{
+			AutoEnable: pulumi.Bool(true),
+			GraphArn:   graph.ID(),
+		}, pulumi.Provider(admin)); err != nil {
+		return fmt.Errorf("failed to configure Detective organization in %s: %w", region, err)
+	}
+
+	s.metrics.IncrementCounter("detective_regions_enabled")
+	s.logger.Info("Detective enabled", zap.String("region", region))
+	return nil
+}
+
+// setupInspector delegates Inspector to the security account and auto-enables the
+// configured scan types for every member account
+func (s *Services) setupInspector(ctx *pulumi.Context, region string, cfg *config.LandingZoneConfig) error {
+	mgmt, err := s.managementProvider(ctx, region)
+	if err != nil {
+		return err
+	}
+	admin, err := s.adminProvider(ctx, region)
+	if err != nil {
+		return err
+	}
+
+	scanTypes := cfg.InspectorScanTypes
+	if len(scanTypes) == 0 {
+		scanTypes = DefaultInspectorScanTypes
+	}
+	enabled := make(map[string]bool)
+	for _, scanType := range scanTypes {
+		enabled[scanType] = true
+	}
+
+	delegation, err := inspector2.NewDelegatedAdminAccount(ctx, fmt.Sprintf("inspector-admin-%s", region),
+		&inspector2.DelegatedAdminAccountArgs{
+			AccountId: pulumi.String(s.adminAccountId),
+		}, pulumi.Provider(mgmt))
+	if err != nil {
+		return fmt.Errorf("failed to delegate Inspector administration in %s: %w", region, err)
+	}
+
+	// The delegated administrator must itself be enabled before it can configure the organization
+	enabler, err := inspector2.NewEnabler(ctx, fmt.Sprintf("inspector-enabler-%s", region), &inspector2.EnablerArgs{
+		AccountIds:    pulumi.ToStringArray([]string{s.adminAccountId}),
+		ResourceTypes: pulumi.ToStringArray(scanTypes),
+	}, pulumi.Provider(admin), pulumi.DependsOn([]pulumi.Resource{delegation}))
+	if err != nil {
+		return fmt.Errorf("failed to enable Inspector in %s: %w", region, err)
+	}
+
+	if _, err := inspector2.NewOrganizationConfiguration(ctx, fmt.Sprintf("inspector-org-config-%s", region),
+		&inspector2.OrganizationConfigurationArgs{
+			AutoEnable: &inspector2.OrganizationConfigurationAutoEnableArgs{
+				Ec2:        pulumi.Bool(enabled[ScanTypeEC2]),
+				Ecr:        pulumi.Bool(enabled[ScanTypeECR]),
+				Lambda:     pulumi.Bool(enabled[ScanTypeLambda]),
+				LambdaCode: pulumi.Bool(enabled[ScanTypeLambdaCode]),
+			},
+		}, pulumi.Provider(admin), pulumi.DependsOn([]pulumi.Resource{enabler})); err != nil {
+		return fmt.Errorf("failed to configure Inspector organization in %s: %w", region, err)
+	}
+
+	s.metrics.IncrementCounter("inspector_regions_enabled")
+	s.logger.Info("Inspector enabled",
+		zap.String("region", region),
+		zap.Strings("scanTypes", scanTypes))
+	return nil
+}
+
+// managementProvider returns the provider of the management account for a region
+func (s *Services) managementProvider(ctx *pulumi.Context, region string) (*aws.Provider, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if provider, ok := s.mgmtProviders[region]; ok {
+		return provider, nil
+	}
+
+	provider, err := aws.NewProvider(ctx, fmt.Sprintf("security-mgmt-%s", region), &aws.ProviderArgs{
+		Region: pulumi.String(region),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create management provider for %s: %w", region, err)
+	}
+
+	s.mgmtProviders[region] = provider
+	return provider, nil
+}
+
+// adminProvider returns the provider of the delegated administrator account for a region
+func (s *Services) adminProvider(ctx *pulumi.Context, region string) (*aws.Provider, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if provider, ok := s.adminProviders[region]; ok {
+		return provider, nil
+	}
+
+	provider, err := aws.NewProvider(ctx, fmt.Sprintf("security-admin-%s", region), &aws.ProviderArgs{
+		Region: pulumi.String(region),
+		AssumeRole: &aws.ProviderAssumeRoleArgs{
+			RoleArn:     pulumi.String(awsclient.RoleArn(s.adminAccountId, s.roleName)),
+			SessionName: pulumi.String(awsclient.SessionName),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create security account provider for %s: %w", region, err)
+	}
+
+	s.adminProviders[region] = provider
+	return provider, nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package selection provides module selection for partial deployments of the landing zone.
+// Version: 1.0.0
+package selection
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// Module names accepted by --only and --skip
+	ModuleOrganization = "organization"
+	ModuleControlTower = "controltower"
+	ModuleSecurity     = "security"
+	ModuleNetworking   = "networking"
+	ModuleBaseline     = "baseline"
+	ModuleBilling      = "billing"
+
+	// Environment variables carrying the selection into the Pulumi program
+	EnvOnly = "AWS_ORG_ONLY"
+	EnvSkip = "AWS_ORG_SKIP"
+
+	// EnvTargeted is set by the deploy command when the update is restricted to the
+	// URNs of the selected modules. Without it a partial selection could only be
+	// previewed, since the engine would delete the resources of skipped modules.
+	EnvTargeted = "AWS_ORG_TARGETED_UPDATE"
+)
+
+// Modules lists every module that can be selected
+var Modules = []string{
+	ModuleOrganization,
+	ModuleControlTower,
+	ModuleSecurity,
+	ModuleNetworking,
+	ModuleBaseline,
+	ModuleBilling,
+}
+
+// Selection represents the set of modules a run applies
+type Selection struct {
+	only map[string]bool
+	skip map[string]bool
+}
+
+// New creates a selection from the --only and --skip lists
+func New(only, skip []string) (*Selection, error) {
+	s := &Selection{
+		only: make(map[string]bool),
+		skip: make(map[string]bool),
+	}
+
+	for _, name := range only {
+		if err := validate(name); err != nil {
+			return nil, err
+		}
+		s.only[name] = true
+	}
+
+	for _, name := range skip {
+		if err := validate(name); err != nil {
+			return nil, err
+		}
+		if s.only[name] {
+			return nil, fmt.Errorf("module %q cannot be both selected and skipped", name)
+		}
+		s.skip[name] = true
+	}
+
+	return s, nil
+}
+
+// All returns a selection that applies every module
+func All() *Selection {
+	s, _ := New(nil, nil)
+	return s
+}
+
+// Enabled reports whether the module is part of the selection
+func (s *Selection) Enabled(module string) bool {
+	if s.skip[module] {
+		return false
+	}
+	return len(s.only) == 0 || s.only[module]
+}
+
+// Partial reports whether at least one module is left out
+func (s *Selection) Partial() bool {
+	for _, module := range Modules {
+		if !s.Enabled(module) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnabledModules returns the selected modules in a stable order
+func (s *Selection) EnabledModules() []string {
+	var modules []string
+	for _, module := range Modules {
+		if s.Enabled(module) {
+			modules = append(modules, module)
+		}
+	}
+	return modules
+}
+
+// Env returns the environment variables that reproduce the selection in the Pulumi program
+func (s *Selection) Env() map[string]string {
+	return map[string]string{
+		EnvOnly: strings.Join(keys(s.only), ","),
+		EnvSkip: strings.Join(keys(s.skip), ","),
+	}
+}
+
+// String returns a human readable description of the selection
+func (s *Selection) String() string {
+	return strings.Join(s.EnabledModules(), ",")
+}
+
+// validate checks that a module name is known
+func validate(name string) error {
+	for _, module := range Modules {
+		if module == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown module %q, valid modules are: %s", name, strings.Join(Modules, ", "))
+}
+
+// keys returns the sorted keys of a set
+func keys(set map[string]bool) []string {
+	result := make([]string, 0, len(set))
+	for key := range set {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
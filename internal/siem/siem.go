@@ -0,0 +1,185 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package siem provides the forwarding of the organization trail and the security
+// findings to an external SIEM through Kinesis Data Firehose.
+// Version: 1.0.0
+package siem
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/organizations"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// OutputStreamArns is the stack output holding the ARN of the delivery stream of
+	// each region
+	OutputStreamArns = "siemStreamArns"
+
+	// Services assuming the roles of the forwarding
+	eventsService   = "events"
+	firehoseService = "firehose"
+	lambdaService   = "lambda"
+	logsService     = "logs"
+
+	// Service decrypting the token secret for the delivery streams
+	secretsManagerService = "secretsmanager"
+)
+
+// SIEM holds the state of the SIEM forwarding setup
+type SIEM struct {
+	logger       *zap.Logger
+	metrics      *metrics.Collector
+	cfg          *config.SIEMConfig
+	lz           *config.LandingZoneConfig
+	tags         map[string]string
+	names        config.ResourceNames
+	accountId    string
+	roleName     string
+	managementId string
+	providers    *awsclient.Providers
+}
+
+// SetupSIEM creates in the SIEM account, in every SIEM region, the Lambda function
+// shaping the events for the SIEM, the delivery stream sending them to it and the
+// EventBridge rules forwarding the GuardDuty and Security Hub findings to the stream.
+// In the home region, the CloudTrail log group of the management account is subscribed
+// to the stream too.
+func SetupSIEM(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("siem")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("siem")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("siem_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup SIEM forwarding"); err != nil {
+		return err
+	}
+
+	if cfg.SIEM == nil {
+		logger.Info("no SIEM forwarding configured")
+		return nil
+	}
+
+	org, err := organizations.LookupOrganization(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look up organization: %w", err)
+	}
+
+	s := &SIEM{
+		logger:       logger,
+		metrics:      metrics,
+		cfg:          cfg.SIEM,
+		lz:           cfg,
+		tags:         cfg.Tags,
+		names:        cfg.ResourceNames(),
+		accountId:    cfg.SIEMAccountId(),
+		roleName:     awsclient.MemberRoleName(cfg),
+		managementId: org.MasterAccountId,
+		providers:    awsclient.NewProviders("siem", org.MasterAccountId, awsclient.MemberRoleName(cfg)),
+	}
+
+	streamArns := pulumi.StringMap{}
+	for _, region := range cfg.SIEMRegions() {
+		provider, err := s.providers.Get(ctx, s.accountId, region)
+		if err != nil {
+			return err
+		}
+
+		function, err := s.transformFunction(ctx, region, provider)
+		if err != nil {
+			return err
+		}
+		stream, err := s.deliveryStream(ctx, region, function, provider)
+		if err != nil {
+			return err
+		}
+		streamArns[region] = stream.Arn
+
+		if err := s.forwardFindings(ctx, region, stream, provider); err != nil {
+			return err
+		}
+		if region == cfg.HomeRegion && s.cfg.Forwards(config.SIEMSourceCloudTrail) {
+			if err := s.forwardTrail(ctx, region, stream, provider); err != nil {
+				return err
+			}
+		}
+	}
+	ctx.Export(OutputStreamArns, streamArns)
+
+	logger.Info("SIEM forwarding setup completed successfully",
+		zap.String("type", s.cfg.Type),
+		zap.String("account", s.accountId),
+		zap.Strings("regions", cfg.SIEMRegions()))
+	return nil
+}
+
+// role creates a role of the SIEM account assumed by a service under the given
+// condition, with an inline policy
+func (s *SIEM) role(ctx *pulumi.Context, name string, resource config.NamedResource, region, description, service string, condition map[string]interface{}, policy pulumi.StringInput, provider *aws.Provider) (*iam.Role, error) {
+	statement := map[string]interface{}{
+		"Effect":    "Allow",
+		"Principal": map[string]string{"Service": awsclient.ServicePrincipal(service)},
+		"Action":    "sts:AssumeRole",
+	}
+	if condition != nil {
+		statement["Condition"] = condition
+	}
+	trust, err := awsclient.PolicyDocument([]map[string]interface{}{statement})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal trust policy: %w", err)
+	}
+
+	role, err := iam.NewRole(ctx, name, &iam.RoleArgs{
+		Name:             component.Name(s.names.Name(resource, region)),
+		Description:      pulumi.String(description),
+		AssumeRolePolicy: pulumi.String(trust),
+		Tags:             pulumi.ToStringMap(s.tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := iam.NewRolePolicy(ctx, name, &iam.RolePolicyArgs{
+		Role:   role.ID(),
+		Policy: policy,
+	}, pulumi.Provider(provider)); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// putRecordPolicy returns the policy letting a service put records into a stream
+func putRecordPolicy(stream pulumi.StringOutput) pulumi.StringOutput {
+	return stream.ApplyT(func(streamArn string) (string, error) {
+		return awsclient.PolicyDocument([]map[string]interface{}{
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"firehose:PutRecord", "firehose:PutRecordBatch"},
+				"Resource": streamArn,
+			},
+		})
+	}).(pulumi.StringOutput)
+}
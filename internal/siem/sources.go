@@ -0,0 +1,135 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package siem
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kinesis"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// findingSources are the EventBridge sources and detail types of the findings
+// forwarded to the SIEM
+var findingSources = []struct {
+	source      string
+	eventSource string
+	detailType  string
+}{
+	{config.SIEMSourceGuardDuty, "aws.guardduty", "GuardDuty Finding"},
+	{config.SIEMSourceSecurityHub, "aws.securityhub", "Security Hub Findings - Imported"},
+}
+
+// forwardFindings creates the rules forwarding the GuardDuty and Security Hub findings
+// of a region to its delivery stream
+func (s *SIEM) forwardFindings(ctx *pulumi.Context, region string, stream *kinesis.FirehoseDeliveryStream, provider *aws.Provider) error {
+	var role *iam.Role
+	for _, finding := range findingSources {
+		if !s.cfg.Forwards(finding.source) {
+			continue
+		}
+
+		if role == nil {
+			var err error
+			role, err = s.role(ctx, fmt.Sprintf("siem-events-%s", region), config.RoleSIEMEvents, region,
+				"Forwards the security findings to the SIEM delivery stream", eventsService,
+				map[string]interface{}{"StringEquals": map[string]string{"aws:SourceAccount": s.accountId}},
+				putRecordPolicy(stream.Arn), provider)
+			if err != nil {
+				return fmt.Errorf("failed to create SIEM events role in %s: %w", region, err)
+			}
+		}
+
+		pattern, err := json.Marshal(map[string]interface{}{
+			"source":      []string{finding.eventSource},
+			"detail-type": []string{finding.detailType},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s event pattern: %w", finding.source, err)
+		}
+
+		name := fmt.Sprintf("%s-%s", s.cfg.StreamName(), finding.source)
+		rule, err := cloudwatch.NewEventRule(ctx, fmt.Sprintf("siem-%s-%s", finding.source, region), &cloudwatch.EventRuleArgs{
+			Name:         pulumi.String(name),
+			Description:  pulumi.String(fmt.Sprintf("Forwards the %s findings to the SIEM", finding.detailType)),
+			EventPattern: pulumi.String(string(pattern)),
+			Tags:         pulumi.ToStringMap(s.tags),
+		}, pulumi.Provider(provider))
+		if err != nil {
+			return fmt.Errorf("failed to create SIEM %s rule in %s: %w", finding.source, region, err)
+		}
+
+		if _, err := cloudwatch.NewEventTarget(ctx, fmt.Sprintf("siem-%s-%s", finding.source, region), &cloudwatch.EventTargetArgs{
+			Rule:    rule.Name,
+			Arn:     stream.Arn,
+			RoleArn: role.Arn,
+		}, pulumi.Provider(provider)); err != nil {
+			return fmt.Errorf("failed to forward %s findings to the SIEM in %s: %w", finding.source, region, err)
+		}
+		s.metrics.IncrementCounter("siem_rules_created")
+	}
+	return nil
+}
+
+// forwardTrail subscribes the CloudTrail log group of the management account to the
+// delivery stream of its region, through a destination of the SIEM account accepting
+// subscriptions from the management account only
+func (s *SIEM) forwardTrail(ctx *pulumi.Context, region string, stream *kinesis.FirehoseDeliveryStream, provider *aws.Provider) error {
+	role, err := s.role(ctx, fmt.Sprintf("siem-subscription-%s", region), config.RoleSIEMSubscription, region,
+		"Puts the trail events into the SIEM delivery stream", logsService,
+		map[string]interface{}{"StringEquals": map[string][]string{"aws:SourceAccount": {s.managementId, s.accountId}}},
+		putRecordPolicy(stream.Arn), provider)
+	if err != nil {
+		return fmt.Errorf("failed to create SIEM subscription role in %s: %w", region, err)
+	}
+
+	destination, err := cloudwatch.NewLogDestination(ctx, fmt.Sprintf("siem-trail-%s", region), &cloudwatch.LogDestinationArgs{
+		Name:      pulumi.String(s.cfg.StreamName()),
+		RoleArn:   role.Arn,
+		TargetArn: stream.Arn,
+		Tags:      pulumi.ToStringMap(s.tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return fmt.Errorf("failed to create SIEM trail destination in %s: %w", region, err)
+	}
+
+	policy, err := awsclient.PolicyDocument([]map[string]interface{}{{
+		"Effect":    "Allow",
+		"Principal": map[string]string{"AWS": s.managementId},
+		"Action":    "logs:PutSubscriptionFilter",
+		"Resource": fmt.Sprintf("arn:%s:logs:%s:%s:destination:%s",
+			awsclient.Partition(), region, s.accountId, s.cfg.StreamName()),
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal SIEM trail destination policy: %w", err)
+	}
+	destinationPolicy, err := cloudwatch.NewLogDestinationPolicy(ctx, fmt.Sprintf("siem-trail-%s", region), &cloudwatch.LogDestinationPolicyArgs{
+		DestinationName: destination.Name,
+		AccessPolicy:    pulumi.String(policy),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return fmt.Errorf("failed to create SIEM trail destination policy in %s: %w", region, err)
+	}
+
+	mgmt, err := s.providers.Get(ctx, s.managementId, region)
+	if err != nil {
+		return err
+	}
+	if _, err := cloudwatch.NewLogSubscriptionFilter(ctx, fmt.Sprintf("siem-trail-%s", region), &cloudwatch.LogSubscriptionFilterArgs{
+		Name:           pulumi.String(s.cfg.StreamName()),
+		LogGroup:       pulumi.String(s.lz.CloudTrailLogGroup),
+		FilterPattern:  pulumi.String(""),
+		DestinationArn: destination.Arn,
+	}, pulumi.Provider(mgmt), pulumi.DependsOn([]pulumi.Resource{destinationPolicy})); err != nil {
+		return fmt.Errorf("failed to subscribe the CloudTrail log group to the SIEM in %s: %w", region, err)
+	}
+	return nil
+}
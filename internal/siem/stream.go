@@ -0,0 +1,238 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package siem
+
+import (
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kinesis"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/lambda"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// Destinations of the delivery streams
+	destinationSplunk     = "splunk"
+	destinationHTTP       = "http_endpoint"
+	destinationOpenSearch = "opensearch"
+
+	// Processor invoking the transformation function
+	processorLambda = "Lambda"
+	parameterLambda = "LambdaArn"
+
+	// Prefix of the records the SIEM rejected in the backup bucket
+	backupPrefix = "siem-failed/"
+
+	// Splunk endpoint accepting events in the HEC event format
+	hecEndpointEvent = "Event"
+
+	// Period the OpenSearch index is rotated at
+	indexRotationDaily = "OneDay"
+)
+
+// deliveryStream creates the delivery stream of a region sending the events shaped by
+// the transformation function to the SIEM
+func (s *SIEM) deliveryStream(ctx *pulumi.Context, region string, function *lambda.Function, provider *aws.Provider) (*kinesis.FirehoseDeliveryStream, error) {
+	role, err := s.deliveryRole(ctx, region, function, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	args := &kinesis.FirehoseDeliveryStreamArgs{
+		Name: pulumi.String(s.cfg.StreamName()),
+		Tags: pulumi.ToStringMap(s.tags),
+	}
+	bucketArn := pulumi.String(awsclient.BucketArn(s.cfg.BackupBucket))
+	processor := pulumi.StringInput(function.Arn)
+
+	switch s.cfg.Type {
+	case config.SIEMSplunk:
+		args.Destination = pulumi.String(destinationSplunk)
+		args.SplunkConfiguration = &kinesis.FirehoseDeliveryStreamSplunkConfigurationArgs{
+			HecEndpoint:     pulumi.String(s.cfg.Endpoint),
+			HecEndpointType: pulumi.String(hecEndpointEvent),
+			S3BackupMode:    pulumi.String("FailedEventsOnly"),
+			S3Configuration: &kinesis.FirehoseDeliveryStreamSplunkConfigurationS3ConfigurationArgs{
+				BucketArn:         bucketArn,
+				RoleArn:           role.Arn,
+				Prefix:            pulumi.String(backupPrefix),
+				CompressionFormat: pulumi.String("GZIP"),
+			},
+			SecretsManagerConfiguration: &kinesis.FirehoseDeliveryStreamSplunkConfigurationSecretsManagerConfigurationArgs{
+				Enabled:   pulumi.Bool(true),
+				SecretArn: pulumi.String(s.cfg.TokenSecretArn),
+				RoleArn:   role.Arn,
+			},
+			ProcessingConfiguration: &kinesis.FirehoseDeliveryStreamSplunkConfigurationProcessingConfigurationArgs{
+				Enabled: pulumi.Bool(true),
+				Processors: kinesis.FirehoseDeliveryStreamSplunkConfigurationProcessingConfigurationProcessorArray{
+					&kinesis.FirehoseDeliveryStreamSplunkConfigurationProcessingConfigurationProcessorArgs{
+						Type: pulumi.String(processorLambda),
+						Parameters: kinesis.FirehoseDeliveryStreamSplunkConfigurationProcessingConfigurationProcessorParameterArray{
+							&kinesis.FirehoseDeliveryStreamSplunkConfigurationProcessingConfigurationProcessorParameterArgs{
+								ParameterName:  pulumi.String(parameterLambda),
+								ParameterValue: processor,
+							},
+						},
+					},
+				},
+			},
+		}
+
+	case config.SIEMHTTP:
+		http := &kinesis.FirehoseDeliveryStreamHttpEndpointConfigurationArgs{
+			Url:          pulumi.String(s.cfg.Endpoint),
+			Name:         pulumi.String(s.cfg.StreamName()),
+			RoleArn:      role.Arn,
+			S3BackupMode: pulumi.String("FailedDataOnly"),
+			S3Configuration: &kinesis.FirehoseDeliveryStreamHttpEndpointConfigurationS3ConfigurationArgs{
+				BucketArn:         bucketArn,
+				RoleArn:           role.Arn,
+				Prefix:            pulumi.String(backupPrefix),
+				CompressionFormat: pulumi.String("GZIP"),
+			},
+			RequestConfiguration: &kinesis.FirehoseDeliveryStreamHttpEndpointConfigurationRequestConfigurationArgs{
+				ContentEncoding: pulumi.String("GZIP"),
+			},
+			ProcessingConfiguration: &kinesis.FirehoseDeliveryStreamHttpEndpointConfigurationProcessingConfigurationArgs{
+				Enabled: pulumi.Bool(true),
+				Processors: kinesis.FirehoseDeliveryStreamHttpEndpointConfigurationProcessingConfigurationProcessorArray{
+					&kinesis.FirehoseDeliveryStreamHttpEndpointConfigurationProcessingConfigurationProcessorArgs{
+						Type: pulumi.String(processorLambda),
+						Parameters: kinesis.FirehoseDeliveryStreamHttpEndpointConfigurationProcessingConfigurationProcessorParameterArray{
+							&kinesis.FirehoseDeliveryStreamHttpEndpointConfigurationProcessingConfigurationProcessorParameterArgs{
+								ParameterName:  pulumi.String(parameterLambda),
+								ParameterValue: processor,
+							},
+						},
+					},
+				},
+			},
+		}
+		if s.cfg.TokenSecretArn != "" {
+			http.SecretsManagerConfiguration = &kinesis.FirehoseDeliveryStreamHttpEndpointConfigurationSecretsManagerConfigurationArgs{
+				Enabled:   pulumi.Bool(true),
+				SecretArn: pulumi.String(s.cfg.TokenSecretArn),
+				RoleArn:   role.Arn,
+			}
+		}
+		args.Destination = pulumi.String(destinationHTTP)
+		args.HttpEndpointConfiguration = http
+
+	case config.SIEMOpenSearch:
+		args.Destination = pulumi.String(destinationOpenSearch)
+		args.OpensearchConfiguration = &kinesis.FirehoseDeliveryStreamOpensearchConfigurationArgs{
+			DomainArn:           pulumi.String(s.cfg.DomainArn),
+			IndexName:           pulumi.String(s.cfg.Index()),
+			IndexRotationPeriod: pulumi.String(indexRotationDaily),
+			RoleArn:             role.Arn,
+			S3BackupMode:        pulumi.String("FailedDocumentsOnly"),
+			S3Configuration: &kinesis.FirehoseDeliveryStreamOpensearchConfigurationS3ConfigurationArgs{
+				BucketArn:         bucketArn,
+				RoleArn:           role.Arn,
+				Prefix:            pulumi.String(backupPrefix),
+				CompressionFormat: pulumi.String("GZIP"),
+			},
+			ProcessingConfiguration: &kinesis.FirehoseDeliveryStreamOpensearchConfigurationProcessingConfigurationArgs{
+				Enabled: pulumi.Bool(true),
+				Processors: kinesis.FirehoseDeliveryStreamOpensearchConfigurationProcessingConfigurationProcessorArray{
+					&kinesis.FirehoseDeliveryStreamOpensearchConfigurationProcessingConfigurationProcessorArgs{
+						Type: pulumi.String(processorLambda),
+						Parameters: kinesis.FirehoseDeliveryStreamOpensearchConfigurationProcessingConfigurationProcessorParameterArray{
+							&kinesis.FirehoseDeliveryStreamOpensearchConfigurationProcessingConfigurationProcessorParameterArgs{
+								ParameterName:  pulumi.String(parameterLambda),
+								ParameterValue: processor,
+							},
+						},
+					},
+				},
+			},
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported SIEM type %q", s.cfg.Type)
+	}
+
+	stream, err := kinesis.NewFirehoseDeliveryStream(ctx, fmt.Sprintf("siem-stream-%s", region), args, pulumi.Provider(provider))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SIEM delivery stream in %s: %w", region, err)
+	}
+	return stream, nil
+}
+
+// deliveryRole creates the role the delivery stream of a region assumes to invoke the
+// transformation function, read the token, write to the SIEM and back up the records
+// it rejects
+func (s *SIEM) deliveryRole(ctx *pulumi.Context, region string, function *lambda.Function, provider *aws.Provider) (*iam.Role, error) {
+	bucketArn := awsclient.BucketArn(s.cfg.BackupBucket)
+	policy := function.Arn.ApplyT(func(functionArn string) (string, error) {
+		statements := []map[string]interface{}{
+			{
+				"Effect": "Allow",
+				"Action": []string{
+					"s3:AbortMultipartUpload",
+					"s3:GetBucketLocation",
+					"s3:GetObject",
+					"s3:ListBucket",
+					"s3:ListBucketMultipartUploads",
+					"s3:PutObject",
+				},
+				"Resource": []string{bucketArn, bucketArn + "/*"},
+			},
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"lambda:InvokeFunction", "lambda:GetFunctionConfiguration"},
+				"Resource": []string{functionArn, functionArn + ":*"},
+			},
+		}
+		if s.cfg.TokenSecretArn != "" {
+			// A secret encrypted with a customer managed key is only readable with
+			// Decrypt on the key, limited to Secrets Manager decrypting this secret
+			statements = append(statements, map[string]interface{}{
+				"Effect":   "Allow",
+				"Action":   "secretsmanager:GetSecretValue",
+				"Resource": s.cfg.TokenSecretArn,
+			}, map[string]interface{}{
+				"Effect":   "Allow",
+				"Action":   "kms:Decrypt",
+				"Resource": "*",
+				"Condition": map[string]interface{}{
+					"StringLike": map[string]string{"kms:ViaService": secretsManagerService + ".*.amazonaws.com"},
+					"StringEquals": map[string]string{
+						"kms:EncryptionContext:SecretARN": s.cfg.TokenSecretArn,
+					},
+				},
+			})
+		}
+		if s.cfg.Type == config.SIEMOpenSearch {
+			statements = append(statements, map[string]interface{}{
+				"Effect": "Allow",
+				"Action": []string{
+					"es:DescribeDomain",
+					"es:DescribeDomains",
+					"es:DescribeDomainConfig",
+					"es:ESHttpGet",
+					"es:ESHttpPost",
+					"es:ESHttpPut",
+				},
+				"Resource": []string{s.cfg.DomainArn, s.cfg.DomainArn + "/*"},
+			})
+		}
+		return awsclient.PolicyDocument(statements)
+	}).(pulumi.StringOutput)
+
+	role, err := s.role(ctx, fmt.Sprintf("siem-delivery-%s", region), config.RoleSIEMDelivery, region,
+		"Delivers the trail events and security findings to the SIEM", firehoseService,
+		map[string]interface{}{"StringEquals": map[string]string{"aws:SourceAccount": s.accountId}},
+		policy, provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SIEM delivery role in %s: %w", region, err)
+	}
+	return role, nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package siem
+
+import (
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/lambda"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// Runtime and handler of the transformation function
+	transformRuntime = "python3.12"
+	transformHandler = "index.handler"
+
+	// Seconds the transformation function may run, the longest Firehose allows
+	transformTimeout = 60
+)
+
+// transformSource is the code of the transformation function. Records put by
+// EventBridge hold one event; records of a log group subscription are gzipped batches
+// of trail events. Splunk and HTTPS endpoints receive one JSON event per line, tagged
+// with its source; OpenSearch receives one document per record, so the events of a
+// batch are grouped into one document.
+const transformSource = `import base64
+import gzip
+import json
+import os
+
+FORMAT = os.environ["SIEM_FORMAT"]
+
+
+def events(data):
+    try:
+        payload = json.loads(gzip.decompress(data))
+    except OSError:
+        event = json.loads(data)
+        yield event.get("source", "aws.events").replace("aws.", "", 1), event
+        return
+    if payload.get("messageType") != "DATA_MESSAGE":
+        return
+    for log_event in payload["logEvents"]:
+        yield "cloudtrail", json.loads(log_event["message"])
+
+
+def render(batch):
+    if FORMAT == "opensearch":
+        if len(batch) == 1:
+            source, event = batch[0]
+            return json.dumps({"source": source, "event": event})
+        return json.dumps({"source": batch[0][0], "events": [event for _, event in batch]})
+    lines = []
+    for source, event in batch:
+        if FORMAT == "splunk":
+            lines.append(json.dumps({"source": source, "sourcetype": "aws:" + source, "event": event}))
+        else:
+            lines.append(json.dumps({"source": source, "event": event}))
+    return "\n".join(lines) + "\n"
+
+
+def handler(event, context):
+    output = []
+    for record in event["records"]:
+        batch = list(events(base64.b64decode(record["data"])))
+        if not batch:
+            output.append({"recordId": record["recordId"], "result": "Dropped", "data": record["data"]})
+            continue
+        data = base64.b64encode(render(batch).encode()).decode()
+        output.append({"recordId": record["recordId"], "result": "Ok", "data": data})
+    return {"records": output}
+`
+
+// transformFunction creates the function shaping the events of a region for the SIEM
+func (s *SIEM) transformFunction(ctx *pulumi.Context, region string, provider *aws.Provider) (*lambda.Function, error) {
+	role, err := iam.NewRole(ctx, fmt.Sprintf("siem-transform-%s", region), &iam.RoleArgs{
+		Name:        component.Name(s.names.Name(config.RoleSIEMTransform, region)),
+		Description: pulumi.String("Shapes the events forwarded to the SIEM"),
+		AssumeRolePolicy: pulumi.String(fmt.Sprintf(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Allow",
+				"Principal": {
+					"Service": "%s"
+				},
+				"Action": "sts:AssumeRole"
+			}]
+		}`, awsclient.ServicePrincipal(lambdaService))),
+		ManagedPolicyArns: pulumi.ToStringArray([]string{awsclient.PolicyArn("service-role/AWSLambdaBasicExecutionRole")}),
+		Tags:              pulumi.ToStringMap(s.tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SIEM transformation role in %s: %w", region, err)
+	}
+
+	function, err := lambda.NewFunction(ctx, fmt.Sprintf("siem-transform-%s", region), &lambda.FunctionArgs{
+		Name:        pulumi.String(s.cfg.StreamName()),
+		Description: pulumi.String("Shapes the trail events and security findings for the SIEM"),
+		Runtime:     pulumi.String(transformRuntime),
+		Handler:     pulumi.String(transformHandler),
+		Role:        role.Arn,
+		Timeout:     pulumi.Int(transformTimeout),
+		Code: pulumi.NewAssetArchive(map[string]interface{}{
+			"index.py": pulumi.NewStringAsset(transformSource),
+		}),
+		Environment: &lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap{"SIEM_FORMAT": pulumi.String(s.cfg.Type)},
+		},
+		Tags: pulumi.ToStringMap(s.tags),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SIEM transformation function in %s: %w", region, err)
+	}
+	return function, nil
+}
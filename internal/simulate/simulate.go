@@ -0,0 +1,495 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package simulate provides the what-if analysis of changes to the organization before they are made.
+// Version: 1.0.0
+package simulate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/invitations"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/orgcache"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ct "github.com/aws/aws-sdk-go-v2/service/controltower"
+	cttypes "github.com/aws/aws-sdk-go-v2/service/controltower/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"go.uber.org/zap"
+)
+
+// Kinds of the governance an account inherits from its OUs
+const (
+	KindSCP       = "scp"
+	KindTagPolicy = "tagPolicy"
+	KindControl   = "control"
+	KindBaseline  = "baseline"
+	KindBlueprint = "blueprint"
+)
+
+// kindOrder sorts the changes of a move
+var kindOrder = map[string]int{KindSCP: 0, KindTagPolicy: 1, KindControl: 2, KindBaseline: 3, KindBlueprint: 4}
+
+// Governance is a policy, control, baseline or blueprint applying to an account
+// through the root or one of its OUs
+type Governance struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Via is the root or OU the governance is attached to or enabled on
+	Via string `json:"via"`
+}
+
+// key identifies a governance regardless of where it is attached
+func (g Governance) key() string {
+	return g.Kind + "/" + g.ID
+}
+
+// Move is the delta of governance an account would see moving to another OU
+type Move struct {
+	AccountID   string       `json:"accountId"`
+	AccountName string       `json:"accountName"`
+	From        []string     `json:"from"`
+	To          []string     `json:"to"`
+	Gained      []Governance `json:"gained"`
+	Lost        []Governance `json:"lost"`
+}
+
+// node is the root or an OU of a chain
+type node struct {
+	id   string
+	name string
+	root bool
+}
+
+// Simulator reads the governance of the live organization. The OU tree is served
+// from the organization cache.
+type Simulator struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	cfg       *config.LandingZoneConfig
+	cache     *orgcache.Cache
+	orgClient *organizations.Client
+	ctClient  *ct.Client
+
+	// Governance of each node, read once
+	governance map[string][]Governance
+	baselines  map[string]string
+}
+
+// NewSimulator creates a simulator using the credentials of the management account
+func NewSimulator(ctx context.Context, cfg *config.LandingZoneConfig, cache *orgcache.Cache) (*Simulator, error) {
+	logger, err := logging.NewLogger("simulate")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("simulate")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Simulator{
+		logger:     logger,
+		metrics:    metrics,
+		cfg:        cfg,
+		cache:      cache,
+		orgClient:  organizations.NewFromConfig(base),
+		ctClient:   ct.NewFromConfig(base),
+		governance: make(map[string][]Governance),
+	}, nil
+}
+
+// Move computes the SCPs, tag policies, Control Tower controls and baselines, and
+// the blueprints of the configuration, an account would gain and lose moving to an
+// OU given by ID or name. Nothing is moved. Governance is inherited from the root
+// and every OU above the account, so what the two OUs share is left out.
+func (s *Simulator) Move(ctx context.Context, accountID, target string) (*Move, error) {
+	start := time.Now()
+	defer func() {
+		s.metrics.RecordDuration("simulate_move", time.Since(start))
+	}()
+
+	account, err := s.orgClient.DescribeAccount(ctx, &organizations.DescribeAccountInput{AccountId: aws.String(accountID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe account %s: %w", accountID, err)
+	}
+	parents, err := s.orgClient.ListParents(ctx, &organizations.ListParentsInput{ChildId: aws.String(accountID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list parents of %s: %w", accountID, err)
+	}
+	if len(parents.Parents) == 0 {
+		return nil, fmt.Errorf("account %s has no parent", accountID)
+	}
+
+	rootID, err := s.cache.RootID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ous, err := s.cache.OUs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	targetID, err := resolveOU(ous, rootID, target)
+	if err != nil {
+		return nil, err
+	}
+	sourceID := aws.ToString(parents.Parents[0].Id)
+	if targetID == sourceID {
+		return nil, fmt.Errorf("account %s is already in %s", accountID, target)
+	}
+
+	from, err := chain(ous, rootID, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := chain(ous, rootID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	ouArn, err := s.ouArnPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+	before, err := s.inherited(ctx, from, ouArn)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.inherited(ctx, to, ouArn)
+	if err != nil {
+		return nil, err
+	}
+
+	move := &Move{
+		AccountID:   accountID,
+		AccountName: aws.ToString(account.Account.Name),
+		From:        names(from),
+		To:          names(to),
+		Gained:      difference(after, before),
+		Lost:        difference(before, after),
+	}
+
+	s.metrics.IncrementCounter("moves_simulated")
+	s.logger.Info("account move simulated",
+		zap.String("accountId", accountID),
+		zap.String("target", target),
+		zap.Int("gained", len(move.Gained)),
+		zap.Int("lost", len(move.Lost)))
+	return move, nil
+}
+
+// resolveOU returns the ID of an OU given by ID or unique name, or of the root
+func resolveOU(ous []orgcache.OU, rootID, target string) (string, error) {
+	if target == rootID || strings.EqualFold(target, config.PolicyTargetRoot) {
+		return rootID, nil
+	}
+
+	var matches []string
+	for _, ou := range ous {
+		if ou.ID == target {
+			return ou.ID, nil
+		}
+		if ou.Name == target {
+			matches = append(matches, ou.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("OU %s not found", target)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("several OUs are named %s, use the ID of one of %s", target, strings.Join(matches, ", "))
+	}
+}
+
+// chain returns the root and the OUs down to an OU
+func chain(ous []orgcache.OU, rootID, id string) ([]node, error) {
+	byID := make(map[string]orgcache.OU, len(ous))
+	for _, ou := range ous {
+		byID[ou.ID] = ou
+	}
+
+	var nodes []node
+	for id != rootID {
+		ou, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("OU %s not found", id)
+		}
+		nodes = append([]node{{id: ou.ID, name: ou.Name}}, nodes...)
+		id = ou.ParentID
+	}
+	return append([]node{{id: rootID, name: config.PolicyTargetRoot, root: true}}, nodes...), nil
+}
+
+// names returns the names of the nodes of a chain
+func names(nodes []node) []string {
+	result := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		result = append(result, n.name)
+	}
+	return result
+}
+
+// ouArnPrefix returns the prefix of the ARNs of the OUs of the organization
+func (s *Simulator) ouArnPrefix(ctx context.Context) (string, error) {
+	org, err := s.orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe organization: %w", err)
+	}
+	return fmt.Sprintf("arn:%s:organizations::%s:ou/%s/", awsclient.Partition(),
+		aws.ToString(org.Organization.MasterAccountId), aws.ToString(org.Organization.Id)), nil
+}
+
+// inherited returns the governance an account inherits from a chain, by key
+func (s *Simulator) inherited(ctx context.Context, nodes []node, ouArn string) (map[string]Governance, error) {
+	result := make(map[string]Governance)
+	for _, n := range nodes {
+		governance, ok := s.governance[n.id]
+		if !ok {
+			var err error
+			if governance, err = s.read(ctx, n, ouArn); err != nil {
+				return nil, err
+			}
+			s.governance[n.id] = governance
+		}
+		for _, g := range governance {
+			if _, ok := result[g.key()]; !ok {
+				result[g.key()] = g
+			}
+		}
+	}
+	return result, nil
+}
+
+// read returns the governance attached to or enabled on the root or an OU
+func (s *Simulator) read(ctx context.Context, n node, ouArn string) ([]Governance, error) {
+	var governance []Governance
+
+	policyKinds := map[orgtypes.PolicyType]string{
+		orgtypes.PolicyTypeServiceControlPolicy: KindSCP,
+		orgtypes.PolicyTypeTagPolicy:            KindTagPolicy,
+	}
+	for policyType, kind := range policyKinds {
+		paginator := organizations.NewListPoliciesForTargetPaginator(s.orgClient, &organizations.ListPoliciesForTargetInput{
+			TargetId: aws.String(n.id),
+			Filter:   policyType,
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list policies of %s: %w", n.name, err)
+			}
+			for _, policy := range page.Policies {
+				governance = append(governance, Governance{
+					Kind: kind,
+					ID:   aws.ToString(policy.Id),
+					Name: aws.ToString(policy.Name),
+					Via:  n.name,
+				})
+			}
+		}
+	}
+
+	// Control Tower governs OUs, not the root
+	if !n.root {
+		arn := ouArn + n.id
+		controls, err := s.controls(ctx, n, arn)
+		if err != nil {
+			return nil, err
+		}
+		governance = append(governance, controls...)
+
+		baselines, err := s.enabledBaselines(ctx, n, arn)
+		if err != nil {
+			return nil, err
+		}
+		governance = append(governance, baselines...)
+	}
+
+	governance = append(governance, s.blueprints(n)...)
+	return governance, nil
+}
+
+// controls returns the Control Tower controls enabled on an OU
+func (s *Simulator) controls(ctx context.Context, n node, arn string) ([]Governance, error) {
+	var governance []Governance
+	paginator := ct.NewListEnabledControlsPaginator(s.ctClient, &ct.ListEnabledControlsInput{
+		TargetIdentifier: aws.String(arn),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list enabled controls of %s: %w", n.name, err)
+		}
+		for _, control := range page.EnabledControls {
+			identifier := aws.ToString(control.ControlIdentifier)
+			governance = append(governance, Governance{
+				Kind: KindControl,
+				ID:   identifier,
+				Name: identifier[strings.LastIndex(identifier, "/")+1:],
+				Via:  n.name,
+			})
+		}
+	}
+	return governance, nil
+}
+
+// enabledBaselines returns the Control Tower baselines enabled on an OU
+func (s *Simulator) enabledBaselines(ctx context.Context, n node, arn string) ([]Governance, error) {
+	if err := s.loadBaselineNames(ctx); err != nil {
+		return nil, err
+	}
+
+	var governance []Governance
+	paginator := ct.NewListEnabledBaselinesPaginator(s.ctClient, &ct.ListEnabledBaselinesInput{
+		Filter: &cttypes.EnabledBaselineFilter{TargetIdentifiers: []string{arn}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list enabled baselines of %s: %w", n.name, err)
+		}
+		for _, baseline := range page.EnabledBaselines {
+			identifier := aws.ToString(baseline.BaselineIdentifier)
+			name := s.baselines[identifier]
+			if name == "" {
+				name = identifier
+			}
+			governance = append(governance, Governance{Kind: KindBaseline, ID: identifier, Name: name, Via: n.name})
+		}
+	}
+	return governance, nil
+}
+
+// loadBaselineNames reads the names of the Control Tower baselines once
+func (s *Simulator) loadBaselineNames(ctx context.Context) error {
+	if s.baselines != nil {
+		return nil
+	}
+
+	s.baselines = make(map[string]string)
+	paginator := ct.NewListBaselinesPaginator(s.ctClient, &ct.ListBaselinesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			s.baselines = nil
+			return fmt.Errorf("failed to list baselines: %w", err)
+		}
+		for _, baseline := range page.Baselines {
+			s.baselines[aws.ToString(baseline.Arn)] = aws.ToString(baseline.Name)
+		}
+	}
+	return nil
+}
+
+// blueprints returns the blueprints of the configuration mapped to the accounts of
+// the root or an OU, listed by key or name
+func (s *Simulator) blueprints(n node) []Governance {
+	afc := s.cfg.AccountFactoryCustomization
+	if afc == nil {
+		return nil
+	}
+
+	var governance []Governance
+	for _, blueprint := range afc.Blueprints {
+		for _, ou := range blueprint.OUs {
+			matched := n.root && ou == config.PolicyTargetRoot
+			if !n.root {
+				matched = ou == n.name || invitations.OUName(s.cfg, ou) == n.name
+			}
+			if matched {
+				governance = append(governance, Governance{
+					Kind: KindBlueprint,
+					ID:   blueprint.Name,
+					Name: blueprint.Name,
+					Via:  n.name,
+				})
+				break
+			}
+		}
+	}
+	return governance
+}
+
+// difference returns the governance of a that b lacks, by kind and name
+func difference(a, b map[string]Governance) []Governance {
+	result := []Governance{}
+	for key, g := range a {
+		if _, ok := b[key]; !ok {
+			result = append(result, g)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Kind != result[j].Kind {
+			return kindOrder[result[i].Kind] < kindOrder[result[j].Kind]
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Write renders a move as text or JSON
+func Write(w io.Writer, format string, move *Move) error {
+	switch format {
+	case report.FormatText:
+		writeText(w, move)
+		return nil
+	case report.FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(move); err != nil {
+			return fmt.Errorf("failed to encode move simulation: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// writeText writes the path of the move, then what the account gains and loses
+func writeText(w io.Writer, move *Move) {
+	fmt.Fprintf(w, "Moving %s (%s) from %s to %s\n", move.AccountName, move.AccountID,
+		strings.Join(move.From, "/"), strings.Join(move.To, "/"))
+
+	if len(move.Gained) == 0 && len(move.Lost) == 0 {
+		fmt.Fprintln(w, "\nThe account keeps the same policies, controls, baselines and blueprints.")
+		return
+	}
+	sections := []struct {
+		title  string
+		sign   string
+		change []Governance
+	}{
+		{"Gains", "+", move.Gained},
+		{"Loses", "-", move.Lost},
+	}
+	for _, section := range sections {
+		if len(section.change) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", section.title)
+		for _, g := range section.change {
+			fmt.Fprintf(w, "  %s %-10s %s (from %s)\n", section.sign, g.Kind, g.Name, g.Via)
+		}
+	}
+}
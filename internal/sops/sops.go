@@ -0,0 +1,498 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package sops decrypts JSON files encrypted with SOPS using AWS KMS or age keys.
+// Files are decrypted in memory, the plaintext is never written to disk.
+// Version: 1.0.0
+package sops
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+var (
+	// ErrNoKey is returned when none of the keys of a file decrypts its data key
+	ErrNoKey = errors.New("no key could decrypt the SOPS data key")
+
+	// ErrIntegrity is returned when the values of a file do not match its MAC
+	ErrIntegrity = errors.New("SOPS file failed its integrity check")
+)
+
+const (
+	// EnvAgeKey holds age identities, one per line
+	EnvAgeKey = "SOPS_AGE_KEY"
+
+	// EnvAgeKeyFile names the file of the age identities, by default
+	// sops/age/keys.txt in the user configuration directory
+	EnvAgeKeyFile = "SOPS_AGE_KEY_FILE"
+
+	// Top-level key of the SOPS metadata
+	metadataKey = "sops"
+)
+
+var encryptedRE = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.+),iv:(.+),tag:(.+),type:(.+)\]`)
+
+// Written first to the MAC of files encrypted with mac_only_encrypted, as SOPS does
+var macOnlyEncryptedInit = []byte{0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3, 0xd1, 0x47, 0xbe, 0xb, 0xb, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69}
+
+// metadata is the part of the SOPS metadata used to decrypt a file
+type metadata struct {
+	KeyGroups        []keyGroup `json:"key_groups"`
+	KMS              []kmsKey   `json:"kms"`
+	Age              []ageKey   `json:"age"`
+	LastModified     string     `json:"lastmodified"`
+	MAC              string     `json:"mac"`
+	MACOnlyEncrypted bool       `json:"mac_only_encrypted"`
+}
+
+type keyGroup struct {
+	KMS []kmsKey `json:"kms"`
+	Age []ageKey `json:"age"`
+}
+
+// kmsKey is the data key encrypted with an AWS KMS key
+type kmsKey struct {
+	Arn              string             `json:"arn"`
+	Role             string             `json:"role"`
+	Context          map[string]*string `json:"context"`
+	EncryptedDataKey string             `json:"enc"`
+	AwsProfile       string             `json:"aws_profile"`
+}
+
+// ageKey is the data key encrypted to an age recipient
+type ageKey struct {
+	Recipient        string `json:"recipient"`
+	EncryptedDataKey string `json:"enc"`
+}
+
+// IsEncrypted reports whether data is a JSON document encrypted with SOPS
+func IsEncrypted(data []byte) bool {
+	var doc struct {
+		Sops *metadata `json:"sops"`
+	}
+	return json.Unmarshal(data, &doc) == nil && doc.Sops != nil && doc.Sops.MAC != ""
+}
+
+// Decrypt decrypts a JSON document encrypted with SOPS and returns the plaintext
+// document without its metadata. The data key is decrypted with the first of the
+// KMS or age keys of the file that succeeds, and the values are checked against the
+// MAC of the file.
+func Decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	var doc struct {
+		Sops *metadata `json:"sops"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse SOPS file: %w", err)
+	}
+	if doc.Sops == nil {
+		return nil, fmt.Errorf("failed to parse SOPS file: no %s metadata", metadataKey)
+	}
+
+	key, err := dataKey(ctx, doc.Sops)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, mac, err := decryptTree(data, key, doc.Sops.MACOnlyEncrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	lastModified, err := time.Parse(time.RFC3339, doc.Sops.LastModified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SOPS lastmodified %q: %w", doc.Sops.LastModified, err)
+	}
+	expected, err := decryptValue(doc.Sops.MAC, key, lastModified.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decrypt MAC: %w", ErrIntegrity, err)
+	}
+	if string(expected) != mac {
+		return nil, ErrIntegrity
+	}
+	return plaintext, nil
+}
+
+// dataKey decrypts the data key of a file with its KMS and age keys. Files whose data
+// key is split across several key groups are not supported.
+func dataKey(ctx context.Context, md *metadata) ([]byte, error) {
+	kmsKeys, ageKeys := md.KMS, md.Age
+	switch len(md.KeyGroups) {
+	case 0:
+	case 1:
+		kmsKeys, ageKeys = md.KeyGroups[0].KMS, md.KeyGroups[0].Age
+	default:
+		return nil, fmt.Errorf("SOPS files split across %d key groups are not supported", len(md.KeyGroups))
+	}
+
+	var errs []error
+	for _, k := range kmsKeys {
+		key, err := k.decrypt(ctx)
+		if err == nil {
+			return key, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(ageKeys) > 0 {
+		identities, err := ageIdentities()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, k := range ageKeys {
+			if len(identities) == 0 {
+				break
+			}
+			key, err := k.decrypt(identities)
+			if err == nil {
+				return key, nil
+			}
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("%w: the file has no KMS or age key", ErrNoKey)
+	}
+	return nil, fmt.Errorf("%w: %w", ErrNoKey, errors.Join(errs...))
+}
+
+// decrypt decrypts the data key with the KMS key, in the region of the key and with
+// the role and profile recorded with it
+func (k kmsKey) decrypt(ctx context.Context) ([]byte, error) {
+	parsed, err := arn.Parse(k.Arn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KMS key ARN %s: %w", k.Arn, err)
+	}
+
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(parsed.Region)}
+	if k.AwsProfile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(k.AwsProfile))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if k.Role != "" {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), k.Role))
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(k.EncryptedDataKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key of %s: %w", k.Arn, err)
+	}
+	encryptionContext := make(map[string]string, len(k.Context))
+	for key, value := range k.Context {
+		encryptionContext[key] = aws.ToString(value)
+	}
+
+	out, err := kms.NewFromConfig(cfg).Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob:    blob,
+		KeyId:             aws.String(k.Arn),
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key with %s: %w", k.Arn, err)
+	}
+	return out.Plaintext, nil
+}
+
+// decrypt decrypts the data key with the identities
+func (k ageKey) decrypt(identities []age.Identity) ([]byte, error) {
+	r, err := age.Decrypt(armor.NewReader(strings.NewReader(k.EncryptedDataKey)), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key for %s: %w", k.Recipient, err)
+	}
+	key, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key for %s: %w", k.Recipient, err)
+	}
+	return key, nil
+}
+
+// ageIdentities returns the age identities of EnvAgeKey and of the key file
+func ageIdentities() ([]age.Identity, error) {
+	var sources []string
+	if key := os.Getenv(EnvAgeKey); key != "" {
+		sources = append(sources, key)
+	}
+
+	path := os.Getenv(EnvAgeKeyFile)
+	explicit := path != ""
+	if !explicit {
+		if dir, err := os.UserConfigDir(); err == nil {
+			path = filepath.Join(dir, "sops", "age", "keys.txt")
+		}
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			sources = append(sources, string(data))
+		case explicit || !errors.Is(err, os.ErrNotExist):
+			return nil, fmt.Errorf("failed to read age key file %s: %w", path, err)
+		}
+	}
+
+	var identities []age.Identity
+	for _, source := range sources {
+		parsed, err := age.ParseIdentities(strings.NewReader(source))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse age identities: %w", err)
+		}
+		identities = append(identities, parsed...)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no age identity found, set %s or %s", EnvAgeKey, EnvAgeKeyFile)
+	}
+	return identities, nil
+}
+
+// decryptValue decrypts an ENC[AES256_GCM,...] value with the data key, the additional
+// data binding it to its place in the document
+func decryptValue(value string, key []byte, additionalData string) ([]byte, error) {
+	matches := encryptedRE.FindStringSubmatch(value)
+	if matches == nil {
+		return nil, fmt.Errorf("value is not encrypted with SOPS")
+	}
+
+	var parts [3][]byte
+	for i := range parts {
+		part, err := base64.StdEncoding.DecodeString(matches[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid encrypted value: %w", err)
+		}
+		parts[i] = part
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return plaintext, nil
+}
+
+// treeDecrypter rewrites a SOPS document as plaintext, token by token so the MAC is
+// computed over the values in the order of the file
+type treeDecrypter struct {
+	dec              *json.Decoder
+	out              bytes.Buffer
+	key              []byte
+	mac              hash.Hash
+	macOnlyEncrypted bool
+}
+
+// decryptTree decrypts the values of a SOPS document and drops its metadata. It
+// returns the plaintext document and the MAC of its values. Numbers keep the text of
+// the file, so integers beyond the precision of a float64 are not rounded.
+func decryptTree(data, key []byte, macOnlyEncrypted bool) ([]byte, string, error) {
+	t := &treeDecrypter{
+		dec:              json.NewDecoder(bytes.NewReader(data)),
+		key:              key,
+		mac:              sha512.New(),
+		macOnlyEncrypted: macOnlyEncrypted,
+	}
+	t.dec.UseNumber()
+	if macOnlyEncrypted {
+		t.mac.Write(macOnlyEncryptedInit)
+	}
+
+	tok, err := t.dec.Token()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse SOPS file: %w", err)
+	}
+	if tok != json.Delim('{') {
+		return nil, "", fmt.Errorf("failed to parse SOPS file: not a JSON object")
+	}
+	if err := t.object(nil, true); err != nil {
+		return nil, "", err
+	}
+	return t.out.Bytes(), fmt.Sprintf("%X", t.mac.Sum(nil)), nil
+}
+
+// object rewrites the members of an object whose opening brace was read. The
+// metadata is dropped from the top-level object.
+func (t *treeDecrypter) object(path []string, top bool) error {
+	t.out.WriteByte('{')
+	written := 0
+	for t.dec.More() {
+		tok, err := t.dec.Token()
+		if err != nil {
+			return fmt.Errorf("failed to parse SOPS file: %w", err)
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("failed to parse SOPS file: unexpected %v", tok)
+		}
+		if top && key == metadataKey {
+			var skipped json.RawMessage
+			if err := t.dec.Decode(&skipped); err != nil {
+				return fmt.Errorf("failed to parse SOPS file: %w", err)
+			}
+			continue
+		}
+
+		if written > 0 {
+			t.out.WriteByte(',')
+		}
+		written++
+		if err := t.write(key); err != nil {
+			return err
+		}
+		t.out.WriteByte(':')
+		if err := t.value(append(path[:len(path):len(path)], key)); err != nil {
+			return err
+		}
+	}
+	if _, err := t.dec.Token(); err != nil {
+		return fmt.Errorf("failed to parse SOPS file: %w", err)
+	}
+	t.out.WriteByte('}')
+	return nil
+}
+
+// array rewrites the elements of an array whose opening bracket was read. Elements
+// share the path of the array.
+func (t *treeDecrypter) array(path []string) error {
+	t.out.WriteByte('[')
+	for i := 0; t.dec.More(); i++ {
+		if i > 0 {
+			t.out.WriteByte(',')
+		}
+		if err := t.value(path); err != nil {
+			return err
+		}
+	}
+	if _, err := t.dec.Token(); err != nil {
+		return fmt.Errorf("failed to parse SOPS file: %w", err)
+	}
+	t.out.WriteByte(']')
+	return nil
+}
+
+// value rewrites the next value, decrypting the encrypted strings. Every value is
+// added to the MAC, or only the encrypted ones with mac_only_encrypted; nulls never are.
+func (t *treeDecrypter) value(path []string) error {
+	tok, err := t.dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to parse SOPS file: %w", err)
+	}
+
+	switch v := tok.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			return t.object(path, false)
+		case '[':
+			return t.array(path)
+		}
+		return fmt.Errorf("failed to parse SOPS file: unexpected %v", v)
+	case nil:
+		return t.write(nil)
+	case string:
+		if encryptedRE.MatchString(v) {
+			return t.decrypt(path, v)
+		}
+	}
+
+	if !t.macOnlyEncrypted {
+		t.mac.Write(macBytes(tok))
+	}
+	return t.write(tok)
+}
+
+// decrypt rewrites an encrypted value as the plaintext of its type
+func (t *treeDecrypter) decrypt(path []string, value string) error {
+	location := strings.Join(path, ":") + ":"
+	plaintext, err := decryptValue(value, t.key, location)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", location, err)
+	}
+
+	var decrypted interface{}
+	switch valueType := encryptedRE.FindStringSubmatch(value)[4]; valueType {
+	case "str", "bytes":
+		decrypted = string(plaintext)
+	case "int":
+		decrypted, err = strconv.Atoi(string(plaintext))
+	case "float":
+		if _, err = strconv.ParseFloat(string(plaintext), 64); err == nil {
+			decrypted = json.Number(plaintext)
+		}
+	case "bool":
+		decrypted, err = strconv.ParseBool(string(plaintext))
+	default:
+		err = fmt.Errorf("unsupported type %s", valueType)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", location, err)
+	}
+
+	t.mac.Write(macBytes(decrypted))
+	return t.write(decrypted)
+}
+
+// write appends a JSON value to the plaintext document
+func (t *treeDecrypter) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode decrypted value: %w", err)
+	}
+	t.out.Write(data)
+	return nil
+}
+
+// macBytes returns the bytes of a value in the MAC, formatted like SOPS does. SOPS
+// reads every JSON number as a float64.
+func macBytes(v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return []byte(v)
+	case int:
+		return []byte(strconv.Itoa(v))
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return []byte(v)
+		}
+		return []byte(strconv.FormatFloat(f, 'f', -1, 64))
+	case bool:
+		if v {
+			return []byte("True")
+		}
+		return []byte("False")
+	}
+	return nil
+}
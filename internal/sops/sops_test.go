@@ -0,0 +1,190 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package sops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Identity of the age recipient the files of testdata are encrypted to, the mock
+// identity of the tests of SOPS. The files were encrypted with SOPS 3.9.1.
+const testAgeIdentity = "AGE-SECRET-KEY-1G0Q5K9TV4REQ3ZSQRMTMG8NSWQGYT0T7TZ33RAZEE0GZYVZN0APSU24RK7"
+
+func TestDecryptValue(t *testing.T) {
+	// Vector of the AES cipher tests of SOPS
+	const value = "ENC[AES256_GCM,data:oYyi,iv:MyIDYbT718JRr11QtBkcj3Dwm4k1aCGZBVeZf0EyV8o=,tag:t5z2Z023Up0kxwCgw1gNxg==,type:str]"
+	key := []byte(strings.Repeat("f", 32))
+
+	tests := []struct {
+		name           string
+		value          string
+		additionalData string
+		want           string
+		wantErr        bool
+	}{
+		{name: "decrypts", value: value, additionalData: "bar:", want: "foo"},
+		{name: "wrong location", value: value, additionalData: "baz:", wantErr: true},
+		{name: "no location", value: value, additionalData: "", wantErr: true},
+		{name: "not encrypted", value: "foo", additionalData: "bar:", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decryptValue(tt.value, key, tt.additionalData)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decryptValue() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decryptValue() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decryptValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecrypt(t *testing.T) {
+	setAgeIdentity(t)
+	want := readJSON(t, readFile(t, "plain.json"))
+
+	for _, file := range []string{"encrypted.json", "encrypted-mac-only.json"} {
+		t.Run(file, func(t *testing.T) {
+			data := readFile(t, file)
+			if !IsEncrypted(data) {
+				t.Fatalf("IsEncrypted() = false, want true")
+			}
+
+			plaintext, err := Decrypt(context.Background(), data)
+			if err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			if got := readJSON(t, plaintext); !reflect.DeepEqual(got, want) {
+				t.Errorf("Decrypt() = %s, want the document of plain.json", plaintext)
+			}
+		})
+	}
+}
+
+func TestDecryptLargeNumber(t *testing.T) {
+	setAgeIdentity(t)
+
+	// SOPS reads numbers as float64, so both texts of the integer have the same MAC
+	data := bytes.Replace(readFile(t, "encrypted.json"), []byte("123456789012345680"), []byte("123456789012345678"), 1)
+	plaintext, err := Decrypt(context.Background(), data)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if !bytes.Contains(plaintext, []byte(`"accountId":123456789012345678`)) {
+		t.Errorf("Decrypt() = %s, want the unencrypted integer unrounded", plaintext)
+	}
+}
+
+func TestDecryptIntegrity(t *testing.T) {
+	setAgeIdentity(t)
+
+	tests := []struct {
+		name    string
+		file    string
+		old     string
+		new     string
+		wantErr error
+	}{
+		{name: "unencrypted value changed", file: "encrypted.json", old: `"label": "plain"`, new: `"label": "changed"`, wantErr: ErrIntegrity},
+		{name: "unencrypted number changed", file: "encrypted.json", old: `"threshold": 0.1`, new: `"threshold": 0.2`, wantErr: ErrIntegrity},
+		{name: "unencrypted value changed with mac_only_encrypted", file: "encrypted-mac-only.json", old: `"label": "plain"`, new: `"label": "changed"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := readFile(t, tt.file)
+			if !bytes.Contains(data, []byte(tt.old)) {
+				t.Fatalf("%s does not contain %s", tt.file, tt.old)
+			}
+			data = bytes.Replace(data, []byte(tt.old), []byte(tt.new), 1)
+
+			_, err := Decrypt(context.Background(), data)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Decrypt() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecryptNoKey(t *testing.T) {
+	t.Setenv(EnvAgeKey, "")
+	t.Setenv(EnvAgeKeyFile, filepath.Join(t.TempDir(), "missing.txt"))
+
+	if _, err := Decrypt(context.Background(), readFile(t, "encrypted.json")); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Decrypt() error = %v, want %v", err, ErrNoKey)
+	}
+}
+
+func TestMacBytes(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{value: "foo", want: "foo"},
+		{value: 3, want: "3"},
+		{value: json.Number("3"), want: "3"},
+		{value: json.Number("1.50"), want: "1.5"},
+		{value: json.Number("1e3"), want: "1000"},
+		{value: json.Number("123456789012345678"), want: "123456789012345680"},
+		{value: true, want: "True"},
+		{value: false, want: "False"},
+	}
+	for _, tt := range tests {
+		if got := string(macBytes(tt.value)); got != tt.want {
+			t.Errorf("macBytes(%#v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// setAgeIdentity makes the identity of the test files the only age identity found
+func setAgeIdentity(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(path, []byte(testAgeIdentity+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvAgeKey, "")
+	t.Setenv(EnvAgeKeyFile, path)
+}
+
+func readFile(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func readJSON(t *testing.T, data []byte) interface{} {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	return v
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package stacks provides the decomposition of the landing zone into independently deployable stacks.
+// Version: 1.0.0
+package stacks
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/selection"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// Component names
+	ComponentOrgCore    = "org-core"
+	ComponentLogging    = "logging"
+	ComponentSecurity   = "security"
+	ComponentNetworking = "networking"
+	ComponentBilling    = "billing"
+
+	// EnvComponent selects the component the Pulumi program deploys. When unset the
+	// program deploys every module into a single stack.
+	EnvComponent = "AWS_ORG_COMPONENT"
+
+	// EnvReferences carries the fully qualified stack names of the dependencies of the
+	// component, as a comma separated list of component=stack pairs
+	EnvReferences = "AWS_ORG_STACK_REFERENCES"
+
+	// Outputs exported by the org-core stack
+	OutputOrganizationID  = "organizationId"
+	OutputOrganizationArn = "organizationArn"
+	OutputRootID          = "rootId"
+)
+
+// Component represents a stack holding a subset of the modules
+type Component struct {
+	Name      string
+	Modules   []string
+	DependsOn []string
+}
+
+// Components lists every component in dependency order
+var Components = []Component{
+	{
+		Name:    ComponentOrgCore,
+		Modules: []string{selection.ModuleOrganization},
+	},
+	{
+		Name:      ComponentLogging,
+		Modules:   []string{selection.ModuleControlTower},
+		DependsOn: []string{ComponentOrgCore},
+	},
+	{
+		Name:      ComponentSecurity,
+		Modules:   []string{selection.ModuleSecurity, selection.ModuleBaseline},
+		DependsOn: []string{ComponentOrgCore},
+	},
+	{
+		Name:      ComponentNetworking,
+		Modules:   []string{selection.ModuleNetworking},
+		DependsOn: []string{ComponentOrgCore},
+	},
+	{
+		Name:      ComponentBilling,
+		Modules:   []string{selection.ModuleBilling},
+		DependsOn: []string{ComponentOrgCore},
+	},
+}
+
+// Lookup returns the component with the given name
+func Lookup(name string) (Component, bool) {
+	for _, component := range Components {
+		if component.Name == name {
+			return component, true
+		}
+	}
+	return Component{}, false
+}
+
+// Resolve returns the named components in dependency order. An empty list selects
+// every component.
+func Resolve(names []string) ([]Component, error) {
+	if len(names) == 0 {
+		return Components, nil
+	}
+
+	requested := make(map[string]bool)
+	for _, name := range names {
+		if _, ok := Lookup(name); !ok {
+			return nil, fmt.Errorf("unknown component %q, valid components are: %s", name, strings.Join(Names(), ", "))
+		}
+		requested[name] = true
+	}
+
+	var resolved []Component
+	for _, component := range Components {
+		if requested[component.Name] {
+			resolved = append(resolved, component)
+		}
+	}
+	return resolved, nil
+}
+
+// Names returns the names of every component
+func Names() []string {
+	names := make([]string, 0, len(Components))
+	for _, component := range Components {
+		names = append(names, component.Name)
+	}
+	return names
+}
+
+// StackName returns the name of the stack holding a component for an environment stack
+func StackName(component, stack string) string {
+	return fmt.Sprintf("%s-%s", component, stack)
+}
+
+// Selection returns the module selection deployed by the component
+func (c Component) Selection() (*selection.Selection, error) {
+	return selection.New(c.Modules, nil)
+}
+
+// Current returns the component selected through the environment, if any
+func Current() (*Component, error) {
+	name := os.Getenv(EnvComponent)
+	if name == "" {
+		return nil, nil
+	}
+
+	component, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown component %q in %s", name, EnvComponent)
+	}
+	return &component, nil
+}
+
+// EncodeReferences formats the stack names of dependencies for EnvReferences
+func EncodeReferences(refs map[string]string) string {
+	pairs := make([]string, 0, len(refs))
+	for component, stack := range refs {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", component, stack))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// References gives access to the outputs of the stacks a component depends on
+type References struct {
+	refs map[string]*pulumi.StackReference
+}
+
+// LoadReferences creates stack references for the dependencies listed in EnvReferences
+func LoadReferences(ctx *pulumi.Context) (*References, error) {
+	r := &References{refs: make(map[string]*pulumi.StackReference)}
+
+	value := os.Getenv(EnvReferences)
+	if value == "" {
+		return r, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		component, stack, ok := strings.Cut(pair, "=")
+		if !ok || component == "" || stack == "" {
+			return nil, fmt.Errorf("invalid stack reference %q in %s", pair, EnvReferences)
+		}
+
+		ref, err := pulumi.NewStackReference(ctx, fmt.Sprintf("%s-ref", component), &pulumi.StackReferenceArgs{
+			Name: pulumi.String(stack),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to reference stack %s: %w", stack, err)
+		}
+		r.refs[component] = ref
+	}
+
+	return r, nil
+}
+
+// StringOutput returns an output of a referenced component. The second value is false
+// when the component is not referenced.
+func (r *References) StringOutput(component, name string) (pulumi.StringOutput, bool) {
+	ref, ok := r.refs[component]
+	if !ok {
+		return pulumi.StringOutput{}, false
+	}
+	return ref.GetStringOutput(pulumi.String(name)), true
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package stacksets provides drift detection of the baseline CloudFormation StackSets.
+// Version: 1.0.0
+package stacksets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/report"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// CheckStackSetDrift is the check name of drifted stack instances in the report
+	CheckStackSetDrift = "stackset-drift"
+
+	// DefaultPrefix matches the baseline StackSets deployed by Control Tower
+	DefaultPrefix = "AWSControlTowerBP-"
+
+	// Drift detection defaults
+	DefaultTimeout = 30 * time.Minute
+	pollInterval   = 15 * time.Second
+)
+
+// Detector triggers drift detection of the baseline StackSets and reports their drifted
+// stack instances. StackSets are administered from the management account.
+type Detector struct {
+	logger       *zap.Logger
+	metrics      *metrics.Collector
+	client       *cloudformation.Client
+	managementId string
+	prefixes     []string
+	timeout      time.Duration
+}
+
+// NewDetector creates a drift detector for the StackSets of the home region
+func NewDetector(ctx context.Context, cfg *config.LandingZoneConfig) (*Detector, error) {
+	logger, err := logging.NewLogger("stackset-drift")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("stackset-drift")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HomeRegion != "" {
+		base.Region = cfg.HomeRegion
+	}
+
+	prefixes := []string{DefaultPrefix}
+	timeout := DefaultTimeout
+	if cfg.Drift != nil {
+		if len(cfg.Drift.StackSetPrefixes) > 0 {
+			prefixes = cfg.Drift.StackSetPrefixes
+		}
+		if cfg.Drift.TimeoutMinutes > 0 {
+			timeout = time.Duration(cfg.Drift.TimeoutMinutes) * time.Minute
+		}
+	}
+
+	return &Detector{
+		logger:       logger,
+		metrics:      metrics,
+		client:       cloudformation.NewFromConfig(base),
+		managementId: cfg.ManagementAccountId,
+		prefixes:     prefixes,
+		timeout:      timeout,
+	}, nil
+}
+
+// Detect starts drift detection on every baseline StackSet and waits for the operations
+// to complete. StackSets whose detection cannot run are reported rather than aborting.
+func (d *Detector) Detect(ctx context.Context, r *report.Report) error {
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDuration("drift_detection", time.Since(start))
+	}()
+
+	names, err := d.stackSets(ctx)
+	if err != nil {
+		return err
+	}
+
+	operations := make(map[string]string)
+	for _, name := range names {
+		out, err := d.client.DetectStackSetDrift(ctx, &cloudformation.DetectStackSetDriftInput{
+			StackSetName: aws.String(name),
+		})
+		var inProgress *cftypes.OperationInProgressException
+		switch {
+		case errors.As(err, &inProgress):
+			d.logger.Info("StackSet operation in progress, using last drift status", zap.String("stackSet", name))
+			continue
+		case err != nil:
+			r.Add(d.finding(d.managementId, name, report.SeverityHigh, fmt.Sprintf("failed to detect drift: %v", err)))
+			continue
+		}
+		operations[name] = aws.ToString(out.OperationId)
+		d.metrics.IncrementCounter("drift_detections_started")
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	for name, operationId := range operations {
+		if err := d.wait(waitCtx, name, operationId); err != nil {
+			r.Add(d.finding(d.managementId, name, report.SeverityHigh, err.Error()))
+		}
+	}
+
+	d.logger.Info("StackSet drift detection completed",
+		zap.Int("stackSets", len(names)),
+		zap.Int("operations", len(operations)))
+	return d.collect(ctx, names, r)
+}
+
+// Collect adds the stack instances found drifted by the last detection to the report,
+// without starting a new one
+func (d *Detector) Collect(ctx context.Context, r *report.Report) error {
+	names, err := d.stackSets(ctx)
+	if err != nil {
+		return err
+	}
+	return d.collect(ctx, names, r)
+}
+
+// collect adds the drifted stack instances of the given StackSets to the report
+func (d *Detector) collect(ctx context.Context, names []string, r *report.Report) error {
+	for _, name := range names {
+		paginator := cloudformation.NewListStackInstancesPaginator(d.client, &cloudformation.ListStackInstancesInput{
+			StackSetName: aws.String(name),
+			Filters: []cftypes.StackInstanceFilter{
+				{
+					Name:   cftypes.StackInstanceFilterNameDriftStatus,
+					Values: aws.String(string(cftypes.StackDriftStatusDrifted)),
+				},
+			},
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list stack instances of %s: %w", name, err)
+			}
+			for _, instance := range page.Summaries {
+				message := "stack instance drifted from its StackSet"
+				if instance.LastDriftCheckTimestamp != nil {
+					message = fmt.Sprintf("%s, detected at %s", message,
+						instance.LastDriftCheckTimestamp.UTC().Format(time.RFC3339))
+				}
+				r.Add(d.finding(aws.ToString(instance.Account),
+					fmt.Sprintf("%s/%s", name, aws.ToString(instance.Region)),
+					report.SeverityHigh, message))
+				d.metrics.IncrementCounter("drifted_instances")
+			}
+		}
+	}
+	return nil
+}
+
+// wait polls a drift detection operation until it completes
+func (d *Detector) wait(ctx context.Context, name, operationId string) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		out, err := d.client.DescribeStackSetOperation(ctx, &cloudformation.DescribeStackSetOperationInput{
+			StackSetName: aws.String(name),
+			OperationId:  aws.String(operationId),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to describe drift detection %s: %w", operationId, err)
+		}
+
+		switch out.StackSetOperation.Status {
+		case cftypes.StackSetOperationStatusSucceeded:
+			return nil
+		case cftypes.StackSetOperationStatusFailed, cftypes.StackSetOperationStatusStopped:
+			return fmt.Errorf("drift detection %s ended with status %s", operationId, out.StackSetOperation.Status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for drift detection %s: %w", operationId, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// stackSets returns the names of the active StackSets matching the baseline prefixes
+func (d *Detector) stackSets(ctx context.Context) ([]string, error) {
+	var names []string
+	paginator := cloudformation.NewListStackSetsPaginator(d.client, &cloudformation.ListStackSetsInput{
+		Status: cftypes.StackSetStatusActive,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list StackSets: %w", err)
+		}
+		for _, summary := range page.Summaries {
+			name := aws.ToString(summary.StackSetName)
+			for _, prefix := range d.prefixes {
+				if strings.HasPrefix(name, prefix) {
+					names = append(names, name)
+					break
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// finding returns a StackSet drift finding
+func (d *Detector) finding(accountId, resource string, severity report.Severity, message string) report.Finding {
+	return report.Finding{
+		AccountID: accountId,
+		Check:     CheckStackSetDrift,
+		Severity:  severity,
+		Resource:  resource,
+		Message:   message,
+	}
+}
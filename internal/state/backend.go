@@ -0,0 +1,134 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package state
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+)
+
+// Backend stores the states saved by the manager
+type Backend interface {
+	// Name identifies the backend in errors and logs
+	Name() string
+	// Write stores a state as the latest one
+	Write(ctx context.Context, stateData *config.StateData) error
+	// Read returns the latest state, or nil when none is stored
+	Read(ctx context.Context) (*config.StateData, error)
+	// Backup stores a copy of a state
+	Backup(ctx context.Context, stateData *config.StateData) error
+	// Cleanup deletes states saved before the expiry date
+	Cleanup(ctx context.Context, expiryDate time.Time) error
+	// History lists the states and backups still stored
+	History(ctx context.Context) ([]Version, error)
+	// ReadVersion returns a state or backup of the history
+	ReadVersion(ctx context.Context, id string) (*config.StateData, error)
+	// DeleteBackup deletes a backup of the history
+	DeleteBackup(ctx context.Context, id string) error
+	// WriteRecord replaces an encoded record, stored apart from the states
+	WriteRecord(ctx context.Context, name string, data []byte, keyArn string) error
+	// ReadRecord returns an encoded record, or nil when none is stored
+	ReadRecord(ctx context.Context, name string) ([]byte, error)
+}
+
+// WithBackend selects the backend storing the state: dynamodb, s3, local or none
+func WithBackend(backendType string) func(*StateManager) error {
+	return func(sm *StateManager) error {
+		sm.backendType = backendType
+		return nil
+	}
+}
+
+// WithStateFile sets the path of the state file of the local backend
+func WithStateFile(path string) func(*StateManager) error {
+	return func(sm *StateManager) error {
+		if path == "" {
+			return fmt.Errorf("a state file path is required")
+		}
+		sm.filePath = path
+		return nil
+	}
+}
+
+// newBackend creates the backend selected by the options
+func (sm *StateManager) newBackend() (Backend, error) {
+	switch sm.backendType {
+	case config.StateBackendDynamoDB:
+		return &dynamoBackend{sm: sm}, nil
+	case config.StateBackendS3:
+		return &s3Backend{sm: sm}, nil
+	case config.StateBackendLocal:
+		return &localBackend{sm: sm, path: sm.filePath}, nil
+	case config.StateBackendNone:
+		return noneBackend{}, nil
+	default:
+		return nil, fmt.Errorf("unknown state backend %q", sm.backendType)
+	}
+}
+
+// dynamoBackend stores states in the DynamoDB state table, with backups and large
+// payloads in the backup bucket
+type dynamoBackend struct {
+	sm *StateManager
+}
+
+// Name implements Backend
+func (b *dynamoBackend) Name() string {
+	return "DynamoDB"
+}
+
+// Write implements Backend
+func (b *dynamoBackend) Write(ctx context.Context, stateData *config.StateData) error {
+	return b.sm.saveToDynamoDB(ctx, stateData)
+}
+
+// Read implements Backend
+func (b *dynamoBackend) Read(ctx context.Context) (*config.StateData, error) {
+	return b.sm.loadFromDynamoDB(ctx)
+}
+
+// Backup implements Backend
+func (b *dynamoBackend) Backup(ctx context.Context, stateData *config.StateData) error {
+	return b.sm.backupToS3(ctx, stateData)
+}
+
+// Cleanup implements Backend
+func (b *dynamoBackend) Cleanup(ctx context.Context, expiryDate time.Time) error {
+	if err := b.sm.cleanupDynamoDB(ctx, expiryDate); err != nil {
+		return err
+	}
+	return b.sm.cleanupS3(ctx, expiryDate)
+}
+
+// noneBackend discards states, for deployments that do not keep one
+type noneBackend struct{}
+
+// Name implements Backend
+func (noneBackend) Name() string {
+	return "none"
+}
+
+// Write implements Backend
+func (noneBackend) Write(ctx context.Context, stateData *config.StateData) error {
+	return nil
+}
+
+// Read implements Backend
+func (noneBackend) Read(ctx context.Context) (*config.StateData, error) {
+	return nil, nil
+}
+
+// Backup implements Backend
+func (noneBackend) Backup(ctx context.Context, stateData *config.StateData) error {
+	return nil
+}
+
+// Cleanup implements Backend
+func (noneBackend) Cleanup(ctx context.Context, expiryDate time.Time) error {
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// bootstrapTimeout bounds the creation of the state table
+	bootstrapTimeout = 5 * time.Minute
+)
+
+// Bootstrap creates the state table when missing and enables point-in-time recovery
+// and the TTL attribute, so expired states are deleted by DynamoDB
+func (sm *StateManager) Bootstrap(ctx context.Context) error {
+	if err := readonly.Check("bootstrap state table"); err != nil {
+		return &config.StateError{
+			Operation: "Bootstrap",
+			Message:   "state writes are disabled",
+			Err:       err,
+		}
+	}
+
+	if sm.backendType != config.StateBackendDynamoDB {
+		return &config.StateError{
+			Operation: "Bootstrap",
+			Message:   fmt.Sprintf("the %s backend has no state table", sm.backendType),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bootstrapTimeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		sm.metrics.RecordDuration("state_bootstrap_duration", time.Since(start))
+	}()
+
+	if err := sm.ensureTable(ctx); err != nil {
+		return &config.StateError{
+			Operation: "Bootstrap",
+			Message:   "failed to create state table",
+			Err:       err,
+		}
+	}
+
+	if _, err := sm.dynamoClient.UpdateContinuousBackups(ctx, &dynamodb.UpdateContinuousBackupsInput{
+		TableName: aws.String(sm.tableName),
+		PointInTimeRecoverySpecification: &types.PointInTimeRecoverySpecification{
+			PointInTimeRecoveryEnabled: aws.Bool(true),
+		},
+	}); err != nil {
+		return &config.StateError{
+			Operation: "Bootstrap",
+			Message:   "failed to enable point-in-time recovery",
+			Err:       err,
+		}
+	}
+
+	if err := sm.ensureTTL(ctx); err != nil {
+		return &config.StateError{
+			Operation: "Bootstrap",
+			Message:   "failed to enable TTL",
+			Err:       err,
+		}
+	}
+
+	sm.logger.Info("state table bootstrapped",
+		zap.String("table", sm.tableName),
+		zap.String("ttlAttribute", config.ExpiresAtAttribute),
+		zap.Int("expiryDays", config.StateExpiryDays))
+	return nil
+}
+
+// ensureTable creates the state table when it does not exist and waits until it is active
+func (sm *StateManager) ensureTable(ctx context.Context) error {
+	_, err := sm.dynamoClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(sm.tableName),
+	})
+	if err == nil {
+		return nil
+	}
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return fmt.Errorf("failed to describe table %s: %w", sm.tableName, err)
+	}
+
+	if _, err := sm.dynamoClient.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(sm.tableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(config.PkAttribute), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String(config.SkAttribute), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(config.PkAttribute), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(config.SkAttribute), KeyType: types.KeyTypeRange},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+		SSESpecification: &types.SSESpecification{
+			Enabled: aws.Bool(true),
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", sm.tableName, err)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(sm.dynamoClient)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(sm.tableName),
+	}, bootstrapTimeout); err != nil {
+		return fmt.Errorf("failed waiting for table %s: %w", sm.tableName, err)
+	}
+
+	sm.logger.Info("state table created", zap.String("table", sm.tableName))
+	return nil
+}
+
+// ensureTTL enables the TTL attribute of the state table
+func (sm *StateManager) ensureTTL(ctx context.Context) error {
+	enabled, err := sm.ttlEnabled(ctx)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		return nil
+	}
+
+	_, err = sm.dynamoClient.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(sm.tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(config.ExpiresAtAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
+}
+
+// ttlEnabled reports whether DynamoDB deletes expired states through the TTL attribute
+func (sm *StateManager) ttlEnabled(ctx context.Context) (bool, error) {
+	out, err := sm.dynamoClient.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(sm.tableName),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to describe TTL of table %s: %w", sm.tableName, err)
+	}
+
+	ttl := out.TimeToLiveDescription
+	if ttl == nil || aws.ToString(ttl.AttributeName) != config.ExpiresAtAttribute {
+		return false, nil
+	}
+	return ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabled ||
+		ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabling, nil
+}
+
+// expiresAt returns the TTL attribute of a state saved at the given time
+func expiresAt(timestamp time.Time) types.AttributeValue {
+	expiry := timestamp.AddDate(0, 0, config.StateExpiryDays).Unix()
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(expiry, 10)}
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package state
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxItemPayload is the largest state stored in a single item, leaving room for the
+	// other attributes below the 400KB item limit of DynamoDB
+	maxItemPayload = 350 * 1024
+	// maxStateChunks is the number of chunk items a state is split into before it is
+	// offloaded to S3
+	maxStateChunks = 10
+	// maxBatchWriteItems is the number of requests accepted by a single BatchWriteItem
+	maxBatchWriteItems = 25
+)
+
+// chunkPartition returns the partition key of the chunks of the state saved with the
+// given sort key. Chunks live outside the state partition, so they are never returned
+// as the latest state.
+func chunkPartition(sk string) string {
+	return fmt.Sprintf("%s#chunk#%s", config.StateFilePrefix, sk)
+}
+
+// chunkKey returns the sort key of a chunk, ordering chunks by index
+func chunkKey(index int) string {
+	return fmt.Sprintf("%04d", index)
+}
+
+// payloadKey returns the S3 key of an offloaded state
+func payloadKey(sk string) string {
+	return fmt.Sprintf("%s/%s.json", config.StateFilePrefix, sk)
+}
+
+// writePayload sets the state attribute of an item. States larger than an item are
+// split across chunk items, and states larger than maxStateChunks chunks are offloaded
+// to the backup bucket; the item then only references them. Chunks and payloads are
+// written before the item, so a failed save never exposes a partial state.
+func (sm *StateManager) writePayload(ctx context.Context, item map[string]types.AttributeValue,
+	sk string, timestamp time.Time, payload []byte, binary bool) error {
+
+	switch {
+	case len(payload) <= maxItemPayload:
+		if binary {
+			item[config.StateAttribute] = &types.AttributeValueMemberB{Value: payload}
+		} else {
+			item[config.StateAttribute] = &types.AttributeValueMemberS{Value: string(payload)}
+		}
+		return nil
+
+	case len(payload) <= maxItemPayload*maxStateChunks:
+		var requests []types.WriteRequest
+		for index := 0; len(payload) > 0; index++ {
+			n := min(len(payload), maxItemPayload)
+			requests = append(requests, types.WriteRequest{
+				PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+					config.PkAttribute:        &types.AttributeValueMemberS{Value: chunkPartition(sk)},
+					config.SkAttribute:        &types.AttributeValueMemberS{Value: chunkKey(index)},
+					config.StateAttribute:     &types.AttributeValueMemberB{Value: payload[:n]},
+					config.ExpiresAtAttribute: expiresAt(timestamp),
+				}},
+			})
+			payload = payload[n:]
+		}
+
+		if err := sm.writeBatches(ctx, requests); err != nil {
+			return fmt.Errorf("failed to write state chunks: %w", err)
+		}
+
+		item[config.ChunksAttribute] = &types.AttributeValueMemberN{Value: strconv.Itoa(len(requests))}
+		sm.metrics.IncrementCounter("state_chunked_saves")
+		sm.logger.Info("state split across chunk items", zap.Int("chunks", len(requests)))
+		return nil
+
+	default:
+		key := payloadKey(sk)
+		if _, err := sm.s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(sm.bucketName),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(payload),
+			ContentType: aws.String("application/octet-stream"),
+		}); err != nil {
+			return fmt.Errorf("failed to offload state to %s: %w", sm.bucketName, err)
+		}
+
+		item[config.PayloadKeyAttribute] = &types.AttributeValueMemberS{Value: key}
+		sm.metrics.IncrementCounter("state_offloaded_saves")
+		sm.logger.Info("state offloaded to S3",
+			zap.String("bucket", sm.bucketName),
+			zap.String("key", key),
+			zap.Int("bytes", len(payload)))
+		return nil
+	}
+}
+
+// readPayload returns the state attribute of an item, reassembling its chunks or
+// reading its offloaded payload
+func (sm *StateManager) readPayload(ctx context.Context, item map[string]types.AttributeValue) ([]byte, error) {
+	switch v := item[config.StateAttribute].(type) {
+	case *types.AttributeValueMemberS:
+		return []byte(v.Value), nil
+	case *types.AttributeValueMemberB:
+		return v.Value, nil
+	}
+
+	if chunks, ok := item[config.ChunksAttribute].(*types.AttributeValueMemberN); ok {
+		count, err := strconv.Atoi(chunks.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk count %q: %w", chunks.Value, err)
+		}
+		sk, _ := item[config.SkAttribute].(*types.AttributeValueMemberS)
+		if sk == nil {
+			return nil, fmt.Errorf("state item has no %s attribute", config.SkAttribute)
+		}
+		return sm.readChunks(ctx, sk.Value, count)
+	}
+
+	if key, ok := item[config.PayloadKeyAttribute].(*types.AttributeValueMemberS); ok {
+		out, err := sm.s3Client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(sm.bucketName),
+			Key:    aws.String(key.Value),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read offloaded state %s: %w", key.Value, err)
+		}
+		defer out.Body.Close()
+
+		payload, err := io.ReadAll(out.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read offloaded state %s: %w", key.Value, err)
+		}
+		return payload, nil
+	}
+
+	return nil, fmt.Errorf("state item has no %s attribute", config.StateAttribute)
+}
+
+// readChunks reassembles the chunks of the state saved with the given sort key
+func (sm *StateManager) readChunks(ctx context.Context, sk string, count int) ([]byte, error) {
+	var payload []byte
+	read := 0
+
+	paginator := dynamodb.NewQueryPaginator(sm.dynamoClient, &dynamodb.QueryInput{
+		TableName:                aws.String(sm.tableName),
+		KeyConditionExpression:   aws.String("#pk = :pk"),
+		ExpressionAttributeNames: map[string]string{"#pk": config.PkAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: chunkPartition(sk)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query state chunks: %w", err)
+		}
+		for _, chunk := range page.Items {
+			data, ok := chunk[config.StateAttribute].(*types.AttributeValueMemberB)
+			if !ok {
+				return nil, fmt.Errorf("state chunk has no %s attribute", config.StateAttribute)
+			}
+			payload = append(payload, data.Value...)
+			read++
+		}
+	}
+
+	if read != count {
+		return nil, fmt.Errorf("state %s has %d of %d chunks", sk, read, count)
+	}
+	return payload, nil
+}
+
+// chunkDeletes returns the requests deleting the chunks of a state item
+func chunkDeletes(item map[string]types.AttributeValue) []types.WriteRequest {
+	chunks, ok := item[config.ChunksAttribute].(*types.AttributeValueMemberN)
+	sk, _ := item[config.SkAttribute].(*types.AttributeValueMemberS)
+	if !ok || sk == nil {
+		return nil
+	}
+	count, err := strconv.Atoi(chunks.Value)
+	if err != nil {
+		return nil
+	}
+
+	requests := make([]types.WriteRequest, 0, count)
+	for index := 0; index < count; index++ {
+		requests = append(requests, types.WriteRequest{
+			DeleteRequest: &types.DeleteRequest{Key: map[string]types.AttributeValue{
+				config.PkAttribute: &types.AttributeValueMemberS{Value: chunkPartition(sk.Value)},
+				config.SkAttribute: &types.AttributeValueMemberS{Value: chunkKey(index)},
+			}},
+		})
+	}
+	return requests
+}
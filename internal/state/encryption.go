@@ -0,0 +1,167 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package state
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+const (
+	// encryptionContextKey binds data keys to the state of this tool in CloudTrail and
+	// key policies
+	encryptionContextKey   = "component"
+	encryptionContextValue = "aws-organization-state"
+)
+
+// envelope holds state encrypted with a data key, itself encrypted with the KMS key
+type envelope struct {
+	KeyArn     string `json:"keyArn"`
+	DataKey    []byte `json:"dataKey"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// encryptor encrypts state client-side with AES-GCM data keys generated by KMS
+type encryptor struct {
+	client *kms.Client
+	keyArn string
+}
+
+// WithKMSKey encrypts the state and its backups client-side with data keys of the given
+// customer managed KMS key. The key ARN is recorded with the state, so states remain
+// readable after the key is changed.
+func WithKMSKey(keyArn string) func(*StateManager) error {
+	return func(sm *StateManager) error {
+		if keyArn == "" {
+			return fmt.Errorf("a KMS key ARN is required for state encryption")
+		}
+		sm.encryptor = &encryptor{
+			client: kms.NewFromConfig(sm.awsConfig),
+			keyArn: keyArn,
+		}
+		return nil
+	}
+}
+
+// seal encrypts plaintext with a new data key
+func (e *encryptor) seal(ctx context.Context, plaintext []byte) (*envelope, error) {
+	out, err := e.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(e.keyArn),
+		KeySpec:           kmstypes.DataKeySpecAes256,
+		EncryptionContext: map[string]string{encryptionContextKey: encryptionContextValue},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate data key with %s: %w", e.keyArn, err)
+	}
+
+	gcm, err := newGCM(out.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return &envelope{
+		KeyArn:     aws.ToString(out.KeyId),
+		DataKey:    out.CiphertextBlob,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, []byte(encryptionContextValue)),
+	}, nil
+}
+
+// open decrypts an envelope with the key recorded in it, which may differ from the key
+// currently configured
+func (e *encryptor) open(ctx context.Context, env *envelope) ([]byte, error) {
+	out, err := e.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(env.KeyArn),
+		CiphertextBlob:    env.DataKey,
+		EncryptionContext: map[string]string{encryptionContextKey: encryptionContextValue},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key with %s: %w", env.KeyArn, err)
+	}
+
+	gcm, err := newGCM(out.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, env.Nonce, env.Ciphertext, []byte(encryptionContextValue))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt state: %w", err)
+	}
+	return plaintext, nil
+}
+
+// encode serializes a state as JSON, wrapped in an envelope when a key is configured.
+// The key ARN of encrypted states is returned to be recorded as metadata.
+func (sm *StateManager) encode(ctx context.Context, stateData *config.StateData) ([]byte, string, error) {
+	data, err := json.Marshal(stateData)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal state data: %w", err)
+	}
+	if sm.encryptor == nil {
+		return data, "", nil
+	}
+
+	env, err := sm.encryptor.seal(ctx, data)
+	if err != nil {
+		return nil, "", err
+	}
+	if data, err = json.Marshal(env); err != nil {
+		return nil, "", fmt.Errorf("failed to marshal encrypted state: %w", err)
+	}
+	sm.metrics.IncrementCounter("state_encryptions")
+	return data, env.KeyArn, nil
+}
+
+// decode parses a state written by encode, decrypting envelopes with the key recorded
+// in them
+func (sm *StateManager) decode(ctx context.Context, data []byte) (*config.StateData, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state data: %w", err)
+	}
+
+	if env.KeyArn != "" {
+		plaintext, err := sm.decryptor().open(ctx, &env)
+		if err != nil {
+			return nil, err
+		}
+		data = plaintext
+	}
+
+	var stateData config.StateData
+	if err := json.Unmarshal(data, &stateData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state data: %w", err)
+	}
+	return &stateData, nil
+}
+
+// newGCM returns an AES-GCM cipher for a data key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
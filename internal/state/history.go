@@ -0,0 +1,365 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+// ErrVersionNotFound is returned for a version the state history does not hold
+var ErrVersionNotFound = errors.New("state version not found")
+
+// Version is a state of the history: a state saved by an update, identified by its
+// timestamp, or a backup, identified by its backup ID
+type Version struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Backup    bool      `json:"backup,omitempty"`
+}
+
+// History lists the versions of the state still stored, newest first
+func (sm *StateManager) History(ctx context.Context) ([]Version, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+
+	versions, err := sm.backend.History(ctx)
+	if err != nil {
+		return nil, &config.StateError{
+			Operation: "History",
+			Message:   fmt.Sprintf("failed to list state history of %s", sm.backend.Name()),
+			Err:       err,
+		}
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Timestamp.After(versions[j].Timestamp)
+	})
+	return versions, nil
+}
+
+// LoadVersion retrieves a version of the state history by its timestamp or backup ID
+func (sm *StateManager) LoadVersion(ctx context.Context, id string) (*config.StateData, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	stateData, err := sm.backend.ReadVersion(ctx, id)
+	if err != nil {
+		return nil, &config.StateError{
+			Operation: "LoadVersion",
+			Message:   fmt.Sprintf("failed to load state version %s from %s", id, sm.backend.Name()),
+			Err:       err,
+		}
+	}
+
+	sm.metrics.IncrementCounter("state_version_loads")
+	return stateData, nil
+}
+
+// PruneBackups deletes the backups older than the backup retention and returns them,
+// oldest first. With dryRun, the expired backups are only listed.
+func (sm *StateManager) PruneBackups(ctx context.Context, dryRun bool) ([]Version, error) {
+	if !dryRun {
+		if err := readonly.Check("prune state backups"); err != nil {
+			return nil, &config.StateError{
+				Operation: "PruneBackups",
+				Message:   "state writes are disabled",
+				Err:       err,
+			}
+		}
+	}
+
+	versions, err := sm.History(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout*2)
+	defer cancel()
+
+	cutoff := time.Now().AddDate(0, 0, -sm.backupRetention)
+	var expired []Version
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		if !version.Backup || version.Timestamp.IsZero() || !version.Timestamp.Before(cutoff) {
+			continue
+		}
+		expired = append(expired, version)
+	}
+	if dryRun {
+		return expired, nil
+	}
+
+	for i, version := range expired {
+		if err := sm.backend.DeleteBackup(ctx, version.ID); err != nil {
+			return expired[:i], &config.StateError{
+				Operation: "PruneBackups",
+				Message:   fmt.Sprintf("failed to delete state backup %s from %s", version.ID, sm.backend.Name()),
+				Err:       err,
+			}
+		}
+		sm.metrics.IncrementCounter("state_backups_pruned")
+	}
+
+	sm.logger.Info("state backups pruned",
+		zap.Int("pruned", len(expired)),
+		zap.Int("retentionDays", sm.backupRetention))
+	return expired, nil
+}
+
+// isBackupID reports whether a version ID names a backup rather than a saved state
+func isBackupID(id string) bool {
+	return strings.HasPrefix(id, config.BackupFilePrefix+"-")
+}
+
+// backupTimestamp returns the creation time recorded in a backup ID
+func backupTimestamp(id string) time.Time {
+	timestamp, _ := time.Parse("20060102-150405", strings.TrimPrefix(id, config.BackupFilePrefix+"-"))
+	return timestamp
+}
+
+// History implements Backend. States are the items of the state table, backups the
+// objects of the backup bucket.
+func (b *dynamoBackend) History(ctx context.Context) ([]Version, error) {
+	var versions []Version
+	paginator := dynamodb.NewQueryPaginator(b.sm.dynamoClient, &dynamodb.QueryInput{
+		TableName:                aws.String(b.sm.tableName),
+		KeyConditionExpression:   aws.String("#pk = :pk"),
+		ProjectionExpression:     aws.String("#sk"),
+		ExpressionAttributeNames: map[string]string{"#pk": config.PkAttribute, "#sk": config.SkAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: config.StateFilePrefix},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query state table %s: %w", b.sm.tableName, err)
+		}
+		for _, item := range page.Items {
+			sk, ok := item[config.SkAttribute].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			timestamp, _ := time.Parse(time.RFC3339, sk.Value)
+			versions = append(versions, Version{ID: sk.Value, Timestamp: timestamp})
+		}
+	}
+
+	if b.sm.bucketName == "" {
+		return versions, nil
+	}
+	backups, err := b.sm.listBackups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(versions, backups...), nil
+}
+
+// ReadVersion implements Backend
+func (b *dynamoBackend) ReadVersion(ctx context.Context, id string) (*config.StateData, error) {
+	if isBackupID(id) {
+		if b.sm.bucketName == "" {
+			return nil, fmt.Errorf("%w: %s, no backup bucket is configured", ErrVersionNotFound, id)
+		}
+		return b.sm.readObject(ctx, fmt.Sprintf("%s/%s.json", config.BackupFilePrefix, id))
+	}
+
+	out, err := b.sm.dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(b.sm.tableName),
+		Key: map[string]types.AttributeValue{
+			config.PkAttribute: &types.AttributeValueMemberS{Value: config.StateFilePrefix},
+			config.SkAttribute: &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state %s from %s: %w", id, b.sm.tableName, err)
+	}
+	if len(out.Item) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrVersionNotFound, id)
+	}
+	return b.sm.decodeItem(ctx, out.Item)
+}
+
+// DeleteBackup implements Backend
+func (b *dynamoBackend) DeleteBackup(ctx context.Context, id string) error {
+	if b.sm.bucketName == "" {
+		return fmt.Errorf("%w: %s, no backup bucket is configured", ErrVersionNotFound, id)
+	}
+	return b.sm.deleteObject(ctx, fmt.Sprintf("%s/%s.json", config.BackupFilePrefix, id))
+}
+
+// History implements Backend. States are the objects of the history prefix, backups
+// those of the backup prefix.
+func (b *s3Backend) History(ctx context.Context) ([]Version, error) {
+	var versions []Version
+	paginator := s3.NewListObjectsV2Paginator(b.sm.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.sm.bucketName),
+		Prefix: aws.String(s3HistoryPrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list state history in %s: %w", b.sm.bucketName, err)
+		}
+		for _, object := range page.Contents {
+			id := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(object.Key), s3HistoryPrefix), ".json")
+			timestamp, _ := time.Parse(time.RFC3339Nano, id)
+			versions = append(versions, Version{ID: id, Timestamp: timestamp})
+		}
+	}
+
+	backups, err := b.sm.listBackups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(versions, backups...), nil
+}
+
+// ReadVersion implements Backend
+func (b *s3Backend) ReadVersion(ctx context.Context, id string) (*config.StateData, error) {
+	if isBackupID(id) {
+		return b.sm.readObject(ctx, fmt.Sprintf("%s/%s.json", config.BackupFilePrefix, id))
+	}
+	return b.sm.readObject(ctx, s3HistoryPrefix+id+".json")
+}
+
+// DeleteBackup implements Backend
+func (b *s3Backend) DeleteBackup(ctx context.Context, id string) error {
+	return b.sm.deleteObject(ctx, fmt.Sprintf("%s/%s.json", config.BackupFilePrefix, id))
+}
+
+// History implements Backend. The state file only holds the latest state, so the
+// history is made of the backups next to it.
+func (b *localBackend) History(ctx context.Context) ([]Version, error) {
+	paths, err := filepath.Glob(b.backupPath(config.BackupFilePrefix + "-*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list state backups: %w", err)
+	}
+
+	prefix := strings.TrimSuffix(b.path, filepath.Ext(b.path)) + "."
+	versions := make([]Version, 0, len(paths))
+	for _, path := range paths {
+		id := strings.TrimSuffix(strings.TrimPrefix(path, prefix), filepath.Ext(b.path))
+		versions = append(versions, Version{ID: id, Timestamp: backupTimestamp(id), Backup: true})
+	}
+	return versions, nil
+}
+
+// ReadVersion implements Backend
+func (b *localBackend) ReadVersion(ctx context.Context, id string) (*config.StateData, error) {
+	if !isBackupID(id) {
+		return nil, fmt.Errorf("%w: %s, the local backend only keeps backups", ErrVersionNotFound, id)
+	}
+
+	data, err := os.ReadFile(b.backupPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrVersionNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state backup %s: %w", id, err)
+	}
+	return b.sm.decode(ctx, data)
+}
+
+// DeleteBackup implements Backend
+func (b *localBackend) DeleteBackup(ctx context.Context, id string) error {
+	if err := os.Remove(b.backupPath(id)); err != nil {
+		return fmt.Errorf("failed to delete state backup %s: %w", id, err)
+	}
+	return nil
+}
+
+// History implements Backend
+func (noneBackend) History(ctx context.Context) ([]Version, error) {
+	return nil, nil
+}
+
+// ReadVersion implements Backend
+func (noneBackend) ReadVersion(ctx context.Context, id string) (*config.StateData, error) {
+	return nil, fmt.Errorf("%w: %s, no state is kept", ErrVersionNotFound, id)
+}
+
+// DeleteBackup implements Backend
+func (noneBackend) DeleteBackup(ctx context.Context, id string) error {
+	return fmt.Errorf("%w: %s, no state is kept", ErrVersionNotFound, id)
+}
+
+// listBackups lists the backups of the backup bucket
+func (sm *StateManager) listBackups(ctx context.Context) ([]Version, error) {
+	var versions []Version
+	prefix := config.BackupFilePrefix + "/"
+	paginator := s3.NewListObjectsV2Paginator(sm.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(sm.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list state backups in %s: %w", sm.bucketName, err)
+		}
+		for _, object := range page.Contents {
+			id := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(object.Key), prefix), ".json")
+			version := Version{ID: id, Timestamp: backupTimestamp(id), Backup: true}
+			if object.LastModified != nil {
+				version.Timestamp = *object.LastModified
+			}
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+// readObject reads a state written by encode from an object of the backup bucket
+func (sm *StateManager) readObject(ctx context.Context, key string) (*config.StateData, error) {
+	out, err := sm.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(sm.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noKey *s3types.NoSuchKey
+		if errors.As(err, &noKey) {
+			return nil, fmt.Errorf("%w: %s", ErrVersionNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to read %s from %s: %w", key, sm.bucketName, err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from %s: %w", key, sm.bucketName, err)
+	}
+	return sm.decode(ctx, data)
+}
+
+// deleteObject deletes an object of the backup bucket
+func (sm *StateManager) deleteObject(ctx context.Context, key string) error {
+	if _, err := sm.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(sm.bucketName),
+		Key:    aws.String(key),
+	}); err != nil {
+		return fmt.Errorf("failed to delete %s from %s: %w", key, sm.bucketName, err)
+	}
+	return nil
+}
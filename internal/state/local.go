@@ -0,0 +1,116 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+)
+
+// localBackend stores the latest state in a file, for tests and local development
+type localBackend struct {
+	sm   *StateManager
+	path string
+}
+
+// Name implements Backend
+func (b *localBackend) Name() string {
+	return "local file " + b.path
+}
+
+// Write implements Backend. The file is replaced atomically, so readers never see a
+// partial state.
+func (b *localBackend) Write(ctx context.Context, stateData *config.StateData) error {
+	data, _, err := b.sm.encode(ctx, stateData)
+	if err != nil {
+		return err
+	}
+	return writeFile(b.path, data)
+}
+
+// Read implements Backend
+func (b *localBackend) Read(ctx context.Context) (*config.StateData, error) {
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", b.path, err)
+	}
+	return b.sm.decode(ctx, data)
+}
+
+// Backup implements Backend. Only explicit backups are kept, next to the state file.
+func (b *localBackend) Backup(ctx context.Context, stateData *config.StateData) error {
+	if stateData.BackupID == "" {
+		return nil
+	}
+
+	data, _, err := b.sm.encode(ctx, stateData)
+	if err != nil {
+		return err
+	}
+	return writeFile(b.backupPath(stateData.BackupID), data)
+}
+
+// Cleanup implements Backend. The state file only holds the latest state, and backups
+// are deleted after the backup retention period.
+func (b *localBackend) Cleanup(ctx context.Context, expiryDate time.Time) error {
+	backups, err := filepath.Glob(b.backupPath("*"))
+	if err != nil {
+		return fmt.Errorf("failed to list state backups: %w", err)
+	}
+
+	retention := time.Now().AddDate(0, 0, -b.sm.backupRetention)
+	for _, backup := range backups {
+		info, err := os.Stat(backup)
+		if err != nil || !info.ModTime().Before(retention) {
+			continue
+		}
+		if err := os.Remove(backup); err != nil {
+			return fmt.Errorf("failed to delete state backup %s: %w", backup, err)
+		}
+	}
+	return nil
+}
+
+// backupPath returns the path of a backup of the state file
+func (b *localBackend) backupPath(backupID string) string {
+	return fmt.Sprintf("%s.%s", strings.TrimSuffix(b.path, filepath.Ext(b.path)), backupID) + filepath.Ext(b.path)
+}
+
+// writeFile writes data to a temporary file renamed over the path
+func writeFile(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create state directory %s: %w", dir, err)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace state file %s: %w", path, err)
+	}
+	return nil
+}
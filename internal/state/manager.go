@@ -7,19 +7,27 @@
 package state
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
 	"go.uber.org/zap"
 )
 
@@ -31,14 +39,22 @@ type StateManager struct {
 	s3Client     *s3.Client
 	tableName    string
 	bucketName   string
+	awsConfig    aws.Config
+	encryptor    *encryptor
+	backendType  string
+	filePath     string
+	backend      Backend
 	mutex        sync.RWMutex
+
+	// Days backups are kept before PruneBackups deletes them
+	backupRetention int
 }
 
 // NewManager creates a new state manager instance with the provided options
 func NewManager(ctx context.Context, opts ...func(*StateManager) error) (*StateManager, error) {
-	logger, err := zap.NewProduction()
+	logger, err := logging.NewLogger("state-manager")
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+		return nil, err
 	}
 
 	metrics, err := metrics.NewCollector("state-manager")
@@ -53,6 +69,7 @@ func NewManager(ctx context.Context, opts ...func(*StateManager) error) (*StateM
 	cfg, err := awsconfig.LoadDefaultConfig(ctx,
 		awsconfig.WithRetryMode(aws.RetryModeStandard),
 		awsconfig.WithRetryMaxAttempts(config.MaxRetries),
+		awsconfig.WithAPIOptions([]func(*middleware.Stack) error{awsclient.ClassifyErrors}),
 	)
 	if err != nil {
 		logger.Error("failed to load AWS config", zap.Error(err))
@@ -66,6 +83,11 @@ func NewManager(ctx context.Context, opts ...func(*StateManager) error) (*StateM
 		s3Client:     s3.NewFromConfig(cfg),
 		tableName:    config.StateTableName,
 		bucketName:   config.StateBackupBucket,
+		awsConfig:    cfg,
+		backendType:  config.StateBackendDynamoDB,
+		filePath:     config.DefaultStateFilePath,
+
+		backupRetention: config.BackupRetentionDays,
 	}
 
 	// Apply options
@@ -76,11 +98,85 @@ func NewManager(ctx context.Context, opts ...func(*StateManager) error) (*StateM
 		}
 	}
 
+	if sm.backend, err = sm.newBackend(); err != nil {
+		logger.Error("failed to initialize state backend", zap.Error(err))
+		return nil, err
+	}
+
 	return sm, nil
 }
 
+// OptionsFor returns the manager options set by the landing zone configuration
+func OptionsFor(cfg *config.LandingZoneConfig) []func(*StateManager) error {
+	var opts []func(*StateManager) error
+	if cfg == nil {
+		return opts
+	}
+
+	if cfg.StateKMSKeyArn != "" {
+		opts = append(opts, WithKMSKey(cfg.StateKMSKeyArn))
+	}
+	if cfg.StateBackend != "" {
+		opts = append(opts, WithBackend(cfg.StateBackend))
+	}
+	if cfg.StateFilePath != "" {
+		opts = append(opts, WithStateFile(cfg.StateFilePath))
+	}
+	if cfg.StateTableName != "" {
+		opts = append(opts, WithTable(cfg.StateTableName))
+	}
+	if cfg.StateBucketName != "" {
+		opts = append(opts, WithBucket(cfg.StateBucketName))
+	}
+	if cfg.BackupRetentionDays != 0 {
+		opts = append(opts, WithBackupRetention(cfg.BackupRetentionDays))
+	}
+	return opts
+}
+
+// WithBackupRetention sets the number of days backups are kept
+func WithBackupRetention(days int) func(*StateManager) error {
+	return func(sm *StateManager) error {
+		if days <= 0 {
+			return fmt.Errorf("backup retention must be a positive number of days")
+		}
+		sm.backupRetention = days
+		return nil
+	}
+}
+
+// WithTable sets the DynamoDB table the state is stored in
+func WithTable(name string) func(*StateManager) error {
+	return func(sm *StateManager) error {
+		if name == "" {
+			return fmt.Errorf("a state table name is required")
+		}
+		sm.tableName = name
+		return nil
+	}
+}
+
+// WithBucket sets the S3 bucket the state and its backups are stored in
+func WithBucket(name string) func(*StateManager) error {
+	return func(sm *StateManager) error {
+		if name == "" {
+			return fmt.Errorf("a state bucket name is required")
+		}
+		sm.bucketName = name
+		return nil
+	}
+}
+
 // Save persists the current state with retry logic
 func (sm *StateManager) Save(ctx context.Context, state interface{}) error {
+	if err := readonly.Check("save state"); err != nil {
+		return &config.StateError{
+			Operation: "Save",
+			Message:   "state writes are disabled",
+			Err:       err,
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
 	defer cancel()
 
@@ -92,9 +188,21 @@ func (sm *StateManager) Save(ctx context.Context, state interface{}) error {
 		sm.metrics.RecordDuration("state_save_duration", time.Since(start))
 	}()
 
-	stateData := &config.StateData{
+	stateData := newStateData()
+	if err := sm.marshalState(state, stateData); err != nil {
+		return err
+	}
+	sm.carryRecords(ctx, stateData)
+
+	return sm.write(ctx, stateData)
+}
+
+// newStateData returns an empty state document stamped with the current time and run
+func newStateData() *config.StateData {
+	return &config.StateData{
 		Version:           config.ConfigVersion,
 		Timestamp:         time.Now(),
+		RunID:             runid.ID(),
 		Component:         "aws-organization",
 		StateTableName:    config.StateTableName,
 		StateBackupBucket: config.StateBackupBucket,
@@ -107,18 +215,17 @@ func (sm *StateManager) Save(ctx context.Context, state interface{}) error {
 			"service": "organization-config",
 		},
 	}
+}
 
-	if err := sm.marshalState(state, stateData); err != nil {
-		return err
-	}
-
+// write stores a state document with retry logic and backs it up asynchronously
+func (sm *StateManager) write(ctx context.Context, stateData *config.StateData) error {
 	backoff := config.InitialBackoff
 	for attempt := 0; attempt < config.MaxRetries; attempt++ {
-		if err := sm.saveToDynamoDB(ctx, stateData); err != nil {
-			if attempt == config.MaxRetries-1 {
+		if err := sm.backend.Write(ctx, stateData); err != nil {
+			if attempt == config.MaxRetries-1 || errors.Is(err, ErrConcurrentWrite) || awsclient.Permanent(err) {
 				return &config.StateError{
 					Operation: "Save",
-					Message:   "max retries exceeded while saving to DynamoDB",
+					Message:   fmt.Sprintf("max retries exceeded while saving to %s", sm.backend.Name()),
 					Err:       err,
 				}
 			}
@@ -134,8 +241,8 @@ func (sm *StateManager) Save(ctx context.Context, state interface{}) error {
 		ctx, cancel := context.WithTimeout(context.Background(), config.DefaultTimeout)
 		defer cancel()
 
-		if err := sm.backupToS3(ctx, stateData); err != nil {
-			sm.logger.Error("failed to backup state to S3",
+		if err := sm.backend.Backup(ctx, stateData); err != nil {
+			sm.logger.Error("failed to backup state",
 				zap.Error(err),
 				zap.String("stateVersion", stateData.Version))
 		}
@@ -166,15 +273,15 @@ func (sm *StateManager) Load(ctx context.Context) (*config.StateData, error) {
 	backoff := config.InitialBackoff
 
 	for attempt := 0; attempt < config.MaxRetries; attempt++ {
-		stateData, lastErr = sm.loadFromDynamoDB(ctx)
+		stateData, lastErr = sm.backend.Read(ctx)
 		if lastErr == nil {
 			break
 		}
 
-		if attempt == config.MaxRetries-1 {
+		if attempt == config.MaxRetries-1 || awsclient.Permanent(lastErr) {
 			return nil, &config.StateError{
 				Operation: "Load",
-				Message:   "max retries exceeded while loading from DynamoDB",
+				Message:   fmt.Sprintf("max retries exceeded while loading from %s", sm.backend.Name()),
 				Err:       lastErr,
 			}
 		}
@@ -183,6 +290,11 @@ func (sm *StateManager) Load(ctx context.Context) (*config.StateData, error) {
 		backoff *= 2
 	}
 
+	if stateData == nil {
+		sm.logger.Info("no stored state found")
+		return nil, nil
+	}
+
 	sm.metrics.IncrementCounter("state_loads")
 	sm.logger.Info("state loaded successfully",
 		zap.String("version", stateData.Version),
@@ -192,6 +304,14 @@ func (sm *StateManager) Load(ctx context.Context) (*config.StateData, error) {
 
 // CreateBackup creates a backup of the current state
 func (sm *StateManager) CreateBackup(ctx context.Context) (string, error) {
+	if err := readonly.Check("create state backup"); err != nil {
+		return "", &config.StateError{
+			Operation: "CreateBackup",
+			Message:   "state writes are disabled",
+			Err:       err,
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
 	defer cancel()
 
@@ -215,10 +335,10 @@ func (sm *StateManager) CreateBackup(ctx context.Context) (string, error) {
 	backupID := fmt.Sprintf("%s-%s", config.BackupFilePrefix, time.Now().Format("20060102-150405"))
 	stateData.BackupID = backupID
 
-	if err := sm.backupToS3(ctx, stateData); err != nil {
+	if err := sm.backend.Backup(ctx, stateData); err != nil {
 		return "", &config.StateError{
 			Operation: "CreateBackup",
-			Message:   "failed to create backup in S3",
+			Message:   fmt.Sprintf("failed to create backup in %s", sm.backend.Name()),
 			Err:       err,
 		}
 	}
@@ -232,6 +352,14 @@ func (sm *StateManager) CreateBackup(ctx context.Context) (string, error) {
 
 // CleanupOldStates removes expired states and backups
 func (sm *StateManager) CleanupOldStates(ctx context.Context) error {
+	if err := readonly.Check("cleanup old states"); err != nil {
+		return &config.StateError{
+			Operation: "CleanupOldStates",
+			Message:   "state writes are disabled",
+			Err:       err,
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout*2) // Longer timeout for cleanup
 	defer cancel()
 
@@ -245,20 +373,10 @@ func (sm *StateManager) CleanupOldStates(ctx context.Context) error {
 
 	expiryDate := time.Now().AddDate(0, 0, -config.StateExpiryDays)
 
-	// Cleanup DynamoDB
-	if err := sm.cleanupDynamoDB(ctx, expiryDate); err != nil {
-		return &config.StateError{
-			Operation: "CleanupOldStates",
-			Message:   "failed to cleanup DynamoDB",
-			Err:       err,
-		}
-	}
-
-	// Cleanup S3
-	if err := sm.cleanupS3(ctx, expiryDate); err != nil {
+	if err := sm.backend.Cleanup(ctx, expiryDate); err != nil {
 		return &config.StateError{
 			Operation: "CleanupOldStates",
-			Message:   "failed to cleanup S3",
+			Message:   fmt.Sprintf("failed to cleanup %s", sm.backend.Name()),
 			Err:       err,
 		}
 	}
@@ -307,19 +425,37 @@ func (sm *StateManager) saveToDynamoDB(ctx context.Context, stateData *config.St
 		return fmt.Errorf("failed to marshal state data: %w", err)
 	}
 
+	sk := stateData.Timestamp.Format(time.RFC3339)
 	item := map[string]types.AttributeValue{
 		config.PkAttribute: &types.AttributeValueMemberS{
 			Value: config.StateFilePrefix,
 		},
 		config.SkAttribute: &types.AttributeValueMemberS{
-			Value: stateData.Timestamp.Format(time.RFC3339),
-		},
-		config.StateAttribute: &types.AttributeValueMemberS{
-			Value: string(data),
+			Value: sk,
 		},
 		config.VersionAttribute: &types.AttributeValueMemberS{
 			Value: stateData.Version,
 		},
+		config.ExpiresAtAttribute: expiresAt(stateData.Timestamp),
+	}
+
+	// Encrypted states record the key and data key they are decrypted with
+	encrypted := false
+	if sm.encryptor != nil {
+		env, err := sm.encryptor.seal(ctx, data)
+		if err != nil {
+			return err
+		}
+		data, encrypted = env.Ciphertext, true
+		item[config.KeyArnAttribute] = &types.AttributeValueMemberS{Value: env.KeyArn}
+		item[config.DataKeyAttribute] = &types.AttributeValueMemberB{Value: env.DataKey}
+		item[config.NonceAttribute] = &types.AttributeValueMemberB{Value: env.Nonce}
+		sm.metrics.IncrementCounter("state_encryptions")
+	}
+
+	// Large states are written to chunk items or S3 before the item referencing them
+	if err := sm.writePayload(ctx, item, sk, stateData.Timestamp, data, encrypted); err != nil {
+		return err
 	}
 
 	_, err = sm.dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
@@ -331,21 +467,207 @@ func (sm *StateManager) saveToDynamoDB(ctx context.Context, stateData *config.St
 }
 
 func (sm *StateManager) loadFromDynamoDB(ctx context.Context) (*config.StateData, error) {
-	// Implementation for loading from DynamoDB
-	return nil, nil
+	out, err := sm.dynamoClient.Query(ctx, &dynamodb.QueryInput{
+		TableName:                aws.String(sm.tableName),
+		KeyConditionExpression:   aws.String("#pk = :pk"),
+		ExpressionAttributeNames: map[string]string{"#pk": config.PkAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: config.StateFilePrefix},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query state table %s: %w", sm.tableName, err)
+	}
+	if len(out.Items) == 0 {
+		return nil, nil
+	}
+
+	return sm.decodeItem(ctx, out.Items[0])
+}
+
+// decodeItem reads the state of an item of the state table, decrypting it when it
+// records its key
+func (sm *StateManager) decodeItem(ctx context.Context, item map[string]types.AttributeValue) (*config.StateData, error) {
+	data, err := sm.readPayload(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyArn, ok := item[config.KeyArnAttribute].(*types.AttributeValueMemberS); ok {
+		env := &envelope{KeyArn: keyArn.Value, Ciphertext: data}
+		if dataKey, ok := item[config.DataKeyAttribute].(*types.AttributeValueMemberB); ok {
+			env.DataKey = dataKey.Value
+		}
+		if nonce, ok := item[config.NonceAttribute].(*types.AttributeValueMemberB); ok {
+			env.Nonce = nonce.Value
+		}
+		if env.DataKey == nil || env.Nonce == nil {
+			return nil, fmt.Errorf("encrypted state is missing its key attributes")
+		}
+
+		if data, err = sm.decryptor().open(ctx, env); err != nil {
+			return nil, err
+		}
+	}
+
+	var stateData config.StateData
+	if err := json.Unmarshal(data, &stateData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state data: %w", err)
+	}
+	return &stateData, nil
 }
 
 func (sm *StateManager) backupToS3(ctx context.Context, stateData *config.StateData) error {
-	// Implementation for backing up to S3
+	data, keyArn, err := sm.encode(ctx, stateData)
+	if err != nil {
+		return err
+	}
+
+	name := stateData.BackupID
+	if name == "" {
+		name = fmt.Sprintf("%s-%s", config.BackupFilePrefix, stateData.Timestamp.Format("20060102-150405"))
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(sm.bucketName),
+		Key:         aws.String(fmt.Sprintf("%s/%s.json", config.BackupFilePrefix, name)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+
+	// Encrypted backups record the key ARN as metadata
+	if keyArn != "" {
+		input.Metadata = map[string]string{config.KeyArnAttribute: keyArn}
+	}
+
+	if _, err := sm.s3Client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to write backup %s to %s: %w", name, sm.bucketName, err)
+	}
 	return nil
 }
 
+// decryptor returns the encryptor of the manager, or a KMS client decrypting with the
+// keys recorded in encrypted states when no key is configured
+func (sm *StateManager) decryptor() *encryptor {
+	if sm.encryptor != nil {
+		return sm.encryptor
+	}
+	return &encryptor{client: kms.NewFromConfig(sm.awsConfig)}
+}
+
+// cleanupDynamoDB deletes expired states. With TTL enabled DynamoDB deletes them
+// itself, and only states saved before the TTL attribute was written are deleted here.
 func (sm *StateManager) cleanupDynamoDB(ctx context.Context, expiryDate time.Time) error {
-	// Implementation for cleaning up DynamoDB
+	ttl, err := sm.ttlEnabled(ctx)
+	if err != nil {
+		sm.logger.Warn("failed to check TTL, deleting expired states explicitly", zap.Error(err))
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(sm.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk AND #sk < :expiry"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": config.PkAttribute,
+			"#sk": config.SkAttribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: config.StateFilePrefix},
+			":expiry": &types.AttributeValueMemberS{Value: expiryDate.Format(time.RFC3339)},
+		},
+		ProjectionExpression: aws.String("#pk, #sk, #chunks"),
+	}
+	input.ExpressionAttributeNames["#chunks"] = config.ChunksAttribute
+	if ttl {
+		input.FilterExpression = aws.String("attribute_not_exists(#ttl)")
+		input.ExpressionAttributeNames["#ttl"] = config.ExpiresAtAttribute
+	}
+
+	var requests []types.WriteRequest
+	paginator := dynamodb.NewQueryPaginator(sm.dynamoClient, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to query expired states: %w", err)
+		}
+		for _, item := range page.Items {
+			requests = append(requests, types.WriteRequest{
+				DeleteRequest: &types.DeleteRequest{Key: map[string]types.AttributeValue{
+					config.PkAttribute: item[config.PkAttribute],
+					config.SkAttribute: item[config.SkAttribute],
+				}},
+			})
+			requests = append(requests, chunkDeletes(item)...)
+		}
+	}
+
+	if err := sm.writeBatches(ctx, requests); err != nil {
+		return fmt.Errorf("failed to delete expired states: %w", err)
+	}
+
+	sm.logger.Info("expired states deleted", zap.Bool("ttlEnabled", ttl))
 	return nil
 }
 
+// writeBatches sends write requests to the state table in batches, retrying the
+// unprocessed ones
+func (sm *StateManager) writeBatches(ctx context.Context, requests []types.WriteRequest) error {
+	for len(requests) > 0 {
+		n := min(len(requests), maxBatchWriteItems)
+		if err := sm.writeBatch(ctx, requests[:n]); err != nil {
+			return err
+		}
+		requests = requests[n:]
+	}
+	return nil
+}
+
+// writeBatch sends a single batch of write requests
+func (sm *StateManager) writeBatch(ctx context.Context, requests []types.WriteRequest) error {
+	backoff := config.InitialBackoff
+	for attempt := 0; attempt < config.MaxRetries; attempt++ {
+		out, err := sm.dynamoClient.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{sm.tableName: requests},
+		})
+		if err != nil {
+			return err
+		}
+
+		if requests = out.UnprocessedItems[sm.tableName]; len(requests) == 0 {
+			return nil
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return fmt.Errorf("%d items unprocessed after %d attempts: %w", len(requests), config.MaxRetries, awsclient.ErrThrottled)
+}
+
+// cleanupS3 deletes the offloaded payloads of expired states
 func (sm *StateManager) cleanupS3(ctx context.Context, expiryDate time.Time) error {
-	// Implementation for cleaning up S3
+	paginator := s3.NewListObjectsV2Paginator(sm.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(sm.bucketName),
+		Prefix: aws.String(config.StateFilePrefix + "/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list state payloads in %s: %w", sm.bucketName, err)
+		}
+
+		for _, object := range page.Contents {
+			if object.LastModified == nil || !object.LastModified.Before(expiryDate) {
+				continue
+			}
+			if _, err := sm.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(sm.bucketName),
+				Key:    object.Key,
+			}); err != nil {
+				return fmt.Errorf("failed to delete state payload %s: %w", aws.ToString(object.Key), err)
+			}
+			sm.metrics.IncrementCounter("state_payloads_deleted")
+		}
+	}
 	return nil
 }
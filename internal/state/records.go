@@ -0,0 +1,238 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// recordPartition holds the records in the state table, outside the state partition
+	recordPartition = config.StateFilePrefix + "#record"
+	// s3RecordPrefix holds the records in the bucket of the S3 backend
+	s3RecordPrefix = "records/"
+)
+
+// SaveRecord stores a record apart from the state. A record is replaced in place: it
+// creates no state version or backup, so it never shows in the state history.
+func (sm *StateManager) SaveRecord(ctx context.Context, name string, record interface{}) error {
+	if err := readonly.Check("save state record"); err != nil {
+		return &config.StateError{
+			Operation: "SaveRecord",
+			Message:   "state writes are disabled",
+			Err:       err,
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return &config.StateError{
+			Operation: "SaveRecord",
+			Message:   fmt.Sprintf("failed to marshal record %s", name),
+			Err:       err,
+		}
+	}
+
+	// Records are encoded as a document holding them alone, so they are encrypted
+	// like the state
+	encoded, keyArn, err := sm.encode(ctx, &config.StateData{
+		Version:   config.ConfigVersion,
+		Timestamp: time.Now(),
+		RunID:     runid.ID(),
+		Records:   map[string]json.RawMessage{name: data},
+	})
+	if err != nil {
+		return &config.StateError{
+			Operation: "SaveRecord",
+			Message:   fmt.Sprintf("failed to encode record %s", name),
+			Err:       err,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+
+	if err := sm.backend.WriteRecord(ctx, name, encoded, keyArn); err != nil {
+		return &config.StateError{
+			Operation: "SaveRecord",
+			Message:   fmt.Sprintf("failed to write record %s to %s", name, sm.backend.Name()),
+			Err:       err,
+		}
+	}
+	sm.metrics.IncrementCounter("state_records_saved")
+	return nil
+}
+
+// LoadRecord decodes a record into record. It reports whether the record exists.
+// Records saved inside the state by earlier versions are read when no record is
+// stored apart from it.
+func (sm *StateManager) LoadRecord(ctx context.Context, name string, record interface{}) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+
+	encoded, err := sm.backend.ReadRecord(ctx, name)
+	if err != nil {
+		return false, &config.StateError{
+			Operation: "LoadRecord",
+			Message:   fmt.Sprintf("failed to read record %s from %s", name, sm.backend.Name()),
+			Err:       err,
+		}
+	}
+
+	var stateData *config.StateData
+	if encoded != nil {
+		if stateData, err = sm.decode(ctx, encoded); err != nil {
+			return false, &config.StateError{
+				Operation: "LoadRecord",
+				Message:   fmt.Sprintf("failed to decode record %s", name),
+				Err:       err,
+			}
+		}
+	} else if stateData, err = sm.Load(ctx); err != nil {
+		return false, err
+	}
+	if stateData == nil {
+		return false, nil
+	}
+
+	data, ok := stateData.Records[name]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, record); err != nil {
+		return false, &config.StateError{
+			Operation: "LoadRecord",
+			Message:   fmt.Sprintf("failed to unmarshal record %s", name),
+			Err:       err,
+		}
+	}
+	return true, nil
+}
+
+// carryRecords copies the records of the stored state into a new state document, so
+// saving the configuration does not drop the records saved inside the state by
+// earlier versions
+func (sm *StateManager) carryRecords(ctx context.Context, stateData *config.StateData) {
+	stored, err := sm.backend.Read(ctx)
+	if err != nil {
+		sm.logger.Warn("failed to read stored state, its records are not carried over", zap.Error(err))
+		return
+	}
+	if stored != nil {
+		stateData.Records = stored.Records
+	}
+}
+
+// WriteRecord implements Backend. Records are items of their own partition, without
+// expiry.
+func (b *dynamoBackend) WriteRecord(ctx context.Context, name string, data []byte, keyArn string) error {
+	if len(data) > maxItemPayload {
+		return fmt.Errorf("record of %d bytes exceeds the item size of the state table", len(data))
+	}
+
+	_, err := b.sm.dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(b.sm.tableName),
+		Item: map[string]types.AttributeValue{
+			config.PkAttribute:    &types.AttributeValueMemberS{Value: recordPartition},
+			config.SkAttribute:    &types.AttributeValueMemberS{Value: name},
+			config.StateAttribute: &types.AttributeValueMemberS{Value: string(data)},
+		},
+	})
+	return err
+}
+
+// ReadRecord implements Backend
+func (b *dynamoBackend) ReadRecord(ctx context.Context, name string) ([]byte, error) {
+	out, err := b.sm.dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(b.sm.tableName),
+		Key: map[string]types.AttributeValue{
+			config.PkAttribute: &types.AttributeValueMemberS{Value: recordPartition},
+			config.SkAttribute: &types.AttributeValueMemberS{Value: name},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, nil
+	}
+	if data, ok := out.Item[config.StateAttribute].(*types.AttributeValueMemberS); ok {
+		return []byte(data.Value), nil
+	}
+	return nil, fmt.Errorf("record item has no %s attribute", config.StateAttribute)
+}
+
+// WriteRecord implements Backend. Records are objects of their own prefix, outside the
+// state history.
+func (b *s3Backend) WriteRecord(ctx context.Context, name string, data []byte, keyArn string) error {
+	_, err := b.sm.s3Client.PutObject(ctx, b.putInput(s3RecordPrefix+name+".json", data, keyArn))
+	return err
+}
+
+// ReadRecord implements Backend
+func (b *s3Backend) ReadRecord(ctx context.Context, name string) ([]byte, error) {
+	out, err := b.sm.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.sm.bucketName),
+		Key:    aws.String(s3RecordPrefix + name + ".json"),
+	})
+	if err != nil {
+		var noKey *s3types.NoSuchKey
+		if errors.As(err, &noKey) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+// WriteRecord implements Backend. Records are files of a directory next to the state
+// file.
+func (b *localBackend) WriteRecord(ctx context.Context, name string, data []byte, keyArn string) error {
+	return writeFile(b.recordPath(name), data)
+}
+
+// ReadRecord implements Backend
+func (b *localBackend) ReadRecord(ctx context.Context, name string) ([]byte, error) {
+	data, err := os.ReadFile(b.recordPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// recordPath returns the path of a record of the state file
+func (b *localBackend) recordPath(name string) string {
+	return filepath.Join(strings.TrimSuffix(b.path, filepath.Ext(b.path))+".records", name+".json")
+}
+
+// WriteRecord implements Backend
+func (noneBackend) WriteRecord(ctx context.Context, name string, data []byte, keyArn string) error {
+	return nil
+}
+
+// ReadRecord implements Backend
+func (noneBackend) ReadRecord(ctx context.Context, name string) ([]byte, error) {
+	return nil, nil
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package state
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const (
+	// s3LatestKey holds the latest state in the bucket of the S3 backend
+	s3LatestKey = config.StateFilePrefix + ".json"
+	// s3HistoryPrefix holds every state written by the S3 backend
+	s3HistoryPrefix = "history/"
+)
+
+// ErrConcurrentWrite reports a state replaced by another writer since it was read
+var ErrConcurrentWrite = errors.New("state was modified concurrently")
+
+// s3Backend stores states in the backup bucket only. The latest state is replaced with
+// conditional writes, so concurrent writers cannot overwrite each other's state.
+type s3Backend struct {
+	sm *StateManager
+	// etag of the latest state as last read or written, empty when it did not exist
+	etag  string
+	known bool
+}
+
+// Name implements Backend
+func (b *s3Backend) Name() string {
+	return "S3 bucket " + b.sm.bucketName
+}
+
+// Write implements Backend
+func (b *s3Backend) Write(ctx context.Context, stateData *config.StateData) error {
+	data, keyArn, err := b.sm.encode(ctx, stateData)
+	if err != nil {
+		return err
+	}
+
+	if !b.known {
+		if err := b.head(ctx); err != nil {
+			return err
+		}
+	}
+
+	input := b.putInput(s3LatestKey, data, keyArn)
+	if b.etag == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(b.etag)
+	}
+
+	out, err := b.sm.s3Client.PutObject(ctx, input)
+	if err != nil {
+		if preconditionFailed(err) {
+			b.known = false
+			return fmt.Errorf("failed to write %s: %w", s3LatestKey, ErrConcurrentWrite)
+		}
+		return fmt.Errorf("failed to write %s to %s: %w", s3LatestKey, b.sm.bucketName, err)
+	}
+	b.etag, b.known = aws.ToString(out.ETag), true
+
+	// History objects are never replaced
+	history := b.putInput(s3HistoryPrefix+stateData.Timestamp.Format(time.RFC3339Nano)+".json", data, keyArn)
+	history.IfNoneMatch = aws.String("*")
+	if _, err := b.sm.s3Client.PutObject(ctx, history); err != nil && !preconditionFailed(err) {
+		return fmt.Errorf("failed to write state history to %s: %w", b.sm.bucketName, err)
+	}
+	return nil
+}
+
+// Read implements Backend
+func (b *s3Backend) Read(ctx context.Context) (*config.StateData, error) {
+	out, err := b.sm.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.sm.bucketName),
+		Key:    aws.String(s3LatestKey),
+	})
+	if err != nil {
+		var noKey *s3types.NoSuchKey
+		if errors.As(err, &noKey) {
+			b.etag, b.known = "", true
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s from %s: %w", s3LatestKey, b.sm.bucketName, err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from %s: %w", s3LatestKey, b.sm.bucketName, err)
+	}
+	b.etag, b.known = aws.ToString(out.ETag), true
+
+	return b.sm.decode(ctx, data)
+}
+
+// Backup implements Backend
+func (b *s3Backend) Backup(ctx context.Context, stateData *config.StateData) error {
+	return b.sm.backupToS3(ctx, stateData)
+}
+
+// Cleanup implements Backend. The latest state is always kept.
+func (b *s3Backend) Cleanup(ctx context.Context, expiryDate time.Time) error {
+	paginator := s3.NewListObjectsV2Paginator(b.sm.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.sm.bucketName),
+		Prefix: aws.String(s3HistoryPrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list state history in %s: %w", b.sm.bucketName, err)
+		}
+
+		for _, object := range page.Contents {
+			if object.LastModified == nil || !object.LastModified.Before(expiryDate) {
+				continue
+			}
+			if _, err := b.sm.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(b.sm.bucketName),
+				Key:    object.Key,
+			}); err != nil {
+				return fmt.Errorf("failed to delete state %s: %w", aws.ToString(object.Key), err)
+			}
+		}
+	}
+	return nil
+}
+
+// head records the etag of the latest state before a write not preceded by a read
+func (b *s3Backend) head(ctx context.Context) error {
+	out, err := b.sm.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.sm.bucketName),
+		Key:    aws.String(s3LatestKey),
+	})
+	if err != nil {
+		var notFound *s3types.NotFound
+		if errors.As(err, &notFound) {
+			b.etag, b.known = "", true
+			return nil
+		}
+		return fmt.Errorf("failed to read %s from %s: %w", s3LatestKey, b.sm.bucketName, err)
+	}
+	b.etag, b.known = aws.ToString(out.ETag), true
+	return nil
+}
+
+// putInput returns the request writing a state object
+func (b *s3Backend) putInput(key string, data []byte, keyArn string) *s3.PutObjectInput {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(b.sm.bucketName),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+	if keyArn != "" {
+		input.Metadata = map[string]string{config.KeyArnAttribute: keyArn}
+	}
+	return input
+}
+
+// preconditionFailed reports whether a conditional write was rejected
+func preconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "PreconditionFailed" {
+		return true
+	}
+	var respErr *smithyhttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusPreconditionFailed
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package stream provides the live progress of deployments: the deploy command publishes
+// the progress of its updates to the server of the serve command, which pushes it to
+// portals over Server-Sent Events or WebSocket.
+// Version: 1.0.0
+package stream
+
+import (
+	"sync"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/progress"
+)
+
+const (
+	// Number of recent events replayed to a new subscriber
+	replayEvents = 1000
+
+	// Number of events a subscriber may lag behind before it is dropped
+	subscriberBuffer = 256
+)
+
+// subscriber receives the events of a run, or of every run when run is empty
+type subscriber struct {
+	run    string
+	events chan progress.Event
+}
+
+// Hub fans the published events out to the subscribers. A subscriber joining while a
+// deployment runs first receives the recent events, so a portal opened part way through
+// shows the resources already done. Subscribers too slow to keep up are dropped rather
+// than slowing down the publishers.
+type Hub struct {
+	mutex       sync.Mutex
+	recent      []progress.Event
+	subscribers map[*subscriber]bool
+}
+
+// NewHub creates a hub without subscribers
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*subscriber]bool)}
+}
+
+// Publish records an event and sends it to the subscribers of its run
+func (h *Hub) Publish(event progress.Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.recent = append(h.recent, event)
+	if len(h.recent) > replayEvents {
+		h.recent = append([]progress.Event(nil), h.recent[len(h.recent)-replayEvents:]...)
+	}
+
+	for s := range h.subscribers {
+		if !s.matches(event) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			delete(h.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the recent and future events of a run, or of
+// every run when run is empty, and a function ending the subscription. The channel is
+// closed when the subscription ends or the subscriber falls behind.
+func (h *Hub) Subscribe(run string) (<-chan progress.Event, func()) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s := &subscriber{run: run}
+	var replay []progress.Event
+	for _, event := range h.recent {
+		if s.matches(event) {
+			replay = append(replay, event)
+		}
+	}
+	s.events = make(chan progress.Event, len(replay)+subscriberBuffer)
+	for _, event := range replay {
+		s.events <- event
+	}
+	h.subscribers[s] = true
+
+	return s.events, func() {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		if h.subscribers[s] {
+			delete(h.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// Subscribers returns the number of subscribers
+func (h *Hub) Subscribers() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.subscribers)
+}
+
+// matches reports whether an event belongs to the run of the subscriber
+func (s *subscriber) matches(event progress.Event) bool {
+	return s.run == "" || s.run == event.RunID
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/progress"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"go.uber.org/zap"
+)
+
+const (
+	// Number of events waiting to be sent before new events are dropped
+	queueSize = 1024
+
+	// Time the server is given to receive the events left once an update ends
+	flushTimeout = 5 * time.Second
+)
+
+// Publisher publishes the progress of the updates of a deployment to the progress
+// server. Publishing never fails an update: when the server cannot be reached, the
+// progress is dropped and the failure logged.
+type Publisher struct {
+	logger *zap.Logger
+	client *http.Client
+	url    string
+	token  string
+}
+
+// NewPublisher creates a publisher to the progress server of the configuration
+func NewPublisher(cfg *config.ProgressStreamConfig) (*Publisher, error) {
+	logger, err := logging.NewLogger("stream")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Publisher{
+		logger: logger,
+		client: &http.Client{},
+		url:    strings.TrimSuffix(cfg.URL, "/") + PathProgress,
+		token:  cfg.Token(),
+	}, nil
+}
+
+// Track returns a channel receiving the engine events of an update of a stack, and a
+// function to call once the update returned, waiting for the events to be sent. The
+// engine does not close the channel when the update fails before it starts, so the
+// function does not wait for it. The events of an update are sent in the body of a single request, as the
+// resources progress. A server too slow to keep up loses events rather than holding
+// up the engine, and is given a few seconds after the update to receive the rest.
+func (p *Publisher) Track(ctx context.Context, stack string) (chan events.EngineEvent, func()) {
+	eventCh := make(chan events.EngineEvent)
+	queue := make(chan progress.Event, queueSize)
+	reader, writer := io.Pipe()
+	postCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	returned := make(chan struct{})
+	drained := make(chan struct{})
+	sent := make(chan struct{})
+
+	go func() {
+		defer close(sent)
+		// The reader is closed however the request ends, so the encoder never blocks
+		// on a body the server stopped reading
+		err := p.post(postCtx, reader)
+		reader.CloseWithError(err)
+		if err != nil {
+			p.logger.Warn("failed to publish deployment progress",
+				zap.String("url", p.url),
+				zap.Error(err))
+		}
+	}()
+
+	go func() {
+		defer close(drained)
+		defer close(queue)
+		timeline := progress.NewTimeline(stack)
+		dropped := 0
+	receive:
+		for {
+			// The channel is unbuffered, so every event was received once the update
+			// returned
+			var event events.EngineEvent
+			select {
+			case e, ok := <-eventCh:
+				if !ok {
+					break receive
+				}
+				event = e
+			case <-returned:
+				break receive
+			}
+
+			resource, ok := timeline.Event(event)
+			if !ok {
+				continue
+			}
+			select {
+			case queue <- resource:
+			default:
+				dropped++
+			}
+		}
+		if dropped > 0 {
+			p.logger.Warn("deployment progress events dropped", zap.Int("dropped", dropped))
+		}
+	}()
+
+	go func() {
+		encoder := json.NewEncoder(writer)
+		failed := false
+		for resource := range queue {
+			if !failed {
+				failed = encoder.Encode(resource) != nil
+			}
+		}
+		writer.Close()
+	}()
+
+	return eventCh, func() {
+		defer cancel()
+		close(returned)
+		<-drained
+		select {
+		case <-sent:
+		case <-time.After(flushTimeout):
+			cancel()
+			<-sent
+		}
+	}
+}
+
+// post sends the events read from body to the progress server
+func (p *Publisher) post(ctx context.Context, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("progress server returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
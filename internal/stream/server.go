@@ -0,0 +1,288 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package stream
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/progress"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// Paths of the progress endpoints
+	PathProgress          = "/v1/progress"
+	PathProgressWebSocket = "/v1/progress/ws"
+
+	// Query parameters selecting the run streamed and carrying the token of clients
+	// that cannot set headers, such as browsers opening a WebSocket
+	ParamRun   = "run"
+	ParamToken = "token"
+
+	// Interval of the comments keeping idle Server-Sent Events connections open
+	keepAliveInterval = 15 * time.Second
+
+	// Time the server is given to answer the requests in flight when it stops
+	shutdownTimeout = 5 * time.Second
+
+	// Time a client may take to send the headers of a request
+	readHeaderTimeout = 5 * time.Second
+)
+
+// Server receives the events published by deployments on PathProgress and streams
+// them to subscribers as Server-Sent Events on the same path, or as JSON messages on
+// PathProgressWebSocket
+type Server struct {
+	logger  *zap.Logger
+	metrics *metrics.Collector
+	hub     *Hub
+	token   string
+}
+
+// NewServer creates a progress server. When token is set, publishers and subscribers
+// must present it as a bearer token or in the token query parameter.
+func NewServer(token string) (*Server, error) {
+	logger, err := logging.NewLogger("stream")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("stream")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	return &Server{logger: logger, metrics: metrics, hub: NewHub(), token: token}, nil
+}
+
+// Hub returns the hub of the server, which events may also be published to directly
+func (s *Server) Hub() *Hub {
+	return s.hub
+}
+
+// Handler returns the handler of the progress endpoints
+func (s *Server) Handler() http.Handler {
+	ws := websocket.Server{
+		Handshake: s.handshake,
+		Handler:   s.serveWebSocket,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathProgress, func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			if !s.acceptsBody(r) {
+				http.Error(w, "progress events must be sent as JSON", http.StatusUnsupportedMediaType)
+				return
+			}
+			s.ingest(w, r)
+		case http.MethodGet:
+			s.serveEvents(w, r)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc(PathProgressWebSocket, func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		ws.ServeHTTP(w, r)
+	})
+	return mux
+}
+
+// Serve serves the progress endpoints on listener until the context ends, which also
+// ends the streams of the subscribers
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			s.logger.Warn("failed to stop progress server", zap.Error(err))
+		}
+	}()
+
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("progress server failed: %w", err)
+	}
+	<-done
+	return nil
+}
+
+// authorized reports whether a request carries the token of the server
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	token := r.URL.Query().Get(ParamToken)
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// handshake accepts the WebSocket handshakes of clients that present the token. Without
+// a token, which only a loopback server allows, it rejects the handshakes of pages of
+// other origins, so that a site opened in a browser cannot subscribe.
+func (s *Server) handshake(config *websocket.Config, r *http.Request) error {
+	if s.token != "" {
+		return nil
+	}
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin != nil && !strings.EqualFold(origin.Host, r.Host) {
+		return fmt.Errorf("cross-origin WebSocket handshake from %s rejected", origin)
+	}
+	return nil
+}
+
+// acceptsBody reports whether the events of a POST may be published. Without a token,
+// only JSON bodies are accepted: browsers cannot send them to another origin without a
+// preflight the server does not answer.
+func (s *Server) acceptsBody(r *http.Request) bool {
+	if s.token != "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "application/x-ndjson"
+}
+
+// ingest publishes the events of a deployment, sent as a stream of JSON objects for
+// as long as its updates run
+func (s *Server) ingest(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	published := 0
+	for {
+		var event progress.Event
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			s.logger.Warn("invalid progress event", zap.Int("published", published), zap.Error(err))
+			http.Error(w, fmt.Sprintf("invalid progress event: %v", err), http.StatusBadRequest)
+			return
+		}
+		s.hub.Publish(event)
+		published++
+	}
+
+	s.metrics.IncrementCounter("deployments_published")
+	s.logger.Info("deployment progress published", zap.Int("events", published))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveEvents streams the events of the run of the request as Server-Sent Events until
+// the client goes away
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := s.hub.Subscribe(r.URL.Query().Get(ParamRun))
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	s.metrics.IncrementCounter("subscriptions")
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				s.logger.Warn("failed to encode progress event", zap.Error(err))
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// serveWebSocket streams the events of the run of the request as JSON messages until
+// the client goes away
+func (s *Server) serveWebSocket(conn *websocket.Conn) {
+	defer conn.Close()
+
+	r := conn.Request()
+	events, unsubscribe := s.hub.Subscribe(r.URL.Query().Get(ParamRun))
+	defer unsubscribe()
+
+	// Subscribers send nothing; reading notices when they close the connection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		io.Copy(io.Discard, conn)
+	}()
+
+	s.metrics.IncrementCounter("subscriptions")
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-closed:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := websocket.JSON.Send(conn, event); err != nil {
+				return
+			}
+		}
+	}
+}
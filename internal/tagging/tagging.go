@@ -0,0 +1,99 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package tagging propagates the standard tags of the landing zone to every resource.
+// Version: 1.0.0
+package tagging
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+// tagsField is the field of the resource arguments holding the tags
+const tagsField = "Tags"
+
+// stringMapInput is the type of the tags of the resources taking a map of tags
+var stringMapInput = reflect.TypeOf((*pulumi.StringMapInput)(nil)).Elem()
+
+// Register adds a stack transformation giving every resource registered afterwards
+// the standard tags of the landing zone. It must be called before the modules create
+// their resources.
+func Register(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("tagging")
+	if err != nil {
+		return err
+	}
+
+	if cfg.TagPropagation == nil {
+		logger.Info("tag propagation is not configured")
+		return nil
+	}
+
+	tags := cfg.StandardTags()
+	if err := ctx.RegisterStackTransformation(Transformation(tags, cfg.TagPropagation.Excluded())); err != nil {
+		return fmt.Errorf("failed to register tag propagation: %w", err)
+	}
+
+	logger.Info("propagating standard tags", zap.Int("tags", len(tags)))
+	return nil
+}
+
+// Transformation returns the transformation adding the tags to the arguments of
+// every resource taking a map of tags, except the excluded types. Tags already in
+// the arguments win.
+func Transformation(tags map[string]string, excluded map[string]bool) pulumi.ResourceTransformation {
+	return func(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+		if excluded[args.Type] || args.Props == nil {
+			return nil
+		}
+
+		props := reflect.ValueOf(args.Props)
+		if props.Kind() != reflect.Ptr || props.IsNil() || props.Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		field := props.Elem().FieldByName(tagsField)
+		if !field.IsValid() || !field.CanSet() || field.Type() != stringMapInput {
+			return nil
+		}
+
+		var current pulumi.StringMapInput
+		if !field.IsNil() {
+			current = field.Interface().(pulumi.StringMapInput)
+		}
+		field.Set(reflect.ValueOf(merge(tags, current)))
+
+		return &pulumi.ResourceTransformationResult{Props: args.Props, Opts: args.Opts}
+	}
+}
+
+// merge returns the standard tags overridden by the tags of a resource
+func merge(tags map[string]string, current pulumi.StringMapInput) pulumi.StringMapInput {
+	switch current := current.(type) {
+	case nil:
+		return pulumi.ToStringMap(tags)
+	case pulumi.StringMap:
+		merged := pulumi.ToStringMap(tags)
+		for key, value := range current {
+			merged[key] = value
+		}
+		return merged
+	default:
+		return current.ToStringMapOutput().ApplyT(func(resourceTags map[string]string) map[string]string {
+			merged := make(map[string]string, len(tags)+len(resourceTags))
+			for key, value := range tags {
+				merged[key] = value
+			}
+			for key, value := range resourceTags {
+				merged[key] = value
+			}
+			return merged
+		}).(pulumi.StringMapOutput)
+	}
+}
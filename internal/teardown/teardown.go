@@ -0,0 +1,703 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package teardown provides ordered decommissioning of the landing zone.
+// Version: 1.0.0
+package teardown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/controltower"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	ct "github.com/aws/aws-sdk-go-v2/service/controltower"
+	cttypes "github.com/aws/aws-sdk-go-v2/service/controltower/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/smithy-go/middleware"
+	"go.uber.org/zap"
+)
+
+// Step identifies a single decommissioning step
+type Step string
+
+const (
+	// Decommissioning steps
+	StepDetachPolicies  Step = "detach-policies"
+	StepDisableControls Step = "disable-controls"
+	StepMoveAccounts    Step = "move-accounts"
+	StepDeleteOUs       Step = "delete-ous"
+	StepSharedInfra     Step = "shared-infrastructure"
+
+	// Batch sizes imposed by the AWS APIs
+	ssmDeleteBatchSize = 10
+	s3DeleteBatchSize  = 1000
+
+	// Control Tower control operations are asynchronous
+	controlOperationPollInterval = 15 * time.Second
+	controlOperationTimeout      = 30 * time.Minute
+)
+
+// Steps lists the decommissioning steps in execution order
+var Steps = []Step{
+	StepDetachPolicies,
+	StepDisableControls,
+	StepMoveAccounts,
+	StepDeleteOUs,
+	StepSharedInfra,
+}
+
+// ErrConfirmationMismatch is returned when the typed confirmation does not match the organization ID
+var ErrConfirmationMismatch = errors.New("confirmation does not match the organization ID")
+
+// Options controls a destroy run
+type Options struct {
+	// Confirmation must equal the ID of the organization being destroyed
+	Confirmation string
+	// Retain lists additional resource names to keep, on top of the configured ones
+	Retain []string
+}
+
+// ouNode represents an organizational unit discovered while walking the hierarchy
+type ouNode struct {
+	id     string
+	arn    string
+	name   string
+	parent string
+	depth  int
+}
+
+// Decommissioner tears down landing zone resources in dependency order
+type Decommissioner struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	orgClient *organizations.Client
+	ctClient  *ct.Client
+	s3Client  *s3.Client
+	ssmClient *ssm.Client
+	iamClient *iam.Client
+	retain    map[string]bool
+	rootID    string
+	ous       []ouNode
+
+	// IDs of the retained OUs with their nested OUs, and of their ancestors, which
+	// cannot be deleted while they hold a retained OU
+	retainedOUs map[string]bool
+	ancestors   map[string]bool
+}
+
+// NewDecommissioner creates a new decommissioner instance
+func NewDecommissioner(ctx context.Context) (*Decommissioner, error) {
+	logger, err := logging.NewLogger("teardown")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("teardown")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRetryMode(aws.RetryModeStandard),
+		awsconfig.WithRetryMaxAttempts(config.MaxRetries),
+		awsconfig.WithAPIOptions([]func(*middleware.Stack) error{awsclient.ClassifyErrors}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &Decommissioner{
+		logger:    logger,
+		metrics:   metrics,
+		orgClient: organizations.NewFromConfig(cfg),
+		ctClient:  ct.NewFromConfig(cfg),
+		s3Client:  s3.NewFromConfig(cfg),
+		ssmClient: ssm.NewFromConfig(cfg),
+		iamClient: iam.NewFromConfig(cfg),
+	}, nil
+}
+
+// OrganizationID returns the ID of the organization the credentials belong to
+func (d *Decommissioner) OrganizationID(ctx context.Context) (string, error) {
+	out, err := d.orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe organization: %w", err)
+	}
+	return aws.ToString(out.Organization.Id), nil
+}
+
+// Destroy runs every decommissioning step in order, stopping at the first failure
+func (d *Decommissioner) Destroy(ctx context.Context, cfg *config.LandingZoneConfig, opts Options) error {
+	if err := readonly.Check("destroy landing zone"); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer func() {
+		d.metrics.RecordDuration("destroy_duration", time.Since(start))
+	}()
+
+	orgID, err := d.OrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+	if opts.Confirmation != orgID {
+		return fmt.Errorf("%w: expected %s", ErrConfirmationMismatch, orgID)
+	}
+
+	d.retain = RetainedResources(cfg, opts.Retain)
+	if err := d.discover(ctx); err != nil {
+		return err
+	}
+
+	d.logger.Info("starting landing zone teardown",
+		zap.String("organizationId", orgID),
+		zap.Int("organizationalUnits", len(d.ous)),
+		zap.Int("retainedResources", len(d.retain)))
+
+	runners := map[Step]func(context.Context, *config.LandingZoneConfig) error{
+		StepDetachPolicies:  d.detachPolicies,
+		StepDisableControls: d.disableControls,
+		StepMoveAccounts:    d.moveAccounts,
+		StepDeleteOUs:       d.deleteOUs,
+		StepSharedInfra:     d.deleteSharedInfra,
+	}
+
+	for _, step := range Steps {
+		stepStart := time.Now()
+		d.logger.Info("running teardown step", zap.String("step", string(step)))
+
+		if err := runners[step](ctx, cfg); err != nil {
+			d.logger.Error("teardown step failed", zap.String("step", string(step)), zap.Error(err))
+			return fmt.Errorf("teardown step %s failed: %w", step, err)
+		}
+
+		d.metrics.RecordDuration(fmt.Sprintf("destroy_step_%s", sanitizeMetricName(step)), time.Since(stepStart))
+	}
+
+	d.metrics.IncrementCounter("destroys_completed")
+	d.logger.Info("landing zone teardown completed", zap.Duration("duration", time.Since(start)))
+	return nil
+}
+
+// RetainedResources builds the set of resource names the destroy workflow must keep.
+// The log buckets are retained unless the configuration provides its own list.
+func RetainedResources(cfg *config.LandingZoneConfig, extra []string) map[string]bool {
+	retain := make(map[string]bool)
+
+	names := cfg.RetainOnDestroy
+	if names == nil {
+		names = []string{cfg.LogBucketName, cfg.AccessLogBucketName, cfg.FlowLogBucketName}
+	}
+
+	for _, name := range append(names, extra...) {
+		if name != "" {
+			retain[name] = true
+		}
+	}
+	return retain
+}
+
+// discover walks the organization hierarchy once so every step works on the same snapshot
+func (d *Decommissioner) discover(ctx context.Context) error {
+	roots, err := d.orgClient.ListRoots(ctx, &organizations.ListRootsInput{})
+	if err != nil {
+		return fmt.Errorf("failed to list roots: %w", err)
+	}
+	if len(roots.Roots) == 0 {
+		return fmt.Errorf("organization has no root")
+	}
+
+	d.rootID = aws.ToString(roots.Roots[0].Id)
+	d.ous = nil
+	if err := d.walkOUs(ctx, d.rootID, 0); err != nil {
+		return err
+	}
+	d.markRetainedOUs()
+	return nil
+}
+
+// markRetainedOUs marks the OUs kept whole, retained by name or nested in a retained
+// OU, and their ancestors. Parents are discovered before their children.
+func (d *Decommissioner) markRetainedOUs() {
+	parents := make(map[string]string, len(d.ous))
+	d.retainedOUs = make(map[string]bool)
+	d.ancestors = make(map[string]bool)
+	for _, ou := range d.ous {
+		parents[ou.id] = ou.parent
+		if d.retain[ou.name] || d.retainedOUs[ou.parent] {
+			d.retainedOUs[ou.id] = true
+		}
+	}
+	for id := range d.retainedOUs {
+		for parent := parents[id]; parent != "" && parent != d.rootID; parent = parents[parent] {
+			if !d.retainedOUs[parent] {
+				d.ancestors[parent] = true
+			}
+		}
+	}
+}
+
+// retainedAccounts returns the IDs of the accounts placed in retained OUs
+func (d *Decommissioner) retainedAccounts(ctx context.Context) (map[string]bool, error) {
+	accounts := make(map[string]bool)
+	for _, ou := range d.ous {
+		if !d.retainedOUs[ou.id] {
+			continue
+		}
+		paginator := organizations.NewListAccountsForParentPaginator(d.orgClient,
+			&organizations.ListAccountsForParentInput{ParentId: aws.String(ou.id)})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list accounts for OU %s: %w", ou.name, err)
+			}
+			for _, account := range page.Accounts {
+				accounts[aws.ToString(account.Id)] = true
+			}
+		}
+	}
+	return accounts, nil
+}
+
+// walkOUs recursively collects the organizational units below parentID
+func (d *Decommissioner) walkOUs(ctx context.Context, parentID string, depth int) error {
+	paginator := organizations.NewListOrganizationalUnitsForParentPaginator(d.orgClient,
+		&organizations.ListOrganizationalUnitsForParentInput{ParentId: aws.String(parentID)})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list OUs for parent %s: %w", parentID, err)
+		}
+
+		for _, ou := range page.OrganizationalUnits {
+			d.ous = append(d.ous, ouNode{
+				id:     aws.ToString(ou.Id),
+				arn:    aws.ToString(ou.Arn),
+				name:   aws.ToString(ou.Name),
+				parent: parentID,
+				depth:  depth,
+			})
+			if err := d.walkOUs(ctx, aws.ToString(ou.Id), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// detachPolicies detaches customer managed SCPs from the root, every OU and every
+// account. Retained OUs and their accounts keep their SCPs.
+func (d *Decommissioner) detachPolicies(ctx context.Context, _ *config.LandingZoneConfig) error {
+	targets := []string{d.rootID}
+	for _, ou := range d.ous {
+		if d.retainedOUs[ou.id] {
+			d.logger.Info("retaining policies of OU", zap.String("ou", ou.name))
+			continue
+		}
+		targets = append(targets, ou.id)
+	}
+
+	retained, err := d.retainedAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	accounts := organizations.NewListAccountsPaginator(d.orgClient, &organizations.ListAccountsInput{})
+	for accounts.HasMorePages() {
+		page, err := accounts.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list accounts: %w", err)
+		}
+		for _, account := range page.Accounts {
+			if !retained[aws.ToString(account.Id)] {
+				targets = append(targets, aws.ToString(account.Id))
+			}
+		}
+	}
+
+	for _, target := range targets {
+		paginator := organizations.NewListPoliciesForTargetPaginator(d.orgClient, &organizations.ListPoliciesForTargetInput{
+			TargetId: aws.String(target),
+			Filter:   orgtypes.PolicyTypeServiceControlPolicy,
+		})
+
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list policies for target %s: %w", target, err)
+			}
+
+			for _, policy := range page.Policies {
+				// AWS managed policies such as FullAWSAccess must stay attached
+				if policy.AwsManaged || d.retain[aws.ToString(policy.Name)] {
+					continue
+				}
+
+				if _, err := d.orgClient.DetachPolicy(ctx, &organizations.DetachPolicyInput{
+					PolicyId: policy.Id,
+					TargetId: aws.String(target),
+				}); err != nil {
+					return fmt.Errorf("failed to detach policy %s from %s: %w", aws.ToString(policy.Name), target, err)
+				}
+
+				d.logger.Info("detached policy",
+					zap.String("policy", aws.ToString(policy.Name)),
+					zap.String("target", target))
+				d.metrics.IncrementCounter("policies_detached")
+			}
+		}
+	}
+
+	return nil
+}
+
+// disableControls disables every Control Tower control enabled on the discovered OUs.
+// Retained OUs keep their controls.
+func (d *Decommissioner) disableControls(ctx context.Context, _ *config.LandingZoneConfig) error {
+	for _, ou := range d.ous {
+		if d.retainedOUs[ou.id] {
+			d.logger.Info("retaining controls of OU", zap.String("ou", ou.name))
+			continue
+		}
+
+		paginator := ct.NewListEnabledControlsPaginator(d.ctClient, &ct.ListEnabledControlsInput{
+			TargetIdentifier: aws.String(ou.arn),
+		})
+
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list enabled controls for OU %s: %w", ou.name, err)
+			}
+
+			for _, control := range page.EnabledControls {
+				out, err := d.ctClient.DisableControl(ctx, &ct.DisableControlInput{
+					ControlIdentifier: control.ControlIdentifier,
+					TargetIdentifier:  aws.String(ou.arn),
+				})
+				if err != nil {
+					return fmt.Errorf("failed to disable control %s on OU %s: %w",
+						aws.ToString(control.ControlIdentifier), ou.name, err)
+				}
+
+				if err := d.waitForControlOperation(ctx, aws.ToString(out.OperationIdentifier)); err != nil {
+					return err
+				}
+
+				d.logger.Info("disabled control",
+					zap.String("control", aws.ToString(control.ControlIdentifier)),
+					zap.String("ou", ou.name))
+				d.metrics.IncrementCounter("controls_disabled")
+			}
+		}
+	}
+
+	return nil
+}
+
+// waitForControlOperation polls an asynchronous Control Tower operation until it finishes
+func (d *Decommissioner) waitForControlOperation(ctx context.Context, operationID string) error {
+	ctx, cancel := context.WithTimeout(ctx, controlOperationTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(controlOperationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		out, err := d.ctClient.GetControlOperation(ctx, &ct.GetControlOperationInput{
+			OperationIdentifier: aws.String(operationID),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get control operation %s: %w", operationID, err)
+		}
+
+		switch out.ControlOperation.Status {
+		case cttypes.ControlOperationStatusSucceeded:
+			return nil
+		case cttypes.ControlOperationStatusFailed:
+			return fmt.Errorf("control operation %s failed: %s",
+				operationID, aws.ToString(out.ControlOperation.StatusMessage))
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for control operation %s: %w", operationID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// moveAccounts moves every account out of the discovered OUs and back to the root.
+// Accounts of retained OUs stay where they are.
+func (d *Decommissioner) moveAccounts(ctx context.Context, _ *config.LandingZoneConfig) error {
+	for _, ou := range d.ous {
+		if d.retainedOUs[ou.id] {
+			d.logger.Info("retaining accounts of OU", zap.String("ou", ou.name))
+			continue
+		}
+
+		paginator := organizations.NewListAccountsForParentPaginator(d.orgClient,
+			&organizations.ListAccountsForParentInput{ParentId: aws.String(ou.id)})
+
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list accounts for OU %s: %w", ou.name, err)
+			}
+
+			for _, account := range page.Accounts {
+				if _, err := d.orgClient.MoveAccount(ctx, &organizations.MoveAccountInput{
+					AccountId:           account.Id,
+					SourceParentId:      aws.String(ou.id),
+					DestinationParentId: aws.String(d.rootID),
+				}); err != nil {
+					return fmt.Errorf("failed to move account %s out of OU %s: %w",
+						aws.ToString(account.Id), ou.name, err)
+				}
+
+				d.logger.Info("moved account to root",
+					zap.String("accountId", aws.ToString(account.Id)),
+					zap.String("sourceOU", ou.name))
+				d.metrics.IncrementCounter("accounts_moved")
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteOUs deletes the discovered OUs, deepest first so parents are empty when deleted.
+// Retained OUs and the OUs holding them are kept.
+func (d *Decommissioner) deleteOUs(ctx context.Context, _ *config.LandingZoneConfig) error {
+	ous := make([]ouNode, len(d.ous))
+	copy(ous, d.ous)
+	sort.SliceStable(ous, func(i, j int) bool {
+		return ous[i].depth > ous[j].depth
+	})
+
+	for _, ou := range ous {
+		if d.retainedOUs[ou.id] {
+			d.logger.Info("retaining OU", zap.String("ou", ou.name))
+			continue
+		}
+		if d.ancestors[ou.id] {
+			d.logger.Info("keeping OU holding a retained OU", zap.String("ou", ou.name))
+			continue
+		}
+
+		if _, err := d.orgClient.DeleteOrganizationalUnit(ctx, &organizations.DeleteOrganizationalUnitInput{
+			OrganizationalUnitId: aws.String(ou.id),
+		}); err != nil {
+			return fmt.Errorf("failed to delete OU %s: %w", ou.name, err)
+		}
+
+		d.logger.Info("deleted OU", zap.String("ou", ou.name))
+		d.metrics.IncrementCounter("ous_deleted")
+	}
+
+	return nil
+}
+
+// deleteSharedInfra removes the SSM parameters, IAM roles and buckets the tool created
+func (d *Decommissioner) deleteSharedInfra(ctx context.Context, cfg *config.LandingZoneConfig) error {
+	if err := d.deleteParameters(ctx, cfg); err != nil {
+		return err
+	}
+
+	for _, role := range []string{
+		controltower.RoleNameControlTowerAdmin,
+		controltower.RoleNameCloudTrail,
+		controltower.RoleNameStackSet,
+	} {
+		if d.retain[role] {
+			continue
+		}
+		if err := d.deleteRole(ctx, role); err != nil {
+			return err
+		}
+	}
+
+	for _, bucket := range []string{cfg.LogBucketName, cfg.AccessLogBucketName, cfg.FlowLogBucketName} {
+		if bucket == "" {
+			continue
+		}
+		if d.retain[bucket] {
+			d.logger.Info("retaining bucket", zap.String("bucket", bucket))
+			continue
+		}
+		if err := d.deleteBucket(ctx, bucket); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteParameters deletes the SSM parameter tree written by the tool, under the
+// parameter namespace
+func (d *Decommissioner) deleteParameters(ctx context.Context, cfg *config.LandingZoneConfig) error {
+	var names []string
+
+	paginator := ssm.NewGetParametersByPathPaginator(d.ssmClient, &ssm.GetParametersByPathInput{
+		Path:      aws.String(cfg.ParameterNames().Namespace()),
+		Recursive: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list SSM parameters: %w", err)
+		}
+		for _, param := range page.Parameters {
+			if !d.retain[aws.ToString(param.Name)] {
+				names = append(names, aws.ToString(param.Name))
+			}
+		}
+	}
+
+	for i := 0; i < len(names); i += ssmDeleteBatchSize {
+		end := i + ssmDeleteBatchSize
+		if end > len(names) {
+			end = len(names)
+		}
+
+		if _, err := d.ssmClient.DeleteParameters(ctx, &ssm.DeleteParametersInput{
+			Names: names[i:end],
+		}); err != nil {
+			return fmt.Errorf("failed to delete SSM parameters: %w", err)
+		}
+	}
+
+	d.logger.Info("deleted SSM parameters", zap.Int("count", len(names)))
+	return nil
+}
+
+// deleteRole detaches all policies from an IAM role and deletes it, ignoring missing roles
+func (d *Decommissioner) deleteRole(ctx context.Context, name string) error {
+	var notFound *iamtypes.NoSuchEntityException
+
+	attached, err := d.iamClient.ListAttachedRolePolicies(ctx, &iam.ListAttachedRolePoliciesInput{
+		RoleName: aws.String(name),
+	})
+	if errors.As(err, &notFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list policies for role %s: %w", name, err)
+	}
+
+	for _, policy := range attached.AttachedPolicies {
+		if _, err := d.iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
+			RoleName:  aws.String(name),
+			PolicyArn: policy.PolicyArn,
+		}); err != nil {
+			return fmt.Errorf("failed to detach policy from role %s: %w", name, err)
+		}
+	}
+
+	inline, err := d.iamClient.ListRolePolicies(ctx, &iam.ListRolePoliciesInput{
+		RoleName: aws.String(name),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list inline policies for role %s: %w", name, err)
+	}
+
+	for _, policy := range inline.PolicyNames {
+		if _, err := d.iamClient.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{
+			RoleName:   aws.String(name),
+			PolicyName: aws.String(policy),
+		}); err != nil {
+			return fmt.Errorf("failed to delete inline policy from role %s: %w", name, err)
+		}
+	}
+
+	if _, err := d.iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{
+		RoleName: aws.String(name),
+	}); err != nil {
+		return fmt.Errorf("failed to delete role %s: %w", name, err)
+	}
+
+	d.logger.Info("deleted role", zap.String("role", name))
+	return nil
+}
+
+// deleteBucket empties a versioned bucket and deletes it
+func (d *Decommissioner) deleteBucket(ctx context.Context, bucket string) error {
+	paginator := s3.NewListObjectVersionsPaginator(d.s3Client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list objects in bucket %s: %w", bucket, err)
+		}
+
+		objects := make([]s3types.ObjectIdentifier, 0, len(page.Versions)+len(page.DeleteMarkers))
+		for _, version := range page.Versions {
+			objects = append(objects, s3types.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+		}
+		for _, marker := range page.DeleteMarkers {
+			objects = append(objects, s3types.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+		}
+
+		for i := 0; i < len(objects); i += s3DeleteBatchSize {
+			end := i + s3DeleteBatchSize
+			if end > len(objects) {
+				end = len(objects)
+			}
+
+			out, err := d.s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+				Bucket: aws.String(bucket),
+				Delete: &s3types.Delete{Objects: objects[i:end], Quiet: aws.Bool(true)},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to empty bucket %s: %w", bucket, err)
+			}
+			// Objects that could not be deleted are reported in the response only
+			if len(out.Errors) > 0 {
+				first := out.Errors[0]
+				return fmt.Errorf("failed to delete %d objects of bucket %s, %s: %s %s",
+					len(out.Errors), bucket, aws.ToString(first.Key), aws.ToString(first.Code), aws.ToString(first.Message))
+			}
+		}
+	}
+
+	if _, err := d.s3Client.DeleteBucket(ctx, &s3.DeleteBucketInput{
+		Bucket: aws.String(bucket),
+	}); err != nil {
+		return fmt.Errorf("failed to delete bucket %s: %w", bucket, err)
+	}
+
+	d.logger.Info("deleted bucket", zap.String("bucket", bucket))
+	return nil
+}
+
+// sanitizeMetricName converts a step name into a valid Prometheus metric name fragment
+func sanitizeMetricName(step Step) string {
+	name := []byte(step)
+	for i, c := range name {
+		if c == '-' {
+			name[i] = '_'
+		}
+	}
+	return string(name)
+}
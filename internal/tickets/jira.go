@@ -0,0 +1,115 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tickets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+)
+
+const (
+	// REST API path of the issues, version 2 accepting plain text
+	jiraIssuePath = "/rest/api/2/issue"
+
+	// Defaults of the issues
+	defaultJiraIssueType      = "Task"
+	defaultJiraDoneTransition = "Done"
+)
+
+// jira tracks applies as issues of a Jira project
+type jira struct {
+	client         *client
+	project        string
+	issueType      string
+	doneTransition string
+}
+
+// newJira returns the Jira project of a configuration
+func newJira(client *client, ticket config.ChangeTicketConfig) *jira {
+	j := &jira{
+		client:         client,
+		project:        ticket.Project,
+		issueType:      ticket.IssueType,
+		doneTransition: ticket.DoneTransition,
+	}
+	if j.issueType == "" {
+		j.issueType = defaultJiraIssueType
+	}
+	if j.doneTransition == "" {
+		j.doneTransition = defaultJiraDoneTransition
+	}
+	return j
+}
+
+// Name returns the ticket system
+func (j *jira) Name() string {
+	return config.TicketSystemJira
+}
+
+// Open creates an issue and returns its key
+func (j *jira) Open(ctx context.Context, title, body string) (string, error) {
+	payload := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": j.project},
+			"issuetype":   map[string]string{"name": j.issueType},
+			"summary":     title,
+			"description": body,
+		},
+	}
+
+	var issue struct {
+		Key string `json:"key"`
+	}
+	if err := j.client.doJSON(ctx, http.MethodPost, jiraIssuePath, payload, &issue); err != nil {
+		return "", err
+	}
+	return issue.Key, nil
+}
+
+// Update adds a comment to an issue
+func (j *jira) Update(ctx context.Context, id, body string) error {
+	return j.client.doJSON(ctx, http.MethodPost, jiraIssuePath+"/"+id+"/comment",
+		map[string]string{"body": body}, nil)
+}
+
+// Approve returns at once, as Jira issues have no approval
+func (j *jira) Approve(ctx context.Context, id string) error {
+	return nil
+}
+
+// Close comments the result on an issue and moves it through the done transition.
+// Jira has no unsuccessful resolution by default, so failures are only told apart by
+// the comment.
+func (j *jira) Close(ctx context.Context, id string, success bool, body string) error {
+	if err := j.Update(ctx, id, body); err != nil {
+		return err
+	}
+
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	path := jiraIssuePath + "/" + id + "/transitions"
+	if err := j.client.doJSON(ctx, http.MethodGet, path, nil, &available); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(available.Transitions))
+	for _, transition := range available.Transitions {
+		if strings.EqualFold(transition.Name, j.doneTransition) {
+			return j.client.doJSON(ctx, http.MethodPost, path, map[string]interface{}{
+				"transition": map[string]string{"id": transition.ID},
+			}, nil)
+		}
+		names = append(names, transition.Name)
+	}
+	return fmt.Errorf("issue %s has no %q transition, available: %s", id, j.doneTransition, strings.Join(names, ", "))
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package tickets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+)
+
+const (
+	// Table API path of the change requests
+	serviceNowChangePath = "/api/now/table/change_request"
+
+	// States of the model of normal change requests
+	serviceNowStateNew       = "-5"
+	serviceNowStateAssess    = "-4"
+	serviceNowStateScheduled = "-2"
+	serviceNowStateImplement = "-1"
+	serviceNowStateReview    = "0"
+	serviceNowStateClosed    = "3"
+	serviceNowStateCanceled  = "4"
+
+	// Approval of a rejected change request
+	serviceNowApprovalRejected = "rejected"
+
+	// Close codes of a closed change request
+	serviceNowSuccessful   = "successful"
+	serviceNowUnsuccessful = "unsuccessful"
+
+	// Type of the change requests opened for applies
+	serviceNowChangeTypeNormal = "normal"
+
+	// Interval between the reads of a change request waiting for approval
+	approvalPollInterval = 30 * time.Second
+)
+
+// serviceNow tracks applies as change requests of a ServiceNow instance
+type serviceNow struct {
+	client          *client
+	assignmentGroup string
+	approvalTimeout time.Duration
+}
+
+// serviceNowRecord is the part of a change request the Table API returns
+type serviceNowRecord struct {
+	Result struct {
+		SysID    string `json:"sys_id"`
+		Number   string `json:"number"`
+		State    string `json:"state"`
+		Approval string `json:"approval"`
+	} `json:"result"`
+}
+
+// Name returns the ticket system
+func (s *serviceNow) Name() string {
+	return config.TicketSystemServiceNow
+}
+
+// Open creates a change request and returns its sys_id
+func (s *serviceNow) Open(ctx context.Context, title, body string) (string, error) {
+	payload := map[string]string{
+		"short_description": title,
+		"description":       body,
+		"type":              serviceNowChangeTypeNormal,
+	}
+	if s.assignmentGroup != "" {
+		payload["assignment_group"] = s.assignmentGroup
+	}
+
+	var record serviceNowRecord
+	if err := s.client.doJSON(ctx, http.MethodPost, serviceNowChangePath, payload, &record); err != nil {
+		return "", err
+	}
+	return record.Result.SysID, nil
+}
+
+// Update adds a work note to a change request
+func (s *serviceNow) Update(ctx context.Context, id, body string) error {
+	return s.patch(ctx, id, map[string]string{"work_notes": body})
+}
+
+// Approve requests the approval of a new change request and waits for it to be
+// scheduled, then moves it to implementation. A change request already in
+// implementation, as left by an interrupted apply, is approved.
+func (s *serviceNow) Approve(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.approvalTimeout)
+	defer cancel()
+
+	for {
+		record, err := s.get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if record.Result.Approval == serviceNowApprovalRejected {
+			return fmt.Errorf("change request %s was rejected", record.Result.Number)
+		}
+
+		switch record.Result.State {
+		case serviceNowStateNew:
+			if err := s.patch(ctx, id, map[string]string{"state": serviceNowStateAssess}); err != nil {
+				return fmt.Errorf("failed to request approval: %w", err)
+			}
+		case serviceNowStateScheduled:
+			return s.patch(ctx, id, map[string]string{"state": serviceNowStateImplement})
+		case serviceNowStateImplement, serviceNowStateReview:
+			return nil
+		case serviceNowStateClosed, serviceNowStateCanceled:
+			return fmt.Errorf("change request %s is closed", record.Result.Number)
+		}
+
+		if err := sleep(ctx, approvalPollInterval); err != nil {
+			return fmt.Errorf("change request %s not approved within %s: %w",
+				record.Result.Number, s.approvalTimeout, err)
+		}
+	}
+}
+
+// Close moves a change request through review to closed, with its close code and
+// notes. A change request that was never approved is canceled instead.
+func (s *serviceNow) Close(ctx context.Context, id string, success bool, body string) error {
+	record, err := s.get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// The state model only moves a change request one state forward at a time
+	transitions := []string{serviceNowStateImplement, serviceNowStateReview}
+	switch record.Result.State {
+	case serviceNowStateClosed, serviceNowStateCanceled:
+		return nil
+	case serviceNowStateScheduled:
+	case serviceNowStateImplement:
+		transitions = transitions[1:]
+	case serviceNowStateReview:
+		transitions = nil
+	default:
+		return s.patch(ctx, id, map[string]string{
+			"state":      serviceNowStateCanceled,
+			"work_notes": body,
+		})
+	}
+
+	for _, state := range transitions {
+		if err := s.patch(ctx, id, map[string]string{"state": state}); err != nil {
+			return fmt.Errorf("failed to move change request %s to state %s: %w", record.Result.Number, state, err)
+		}
+	}
+
+	code := serviceNowSuccessful
+	if !success {
+		code = serviceNowUnsuccessful
+	}
+	return s.patch(ctx, id, map[string]string{
+		"state":       serviceNowStateClosed,
+		"close_code":  code,
+		"close_notes": body,
+	})
+}
+
+// get reads the state and approval of a change request
+func (s *serviceNow) get(ctx context.Context, id string) (*serviceNowRecord, error) {
+	var record serviceNowRecord
+	path := serviceNowChangePath + "/" + id + "?sysparm_fields=sys_id,number,state,approval"
+	if err := s.client.doJSON(ctx, http.MethodGet, path, nil, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// patch updates the fields of a change request
+func (s *serviceNow) patch(ctx context.Context, id string, fields map[string]string) error {
+	return s.client.doJSON(ctx, http.MethodPatch, serviceNowChangePath+"/"+id, fields, nil)
+}
+
+// sleep waits for d or the end of the context
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package tickets provides the ServiceNow and Jira tickets tracking the applies of the
+// landing zone for change management.
+// Version: 1.0.0
+package tickets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/plan"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/runid"
+	"go.uber.org/zap"
+)
+
+const (
+	// RecordName is the name of the record of the open tickets in the state
+	RecordName = "change-tickets"
+
+	// Timeout of requests to the ticket systems
+	requestTimeout = 30 * time.Second
+
+	// Resource changes listed in a ticket
+	maxListedChanges = 100
+)
+
+// System opens, updates and closes the tickets of a ticket system
+type System interface {
+	// Name returns the ticket system
+	Name() string
+	// Open creates a ticket and returns its ID
+	Open(ctx context.Context, title, body string) (string, error)
+	// Update adds a note to an open ticket
+	Update(ctx context.Context, id, body string) error
+	// Approve waits for an open ticket to be approved and ready for the apply
+	Approve(ctx context.Context, id string) error
+	// Close closes a ticket as successful or not with the given notes
+	Close(ctx context.Context, id string, success bool, body string) error
+}
+
+// Store persists the tickets left open
+type Store interface {
+	SaveRecord(ctx context.Context, name string, record interface{}) error
+	LoadRecord(ctx context.Context, name string, record interface{}) (bool, error)
+}
+
+// Record holds the ID of the ticket of every stack and system still open, keyed by
+// stack and system
+type Record struct {
+	Open map[string]string `json:"open"`
+}
+
+// tracked is a ticket system tracking the applies of the stack
+type tracked struct {
+	system System
+	id     string
+}
+
+// Manager tracks the apply of a stack in the configured ticket systems
+type Manager struct {
+	logger  *zap.Logger
+	metrics *metrics.Collector
+	store   Store
+	stack   string
+	systems []*tracked
+	started time.Time
+}
+
+// NewManager creates a manager for the ticket systems tracking the given stack
+func NewManager(cfg *config.LandingZoneConfig, stack string, store Store) (*Manager, error) {
+	logger, err := logging.NewLogger("tickets")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("tickets")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	m := &Manager{logger: logger, metrics: metrics, store: store, stack: stack}
+	for _, ticket := range cfg.ChangeTicketsFor(stack) {
+		system, err := New(ticket)
+		if err != nil {
+			return nil, err
+		}
+		m.systems = append(m.systems, &tracked{system: system})
+	}
+	return m, nil
+}
+
+// New returns the ticket system of a configuration, with its credentials read from the
+// environment
+func New(ticket config.ChangeTicketConfig) (System, error) {
+	userEnv, secretEnv := ticket.Credentials()
+	user, secret := os.Getenv(userEnv), os.Getenv(secretEnv)
+	if user == "" || secret == "" {
+		return nil, fmt.Errorf("%s and %s are required to open %s tickets", userEnv, secretEnv, ticket.System)
+	}
+
+	client := &client{
+		http:   &http.Client{Timeout: requestTimeout},
+		base:   strings.TrimSuffix(ticket.URL, "/"),
+		user:   user,
+		secret: secret,
+	}
+	switch ticket.System {
+	case config.TicketSystemServiceNow:
+		return &serviceNow{
+			client:          client,
+			assignmentGroup: ticket.AssignmentGroup,
+			approvalTimeout: ticket.ApprovalTimeout(),
+		}, nil
+	case config.TicketSystemJira:
+		return newJira(client, ticket), nil
+	default:
+		return nil, fmt.Errorf("unsupported ticket system %q", ticket.System)
+	}
+}
+
+// Open opens a ticket in every system with the summary of the planned changes and waits
+// for the tickets to be approved. A ticket left open by an interrupted apply of the
+// stack is updated instead.
+func (m *Manager) Open(ctx context.Context, modules string, changes []plan.Change) error {
+	record, err := m.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	title := fmt.Sprintf("Landing zone change: apply of stack %s", m.stack)
+	body := Summary(m.stack, modules, changes)
+	for _, t := range m.systems {
+		key := m.key(t.system)
+		if id, ok := record.Open[key]; ok {
+			if err := t.system.Update(ctx, id, "Apply restarted.\n\n"+body); err != nil {
+				return fmt.Errorf("failed to update %s ticket %s: %w", t.system.Name(), id, err)
+			}
+			t.id = id
+			m.logger.Info("change ticket updated", zap.String("system", t.system.Name()), zap.String("ticket", id))
+			continue
+		}
+
+		id, err := t.system.Open(ctx, title, body)
+		if err != nil {
+			return fmt.Errorf("failed to open %s ticket: %w", t.system.Name(), err)
+		}
+		t.id = id
+		record.Open[key] = id
+		m.logger.Info("change ticket opened", zap.String("system", t.system.Name()), zap.String("ticket", id))
+		m.metrics.IncrementCounter("tickets_opened")
+	}
+
+	// The tickets are recorded before the approval, so an apply interrupted while
+	// waiting does not open them again
+	if err := m.store.SaveRecord(ctx, RecordName, record); err != nil {
+		return err
+	}
+
+	for _, t := range m.systems {
+		m.logger.Info("waiting for change ticket approval", zap.String("system", t.system.Name()), zap.String("ticket", t.id))
+		if err := t.system.Approve(ctx, t.id); err != nil {
+			return fmt.Errorf("%s ticket %s was not approved: %w", t.system.Name(), t.id, err)
+		}
+	}
+
+	m.started = time.Now()
+	return nil
+}
+
+// Close closes the tickets with the result of the apply
+func (m *Manager) Close(ctx context.Context, applyErr error) error {
+	record, err := m.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Apply of stack %s succeeded in %s (run %s).", m.stack, time.Since(m.started).Round(time.Second), runid.ID())
+	if applyErr != nil {
+		body = fmt.Sprintf("Apply of stack %s failed after %s (run %s):\n\n%s", m.stack, time.Since(m.started).Round(time.Second), runid.ID(), applyErr)
+	}
+
+	var failed []string
+	for _, t := range m.systems {
+		if t.id == "" {
+			continue
+		}
+		if err := t.system.Close(ctx, t.id, applyErr == nil, body); err != nil {
+			m.logger.Error("failed to close change ticket",
+				zap.String("system", t.system.Name()), zap.String("ticket", t.id), zap.Error(err))
+			failed = append(failed, t.system.Name()+" "+t.id)
+			continue
+		}
+		delete(record.Open, m.key(t.system))
+		m.logger.Info("change ticket closed", zap.String("system", t.system.Name()), zap.String("ticket", t.id),
+			zap.Bool("success", applyErr == nil))
+		m.metrics.IncrementCounter("tickets_closed")
+	}
+
+	if err := m.store.SaveRecord(ctx, RecordName, record); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to close change tickets: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// load returns the record of the open tickets
+func (m *Manager) load(ctx context.Context) (*Record, error) {
+	record := &Record{}
+	if _, err := m.store.LoadRecord(ctx, RecordName, record); err != nil {
+		return nil, err
+	}
+	if record.Open == nil {
+		record.Open = make(map[string]string)
+	}
+	return record, nil
+}
+
+// key returns the key of the ticket of a system in the record
+func (m *Manager) key(system System) string {
+	return m.stack + "/" + system.Name()
+}
+
+// Summary renders the plan of an apply as the plain text body of a ticket
+func Summary(stack, modules string, changes []plan.Change) string {
+	doc := plan.New("deploy")
+	doc.AddChanges(changes...)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Stack: %s\n", stack)
+	fmt.Fprintf(&b, "Modules: %s\n", modules)
+	fmt.Fprintf(&b, "Run: %s\n", runid.ID())
+
+	if len(changes) == 0 {
+		b.WriteString("Planned changes: none\n")
+		return b.String()
+	}
+
+	operations := make([]string, 0, len(doc.Summary))
+	for operation := range doc.Summary {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	counts := make([]string, 0, len(operations))
+	for _, operation := range operations {
+		counts = append(counts, fmt.Sprintf("%s: %d", operation, doc.Summary[operation]))
+	}
+	fmt.Fprintf(&b, "Planned changes: %s\n\n", strings.Join(counts, ", "))
+
+	for i, change := range doc.Changes {
+		if i == maxListedChanges {
+			fmt.Fprintf(&b, "... and %d more\n", len(doc.Changes)-maxListedChanges)
+			break
+		}
+		fmt.Fprintf(&b, "%s %s %s", change.Operation, change.Type, resourceName(change.URN))
+		if len(change.Diffs) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(change.Diffs, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// resourceName returns the name at the end of a URN
+func resourceName(urn string) string {
+	if i := strings.LastIndex(urn, "::"); i >= 0 {
+		return urn[i+2:]
+	}
+	return urn
+}
+
+// client calls the REST API of a ticket system with basic authentication
+type client struct {
+	http   *http.Client
+	base   string
+	user   string
+	secret string
+}
+
+// doJSON sends a JSON request to the API and decodes the response into out
+func (c *client) doJSON(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	url := c.base + path
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(c.user, c.secret)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s %s: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", method, url, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response of %s %s: %w", method, url, err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package transform registers the customer transformations of the resources of the landing zone.
+// Version: 1.0.0
+package transform
+
+import (
+	"fmt"
+	"plugin"
+	"reflect"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+const (
+	// PluginSymbol is the function a transformation plugin exports
+	PluginSymbol = "Transformations"
+
+	// nameField is the field of the resource arguments holding the physical name
+	nameField = "Name"
+)
+
+// PluginFunc is the type of the function a transformation plugin exports
+type PluginFunc = func() []pulumi.ResourceTransformation
+
+var (
+	stringInput    = reflect.TypeOf((*pulumi.StringInput)(nil)).Elem()
+	stringPtrInput = reflect.TypeOf((*pulumi.StringPtrInput)(nil)).Elem()
+)
+
+// Register adds the transformations of the configured rules, then those of the
+// plugins, as stack transformations. It must be called before the modules create
+// their resources.
+func Register(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("transform")
+	if err != nil {
+		return err
+	}
+
+	t := cfg.Transformations
+	if t == nil {
+		logger.Info("no resource transformations configured")
+		return nil
+	}
+
+	transformations := make([]pulumi.ResourceTransformation, 0, len(t.Rules))
+	for i := range t.Rules {
+		transformations = append(transformations, Rule(&t.Rules[i]))
+	}
+	for _, path := range t.Plugins {
+		loaded, err := Load(path)
+		if err != nil {
+			return err
+		}
+		logger.Info("loaded transformation plugin",
+			zap.String("plugin", path),
+			zap.Int("transformations", len(loaded)))
+		transformations = append(transformations, loaded...)
+	}
+
+	for _, transformation := range transformations {
+		if err := ctx.RegisterStackTransformation(transformation); err != nil {
+			return fmt.Errorf("failed to register resource transformation: %w", err)
+		}
+	}
+
+	logger.Info("registered resource transformations",
+		zap.Int("rules", len(t.Rules)),
+		zap.Int("plugins", len(t.Plugins)))
+	return nil
+}
+
+// Load opens a transformation plugin and returns its transformations
+func Load(path string) ([]pulumi.ResourceTransformation, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transformation plugin %s: %w", path, err)
+	}
+
+	symbol, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("transformation plugin %s does not export %s: %w", path, PluginSymbol, err)
+	}
+	fn, ok := symbol.(PluginFunc)
+	if !ok {
+		return nil, fmt.Errorf("%s of transformation plugin %s must be a func() []pulumi.ResourceTransformation, got %T",
+			PluginSymbol, path, symbol)
+	}
+	return fn(), nil
+}
+
+// Rule returns the transformation applying a rule to the resources it matches
+func Rule(rule *config.TransformationRule) pulumi.ResourceTransformation {
+	return func(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+		if !rule.Matches(args.Type, args.Name) {
+			return nil
+		}
+
+		opts := append([]pulumi.ResourceOption{}, args.Opts...)
+		if rule.Protect {
+			opts = append(opts, pulumi.Protect(true))
+		}
+		if rule.RetainOnDelete {
+			opts = append(opts, pulumi.RetainOnDelete(true))
+		}
+		if len(rule.IgnoreChanges) > 0 {
+			opts = append(opts, pulumi.IgnoreChanges(rule.IgnoreChanges))
+		}
+		if rule.NamePrefix != "" {
+			prefixName(args.Props, rule.NamePrefix)
+		}
+
+		return &pulumi.ResourceTransformationResult{Props: args.Props, Opts: opts}
+	}
+}
+
+// prefixName prefixes the physical name in the arguments of a resource, when its
+// module gives it one
+func prefixName(props pulumi.Input, prefix string) {
+	if props == nil {
+		return
+	}
+	value := reflect.ValueOf(props)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return
+	}
+	field := value.Elem().FieldByName(nameField)
+	if !field.IsValid() || !field.CanSet() || field.IsNil() {
+		return
+	}
+
+	switch field.Type() {
+	case stringInput:
+		name := field.Interface().(pulumi.StringInput)
+		field.Set(reflect.ValueOf(pulumi.StringInput(pulumi.Sprintf("%s%s", prefix, name))))
+	case stringPtrInput:
+		name := field.Interface().(pulumi.StringPtrInput).ToStringPtrOutput().Elem()
+		field.Set(reflect.ValueOf(pulumi.StringPtrInput(pulumi.Sprintf("%s%s", prefix, name).ToStringPtrOutput())))
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package vending provides the Step Functions orchestration of account vending, with
+// this tool acting as the worker of its steps.
+// Version: 1.0.0
+package vending
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/component"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/sfn"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"go.uber.org/zap"
+)
+
+// Step is a step of the vending of an account, performed by the worker
+type Step string
+
+const (
+	// Vending steps
+	StepCreate   Step = "create"
+	StepEnroll   Step = "enroll"
+	StepSettings Step = "settings"
+	StepVerify   Step = "verify"
+
+	// OutputStateMachineArn is the stack output holding the ARN of the state machine
+	OutputStateMachineArn = "vendingStateMachineArn"
+
+	// Error reported by the state machine when a step fails
+	vendingFailedError = "AccountVendingFailed"
+
+	// Retries of a step whose worker stopped sending heartbeats
+	heartbeatRetries  = 2
+	heartbeatInterval = 30
+)
+
+// Steps lists the vending steps in execution order
+var Steps = []Step{StepCreate, StepEnroll, StepSettings, StepVerify}
+
+// ActivityName returns the name of the activity of a step
+func ActivityName(cfg *config.VendingConfig, step Step) string {
+	return fmt.Sprintf("%s-%s", cfg.Name(), step)
+}
+
+// SetupVending creates the activities of the vending steps and the state machine
+// running them in order for each account. The worker of this tool performs the
+// activities, so the state machine needs no permissions of its own.
+func SetupVending(ctx *pulumi.Context, cfg *config.LandingZoneConfig) error {
+	logger, err := logging.NewLogger("vending")
+	if err != nil {
+		return err
+	}
+
+	metrics, err := metrics.NewCollector("vending")
+	if err != nil {
+		return fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.RecordDuration("vending_setup", time.Since(start))
+	}()
+
+	if err := readonly.Guard(ctx, "setup account vending"); err != nil {
+		return err
+	}
+
+	v := cfg.Vending
+	if v == nil {
+		logger.Info("no account vending state machine configured")
+		return nil
+	}
+
+	var activityArns []interface{}
+	for _, step := range Steps {
+		activity, err := sfn.NewActivity(ctx, fmt.Sprintf("vending-%s", step), &sfn.ActivityArgs{
+			Name: pulumi.String(ActivityName(v, step)),
+			Tags: pulumi.ToStringMap(cfg.Tags),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create vending activity %s: %w", step, err)
+		}
+		activityArns = append(activityArns, activity.ID().ToStringOutput())
+	}
+
+	role, err := iam.NewRole(ctx, "vending-state-machine", &iam.RoleArgs{
+		Name:        component.Name(cfg.ResourceNames().Name(config.RoleVendingStateMachine, "")),
+		Description: pulumi.String("Runs the account vending state machine"),
+		AssumeRolePolicy: pulumi.String(fmt.Sprintf(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Allow",
+				"Principal": {
+					"Service": "%s"
+				},
+				"Action": "sts:AssumeRole"
+			}]
+		}`, awsclient.ServicePrincipal("states"))),
+		Tags: pulumi.ToStringMap(cfg.Tags),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create vending state machine role: %w", err)
+	}
+
+	definition := pulumi.All(activityArns...).ApplyT(func(arns []interface{}) (string, error) {
+		return Definition(v, arns)
+	}).(pulumi.StringOutput)
+
+	machine, err := sfn.NewStateMachine(ctx, "vending-state-machine", &sfn.StateMachineArgs{
+		Name:       pulumi.String(v.Name()),
+		RoleArn:    role.Arn,
+		Definition: definition,
+		Tags:       pulumi.ToStringMap(cfg.Tags),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create vending state machine: %w", err)
+	}
+
+	ctx.Export(OutputStateMachineArn, machine.Arn)
+	logger.Info("account vending state machine created", zap.String("name", v.Name()))
+	return nil
+}
+
+// Definition returns the Amazon States Language definition running the activities of
+// the steps, given in the order of Steps. A step whose worker stops sending heartbeats
+// is retried, so another worker picks it up; any other failure fails the execution.
+func Definition(v *config.VendingConfig, activityArns []interface{}) (string, error) {
+	states := map[string]interface{}{
+		"Failed": map[string]interface{}{
+			"Type":      "Fail",
+			"Error":     vendingFailedError,
+			"CausePath": "$.error.Cause",
+		},
+	}
+	for i, step := range Steps {
+		state := map[string]interface{}{
+			"Type":             "Task",
+			"Resource":         activityArns[i],
+			"HeartbeatSeconds": v.Heartbeat(),
+			"TimeoutSeconds":   v.Timeout(),
+			"Retry": []map[string]interface{}{{
+				"ErrorEquals":     []string{"States.HeartbeatTimeout"},
+				"IntervalSeconds": heartbeatInterval,
+				"MaxAttempts":     heartbeatRetries,
+			}},
+			"Catch": []map[string]interface{}{{
+				"ErrorEquals": []string{"States.ALL"},
+				"ResultPath":  "$.error",
+				"Next":        "Failed",
+			}},
+		}
+		if i == len(Steps)-1 {
+			state["End"] = true
+		} else {
+			state["Next"] = stateName(Steps[i+1])
+		}
+		states[stateName(step)] = state
+	}
+
+	definition, err := json.Marshal(map[string]interface{}{
+		"Comment": "Creates, enrolls, baselines and verifies a landing zone account",
+		"StartAt": stateName(Steps[0]),
+		"States":  states,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal vending state machine definition: %w", err)
+	}
+	return string(definition), nil
+}
+
+// stateName returns the name of the state of a step
+func stateName(step Step) string {
+	switch step {
+	case StepCreate:
+		return "CreateAccount"
+	case StepEnroll:
+		return "EnrollAccount"
+	case StepSettings:
+		return "ApplyAccountSettings"
+	default:
+		return "VerifyBaseline"
+	}
+}
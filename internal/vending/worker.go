@@ -0,0 +1,731 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package vending
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/compliance"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/probe"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/quotas"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ct "github.com/aws/aws-sdk-go-v2/service/controltower"
+	cttypes "github.com/aws/aws-sdk-go-v2/service/controltower/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	"go.uber.org/zap"
+)
+
+const (
+	// Interval between two reads of the status of an account creation
+	createPollInterval = 15 * time.Second
+
+	// Interval between two reads of the status of a Control Tower enrollment
+	enrollPollInterval = 30 * time.Second
+
+	// Maximum length of the error and cause reported to Step Functions
+	maxErrorLength = 256
+	maxCauseLength = 32768
+
+	// Name of the worker when the hostname is unknown
+	workerName = "aws-organization"
+
+	// DefaultDrainTimeout is the time the steps in flight are given to finish once the
+	// worker is stopped
+	DefaultDrainTimeout = 90 * time.Second
+
+	// A poller that has not looped for this long, outside of a step, is stuck. A long
+	// poll of an activity lasts up to a minute.
+	stalledPoller = 3 * time.Minute
+)
+
+// invalidExecutionChars matches the characters not allowed in execution names
+var invalidExecutionChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// Request is the input of a vending execution. Every step returns it with the fields
+// it learned, so the account ID found by the create step reaches the later steps.
+type Request struct {
+	AccountName string `json:"accountName"`
+	Email       string `json:"email"`
+	OUName      string `json:"ouName"`
+	AccountId   string `json:"accountId,omitempty"`
+}
+
+// Validate checks that a request names the account and its OU
+func (r *Request) Validate() error {
+	if r.AccountName == "" || r.Email == "" || r.OUName == "" {
+		return fmt.Errorf("vending request requires an account name, email and OU")
+	}
+	return nil
+}
+
+// Worker performs the activities of the vending state machine
+type Worker struct {
+	logger    *zap.Logger
+	metrics   *metrics.Collector
+	base      aws.Config
+	sfnClient *sfn.Client
+	orgClient *organizations.Client
+	ctClient  *ct.Client
+	cfg       *config.LandingZoneConfig
+	vending   *config.VendingConfig
+	name      string
+	drain     time.Duration
+	probe     *probe.Probe
+	quotas    *quotas.Requester
+
+	// Last loop and current step of the poller of each step, for the liveness probe
+	mutex    sync.Mutex
+	lastPoll map[Step]time.Time
+	busy     map[Step]bool
+}
+
+// WithDrainTimeout sets the time the steps in flight are given to finish once the
+// worker is stopped
+func WithDrainTimeout(timeout time.Duration) func(*Worker) error {
+	return func(w *Worker) error {
+		if timeout < 0 {
+			return fmt.Errorf("drain timeout must not be negative")
+		}
+		w.drain = timeout
+		return nil
+	}
+}
+
+// WithProbe reports the liveness and readiness of the worker to a probe
+func WithProbe(p *probe.Probe) func(*Worker) error {
+	return func(w *Worker) error {
+		w.probe = p
+		p.AddCheck("vending pollers", w.live)
+		return nil
+	}
+}
+
+// WithQuotaStore requests the service quota increases of the baseline of new accounts,
+// recording their status in the given store
+func WithQuotaStore(ctx context.Context, store quotas.Store) func(*Worker) error {
+	return func(w *Worker) error {
+		requester, err := quotas.NewRequester(ctx, w.cfg, store)
+		if err != nil {
+			return err
+		}
+		w.quotas = requester
+		return nil
+	}
+}
+
+// NewWorker creates a worker using the credentials of the management account
+func NewWorker(ctx context.Context, cfg *config.LandingZoneConfig, opts ...func(*Worker) error) (*Worker, error) {
+	if cfg.Vending == nil {
+		return nil, fmt.Errorf("no account vending state machine configured")
+	}
+
+	logger, err := logging.NewLogger("vending")
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metrics.NewCollector("vending")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metrics: %w", err)
+	}
+
+	base, err := awsclient.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = workerName
+	}
+	w := &Worker{
+		logger:    logger,
+		metrics:   metrics,
+		base:      base,
+		sfnClient: sfn.NewFromConfig(base),
+		orgClient: organizations.NewFromConfig(base),
+		ctClient:  ct.NewFromConfig(base),
+		cfg:       cfg,
+		vending:   cfg.Vending,
+		name:      hostname,
+		drain:     DefaultDrainTimeout,
+		probe:     probe.New(),
+		lastPoll:  make(map[Step]time.Time),
+		busy:      make(map[Step]bool),
+	}
+
+	for _, opt := range opts {
+		if err := opt(w); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Start starts a vending execution for an account and returns its ARN. The execution
+// is named after the account, so vending the same account twice is refused.
+func (w *Worker) Start(ctx context.Context, request Request) (string, error) {
+	if err := readonly.Check("start account vending"); err != nil {
+		return "", err
+	}
+	if err := request.Validate(); err != nil {
+		return "", err
+	}
+
+	machineArn, err := w.stateMachineArn(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	input, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal vending request: %w", err)
+	}
+
+	out, err := w.sfnClient.StartExecution(ctx, &sfn.StartExecutionInput{
+		StateMachineArn: aws.String(machineArn),
+		Name:            aws.String(executionName(request.AccountName)),
+		Input:           aws.String(string(input)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start vending of account %s: %w", request.AccountName, err)
+	}
+
+	w.metrics.IncrementCounter("vending_executions")
+	return aws.ToString(out.ExecutionArn), nil
+}
+
+// Run polls the activities of every step until the context ends. Each step has its own
+// poller, so a long enrollment does not hold up the creation of other accounts. Once the
+// context ends, the worker stops taking tasks and drains: the steps in flight are
+// given the drain timeout to finish before they are abandoned.
+func (w *Worker) Run(ctx context.Context) error {
+	if err := readonly.Check("run account vending worker"); err != nil {
+		return err
+	}
+
+	arns, err := w.activityArns(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Steps outlive the polling context by the drain timeout
+	taskCtx, cancelTasks := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelTasks()
+	go func() {
+		select {
+		case <-taskCtx.Done():
+			return
+		case <-ctx.Done():
+		}
+
+		w.probe.Drain()
+		w.logger.Info("draining vending worker", zap.Duration("timeout", w.drain))
+		select {
+		case <-taskCtx.Done():
+		case <-time.After(w.drain):
+			w.logger.Warn("drain timeout reached, abandoning the steps in flight")
+			cancelTasks()
+		}
+	}()
+
+	w.logger.Info("vending worker started",
+		zap.String("stateMachine", w.vending.Name()),
+		zap.String("worker", w.name))
+
+	var wg sync.WaitGroup
+	for _, step := range Steps {
+		w.touch(step, false)
+		wg.Add(1)
+		go func(step Step, arn string) {
+			defer wg.Done()
+			w.poll(ctx, taskCtx, step, arn)
+		}(step, arns[step])
+	}
+	w.probe.SetReady(true)
+	wg.Wait()
+
+	w.logger.Info("vending worker stopped")
+	return nil
+}
+
+// live fails when a poller has stopped looping without running a step
+func (w *Worker) live() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for step, last := range w.lastPoll {
+		if !w.busy[step] && time.Since(last) > stalledPoller {
+			return fmt.Errorf("poller of step %s stalled since %s", step, last.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// touch records a loop of the poller of a step and whether it runs a step
+func (w *Worker) touch(step Step, busy bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.lastPoll[step] = time.Now()
+	w.busy[step] = busy
+}
+
+// poll runs the tasks of a step activity until the polling context ends. Tasks run
+// with the task context, which outlives it while the worker drains.
+func (w *Worker) poll(ctx, taskCtx context.Context, step Step, activityArn string) {
+	for ctx.Err() == nil {
+		w.touch(step, false)
+		task, err := w.sfnClient.GetActivityTask(ctx, &sfn.GetActivityTaskInput{
+			ActivityArn: aws.String(activityArn),
+			WorkerName:  aws.String(w.name),
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				w.logger.Error("failed to poll vending activity", zap.String("step", string(step)), zap.Error(err))
+				sleep(ctx, createPollInterval)
+			}
+			continue
+		}
+
+		// An empty token means the long poll ended without a task
+		if aws.ToString(task.TaskToken) == "" {
+			continue
+		}
+		w.touch(step, true)
+		w.runTask(taskCtx, step, aws.ToString(task.TaskToken), aws.ToString(task.Input))
+	}
+}
+
+// runTask performs a step for an account while sending heartbeats, and reports its
+// result to the state machine
+func (w *Worker) runTask(ctx context.Context, step Step, token, input string) {
+	start := time.Now()
+	defer func() {
+		w.metrics.RecordDuration(fmt.Sprintf("vending_%s", step), time.Since(start))
+	}()
+
+	taskCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go w.heartbeat(taskCtx, token)
+
+	var request Request
+	err := json.Unmarshal([]byte(input), &request)
+	if err == nil {
+		err = w.perform(taskCtx, step, &request)
+	}
+
+	logger := w.logger.With(
+		zap.String("step", string(step)),
+		zap.String("accountName", request.AccountName),
+		zap.String("accountId", request.AccountId))
+
+	if err != nil {
+		logger.Error("vending step failed", zap.Error(err))
+		if _, sendErr := w.sfnClient.SendTaskFailure(ctx, &sfn.SendTaskFailureInput{
+			TaskToken: aws.String(token),
+			Error:     aws.String(truncate(fmt.Sprintf("%s.%s", vendingFailedError, step), maxErrorLength)),
+			Cause:     aws.String(truncate(err.Error(), maxCauseLength)),
+		}); sendErr != nil {
+			logger.Error("failed to report vending step failure", zap.Error(sendErr))
+		}
+		return
+	}
+
+	output, err := json.Marshal(request)
+	if err != nil {
+		logger.Error("failed to marshal vending step output", zap.Error(err))
+		return
+	}
+	if _, err := w.sfnClient.SendTaskSuccess(ctx, &sfn.SendTaskSuccessInput{
+		TaskToken: aws.String(token),
+		Output:    aws.String(string(output)),
+	}); err != nil {
+		logger.Error("failed to report vending step success", zap.Error(err))
+		return
+	}
+	logger.Info("vending step completed", zap.Duration("duration", time.Since(start)))
+}
+
+// heartbeat tells the state machine the task is alive until the context ends. Beats
+// are sent three times per heartbeat interval so a single lost call is tolerated.
+func (w *Worker) heartbeat(ctx context.Context, token string) {
+	ticker := time.NewTicker(time.Duration(w.vending.Heartbeat()) * time.Second / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := w.sfnClient.SendTaskHeartbeat(ctx, &sfn.SendTaskHeartbeatInput{
+				TaskToken: aws.String(token),
+			}); err != nil && ctx.Err() == nil {
+				w.logger.Warn("failed to send vending heartbeat", zap.Error(err))
+			}
+		}
+	}
+}
+
+// perform runs a step for the account of a request
+func (w *Worker) perform(ctx context.Context, step Step, request *Request) error {
+	if err := request.Validate(); err != nil {
+		return err
+	}
+	if step != StepCreate && request.AccountId == "" {
+		return fmt.Errorf("vending step %s requires the account ID", step)
+	}
+
+	switch step {
+	case StepCreate:
+		return w.create(ctx, request)
+	case StepEnroll:
+		return w.enroll(ctx, request)
+	case StepSettings:
+		return w.settings(ctx, request)
+	case StepVerify:
+		return w.verify(ctx, request)
+	default:
+		return fmt.Errorf("unknown vending step %q", step)
+	}
+}
+
+// create creates the account and waits for its creation to complete. An account that
+// already exists with the email of the request is reused, so a retried step does not
+// create a second account.
+func (w *Worker) create(ctx context.Context, request *Request) error {
+	existing, err := w.accountByEmail(ctx, request.Email)
+	if err != nil {
+		return err
+	}
+	if existing != "" {
+		request.AccountId = existing
+		return nil
+	}
+
+	out, err := w.orgClient.CreateAccount(ctx, &organizations.CreateAccountInput{
+		AccountName: aws.String(request.AccountName),
+		Email:       aws.String(request.Email),
+		RoleName:    aws.String(awsclient.MemberRoleName(w.cfg)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create account %s: %w", request.AccountName, err)
+	}
+
+	id := out.CreateAccountStatus.Id
+	for {
+		status, err := w.orgClient.DescribeCreateAccountStatus(ctx, &organizations.DescribeCreateAccountStatusInput{
+			CreateAccountRequestId: id,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read creation status of account %s: %w", request.AccountName, err)
+		}
+
+		switch status.CreateAccountStatus.State {
+		case orgtypes.CreateAccountStateSucceeded:
+			request.AccountId = aws.ToString(status.CreateAccountStatus.AccountId)
+			return nil
+		case orgtypes.CreateAccountStateFailed:
+			return fmt.Errorf("creation of account %s failed: %s", request.AccountName,
+				status.CreateAccountStatus.FailureReason)
+		}
+
+		if err := sleep(ctx, createPollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// enroll moves the account into its OU and enrolls it in Control Tower by resetting
+// the baselines enabled on the OU, which extends them to the accounts moved into it.
+// A retried step finds the account in its OU and enrolls it again.
+func (w *Worker) enroll(ctx context.Context, request *Request) error {
+	target, err := w.findOU(ctx, request.OUName)
+	if err != nil {
+		return err
+	}
+
+	parents, err := w.orgClient.ListParents(ctx, &organizations.ListParentsInput{
+		ChildId: aws.String(request.AccountId),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read parent of account %s: %w", request.AccountId, err)
+	}
+	if len(parents.Parents) == 0 {
+		return fmt.Errorf("account %s has no parent", request.AccountId)
+	}
+
+	if source := aws.ToString(parents.Parents[0].Id); source != target {
+		if _, err := w.orgClient.MoveAccount(ctx, &organizations.MoveAccountInput{
+			AccountId:           aws.String(request.AccountId),
+			SourceParentId:      aws.String(source),
+			DestinationParentId: aws.String(target),
+		}); err != nil {
+			return fmt.Errorf("failed to move account %s to OU %s: %w", request.AccountId, request.OUName, err)
+		}
+	}
+
+	ou, err := w.orgClient.DescribeOrganizationalUnit(ctx, &organizations.DescribeOrganizationalUnitInput{
+		OrganizationalUnitId: aws.String(target),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe OU %s: %w", request.OUName, err)
+	}
+
+	var enabled []string
+	paginator := ct.NewListEnabledBaselinesPaginator(w.ctClient, &ct.ListEnabledBaselinesInput{
+		Filter: &cttypes.EnabledBaselineFilter{TargetIdentifiers: []string{aws.ToString(ou.OrganizationalUnit.Arn)}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list enabled baselines of OU %s: %w", request.OUName, err)
+		}
+		for _, baseline := range page.EnabledBaselines {
+			enabled = append(enabled, aws.ToString(baseline.Arn))
+		}
+	}
+	if len(enabled) == 0 {
+		return fmt.Errorf("OU %s is not registered with Control Tower, account %s cannot be enrolled",
+			request.OUName, request.AccountId)
+	}
+
+	for _, arn := range enabled {
+		out, err := w.ctClient.ResetEnabledBaseline(ctx, &ct.ResetEnabledBaselineInput{
+			EnabledBaselineIdentifier: aws.String(arn),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to enroll account %s in OU %s: %w", request.AccountId, request.OUName, err)
+		}
+		if err := w.waitBaselineOperation(ctx, aws.ToString(out.OperationIdentifier)); err != nil {
+			return fmt.Errorf("failed to enroll account %s in OU %s: %w", request.AccountId, request.OUName, err)
+		}
+	}
+	return nil
+}
+
+// waitBaselineOperation waits for a Control Tower baseline operation to complete
+func (w *Worker) waitBaselineOperation(ctx context.Context, operationID string) error {
+	for {
+		out, err := w.ctClient.GetBaselineOperation(ctx, &ct.GetBaselineOperationInput{
+			OperationIdentifier: aws.String(operationID),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read baseline operation %s: %w", operationID, err)
+		}
+
+		switch out.BaselineOperation.Status {
+		case cttypes.BaselineOperationStatusSucceeded:
+			return nil
+		case cttypes.BaselineOperationStatusFailed:
+			return fmt.Errorf("baseline operation %s failed: %s", operationID,
+				aws.ToString(out.BaselineOperation.StatusMessage))
+		}
+
+		if err := sleep(ctx, enrollPollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// settings applies the account alias and password policy of the baseline in the
+// account, and requests the increases of its service quota targets. The resources of
+// the baseline are applied by the baseline module.
+func (w *Worker) settings(ctx context.Context, request *Request) error {
+	if w.cfg.Baseline == nil {
+		return nil
+	}
+
+	cfg := awsclient.AssumeRole(w.base, request.AccountId, awsclient.MemberRoleName(w.cfg))
+	findings, err := compliance.NewBaselineCheck(w.cfg, true).Run(ctx,
+		compliance.Account{ID: request.AccountId, Name: request.AccountName}, cfg)
+	if err != nil {
+		return err
+	}
+
+	// Applying the baseline only reports the changes that failed
+	var failures []string
+	for _, finding := range findings {
+		failures = append(failures, finding.Message)
+	}
+
+	// Quota increases are only requested; their approval is tracked in the state
+	if len(w.cfg.Baseline.ServiceQuotas) > 0 {
+		if w.quotas == nil {
+			w.logger.Warn("no state to track service quota requests, increases not requested",
+				zap.String("accountId", request.AccountId))
+		} else if _, err := w.quotas.Apply(ctx, request.AccountId, request.OUName); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// verify checks the baseline artifacts of the account through the read-only role
+func (w *Worker) verify(ctx context.Context, request *Request) error {
+	verifier, err := compliance.NewVerifier(ctx, w.cfg)
+	if err != nil {
+		return err
+	}
+
+	results := verifier.VerifyAccount(ctx, request.AccountId, request.AccountName)
+	if results.Passed {
+		return nil
+	}
+
+	var failures []string
+	for _, artifact := range compliance.Artifacts {
+		if result := results.Results[artifact]; result.Outcome == compliance.OutcomeFail {
+			failures = append(failures, fmt.Sprintf("%s: %s", artifact, result.Message))
+		}
+	}
+	return fmt.Errorf("account %s failed baseline verification: %s", request.AccountId, strings.Join(failures, "; "))
+}
+
+// accountByEmail returns the ID of the member account with an email, or an empty
+// string when there is none
+func (w *Worker) accountByEmail(ctx context.Context, email string) (string, error) {
+	paginator := organizations.NewListAccountsPaginator(w.orgClient, &organizations.ListAccountsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list accounts: %w", err)
+		}
+		for _, account := range page.Accounts {
+			if strings.EqualFold(aws.ToString(account.Email), email) {
+				return aws.ToString(account.Id), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// findOU returns the ID of the OU with a name, searching the whole hierarchy
+func (w *Worker) findOU(ctx context.Context, name string) (string, error) {
+	roots, err := w.orgClient.ListRoots(ctx, &organizations.ListRootsInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list organization roots: %w", err)
+	}
+	if len(roots.Roots) == 0 {
+		return "", fmt.Errorf("organization has no root")
+	}
+
+	parents := []string{aws.ToString(roots.Roots[0].Id)}
+	for len(parents) > 0 {
+		parent := parents[0]
+		parents = parents[1:]
+
+		paginator := organizations.NewListOrganizationalUnitsForParentPaginator(w.orgClient,
+			&organizations.ListOrganizationalUnitsForParentInput{ParentId: aws.String(parent)})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return "", fmt.Errorf("failed to list OUs of %s: %w", parent, err)
+			}
+			for _, ou := range page.OrganizationalUnits {
+				if aws.ToString(ou.Name) == name {
+					return aws.ToString(ou.Id), nil
+				}
+				parents = append(parents, aws.ToString(ou.Id))
+			}
+		}
+	}
+	return "", fmt.Errorf("OU %q not found", name)
+}
+
+// activityArns returns the ARNs of the activities of the steps
+func (w *Worker) activityArns(ctx context.Context) (map[Step]string, error) {
+	names := make(map[string]Step, len(Steps))
+	for _, step := range Steps {
+		names[ActivityName(w.vending, step)] = step
+	}
+
+	arns := make(map[Step]string, len(Steps))
+	paginator := sfn.NewListActivitiesPaginator(w.sfnClient, &sfn.ListActivitiesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list vending activities: %w", err)
+		}
+		for _, activity := range page.Activities {
+			if step, ok := names[aws.ToString(activity.Name)]; ok {
+				arns[step] = aws.ToString(activity.ActivityArn)
+			}
+		}
+	}
+
+	for _, step := range Steps {
+		if arns[step] == "" {
+			return nil, fmt.Errorf("vending activity %s not found, deploy the vending state machine first",
+				ActivityName(w.vending, step))
+		}
+	}
+	return arns, nil
+}
+
+// stateMachineArn returns the ARN of the vending state machine
+func (w *Worker) stateMachineArn(ctx context.Context) (string, error) {
+	paginator := sfn.NewListStateMachinesPaginator(w.sfnClient, &sfn.ListStateMachinesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list state machines: %w", err)
+		}
+		for _, machine := range page.StateMachines {
+			if aws.ToString(machine.Name) == w.vending.Name() {
+				return aws.ToString(machine.StateMachineArn), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("vending state machine %s not found, deploy it first", w.vending.Name())
+}
+
+// executionName returns the name of the execution vending an account, restricted to
+// the characters Step Functions accepts
+func executionName(accountName string) string {
+	name := invalidExecutionChars.ReplaceAllString(accountName, "-")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	return name
+}
+
+// truncate shortens a value to the given length
+func truncate(value string, length int) string {
+	if len(value) > length {
+		return value[:length]
+	}
+	return value
+}
+
+// sleep waits for the given duration or until the context ends
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -8,15 +8,42 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"time"
 
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/baseline"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/billing"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/blueprints"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/catalog"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/centrallogging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/cli"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/controltower"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/extensions"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/health"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/loganalytics"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/logging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/manifest"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/metrics"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/modulehooks"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/networking"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/observability"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/optimization"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/organization"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/policies"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/progress"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/readonly"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/secrets"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/security"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/selection"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/siem"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/stacks"
 	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/tagging"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/transform"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/vending"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
@@ -25,7 +52,8 @@ import (
 const (
 	// ApplicationVersion represents the current version of the application
 	ApplicationVersion = "1.0.0"
-	// DefaultTimeout represents the default timeout for operations
+	// DefaultTimeout represents the default timeout for operations, unless the
+	// configuration sets a deployment deadline
 	DefaultTimeout = 30 * time.Minute
 	// MaxConcurrentOperations represents the maximum number of concurrent AWS operations
 	MaxConcurrentOperations = 10
@@ -35,6 +63,11 @@ const (
 
 // main is the entry point of the application
 func main() {
+	// Apply the logging configuration first, as the format is fixed once the logger exists
+	if err := logging.Configure(loggingSettings(config.DefaultConfig.LandingZoneConfig.Logging)); err != nil {
+		panic("invalid logging configuration: " + err.Error())
+	}
+
 	// Initialize logger
 	logger, err := logging.NewLogger("main")
 	if err != nil {
@@ -42,6 +75,58 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// Parse command line options
+	opts, err := cli.ParseOptions(os.Args[1:])
+	if err != nil {
+		logger.Fatal("failed to parse command line options", zap.Error(err))
+	}
+
+	// --quiet and --verbose replace the configured levels on the console
+	if mode := opts.OutputMode(); mode != progress.ModeNormal {
+		logging.SetConsoleLevel(mode.Level())
+	}
+
+	if opts.ReadOnly {
+		readonly.Enable()
+		logger.Info("read-only mode enabled, all mutating operations are disabled")
+	}
+
+	if err := cli.SelectOrganization(&config.DefaultConfig, opts.Organization); err != nil {
+		logger.Fatal("invalid organization selection", zap.Error(err))
+	}
+	if config.DefaultConfig.Selected != "" {
+		logger.Info("operating on organization",
+			zap.String("organization", config.DefaultConfig.Selected),
+			zap.String("partition", config.DefaultConfig.PartitionName()))
+	}
+
+	if err := cli.SelectEnvironment(&config.DefaultConfig, opts.Environment); err != nil {
+		logger.Fatal("invalid environment selection", zap.Error(err))
+	}
+	if config.DefaultConfig.Environment != "" {
+		logger.Info("operating on environment", zap.String("environment", config.DefaultConfig.Environment))
+	}
+
+	if err := cli.SelectRollback(context.Background(), &config.DefaultConfig); err != nil {
+		logger.Fatal("invalid rollback configuration", zap.Error(err))
+	}
+
+	sel, err := opts.Selection()
+	if err != nil {
+		logger.Fatal("invalid module selection", zap.Error(err))
+	}
+
+	// A multi-stack deployment runs the program once per component stack
+	component, err := stacks.Current()
+	if err != nil {
+		logger.Fatal("invalid stack component", zap.Error(err))
+	}
+	if component != nil {
+		if sel, err = component.Selection(); err != nil {
+			logger.Fatal("invalid stack component", zap.Error(err))
+		}
+	}
+
 	// Initialize metrics collector
 	metrics, err := metrics.NewCollector("aws-organization-config")
 	if err != nil {
@@ -53,15 +138,23 @@ func main() {
 	limiter := rate.NewLimiter(rate.Limit(RateLimitRPS), MaxConcurrentOperations)
 
 	// Initialize state manager
-	stateManager, err := state.NewManager("aws-organization-state")
+	stateManager, err := state.NewManager(context.Background(), state.OptionsFor(config.DefaultConfig.LandingZoneConfig)...)
 	if err != nil {
 		logger.Fatal("failed to initialize state manager", zap.Error(err))
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	// Create context ending at the deployment deadline
+	ctx, cancel := context.WithTimeout(context.Background(), config.DefaultConfig.LandingZoneConfig.Timeouts.Deadline(DefaultTimeout))
 	defer cancel()
 
+	// Run a sub-command instead of the Pulumi program when one is given
+	if len(opts.Args) > 0 {
+		if err := cli.Run(ctx, opts); err != nil {
+			logger.Fatal("command failed", errorFields(err, zap.String("command", opts.Args[0]))...)
+		}
+		return
+	}
+
 	// Run Pulumi program
 	err = pulumi.Run(func(ctx *pulumi.Context) error {
 		// Start timing the execution
@@ -70,36 +163,232 @@ func main() {
 			metrics.RecordDuration("total_execution_time", time.Since(start))
 		}()
 
+		// Only previews are allowed in read-only mode
+		if err := readonly.Guard(ctx, "deploy"); err != nil {
+			return pulumi.Error(err)
+		}
+
+		// Resources of skipped modules are not registered, so a plain update would
+		// delete them. Partial selections must go through the deploy command, which
+		// restricts the update to the resources of the selected modules.
+		if sel.Partial() && component == nil && !ctx.DryRun() && os.Getenv(selection.EnvTargeted) == "" {
+			return pulumi.Error(fmt.Errorf("partial deployments must be applied with the deploy command"))
+		}
+
+		logger.Info("applying modules", zap.String("modules", sel.String()))
+
+		// Outputs of the stacks this component depends on
+		refs, err := stacks.LoadReferences(ctx)
+		if err != nil {
+			return pulumi.Error(err)
+		}
+
 		// Load and validate configuration
 		cfg, err := loadAndValidateConfig(ctx, logger)
 		if err != nil {
 			return pulumi.Error(err)
 		}
 
-		// Create organization with retry logic
-		org, err := createOrganizationWithRetry(ctx, cfg, logger, limiter)
+		// Tag every resource the modules create with the standard tags
+		if err := tagging.Register(ctx, cfg.LandingZoneConfig); err != nil {
+			return pulumi.Error(err)
+		}
+
+		// Customer transformations run after the tags are added
+		if err := transform.Register(ctx, cfg.LandingZoneConfig); err != nil {
+			return pulumi.Error(err)
+		}
+
+		// Hooks run before and after each module
+		moduleHooks, err := modulehooks.NewRunner(ctx, cfg.LandingZoneConfig)
 		if err != nil {
 			return pulumi.Error(err)
 		}
 
-		// Ensure cleanup on error
-		defer func() {
+		// Custom guardrails and baselines registered by the extension plugins
+		extensionRunner, err := extensions.NewRunner(cfg.LandingZoneConfig)
+		if err != nil {
+			return pulumi.Error(err)
+		}
+
+		// ARNs are built in the partition of the deployment credentials, which loading
+		// the SDK configuration detects
+		if _, err := awsclient.Load(ctx.Context()); err != nil {
+			return pulumi.Error(err)
+		}
+
+		// Create organization with retry logic
+		var org *organization.Organization
+		if sel.Enabled(selection.ModuleOrganization) {
+			if err := moduleHooks.Pre(ctx, selection.ModuleOrganization); err != nil {
+				return pulumi.Error(err)
+			}
+
+			org, err = createOrganizationWithRetry(ctx, cfg, logger, limiter)
 			if err != nil {
-				if cleanupErr := org.Cleanup(); cleanupErr != nil {
-					logger.Error("cleanup failed",
-						zap.Error(cleanupErr),
-						zap.String("operation", "cleanup"))
+				return pulumi.Error(err)
+			}
+
+			// Ensure cleanup on error
+			defer func() {
+				if err != nil {
+					if cleanupErr := org.Cleanup(); cleanupErr != nil {
+						logger.Error("cleanup failed",
+							zap.Error(cleanupErr),
+							zap.String("operation", "cleanup"))
+					}
 				}
+			}()
+
+			org.Export(ctx)
+
+			// Attach the service control and tag policies of the configuration
+			if err := policies.SetupPolicies(ctx, cfg.LandingZoneConfig, org.PolicyTargets(cfg.LandingZoneConfig)); err != nil {
+				return pulumi.Error(err)
 			}
-		}()
 
-		// Setup landing zone with retry logic
-		if err := setupLandingZoneWithRetry(ctx, org, cfg, logger, limiter); err != nil {
-			return pulumi.Error(err)
+			// Register the Account Factory Customization blueprints
+			if err := blueprints.SetupBlueprints(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+
+			// Share the portfolios of the platform products with the organization
+			if err := catalog.SetupServiceCatalog(ctx, cfg.LandingZoneConfig, org.Arn(), org.PolicyTargets(cfg.LandingZoneConfig)); err != nil {
+				return pulumi.Error(err)
+			}
+
+			// Publish the manifest consumed by the tooling of the organization
+			if err := manifest.Publish(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+
+			moduleHooks.Post(ctx, selection.ModuleOrganization, map[string]pulumi.Input{
+				stacks.OutputOrganizationArn: org.Arn(),
+			})
+		}
+
+		// Setup landing zone with retry logic, then the custom guardrails
+		if sel.Enabled(selection.ModuleControlTower) {
+			if err := moduleHooks.Pre(ctx, selection.ModuleControlTower); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := setupLandingZoneWithRetry(ctx, cfg, logger, limiter); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := extensionRunner.Guardrails(ctx); err != nil {
+				return pulumi.Error(err)
+			}
+			moduleHooks.Post(ctx, selection.ModuleControlTower, nil)
+		}
+
+		// Enable organization-wide security services, the health organizational view and the
+		// advisors, store the break-glass credentials and webhook secrets, link the
+		// accounts to the monitoring account, subscribe their log groups to the log
+		// archive account, catalog the logs it receives and forward the trail and the
+		// findings to the SIEM
+		if sel.Enabled(selection.ModuleSecurity) {
+			if err := moduleHooks.Pre(ctx, selection.ModuleSecurity); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := security.SetupSecurityServices(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := health.SetupHealth(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := optimization.SetupOptimization(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := secrets.SetupSecrets(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := observability.SetupObservability(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := centrallogging.SetupCentralLogging(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := loganalytics.SetupLogAnalytics(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := siem.SetupSIEM(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+			moduleHooks.Post(ctx, selection.ModuleSecurity, nil)
+		}
+
+		// Apply the resource baseline to every account, deploy the state machine vending
+		// new ones and the custom baselines
+		if sel.Enabled(selection.ModuleBaseline) {
+			if err := moduleHooks.Pre(ctx, selection.ModuleBaseline); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := baseline.SetupAccountBaseline(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := vending.SetupVending(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := extensionRunner.Baselines(ctx); err != nil {
+				return pulumi.Error(err)
+			}
+			moduleHooks.Post(ctx, selection.ModuleBaseline, nil)
+		}
+
+		// Create the shared network, sharing it with the organization when its ARN is known
+		if sel.Enabled(selection.ModuleNetworking) {
+			if err := moduleHooks.Pre(ctx, selection.ModuleNetworking); err != nil {
+				return pulumi.Error(err)
+			}
+
+			var organizationArn pulumi.StringInput
+			if org != nil {
+				organizationArn = org.Arn()
+			} else if arn, ok := refs.StringOutput(stacks.ComponentOrgCore, stacks.OutputOrganizationArn); ok {
+				organizationArn = arn
+			}
+
+			network, err := networking.NewNetworkComponent(ctx, "networking", &networking.NetworkComponentArgs{
+				Config:          cfg.LandingZoneConfig,
+				OrganizationArn: organizationArn,
+			})
+			if err != nil {
+				return pulumi.Error(err)
+			}
+
+			ctx.Export(networking.OutputVPCID, network.VpcId)
+			ctx.Export(networking.OutputSubnetIDs, network.SubnetIds)
+
+			moduleHooks.Post(ctx, selection.ModuleNetworking, map[string]pulumi.Input{
+				networking.OutputVPCID:     network.VpcId,
+				networking.OutputSubnetIDs: network.SubnetIds,
+			})
 		}
 
-		// Save state
-		if err := stateManager.Save(ctx, org); err != nil {
+		// Create the cost categories and deliver the Cost and Usage Report
+		if sel.Enabled(selection.ModuleBilling) {
+			if err := moduleHooks.Pre(ctx, selection.ModuleBilling); err != nil {
+				return pulumi.Error(err)
+			}
+			if err := billing.SetupBilling(ctx, cfg.LandingZoneConfig); err != nil {
+				return pulumi.Error(err)
+			}
+			moduleHooks.Post(ctx, selection.ModuleBilling, nil)
+		}
+
+		// The update waits for the post hooks of the modules and fails with them
+		moduleHooks.Export(ctx)
+
+		// Save the applied configuration as state, compared by `config diff --state`.
+		// Updates leaving out the organization module record it too.
+		if readonly.Enabled() {
+			logger.Info("read-only mode, skipping state save")
+			return nil
+		}
+		if ctx.DryRun() {
+			return nil
+		}
+		if err := stateManager.Save(ctx.Context(), cfg); err != nil {
 			logger.Error("failed to save state", zap.Error(err))
 			return pulumi.Error(err)
 		}
@@ -108,11 +397,36 @@ func main() {
 	})
 
 	if err != nil {
-		logger.Fatal("deployment failed", zap.Error(err))
+		logger.Fatal("deployment failed", errorFields(err)...)
 		os.Exit(1)
 	}
 }
 
+// errorFields returns the log fields of a failure, with the remediation of AWS API
+// errors of a known class
+func errorFields(err error, fields ...zap.Field) []zap.Field {
+	fields = append(fields, zap.Error(err))
+	if hint := awsclient.Hint(err); hint != "" {
+		fields = append(fields, zap.String("hint", hint))
+	}
+	return fields
+}
+
+// loggingSettings returns the logging configuration overridden by the environment
+func loggingSettings(cfg *config.LoggingConfig) logging.Settings {
+	var settings logging.Settings
+	if cfg != nil {
+		settings = logging.Settings{
+			Level:       cfg.Level,
+			Format:      cfg.Format,
+			Components:  cfg.Components,
+			Directory:   cfg.Directory,
+			DisableFile: cfg.DisableFile,
+		}
+	}
+	return settings.WithEnv()
+}
+
 // loadAndValidateConfig loads and validates the configuration
 func loadAndValidateConfig(ctx *pulumi.Context, logger *zap.Logger) (*config.OrganizationConfig, error) {
 	logger.Info("loading configuration")
@@ -132,15 +446,20 @@ func createOrganizationWithRetry(ctx *pulumi.Context, cfg *config.OrganizationCo
 	logger *zap.Logger, limiter *rate.Limiter) (*organization.Organization, error) {
 
 	var org *organization.Organization
-	var err error
 
 	operation := func() error {
 		if err := limiter.Wait(ctx); err != nil {
 			return err
 		}
 
-		org, err = organization.NewOrganization(ctx, cfg)
-		return err
+		component, err := organization.NewOrganizationComponent(ctx, "organization",
+			&organization.OrganizationComponentArgs{Config: cfg})
+		if err != nil {
+			return err
+		}
+
+		org = component.Organization()
+		return nil
 	}
 
 	retryConfig := organization.RetryConfig{
@@ -160,15 +479,17 @@ func createOrganizationWithRetry(ctx *pulumi.Context, cfg *config.OrganizationCo
 }
 
 // setupLandingZoneWithRetry sets up the AWS Control Tower landing zone with retry logic
-func setupLandingZoneWithRetry(ctx *pulumi.Context, org *organization.Organization,
-	cfg *config.OrganizationConfig, logger *zap.Logger, limiter *rate.Limiter) error {
+func setupLandingZoneWithRetry(ctx *pulumi.Context, cfg *config.OrganizationConfig,
+	logger *zap.Logger, limiter *rate.Limiter) error {
 
 	operation := func() error {
 		if err := limiter.Wait(ctx); err != nil {
 			return err
 		}
 
-		return controltower.SetupLandingZone(ctx, org, cfg.LandingZoneConfig)
+		_, err := controltower.NewLandingZoneComponent(ctx, "landing-zone",
+			&controltower.LandingZoneComponentArgs{Config: cfg.LandingZoneConfig})
+		return err
 	}
 
 	retryConfig := organization.RetryConfig{
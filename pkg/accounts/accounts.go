@@ -0,0 +1,73 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package accounts provides the public API for vending AWS Organization accounts.
+// Version: 1.0.0
+package accounts
+
+import (
+	"context"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/accounts"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/pkg/config"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Account types
+type (
+	AccountService        = accounts.AccountService
+	AccountReader         = accounts.AccountReader
+	AccountConfig         = accounts.AccountConfig
+	AccountInfo           = accounts.AccountInfo
+	AccountFilter         = accounts.AccountFilter
+	AccountMatch          = accounts.AccountMatch
+	AccountManager        = accounts.AccountManager
+	AccountsComponent     = accounts.AccountsComponent
+	AccountsComponentArgs = accounts.AccountsComponentArgs
+)
+
+// ComponentType is the type token of the accounts component
+var ComponentType = accounts.AccountsComponentType
+
+// Classes of AWS API errors, matched with errors.Is
+var (
+	ErrThrottled     = awsclient.ErrThrottled
+	ErrAccessDenied  = awsclient.ErrAccessDenied
+	ErrAlreadyExists = awsclient.ErrAlreadyExists
+	ErrLimitExceeded = awsclient.ErrLimitExceeded
+)
+
+// NewAccountManager creates an account manager with the provided options
+func NewAccountManager(ctx context.Context, opts ...func(*AccountManager) error) (*AccountManager, error) {
+	return accounts.NewAccountManager(ctx, opts...)
+}
+
+// WithHooks enables the post-provision hooks configured for the OUs of the landing zone
+func WithHooks(ctx context.Context, lz *config.LandingZoneConfig) func(*AccountManager) error {
+	return accounts.WithHooks(ctx, lz)
+}
+
+// WithParameterSharing shares the SSM parameters of created accounts with the tooling
+// accounts of the landing zone
+func WithParameterSharing(lz *config.LandingZoneConfig) func(*AccountManager) error {
+	return accounts.WithParameterSharing(lz)
+}
+
+// WithParameterNames names the SSM parameters of the manager after the parameter
+// namespace and template of the landing zone
+func WithParameterNames(lz *config.LandingZoneConfig) func(*AccountManager) error {
+	return accounts.WithParameterNames(lz)
+}
+
+// WithAccountAttributes checks the attributes of created accounts against the custom
+// account attributes of the landing zone
+func WithAccountAttributes(lz *config.LandingZoneConfig) func(*AccountManager) error {
+	return accounts.WithAccountAttributes(lz)
+}
+
+// NewAccountsComponent creates a set of accounts as a component resource
+func NewAccountsComponent(ctx *pulumi.Context, name string, args *AccountsComponentArgs, opts ...pulumi.ResourceOption) (*AccountsComponent, error) {
+	return accounts.NewAccountsComponent(ctx, name, args, opts...)
+}
@@ -0,0 +1,11 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package landingzonev1 provides the gRPC client and server of the organization service
+// served by the serve command, generated from proto/landingzone/v1/landingzone.proto.
+// Clients in other languages are generated from the same definitions.
+// Version: 1.0.0
+package landingzonev1
+
+//go:generate protoc -I ../../../../proto --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative landingzone/v1/landingzone.proto
@@ -0,0 +1,126 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package config provides the public configuration types and loader of the landing zone.
+// Version: 1.0.0
+package config
+
+import (
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+)
+
+// Version of the configuration format
+const Version = config.ConfigVersion
+
+// Configuration types
+type (
+	ConfigurationManager     = config.ConfigurationManager
+	OrganizationConfig       = config.OrganizationConfig
+	OrganizationTarget       = config.OrganizationTarget
+	EnvironmentConfig        = config.EnvironmentConfig
+	LandingZoneConfig        = config.LandingZoneConfig
+	OUConfig                 = config.OUConfig
+	AccountConfig            = config.AccountConfig
+	HookConfig               = config.HookConfig
+	InvitationConfig         = config.InvitationConfig
+	VPCConfig                = config.VPCConfig
+	Subnet                   = config.Subnet
+	LogArchiveConfig         = config.LogArchiveConfig
+	MacieConfig              = config.MacieConfig
+	GuardDutyConfig          = config.GuardDutyConfig
+	GuardDutyExportConfig    = config.GuardDutyExportConfig
+	GuardDutyFilterConfig    = config.GuardDutyFilterConfig
+	GuardDutyCriterionConfig = config.GuardDutyCriterionConfig
+	QuarantineConfig         = config.QuarantineConfig
+	ComplianceConfig         = config.ComplianceConfig
+	BaselineConfig           = config.BaselineConfig
+	PasswordPolicyConfig     = config.PasswordPolicyConfig
+	AccountBaselineOverride  = config.AccountBaselineOverride
+	ContactsConfig           = config.ContactsConfig
+	PrimaryContactConfig     = config.PrimaryContactConfig
+	AlternateContactConfig   = config.AlternateContactConfig
+	OptInRegionsConfig       = config.OptInRegionsConfig
+	RootActivityAlarmConfig  = config.RootActivityAlarmConfig
+	ServiceQuotaTarget       = config.ServiceQuotaTarget
+	KeyPolicyConfig          = config.KeyPolicyConfig
+	DriftConfig              = config.DriftConfig
+	BillingConfig            = config.BillingConfig
+	CostCategoryConfig       = config.CostCategoryConfig
+	CostCategoryRuleConfig   = config.CostCategoryRuleConfig
+	CostAndUsageReportConfig = config.CostAndUsageReportConfig
+	HealthConfig             = config.HealthConfig
+	OptimizationConfig       = config.OptimizationConfig
+	ParameterSharingConfig   = config.ParameterSharingConfig
+	ManifestConfig           = config.ManifestConfig
+	AppConfigManifestConfig  = config.AppConfigManifestConfig
+	CacheConfig              = config.CacheConfig
+	PolicyConfig             = config.PolicyConfig
+	PolicyTemplate           = config.PolicyTemplate
+	CanaryConfig             = config.CanaryConfig
+	CanaryProbeConfig        = config.CanaryProbeConfig
+	RolloutConfig            = config.RolloutConfig
+	RolloutWaveConfig        = config.RolloutWaveConfig
+	BlueprintConfig          = config.BlueprintConfig
+	ServiceCatalogConfig     = config.ServiceCatalogConfig
+	PortfolioConfig          = config.PortfolioConfig
+	ProductConfig            = config.ProductConfig
+	PlacementRule            = config.PlacementRule
+	WebhookConfig            = config.WebhookConfig
+	VendingConfig            = config.VendingConfig
+	LoggingConfig            = config.LoggingConfig
+	ParametersConfig         = config.ParametersConfig
+	ParameterNames           = config.ParameterNames
+	TagPropagationConfig     = config.TagPropagationConfig
+	TransformationsConfig    = config.TransformationsConfig
+	TransformationRule       = config.TransformationRule
+	ModuleHooksConfig        = config.ModuleHooksConfig
+	ModuleHook               = config.ModuleHook
+	ExtensionsConfig         = config.ExtensionsConfig
+	TimeoutsConfig           = config.TimeoutsConfig
+	ProgressStreamConfig     = config.ProgressStreamConfig
+	RunHistoryConfig         = config.RunHistoryConfig
+	AccountAttributeConfig   = config.AccountAttributeConfig
+	AccessReviewConfig       = config.AccessReviewConfig
+	AccessReviewEmailConfig  = config.AccessReviewEmailConfig
+	MailConfig               = config.MailConfig
+	EmailVerificationConfig  = config.EmailVerificationConfig
+	NamingConfig             = config.NamingConfig
+	NamedResource            = config.NamedResource
+	ResourceNames            = config.ResourceNames
+	SecretsConfig            = config.SecretsConfig
+	SecretRotationConfig     = config.SecretRotationConfig
+	BreakGlassConfig         = config.BreakGlassConfig
+	ObservabilityConfig      = config.ObservabilityConfig
+	GrafanaConfig            = config.GrafanaConfig
+	PrometheusConfig         = config.PrometheusConfig
+	CentralLoggingConfig     = config.CentralLoggingConfig
+	LogAnalyticsConfig       = config.LogAnalyticsConfig
+	SIEMConfig               = config.SIEMConfig
+	ValidationError          = config.ValidationError
+	Change                   = config.Change
+	ChangeTicketConfig       = config.ChangeTicketConfig
+
+	AccountFactoryCustomizationConfig = config.AccountFactoryCustomizationConfig
+)
+
+// New creates an empty configuration
+func New() (*OrganizationConfig, error) {
+	return config.NewOrganizationConfig()
+}
+
+// Load reads and validates a JSON configuration file, decrypting files encrypted with
+// SOPS in memory
+func Load(path string) (*OrganizationConfig, error) {
+	return config.LoadFile(path)
+}
+
+// Preset returns the landing zone configuration a preset expands into
+func Preset(name string) (*LandingZoneConfig, error) {
+	return config.PresetConfig(name)
+}
+
+// Diff returns the semantic differences between two configurations
+func Diff(from, to *OrganizationConfig) ([]Change, error) {
+	return config.Diff(from, to)
+}
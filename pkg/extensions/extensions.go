@@ -0,0 +1,35 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package extensions provides the public API for custom guardrails and baselines.
+// Extensions register themselves in an init function, either in a build of the
+// landing zone that imports them or in a Go plugin listed in the extensions
+// configuration:
+//
+//	func init() {
+//		extensions.RegisterGuardrail(&denyRegions{})
+//	}
+//
+// Version: 1.0.0
+package extensions
+
+import (
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/extensions"
+)
+
+// Extension types
+type (
+	Extension   = extensions.Extension
+	Environment = extensions.Environment
+)
+
+// RegisterGuardrail registers a guardrail, deployed with the controltower module
+func RegisterGuardrail(extension Extension) {
+	extensions.RegisterGuardrail(extension)
+}
+
+// RegisterBaseline registers a baseline, deployed with the baseline module
+func RegisterBaseline(extension Extension) {
+	extensions.RegisterBaseline(extension)
+}
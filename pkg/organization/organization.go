@@ -0,0 +1,50 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package organization provides the public API for managing AWS Organizations and their OUs.
+// Version: 1.0.0
+package organization
+
+import (
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/organization"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/pkg/config"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Organization types
+type (
+	OrganizationService       = organization.OrganizationService
+	Organization              = organization.Organization
+	OrganizationComponent     = organization.OrganizationComponent
+	OrganizationComponentArgs = organization.OrganizationComponentArgs
+	RetryConfig               = organization.RetryConfig
+	APIError                  = awsclient.APIError
+)
+
+// ComponentType is the type token of the organization component
+var ComponentType = organization.OrganizationComponentType
+
+// Classes of AWS API errors, matched with errors.Is
+var (
+	ErrThrottled     = awsclient.ErrThrottled
+	ErrAccessDenied  = awsclient.ErrAccessDenied
+	ErrAlreadyExists = awsclient.ErrAlreadyExists
+	ErrLimitExceeded = awsclient.ErrLimitExceeded
+)
+
+// NewOrganization creates the organization and its OUs
+func NewOrganization(ctx *pulumi.Context, cfg *config.OrganizationConfig, opts ...pulumi.ResourceOption) (*Organization, error) {
+	return organization.NewOrganization(ctx, cfg, opts...)
+}
+
+// NewOrganizationComponent creates the organization as a component resource
+func NewOrganizationComponent(ctx *pulumi.Context, name string, args *OrganizationComponentArgs, opts ...pulumi.ResourceOption) (*OrganizationComponent, error) {
+	return organization.NewOrganizationComponent(ctx, name, args, opts...)
+}
+
+// RetryWithBackoff retries an operation with a linearly increasing delay
+func RetryWithBackoff(operation func() error, cfg RetryConfig) error {
+	return organization.RetryWithBackoff(operation, cfg)
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2024 Shawn LoPresto
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Package state provides the public API for persisting the state of the organization.
+// Version: 1.0.0
+package state
+
+import (
+	"context"
+
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/awsclient"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/config"
+	"github.com/PimpMyNines/AWS-Pullomi-Organization-Configuration/internal/state"
+)
+
+// State types
+type (
+	StateManager = state.StateManager
+	StateData    = config.StateData
+	StateError   = config.StateError
+	Backend      = state.Backend
+)
+
+// ErrConcurrentWrite reports a state replaced by another writer of the S3 backend
+var ErrConcurrentWrite = state.ErrConcurrentWrite
+
+// Classes of AWS API errors, matched with errors.Is
+var (
+	ErrThrottled     = awsclient.ErrThrottled
+	ErrAccessDenied  = awsclient.ErrAccessDenied
+	ErrAlreadyExists = awsclient.ErrAlreadyExists
+	ErrLimitExceeded = awsclient.ErrLimitExceeded
+)
+
+// Manager defines the state operations guaranteed by the public API
+type Manager interface {
+	Save(ctx context.Context, state interface{}) error
+	Load(ctx context.Context) (*StateData, error)
+	CreateBackup(ctx context.Context) (string, error)
+	CleanupOldStates(ctx context.Context) error
+	Close() error
+}
+
+var _ Manager = (*StateManager)(nil)
+
+// NewManager creates a state manager backed by DynamoDB and S3
+func NewManager(ctx context.Context, opts ...func(*StateManager) error) (*StateManager, error) {
+	return state.NewManager(ctx, opts...)
+}
+
+// WithKMSKey encrypts the state and its backups with a customer managed KMS key
+func WithKMSKey(keyArn string) func(*StateManager) error {
+	return state.WithKMSKey(keyArn)
+}
+
+// WithBackend selects the backend storing the state: dynamodb, s3, local or none
+func WithBackend(backendType string) func(*StateManager) error {
+	return state.WithBackend(backendType)
+}
+
+// WithStateFile sets the path of the state file of the local backend
+func WithStateFile(path string) func(*StateManager) error {
+	return state.WithStateFile(path)
+}